@@ -9,8 +9,32 @@ curl "https://s3.amazonaws.com/aws-cli/awscli-bundle.zip" -o "awscli-bundle.zip"
 unzip awscli-bundle.zip
 ./awscli-bundle/install -i /usr/local/aws -b /usr/bin/aws
 
-mkfs.ext4 /dev/xvdc
-echo -e '/dev/xvdc\t/var/lib/gravity/planet/etcd\text4\tdefaults\t0\t2' >> /etc/fstab
+# ebs_device prints the device of the EBS volume attached as $1.
+# Nitro instances (i.e. arm64) expose the EBS volumes as NVMe devices,
+# which are looked up by the volume ID
+ebs_device() {
+  if [ -b "$1" ]; then
+    echo "$1"
+    return
+  fi
+  local metadata=http://169.254.169.254/latest/meta-data
+  local instance zone volume device
+  instance=$(curl -sf $metadata/instance-id)
+  zone=$(curl -sf $metadata/placement/availability-zone)
+  volume=$(aws ec2 describe-volumes --region "${zone%?}" \
+    --filters "Name=attachment.instance-id,Values=$instance" "Name=attachment.device,Values=$1" \
+    --query 'Volumes[0].VolumeId' --output text)
+  device="/dev/disk/by-id/nvme-Amazon_Elastic_Block_Store_${volume/-/}"
+  for _ in $(seq 30); do
+    [ -b "$device" ] && break
+    sleep 1
+  done
+  echo "$device"
+}
+
+etcd_device=$(ebs_device /dev/xvdc)
+mkfs.ext4 "$etcd_device"
+echo -e "$etcd_device\t/var/lib/gravity/planet/etcd\text4\tdefaults\t0\t2" >> /etc/fstab
 
 mkdir -p /var/lib/gravity/planet/etcd /var/lib/data
 mount /var/lib/gravity/planet/etcd
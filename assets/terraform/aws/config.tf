@@ -1,5 +1,9 @@
 variable "access_key" {}
 variable "secret_key" {}
+variable "session_token" {
+	description = "session token of temporary credentials"
+	default = ""
+}
 variable "ssh_user" {}
 variable "key_pair" {}
 variable "region" {}
@@ -17,16 +21,85 @@ variable "os" {
 	description = "ubuntu | redhat | centos | debian"
 }
 
+variable "tags" {
+  description = "additional tags to attach to all resources (owner, run-id, suite, expiry)"
+  type = "map"
+  default = {}
+}
+
+variable "extra_disk_sizes" {
+  description = "sizes in GB of the additional devices attached to each node"
+  type = "list"
+  default = []
+}
+
+variable "extra_disk_types" {
+  description = "EBS volume types of the additional devices"
+  type = "list"
+  default = []
+}
+
+variable "extra_disk_devices" {
+  description = "device names of the additional devices"
+  type = "list"
+  default = []
+}
+
+variable "subnets" {
+  description = "number of subnets (availability zones) to spread the nodes over"
+  default = 1
+}
+
+variable "ipv6" {
+  description = "assign an IPv6 address to each node (dual-stack)"
+  default = false
+}
+
+variable "arch" {
+  description = "CPU architecture of the nodes: amd64 | arm64"
+  default = "amd64"
+}
+
 variable "instance_type" {
   default = "c3.xlarge"
 }
 
+variable "root_volume_size" {
+  description = "size of the OS volume in GB"
+  default = "60"
+}
+
+variable "docker_volume_type" {
+  description = "EBS volume type of the gravity/docker data device"
+  default = "gp2"
+}
+
+variable "docker_volume_size" {
+  description = "size of the gravity/docker data device in GB"
+  default = "80"
+}
+
+variable "etcd_volume_size" {
+  description = "size of the etcd device in GB"
+  default = "30"
+}
+
+variable "etcd_volume_iops" {
+  description = "provisioned IOPS of the etcd device"
+  default = 1500
+}
+
 provider "aws" {
   access_key = "${var.access_key}"
   secret_key = "${var.secret_key}"
+  token = "${var.session_token}"
   region = "${var.region}"
 }
 
+data "aws_availability_zones" "available" {
+  state = "available"
+}
+
 resource "aws_placement_group" "cluster" {
   name = "${var.cluster_name}"
   strategy = "cluster"
@@ -1,8 +1,6 @@
 # ALL UDP and TCP traffic is allowed within the security group
 resource "aws_security_group" "cluster" {
-    tags {
-        Name = "${var.cluster_name}"
-    }
+    tags = "${merge(var.tags, map("Name", var.cluster_name, "Origin", "robotest"))}"
 
     # SSH
     ingress {
@@ -197,5 +195,6 @@ resource "aws_security_group" "cluster" {
       to_port = 0
       protocol = "-1"
       cidr_blocks = ["0.0.0.0/0"]
+      ipv6_cidr_blocks = ["::/0"]
     }    
 }
\ No newline at end of file
@@ -1,36 +1,38 @@
 
 resource "aws_instance" "node" {
-    ami                  = "${lookup(var.ami, var.os)}"
+    ami                  = "${var.image != "" ? var.image : (var.arch == "arm64" ? element(concat(data.aws_ami.arm64.*.id, list("")), 0) : lookup(var.ami, local.os_vendor))}"
     instance_type        = "${var.instance_type}"
     source_dest_check    = "false"
     ebs_optimized        = true
     security_groups      = ["${aws_security_group.cluster.name}"]
     key_name             = "${var.key_pair}"
-    placement_group      = "${aws_placement_group.cluster.id}"
+    # cluster placement groups cannot span availability zones
+    placement_group      = "${var.subnets > 1 ? "" : aws_placement_group.cluster.id}"
+    # nodes are placed round-robin into the default subnets of the availability zones
+    availability_zone    = "${var.subnets > 1 ? element(data.aws_availability_zones.available.names, count.index % var.subnets) : ""}"
     count                = "${var.nodes}"
     iam_instance_profile = "robotest-node"
     associate_public_ip_address = true
+    ipv6_address_count   = "${var.ipv6 ? 1 : 0}"
 
-    tags {
-        Name = "${var.cluster_name}"
-        Origin = "robotest"
-    }
+    tags = "${merge(var.tags, map("Name", var.cluster_name, "Origin", "robotest"))}"
+    volume_tags = "${merge(var.tags, map("Name", var.cluster_name, "Origin", "robotest"))}"
 
-    user_data = "${file("./bootstrap/${var.os}.sh")}"
+    user_data = "${file("./bootstrap/${local.os_vendor}.sh")}"
 
     # OS
     # /var/lib/gravity device
     # /var/lib/data device
     root_block_device {
         volume_type = "gp2"
-        volume_size = "60"
+        volume_size = "${var.root_volume_size}"
         delete_on_termination = true
     }
 
     # gravity/docker data device
     ebs_block_device = {
-        volume_type = "gp2"
-        volume_size = "80"
+        volume_type = "${var.docker_volume_type}"
+        volume_size = "${var.docker_volume_size}"
         device_name = "${var.docker_device}"
         delete_on_termination = true
     }
@@ -38,9 +40,25 @@ resource "aws_instance" "node" {
     # etcd device
     ebs_block_device = {
         volume_type = "io1"
-        iops = 1500
-        volume_size = "30"
+        iops = "${var.etcd_volume_iops}"
+        volume_size = "${var.etcd_volume_size}"
         device_name = "/dev/xvdc"
         delete_on_termination = true
     }
-}
\ No newline at end of file
+}
+# additional devices, extra_disk_* lists are ordered by the disk index
+resource "aws_ebs_volume" "extra" {
+    count             = "${var.nodes * length(var.extra_disk_sizes)}"
+    availability_zone = "${element(aws_instance.node.*.availability_zone, count.index / length(var.extra_disk_sizes))}"
+    size              = "${element(var.extra_disk_sizes, count.index % length(var.extra_disk_sizes))}"
+    type              = "${element(var.extra_disk_types, count.index % length(var.extra_disk_sizes))}"
+    tags              = "${merge(var.tags, map("Name", var.cluster_name, "Origin", "robotest"))}"
+}
+
+resource "aws_volume_attachment" "extra" {
+    count       = "${var.nodes * length(var.extra_disk_sizes)}"
+    device_name = "${element(var.extra_disk_devices, count.index % length(var.extra_disk_sizes))}"
+    volume_id   = "${element(aws_ebs_volume.extra.*.id, count.index)}"
+    instance_id = "${element(aws_instance.node.*.id, count.index / length(var.extra_disk_sizes))}"
+    force_detach = true
+}
@@ -1,5 +1,16 @@
 # AMI IDs are region specific
 # note each AMI is coming with preset username
+# robotest can override the AMI with the image variable
+
+variable "image" {
+  description = "AMI ID to use instead of the default AMI for the OS"
+  default = ""
+}
+
+locals {
+  # os is given as vendor:version
+  os_vendor = "${element(split(":", var.os), 0)}"
+}
 
 variable "ami" {
   default = {
@@ -8,4 +19,39 @@ variable "ami" {
     centos = "ami-6d1c2007"
     debian = "ami-b14ba7a7"
   }
+}
+
+# arm64 (Graviton) images are looked up as the latest image of the OS family
+variable "ami_arm64_owners" {
+  default = {
+    ubuntu = "099720109477"
+    redhat = "309956199498"
+    centos = "125523088429"
+    debian = "136693071363"
+  }
+}
+
+variable "ami_arm64_names" {
+  default = {
+    ubuntu = "ubuntu/images/hvm-ssd/ubuntu-bionic-18.04-arm64-server-*"
+    redhat = "RHEL-7.*_HVM-*-arm64-*"
+    centos = "CentOS 7.* aarch64*"
+    debian = "debian-10-arm64-*"
+  }
+}
+
+data "aws_ami" "arm64" {
+  count       = "${var.arch == "arm64" && var.image == "" ? 1 : 0}"
+  most_recent = true
+  owners      = ["${lookup(var.ami_arm64_owners, local.os_vendor)}"]
+
+  filter {
+    name   = "name"
+    values = ["${lookup(var.ami_arm64_names, local.os_vendor)}"]
+  }
+
+  filter {
+    name   = "architecture"
+    values = ["arm64"]
+  }
 }
\ No newline at end of file
@@ -5,3 +5,19 @@ output "private_ips" {
 output "public_ips" {
   value = "${join(" ", aws_instance.node.*.public_ip)}"
 }
+
+output "subnets" {
+  value = ["${aws_instance.node.*.subnet_id}"]
+}
+
+output "ipv6_ips" {
+  value = ["${flatten(aws_instance.node.*.ipv6_addresses)}"]
+}
+
+output "zones" {
+  value = ["${aws_instance.node.*.availability_zone}"]
+}
+
+output "instance_types" {
+  value = ["${aws_instance.node.*.instance_type}"]
+}
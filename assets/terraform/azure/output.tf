@@ -10,3 +10,7 @@ output "private_ips" {
 output "public_ips" {
   value = "${join(" ", data.azurerm_public_ip.node.*.ip_address)}"
 }
+
+output "instance_types" {
+  value = ["${azurerm_virtual_machine.node.*.vm_size}"]
+}
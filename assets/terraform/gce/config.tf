@@ -58,6 +58,48 @@ variable "os" {
   type        = string
 }
 
+variable "labels" {
+  description = "Additional labels to attach to all resources (owner, run-id, suite, expiry)"
+  type        = map(string)
+  default     = {}
+}
+
+variable "ipv6" {
+  description = "Assign an internal IPv6 address to each node (dual-stack)"
+  type        = bool
+  default     = false
+}
+
+variable "subnets" {
+  description = "Number of subnets to spread the nodes over"
+  type        = number
+  default     = 1
+}
+
+variable "subnet_cidrs" {
+  description = "CIDR blocks of the additional subnets (subnets - 1), or of all subnets with ipv6 as the robotest subnet is IPv4-only"
+  type        = list(string)
+  default     = []
+}
+
+variable "extra_disk_sizes" {
+  description = "Sizes in GB of the additional disks attached to each node"
+  type        = list(string)
+  default     = []
+}
+
+variable "extra_disk_types" {
+  description = "Types of the additional disks"
+  type        = list(string)
+  default     = []
+}
+
+variable "arch" {
+  description = "CPU architecture of the nodes: amd64 | arm64. arm64 requires a T2A VM type"
+  type        = string
+  default     = "amd64"
+}
+
 variable "disk_type" {
   description = "Disk type for VM. See https://cloud.google.com/compute/docs/disks"
   type        = string
@@ -109,6 +109,72 @@ data "google_compute_subnetwork" "robotest" {
   name = "robotest"
 }
 
+# Additional subnets for multi-subnet clusters, the first subnet is the robotest subnet.
+# The robotest subnet is IPv4-only, so with ipv6 all subnets are dedicated dual-stack subnets
+resource "google_compute_subnetwork" "extra" {
+  count         = length(var.subnet_cidrs)
+  name          = "${var.node_tag}-subnet-${count.index + 1}"
+  network       = data.google_compute_network.robotest.self_link
+  region        = var.region
+  ip_cidr_range = var.subnet_cidrs[count.index]
+
+  stack_type       = var.ipv6 ? "IPV4_IPV6" : "IPV4_ONLY"
+  ipv6_access_type = var.ipv6 ? "INTERNAL" : null
+}
+
+locals {
+  subnets = var.ipv6 ? google_compute_subnetwork.extra.*.self_link : concat([data.google_compute_subnetwork.robotest.self_link], google_compute_subnetwork.extra.*.self_link)
+}
+
+# Allow cluster traffic between the subnets
+resource "google_compute_firewall" "subnets" {
+  count       = length(var.subnet_cidrs) > 0 ? 1 : 0
+  name        = "${var.node_tag}-subnets"
+  description = "Robotest cluster traffic across subnets"
+  network     = data.google_compute_network.robotest.self_link
+
+  source_ranges = concat([data.google_compute_subnetwork.robotest.ip_cidr_range], var.subnet_cidrs)
+  target_tags   = [for i in range(var.nodes) : "${var.node_tag}-node-${i}"]
+
+  allow {
+    protocol = "icmp"
+  }
+
+  allow {
+    protocol = "tcp"
+  }
+
+  allow {
+    protocol = "udp"
+  }
+}
+
+# Route the egress traffic of the additional subnets through Cloud NAT
+# so their nodes can reach the internet independently of the robotest subnet routes
+resource "google_compute_router" "subnets" {
+  count   = length(var.subnet_cidrs) > 0 ? 1 : 0
+  name    = "${var.node_tag}-router"
+  network = data.google_compute_network.robotest.self_link
+  region  = var.region
+}
+
+resource "google_compute_router_nat" "subnets" {
+  count                              = length(var.subnet_cidrs) > 0 ? 1 : 0
+  name                               = "${var.node_tag}-nat"
+  router                             = google_compute_router.subnets[0].name
+  region                             = var.region
+  nat_ip_allocate_option             = "AUTO_ONLY"
+  source_subnetwork_ip_ranges_to_nat = "LIST_OF_SUBNETWORKS"
+
+  dynamic "subnetwork" {
+    for_each = google_compute_subnetwork.extra
+    content {
+      name                    = subnetwork.value.self_link
+      source_ip_ranges_to_nat = ["ALL_IP_RANGES"]
+    }
+  }
+}
+
 # # TODO: propagate to gravity as `--pod-cidr` and `--service-cidr`
 # resource "google_compute_subnetwork" "robotest" {
 #   network = "${data.google_compute_network.robotest.self_link}"
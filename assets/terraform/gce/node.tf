@@ -22,12 +22,14 @@ resource "google_compute_instance" "node" {
     "${var.node_tag}-node-${count.index}",
   ]
 
-  labels = {
+  labels = merge(var.labels, {
     cluster = var.node_tag
-  }
+  })
 
   network_interface {
-    subnetwork = data.google_compute_subnetwork.robotest.self_link
+    # nodes are placed round-robin into the subnets
+    subnetwork = element(local.subnets, count.index % var.subnets)
+    stack_type = var.ipv6 ? "IPV4_IPV6" : "IPV4_ONLY"
 
     access_config {
       # Ephemeral IP
@@ -63,11 +65,12 @@ resource "google_compute_instance" "node" {
 
   metadata_startup_script = data.template_file.bootstrap.rendered
 
-  min_cpu_platform = "Intel Skylake"
+  # T2A (arm64) VMs only come with Ampere Altra
+  min_cpu_platform = var.arch == "arm64" ? null : "Intel Skylake"
 
   boot_disk {
     initialize_params {
-      image = var.oss[var.os]
+      image = local.image
       size  = 64
       type  = var.disk_type
     }
@@ -80,6 +83,16 @@ resource "google_compute_instance" "node" {
     mode   = "READ_WRITE"
   }
 
+  # Additional disks are available as /dev/disk/by-id/google-extra-<index>
+  dynamic "attached_disk" {
+    for_each = range(length(var.extra_disk_sizes))
+    content {
+      source      = google_compute_disk.extra[count.index * length(var.extra_disk_sizes) + attached_disk.value].self_link
+      device_name = "extra-${attached_disk.value}"
+      mode        = "READ_WRITE"
+    }
+  }
+
   service_account {
     # TODO: consider using robotest-specific service account instead of
     # the default service account
@@ -107,9 +120,21 @@ resource "google_compute_disk" "etcd" {
   zone  = local.zone
   size  = 50
 
-  labels = {
+  labels = merge(var.labels, {
     cluster = var.node_tag
-  }
+  })
+}
+
+resource "google_compute_disk" "extra" {
+  count = var.nodes * length(var.extra_disk_sizes)
+  name  = "${var.node_tag}-disk-extra-${count.index}"
+  type  = var.extra_disk_types[count.index % length(var.extra_disk_sizes)]
+  zone  = local.zone
+  size  = var.extra_disk_sizes[count.index % length(var.extra_disk_sizes)]
+
+  labels = merge(var.labels, {
+    cluster = var.node_tag
+  })
 }
 
 data "template_file" "bootstrap" {
@@ -24,3 +24,29 @@ variable "oss" {
   }
 }
 
+variable "oss_arm64" {
+  description = "Map of supported Linux distributions on arm64 (T2A) VMs"
+  type        = map(string)
+
+  default = {
+    # os -> {project}/{image family}
+    "ubuntu:18"     = "ubuntu-os-cloud/ubuntu-1804-lts-arm64"
+    "ubuntu:20"     = "ubuntu-os-cloud/ubuntu-2004-lts-arm64"
+    "ubuntu:latest" = "ubuntu-os-cloud/ubuntu-2004-lts-arm64"
+    "debian:11"     = "debian-cloud/debian-11-arm64"
+    "debian:latest" = "debian-cloud/debian-11-arm64"
+    "suse:15"       = "suse-cloud/sles-15-arm64"
+    "suse:latest"   = "suse-cloud/sles-15-arm64"
+  }
+}
+
+variable "image" {
+  description = "Image ({project}/{image}) to use instead of the default image for the OS"
+  type        = string
+  default     = ""
+}
+
+locals {
+  image = var.image != "" ? var.image : (var.arch == "arm64" ? lookup(var.oss_arm64, var.os, "") : lookup(var.oss, var.os, ""))
+}
+
@@ -9,3 +9,19 @@ output "private_ips" {
 output "public_ips" {
   value = google_compute_instance.node.*.network_interface.0.access_config.0.nat_ip
 }
+
+output "subnets" {
+  value = google_compute_instance.node.*.network_interface.0.subnetwork
+}
+
+output "ipv6_ips" {
+  value = var.ipv6 ? google_compute_instance.node.*.network_interface.0.ipv6_address : []
+}
+
+output "zones" {
+  value = google_compute_instance.node.*.zone
+}
+
+output "instance_types" {
+  value = google_compute_instance.node.*.machine_type
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/gravitational/robotest/infra/gravity"
+
+	"github.com/gravitational/trace"
+)
+
+// runCompare prints the differences of a run from a baseline run given their JSON reports
+func runCompare(args []string) error {
+	flags := flag.NewFlagSet("compare", flag.ExitOnError)
+	failOnRegression := flags.Bool("fail-on-regression", false, "Exit with an error if a test passed in the baseline run but not in the run")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %v compare [flags] <baseline report> <report>\n\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return trace.BadParameter("expected the baseline report and the report")
+	}
+
+	baseline, err := gravity.ReadReport(flags.Arg(0))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	report, err := gravity.ReadReport(flags.Arg(1))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	diff := gravity.CompareReports(*baseline, *report)
+	fmt.Printf("%v %v -> %v %v\n\n", baseline.Suite, baseline.Tag, report.Suite, report.Tag)
+	fmt.Print(diff)
+	if *failOnRegression && diff.Regressed() {
+		return trace.CompareFailed("tests regressed")
+	}
+	return nil
+}
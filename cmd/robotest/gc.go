@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gravitational/robotest/infra/gc"
+
+	"github.com/gravitational/trace"
+	log "github.com/sirupsen/logrus"
+)
+
+// runGC lists and destroys the cloud resources of runs older than the TTL
+func runGC(args []string) error {
+	flags := flag.NewFlagSet("gc", flag.ExitOnError)
+	ttl := flags.Duration("ttl", 24*time.Hour, "Age after which resources of a run are considered leaked")
+	dryRun := flags.Bool("dry-run", false, "Only list the resources that would be destroyed")
+	timeout := flags.Duration("timeout", time.Hour, "Timeout for the whole collection")
+	awsRegions := flags.String("aws-regions", "", "Comma-separated list of AWS regions to collect resources in. Credentials are read from the environment")
+	awsStateBucket := flags.String("aws-state-bucket", "", "S3 bucket with the terraform state of the runs")
+	awsStatePrefix := flags.String("aws-state-prefix", "", "Key prefix of the terraform state in the S3 bucket")
+	gceProject := flags.String("gce-project", "", "GCE project to collect resources in")
+	gceCredentials := flags.String("gce-credentials", os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"), "GCE service account file")
+	gceStateBucket := flags.String("gce-state-bucket", "", "GCS bucket with the terraform state of the runs")
+	gceStatePrefix := flags.String("gce-state-prefix", "", "Object prefix of the terraform state in the GCS bucket")
+	debug := flags.Bool("debug", false, "Verbose mode")
+	flags.Parse(args)
+
+	if *debug {
+		log.SetLevel(log.DebugLevel)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	var collectors []gc.Collector
+	for _, region := range strings.Split(*awsRegions, ",") {
+		region = strings.TrimSpace(region)
+		if region == "" {
+			continue
+		}
+		collector, err := gc.NewAWS(gc.AWSConfig{
+			Region:      region,
+			StateBucket: *awsStateBucket,
+			StatePrefix: *awsStatePrefix,
+		})
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		collectors = append(collectors, collector)
+	}
+	if *gceProject != "" {
+		collector, err := gc.NewGCE(ctx, gc.GCEConfig{
+			Project:     *gceProject,
+			Credentials: *gceCredentials,
+			StateBucket: *gceStateBucket,
+			StatePrefix: *gceStatePrefix,
+		})
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		collectors = append(collectors, collector)
+	}
+	if len(collectors) == 0 {
+		return trace.BadParameter("specify at least one of -aws-regions or -gce-project")
+	}
+
+	expired, err := gc.Run(ctx, gc.Config{
+		Collectors:  collectors,
+		TTL:         *ttl,
+		DryRun:      *dryRun,
+		FieldLogger: log.StandardLogger(),
+	})
+	for _, resource := range expired {
+		fmt.Printf("%-16v %-40v %-16v %-30v %v\n", resource.Kind, resource.ID,
+			resource.Location, resource.RunID, resource.Created.Format(time.RFC3339))
+	}
+	return trace.Wrap(err)
+}
@@ -0,0 +1,81 @@
+// Command robotest implements maintenance commands for robotest runs
+// that are not tied to a specific test suite.
+//
+// Usage:
+//
+//   robotest <command> [flags]
+//
+// Commands:
+//
+//   compare  print the differences of a run from a baseline run given their JSON reports
+//   gc       destroy cloud resources leaked by interrupted test runs
+//   report   render the standalone HTML report of a run given its JSON report
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/gravitational/trace"
+	log "github.com/sirupsen/logrus"
+)
+
+// command is a robotest subcommand
+type command struct {
+	// description is a short description of the command
+	description string
+	// run executes the command with the specified arguments
+	run func(args []string) error
+}
+
+var commands = map[string]command{
+	"compare": {
+		description: "print the differences of a run from a baseline run given their JSON reports",
+		run:         runCompare,
+	},
+	"gc": {
+		description: "destroy cloud resources leaked by interrupted test runs",
+		run:         runGC,
+	},
+	"report": {
+		description: "render the standalone HTML report of a run given its JSON report",
+		run:         runReport,
+	},
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
+	err := cmd.run(flag.Args()[1:])
+	if err != nil {
+		log.Error(trace.DebugReport(err))
+		fmt.Fprintln(os.Stderr, trace.UserMessage(err))
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %v <command> [flags]\n\nCommands:\n", os.Args[0])
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10v %v\n", name, commands[name].description)
+	}
+}
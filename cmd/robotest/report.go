@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gravitational/robotest/infra/gravity"
+
+	"github.com/gravitational/trace"
+)
+
+// runReport renders the standalone HTML report of a run given its JSON report
+func runReport(args []string) error {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	output := flags.String("o", "", "HTML file to write, defaults to the report with the .html extension")
+	uiArtifacts := flags.String("ui-artifacts", "", "Report directory of the UI tests to embed the failure artifacts (screenshots, console logs) of")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %v report [flags] <report>\n\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return trace.BadParameter("expected the report")
+	}
+
+	report, err := gravity.ReadReport(flags.Arg(0))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	path := *output
+	if path == "" {
+		path = strings.TrimSuffix(flags.Arg(0), ".json") + ".html"
+	}
+	err = gravity.WriteHTMLReport(path, gravity.HTMLReportConfig{
+		Report:      *report,
+		UIArtifacts: *uiArtifacts,
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	fmt.Println(path)
+	return nil
+}
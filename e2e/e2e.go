@@ -29,19 +29,39 @@ func RunE2ETests(t *testing.T) {
 	ginkgo.RunSpecs(t, "Robotest e2e suite")
 }
 
-// Run the tasks that are meant to be run once per invocation
+// Run the tasks that are meant to be run once per invocation,
+// or once per ginkgo node when running with a cluster pool
 var _ = ginkgo.SynchronizedBeforeSuite(func() []byte {
 	// Run only on ginkgo node 1
-	framework.CreateDriver()
-	framework.InitializeCluster()
+	if !framework.ClusterPoolEnabled() {
+		setup()
+	}
 	return nil
 }, func([]byte) {
+	// Run on all ginkgo nodes
+	if framework.ClusterPoolEnabled() {
+		setup()
+	}
 })
 
 var _ = ginkgo.SynchronizedAfterSuite(func() {
 	// Run on all ginkgo nodes
+	if framework.ClusterPoolEnabled() {
+		cleanup()
+	}
 }, func() {
 	// Run only on ginkgo node 1
+	if !framework.ClusterPoolEnabled() {
+		cleanup()
+	}
+})
+
+func setup() {
+	framework.CreateDriver()
+	framework.InitializeCluster()
+}
+
+func cleanup() {
 	if framework.TestContext.DumpCore {
 		framework.CoreDump()
 		return
@@ -57,4 +77,4 @@ var _ = ginkgo.SynchronizedAfterSuite(func() {
 		}
 		framework.Destroy()
 	}
-})
+}
@@ -9,17 +9,15 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
 
 	"github.com/gravitational/robotest/infra"
-	"github.com/gravitational/robotest/lib/system"
+	"github.com/gravitational/robotest/lib/loc"
 
 	"github.com/gravitational/trace"
 	semver "github.com/hashicorp/go-version"
-	"github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"gopkg.in/yaml.v2"
 )
@@ -27,10 +25,11 @@ import (
 // FakeUpdateApplication implements site update test by downloading the application tarball,
 // incrementing the version and importing the same tarball with a new version.
 //
-// It downloads the update from one of the remote nodes before returning to ensure
-// that the application update is available
+// The tarball is exported and imported with the Ops Center API, so no local gravity
+// binary is required
 func FakeUpdateApplication() {
-	Expect(ConnectToOpsCenter(TestContext.OpsCenterURL, TestContext.ServiceLogin)).To(Succeed())
+	client, err := ConnectToOpsCenter(TestContext.OpsCenterURL, TestContext.ServiceLogin)
+	Expect(err).NotTo(HaveOccurred())
 	Expect(TestContext.Application.Locator).NotTo(BeNil(), "expected a valid application package")
 
 	nodes := Cluster.Provisioner().NodePool().AllocatedNodes()
@@ -38,16 +37,13 @@ func FakeUpdateApplication() {
 		Failf("expected active nodes in cluster, got none")
 	}
 
-	stateDir := fmt.Sprintf("--state-dir=%v", TestContext.StateDir)
-	opsURL := fmt.Sprintf("--ops-url=%v", TestContext.OpsCenterURL)
+	ctx, cancel := context.WithTimeout(context.Background(), opsCenterRequestTimeout)
+	defer cancel()
 	outputPath := filepath.Join(TestContext.StateDir, "app.tar.gz")
-	cmd := exec.Command("gravity", "--insecure", stateDir, "package", "export",
-		opsURL, TestContext.Application.String(), outputPath)
-	Expect(system.Exec(cmd, os.Stderr)).To(Succeed())
+	Expect(exportPackage(ctx, client, *TestContext.Application.Locator, outputPath)).To(Succeed())
 
 	versionS := TestContext.Application.Version
 	if versionS == latestMetaversion {
-		var err error
 		versionS, err = getResourceVersion(outputPath)
 		Expect(err).NotTo(HaveOccurred(), "expected to query application package version from tarball")
 	}
@@ -56,17 +52,19 @@ func FakeUpdateApplication() {
 	Expect(err).NotTo(HaveOccurred(),
 		fmt.Sprintf("expected a version in semver format, got %q", TestContext.Application.Version))
 
-	bumpedVersion := bump(*version)
-	bumpedVersionParam := fmt.Sprintf("--version=%v", bumpedVersion)
 	// Import the same package with a new version to emulate update
-	cmd = exec.Command("gravity", "--insecure", stateDir, "app", "import", opsURL, bumpedVersionParam, outputPath)
-	Expect(system.Exec(cmd, os.Stderr)).To(Succeed())
-	testState.Application.Version = bumpedVersion
+	bumped := *TestContext.Application.Locator
+	bumped.Version = bump(*version)
+	bumpedPath := filepath.Join(TestContext.StateDir, "app-update.tar.gz")
+	Expect(writeResourceVersion(outputPath, bumpedPath, bumped.Version)).To(Succeed())
+	Expect(importPackage(ctx, client, bumped, bumpedPath)).To(Succeed())
+	testState.Application.Version = bumped.Version
 }
 
 // UpdateApplicationWithInstaller impements site update via installer tarball
 func UpdateApplicationWithInstaller() {
-	Expect(ConnectToOpsCenter(TestContext.OpsCenterURL, TestContext.ServiceLogin)).To(Succeed())
+	_, err := ConnectToOpsCenter(TestContext.OpsCenterURL, TestContext.ServiceLogin)
+	Expect(err).NotTo(HaveOccurred())
 	Expect(testState.ProvisionerState.InstallerAddr).NotTo(BeNil(), "expected a valid installer address")
 
 	provisioner := Cluster.Provisioner()
@@ -79,7 +77,8 @@ func UpdateApplicationWithInstaller() {
 
 // BackupApplication implements test for backup hook
 func BackupApplication() {
-	Expect(ConnectToOpsCenter(TestContext.OpsCenterURL, TestContext.ServiceLogin)).To(Succeed())
+	_, err := ConnectToOpsCenter(TestContext.OpsCenterURL, TestContext.ServiceLogin)
+	Expect(err).NotTo(HaveOccurred())
 	Expect(TestContext.Application.Locator).NotTo(BeNil(), "expected a valid application package")
 	Expect(TestContext.Extensions.BackupConfig.Addr).NotTo(BeNil(), "expect valid node address for backup operation")
 	Expect(TestContext.Extensions.BackupConfig.Path).NotTo(BeNil(), "expect valid path to backup file")
@@ -94,7 +93,8 @@ func BackupApplication() {
 
 // RestoreApplication implements test for restore hook
 func RestoreApplication() {
-	Expect(ConnectToOpsCenter(TestContext.OpsCenterURL, TestContext.ServiceLogin)).To(Succeed())
+	_, err := ConnectToOpsCenter(TestContext.OpsCenterURL, TestContext.ServiceLogin)
+	Expect(err).NotTo(HaveOccurred())
 	Expect(TestContext.Application.Locator).NotTo(BeNil(), "expected a valid application package")
 	Expect(testState.BackupState.Addr).NotTo(BeNil(), "expect valid node address for restore operation")
 	Expect(testState.BackupState.Path).NotTo(BeNil(), "expect valid path to backup file")
@@ -107,11 +107,140 @@ func RestoreApplication() {
 
 // ConnectToOpsCenter connects to the Ops Center specified with opsCenterURL using
 // specified login
-func ConnectToOpsCenter(opsCenterURL string, login ServiceLogin) error {
-	stateDir := fmt.Sprintf("--state-dir=%v", TestContext.StateDir)
-	cmd := exec.Command("gravity", "--insecure", stateDir, "ops", "connect", opsCenterURL,
-		login.Username, login.Password)
-	return trace.Wrap(system.Exec(cmd, io.MultiWriter(os.Stderr, ginkgo.GinkgoWriter)))
+func ConnectToOpsCenter(opsCenterURL string, login ServiceLogin) (*OpsCenterClient, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), opsCenterRequestTimeout)
+	defer cancel()
+	client, err := NewOpsCenterClient(ctx, opsCenterURL, login)
+	return client, trace.Wrap(err)
+}
+
+// exportPackage downloads the package given with locator into the file at path
+func exportPackage(ctx context.Context, client *OpsCenterClient, locator loc.Locator, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	defer f.Close()
+	if err := client.ExportPackage(ctx, locator, f); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.ConvertSystemError(f.Close())
+}
+
+// importPackage uploads the package from the file at path as the package given with locator
+func importPackage(ctx context.Context, client *OpsCenterClient, locator loc.Locator, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	defer f.Close()
+	return trace.Wrap(client.ImportPackage(ctx, locator, f))
+}
+
+// writeResourceVersion copies the application tarball at srcPath to dstPath
+// with the resource version in the application manifest set to version
+func writeResourceVersion(srcPath, dstPath, version string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	defer src.Close()
+	dst, err := os.Create(dstPath)
+	if err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	defer dst.Close()
+	if err := setResourceVersion(src, dst, version); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.ConvertSystemError(dst.Close())
+}
+
+// setResourceVersion copies the application tarball from src to dst
+// with the resource version in the application manifest set to version.
+// The rest of the manifest and the tarball is copied unchanged
+func setResourceVersion(src io.Reader, dst io.Writer, version string) error {
+	rz, err := gzip.NewReader(src)
+	if err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	defer rz.Close()
+	wz := gzip.NewWriter(dst)
+	r := tar.NewReader(rz)
+	w := tar.NewWriter(wz)
+	var found bool
+	for {
+		hdr, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return trace.ConvertSystemError(err)
+		}
+		if found || !isManifest(hdr) {
+			if err := w.WriteHeader(hdr); err != nil {
+				return trace.Wrap(err)
+			}
+			if _, err := io.Copy(w, r); err != nil {
+				return trace.Wrap(err)
+			}
+			continue
+		}
+		manifestBytes, err := ioutil.ReadAll(r)
+		if err != nil {
+			return trace.ConvertSystemError(err)
+		}
+		manifestBytes, err = replaceResourceVersion(manifestBytes, version)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		hdr.Size = int64(len(manifestBytes))
+		if err := w.WriteHeader(hdr); err != nil {
+			return trace.Wrap(err)
+		}
+		if _, err := w.Write(manifestBytes); err != nil {
+			return trace.Wrap(err)
+		}
+		found = true
+	}
+	if !found {
+		return trace.NotFound("no application manifest in tarball")
+	}
+	if err := w.Close(); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(wz.Close())
+}
+
+// replaceResourceVersion returns the application manifest with the resource version set to version
+func replaceResourceVersion(manifestBytes []byte, version string) ([]byte, error) {
+	var manifest yaml.MapSlice
+	if err := yaml.Unmarshal(manifestBytes, &manifest); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	for i, item := range manifest {
+		if item.Key != "metadata" {
+			continue
+		}
+		metadata, ok := item.Value.(yaml.MapSlice)
+		if !ok {
+			return nil, trace.BadParameter("unexpected application manifest metadata %v", item.Value)
+		}
+		manifest[i].Value = setMapItem(metadata, "resourceVersion", version)
+		return yaml.Marshal(manifest)
+	}
+	return nil, trace.NotFound("no metadata in application manifest")
+}
+
+// setMapItem sets the value of the key in m, adding it if necessary
+func setMapItem(m yaml.MapSlice, key string, value interface{}) yaml.MapSlice {
+	for i, item := range m {
+		if item.Key == key {
+			m[i].Value = value
+			return m
+		}
+	}
+	return append(m, yaml.MapItem{Key: key, Value: value})
 }
 
 // getResourceVersion retrieves the version of the test application
@@ -138,7 +267,7 @@ func getResourceVersion(tarball string) (string, error) {
 			}
 			return "", trace.ConvertSystemError(err)
 		}
-		if strings.HasSuffix(hdr.Name, "app.yaml") {
+		if isManifest(hdr) {
 			var manifestBytes []byte
 			manifestBytes, err = ioutil.ReadAll(r)
 			if err != nil {
@@ -156,6 +285,11 @@ func getResourceVersion(tarball string) (string, error) {
 	return resourceVersion, trace.Wrap(err)
 }
 
+// isManifest returns true if hdr describes the application manifest
+func isManifest(hdr *tar.Header) bool {
+	return strings.HasSuffix(hdr.Name, "app.yaml")
+}
+
 // bump increments the version specified in v by adding 1 to
 // the last segment's value
 func bump(v semver.Version) string {
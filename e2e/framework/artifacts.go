@@ -0,0 +1,72 @@
+package framework
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/gravitational/robotest/lib/constants"
+	"github.com/gravitational/trace"
+
+	web "github.com/sclevine/agouti"
+	log "github.com/sirupsen/logrus"
+)
+
+// captureFailureArtifacts saves a screenshot, the page HTML and the browser
+// console logs of the page under ReportDir in a directory named after the spec.
+// Errors are only logged so that the original test failure is reported
+func captureFailureArtifacts(page *web.Page, specName string) {
+	if page == nil || TestContext.ReportDir == "" {
+		return
+	}
+	dir := filepath.Join(TestContext.ReportDir, "artifacts", specDirName(specName))
+	logger := log.WithField("dir", dir)
+	if err := os.MkdirAll(dir, constants.SharedDirMask); err != nil {
+		logger.WithError(err).Error("Failed to create artifacts directory.")
+		return
+	}
+	logger.Info("Capture failure artifacts.")
+	if err := page.Screenshot(filepath.Join(dir, "screenshot.png")); err != nil {
+		logger.WithError(err).Warn("Failed to capture screenshot.")
+	}
+	if err := savePageHTML(page, filepath.Join(dir, "page.html")); err != nil {
+		logger.WithError(err).Warn("Failed to save page HTML.")
+	}
+	if err := saveBrowserLogs(page, filepath.Join(dir, "console.log")); err != nil {
+		logger.WithError(err).Warn("Failed to save browser console logs.")
+	}
+}
+
+func savePageHTML(page *web.Page, path string) error {
+	html, err := page.HTML()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.ConvertSystemError(ioutil.WriteFile(path, []byte(html), constants.SharedReadMask))
+}
+
+func saveBrowserLogs(page *web.Page, path string) error {
+	logs, err := page.ReadAllLogs("browser")
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	var buf strings.Builder
+	for _, entry := range logs {
+		fmt.Fprintf(&buf, "%v [%v] %v\n", entry.Time.Format("2006-01-02T15:04:05.000Z07:00"), entry.Level, entry.Message)
+	}
+	return trace.ConvertSystemError(ioutil.WriteFile(path, []byte(buf.String()), constants.SharedReadMask))
+}
+
+// specDirName converts the spec name into a file system friendly directory name
+func specDirName(specName string) string {
+	name := strings.Trim(unsafeChars.ReplaceAllString(specName, "_"), "_")
+	if name == "" {
+		return "unnamed"
+	}
+	return name
+}
+
+var unsafeChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
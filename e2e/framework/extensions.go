@@ -11,6 +11,18 @@ type Extensions struct {
 	InstallTimeout duration `json:"install_timeout" yaml:"install_timeout" `
 	// BackupConfig defines configuration for Backup/Restore operations
 	BackupConfig *BackupConfig `json:"backup_config" yaml:"backup_config"`
+	// LicenseRenewal defines configuration for the license expiration test
+	LicenseRenewal *LicenseRenewal `json:"license_renewal" yaml:"license_renewal"`
+}
+
+// LicenseRenewal defines configuration for the license expiration and renewal test.
+// The cluster is expected to be installed with a short-lived license
+type LicenseRenewal struct {
+	// License specifies the renewed license to upload once the license has expired
+	License string `json:"license" yaml:"license"`
+	// ExpiryTimeout specifies the time to wait for the install license to expire.
+	// Defaults to uimodel/defaults.SiteLicenseExpiryTimeout if unspecified
+	ExpiryTimeout duration `json:"expiry_timeout" yaml:"expiry_timeout"`
 }
 
 // BackupConfig defines configuration for Backup/Restore operations
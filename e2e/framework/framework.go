@@ -41,6 +41,8 @@ func New() *T {
 // Framework stores attributes common to a single context
 type T struct {
 	Page *web.Page
+	// recorder records the browser session of the current spec
+	recorder *recorder
 }
 
 // BeforeEach emulates BeforeAll for a context.
@@ -52,9 +54,29 @@ func (r *T) BeforeEach() {
 		r.Page, err = newPage()
 		Expect(err).NotTo(HaveOccurred())
 	}
+	if TestContext.VideoRecording != nil {
+		var err error
+		r.recorder, err = startRecording(*TestContext.VideoRecording, CurrentGinkgoTestDescription().FullTestText)
+		if err != nil {
+			log.Warnf("failed to start recording: %v", trace.DebugReport(err))
+		}
+	}
 }
 
+// AfterEach captures the state of the web page if the spec has failed
+// and stops the session recording
 func (r *T) AfterEach() {
+	desc := CurrentGinkgoTestDescription()
+	if desc.Failed {
+		captureFailureArtifacts(r.Page, desc.FullTestText)
+	}
+	if r.recorder != nil {
+		err := r.recorder.stop(desc.Failed || TestContext.VideoRecording.KeepPassed)
+		if err != nil {
+			log.Warnf("failed to stop recording: %v", trace.DebugReport(err))
+		}
+		r.recorder = nil
+	}
 }
 
 // CreateDriver creates a new instance of the web driver
@@ -63,7 +85,7 @@ func CreateDriver() {
 		log.Debugf("WebDriverURL specified - skip CreateDriver")
 		return
 	}
-	driver = web.ChromeDriver()
+	driver = TestContext.WebDriver.newDriver()
 	Expect(driver).NotTo(BeNil())
 	Expect(driver.Start()).To(Succeed())
 }
@@ -98,6 +120,36 @@ func InstallerNode() infra.Node {
 	return installerNode
 }
 
+// WizardProcess returns the installer process the wizard is running.
+// Only applicable in wizard mode (TestContext.Wizard == true)
+func WizardProcess() *infra.WizardProcess {
+	wizard, ok := Cluster.(infra.Wizard)
+	if !ok {
+		return nil
+	}
+	return wizard.Process()
+}
+
+// EnsureWizardRunning restarts the wizard installer process if it has exited
+// and updates the entry URL to point to the restarted installer.
+// It is a no-op unless running in wizard mode
+func EnsureWizardRunning() {
+	process := WizardProcess()
+	if process == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaults.WizardRestartTimeout)
+	defer cancel()
+	restarted, err := process.EnsureRunning(ctx)
+	Expect(err).NotTo(HaveOccurred(), "should have a running installer")
+	if !restarted {
+		return
+	}
+	testState.EntryURL = Cluster.OpsCenterURL()
+	TestContext.OpsCenterURL = Cluster.OpsCenterURL()
+	Expect(saveState(withBackup)).To(Succeed())
+}
+
 // InitializeCluster creates infrastructure according to configuration
 func InitializeCluster() {
 	config := infra.Config{ClusterName: TestContext.ClusterName}
@@ -242,6 +294,9 @@ func CoreDump() {
 		return
 	}
 
+	if err := fetchOpsCenterReport(); err != nil {
+		log.Warnf("failed to download the cluster report from the Ops Center: %v", trace.DebugReport(err))
+	}
 	if installerNode != nil {
 		// Collect logs, generated by `gravity report` command
 		err := fetchReportLogs()
@@ -274,6 +329,31 @@ func CoreDump() {
 	}
 }
 
+// fetchOpsCenterReport downloads the cluster report from the Ops Center
+// using the service login
+func fetchOpsCenterReport() error {
+	if TestContext.OpsCenterURL == "" || TestContext.ServiceLogin.IsEmpty() || TestContext.ClusterName == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), opsCenterRequestTimeout)
+	defer cancel()
+	client, err := NewOpsCenterClient(ctx, TestContext.OpsCenterURL, TestContext.ServiceLogin)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	reportFile, err := os.Create(filepath.Join(TestContext.ReportDir, "opscenter-report.tar.gz"))
+	if err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	defer reportFile.Close()
+	err = client.Report(ctx, TestContext.ClusterName, reportFile)
+	if err != nil {
+		os.Remove(reportFile.Name())
+		return trace.Wrap(err)
+	}
+	return nil
+}
+
 func fetchReportLogs() error {
 	reportCmd := fmt.Sprintf("gravity report --file %v", defaults.ReportPath)
 	err := infra.Run(installerNode, reportCmd, os.Stderr)
@@ -334,21 +414,23 @@ func saveState(withBackup backupFlag) error {
 	return nil
 }
 
-func newPage() (*web.Page, error) {
+func newPage() (page *web.Page, err error) {
+	config := TestContext.WebDriver
 	if TestContext.WebDriverURL != "" {
-		return web.NewPage(TestContext.WebDriverURL, web.Desired(web.Capabilities{
-			"chromeOptions": map[string][]string{
-				"args": []string{
-					// There is no GPU inside docker box!
-					"disable-gpu",
-					// Sandbox requires namespace permissions that we don't have on a container
-					"no-sandbox",
-				},
-			},
-			"javascriptEnabled": true,
-		}))
-	}
-	return driver.NewPage()
+		page, err = web.NewPage(TestContext.WebDriverURL, web.Desired(config.capabilities()))
+	} else {
+		page, err = driver.NewPage()
+	}
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if config.WindowWidth != 0 {
+		if err := page.Size(config.WindowWidth, config.WindowHeight); err != nil {
+			page.Destroy()
+			return nil, trace.Wrap(err)
+		}
+	}
+	return page, nil
 }
 
 func newStateDir(clusterName string) (dir string, err error) {
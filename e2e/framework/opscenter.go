@@ -0,0 +1,241 @@
+package framework
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gravitational/robotest/lib/loc"
+
+	"github.com/gravitational/trace"
+)
+
+// OpsCenterClient is a client for the Ops Center API.
+// It authenticates with the service login and does not require
+// a local gravity binary
+type OpsCenterClient struct {
+	baseURL *url.URL
+	login   ServiceLogin
+	client  *http.Client
+}
+
+// OpsCenterSite describes a cluster registered with the Ops Center
+type OpsCenterSite struct {
+	// Domain is the cluster name
+	Domain string `json:"domain"`
+	// State is the cluster state
+	State string `json:"state"`
+	// Provider is the cluster cloud provider
+	Provider string `json:"provider"`
+}
+
+// OpsCenterOperation describes a cluster operation
+type OpsCenterOperation struct {
+	// ID is the operation ID
+	ID string `json:"id"`
+	// Type is the operation type, i.e. operation_install
+	Type string `json:"type"`
+	// State is the operation state
+	State string `json:"state"`
+}
+
+// Completed returns true if the operation has completed successfully
+func (r OpsCenterOperation) Completed() bool {
+	return r.State == opsCenterOperationCompleted
+}
+
+// Failed returns true if the operation has failed
+func (r OpsCenterOperation) Failed() bool {
+	return r.State == opsCenterOperationFailed
+}
+
+// NewOpsCenterClient returns a new client for the Ops Center at opsCenterURL
+// and verifies that the service login is valid
+func NewOpsCenterClient(ctx context.Context, opsCenterURL string, login ServiceLogin) (*OpsCenterClient, error) {
+	if login.IsEmpty() {
+		return nil, trace.BadParameter("service login is required to access the Ops Center API")
+	}
+	u, err := url.Parse(opsCenterURL)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	client := &OpsCenterClient{
+		baseURL: u,
+		login:   login,
+		client: &http.Client{
+			Transport: &http.Transport{
+				// Ops Centers under test use self-signed certificates
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+				Proxy:           http.ProxyFromEnvironment,
+			},
+			Timeout: opsCenterRequestTimeout,
+		},
+	}
+	if _, err := client.Sites(ctx); err != nil {
+		return nil, trace.Wrap(err, "failed to authenticate with the Ops Center %v", opsCenterURL)
+	}
+	return client, nil
+}
+
+// Sites returns the clusters registered with the Ops Center
+func (r *OpsCenterClient) Sites(ctx context.Context) ([]OpsCenterSite, error) {
+	var sites []OpsCenterSite
+	err := r.getJSON(ctx, r.sitesPath(), &sites)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return sites, nil
+}
+
+// Operations returns the operations of the specified cluster
+func (r *OpsCenterClient) Operations(ctx context.Context, domain string) ([]OpsCenterOperation, error) {
+	var operations []OpsCenterOperation
+	err := r.getJSON(ctx, path.Join(r.sitesPath(), domain, "operations"), &operations)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return operations, nil
+}
+
+// Operation returns the operation given with id of the specified cluster
+func (r *OpsCenterClient) Operation(ctx context.Context, domain, id string) (*OpsCenterOperation, error) {
+	var operation OpsCenterOperation
+	err := r.getJSON(ctx, path.Join(r.sitesPath(), domain, "operations", "common", id), &operation)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &operation, nil
+}
+
+// Report downloads the diagnostic report (tarball) of the specified cluster into w
+func (r *OpsCenterClient) Report(ctx context.Context, domain string, w io.Writer) error {
+	resp, err := r.get(ctx, path.Join(r.sitesPath(), domain, "report"))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	return trace.ConvertSystemError(err)
+}
+
+// ExportPackage downloads the package (i.e. the application tarball) given with locator into w
+func (r *OpsCenterClient) ExportPackage(ctx context.Context, locator loc.Locator, w io.Writer) error {
+	resp, err := r.get(ctx, path.Join(packagesPath(locator.Repository), locator.Name, locator.Version, "file"))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	return trace.ConvertSystemError(err)
+}
+
+// ImportPackage uploads the package (i.e. the application tarball) read from data
+// into the Ops Center as the package given with locator
+func (r *OpsCenterClient) ImportPackage(ctx context.Context, locator loc.Locator, data io.Reader) error {
+	body, w := io.Pipe()
+	form := multipart.NewWriter(w)
+	go func() {
+		w.CloseWithError(writePackageForm(form, locator, data))
+	}()
+	resp, err := r.do(ctx, http.MethodPost, packagesPath(locator.Repository), body, form.FormDataContentType())
+	if err != nil {
+		// unblock the form writer
+		body.Close()
+		return trace.Wrap(err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// writePackageForm writes the multipart form to upload the package with
+func writePackageForm(form *multipart.Writer, locator loc.Locator, data io.Reader) error {
+	if err := form.WriteField("locator", locator.String()); err != nil {
+		return trace.Wrap(err)
+	}
+	part, err := form.CreateFormFile("package", locator.Name)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if _, err := io.Copy(part, data); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(form.Close())
+}
+
+func (r *OpsCenterClient) getJSON(ctx context.Context, path string, out interface{}) error {
+	resp, err := r.get(ctx, path)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer resp.Body.Close()
+	return trace.Wrap(json.NewDecoder(resp.Body).Decode(out))
+}
+
+// get issues a GET request for the specified API path.
+// Returns an error if the response status is not 200 OK
+func (r *OpsCenterClient) get(ctx context.Context, path string) (*http.Response, error) {
+	return r.do(ctx, http.MethodGet, path, nil, "")
+}
+
+// do issues a request for the specified API path with the optional body of the given content type.
+// Returns an error if the response status is not 200 OK
+func (r *OpsCenterClient) do(ctx context.Context, method, path string, body io.Reader, contentType string) (*http.Response, error) {
+	u := *r.baseURL
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	req = req.WithContext(ctx)
+	req.SetBasicAuth(r.login.Username, r.login.Password)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, trace.ConnectionProblem(err, "failed to reach the Ops Center")
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	respBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+	err = fmt.Errorf("%v %v: %v: %s", method, path, resp.Status, respBody)
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return nil, trace.NotFound(err.Error())
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, trace.AccessDenied(err.Error())
+	default:
+		return nil, trace.Wrap(err)
+	}
+}
+
+func (r *OpsCenterClient) sitesPath() string {
+	return path.Join("/portalapi/v1/accounts", opsCenterAccountID, "sites")
+}
+
+// packagesPath returns the API path of the packages in the repository
+func packagesPath(repository string) string {
+	return path.Join("/pack/v1/repositories", repository, "packages")
+}
+
+const (
+	// opsCenterAccountID is the ID of the system account clusters are registered with
+	opsCenterAccountID = "00000000-0000-0000-0000-000000000001"
+	// opsCenterRequestTimeout limits the duration of Ops Center API requests,
+	// including report downloads
+	opsCenterRequestTimeout = 10 * time.Minute
+
+	opsCenterOperationCompleted = "completed"
+	opsCenterOperationFailed    = "failed"
+)
@@ -0,0 +1,160 @@
+package framework
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gravitational/robotest/lib/loc"
+
+	"github.com/gravitational/trace"
+	semver "github.com/hashicorp/go-version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportsPackage(t *testing.T) {
+	var path, username string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		username, _, _ = r.BasicAuth()
+		w.Write([]byte("tarball"))
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	err := newTestClient(t, srv.URL).ExportPackage(context.TODO(), testLocator, &buf)
+	require.NoError(t, err)
+	assert.Equal(t, "/pack/v1/repositories/gravitational.io/packages/app/1.0.0/file", path)
+	assert.Equal(t, "robotest", username)
+	assert.Equal(t, "tarball", buf.String())
+}
+
+func TestImportsPackage(t *testing.T) {
+	var method, path, locator, data string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		locator = r.FormValue("locator")
+		f, _, err := r.FormFile("package")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		bytes, _ := ioutil.ReadAll(f)
+		data = string(bytes)
+	}))
+	defer srv.Close()
+
+	err := newTestClient(t, srv.URL).ImportPackage(context.TODO(), testLocator, bytes.NewBufferString("tarball"))
+	require.NoError(t, err)
+	assert.Equal(t, http.MethodPost, method)
+	assert.Equal(t, "/pack/v1/repositories/gravitational.io/packages", path)
+	assert.Equal(t, testLocator.String(), locator)
+	assert.Equal(t, "tarball", data)
+}
+
+func TestPackageNotFound(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	err := newTestClient(t, srv.URL).ExportPackage(context.TODO(), testLocator, ioutil.Discard)
+	assert.True(t, trace.IsNotFound(err), "expected not found, got %v", err)
+}
+
+func TestSetsResourceVersion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "robotest-app")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	srcPath := filepath.Join(dir, "app.tar.gz")
+	dstPath := filepath.Join(dir, "app-update.tar.gz")
+	writeTarball(t, srcPath, map[string]string{
+		"resources/app.yaml": "apiVersion: bundle.gravitational.io/v2\nkind: Bundle\nmetadata:\n  name: app\n  resourceVersion: 1.0.0\n",
+		"resources/charts":   "chart",
+	})
+	require.NoError(t, writeResourceVersion(srcPath, dstPath, "1.0.1"))
+
+	version, err := getResourceVersion(dstPath)
+	require.NoError(t, err)
+	assert.Equal(t, "1.0.1", version)
+	files := readTarball(t, dstPath)
+	assert.Equal(t, "chart", files["resources/charts"])
+	assert.Equal(t, "apiVersion: bundle.gravitational.io/v2\nkind: Bundle\nmetadata:\n  name: app\n  resourceVersion: 1.0.1\n",
+		files["resources/app.yaml"])
+}
+
+func TestBumpsVersion(t *testing.T) {
+	var testCases = []struct {
+		version  string
+		expected string
+	}{
+		{"1.0.0", "1.0.1"},
+		{"5.2.3-alpha.1", "5.2.4-alpha.1"},
+		{"0.0.1+latest", "0.0.2+latest"},
+	}
+	for _, tc := range testCases {
+		version, err := semver.NewVersion(tc.version)
+		require.NoError(t, err, tc.version)
+		assert.Equal(t, tc.expected, bump(*version), tc.version)
+	}
+}
+
+// newTestClient returns a client for the test Ops Center at addr
+func newTestClient(t *testing.T, addr string) *OpsCenterClient {
+	u, err := url.Parse(addr)
+	require.NoError(t, err)
+	return &OpsCenterClient{
+		baseURL: u,
+		login:   ServiceLogin{Username: "robotest", Password: "secret"},
+		client:  http.DefaultClient,
+	}
+}
+
+// writeTarball writes the gzipped tarball with the specified files at path
+func writeTarball(t *testing.T, path string, files map[string]string) {
+	var buf bytes.Buffer
+	wz := gzip.NewWriter(&buf)
+	w := tar.NewWriter(wz)
+	for name, data := range files {
+		require.NoError(t, w.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data))}))
+		_, err := w.Write([]byte(data))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	require.NoError(t, wz.Close())
+	require.NoError(t, ioutil.WriteFile(path, buf.Bytes(), 0644))
+}
+
+// readTarball returns the files in the gzipped tarball at path
+func readTarball(t *testing.T, path string) map[string]string {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	rz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	r := tar.NewReader(rz)
+	files := make(map[string]string)
+	for {
+		hdr, err := r.Next()
+		if err != nil {
+			break
+		}
+		data, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		files[hdr.Name] = string(data)
+	}
+	return files
+}
+
+var testLocator = loc.Locator{Repository: "gravitational.io", Name: "app", Version: "1.0.0"}
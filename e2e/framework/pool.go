@@ -0,0 +1,42 @@
+package framework
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/onsi/ginkgo/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// ClusterPoolEnabled returns true if each parallel ginkgo node runs its specs
+// against its own cluster.
+//
+// With `ginkgo -p` (or -nodes N) and cluster_pool set, every node provisions
+// (or loads from its state file) an independent cluster, so specs are distributed
+// across N clusters instead of sharing the single global one
+func ClusterPoolEnabled() bool {
+	return TestContext.ClusterPool && config.GinkgoConfig.ParallelTotal > 1
+}
+
+// bindPoolMember makes the configuration specific to the cluster of this ginkgo node:
+// the cluster name, state file, state and report directories are suffixed with the node index
+func bindPoolMember() {
+	node := config.GinkgoConfig.ParallelNode
+	TestContext.ClusterName = poolMemberName(TestContext.ClusterName, node)
+	stateConfigFile = poolMemberName(stateConfigFile, node)
+	if stateDir != "" {
+		stateDir = filepath.Join(stateDir, fmt.Sprint(node))
+	}
+	if TestContext.ReportDir != "" {
+		TestContext.ReportDir = filepath.Join(TestContext.ReportDir, fmt.Sprint(node))
+	}
+	log.WithFields(log.Fields{
+		"node":    node,
+		"cluster": TestContext.ClusterName,
+		"state":   stateConfigFile,
+	}).Info("Bound to cluster pool member.")
+}
+
+func poolMemberName(name string, node int) string {
+	return fmt.Sprintf("%v-%v", name, node)
+}
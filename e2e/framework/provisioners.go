@@ -0,0 +1,78 @@
+package framework
+
+import (
+	"github.com/gravitational/robotest/infra"
+	"github.com/gravitational/robotest/infra/terraform"
+	"github.com/gravitational/robotest/infra/vagrant"
+
+	"github.com/gravitational/trace"
+)
+
+func init() {
+	infra.RegisterProvisioner(string(provisionerTerraform), terraformFactory{})
+	infra.RegisterProvisioner(string(provisionerVagrant), vagrantFactory{})
+}
+
+// terraformFactory creates terraform provisioners from the test configuration
+type terraformFactory struct{}
+
+// New returns a new terraform provisioner
+func (terraformFactory) New(infraConfig infra.Config, stateDir string) (infra.Provisioner, error) {
+	config, err := makeTerraformConfig(infraConfig)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	provisioner, err := terraform.New(stateDir, *config)
+	return provisioner, trace.Wrap(err)
+}
+
+// NewFromState returns a terraform provisioner for the saved state
+func (terraformFactory) NewFromState(infraConfig infra.Config, state infra.ProvisionerState) (infra.Provisioner, error) {
+	config, err := makeTerraformConfig(infraConfig)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	provisioner, err := terraform.NewFromState(*config, state)
+	return provisioner, trace.Wrap(err)
+}
+
+// vagrantFactory creates vagrant provisioners from the test configuration
+type vagrantFactory struct{}
+
+// New returns a new vagrant provisioner
+func (vagrantFactory) New(infraConfig infra.Config, stateDir string) (infra.Provisioner, error) {
+	config := vagrant.Config{
+		Config:       infraConfig,
+		ScriptPath:   TestContext.Onprem.ScriptPath,
+		InstallerURL: TestContext.Onprem.InstallerURL,
+		NumNodes:     TestContext.Onprem.NumNodes,
+		DockerDevice: TestContext.Onprem.DockerDevice,
+	}
+	err := config.Validate()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	provisioner, err := vagrant.New(stateDir, config)
+	return provisioner, trace.Wrap(err)
+}
+
+// NewFromState returns a vagrant provisioner for the saved state
+func (vagrantFactory) NewFromState(infraConfig infra.Config, state infra.ProvisionerState) (infra.Provisioner, error) {
+	numNodes := len(state.Nodes)
+	if TestContext.Onprem.NumNodes > 0 {
+		// Always override from configuration if available
+		numNodes = TestContext.Onprem.NumNodes
+	}
+	config := vagrant.Config{
+		Config:       infraConfig,
+		ScriptPath:   TestContext.Onprem.ScriptPath,
+		InstallerURL: TestContext.Onprem.InstallerURL,
+		NumNodes:     numNodes,
+	}
+	err := config.Validate()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	provisioner, err := vagrant.NewFromState(config, state)
+	return provisioner, trace.Wrap(err)
+}
@@ -15,9 +15,10 @@ import (
 	"github.com/gravitational/robotest/infra/providers/azure"
 	"github.com/gravitational/robotest/infra/providers/gce"
 	"github.com/gravitational/robotest/infra/terraform"
-	"github.com/gravitational/robotest/infra/vagrant"
 	"github.com/gravitational/robotest/lib/debug"
 	"github.com/gravitational/robotest/lib/loc"
+	"github.com/gravitational/robotest/lib/secret"
+	"github.com/gravitational/robotest/lib/xlog"
 
 	"github.com/gravitational/configure"
 	"github.com/gravitational/trace"
@@ -35,17 +36,24 @@ func ConfigureFlags() {
 
 	flag.Parse()
 
-	initLogger(debugFlag)
+	err := initLogger(debugFlag, logModules, logJSON)
+	if err != nil {
+		Failf("invalid log configuration: %v", err)
+	}
 
 	if debugFlag {
 		debug.StartProfiling(fmt.Sprintf("localhost:%v", debugPort))
 	}
 
-	err := initTestContext(configFile)
+	err = initTestContext(configFile)
 	if err != nil {
 		Failf("failed to read configuration from %q: %v", configFile, trace.DebugReport(err))
 	}
 
+	if ClusterPoolEnabled() {
+		bindPoolMember()
+	}
+
 	err = initTestState(stateConfigFile)
 	if err != nil {
 		Failf("failed to read state configuration from %q: %v", stateConfigFile, trace.DebugReport(err))
@@ -132,6 +140,9 @@ func (r *TestContextType) Validate() error {
 		errors = append(errors, trace.BadParameter("Onprem configuration is required for provisioner %v",
 			TestContext.Provisioner.Type))
 	}
+	if err := TestContext.WebDriver.Check(); err != nil {
+		errors = append(errors, err)
+	}
 	// Do not mandate AWS.AccessKey/AWS.SecretKey for terraform as scripts can be written to consume
 	// credentials not only from environment
 	return trace.NewAggregate(errors...)
@@ -218,6 +229,12 @@ type TestContextType struct {
 	Bandwagon BandwagonConfig `json:"bandwagon" yaml:"bandwagon"`
 	// WebDriverURL specifies optional WebDriver URL to use
 	WebDriverURL string `json:"web_driver_url,omitempty" yaml:"web_driver_url,omitempty" `
+	// WebDriver defines the browser to use
+	WebDriver WebDriverConfig `json:"web_driver,omitempty" yaml:"web_driver,omitempty"`
+	// ClusterPool specifies whether each parallel ginkgo node uses its own cluster
+	ClusterPool bool `json:"cluster_pool,omitempty" yaml:"cluster_pool,omitempty"`
+	// VideoRecording optionally enables recording of browser sessions per spec
+	VideoRecording *VideoConfig `json:"video_recording,omitempty" yaml:"video_recording,omitempty"`
 	// Extensions groups arbitrary test step configuration
 	Extensions Extensions `json:"extensions,omitempty" yaml:"extensions,omitempty"`
 }
@@ -232,6 +249,9 @@ type Provisioner struct {
 	LoadFromState bool `json:"load_from_state" yaml:"load_from_state"`
 	// StateFile defines path to file with provisioner output data
 	StateFile string `json:"state_file" yaml:"state_file"`
+	// Settings optionally holds the configuration of a custom provisioner
+	// registered with infra.RegisterProvisioner
+	Settings map[string]interface{} `json:"settings,omitempty" yaml:"settings"`
 }
 
 type BandwagonConfig struct {
@@ -259,8 +279,52 @@ type Login struct {
 	Username string `json:"username" yaml:"username"`
 	Password string `json:"password" yaml:"password"`
 	// AuthProvider specifies the authentication provider to use for login.
-	// Available providers are `email` and `gogole`
+	// Available providers are `email`, `google`, `oidc` and `saml`
 	AuthProvider string `json:"auth_provider,omitempty" yaml:"auth_provider,omitempty"`
+	// SSO defines the external identity provider login form for `oidc` and `saml`
+	SSO *SSOConfig `json:"sso,omitempty" yaml:"sso,omitempty"`
+}
+
+// SSOConfig defines how to complete the login flow of an external identity provider.
+// The selectors default to the login form of Dex
+type SSOConfig struct {
+	// Connector specifies the display name of the SSO connector on the login page
+	Connector string `json:"connector" yaml:"connector"`
+	// Username specifies the selector of the username input
+	Username string `json:"username_selector" yaml:"username_selector"`
+	// Password specifies the selector of the password input
+	Password string `json:"password_selector" yaml:"password_selector"`
+	// Submit specifies the selector of the login form submit button
+	Submit string `json:"submit_selector" yaml:"submit_selector"`
+	// Approve specifies the selector of the optional access approval button
+	Approve string `json:"approve_selector" yaml:"approve_selector"`
+}
+
+// UsernameSelector returns the selector of the username input
+func (r SSOConfig) UsernameSelector() string {
+	return withDefault(r.Username, "#login")
+}
+
+// PasswordSelector returns the selector of the password input
+func (r SSOConfig) PasswordSelector() string {
+	return withDefault(r.Password, "#password")
+}
+
+// SubmitSelector returns the selector of the login form submit button
+func (r SSOConfig) SubmitSelector() string {
+	return withDefault(r.Submit, "#submit-login")
+}
+
+// ApproveSelector returns the selector of the access approval button
+func (r SSOConfig) ApproveSelector() string {
+	return withDefault(r.Approve, `.dex-container button[type="submit"]`)
+}
+
+func withDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
 }
 
 func (r Login) IsEmpty() bool {
@@ -367,6 +431,8 @@ func registerCommonFlags() {
 	flag.StringVar(&stateConfigFile, "state-file", "config.yaml.state", "State configuration file to use")
 	flag.BoolVar(&debugFlag, "debug", false, "Verbose mode")
 	flag.IntVar(&debugPort, "debug-port", 6060, "Profiling port")
+	flag.StringVar(&logModules, "log-modules", "", "Override the log level per module (e2e, infra, ssh, ssh.scp), i.e. 'e2e=info,ssh=debug'")
+	flag.BoolVar(&logJSON, "log-json", false, "Write the logs as JSON objects")
 	flag.Var(&mode, "mode", "Run robotest in specific mode. Supported modes: [`wizard`,`provision`]")
 	flag.BoolVar(&teardownFlag, "destroy", false, "Destroy infrastructure after all tests")
 	flag.BoolVar(&outputFlag, "output", false, "Display current state only")
@@ -414,10 +480,40 @@ func newContextConfig(configFile string) error {
 	if err != nil {
 		return trace.Wrap(err, "Error parsing config file")
 	}
+	if TestContext.Provisioner != nil {
+		// the settings are saved with the test state as JSON
+		TestContext.Provisioner.Settings = jsonSettings(TestContext.Provisioner.Settings)
+	}
 
 	return nil
 }
 
+// jsonSettings returns the provisioner settings with the nested YAML maps
+// converted to maps with string keys so the settings can be encoded as JSON
+func jsonSettings(settings map[string]interface{}) map[string]interface{} {
+	for key, value := range settings {
+		settings[key] = jsonValue(value)
+	}
+	return settings
+}
+
+// jsonValue converts the YAML maps in value to maps with string keys
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			out[fmt.Sprint(key)] = jsonValue(value)
+		}
+		return out
+	case []interface{}:
+		for i := range v {
+			v[i] = jsonValue(v[i])
+		}
+	}
+	return value
+}
+
 func initTestState(configFile string) error {
 	confFile, err := os.Open(configFile)
 	if err != nil && !os.IsNotExist(err) {
@@ -449,14 +545,45 @@ func nowStamp() string {
 	return time.Now().Format(time.StampMilli)
 }
 
-func initLogger(debug bool) {
+// logFields returns the fields identifying the tested cluster
+func logFields() log.Fields {
+	fields := log.Fields{}
+	if TestContext == nil {
+		return fields
+	}
+	if TestContext.ClusterName != "" {
+		fields["cluster"] = TestContext.ClusterName
+	}
+	if TestContext.CloudProvider != "" {
+		fields["cloud"] = TestContext.CloudProvider
+	}
+	if TestContext.Onprem != nil && TestContext.Onprem.OS != "" {
+		fields["os"] = TestContext.Onprem.OS
+	}
+	return fields
+}
+
+func initLogger(debug bool, logModules string, json bool) error {
 	level := log.InfoLevel
 	if debug {
 		level = log.DebugLevel
 	}
+	modules, err := xlog.ParseModuleLevels(logModules)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	// entries of the UI tests are not attributed to a module
+	if e2eLevel, ok := modules[xlog.ModuleE2E]; ok {
+		level = e2eLevel
+	}
 	log.StandardLogger().Hooks = make(log.LevelHooks)
+	log.AddHook(secret.Hook{})
+	// entries logged by the infrastructure packages through the standard logger
+	// are attributed to the tested cluster once the configuration is read
+	xlog.PrependHook(log.StandardLogger(), xlog.FieldsHook(logFields))
 	log.SetOutput(os.Stderr)
-	log.SetLevel(level)
+	xlog.Configure(xlog.Config{Level: level, Modules: modules, JSON: json})
+	return nil
 }
 
 func makeTerraformConfig(infraConfig infra.Config) (config *terraform.Config, err error) {
@@ -489,40 +616,14 @@ func makeTerraformConfig(infraConfig infra.Config) (config *terraform.Config, er
 }
 
 func provisionerFromConfig(infraConfig infra.Config, stateDir string, provisionerConfig Provisioner) (provisioner infra.Provisioner, err error) {
-	switch provisionerConfig.Type {
-	case provisionerTerraform:
-		config, err := makeTerraformConfig(infraConfig)
-		if err != nil {
-			return nil, trace.Wrap(err)
-		}
-		provisioner, err = terraform.New(stateDir, *config)
-		if err != nil {
-			return nil, trace.Wrap(err)
-		}
-	case provisionerVagrant:
-		config := vagrant.Config{
-			Config:       infraConfig,
-			ScriptPath:   TestContext.Onprem.ScriptPath,
-			InstallerURL: TestContext.Onprem.InstallerURL,
-			NumNodes:     TestContext.Onprem.NumNodes,
-			DockerDevice: TestContext.Onprem.DockerDevice,
-		}
-		err = config.Validate()
-		if err != nil {
-			return nil, trace.Wrap(err)
-		}
-		provisioner, err = vagrant.New(stateDir, config)
-		if err != nil {
-			return nil, trace.Wrap(err)
-		}
-	default:
-		// no provisioner when the cluster has already been provisioned
-		// or automatic provisioning is used
-		if provisionerConfig.Type != "" {
-			return nil, trace.BadParameter("unknown provisioner %q", provisionerConfig.Type)
-		}
+	// no provisioner when the cluster has already been provisioned
+	// or automatic provisioning is used
+	if provisionerConfig.Type == "" {
+		return nil, nil
 	}
-	return provisioner, nil
+	infraConfig.Settings = provisionerConfig.Settings
+	provisioner, err = infra.NewProvisioner(string(provisionerConfig.Type), infraConfig, stateDir)
+	return provisioner, trace.Wrap(err)
 }
 
 func provisionerFromState(infraConfig infra.Config, testState TestState) (provisioner infra.Provisioner, err error) {
@@ -530,44 +631,14 @@ func provisionerFromState(infraConfig infra.Config, testState TestState) (provis
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
-	numNodes := len(testState.ProvisionerState.Nodes)
-	if TestContext.Onprem.NumNodes > 0 {
-		// Always override from configuration if available
-		numNodes = TestContext.Onprem.NumNodes
+	// no provisioner when the cluster has already been provisioned
+	// or automatic provisioning is used
+	if testState.Provisioner == nil || testState.Provisioner.Type == "" {
+		return nil, nil
 	}
-	switch testState.Provisioner.Type {
-	case provisionerTerraform:
-		config, err := makeTerraformConfig(infraConfig)
-		if err != nil {
-			return nil, trace.Wrap(err)
-		}
-		provisioner, err = terraform.NewFromState(*config, *testState.ProvisionerState)
-		if err != nil {
-			return nil, trace.Wrap(err)
-		}
-	case provisionerVagrant:
-		config := vagrant.Config{
-			Config:       infraConfig,
-			ScriptPath:   TestContext.Onprem.ScriptPath,
-			InstallerURL: TestContext.Onprem.InstallerURL,
-			NumNodes:     numNodes,
-		}
-		err := config.Validate()
-		if err != nil {
-			return nil, trace.Wrap(err)
-		}
-		provisioner, err = vagrant.NewFromState(config, *testState.ProvisionerState)
-		if err != nil {
-			return nil, trace.Wrap(err)
-		}
-	default:
-		// no provisioner when the cluster has already been provisioned
-		// or automatic provisioning is used
-		if testState.Provisioner != nil && testState.Provisioner.Type != "" {
-			return nil, trace.BadParameter("unknown provisioner %q", testState.Provisioner.Type)
-		}
-	}
-	return provisioner, nil
+	infraConfig.Settings = testState.Provisioner.Settings
+	provisioner, err = infra.NewProvisionerFromState(string(testState.Provisioner.Type), infraConfig, *testState.ProvisionerState)
+	return provisioner, trace.Wrap(err)
 }
 
 func outputSensitiveConfig(testConfig TestContextType) {
@@ -577,6 +648,11 @@ func outputSensitiveConfig(testConfig TestContextType) {
 	testConfig.Login.Password = mask
 	testConfig.ServiceLogin.Password = mask
 	testConfig.License = mask
+	if testConfig.Extensions.LicenseRenewal != nil {
+		renewal := *testConfig.Extensions.LicenseRenewal
+		renewal.License = mask
+		testConfig.Extensions.LicenseRenewal = &renewal
+	}
 	var buf bytes.Buffer
 	pretty.Fprintf(&buf, "[CONFIG] %#v", testConfig)
 	log.Debug(buf.String())
@@ -638,6 +714,12 @@ var debugFlag bool
 // debugPort defines the port for profiling endpoint
 var debugPort int
 
+// logModules optionally overrides the log level per module
+var logModules string
+
+// logJSON defines whether to write the logs as JSON objects
+var logJSON bool
+
 // mode defines the mode for tests
 var mode modeType
 
@@ -0,0 +1,39 @@
+package framework
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestProvisionerSettingsEncodeAsJSON(t *testing.T) {
+	var config TestContextType
+	err := yaml.Unmarshal([]byte(`
+provisioner:
+  type: custom
+  settings:
+    pool: robotest
+    nodes:
+      - addr: 10.0.0.1
+        labels: {role: master}
+    limits: {cpu: 2}
+`), &config)
+	require.NoError(t, err)
+
+	config.Provisioner.Settings = jsonSettings(config.Provisioner.Settings)
+	data, err := json.Marshal(config.Provisioner)
+	require.NoError(t, err)
+
+	var provisioner Provisioner
+	require.NoError(t, json.Unmarshal(data, &provisioner))
+	assert.Equal(t, map[string]interface{}{
+		"pool": "robotest",
+		"nodes": []interface{}{
+			map[string]interface{}{"addr": "10.0.0.1", "labels": map[string]interface{}{"role": "master"}},
+		},
+		"limits": map[string]interface{}{"cpu": float64(2)},
+	}, provisioner.Settings)
+}
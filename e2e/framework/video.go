@@ -0,0 +1,104 @@
+package framework
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/gravitational/robotest/lib/constants"
+	"github.com/gravitational/trace"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// VideoConfig defines configuration for recording browser sessions
+type VideoConfig struct {
+	// Display specifies the X display the browser renders into, i.e. :99
+	Display string `json:"display" yaml:"display" validate:"required"`
+	// Size specifies the size of the recorded screen area as WxH.
+	// Defaults to 1920x1080
+	Size string `json:"size" yaml:"size"`
+	// FrameRate specifies the number of frames per second to record.
+	// Defaults to 10
+	FrameRate int `json:"frame_rate" yaml:"frame_rate"`
+	// KeepPassed specifies whether to keep recordings of the specs that passed.
+	// By default, only recordings of failed specs are kept
+	KeepPassed bool `json:"keep_passed" yaml:"keep_passed"`
+}
+
+// recorder records the X display with ffmpeg into a file
+type recorder struct {
+	cmd  *exec.Cmd
+	path string
+}
+
+// startRecording starts recording the configured display into a video file
+// named after the spec under ReportDir
+func startRecording(config VideoConfig, specName string) (*recorder, error) {
+	if TestContext.ReportDir == "" {
+		return nil, trace.BadParameter("report directory is required to record videos")
+	}
+	dir := filepath.Join(TestContext.ReportDir, "videos")
+	if err := os.MkdirAll(dir, constants.SharedDirMask); err != nil {
+		return nil, trace.ConvertSystemError(err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%v.mp4", specDirName(specName)))
+	cmd := exec.Command("ffmpeg", config.args(path)...)
+	if err := cmd.Start(); err != nil {
+		return nil, trace.Wrap(err, "failed to start ffmpeg")
+	}
+	log.WithField("video", path).Debug("Recording started.")
+	return &recorder{cmd: cmd, path: path}, nil
+}
+
+// stop stops the recording and removes the video file unless keep is set
+func (r *recorder) stop(keep bool) error {
+	// ffmpeg finalizes the video file on interrupt
+	if err := r.cmd.Process.Signal(syscall.SIGINT); err != nil {
+		return trace.Wrap(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- r.cmd.Wait()
+	}()
+	select {
+	case <-done:
+	case <-time.After(recorderStopTimeout):
+		r.cmd.Process.Kill()
+		<-done
+	}
+	if !keep {
+		return trace.ConvertSystemError(os.Remove(r.path))
+	}
+	log.WithField("video", r.path).Info("Recording saved.")
+	return nil
+}
+
+func (r VideoConfig) args(path string) []string {
+	size := r.Size
+	if size == "" {
+		size = defaultVideoSize
+	}
+	frameRate := r.FrameRate
+	if frameRate == 0 {
+		frameRate = defaultVideoFrameRate
+	}
+	return []string{
+		"-y", "-loglevel", "error",
+		"-f", "x11grab",
+		"-video_size", size,
+		"-framerate", fmt.Sprint(frameRate),
+		"-i", r.Display,
+		"-codec:v", "libx264", "-preset", "ultrafast", "-pix_fmt", "yuv420p",
+		path,
+	}
+}
+
+const (
+	defaultVideoSize      = "1920x1080"
+	defaultVideoFrameRate = 10
+	recorderStopTimeout   = 10 * time.Second
+)
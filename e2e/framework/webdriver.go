@@ -0,0 +1,84 @@
+package framework
+
+import (
+	"fmt"
+
+	"github.com/gravitational/trace"
+
+	web "github.com/sclevine/agouti"
+)
+
+// WebDriverConfig defines the browser to run UI tests with
+type WebDriverConfig struct {
+	// Browser specifies the browser to use: chrome (default) or firefox.
+	// A local chromedriver or geckodriver is started unless WebDriverURL is set
+	Browser string `json:"browser" yaml:"browser"`
+	// Headless specifies whether to run the browser without a display
+	Headless bool `json:"headless" yaml:"headless"`
+	// WindowWidth specifies the browser window width in pixels
+	WindowWidth int `json:"window_width" yaml:"window_width"`
+	// WindowHeight specifies the browser window height in pixels
+	WindowHeight int `json:"window_height" yaml:"window_height"`
+}
+
+// Check validates this configuration
+func (r WebDriverConfig) Check() error {
+	switch r.Browser {
+	case "", browserChrome, browserFirefox:
+	default:
+		return trace.BadParameter("unsupported browser %q, expected %v or %v",
+			r.Browser, browserChrome, browserFirefox)
+	}
+	if (r.WindowWidth == 0) != (r.WindowHeight == 0) {
+		return trace.BadParameter("both window width and height are required")
+	}
+	return nil
+}
+
+// newDriver returns a new local web driver for the configured browser
+func (r WebDriverConfig) newDriver() *web.WebDriver {
+	if r.browser() == browserFirefox {
+		return web.GeckoDriver(web.Desired(r.capabilities()))
+	}
+	return web.ChromeDriver(web.Desired(r.capabilities()))
+}
+
+// capabilities returns the desired capabilities for the configured browser
+func (r WebDriverConfig) capabilities() web.Capabilities {
+	capabilities := web.NewCapabilities().Browser(r.browser()).With("javascriptEnabled")
+	switch r.browser() {
+	case browserFirefox:
+		var args []string
+		if r.Headless {
+			args = append(args, "-headless")
+		}
+		capabilities["moz:firefoxOptions"] = map[string][]string{"args": args}
+	default:
+		args := []string{
+			// There is no GPU inside docker box!
+			"disable-gpu",
+			// Sandbox requires namespace permissions that we don't have on a container
+			"no-sandbox",
+		}
+		if r.Headless {
+			args = append(args, "headless")
+		}
+		if r.WindowWidth != 0 {
+			args = append(args, fmt.Sprintf("window-size=%v,%v", r.WindowWidth, r.WindowHeight))
+		}
+		capabilities["chromeOptions"] = map[string][]string{"args": args}
+	}
+	return capabilities
+}
+
+func (r WebDriverConfig) browser() string {
+	if r.Browser == "" {
+		return browserChrome
+	}
+	return r.Browser
+}
+
+const (
+	browserChrome  = "chrome"
+	browserFirefox = "firefox"
+)
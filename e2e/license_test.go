@@ -0,0 +1,39 @@
+package e2e
+
+import (
+	"github.com/gravitational/robotest/e2e/framework"
+	"github.com/gravitational/robotest/e2e/uimodel"
+	uidefaults "github.com/gravitational/robotest/e2e/uimodel/defaults"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = framework.RoboDescribe("License expiration", func() {
+	f := framework.New()
+	ctx := framework.TestContext
+
+	It("should renew an expired license [provisioner:onprem,license]", func() {
+		renewal := ctx.Extensions.LicenseRenewal
+		Expect(renewal).NotTo(BeNil(), "requires license renewal configuration")
+
+		expiryTimeout := renewal.ExpiryTimeout.Duration()
+		if expiryTimeout == 0 {
+			expiryTimeout = uidefaults.SiteLicenseExpiryTimeout
+		}
+
+		By("waiting for the install license to expire")
+		ui := uimodel.InitWithUser(f.Page, framework.SiteURL())
+		site := ui.GoToSite(ctx.ClusterName)
+		site.WaitForLicenseExpired(expiryTimeout, uidefaults.SiteLicenseExpiryPollInterval)
+
+		By("uploading the renewed license")
+		licensePage := site.GoToLicense()
+		licensePage.UpdateLicense(renewal.License)
+
+		By("verifying the cluster is active again")
+		site = ui.GoToSite(ctx.ClusterName)
+		site.WaitForReadyState()
+		Expect(site.IsLicenseExpired()).To(BeFalse(), "should not display expired license banner")
+	})
+})
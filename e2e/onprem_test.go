@@ -20,6 +20,9 @@ var _ = framework.RoboDescribe("Onprem Integration Test", func() {
 	ctx := framework.TestContext
 
 	It("should provision a new cluster [provisioner:onprem,install]", func() {
+		By("making sure the installer is running")
+		framework.EnsureWizardRunning()
+
 		By("navigating to installer step")
 		domainName := ctx.ClusterName
 		ui := uimodel.InitWithUser(f.Page, framework.InstallerURL())
@@ -86,6 +89,19 @@ var _ = framework.RoboDescribe("Onprem Integration Test", func() {
 		siteServerPage := site.GoToServers()
 		newSiteServer := siteServerPage.AddOnPremServer()
 		siteServerPage.DeleteServer(newSiteServer)
+
+		By("verifying the operations log")
+		operationsPage := site.GoToOperations()
+		Expect(operationsPage.LastOperation().State).To(Equal("completed"))
+	})
+
+	It("should invite and delete a user [provisioner:onprem,users]", func() {
+		ui := uimodel.InitWithUser(f.Page, framework.SiteURL())
+		site := ui.GoToSite(ctx.ClusterName)
+		usersPage := site.GoToUsers()
+		email := fmt.Sprintf("robotest-%v@example.com", ctx.ClusterName)
+		usersPage.InviteUser(email, "@teleadmin")
+		usersPage.DeleteUser(email)
 	})
 
 	It("should update site to the latest version [provisioner:onprem,update]", func() {
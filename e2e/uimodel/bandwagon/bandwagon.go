@@ -1,6 +1,7 @@
 package bandwagon
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/gravitational/robotest/e2e/framework"
@@ -33,21 +34,20 @@ func (b *Bandwagon) SubmitForm(config framework.BandwagonConfig) {
 	count, _ := b.page.FindByName("org").Count()
 	if count > 0 {
 		log.Infof("entering organization: %s", config.Organization)
-		Expect(b.page.FindByName("org").Fill(config.Organization)).To(Succeed(), "should enter organization")
+		utils.Fill(b.page, `[name="org"]`, config.Organization)
 	}
 
 	log.Infof("entering email: %s", config.Email)
-	Expect(b.page.FindByName("email").Fill(config.Email)).To(Succeed(), "should enter email")
+	utils.Fill(b.page, `[name="email"]`, config.Email)
 	count, _ = b.page.FindByName("name").Count()
 	if count > 0 {
 		log.Infof("entering username: %s", config.Username)
-		Expect(b.page.FindByName("name").Fill(config.Username)).To(Succeed(), "should enter username")
+		utils.Fill(b.page, `[name="name"]`, config.Username)
 	}
 
 	log.Infof("entering password: %s", config.Password)
-	Expect(b.page.FindByName("password").Fill(config.Password)).To(Succeed(), "should enter password")
-	Expect(b.page.FindByName("passwordConfirmed").Fill(config.Password)).
-		To(Succeed(), "should re-enter password")
+	utils.Fill(b.page, `[name="password"]`, config.Password)
+	utils.Fill(b.page, `[name="passwordConfirmed"]`, config.Password)
 
 	log.Info("entering extra fields")
 	b.FillExtraFields(config.Extra.PlatformDNS, "platformDns")
@@ -64,7 +64,7 @@ func (b *Bandwagon) SubmitForm(config framework.BandwagonConfig) {
 	})
 
 	log.Info("submitting the form")
-	Expect(b.page.FindByClass("my-page-btn-submit").Click()).To(Succeed(), "should click submit button")
+	utils.Click(b.page, ".my-page-btn-submit")
 
 	utils.PauseForPageJs()
 	Eventually(func() bool {
@@ -77,6 +77,6 @@ func (b *Bandwagon) FillExtraFields(fieldValue, cssSelector string) {
 	count, _ := b.page.FindByName(cssSelector).Count()
 	if count > 0 {
 		log.Infof("entering %s: %s", cssSelector, fieldValue)
-		Expect(b.page.FindByName(cssSelector).Fill(fieldValue)).To(Succeed(), "should enter %s field value", cssSelector)
+		utils.Fill(b.page, fmt.Sprintf(`[name=%q]`, cssSelector), fieldValue)
 	}
 }
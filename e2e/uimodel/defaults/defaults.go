@@ -12,6 +12,13 @@ const (
 	// EventuallyPollInterval defines the frequency of Eventually polling attempts
 	EventuallyPollInterval = 300 * time.Millisecond
 
+	// ActionRetryTimeout specifies the total time to retry a page action on transient element errors
+	ActionRetryTimeout = 1 * time.Minute
+	// ActionRetryDelay specifies the initial delay between page action attempts
+	ActionRetryDelay = 200 * time.Millisecond
+	// ActionRetryMaxDelay specifies the maximum delay between page action attempts
+	ActionRetryMaxDelay = 3 * time.Second
+
 	// AgentServerTimeout defines the amount of time to wait for agents to connect
 	AgentServerTimeout = 5 * time.Minute
 
@@ -39,6 +46,11 @@ const (
 	// SiteFetchServerProfileTimeout is a waiting time to fetch AWS server profiles
 	SiteFetchServerProfileTimeout = 20 * time.Second
 
+	// SiteLicenseExpiryTimeout defines the default amount of time to wait for the cluster license to expire
+	SiteLicenseExpiryTimeout = 30 * time.Minute
+	// SiteLicenseExpiryPollInterval defines the frequency of checks for the expired license
+	SiteLicenseExpiryPollInterval = 30 * time.Second
+
 	// LoginGoogleNextStepTimeout specifies the amount of time needed for google auth steps to initialize
 	LoginGoogleNextStepTimeout = 10 * time.Second
 
@@ -34,8 +34,7 @@ func (i *Installer) ProcessLicenseStepIfRequired(license string) {
 		log.Infof("trying to complete license step")
 		Expect(license).NotTo(BeEmpty(), "should have a valid license")
 		Expect(elems.SendKeys(license)).To(Succeed())
-		Expect(i.page.FindByClass("grv-installer-btn-new-site").Click()).
-			To(Succeed(), "should input the license text")
+		utils.Click(i.page, ".grv-installer-btn-new-site")
 		Eventually(i.page.FindByClass("grv-installer-warning"), defaults.FindTimeout).
 			ShouldNot(BeFound(), "should be no warnings")
 	}
@@ -49,10 +48,10 @@ func (i *Installer) InitAWSInstallation(domainName string) {
 
 	log.Infof("providing AWS keys")
 	config := framework.TestContext.AWS
-	Expect(i.page.FindByClass("--aws").Click()).To(Succeed())
-	Expect(i.page.FindByName("aws_access_key").Fill(config.AccessKey)).To(Succeed())
-	Expect(i.page.FindByName("aws_secret_key").Fill(config.SecretKey)).To(Succeed())
-	Expect(i.page.FindByClass("grv-installer-btn-new-site").Click()).To(Succeed())
+	utils.Click(i.page, ".--aws")
+	utils.Fill(i.page, `[name="aws_access_key"]`, config.AccessKey)
+	utils.Fill(i.page, `[name="aws_secret_key"]`, config.SecretKey)
+	utils.Click(i.page, ".grv-installer-btn-new-site")
 	Eventually(func() bool {
 		return utils.IsFound(i.page, ".grv-installer-aws-region") || i.IsWarningVisible()
 	}, defaults.AjaxCallTimeout).Should(BeTrue(), "should accept AWS keys")
@@ -79,7 +78,7 @@ func (i *Installer) InitOnPremInstallation(domainName string) {
 	specifyDomainName(i.page, domainName)
 
 	log.Infof("setting provisioner")
-	Expect(i.page.FindByClass("--metal").Click()).To(Succeed())
+	utils.Click(i.page, ".--metal")
 	i.proceedToReqs()
 }
 
@@ -154,15 +153,13 @@ func (i *Installer) GetAWSProfiles() []AWSProfile {
 // ProceedToSite proceeds to the cluster site once installation is completed.
 func (i *Installer) ProceedToSite() {
 	log.Info("trying to proceed to site")
-	Expect(i.page.Find(".grv-installer-progress-result .btn-primary").Click()).To(Succeed())
+	utils.Click(i.page, ".grv-installer-progress-result .btn-primary")
 }
 
 // StartInstallation starts install operation
 func (i *Installer) StartInstallation() {
 	log.Info("clicking on start installation")
-	button := i.page.Find(".grv-installer-footer .btn-primary")
-	Expect(button).To(BeFound())
-	Expect(button.Click()).To(Succeed())
+	utils.Click(i.page, ".grv-installer-footer .btn-primary")
 	log.Info("checking if installation has started")
 	Eventually(func() bool {
 		return i.IsInProgressStep() || i.IsWarningVisible()
@@ -231,9 +228,9 @@ func (i *Installer) NeedsBandwagon(domainName string) bool {
 func (i *Installer) SelectFlavorByIndex(index int) {
 	log.Infof("selecting a flavor")
 	cssSelector := fmt.Sprintf(".grv-slider-value-desc:nth-child(%v) span", index)
-	elem := i.page.First(cssSelector)
-	Expect(elem).To(BeFound())
-	Expect(elem.Click()).To(Succeed())
+	utils.Retry(i.page, "select flavor "+cssSelector, func() error {
+		return i.page.First(cssSelector).Click()
+	})
 }
 
 // SelectFlavorByLabel selects flavor by label
@@ -272,7 +269,7 @@ func (i *Installer) WaitForCompletion() {
 
 func (i *Installer) proceedToReqs() {
 	log.Info("trying to init install operation")
-	Expect(i.page.FindByClass("grv-installer-btn-new-site").Click()).To(Succeed())
+	utils.Click(i.page, ".grv-installer-btn-new-site")
 	Eventually(func() bool {
 		return i.hasIssues() || i.IsRequirementsReviewStep()
 	}, defaults.InstallCreateClusterTimeout).Should(BeTrue())
@@ -327,7 +324,7 @@ func getServerCountFromSelectedProfile(page *web.Page) int {
 
 func specifyDomainName(page *web.Page, domainName string) {
 	log.Infof("specifying domain name")
-	Expect(page.FindByName("domainName").Fill(domainName)).To(Succeed())
+	utils.Fill(page, `[name="domainName"]`, domainName)
 	Eventually(func() bool {
 		return utils.IsFound(page, ".fa-check") || utils.HasValidationErrors(page)
 	}, defaults.AjaxCallTimeout).Should(BeTrue())
@@ -47,6 +47,33 @@ func (s *Site) GoToServers() ServerPage {
 	return ServerPage{site: s}
 }
 
+// GoToOperations navigates to cluster operations log page
+func (s *Site) GoToOperations() OperationsPage {
+	url := utils.GetSiteOperationsURL(s.page, s.domainName)
+	VerifySiteNavigation(s.page, url)
+	Eventually(s.page.FindByClass("grv-site-operations"), defaults.AjaxCallTimeout).
+		Should(BeFound(), "waiting for operations to load")
+	return OperationsPage{site: s}
+}
+
+// GoToUsers navigates to cluster user management page
+func (s *Site) GoToUsers() UsersPage {
+	url := utils.GetSiteUsersURL(s.page, s.domainName)
+	VerifySiteNavigation(s.page, url)
+	Eventually(s.page.FindByClass("grv-site-users"), defaults.AjaxCallTimeout).
+		Should(BeFound(), "waiting for users to load")
+	return UsersPage{site: s}
+}
+
+// GoToLicense navigates to cluster license page
+func (s *Site) GoToLicense() LicensePage {
+	url := utils.GetSiteLicenseURL(s.page, s.domainName)
+	VerifySiteNavigation(s.page, url)
+	Eventually(s.page.FindByClass("grv-site-license"), defaults.AjaxCallTimeout).
+		Should(BeFound(), "waiting for license to load")
+	return LicensePage{site: s}
+}
+
 // UpdateWithLatestVersion updates this cluster with the new version
 func (s *Site) UpdateWithLatestVersion() {
 	log.Infof("looking for available versions")
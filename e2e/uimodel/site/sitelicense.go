@@ -0,0 +1,48 @@
+package site
+
+import (
+	"time"
+
+	"github.com/gravitational/robotest/e2e/uimodel/defaults"
+	"github.com/gravitational/robotest/e2e/uimodel/utils"
+
+	. "github.com/onsi/gomega"
+	. "github.com/sclevine/agouti/matchers"
+	log "github.com/sirupsen/logrus"
+)
+
+// LicensePage is cluster license page ui model
+type LicensePage struct {
+	site *Site
+}
+
+// UpdateLicense uploads the specified license to the cluster
+func (p *LicensePage) UpdateLicense(license string) {
+	log.Infof("updating cluster license")
+	page := p.site.page
+	Expect(license).NotTo(BeEmpty(), "should have a valid license")
+	utils.Click(page, ".grv-site-license-btn-update")
+	elems := page.Find(".modal-dialog textarea")
+	Eventually(elems, defaults.FindTimeout).Should(BeFound(), "should open license dialog")
+	Expect(elems.SendKeys(license)).To(Succeed(), "should input the license text")
+	utils.Click(page, ".modal-dialog .btn-primary")
+	Eventually(page.Find(".modal-dialog"), defaults.AjaxCallTimeout).
+		ShouldNot(BeFound(), "should accept the license")
+}
+
+// IsLicenseExpired checks if the cluster shows the expired license banner
+func (s *Site) IsLicenseExpired() bool {
+	return utils.IsFound(s.page, ".grv-site-license-expired")
+}
+
+// WaitForLicenseExpired waits until the cluster shows the expired license banner
+func (s *Site) WaitForLicenseExpired(timeout, pollInterval time.Duration) {
+	Eventually(func() bool {
+		log.Infof("checking for expired license banner")
+		if s.IsLicenseExpired() {
+			return true
+		}
+		s.page.Refresh()
+		return false
+	}, timeout, pollInterval).Should(BeTrue(), "should display expired license banner")
+}
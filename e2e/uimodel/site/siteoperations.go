@@ -0,0 +1,69 @@
+package site
+
+import (
+	"encoding/json"
+
+	"github.com/gravitational/robotest/e2e/uimodel/defaults"
+
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+)
+
+// OperationsPage is cluster operations log page ui model
+type OperationsPage struct {
+	site *Site
+}
+
+// SiteOperation is a cluster operation as listed in the operations log
+type SiteOperation struct {
+	ID      string `json:"ID"`
+	Type    string `json:"Type"`
+	State   string `json:"State"`
+	Created string `json:"Created"`
+}
+
+// GetOperations returns the operations of this cluster, most recent first
+func (p *OperationsPage) GetOperations() []SiteOperation {
+	const script = `
+            var getter = [ ["site_operations"], opList => {
+                return opList.map(opMap => {
+                    return {
+                        ID: opMap.get("id"),
+                        Type: opMap.get("type"),
+                        State: opMap.get("state"),
+                        Created: opMap.get("created")
+                    }
+                }).toList().toJS();
+            }];
+
+            var data = window.reactor.evaluate(getter)
+            return JSON.stringify(data);
+        `
+	var items []SiteOperation
+	var result string
+
+	Expect(p.site.page.RunScript(script, nil, &result)).To(Succeed())
+	Expect(json.Unmarshal([]byte(result), &items)).To(Succeed())
+	return items
+}
+
+// LastOperation returns the most recent operation of this cluster
+func (p *OperationsPage) LastOperation() SiteOperation {
+	operations := p.GetOperations()
+	Expect(operations).NotTo(BeEmpty(), "should have at least one operation")
+	return operations[0]
+}
+
+// WaitForOperation waits until the most recent operation of the given type
+// reaches the specified state
+func (p *OperationsPage) WaitForOperation(opType, state string) {
+	log.Infof("waiting for %v operation to become %v", opType, state)
+	Eventually(func() bool {
+		for _, op := range p.GetOperations() {
+			if op.Type == opType {
+				return op.State == state
+			}
+		}
+		return false
+	}, defaults.SiteOperationEndTimeout).Should(BeTrue(), "should find %v operation in state %v", opType, state)
+}
@@ -0,0 +1,93 @@
+package site
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gravitational/robotest/e2e/uimodel/defaults"
+	"github.com/gravitational/robotest/e2e/uimodel/utils"
+
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+)
+
+// UsersPage is cluster user management page ui model
+type UsersPage struct {
+	site *Site
+}
+
+// SiteUser is a cluster user
+type SiteUser struct {
+	Email  string   `json:"Email"`
+	Roles  []string `json:"Roles"`
+	Status string   `json:"Status"`
+}
+
+// GetUsers returns the users of this cluster
+func (p *UsersPage) GetUsers() []SiteUser {
+	const script = `
+            var getter = [ ["site_users"], userList => {
+                return userList.map(userMap => {
+                    return {
+                        Email: userMap.get("userId"),
+                        Roles: userMap.get("roles"),
+                        Status: userMap.get("status")
+                    }
+                }).toList().toJS();
+            }];
+
+            var data = window.reactor.evaluate(getter)
+            return JSON.stringify(data);
+        `
+	var items []SiteUser
+	var result string
+
+	Expect(p.site.page.RunScript(script, nil, &result)).To(Succeed())
+	Expect(json.Unmarshal([]byte(result), &items)).To(Succeed())
+	return items
+}
+
+// InviteUser invites a new user with the given email and role
+func (p *UsersPage) InviteUser(email, role string) {
+	log.Infof("inviting user %v with role %v", email, role)
+	page := p.site.page
+	utils.Click(page, ".grv-site-users-btn-invite")
+	utils.Fill(page, `.modal-dialog [name="userId"]`, email)
+	utils.SetDropdownValue(page, "grv-site-users-roles", role)
+	utils.Click(page, ".modal-dialog .btn-primary")
+	Eventually(p.hasUser(email), defaults.AjaxCallTimeout).Should(BeTrue(), "should find the invited user")
+}
+
+// DeleteUser deletes the user with the given email
+func (p *UsersPage) DeleteUser(email string) {
+	log.Infof("deleting user %v", email)
+	page := p.site.page
+	index := p.userIndex(email)
+	Expect(index).To(BeNumerically(">=", 0), "should find user %v", email)
+
+	utils.Click(page, fmt.Sprintf(".grv-site-users tr:nth-child(%v) .dropdown-toggle", index+1))
+	utils.Click(page, fmt.Sprintf(".grv-site-users tr:nth-child(%v) .dropdown-menu .fa-trash", index+1))
+	utils.Click(page, ".modal-dialog .btn-danger")
+	Eventually(p.hasUser(email), defaults.AjaxCallTimeout).ShouldNot(BeTrue(), "verify that the user disappeared from the list")
+}
+
+func (p *UsersPage) hasUser(email string) func() bool {
+	return func() bool {
+		return p.userIndex(email) >= 0
+	}
+}
+
+func (p *UsersPage) userIndex(email string) int {
+	const scriptTemplate = `
+            var targetIndex = -1;
+            var rows = document.querySelectorAll(".grv-site-users .grv-table tbody tr");
+            rows.forEach( (z, index) => {
+                if( z.innerText.indexOf("%v") !== -1) targetIndex = index;
+            })
+
+            return targetIndex;
+        `
+	var result int
+	Expect(p.site.page.RunScript(fmt.Sprintf(scriptTemplate, email), nil, &result)).To(Succeed())
+	return result
+}
@@ -14,6 +14,8 @@ import (
 const (
 	WithEmail      = "email"
 	WithGoogle     = "google"
+	WithOIDC       = "oidc"
+	WithSAML       = "saml"
 	WithNoProvider = ""
 )
 
@@ -39,8 +41,8 @@ func (u *User) LoginWithEmail() {
 	if count > 0 {
 		Expect(u.page.FindByName("userId").Fill(u.email)).To(Succeed())
 	}
-	Expect(u.page.FindByName("password").Fill(u.password)).To(Succeed())
-	Expect(u.page.FindByClass("btn-primary").Click()).To(Succeed())
+	utils.Fill(u.page, `[name="password"]`, u.password)
+	utils.Click(u.page, ".btn-primary")
 	Eventually(u.page.URL, defaults.FindTimeout).ShouldNot(HaveSuffix("/login"))
 }
 
@@ -55,10 +57,9 @@ func (u *User) LoginWithGoogle() {
 	if userCount == 0 {
 		Expect(u.page.FindByID("identifierId").Fill(u.email)).To(Succeed())
 		Expect(u.page.FindByID("identifierNext").Click()).To(Succeed())
-		Eventually(u.page.FindByName("password"), defaults.LoginGoogleNextStepTimeout).Should(BeFound())
-		utils.PauseForPageJs()
-		Expect(u.page.FindByName("password").Fill(u.password)).To(Succeed())
-		Expect(u.page.FindByID("passwordNext").Click()).To(Succeed())
+		utils.WaitFor(u.page, `[name="password"]`)
+		utils.Fill(u.page, `[name="password"]`, u.password)
+		utils.Click(u.page, "#passwordNext")
 	} else {
 		Expect(u.page.Find(cssUserSelector).Click()).To(Succeed())
 	}
@@ -82,8 +83,7 @@ func (u *User) LoginWithGoogle() {
 
 // Signout logs out a user
 func (u *User) Signout() {
-	Eventually(u.page.FindByClass("fa-sign-out"), defaults.FindTimeout).Should(BeFound())
-	Expect(u.page.FindByClass("fa-sign-out").Click()).To(Succeed())
+	utils.Click(u.page, ".fa-sign-out")
 	Eventually(u.page.FindByClass("grv-user-login")).Should(BeFound())
 }
 
@@ -101,6 +101,9 @@ func EnsureUserAt(page *web.Page, URL string) {
 			user.LoginWithEmail()
 		case WithGoogle:
 			user.LoginWithGoogle()
+		case WithOIDC, WithSAML:
+			Expect(login.SSO).NotTo(BeNil(), "requires SSO configuration")
+			user.LoginWithSSO(*login.SSO)
 		default:
 			framework.Failf("unknown auth type %s", login.AuthProvider)
 		}
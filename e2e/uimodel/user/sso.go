@@ -0,0 +1,38 @@
+package user
+
+import (
+	"fmt"
+
+	"github.com/gravitational/robotest/e2e/framework"
+	"github.com/gravitational/robotest/e2e/uimodel/defaults"
+	"github.com/gravitational/robotest/e2e/uimodel/utils"
+
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+)
+
+// LoginWithSSO logs in a user through the external identity provider (OIDC or SAML)
+// configured with the SSO connector.
+// The form selectors default to the ones of the Dex login page
+func (u *User) LoginWithSSO(config framework.SSOConfig) {
+	log.Infof("logging in with SSO connector %q", config.Connector)
+	if config.Connector != "" {
+		button := u.page.FirstByXPath(fmt.Sprintf(`//button[contains(., %q)]`, config.Connector))
+		Expect(button.Click()).To(Succeed(), "should click on SSO connector button")
+	} else {
+		utils.Click(u.page, ".grv-user-login .btn-sso, .grv-user-login .btn-oidc, .grv-user-login .btn-saml")
+	}
+
+	utils.WaitFor(u.page, config.UsernameSelector())
+	utils.Fill(u.page, config.UsernameSelector(), u.email)
+	utils.Fill(u.page, config.PasswordSelector(), u.password)
+	utils.Click(u.page, config.SubmitSelector())
+	utils.PauseForPageJs()
+
+	// Identity providers may ask to approve the access for the client
+	if approve := config.ApproveSelector(); utils.IsFound(u.page, approve) {
+		log.Infof("approving access")
+		utils.Click(u.page, approve)
+	}
+	Eventually(u.page.URL, defaults.FindTimeout).ShouldNot(HaveSuffix("/login"))
+}
@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gravitational/robotest/e2e/framework"
+	"github.com/gravitational/robotest/e2e/uimodel/defaults"
+
+	web "github.com/sclevine/agouti"
+	log "github.com/sirupsen/logrus"
+)
+
+// RetryPolicy defines how page actions are retried on transient element errors
+type RetryPolicy struct {
+	// Timeout specifies the total time to retry an action
+	Timeout time.Duration
+	// Delay specifies the initial delay between attempts.
+	// The delay is doubled after each attempt up to MaxDelay
+	Delay time.Duration
+	// MaxDelay specifies the maximum delay between attempts
+	MaxDelay time.Duration
+	// BusySelectors lists selectors of spinners and overlays to wait
+	// to disappear before each attempt
+	BusySelectors []string
+}
+
+// ActionRetry is the retry policy for the page actions below
+var ActionRetry = RetryPolicy{
+	Timeout:       defaults.ActionRetryTimeout,
+	Delay:         defaults.ActionRetryDelay,
+	MaxDelay:      defaults.ActionRetryMaxDelay,
+	BusySelectors: []string{".fa-spinner", ".grv-spinner"},
+}
+
+// Click clicks the element matching selector, retrying while the element
+// is not found, is stale or is covered by another element
+func Click(page *web.Page, selector string) {
+	Retry(page, "click "+selector, func() error {
+		return page.Find(selector).Click()
+	})
+}
+
+// Fill fills the element matching selector with value, retrying while the element
+// is not found or is stale
+func Fill(page *web.Page, selector, value string) {
+	Retry(page, "fill "+selector, func() error {
+		return page.Find(selector).Fill(value)
+	})
+}
+
+// WaitFor waits until the element matching selector becomes visible
+func WaitFor(page *web.Page, selector string) {
+	Retry(page, "find "+selector, func() error {
+		visible, err := page.Find(selector).Visible()
+		if err != nil {
+			return err
+		}
+		if !visible {
+			return errNotVisible
+		}
+		return nil
+	})
+}
+
+// Retry runs action on the page according to ActionRetry until it succeeds
+// or fails with a non-transient error.
+// The spec fails if the action does not succeed
+func Retry(page *web.Page, description string, action func() error) {
+	err := ActionRetry.do(page, action)
+	if err != nil {
+		framework.Failf("failed to %v: %v", description, err)
+	}
+}
+
+func (r RetryPolicy) do(page *web.Page, action func() error) error {
+	deadline := time.Now().Add(r.Timeout)
+	delay := r.Delay
+	for {
+		r.waitNotBusy(page, deadline)
+		err := action()
+		if err == nil || !isTransient(err) || time.Now().After(deadline) {
+			return err
+		}
+		log.Debugf("retrying in %v: %v", delay, err)
+		time.Sleep(delay)
+		delay *= 2
+		if delay > r.MaxDelay {
+			delay = r.MaxDelay
+		}
+	}
+}
+
+// waitNotBusy waits until none of the busy selectors is visible or the deadline passes
+func (r RetryPolicy) waitNotBusy(page *web.Page, deadline time.Time) {
+	for _, selector := range r.BusySelectors {
+		for time.Now().Before(deadline) {
+			visible, err := page.Find(selector).Visible()
+			if err != nil || !visible {
+				break
+			}
+			time.Sleep(defaults.EventuallyPollInterval)
+		}
+	}
+}
+
+// isTransient returns true if err indicates an element that is not (yet)
+// available for interaction
+func isTransient(err error) bool {
+	if err == errNotVisible {
+		return true
+	}
+	message := strings.ToLower(err.Error())
+	for _, pattern := range transientErrors {
+		if strings.Contains(message, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// transientErrors lists the WebDriver error messages that are worth retrying
+var transientErrors = []string{
+	"stale element reference",
+	"element not found",
+	"no such element",
+	"element not visible",
+	"element not interactable",
+	"is not clickable",
+}
+
+type notVisibleError struct{}
+
+func (notVisibleError) Error() string { return "element not visible" }
+
+var errNotVisible error = notVisibleError{}
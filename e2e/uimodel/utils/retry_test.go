@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetriesTransientErrors(t *testing.T) {
+	var attempts int
+	err := testPolicy.do(nil, func() error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("stale element reference: element is not attached to the page document")
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+}
+
+func TestDoesNotRetryOtherErrors(t *testing.T) {
+	var attempts int
+	err := testPolicy.do(nil, func() error {
+		attempts++
+		return errors.New("ambiguous find")
+	})
+	assert.EqualError(t, err, "ambiguous find")
+	assert.Equal(t, 1, attempts)
+}
+
+func TestStopsRetryingAfterTimeout(t *testing.T) {
+	var attempts int
+	err := testPolicy.do(nil, func() error {
+		attempts++
+		return errNotVisible
+	})
+	assert.Equal(t, errNotVisible, err)
+	assert.True(t, attempts > 1, "expected more than one attempt, got %v", attempts)
+}
+
+func TestDetectsTransientErrors(t *testing.T) {
+	var testCases = []struct {
+		err       error
+		transient bool
+	}{
+		{errNotVisible, true},
+		{errors.New("failed to select element from selection 'CSS: .btn': element not found"), true},
+		{errors.New("unknown error: Element is not clickable at point (100, 200)"), true},
+		{errors.New("no such element: Unable to locate element"), true},
+		{errors.New("failed to select element from selection 'CSS: .btn': ambiguous find"), false},
+		{errors.New("failed to navigate: connection refused"), false},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.transient, isTransient(tc.err), tc.err.Error())
+	}
+}
+
+// testPolicy retries without waiting for busy elements, so the page is not used
+var testPolicy = RetryPolicy{
+	Timeout:  50 * time.Millisecond,
+	Delay:    time.Millisecond,
+	MaxDelay: 5 * time.Millisecond,
+}
@@ -65,6 +65,24 @@ func GetSiteServersURL(page *web.Page, clusterName string) string {
 	return fmt.Sprintf("%v/servers", clusterURL)
 }
 
+// GetSiteOperationsURL returns cluster operations page URL
+func GetSiteOperationsURL(page *web.Page, clusterName string) string {
+	clusterURL := GetSiteURL(page, clusterName)
+	return fmt.Sprintf("%v/operations", clusterURL)
+}
+
+// GetSiteUsersURL returns cluster users page URL
+func GetSiteUsersURL(page *web.Page, clusterName string) string {
+	clusterURL := GetSiteURL(page, clusterName)
+	return fmt.Sprintf("%v/users", clusterURL)
+}
+
+// GetSiteLicenseURL returns cluster license page URL
+func GetSiteLicenseURL(page *web.Page, clusterName string) string {
+	clusterURL := GetSiteURL(page, clusterName)
+	return fmt.Sprintf("%v/license", clusterURL)
+}
+
 // FillOutAWSKeys fills out AWS access and secret fields with given values
 func FillOutAWSKeys(page *web.Page, accessKey string, secretKey string) {
 	Expect(page.FindByName("aws_access_key").Fill(accessKey)).To(Succeed(), "should enter access key")
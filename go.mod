@@ -34,9 +34,9 @@ require (
 	golang.org/x/crypto v0.0.0-20190308221718-c2843e01d9a2
 	golang.org/x/oauth2 v0.0.0-20181128211412-28207608b838
 	golang.org/x/sync v0.0.0-20181108010431-42b317875d0f // indirect
-	google.golang.org/api v0.0.0-20181129220737-af4fc4062c26 // indirect
+	google.golang.org/api v0.0.0-20181129220737-af4fc4062c26
 	google.golang.org/appengine v1.3.0 // indirect
-	google.golang.org/genproto v0.0.0-20181202183823-bd91e49a0898 // indirect
+	google.golang.org/genproto v0.0.0-20181202183823-bd91e49a0898
 	gopkg.in/alecthomas/kingpin.v2 v2.2.6 // indirect
 	gopkg.in/go-playground/assert.v1 v1.2.1 // indirect
 	gopkg.in/go-playground/validator.v9 v9.23.0
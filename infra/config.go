@@ -16,6 +16,9 @@ func (r *Config) Validate() error {
 type Config struct {
 	// ClusterName is the name assigned to the provisioned machines
 	ClusterName string `json:"cluster_name" `
+	// Settings optionally holds the provisioner-specific configuration,
+	// i.e. for provisioners registered with RegisterProvisioner
+	Settings map[string]interface{} `json:"settings,omitempty"`
 }
 
 // ProvisionerState defines the state configuration for a cluster
@@ -0,0 +1,132 @@
+// Package cost estimates the cloud cost of the infrastructure provisioned
+// for a test run.
+//
+// Prices are approximate on-demand list prices (USD) and are only meant
+// to give the order of magnitude of the spend per run - they do not account
+// for regional differences, network traffic or discounts.
+package cost
+
+import (
+	"time"
+
+	"github.com/gravitational/robotest/lib/constants"
+
+	"github.com/gravitational/trace"
+)
+
+// Usage describes the resources allocated for a single test run
+type Usage struct {
+	// Cloud specifies the cloud provider
+	Cloud string `json:"cloud"`
+	// InstanceType specifies the type of the provisioned VMs
+	InstanceType string `json:"instance_type"`
+	// Nodes specifies the number of provisioned VMs
+	Nodes int `json:"nodes"`
+	// DiskGB specifies the total size of the disks attached to each VM
+	DiskGB int `json:"disk_gb"`
+	// Preemptible specifies whether the VMs are preemptible (spot) instances
+	Preemptible bool `json:"preemptible,omitempty"`
+	// Duration specifies the wall-clock time the resources have been allocated for
+	Duration time.Duration `json:"duration"`
+}
+
+// Estimate describes the estimated cost of the resource usage
+type Estimate struct {
+	// Instances is the cost of the VMs
+	Instances float64 `json:"instances"`
+	// Disks is the cost of the attached disks
+	Disks float64 `json:"disks"`
+}
+
+// Total returns the total estimated cost
+func (r Estimate) Total() float64 {
+	return r.Instances + r.Disks
+}
+
+// Add returns the sum of this and the other estimate
+func (r Estimate) Add(other Estimate) Estimate {
+	return Estimate{
+		Instances: r.Instances + other.Instances,
+		Disks:     r.Disks + other.Disks,
+	}
+}
+
+// Compute estimates the cost of the specified resource usage.
+// Returns a NotFound error if the instance type is not in the price table
+func Compute(usage Usage) (*Estimate, error) {
+	instancePrices, ok := hourlyInstancePrices[usage.Cloud]
+	if !ok {
+		return nil, trace.NotFound("no prices for cloud %q", usage.Cloud)
+	}
+	hourly, ok := instancePrices[usage.InstanceType]
+	if !ok {
+		return nil, trace.NotFound("no price for %v instance type %q", usage.Cloud, usage.InstanceType)
+	}
+	if usage.Preemptible {
+		hourly *= preemptibleDiscount
+	}
+	hours := usage.Duration.Hours()
+	nodes := float64(usage.Nodes)
+	return &Estimate{
+		Instances: hourly * nodes * hours,
+		Disks:     monthlyDiskPrices[usage.Cloud] * float64(usage.DiskGB) * nodes * hours / hoursPerMonth,
+	}, nil
+}
+
+const (
+	// hoursPerMonth is the number of hours per month used for disk prices
+	hoursPerMonth = 730
+	// preemptibleDiscount approximates the price of a preemptible instance
+	// relative to the on-demand price
+	preemptibleDiscount = 0.3
+)
+
+// hourlyInstancePrices lists the on-demand price per hour of the instance types
+// commonly used with robotest
+var hourlyInstancePrices = map[string]map[string]float64{
+	constants.AWS: {
+		"c3.xlarge":   0.21,
+		"c3.2xlarge":  0.42,
+		"c4.xlarge":   0.199,
+		"c4.2xlarge":  0.398,
+		"c5.xlarge":   0.17,
+		"c5.2xlarge":  0.34,
+		"m4.xlarge":   0.2,
+		"m4.2xlarge":  0.4,
+		"m5.xlarge":   0.192,
+		"m5.2xlarge":  0.384,
+		"t2.medium":   0.0464,
+		"t2.large":    0.0928,
+		"t2.xlarge":   0.1856,
+		"m6g.xlarge":  0.154,
+		"m6g.2xlarge": 0.308,
+		"c6g.xlarge":  0.136,
+		"c6g.2xlarge": 0.272,
+	},
+	constants.GCE: {
+		"n1-standard-1":  0.0475,
+		"n1-standard-2":  0.095,
+		"n1-standard-4":  0.19,
+		"n1-standard-8":  0.38,
+		"n1-highcpu-4":   0.1418,
+		"n1-highcpu-8":   0.2836,
+		"n1-highmem-4":   0.2369,
+		"t2a-standard-4": 0.154,
+		"t2a-standard-8": 0.308,
+	},
+	constants.Azure: {
+		"Standard_D2s_v3": 0.096,
+		"Standard_D4s_v3": 0.192,
+		"Standard_D8s_v3": 0.384,
+		"Standard_F4s":    0.199,
+		"Standard_F8s":    0.398,
+	},
+}
+
+// monthlyDiskPrices lists the price per GB-month of the SSD disks provisioned
+// by the robotest terraform scripts
+var monthlyDiskPrices = map[string]float64{
+	constants.AWS:   0.1,
+	constants.GCE:   0.17,
+	constants.Azure: 0.135,
+}
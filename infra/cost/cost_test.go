@@ -0,0 +1,38 @@
+package cost
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gravitational/robotest/lib/constants"
+
+	"github.com/gravitational/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompute(t *testing.T) {
+	estimate, err := Compute(Usage{
+		Cloud:        constants.AWS,
+		InstanceType: "c3.xlarge",
+		Nodes:        3,
+		DiskGB:       100,
+		Duration:     2 * time.Hour,
+	})
+	require.NoError(t, err)
+	assert.InDelta(t, 0.21*3*2, estimate.Instances, 1e-9)
+	assert.InDelta(t, 0.1*100*3*2/hoursPerMonth, estimate.Disks, 1e-9)
+
+	preemptible, err := Compute(Usage{
+		Cloud:        constants.GCE,
+		InstanceType: "n1-standard-1",
+		Nodes:        1,
+		Preemptible:  true,
+		Duration:     time.Hour,
+	})
+	require.NoError(t, err)
+	assert.InDelta(t, 0.0475*preemptibleDiscount, preemptible.Total(), 1e-9)
+
+	_, err = Compute(Usage{Cloud: constants.AWS, InstanceType: "x1.unknown"})
+	assert.True(t, trace.IsNotFound(err))
+}
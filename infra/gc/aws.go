@@ -0,0 +1,198 @@
+package gc
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/gravitational/trace"
+)
+
+// AWSConfig defines the configuration of the AWS collector
+type AWSConfig struct {
+	// Region specifies the EC2 region to collect resources in
+	Region string
+	// StateBucket optionally specifies the S3 bucket with terraform state
+	// of the robotest runs (see terraform.Backend)
+	StateBucket string
+	// StatePrefix specifies the key prefix of the terraform state objects
+	StatePrefix string
+}
+
+// NewAWS returns a new collector for the AWS resources in the configured region.
+// Credentials are read from the environment
+func NewAWS(config AWSConfig) (Collector, error) {
+	if config.Region == "" {
+		return nil, trace.BadParameter("AWS region is required")
+	}
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(config.Region)})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &awsCollector{
+		AWSConfig: config,
+		ec2:       ec2.New(sess),
+		s3:        s3.New(sess),
+	}, nil
+}
+
+type awsCollector struct {
+	AWSConfig
+	ec2 *ec2.EC2
+	s3  *s3.S3
+}
+
+// Name identifies the collector
+func (r *awsCollector) Name() string {
+	return "aws/" + r.Region
+}
+
+// List returns the EC2 instances, security groups, placement groups
+// and terraform state allocated by robotest
+func (r *awsCollector) List(ctx context.Context) (resources []Resource, err error) {
+	runs := make(map[string]struct{})
+	err = r.ec2.DescribeInstancesPagesWithContext(ctx, &ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{
+			{Name: aws.String("tag:" + tagOrigin), Values: aws.StringSlice([]string{originRobotest})},
+			{Name: aws.String("instance-state-name"), Values: aws.StringSlice([]string{
+				"pending", "running", "stopping", "stopped"})},
+		},
+	}, func(page *ec2.DescribeInstancesOutput, last bool) bool {
+		for _, reservation := range page.Reservations {
+			for _, instance := range reservation.Instances {
+				runID := ec2Tag(instance.Tags, tagName)
+				runs[runID] = struct{}{}
+				resources = append(resources, Resource{
+					Kind:     KindInstance,
+					ID:       aws.StringValue(instance.InstanceId),
+					Location: r.Region,
+					RunID:    runID,
+					Created:  aws.TimeValue(instance.LaunchTime),
+				})
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	groups, err := r.ec2.DescribeSecurityGroupsWithContext(ctx, &ec2.DescribeSecurityGroupsInput{
+		Filters: []*ec2.Filter{
+			{Name: aws.String("tag:" + tagOrigin), Values: aws.StringSlice([]string{originRobotest})},
+		},
+	})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	for _, group := range groups.SecurityGroups {
+		resources = append(resources, Resource{
+			Kind:     KindSecurityGroup,
+			ID:       aws.StringValue(group.GroupId),
+			Location: r.Region,
+			RunID:    ec2Tag(group.Tags, tagName),
+		})
+	}
+
+	// Placement groups cannot be tagged and are named after the cluster,
+	// only consider those belonging to known runs
+	placementGroups, err := r.ec2.DescribePlacementGroupsWithContext(ctx, &ec2.DescribePlacementGroupsInput{})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	for _, group := range placementGroups.PlacementGroups {
+		name := aws.StringValue(group.GroupName)
+		if _, ok := runs[name]; !ok {
+			continue
+		}
+		resources = append(resources, Resource{
+			Kind:     KindPlacementGroup,
+			ID:       name,
+			Location: r.Region,
+			RunID:    name,
+		})
+	}
+
+	if r.StateBucket == "" {
+		return resources, nil
+	}
+	err = r.s3.ListObjectsPagesWithContext(ctx, &s3.ListObjectsInput{
+		Bucket: aws.String(r.StateBucket),
+		Prefix: aws.String(r.StatePrefix),
+	}, func(page *s3.ListObjectsOutput, last bool) bool {
+		for _, object := range page.Contents {
+			key := aws.StringValue(object.Key)
+			if path.Base(key) != "terraform.tfstate" {
+				continue
+			}
+			resources = append(resources, Resource{
+				Kind:     KindState,
+				ID:       key,
+				Location: r.StateBucket,
+				RunID:    path.Base(path.Dir(strings.TrimPrefix(key, r.StatePrefix))),
+				Created:  aws.TimeValue(object.LastModified),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return resources, nil
+}
+
+// Delete destroys the specified resource.
+// Instances are waited on for termination as dependent resources
+// cannot be removed before
+func (r *awsCollector) Delete(ctx context.Context, resource Resource) error {
+	var err error
+	switch resource.Kind {
+	case KindInstance:
+		_, err = r.ec2.TerminateInstancesWithContext(ctx, &ec2.TerminateInstancesInput{
+			InstanceIds: aws.StringSlice([]string{resource.ID}),
+		})
+		if err == nil {
+			err = r.ec2.WaitUntilInstanceTerminatedWithContext(ctx, &ec2.DescribeInstancesInput{
+				InstanceIds: aws.StringSlice([]string{resource.ID}),
+			})
+		}
+	case KindSecurityGroup:
+		_, err = r.ec2.DeleteSecurityGroupWithContext(ctx, &ec2.DeleteSecurityGroupInput{
+			GroupId: aws.String(resource.ID),
+		})
+	case KindPlacementGroup:
+		_, err = r.ec2.DeletePlacementGroupWithContext(ctx, &ec2.DeletePlacementGroupInput{
+			GroupName: aws.String(resource.ID),
+		})
+	case KindState:
+		_, err = r.s3.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(r.StateBucket),
+			Key:    aws.String(resource.ID),
+		})
+	default:
+		return trace.BadParameter("unsupported resource kind %v", resource.Kind)
+	}
+	return trace.Wrap(err)
+}
+
+func ec2Tag(tags []*ec2.Tag, key string) string {
+	for _, tag := range tags {
+		if aws.StringValue(tag.Key) == key {
+			return aws.StringValue(tag.Value)
+		}
+	}
+	return ""
+}
+
+const (
+	// tagName is the resource tag with the name of the cluster
+	tagName = "Name"
+	// tagOrigin is the resource tag identifying robotest resources
+	tagOrigin = "Origin"
+	// originRobotest is the value of the origin tag on robotest resources
+	originRobotest = "robotest"
+)
@@ -0,0 +1,170 @@
+// Package gc implements collection of the cloud resources leaked by
+// interrupted robotest runs.
+//
+// Resources are attributed to runs using the cloud-specific tags
+// (labels on GCE) robotest attaches to them during provisioning.
+// A run is considered expired once its oldest resource is older than
+// the configured TTL - all resources of an expired run are then destroyed.
+package gc
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// Collector lists and destroys robotest resources in a single cloud
+type Collector interface {
+	// Name identifies the collector
+	Name() string
+	// List returns all resources allocated by robotest
+	List(ctx context.Context) ([]Resource, error)
+	// Delete destroys the specified resource
+	Delete(ctx context.Context, resource Resource) error
+}
+
+// Resource describes a single cloud resource allocated by robotest
+type Resource struct {
+	// Kind specifies the type of the resource
+	Kind Kind `json:"kind"`
+	// ID identifies the resource within the cloud
+	ID string `json:"id"`
+	// Location specifies the region or zone of the resource
+	Location string `json:"location"`
+	// RunID identifies the robotest run (cluster) the resource belongs to
+	RunID string `json:"run_id"`
+	// Created specifies the resource creation time.
+	// Can be zero if the cloud does not report it for the resource
+	Created time.Time `json:"created"`
+}
+
+// Kind specifies the type of a cloud resource.
+// Kinds are ordered by the order of deletion so that resources are removed
+// before the resources they depend upon
+type Kind int
+
+const (
+	// KindInstanceGroup is a group of instances
+	KindInstanceGroup Kind = iota
+	// KindInstance is a virtual machine
+	KindInstance
+	// KindDisk is a block device
+	KindDisk
+	// KindSecurityGroup is a firewall configuration
+	KindSecurityGroup
+	// KindPlacementGroup is a placement policy for instances
+	KindPlacementGroup
+	// KindState is a terraform state object
+	KindState
+)
+
+// String returns the textual representation of this kind
+func (r Kind) String() string {
+	switch r {
+	case KindInstanceGroup:
+		return "instance-group"
+	case KindInstance:
+		return "instance"
+	case KindDisk:
+		return "disk"
+	case KindSecurityGroup:
+		return "security-group"
+	case KindPlacementGroup:
+		return "placement-group"
+	case KindState:
+		return "terraform-state"
+	default:
+		return "unknown"
+	}
+}
+
+// Config defines the garbage collector configuration
+type Config struct {
+	// Collectors lists the clouds to collect resources from
+	Collectors []Collector
+	// TTL specifies the age of a run after which its resources are collected
+	TTL time.Duration
+	// DryRun only lists the resources that would be collected
+	DryRun bool
+	// Clock overrides the time source.
+	// Defaults to time.Now
+	Clock func() time.Time
+	logrus.FieldLogger
+}
+
+// Run lists the resources from all configured collectors and destroys
+// the resources of the runs that have expired.
+// Returns the list of expired resources
+func Run(ctx context.Context, config Config) (expired []Resource, err error) {
+	if config.TTL <= 0 {
+		return nil, trace.BadParameter("TTL must be positive")
+	}
+	if config.Clock == nil {
+		config.Clock = time.Now
+	}
+	if config.FieldLogger == nil {
+		config.FieldLogger = logrus.StandardLogger()
+	}
+
+	var errors []error
+	for _, collector := range config.Collectors {
+		log := config.WithField("collector", collector.Name())
+		resources, err := collector.List(ctx)
+		if err != nil {
+			errors = append(errors, trace.Wrap(err, "failed to list %v resources", collector.Name()))
+			continue
+		}
+		resources = Expired(resources, config.TTL, config.Clock())
+		expired = append(expired, resources...)
+		for _, resource := range resources {
+			log := log.WithFields(logrus.Fields{
+				"kind":     resource.Kind.String(),
+				"id":       resource.ID,
+				"location": resource.Location,
+				"run_id":   resource.RunID,
+				"created":  resource.Created,
+			})
+			if config.DryRun {
+				log.Info("Would delete.")
+				continue
+			}
+			log.Info("Delete.")
+			err := collector.Delete(ctx, resource)
+			if err != nil {
+				log.WithError(err).Warn("Failed to delete.")
+				errors = append(errors, trace.Wrap(err, "failed to delete %v %v", resource.Kind, resource.ID))
+			}
+		}
+	}
+	return expired, trace.NewAggregate(errors...)
+}
+
+// Expired returns the resources of runs which are older than ttl
+// in the order of deletion.
+// The age of a run is determined by its oldest resource.
+// Runs without any dated resources are never considered expired
+func Expired(resources []Resource, ttl time.Duration, now time.Time) (expired []Resource) {
+	created := make(map[string]time.Time)
+	for _, resource := range resources {
+		if resource.Created.IsZero() {
+			continue
+		}
+		if ts, ok := created[resource.RunID]; !ok || resource.Created.Before(ts) {
+			created[resource.RunID] = resource.Created
+		}
+	}
+	for _, resource := range resources {
+		ts, ok := created[resource.RunID]
+		if !ok || now.Sub(ts) < ttl {
+			continue
+		}
+		expired = append(expired, resource)
+	}
+	sort.SliceStable(expired, func(i, j int) bool {
+		return expired[i].Kind < expired[j].Kind
+	})
+	return expired
+}
@@ -0,0 +1,85 @@
+package gc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpired(t *testing.T) {
+	now := time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
+	resources := []Resource{
+		{Kind: KindSecurityGroup, ID: "sg-old", RunID: "old"},
+		{Kind: KindInstance, ID: "i-old-1", RunID: "old", Created: now.Add(-48 * time.Hour)},
+		{Kind: KindInstance, ID: "i-old-2", RunID: "old", Created: now.Add(-time.Hour)},
+		{Kind: KindInstance, ID: "i-new", RunID: "new", Created: now.Add(-time.Hour)},
+		{Kind: KindSecurityGroup, ID: "sg-undated", RunID: "undated"},
+	}
+
+	expired := Expired(resources, 24*time.Hour, now)
+	var ids []string
+	for _, resource := range expired {
+		ids = append(ids, resource.ID)
+	}
+	assert.Equal(t, []string{"i-old-1", "i-old-2", "sg-old"}, ids)
+}
+
+func TestRunDryRun(t *testing.T) {
+	now := time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
+	collector := &testCollector{resources: []Resource{
+		{Kind: KindInstance, ID: "i-old", RunID: "old", Created: now.Add(-48 * time.Hour)},
+	}}
+	config := Config{
+		Collectors: []Collector{collector},
+		TTL:        time.Hour,
+		DryRun:     true,
+		Clock:      func() time.Time { return now },
+	}
+
+	expired, err := Run(context.Background(), config)
+	require.NoError(t, err)
+	assert.Len(t, expired, 1)
+	assert.Empty(t, collector.deleted)
+
+	config.DryRun = false
+	_, err = Run(context.Background(), config)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"i-old"}, collector.deleted)
+}
+
+type testCollector struct {
+	resources []Resource
+	deleted   []string
+}
+
+func (r *testCollector) Name() string { return "test" }
+
+func (r *testCollector) List(context.Context) ([]Resource, error) { return r.resources, nil }
+
+func (r *testCollector) Delete(ctx context.Context, resource Resource) error {
+	r.deleted = append(r.deleted, resource.ID)
+	return nil
+}
+
+func TestStateRunID(t *testing.T) {
+	var testCases = []struct {
+		name   string
+		prefix string
+		runID  string
+		ok     bool
+	}{
+		{"robotest/run-1/default.tfstate", "robotest/", "run-1", true},
+		{"robotest/run-1/default.tflock", "robotest", "run-1", true},
+		{"run-1/default.tfstate", "", "run-1", true},
+		{"default.tfstate", "", "", false},
+		{"robotest/run-1/terraform.log", "robotest/", "", false},
+	}
+	for _, tc := range testCases {
+		runID, ok := stateRunID(tc.name, tc.prefix)
+		assert.Equal(t, tc.ok, ok, tc.name)
+		assert.Equal(t, tc.runID, runID, tc.name)
+	}
+}
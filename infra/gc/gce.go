@@ -0,0 +1,224 @@
+package gc
+
+import (
+	"context"
+	"io/ioutil"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gravitational/robotest/infra/providers/gce"
+
+	"github.com/gravitational/trace"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	compute "google.golang.org/api/compute/v1"
+	storage "google.golang.org/api/storage/v1"
+)
+
+// GCEConfig defines the configuration of the GCE collector
+type GCEConfig struct {
+	// Project specifies the project to collect resources in
+	Project string
+	// Credentials names the service account file
+	Credentials string
+	// StateBucket optionally specifies the GCS bucket with terraform state
+	// of the robotest runs (see terraform.Backend)
+	StateBucket string
+	// StatePrefix specifies the object prefix of the terraform state objects
+	StatePrefix string
+}
+
+// NewGCE returns a new collector for the GCE resources in the configured project
+func NewGCE(ctx context.Context, config GCEConfig) (Collector, error) {
+	if config.Project == "" {
+		return nil, trace.BadParameter("GCE project is required")
+	}
+	data, err := ioutil.ReadFile(config.Credentials)
+	if err != nil {
+		return nil, trace.ConvertSystemError(err)
+	}
+	creds, err := google.CredentialsFromJSON(ctx, data, compute.ComputeScope, storage.DevstorageReadWriteScope)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	client := oauth2.NewClient(ctx, creds.TokenSource)
+	service, err := compute.New(client)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	storageService, err := storage.New(client)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &gceCollector{GCEConfig: config, service: service, storage: storageService}, nil
+}
+
+type gceCollector struct {
+	GCEConfig
+	service *compute.Service
+	storage *storage.Service
+}
+
+// Name identifies the collector
+func (r *gceCollector) Name() string {
+	return "gce/" + r.Project
+}
+
+// List returns the instance groups, instances, disks and terraform state allocated by robotest.
+// Robotest resources are labeled with the cluster node tag
+func (r *gceCollector) List(ctx context.Context) (resources []Resource, err error) {
+	err = r.service.Instances.AggregatedList(r.Project).Filter(labelFilter).Pages(ctx,
+		func(page *compute.InstanceAggregatedList) error {
+			for _, scope := range page.Items {
+				for _, instance := range scope.Instances {
+					resources = append(resources, Resource{
+						Kind:     KindInstance,
+						ID:       instance.Name,
+						Location: path.Base(instance.Zone),
+						RunID:    instance.Labels[labelCluster],
+						Created:  parseTimestamp(instance.CreationTimestamp),
+					})
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	err = r.service.Disks.AggregatedList(r.Project).Filter(labelFilter).Pages(ctx,
+		func(page *compute.DiskAggregatedList) error {
+			for _, scope := range page.Items {
+				for _, disk := range scope.Disks {
+					resources = append(resources, Resource{
+						Kind:     KindDisk,
+						ID:       disk.Name,
+						Location: path.Base(disk.Zone),
+						RunID:    disk.Labels[labelCluster],
+						Created:  parseTimestamp(disk.CreationTimestamp),
+					})
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	// Instance groups do not support labels and are named after the node tag
+	err = r.service.InstanceGroups.AggregatedList(r.Project).Filter(`name eq "robotest-.*-node-group"`).Pages(ctx,
+		func(page *compute.InstanceGroupAggregatedList) error {
+			for _, scope := range page.Items {
+				for _, group := range scope.InstanceGroups {
+					resources = append(resources, Resource{
+						Kind:     KindInstanceGroup,
+						ID:       group.Name,
+						Location: path.Base(group.Zone),
+						RunID:    strings.TrimSuffix(group.Name, "-node-group"),
+						Created:  parseTimestamp(group.CreationTimestamp),
+					})
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	if r.StateBucket == "" {
+		return resources, nil
+	}
+	err = r.storage.Objects.List(r.StateBucket).Prefix(r.StatePrefix).Pages(ctx,
+		func(page *storage.Objects) error {
+			for _, object := range page.Items {
+				if runID, ok := stateRunID(object.Name, r.StatePrefix); ok {
+					resources = append(resources, Resource{
+						Kind:     KindState,
+						ID:       object.Name,
+						Location: r.StateBucket,
+						// attribute the state to the run the same way as the labeled resources
+						RunID:   gce.TranslateClusterName(runID),
+						Created: parseTimestamp(object.TimeCreated),
+					})
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return resources, nil
+}
+
+// stateRunID returns the run ID from the name of the terraform state object of
+// the gcs backend (see terraform.Backend.StatePath), i.e. <prefix>/<run ID>/default.tfstate.
+// Lock files left behind by interrupted runs are also considered state
+func stateRunID(name, prefix string) (runID string, ok bool) {
+	if ext := path.Ext(name); ext != ".tfstate" && ext != ".tflock" {
+		return "", false
+	}
+	runID = path.Base(path.Dir(strings.TrimPrefix(name, prefix)))
+	if runID == "." || runID == "/" {
+		return "", false
+	}
+	return runID, true
+}
+
+// Delete destroys the specified resource and waits for the operation to complete
+func (r *gceCollector) Delete(ctx context.Context, resource Resource) error {
+	var op *compute.Operation
+	var err error
+	switch resource.Kind {
+	case KindState:
+		err = r.storage.Objects.Delete(r.StateBucket, resource.ID).Context(ctx).Do()
+		return trace.Wrap(err)
+	case KindInstanceGroup:
+		op, err = r.service.InstanceGroups.Delete(r.Project, resource.Location, resource.ID).Context(ctx).Do()
+	case KindInstance:
+		op, err = r.service.Instances.Delete(r.Project, resource.Location, resource.ID).Context(ctx).Do()
+	case KindDisk:
+		op, err = r.service.Disks.Delete(r.Project, resource.Location, resource.ID).Context(ctx).Do()
+	default:
+		return trace.BadParameter("unsupported resource kind %v", resource.Kind)
+	}
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(r.waitZoneOperation(ctx, resource.Location, op))
+}
+
+func (r *gceCollector) waitZoneOperation(ctx context.Context, zone string, op *compute.Operation) (err error) {
+	for op.Status != "DONE" {
+		select {
+		case <-time.After(operationPollInterval):
+		case <-ctx.Done():
+			return trace.Wrap(ctx.Err())
+		}
+		op, err = r.service.ZoneOperations.Get(r.Project, zone, op.Name).Context(ctx).Do()
+		if err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	if op.Error != nil && len(op.Error.Errors) != 0 {
+		return trace.Errorf("operation %v failed: %v", op.Name, op.Error.Errors[0].Message)
+	}
+	return nil
+}
+
+func parseTimestamp(value string) time.Time {
+	ts, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return ts
+}
+
+const (
+	// labelCluster names the label with the cluster node tag
+	labelCluster = "cluster"
+	// labelFilter selects the resources labeled by robotest
+	labelFilter = `labels.cluster eq "robotest-.*"`
+	// operationPollInterval specifies the interval between operation status queries
+	operationPollInterval = 5 * time.Second
+)
@@ -0,0 +1,47 @@
+package gravity
+
+import (
+	"net"
+
+	"github.com/gravitational/robotest/infra"
+
+	"github.com/gravitational/trace"
+)
+
+const (
+	// AddressFamilyIPv4 selects the IPv4 addresses of the nodes for the cluster
+	AddressFamilyIPv4 = "ipv4"
+	// AddressFamilyIPv6 selects the IPv6 addresses of the nodes for the cluster.
+	// Requires the nodes to be provisioned dual-stack
+	AddressFamilyIPv6 = "ipv6"
+)
+
+// advertiseAddr returns the address the node advertises to the cluster for the given
+// address family. Defaults to the IPv4 address
+func advertiseAddr(node infra.Node, family string) (string, error) {
+	switch family {
+	case "", AddressFamilyIPv4:
+		return node.PrivateAddr(), nil
+	case AddressFamilyIPv6:
+		addr := node.PrivateAddrIPv6()
+		if addr == "" {
+			return "", trace.NotFound("%v has no IPv6 address, provision the nodes dual-stack", node)
+		}
+		return addr, nil
+	default:
+		return "", trace.BadParameter("unknown address family %q", family)
+	}
+}
+
+// peerAddr returns the address other nodes join the node at for the given address family.
+// IPv6 addresses are enclosed in square brackets
+func peerAddr(node infra.Node, family string) (string, error) {
+	addr, err := advertiseAddr(node, family)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	if ip := net.ParseIP(addr); ip != nil && ip.To4() == nil {
+		return "[" + addr + "]", nil
+	}
+	return addr, nil
+}
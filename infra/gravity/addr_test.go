@@ -0,0 +1,67 @@
+package gravity
+
+import (
+	"testing"
+
+	"github.com/gravitational/robotest/infra"
+
+	"github.com/gravitational/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeAddrs(t *testing.T) {
+	var testCases = []struct {
+		comment   string
+		node      addrNode
+		family    string
+		advertise string
+		peer      string
+	}{
+		{
+			comment:   "default to IPv4",
+			node:      addrNode{addr: "10.0.0.1", addr6: "fd00::1"},
+			advertise: "10.0.0.1",
+			peer:      "10.0.0.1",
+		},
+		{
+			comment:   "IPv4",
+			node:      addrNode{addr: "10.0.0.1", addr6: "fd00::1"},
+			family:    AddressFamilyIPv4,
+			advertise: "10.0.0.1",
+			peer:      "10.0.0.1",
+		},
+		{
+			comment:   "IPv6 in brackets",
+			node:      addrNode{addr: "10.0.0.1", addr6: "fd00::1"},
+			family:    AddressFamilyIPv6,
+			advertise: "fd00::1",
+			peer:      "[fd00::1]",
+		},
+	}
+	for _, tc := range testCases {
+		advertise, err := advertiseAddr(tc.node, tc.family)
+		require.NoError(t, err, tc.comment)
+		assert.Equal(t, tc.advertise, advertise, tc.comment)
+		peer, err := peerAddr(tc.node, tc.family)
+		require.NoError(t, err, tc.comment)
+		assert.Equal(t, tc.peer, peer, tc.comment)
+	}
+}
+
+func TestNodeAddrsInvalid(t *testing.T) {
+	_, err := peerAddr(addrNode{addr: "10.0.0.1"}, AddressFamilyIPv6)
+	assert.True(t, trace.IsNotFound(err), "IPv4-only node: %v", err)
+	_, err = peerAddr(addrNode{addr: "10.0.0.1", addr6: "fd00::1"}, "ipx")
+	assert.True(t, trace.IsBadParameter(err), "unknown address family: %v", err)
+}
+
+// addrNode is a node with the given addresses
+type addrNode struct {
+	infra.Node
+	addr, addr6 string
+}
+
+func (r addrNode) PrivateAddr() string     { return r.addr }
+func (r addrNode) PrivateAddrIPv6() string { return r.addr6 }
+func (r addrNode) String() string          { return r.addr }
@@ -0,0 +1,113 @@
+package gravity
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gravitational/robotest/lib/shell"
+	"github.com/gravitational/robotest/lib/utils"
+
+	"github.com/gravitational/trace"
+)
+
+// BlockEgress blocks all outbound traffic from the nodes (and pods running on them)
+// except for private networks and the cloud metadata service
+func (c *TestContext) BlockEgress(nodes []Gravity) error {
+	c.Logger().Info("Block egress traffic.")
+	return trace.Wrap(c.runOnNodes(nodes, blockEgressCmd))
+}
+
+// AllowEgress removes the outbound traffic restrictions from the nodes
+func (c *TestContext) AllowEgress(nodes []Gravity) error {
+	c.Logger().Info("Allow egress traffic.")
+	return trace.Wrap(c.runOnNodes(nodes, allowEgressCmd))
+}
+
+// AssertEgressBlocked verifies that the nodes cannot reach the internet
+func (c *TestContext) AssertEgressBlocked(nodes []Gravity) error {
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	targets, err := gravityNodes(nodes)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	errs := make(chan error, len(targets))
+	for _, node := range targets {
+		go func(node *gravity) {
+			err := node.run(ctx, node.Logger(), egressProbeCmd, nil)
+			if err == nil {
+				errs <- trace.CompareFailed("%v can reach the internet with egress blocked", node)
+				return
+			}
+			errs <- nil
+		}(node)
+	}
+	return trace.Wrap(utils.CollectErrors(ctx, errs))
+}
+
+// withEgress runs fn with egress traffic temporarily allowed on the nodes
+// if the nodes are air-gapped
+func (c *TestContext) withEgress(nodes []Gravity, fn func() error) error {
+	if !c.provisionerConfig().AirGapped {
+		return trace.Wrap(fn())
+	}
+	if err := c.AllowEgress(nodes); err != nil {
+		return trace.Wrap(err)
+	}
+	err := fn()
+	if errBlock := c.BlockEgress(nodes); errBlock != nil {
+		return trace.NewAggregate(err, errBlock)
+	}
+	return trace.Wrap(err)
+}
+
+func (c *TestContext) runOnNodes(nodes []Gravity, cmd string) error {
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	targets, err := gravityNodes(nodes)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	errs := make(chan error, len(targets))
+	for _, node := range targets {
+		go func(node *gravity) {
+			errs <- node.run(ctx, node.Logger(), cmd, nil)
+		}(node)
+	}
+	return trace.Wrap(utils.CollectErrors(ctx, errs))
+}
+
+// egressChain is the iptables chain with the egress restrictions
+const egressChain = "ROBOTEST-EGRESS"
+
+// allowedEgressNetworks lists the destinations that stay reachable with egress blocked:
+// private networks (nodes, pods, services) and link-local addresses (cloud metadata, DNS and NTP)
+var allowedEgressNetworks = []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "169.254.0.0/16"}
+
+var blockEgressCmd = func() string {
+	rules := []string{
+		fmt.Sprintf("(sudo iptables -N %[1]v || sudo iptables -F %[1]v)", egressChain),
+		shell.Sudo("iptables", "-A", egressChain, "-o", "lo", "-j", "RETURN").String(),
+		shell.Sudo("iptables", "-A", egressChain, "-m", "conntrack", "--ctstate", "ESTABLISHED,RELATED", "-j", "RETURN").String(),
+	}
+	for _, network := range allowedEgressNetworks {
+		rules = append(rules, shell.Sudo("iptables", "-A", egressChain, "-d", network, "-j", "RETURN").String())
+	}
+	rules = append(rules, shell.Sudo("iptables", "-A", egressChain, "-j", "REJECT").String())
+	for _, chain := range []string{"OUTPUT", "FORWARD"} {
+		rules = append(rules, fmt.Sprintf("(sudo iptables -C %[1]v -j %[2]v || sudo iptables -I %[1]v 1 -j %[2]v)",
+			chain, egressChain))
+	}
+	return strings.Join(rules, " && ")
+}()
+
+var allowEgressCmd = fmt.Sprintf(
+	"while sudo iptables -D OUTPUT -j %[1]v 2>/dev/null; do :; done; "+
+		"while sudo iptables -D FORWARD -j %[1]v 2>/dev/null; do :; done; "+
+		"sudo iptables -F %[1]v 2>/dev/null || true", egressChain)
+
+// egressProbeCmd succeeds if the node can connect to a public endpoint
+const egressProbeCmd = "timeout 10 bash -c '</dev/tcp/8.8.8.8/53'"
@@ -0,0 +1,42 @@
+package gravity
+
+import (
+	"context"
+	"time"
+
+	"github.com/gravitational/robotest/lib/grafana"
+
+	"github.com/sirupsen/logrus"
+)
+
+var annotations *grafana.Client
+
+// SetAnnotations configures the Grafana to annotate the events of the tests in:
+// the phase changes of the tests, the state changes of the gravity operations
+// and the faults injected by the chaos scheduler
+func SetAnnotations(client *grafana.Client) {
+	annotations = client
+}
+
+// annotate creates the annotation tagged with robotest and the name of the test,
+// if annotations are configured. Failures are logged but do not fail the test
+func (c *TestContext) annotate(annotation grafana.Annotation) {
+	annotateTest(c.ctx, c.Logger(), c.name, annotation)
+}
+
+// annotateTest creates the annotation tagged with robotest and the name of the test given with name,
+// if annotations are configured. Failures are logged
+func annotateTest(ctx context.Context, log logrus.FieldLogger, name string, annotation grafana.Annotation) {
+	if annotations == nil {
+		return
+	}
+	annotation.Tags = append([]string{"robotest", name}, annotation.Tags...)
+	ctx, cancel := context.WithTimeout(ctx, annotateTimeout)
+	defer cancel()
+	if err := annotations.Annotate(ctx, annotation); err != nil {
+		log.WithError(err).Warn("Failed to annotate in Grafana.")
+	}
+}
+
+// annotateTimeout is the time allotted to create an annotation
+const annotateTimeout = 10 * time.Second
@@ -17,8 +17,8 @@ import (
 func (c *TestContext) AutoScale(target int) ([]Gravity, error) {
 	c.Logger().Debug("attempting to connect to AWS api")
 	sess, err := session.NewSession(&aws.Config{
-		Region:      aws.String(c.provisionerCfg.Ops.EC2Region),
-		Credentials: credentials.NewStaticCredentials(c.provisionerCfg.Ops.EC2AccessKey, c.provisionerCfg.Ops.EC2SecretKey, ""),
+		Region:      aws.String(c.provisionerConfig().Ops.EC2Region),
+		Credentials: credentials.NewStaticCredentials(c.provisionerConfig().Ops.EC2AccessKey, c.provisionerConfig().Ops.EC2SecretKey, ""),
 	})
 	if err != nil {
 		return nil, trace.Wrap(err)
@@ -28,7 +28,7 @@ func (c *TestContext) AutoScale(target int) ([]Gravity, error) {
 
 	// first, let's set the desired capacity
 	setCapacity := &autoscaling.SetDesiredCapacityInput{
-		AutoScalingGroupName: aws.String(c.provisionerCfg.clusterName),
+		AutoScalingGroupName: aws.String(c.provisionerConfig().clusterName),
 		DesiredCapacity:      aws.Int64(int64(target)),
 		HonorCooldown:        aws.Bool(false),
 	}
@@ -41,7 +41,7 @@ func (c *TestContext) AutoScale(target int) ([]Gravity, error) {
 	// next we need to find all the instances that were just created, and build objects and ssh connections to them
 	describeASG := &autoscaling.DescribeAutoScalingGroupsInput{
 		AutoScalingGroupNames: []*string{
-			aws.String(c.provisionerCfg.clusterName),
+			aws.String(c.provisionerConfig().clusterName),
 		},
 	}
 
@@ -98,7 +98,7 @@ func checkForNodeAssignment(svc *autoscaling.AutoScaling, describeASG *autoscali
 
 // getAWSNodes will connect to the AWS API, and get a listing of nodes matching the specified filter.
 func (c *TestContext) getAWSNodes(ec2svc *ec2.EC2, filterName string, filterValue string) (nodes []*gravity, err error) {
-	cloudParams, err := makeDynamicParams(c.provisionerCfg)
+	cloudParams, err := makeDynamicParams(c.provisionerConfig())
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
@@ -120,7 +120,7 @@ func (c *TestContext) getAWSNodes(ec2svc *ec2.EC2, filterName string, filterValu
 	for _, reservation := range resp.Reservations {
 		for _, inst := range reservation.Instances {
 			node := ops.New(*inst.PublicIpAddress, *inst.PrivateIpAddress,
-				c.provisionerCfg.Ops.SSHUser, c.provisionerCfg.Ops.SSHKeyPath)
+				c.provisionerConfig().Ops.SSHUser, c.provisionerConfig().Ops.SSHKeyPath)
 
 			gravityNode, err := connectVM(c.Context(), c.Logger(), node, *cloudParams)
 			if err != nil {
@@ -0,0 +1,235 @@
+package gravity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/gravitational/robotest/lib/constants"
+	"github.com/gravitational/robotest/lib/shell"
+	sshutils "github.com/gravitational/robotest/lib/ssh"
+
+	"github.com/gravitational/trace"
+)
+
+// BenchmarkSample records the durations of a single run of a benchmarked operation
+type BenchmarkSample struct {
+	// Total is the duration of the whole operation
+	Total time.Duration `json:"total"`
+	// Phases maps the top-level phases of the operation plan to their durations
+	Phases map[string]time.Duration `json:"phases"`
+}
+
+// BenchmarkStats summarizes the durations of a benchmarked operation or phase over the runs
+type BenchmarkStats struct {
+	// Runs is the number of samples
+	Runs int `json:"runs"`
+	// Mean is the mean duration
+	Mean time.Duration `json:"mean"`
+	// Median is the median duration
+	Median time.Duration `json:"median"`
+	// Min is the shortest duration
+	Min time.Duration `json:"min"`
+	// Max is the longest duration
+	Max time.Duration `json:"max"`
+	// StdDev is the standard deviation of the durations
+	StdDev time.Duration `json:"stddev"`
+}
+
+// BenchmarkReport is the statistical summary of the runs of a benchmarked operation
+type BenchmarkReport struct {
+	// Operation names the benchmarked operation, i.e. install or upgrade
+	Operation string `json:"operation"`
+	// Total summarizes the durations of the whole operation
+	Total BenchmarkStats `json:"total"`
+	// Phases summarizes the durations of the top-level plan phases
+	Phases map[string]BenchmarkStats `json:"phases"`
+	// Samples lists the individual runs
+	Samples []BenchmarkSample `json:"samples"`
+}
+
+// BenchmarkRegression describes an operation or phase that has become slower than the baseline
+type BenchmarkRegression struct {
+	// Phase names the phase, or is empty for the whole operation
+	Phase string
+	// Baseline is the median duration of the baseline
+	Baseline time.Duration
+	// Median is the median duration of the benchmark
+	Median time.Duration
+}
+
+// String describes the regression
+func (r BenchmarkRegression) String() string {
+	name := r.Phase
+	if name == "" {
+		name = "total"
+	}
+	return fmt.Sprintf("%v: %v -> %v (+%.0f%%)", name, r.Baseline, r.Median,
+		(float64(r.Median)/float64(r.Baseline)-1)*100)
+}
+
+// NewBenchmarkReport summarizes the samples of the benchmarked operation
+func NewBenchmarkReport(operation string, samples []BenchmarkSample) BenchmarkReport {
+	report := BenchmarkReport{
+		Operation: operation,
+		Phases:    make(map[string]BenchmarkStats),
+		Samples:   samples,
+	}
+	var totals []time.Duration
+	phases := make(map[string][]time.Duration)
+	for _, sample := range samples {
+		totals = append(totals, sample.Total)
+		for phase, d := range sample.Phases {
+			phases[phase] = append(phases[phase], d)
+		}
+	}
+	report.Total = NewBenchmarkStats(totals)
+	for phase, durations := range phases {
+		report.Phases[phase] = NewBenchmarkStats(durations)
+	}
+	return report
+}
+
+// NewBenchmarkStats computes the statistics of the given durations
+func NewBenchmarkStats(durations []time.Duration) (stats BenchmarkStats) {
+	if len(durations) == 0 {
+		return stats
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	stats.Runs = len(sorted)
+	stats.Min, stats.Max = sorted[0], sorted[len(sorted)-1]
+	if len(sorted)%2 == 1 {
+		stats.Median = sorted[len(sorted)/2]
+	} else {
+		stats.Median = (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+	}
+	var sum float64
+	for _, d := range sorted {
+		sum += float64(d)
+	}
+	mean := sum / float64(len(sorted))
+	var variance float64
+	for _, d := range sorted {
+		variance += (float64(d) - mean) * (float64(d) - mean)
+	}
+	stats.Mean = time.Duration(mean)
+	stats.StdDev = time.Duration(math.Sqrt(variance / float64(len(sorted))))
+	return stats
+}
+
+// CompareBenchmark returns the operation and phases of the report with the median duration
+// exceeding the median of the baseline by more than maxRegression, i.e. 0.2 for 20%.
+// Differences below benchmarkMinRegression are ignored as noise.
+// Phases missing from the baseline are not compared
+func CompareBenchmark(baseline, report BenchmarkReport, maxRegression float64) (regressions []BenchmarkRegression) {
+	regressed := func(baseline, stats BenchmarkStats) bool {
+		return baseline.Runs != 0 && stats.Runs != 0 &&
+			stats.Median-baseline.Median > benchmarkMinRegression &&
+			float64(stats.Median) > float64(baseline.Median)*(1+maxRegression)
+	}
+	if regressed(baseline.Total, report.Total) {
+		regressions = append(regressions, BenchmarkRegression{
+			Baseline: baseline.Total.Median,
+			Median:   report.Total.Median,
+		})
+	}
+	var phases []string
+	for phase := range report.Phases {
+		phases = append(phases, phase)
+	}
+	sort.Strings(phases)
+	for _, phase := range phases {
+		if regressed(baseline.Phases[phase], report.Phases[phase]) {
+			regressions = append(regressions, BenchmarkRegression{
+				Phase:    phase,
+				Baseline: baseline.Phases[phase].Median,
+				Median:   report.Phases[phase].Median,
+			})
+		}
+	}
+	return regressions
+}
+
+// LoadBenchmarkReport reads the benchmark report from the JSON file at path
+func LoadBenchmarkReport(path string) (*BenchmarkReport, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, trace.ConvertSystemError(err)
+	}
+	var report BenchmarkReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, trace.BadParameter("invalid benchmark report %v: %v", path, err)
+	}
+	return &report, nil
+}
+
+// Save writes this report as JSON to the file at path
+func (r BenchmarkReport) Save(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), constants.SharedDirMask); err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	return trace.ConvertSystemError(ioutil.WriteFile(path, data, constants.SharedReadMask))
+}
+
+// PhaseDurations returns the durations of the top-level phases of the last
+// operation plan on the node, for an operation started at start
+func (c *TestContext) PhaseDurations(node Gravity, start time.Time) (map[string]time.Duration, error) {
+	g, ok := node.(*gravity)
+	if !ok {
+		return nil, trace.BadParameter("unsupported node %v", node)
+	}
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	var out string
+	cmd := shell.InDir(g.installDir, shell.Sudo("./gravity", "plan", "--output=json"))
+	err := g.runAndParse(ctx, g.Logger(), cmd, nil, sshutils.ParseAsString(&out))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return phaseDurations([]byte(out), start)
+}
+
+// phaseDurations returns the durations of the top-level phases of the operation plan.
+// The plan only records the time each phase was last updated, so the top-level phases,
+// which run sequentially, are timed from the update of the preceding phase,
+// and the first phase from start
+func phaseDurations(plan []byte, start time.Time) (map[string]time.Duration, error) {
+	var p planPhase
+	if err := json.Unmarshal(plan, &p); err != nil {
+		return nil, trace.Wrap(err, "failed to parse operation plan")
+	}
+	phases := append([]planPhase(nil), p.Phases...)
+	sort.SliceStable(phases, func(i, j int) bool { return phases[i].Updated.Before(phases[j].Updated) })
+	durations := make(map[string]time.Duration, len(phases))
+	prev := start
+	for _, phase := range phases {
+		if phase.State != phaseStateCompleted {
+			return nil, trace.CompareFailed("phase %v is %v", phase.ID, phase.State)
+		}
+		// the clocks of the node and robotest may differ
+		if d := phase.Updated.Sub(prev); d > 0 {
+			durations[phase.ID] = d
+		} else {
+			durations[phase.ID] = 0
+		}
+		prev = phase.Updated
+	}
+	return durations, nil
+}
+
+// benchmarkMinRegression is the difference of the median durations below which
+// a slower operation or phase is not considered a regression
+const benchmarkMinRegression = 30 * time.Second
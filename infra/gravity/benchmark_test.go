@@ -0,0 +1,48 @@
+package gravity
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBenchmarkStats(t *testing.T) {
+	stats := NewBenchmarkStats([]time.Duration{4 * time.Minute, 2 * time.Minute, 6 * time.Minute, 4 * time.Minute})
+	assert.Equal(t, 4, stats.Runs)
+	assert.Equal(t, 4*time.Minute, stats.Mean)
+	assert.Equal(t, 4*time.Minute, stats.Median)
+	assert.Equal(t, 2*time.Minute, stats.Min)
+	assert.Equal(t, 6*time.Minute, stats.Max)
+	assert.InDelta(t, float64(time.Minute)*math.Sqrt2, float64(stats.StdDev), float64(time.Millisecond))
+}
+
+func TestComparesBenchmarkWithBaseline(t *testing.T) {
+	baseline := NewBenchmarkReport("install", []BenchmarkSample{
+		{Total: 20 * time.Minute, Phases: map[string]time.Duration{"/init": 2 * time.Minute, "/masters": 10 * time.Minute, "/wait": 10 * time.Second}},
+	})
+	report := NewBenchmarkReport("install", []BenchmarkSample{
+		{Total: 22 * time.Minute, Phases: map[string]time.Duration{"/init": 2 * time.Minute, "/masters": 15 * time.Minute, "/wait": 30 * time.Second, "/new": time.Hour}},
+	})
+	regressions := CompareBenchmark(baseline, report, 0.2)
+	require.Len(t, regressions, 1)
+	assert.Equal(t, "/masters", regressions[0].Phase)
+	assert.Equal(t, "/masters: 10m0s -> 15m0s (+50%)", regressions[0].String())
+}
+
+func TestPhaseDurations(t *testing.T) {
+	plan := []byte(`{"id":"/","phases":[
+{"id":"/masters","state":"completed","updated":"2020-01-01T10:15:00Z"},
+{"id":"/init","state":"completed","updated":"2020-01-01T10:05:00Z"},
+{"id":"/wait","state":"completed","updated":"2020-01-01T10:16:30Z"}]}`)
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	durations, err := phaseDurations(plan, start)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]time.Duration{
+		"/init":    5 * time.Minute,
+		"/masters": 10 * time.Minute,
+		"/wait":    90 * time.Second,
+	}, durations)
+}
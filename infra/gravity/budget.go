@@ -0,0 +1,94 @@
+package gravity
+
+import (
+	"time"
+
+	"github.com/gravitational/trace"
+)
+
+// SetBudget limits the wall-clock time of Run. Once the budget is exceeded, the test suite
+// is cancelled: the tests do not start new phases and the running tests are interrupted,
+// their logs are collected and their infrastructure is destroyed regardless of the interrupt policy.
+// The interrupted tests are reported as timed out at the phase they were in
+func (s *testSuite) SetBudget(budget time.Duration) {
+	s.Lock()
+	defer s.Unlock()
+	s.budget = budget
+}
+
+// startBudget starts the timer of the test suite budget, if any.
+// Returns the function to stop the timer
+func (s *testSuite) startBudget() (stop func()) {
+	s.RLock()
+	budget := s.budget
+	s.RUnlock()
+	if budget <= 0 {
+		return func() {}
+	}
+	timer := time.AfterFunc(budget, func() {
+		s.exceedBudget(budget)
+	})
+	return func() {
+		timer.Stop()
+	}
+}
+
+// exceedBudget records the phase of each running test and cancels the test suite
+func (s *testSuite) exceedBudget(budget time.Duration) {
+	s.RLock()
+	tests := append([]*TestContext(nil), s.tests...)
+	s.RUnlock()
+	for _, test := range tests {
+		test.timeOut()
+	}
+
+	s.Lock()
+	s.isOverBudget = true
+	s.Unlock()
+	s.Cancel("Test suite exceeded the budget of %v.", budget)
+}
+
+// overBudget returns true if the test suite exceeded its budget
+func (s *testSuite) overBudget() bool {
+	if s == nil {
+		return false
+	}
+	s.RLock()
+	defer s.RUnlock()
+	return s.isOverBudget
+}
+
+// timeOut records the phase of the running test as timed out.
+// A test already tearing down has completed and is not timed out
+func (c *TestContext) timeOut() {
+	c.nodesMu.Lock()
+	defer c.nodesMu.Unlock()
+	// the phase is reset once the test has completed
+	if c.phase != "" && c.phase != phaseTeardown {
+		c.timedOutPhase = c.phase
+	}
+}
+
+// timedOut returns the phase the test timed out at, if the test is interrupted
+// due to the test suite budget
+func (c *TestContext) timedOut() string {
+	c.nodesMu.Lock()
+	defer c.nodesMu.Unlock()
+	return c.timedOutPhase
+}
+
+// checkBudget aborts the test instead of starting the phase once the test suite budget is exceeded.
+// The teardown is always started
+func (c *TestContext) checkBudget(phase string) {
+	if phase == "" || phase == phaseTeardown || !c.suite.overBudget() {
+		return
+	}
+	c.log.WithField("phase", phase).Warn("Test suite budget exceeded, not starting phase.")
+	c.nodesMu.Lock()
+	if c.timedOutPhase == "" {
+		c.timedOutPhase = phase
+	}
+	c.nodesMu.Unlock()
+	c.err = trace.LimitExceeded("test suite budget exceeded, phase %v not started", phase)
+	panic(c.err.Error())
+}
@@ -0,0 +1,54 @@
+package gravity
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExceedBudget(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	suite := &testSuite{ctx: ctx, cancel: cancel, logger: logrus.New()}
+	c := NewTestContext(ctx, DefaultTimeouts, logrus.New())
+	c.suite = suite
+	done := NewTestContext(ctx, DefaultTimeouts, logrus.New())
+	done.suite = suite
+	suite.tests = []*TestContext{c, done}
+
+	c.SetPhase("upgrade")
+	done.SetPhase(phaseTeardown)
+	suite.exceedBudget(time.Hour)
+	assert.Equal(t, "upgrade", c.timedOut())
+	assert.Empty(t, done.timedOut(), "timed out in teardown")
+	assert.Error(t, suite.ctx.Err())
+	assert.True(t, suite.failingFast())
+
+	assert.Panics(t, func() { c.SetPhase("status") })
+	assert.True(t, trace.IsLimitExceeded(c.Error()))
+	assert.Equal(t, "upgrade", c.timedOut())
+	assert.NotPanics(t, func() { c.SetPhase(phaseTeardown) })
+}
+
+func TestNestedPhases(t *testing.T) {
+	c := NewTestContext(context.Background(), DefaultTimeouts, logrus.New())
+	c.SetPhase("replace_node")
+
+	exitReplace := c.enterPhase("replace")
+	exitExpand := c.enterPhase("expand")
+	assert.Equal(t, "expand", c.logFields()["phase"])
+	exitExpand()
+	assert.Equal(t, "replace", c.logFields()["phase"], "outer phase restored")
+	exitReplace()
+	assert.Equal(t, "replace_node", c.logFields()["phase"])
+
+	var phases []string
+	for _, phase := range c.recordedPhases() {
+		phases = append(phases, phase.Phase)
+	}
+	assert.Equal(t, []string{"replace_node", "replace", "expand", "replace"}, phases)
+}
@@ -0,0 +1,170 @@
+package gravity
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gravitational/robotest/lib/shell"
+	sshutils "github.com/gravitational/robotest/lib/ssh"
+	"github.com/gravitational/robotest/lib/utils"
+
+	"github.com/gravitational/trace"
+)
+
+// CaptureFilter selects the traffic to capture
+type CaptureFilter struct {
+	// Ports optionally limits the capture to the specified TCP/UDP ports,
+	// i.e. serf (7496) and etcd (2379, 2380)
+	Ports []int `json:"ports,omitempty"`
+	// Peers optionally limits the capture to the traffic with the specified hosts
+	Peers []string `json:"peers,omitempty"`
+	// FileSizeMB is the size of the capture file to rotate at. Defaults to 100
+	FileSizeMB int `json:"file_size_mb,omitempty"`
+	// Files is the number of capture files to keep per node. Defaults to 5
+	Files int `json:"files,omitempty"`
+}
+
+// PacketCapture is a packet capture running on a set of nodes
+type PacketCapture struct {
+	name  string
+	nodes []Gravity
+}
+
+// StartCapture starts a rotating packet capture (tcpdump) with the given name on the nodes.
+// Use StopCapture to stop the capture and fetch the capture files
+func (c *TestContext) StartCapture(nodes []Gravity, name string, filter CaptureFilter) (*PacketCapture, error) {
+	targets, err := gravityNodes(nodes)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	c.Logger().WithField("filter", filter.expr()).Infof("Start packet capture %v.", name)
+	cmd := startCaptureCmd(name, filter)
+	errs := make(chan error, len(targets))
+	for _, node := range targets {
+		go func(node *gravity) {
+			errs <- trace.Wrap(node.startCapture(ctx, cmd), "failed to start capture on %v", node)
+		}(node)
+	}
+	if err := utils.CollectErrors(ctx, errs); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &PacketCapture{name: name, nodes: nodes}, nil
+}
+
+// StopCapture stops the packet capture and fetches the capture files into
+// captures/<name>/<node IP>.tgz in the state directory.
+// Nodes that are offline are skipped
+func (c *TestContext) StopCapture(capture *PacketCapture) error {
+	targets, err := gravityNodes(capture.nodes)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.CollectLogs)
+	defer cancel()
+
+	c.Logger().Infof("Stop packet capture %v.", capture.name)
+	errs := make(chan error, len(targets))
+	for _, node := range targets {
+		go func(node *gravity) {
+			if node.Offline() {
+				node.Logger().Warn("Node is offline, skip fetching the capture.")
+				errs <- nil
+				return
+			}
+			errs <- trace.Wrap(node.stopCapture(ctx, capture.name), "failed to fetch capture from %v", node)
+		}(node)
+	}
+	return trace.Wrap(utils.CollectErrors(ctx, errs))
+}
+
+func (g *gravity) startCapture(ctx context.Context, cmd string) error {
+	var install string
+	switch vendor := g.param.os.Vendor; {
+	case isRedHatFamily(vendor):
+		install = "sudo yum install -y tcpdump"
+	default:
+		install = "sudo apt-get update && sudo DEBIAN_FRONTEND=noninteractive apt-get install -y tcpdump"
+	}
+	err := g.run(ctx, g.Logger(), fmt.Sprintf("which tcpdump || (%v)", install), nil)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(g.run(ctx, g.Logger(), cmd, nil))
+}
+
+func (g *gravity) stopCapture(ctx context.Context, name string) error {
+	stop := shell.Sudo("pkill", "-INT", "-f", capturePattern(name)).String() + "; sleep 1"
+	err := g.run(ctx, g.Logger(), stop, nil)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	localPath := filepath.Join(g.param.StateDir, "captures", name, fmt.Sprintf("%v.tgz", g.Node().PrivateAddr()))
+	return trace.Wrap(sshutils.PipeCommand(ctx, g.Client(), g.Logger(), fetchCaptureCmd(name), localPath))
+}
+
+// startCaptureCmd returns the command to start the capture in background
+func startCaptureCmd(name string, filter CaptureFilter) string {
+	fileSize, files := filter.FileSizeMB, filter.Files
+	if fileSize == 0 {
+		fileSize = 100
+	}
+	if files == 0 {
+		files = 5
+	}
+	cmd := shell.Sudo("nohup", "tcpdump", "-i", "any", "-n", "-Z", "root",
+		"-C", strconv.Itoa(fileSize), "-W", strconv.Itoa(files), "-w", capturePath(name))
+	if expr := filter.expr(); expr != "" {
+		cmd.Args(expr)
+	}
+	return fmt.Sprintf("%v > /dev/null 2>&1 &", cmd)
+}
+
+// fetchCaptureCmd returns the command to archive the capture files to stdout.
+// With rotation, tcpdump appends the file number to the capture path
+// (robotest-<name>.pcap0 .. robotest-<name>.pcap<files-1>), so the files are looked up
+// by root instead of relying on the glob expansion of the login shell
+func fetchCaptureCmd(name string) string {
+	find := shell.Sudo("find", ".", "-maxdepth", "1", "-name", filepath.Base(capturePath(name))+"*", "-print0")
+	return fmt.Sprintf("%v | %v", shell.InDir(captureDir, find), shell.Sudo("tar", "-cz", "-C", captureDir, "--null", "-T", "-"))
+}
+
+// expr returns the pcap filter expression for the filter
+func (r CaptureFilter) expr() string {
+	var terms []string
+	if len(r.Ports) != 0 {
+		var ports []string
+		for _, port := range r.Ports {
+			ports = append(ports, fmt.Sprintf("port %v", port))
+		}
+		terms = append(terms, fmt.Sprintf("(%v)", strings.Join(ports, " or ")))
+	}
+	if len(r.Peers) != 0 {
+		var peers []string
+		for _, peer := range r.Peers {
+			peers = append(peers, fmt.Sprintf("host %v", peer))
+		}
+		terms = append(terms, fmt.Sprintf("(%v)", strings.Join(peers, " or ")))
+	}
+	return strings.Join(terms, " and ")
+}
+
+func capturePath(name string) string {
+	return filepath.Join(captureDir, fmt.Sprintf("robotest-%v.pcap", name))
+}
+
+// capturePattern returns the pkill pattern of the tcpdump process writing the capture.
+// The bracket keeps the pattern from matching the sudo and the shell running pkill itself,
+// which would otherwise be killed before tcpdump flushes the capture
+func capturePattern(name string) string {
+	return "[t]cpdump .*-w " + regexp.QuoteMeta(capturePath(name))
+}
+
+// captureDir is the directory on the node the capture files are written to
+const captureDir = "/var/tmp"
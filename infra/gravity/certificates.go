@@ -0,0 +1,194 @@
+package gravity
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gravitational/robotest/lib/utils"
+	"github.com/gravitational/robotest/lib/wait"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// RotateCertificates reissues the cluster certificates on the nodes one node at a time
+// and restarts planet to pick them up.
+// With validity, the certificates are issued to expire after the given duration,
+// i.e. to shorten their validity ahead of ExpireCertificates.
+// Otherwise, gravity issues the certificates with the default validity
+func (c *TestContext) RotateCertificates(nodes []Gravity, validity time.Duration) error {
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	status, err := c.clusterStatus(ctx, nodes)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	for _, node := range nodes {
+		g, ok := node.(*gravity)
+		if !ok {
+			return trace.BadParameter("unsupported node %v", node)
+		}
+		c.Logger().WithFields(logrus.Fields{"node": node, "validity": validity}).Info("Rotate certificates.")
+		if err := rotateCertificates(ctx, g, status.Cluster.Cluster, validity); err != nil {
+			return trace.Wrap(err, "failed to rotate certificates on %v", node)
+		}
+	}
+	return nil
+}
+
+// ExpireCertificates moves the clocks on all nodes past the expiration of the
+// earliest expiring cluster certificate across a reboot (see TimeJump)
+// and returns the offset the clocks have been moved by.
+// The cluster is not verified afterwards as it is expected to be failing
+func (c *TestContext) ExpireCertificates(nodes []Gravity) (offset time.Duration, err error) {
+	targets, err := gravityNodes(nodes)
+	if err != nil {
+		return 0, trace.Wrap(err)
+	}
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Power)
+	defer cancel()
+
+	for _, g := range targets {
+		now, err := nodeTime(ctx, g)
+		if err != nil {
+			return 0, trace.Wrap(err)
+		}
+		certs, err := certificateInfo(ctx, g)
+		if err != nil {
+			return 0, trace.Wrap(err)
+		}
+		for _, cert := range certs {
+			if d := cert.notAfter.Sub(now) + certificateExpiryMargin; offset == 0 || d < offset {
+				offset = d
+			}
+		}
+	}
+	if offset <= 0 {
+		return 0, trace.BadParameter("certificates have already expired")
+	}
+
+	c.Logger().WithFields(logrus.Fields{"nodes": Nodes(nodes), "offset": offset}).Info("Jump clocks past certificate expiration.")
+	errs := make(chan error, len(targets))
+	for _, node := range targets {
+		go func(node *gravity) {
+			errs <- trace.Wrap(jumpClock(ctx, node, offset), "failed to jump clock on %v", node)
+		}(node)
+	}
+	return offset, trace.Wrap(utils.CollectErrors(ctx, errs))
+}
+
+// WaitForFailedProbe waits until the status on any of the nodes reports
+// a failed health probe matching pattern,
+// i.e. to verify that gravity detects expired certificates
+func (c *TestContext) WaitForFailedProbe(nodes []Gravity, pattern *regexp.Regexp) error {
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	retry := wait.Retryer{
+		Attempts:    60,
+		Delay:       10 * time.Second,
+		FieldLogger: c.Logger().WithField("probe", pattern),
+	}
+	return trace.Wrap(retry.Do(ctx, func() error {
+		probes, err := c.failedProbes(ctx, nodes)
+		if err != nil {
+			return wait.Continue(err.Error())
+		}
+		for _, probe := range probes {
+			if pattern.MatchString(probe) {
+				c.Logger().WithField("probe", probe).Info("Failed probe reported.")
+				return nil
+			}
+		}
+		return wait.Continue("no failed probe matching %v in %v", pattern, probes)
+	}))
+}
+
+// AssertNoFailedProbe verifies that the status on none of the nodes
+// reports a failed health probe matching pattern
+func (c *TestContext) AssertNoFailedProbe(nodes []Gravity, pattern *regexp.Regexp) error {
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	probes, err := c.failedProbes(ctx, nodes)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	var matched []string
+	for _, probe := range probes {
+		if pattern.MatchString(probe) {
+			matched = append(matched, probe)
+		}
+	}
+	if len(matched) != 0 {
+		return trace.CompareFailed("failed probes matching %v: %v", pattern, strings.Join(matched, "; "))
+	}
+	return nil
+}
+
+// failedProbes returns the failed health probes on all cluster nodes
+// from the status queried on the first responding node.
+// Unlike Status, degraded status is reported
+func (c *TestContext) failedProbes(ctx context.Context, nodes []Gravity) (probes []string, err error) {
+	status, err := c.clusterStatus(ctx, nodes)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	for _, node := range status.Cluster.Nodes {
+		for _, probe := range node.FailedProbes {
+			probes = append(probes, node.Addr+": "+probe)
+		}
+	}
+	return probes, nil
+}
+
+// clusterStatus returns the raw cluster status from the first node that reports it
+func (c *TestContext) clusterStatus(ctx context.Context, nodes []Gravity) (*GravityStatus, error) {
+	var errors []error
+	for _, node := range nodes {
+		g, ok := node.(*gravity)
+		if !ok {
+			return nil, trace.BadParameter("unsupported node %v", node)
+		}
+		status, err := g.status(ctx)
+		if err == nil {
+			return status, nil
+		}
+		errors = append(errors, err)
+	}
+	return nil, trace.NewAggregate(errors...)
+}
+
+// rotateCertificates reissues the certificates on the node and restarts planet
+func rotateCertificates(ctx context.Context, node *gravity, cluster string, validity time.Duration) error {
+	var validityFlag string
+	if validity != 0 {
+		validityFlag = validity.String()
+	}
+	cmd, err := node.renderCommand(ctx, rotateCertsCommand, struct {
+		commandParams
+		Cluster  string
+		Validity string
+	}{
+		commandParams: node.commandParams(),
+		Cluster:       cluster,
+		Validity:      validityFlag,
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	log := node.Logger()
+	if err := node.run(ctx, log, strings.TrimSpace(cmd), nil); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(node.run(ctx, log, restartPlanetCommand, nil))
+}
+
+// restartPlanetCommand restarts the planet service unit
+const restartPlanetCommand = `sudo systemctl restart $(systemctl list-units --plain --no-legend 'gravity__*planet*' | awk '{print $1}')`
+
+// certificateExpiryMargin is the time the clocks are moved past the certificate expiration by
+const certificateExpiryMargin = time.Hour
@@ -0,0 +1,298 @@
+package gravity
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/gravitational/robotest/lib/grafana"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// ChaosFault names a fault the chaos scheduler injects
+type ChaosFault string
+
+const (
+	// ChaosReboot forcibly reboots a node
+	ChaosReboot ChaosFault = "reboot"
+	// ChaosPartition partitions a node from the other nodes on the network for a while
+	ChaosPartition ChaosFault = "partition"
+	// ChaosKillService kills a cluster service inside planet on a node
+	ChaosKillService ChaosFault = "kill_service"
+	// ChaosSlowDisk throttles the disk I/O on a node for a while, see ThrottleIO
+	ChaosSlowDisk ChaosFault = "slow_disk"
+)
+
+// ChaosConfig configures the chaos scheduler.
+// The schedule is derived from the seed only, so a failing run is reproduced
+// by running the same test with the seed recorded in its report
+type ChaosConfig struct {
+	// Seed seeds the schedule. If zero, a seed is picked at random
+	Seed int64
+	// Duration is the time to inject faults for
+	Duration time.Duration
+	// MinInterval and MaxInterval bound the time between faults.
+	// For faults that last (partition, slow_disk), the interval starts once the fault has ended
+	MinInterval, MaxInterval time.Duration
+	// Faults lists the faults to inject. Defaults to reboot, partition and kill_service
+	Faults []ChaosFault
+	// Services lists the planet services to kill. Defaults to the Kubernetes and etcd services
+	Services []string
+	// MaxPartition bounds how long a node is partitioned or its disk throttled.
+	// Defaults to the minimum interval
+	MaxPartition time.Duration
+	// SlowDisk is the I/O throttle of slow_disk. Defaults to DefaultSlowDisk
+	SlowDisk IOThrottle
+}
+
+// ChaosEvent is a fault scheduled by the chaos scheduler
+type ChaosEvent struct {
+	// At is the time of the fault since the start of the schedule
+	At time.Duration `json:"at"`
+	// Fault names the fault
+	Fault ChaosFault `json:"fault"`
+	// Node is the index of the target node
+	Node int `json:"node"`
+	// Service names the service to kill with kill_service
+	Service string `json:"service,omitempty"`
+	// Duration is how long the node is partitioned with partition, or throttled with slow_disk
+	Duration time.Duration `json:"duration,omitempty"`
+	// Addr is the private address of the target node once the fault has been injected
+	Addr string `json:"addr,omitempty"`
+	// Error describes the failure to inject the fault, if any
+	Error string `json:"error,omitempty"`
+}
+
+// annotation returns the text of the annotation of the injected fault
+func (r ChaosEvent) annotation() string {
+	text := fmt.Sprintf("%v on %v", r.Fault, r.Addr)
+	if r.Service != "" {
+		text = fmt.Sprintf("%v %v on %v", r.Fault, r.Service, r.Addr)
+	}
+	if r.Error != "" {
+		text = fmt.Sprintf("%v failed: %v", text, r.Error)
+	}
+	return text
+}
+
+// ChaosReport records the seed and the faults injected by the chaos scheduler
+type ChaosReport struct {
+	// Seed is the seed the schedule has been generated from
+	Seed int64 `json:"seed"`
+	// Events lists the injected faults in order
+	Events []ChaosEvent `json:"events"`
+}
+
+// NewChaosSchedule returns the schedule of faults for count nodes generated from config.
+// The same configuration and node count always yield the same schedule
+func NewChaosSchedule(config ChaosConfig, count int) ([]ChaosEvent, error) {
+	config = config.withDefaults()
+	if count == 0 {
+		return nil, trace.BadParameter("no nodes to inject faults into")
+	}
+	if config.MinInterval <= 0 || config.MaxInterval < config.MinInterval {
+		return nil, trace.BadParameter("invalid fault interval [%v, %v]", config.MinInterval, config.MaxInterval)
+	}
+	for _, fault := range config.Faults {
+		switch fault {
+		case ChaosReboot, ChaosPartition, ChaosKillService, ChaosSlowDisk:
+		default:
+			return nil, trace.BadParameter("unknown fault %q, expected one of %v, %v, %v or %v",
+				fault, ChaosReboot, ChaosPartition, ChaosKillService, ChaosSlowDisk)
+		}
+	}
+	rnd := rand.New(rand.NewSource(config.Seed))
+	var events []ChaosEvent
+	at := randomDuration(rnd, config.MinInterval, config.MaxInterval)
+	for at < config.Duration {
+		event := ChaosEvent{
+			At:    at,
+			Fault: config.Faults[rnd.Intn(len(config.Faults))],
+			Node:  rnd.Intn(count),
+		}
+		switch event.Fault {
+		case ChaosKillService:
+			event.Service = config.Services[rnd.Intn(len(config.Services))]
+		case ChaosPartition, ChaosSlowDisk:
+			event.Duration = randomDuration(rnd, config.MaxPartition/2, config.MaxPartition)
+		}
+		events = append(events, event)
+		// the next fault is only injected once this one has ended
+		at += event.Duration + randomDuration(rnd, config.MinInterval, config.MaxInterval)
+	}
+	return events, nil
+}
+
+// Chaos is a running chaos scheduler, see StartChaos
+type Chaos struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu     sync.Mutex
+	report ChaosReport
+}
+
+// StartChaos starts injecting faults into the nodes in the background
+// on the schedule generated from config, see NewChaosSchedule.
+// The faults are injected one at a time. The seed and the injected faults are
+// recorded in the test report
+func (c *TestContext) StartChaos(nodes []Gravity, config ChaosConfig) (*Chaos, error) {
+	if config.Seed == 0 {
+		config.Seed = time.Now().UnixNano()
+	}
+	events, err := NewChaosSchedule(config, len(nodes))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	config = config.withDefaults()
+	ctx, cancel := context.WithCancel(c.ctx)
+	chaos := &Chaos{
+		cancel: cancel,
+		done:   make(chan struct{}),
+		report: ChaosReport{Seed: config.Seed},
+	}
+	c.nodesMu.Lock()
+	c.chaos = chaos
+	c.nodesMu.Unlock()
+
+	c.Logger().WithFields(logrus.Fields{
+		"seed":   config.Seed,
+		"faults": len(events),
+	}).Info("Start chaos.")
+	go func() {
+		defer close(chaos.done)
+		start := time.Now()
+		for _, event := range events {
+			select {
+			case <-time.After(time.Until(start.Add(event.At))):
+			case <-ctx.Done():
+				return
+			}
+			event.Addr = nodes[event.Node].Node().PrivateAddr()
+			log := c.Logger().WithFields(logrus.Fields{"fault": event.Fault, "node": nodes[event.Node]})
+			log.Info("Inject fault.")
+			injected := time.Now()
+			if err := c.injectFault(ctx, nodes, event, config); err != nil {
+				log.WithError(err).Warn("Failed to inject fault.")
+				event.Error = err.Error()
+			}
+			chaos.record(event)
+			c.annotate(grafana.Annotation{
+				Time:    injected,
+				TimeEnd: time.Now(),
+				Tags:    []string{"fault", string(event.Fault)},
+				Text:    event.annotation(),
+			})
+		}
+	}()
+	return chaos, nil
+}
+
+// Stop stops injecting faults and returns the report of the injected faults.
+// A fault being injected is given time to complete, i.e. a partition is healed
+func (r *Chaos) Stop() ChaosReport {
+	r.cancel()
+	<-r.done
+	return r.Report()
+}
+
+// Report returns the report of the faults injected so far
+func (r *Chaos) Report() ChaosReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	report := r.report
+	report.Events = append([]ChaosEvent(nil), r.report.Events...)
+	return report
+}
+
+func (r *Chaos) record(event ChaosEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.report.Events = append(r.report.Events, event)
+}
+
+// chaosReport returns the report of the chaos scheduler started by this test, if any
+func (c *TestContext) chaosReport() *ChaosReport {
+	c.nodesMu.Lock()
+	chaos := c.chaos
+	c.nodesMu.Unlock()
+	if chaos == nil {
+		return nil
+	}
+	report := chaos.Report()
+	return &report
+}
+
+func (c *TestContext) injectFault(ctx context.Context, nodes []Gravity, event ChaosEvent, config ChaosConfig) error {
+	node := nodes[event.Node]
+	switch event.Fault {
+	case ChaosReboot:
+		ctx, cancel := context.WithTimeout(ctx, c.timeouts.Power)
+		defer cancel()
+		return trace.Wrap(node.Reboot(ctx, Graceful(false)))
+	case ChaosKillService:
+		ctx, cancel := context.WithTimeout(ctx, c.timeouts.Status)
+		defer cancel()
+		_, err := node.RunInPlanet(ctx, "/bin/systemctl", "kill", "--signal=SIGKILL", event.Service)
+		return trace.Wrap(err)
+	case ChaosPartition:
+		var peers []Gravity
+		for _, peer := range nodes {
+			if peer != node {
+				peers = append(peers, peer)
+			}
+		}
+		if err := c.Partition(node, peers); err != nil {
+			return trace.Wrap(err)
+		}
+		// heal even if the scheduler has been stopped
+		select {
+		case <-time.After(event.Duration):
+		case <-ctx.Done():
+		}
+		return trace.Wrap(c.Heal([]Gravity{node}))
+	case ChaosSlowDisk:
+		if err := c.ThrottleIO([]Gravity{node}, config.SlowDisk); err != nil {
+			return trace.Wrap(err)
+		}
+		// lift the throttle even if the scheduler has been stopped
+		select {
+		case <-time.After(event.Duration):
+		case <-ctx.Done():
+		}
+		return trace.Wrap(c.UnthrottleIO([]Gravity{node}, config.SlowDisk.Dir))
+	}
+	return trace.BadParameter("unknown fault %q", event.Fault)
+}
+
+func (r ChaosConfig) withDefaults() ChaosConfig {
+	if len(r.Faults) == 0 {
+		r.Faults = []ChaosFault{ChaosReboot, ChaosPartition, ChaosKillService}
+	}
+	if len(r.Services) == 0 {
+		r.Services = defaultChaosServices
+	}
+	if r.MaxPartition == 0 {
+		r.MaxPartition = r.MinInterval
+	}
+	if dir := r.SlowDisk.Dir; r.SlowDisk == (IOThrottle{Dir: dir}) {
+		r.SlowDisk = DefaultSlowDisk
+		r.SlowDisk.Dir = dir
+	}
+	return r
+}
+
+// randomDuration returns a random duration in [min, max]
+func randomDuration(rnd *rand.Rand, min, max time.Duration) time.Duration {
+	if max <= min {
+		return min
+	}
+	return min + time.Duration(rnd.Int63n(int64(max-min)+1))
+}
+
+// defaultChaosServices lists the planet services killed by default
+var defaultChaosServices = []string{"etcd", "kube-apiserver", "kube-controller-manager", "kube-scheduler", "kube-kubelet"}
@@ -0,0 +1,61 @@
+package gravity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChaosScheduleIsDeterministic(t *testing.T) {
+	config := ChaosConfig{
+		Seed:        42,
+		Duration:    time.Hour,
+		MinInterval: time.Minute,
+		MaxInterval: 5 * time.Minute,
+	}
+	events, err := NewChaosSchedule(config, 3)
+	require.NoError(t, err)
+	require.NotEmpty(t, events)
+	replay, err := NewChaosSchedule(config, 3)
+	require.NoError(t, err)
+	assert.Equal(t, events, replay)
+
+	for i, event := range events {
+		assert.True(t, event.At < config.Duration)
+		assert.True(t, event.Node >= 0 && event.Node < 3)
+		if i > 0 {
+			prev := events[i-1]
+			assert.True(t, event.At-(prev.At+prev.Duration) >= config.MinInterval, "faults do not overlap")
+		}
+	}
+
+	config.Seed = 43
+	other, err := NewChaosSchedule(config, 3)
+	require.NoError(t, err)
+	assert.NotEqual(t, events, other)
+
+	config.Faults = []ChaosFault{"unplug"}
+	_, err = NewChaosSchedule(config, 3)
+	assert.Error(t, err)
+}
+
+func TestChaosScheduleOffsetsLastingFaults(t *testing.T) {
+	config := ChaosConfig{
+		Seed:         42,
+		Duration:     time.Hour,
+		MinInterval:  time.Minute,
+		MaxInterval:  time.Minute,
+		Faults:       []ChaosFault{ChaosPartition},
+		MaxPartition: 10 * time.Minute,
+	}
+	events, err := NewChaosSchedule(config, 3)
+	require.NoError(t, err)
+	require.True(t, len(events) > 1)
+	for i := 1; i < len(events); i++ {
+		prev := events[i-1]
+		assert.True(t, prev.Duration >= config.MaxPartition/2)
+		assert.Equal(t, prev.At+prev.Duration+config.MinInterval, events[i].At)
+	}
+}
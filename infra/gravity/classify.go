@@ -0,0 +1,112 @@
+package gravity
+
+import (
+	"sort"
+)
+
+const (
+	// ClassPassed means the test passed on the first attempt
+	ClassPassed = "passed"
+	// ClassFlake means the test failed and then passed on a retry
+	ClassFlake = "flake"
+	// ClassFailure means the test failed on all attempts
+	ClassFailure = "failure"
+	// ClassInfrastructure means the test failed on all attempts due to
+	// infrastructure errors, i.e. node preemption or provisioning failures
+	ClassInfrastructure = "infrastructure"
+	// ClassCancelled means the test was interrupted by the test suite cancellation
+	ClassCancelled = "cancelled"
+	// ClassTimedOut means the test was interrupted as the test suite exceeded its budget
+	ClassTimedOut = "timed_out"
+	// ClassKnownIssue means the test failed on all attempts with a known failure
+	// tracked with a ticket, see SetKnownIssues. Known issues do not fail the suite
+	ClassKnownIssue = "known_issue"
+)
+
+// TestResult summarizes all attempts of a scheduled test
+type TestResult struct {
+	// Test names the scheduled test
+	Test string
+	// Class classifies the outcome of the attempts
+	Class string
+	// Attempts lists the attempts in order
+	Attempts []TestStatus
+	// Quarantine is the quarantine of the test, if the test is quarantined.
+	// Quarantined tests do not fail the suite regardless of the class
+	Quarantine *Quarantine
+}
+
+// Classify groups the test attempts by test and classifies the outcome of each test
+func Classify(statuses []TestStatus) (results []TestResult) {
+	index := make(map[string]int)
+	for _, status := range statuses {
+		test := status.Test
+		if test == "" {
+			test = status.Name
+		}
+		i, ok := index[test]
+		if !ok {
+			i = len(results)
+			index[test] = i
+			results = append(results, TestResult{Test: test})
+		}
+		results[i].Attempts = append(results[i].Attempts, status)
+	}
+	for i := range results {
+		attempts := results[i].Attempts
+		sort.SliceStable(attempts, func(i, j int) bool {
+			return attempts[i].Attempt < attempts[j].Attempt
+		})
+		results[i].Class = classify(attempts)
+		results[i].Quarantine = attempts[len(attempts)-1].Quarantine
+	}
+	return results
+}
+
+func classify(attempts []TestStatus) string {
+	last := attempts[len(attempts)-1]
+	switch last.Status {
+	case TestStatusPassed:
+		if len(attempts) == 1 {
+			return ClassPassed
+		}
+		return ClassFlake
+	case TestStatusCancelled:
+		if last.TimedOutPhase != "" {
+			return ClassTimedOut
+		}
+		return ClassCancelled
+	}
+	if isKnownIssue(attempts) {
+		return ClassKnownIssue
+	}
+	for _, attempt := range attempts {
+		if !isInfrastructureFailure(attempt) {
+			return ClassFailure
+		}
+	}
+	return ClassInfrastructure
+}
+
+// isKnownIssue returns true if all attempts failed with an acknowledged known failure
+func isKnownIssue(attempts []TestStatus) bool {
+	for _, attempt := range attempts {
+		if attempt.KnownIssue == nil || !attempt.KnownIssue.Acknowledged() {
+			return false
+		}
+	}
+	return true
+}
+
+// isInfrastructureFailure returns true if the attempt failed
+// due to the infrastructure rather than the product or the test
+func isInfrastructureFailure(status TestStatus) bool {
+	return status.Preempted || status.Category.IsInfrastructure()
+}
+
+func errorMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,41 @@
+package gravity
+
+import (
+	"testing"
+
+	"github.com/gravitational/robotest/lib/failure"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassify(t *testing.T) {
+	statuses := []TestStatus{
+		{Test: "install-1", Attempt: 1, Status: TestStatusPassed},
+		{Test: "upgrade-1", Attempt: 2, Status: TestStatusPassed},
+		{Test: "upgrade-1", Attempt: 1, Status: TestStatusFailed},
+		{Test: "resize-1", Attempt: 1, Status: TestStatusFailed},
+		{Test: "resize-1", Attempt: 2, Status: TestStatusFailed},
+		{Test: "recover-1", Attempt: 1, Status: TestStatusFailed, Preempted: true},
+		{Test: "recover-1", Attempt: 2, Status: TestStatusFailed, Category: failure.CategoryProvisioning},
+		{Test: "expand-1", Attempt: 1, Status: TestStatusFailed, KnownIssue: &failure.KnownSignature{Ticket: "GRAV-1"}},
+		{Test: "shrink-1", Attempt: 1, Status: TestStatusCancelled, TimedOutPhase: "upgrade"},
+		{Test: "join-1", Attempt: 1, Status: TestStatusFailed},
+		{Test: "join-1", Attempt: 2, Status: TestStatusFailed, KnownIssue: &failure.KnownSignature{Ticket: "GRAV-1"}},
+		{Test: "leave-1", Attempt: 1, Status: TestStatusFailed, KnownIssue: &failure.KnownSignature{Description: "no ticket"}},
+	}
+
+	var classes []string
+	for _, result := range Classify(statuses) {
+		classes = append(classes, result.Test+"="+result.Class)
+	}
+	assert.Equal(t, []string{
+		"install-1=" + ClassPassed,
+		"upgrade-1=" + ClassFlake,
+		"resize-1=" + ClassFailure,
+		"recover-1=" + ClassInfrastructure,
+		"expand-1=" + ClassKnownIssue,
+		"shrink-1=" + ClassTimedOut,
+		"join-1=" + ClassFailure,
+		"leave-1=" + ClassFailure,
+	}, classes)
+}
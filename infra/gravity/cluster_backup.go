@@ -0,0 +1,62 @@
+package gravity
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gravitational/trace"
+)
+
+// Backup backs up the application data of the cluster on the given node
+// into the specified path on the node
+func (c *TestContext) Backup(node Gravity, outputPath string) error {
+	defer c.enterPhase("backup")()
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Backup)
+	defer cancel()
+
+	c.Logger().WithField("node", node).Infof("Backup to %v.", outputPath)
+	return trace.Wrap(node.Backup(ctx, outputPath))
+}
+
+// Restore restores the application data of the cluster from the backup
+// at the specified path on the given node
+func (c *TestContext) Restore(node Gravity, backupPath string) error {
+	defer c.enterPhase("restore")()
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Backup)
+	defer cancel()
+
+	c.Logger().WithField("node", node).Infof("Restore from %v.", backupPath)
+	return trace.Wrap(node.Restore(ctx, backupPath))
+}
+
+// WriteWorkloadData stores the value as the workload data marker (a ConfigMap in the
+// default namespace) to verify with VerifyWorkloadData after the cluster data has been restored.
+// The application backup hook is expected to back up the ConfigMaps in the default namespace
+func (c *TestContext) WriteWorkloadData(node Gravity, value string) error {
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	_, err := node.RunInPlanet(ctx, "/usr/bin/kubectl", "create", "configmap", workloadMarker,
+		"--namespace=default", fmt.Sprintf("--from-literal=value=%v", value))
+	return trace.Wrap(err)
+}
+
+// VerifyWorkloadData verifies that the workload data marker has the expected value
+func (c *TestContext) VerifyWorkloadData(node Gravity, expected string) error {
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	out, err := node.RunInPlanet(ctx, "/usr/bin/kubectl", "get", "configmap", workloadMarker,
+		"--namespace=default", "-ojsonpath={.data.value}")
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if value := strings.TrimSpace(out); value != expected {
+		return trace.CompareFailed("workload data is %q after restore, expected %q", value, expected)
+	}
+	return nil
+}
+
+// workloadMarker names the ConfigMap with the workload data
+const workloadMarker = "robotest-backup"
@@ -0,0 +1,153 @@
+package gravity
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/gravitational/robotest/lib/defaults"
+	"github.com/gravitational/robotest/lib/shell"
+
+	"github.com/gravitational/trace"
+)
+
+// OperationInProgressMessage is the error gravity reports when an operation
+// is requested while another cluster operation is in progress
+const OperationInProgressMessage = "another operation is in progress"
+
+// AssertJoinDuringUpgradeRejected starts the upgrade to the specified installer in manual mode
+// and verifies that joining the node while the upgrade is in progress is rejected with
+// OperationInProgressMessage. The upgrade is rolled back afterwards
+func (c *TestContext) AssertJoinDuringUpgradeRejected(nodes []Gravity, node Gravity,
+	installerURL, gravityURL, subdir string, p InstallParam) error {
+	roles, err := c.NodesByRole(nodes)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	master := roles.ApiMaster
+	err = c.withEgress(nodes, func() error {
+		return c.uploadInstaller(master, roles.Other, installerURL, gravityURL, subdir)
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, withDuration(c.timeouts.Upgrade, len(nodes)))
+	defer cancel()
+
+	c.Logger().WithField("leader", master).Info("Upgrade in manual mode.")
+	if err := master.UpgradeManual(ctx); err != nil {
+		return trace.Wrap(err)
+	}
+
+	cmd, err := joinCmd(ctx, master, p)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	c.Logger().WithField("node", node).Info("Join during upgrade.")
+	joinErr := assertOperationConflict(ctx, node, node.Join(ctx, *cmd))
+
+	c.Logger().WithField("leader", master).Info("Rollback upgrade.")
+	if err := master.Rollback(ctx); err != nil {
+		return trace.NewAggregate(joinErr, trace.Wrap(err, "failed to roll back upgrade"))
+	}
+	return trace.Wrap(joinErr)
+}
+
+// ConcurrentJoin joins the extra nodes to the cluster simultaneously.
+// At least one of the joins is expected to succeed, the others either succeed
+// or are rejected with OperationInProgressMessage.
+// Returns the nodes that have joined the cluster
+func (c *TestContext) ConcurrentJoin(current, extra []Gravity, p InstallParam) (joined []Gravity, err error) {
+	if len(current) == 0 || len(extra) == 0 {
+		return nil, trace.BadParameter("empty node list")
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, withDuration(c.timeouts.Install, len(extra)))
+	defer cancel()
+
+	cmd, err := joinCmd(ctx, current[0], p)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	c.Logger().WithField("extra", extra).Info("Concurrent join.")
+	type result struct {
+		node Gravity
+		err  error
+	}
+	results := make(chan result, len(extra))
+	for _, node := range extra {
+		go func(node Gravity) {
+			results <- result{node: node, err: node.Join(ctx, *cmd)}
+		}(node)
+	}
+
+	var errs []error
+	for range extra {
+		r := <-results
+		if r.err == nil {
+			joined = append(joined, r.node)
+			continue
+		}
+		if err := assertOperationConflict(ctx, r.node, r.err); err != nil {
+			errs = append(errs, err)
+		}
+		c.Logger().WithField("node", r.node).Info("Join rejected as expected.")
+	}
+	if len(errs) != 0 {
+		return joined, trace.NewAggregate(errs...)
+	}
+	if len(joined) == 0 {
+		return nil, trace.CompareFailed("none of the concurrent joins succeeded")
+	}
+	return joined, nil
+}
+
+// assertOperationConflict verifies that the operation on the node failed with
+// OperationInProgressMessage
+func assertOperationConflict(ctx context.Context, node Gravity, opErr error) error {
+	if opErr == nil {
+		return trace.CompareFailed("operation on %v succeeded, expected it to fail with %q",
+			node, OperationInProgressMessage)
+	}
+	g, ok := node.(*gravity)
+	if !ok {
+		return trace.BadParameter("unsupported node %v", node)
+	}
+	err := logContains(ctx, g, OperationInProgressMessage)
+	if err != nil {
+		return trace.Wrap(opErr, "operation on %v failed but not with %q", node, OperationInProgressMessage)
+	}
+	return nil
+}
+
+// joinCmd returns the command to join the cluster of the master node
+func joinCmd(ctx context.Context, master Gravity, p InstallParam) (*JoinCmd, error) {
+	joinAddr, err := peerAddr(master.Node(), p.AddressFamily)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	status, err := master.Status(ctx)
+	if err != nil {
+		return nil, trace.Wrap(err, "query status from [%v]", master)
+	}
+	return &JoinCmd{
+		PeerAddr:      joinAddr,
+		Token:         status.Cluster.Token.Token,
+		Role:          p.Role,
+		StateDir:      p.StateDir,
+		AddressFamily: p.AddressFamily,
+		FIPS:          p.FIPS,
+	}, nil
+}
+
+// logContains verifies that the operation log on the node contains the message
+func logContains(ctx context.Context, node *gravity, message string) error {
+	logPath := filepath.Join(node.installDir, defaults.AgentLogPath)
+	cmd := shell.Sudo("grep", "-F", "-q", message, logPath).String()
+	err := node.run(ctx, node.Logger(), cmd, nil)
+	if err != nil {
+		return trace.NotFound("%v does not mention %q", logPath, message)
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package gravity
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gravitational/robotest/lib/wait"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// ResizeCycleStats records the durations of the operations over the resize cycles
+type ResizeCycleStats struct {
+	// Cycles is the number of completed cycles
+	Cycles int
+	// Expand is the cumulative duration of the expand operations
+	Expand time.Duration
+	// Shrink is the cumulative duration of the shrink operations
+	Shrink time.Duration
+	// MaxExpand is the duration of the slowest expand operation
+	MaxExpand time.Duration
+	// MaxShrink is the duration of the slowest shrink operation
+	MaxShrink time.Duration
+	// StateKeys is the number of keys in the cluster state (etcd) after the last cycle
+	StateKeys int
+}
+
+// Fields returns the stats as log fields
+func (r ResizeCycleStats) Fields() logrus.Fields {
+	fields := logrus.Fields{
+		"cycles":     r.Cycles,
+		"expand":     r.Expand,
+		"shrink":     r.Shrink,
+		"max_expand": r.MaxExpand,
+		"max_shrink": r.MaxShrink,
+		"state_keys": r.StateKeys,
+	}
+	if r.Cycles != 0 {
+		fields["avg_expand"] = r.Expand / time.Duration(r.Cycles)
+		fields["avg_shrink"] = r.Shrink / time.Duration(r.Cycles)
+	}
+	return fields
+}
+
+// CycleResize repeatedly expands the cluster by the extra node and shrinks it again
+// with the extra node gracefully leaving, the way an autoscaling group cycles nodes.
+// After each step, the workload is verified to be healthy and, after each shrink,
+// the number of etcd members to be back to the number before the first cycle
+// and the cluster state (operation history included) to grow no faster than
+// linearly with the number of cycles, to surface leaks in the operation and membership handling.
+// The stats of the completed cycles are returned also on failure
+func (c *TestContext) CycleResize(nodes []Gravity, extra Gravity, p InstallParam, cycles int) (stats ResizeCycleStats, err error) {
+	if len(nodes) == 0 {
+		return stats, trace.BadParameter("empty node list")
+	}
+	master := nodes[0]
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	members, err := etcdMembers(ctx, master)
+	cancel()
+	if err != nil {
+		return stats, trace.Wrap(err)
+	}
+	etcdSize := len(members)
+	growth := stateGrowth{}
+	growth.base, err = c.stateKeys(master)
+	if err != nil {
+		return stats, trace.Wrap(err)
+	}
+
+	for cycle := 1; cycle <= cycles; cycle++ {
+		log := c.Logger().WithField("cycle", fmt.Sprintf("%v/%v", cycle, cycles))
+
+		log.WithField("node", extra).Info("Expand.")
+		start := time.Now()
+		err = c.Expand(nodes, []Gravity{extra}, p)
+		if err == nil {
+			err = c.waitForMembers(master, []Gravity{extra}, p.AddressFamily)
+		}
+		if err != nil {
+			return stats, trace.Wrap(err, "expand in cycle %v", cycle)
+		}
+		stats.addExpand(time.Since(start))
+		err = c.CheckWorkloadHealth(nodes)
+		if err != nil {
+			return stats, trace.Wrap(err, "workload after expand in cycle %v", cycle)
+		}
+
+		log.WithField("node", extra).Info("Shrink.")
+		start = time.Now()
+		err = c.ShrinkLeave(nodes, []Gravity{extra})
+		if err != nil {
+			return stats, trace.Wrap(err, "shrink in cycle %v", cycle)
+		}
+		stats.addShrink(time.Since(start))
+		err = c.CheckWorkloadHealth(nodes)
+		if err != nil {
+			return stats, trace.Wrap(err, "workload after shrink in cycle %v", cycle)
+		}
+		err = c.waitForEtcdSize(master, etcdSize)
+		if err != nil {
+			return stats, trace.Wrap(err, "etcd membership after shrink in cycle %v", cycle)
+		}
+
+		stats.StateKeys, err = c.stateKeys(master)
+		if err != nil {
+			return stats, trace.Wrap(err)
+		}
+		err = growth.check(cycle, stats.StateKeys)
+		if err != nil {
+			return stats, trace.Wrap(err, "cluster state after cycle %v", cycle)
+		}
+
+		stats.Cycles = cycle
+		log.WithFields(stats.Fields()).Info("Resize cycle completed.")
+	}
+	return stats, nil
+}
+
+// waitForEtcdSize waits until the etcd cluster queried on master has the given number of members
+func (c *TestContext) waitForEtcdSize(master Gravity, size int) error {
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	retry := wait.Retryer{
+		Attempts:    30,
+		Delay:       10 * time.Second,
+		FieldLogger: c.Logger(),
+	}
+	return trace.Wrap(retry.Do(ctx, func() error {
+		members, err := etcdMembers(ctx, master)
+		if err != nil {
+			return wait.Continue("etcd members not available: %v", err)
+		}
+		if len(members) != size {
+			return wait.Continue("expected %v etcd members, got %v", size, len(members))
+		}
+		return nil
+	}))
+}
+
+// stateKeys returns the number of keys in the cluster state queried on master
+func (c *TestContext) stateKeys(master Gravity) (int, error) {
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+	out, err := master.RunInPlanet(ctx, "/usr/bin/etcdctl", "ls", "--recursive", "/")
+	if err != nil {
+		return 0, trace.Wrap(err, "list cluster state keys")
+	}
+	var keys int
+	for _, line := range strings.Split(out, "\n") {
+		if strings.TrimSpace(line) != "" {
+			keys++
+		}
+	}
+	return keys, nil
+}
+
+// stateGrowth verifies that the cluster state grows at most linearly with the resize cycles:
+// each cycle is allowed to add as many keys as the first cycle added (i.e. the records of
+// its expand and shrink operations), plus stateGrowthSlack for the unrelated churn
+type stateGrowth struct {
+	// base is the number of keys before the first cycle
+	base int
+	// perCycle is the number of keys added by the first cycle
+	perCycle int
+}
+
+// check verifies the number of keys after the given cycle
+func (r *stateGrowth) check(cycle, keys int) error {
+	if cycle == 1 {
+		r.perCycle = keys - r.base
+		if r.perCycle < 0 {
+			r.perCycle = 0
+		}
+		return nil
+	}
+	if limit := r.base + cycle*r.perCycle + stateGrowthSlack; keys > limit {
+		return trace.LimitExceeded("cluster state grew from %v to %v keys over %v cycles, expected at most %v (%v per cycle)",
+			r.base, keys, cycle, limit, r.perCycle)
+	}
+	return nil
+}
+
+// stateGrowthSlack is the number of keys the cluster state may grow by
+// beyond the linear growth over the resize cycles
+const stateGrowthSlack = 50
+
+func (r *ResizeCycleStats) addExpand(d time.Duration) {
+	r.Expand += d
+	if d > r.MaxExpand {
+		r.MaxExpand = d
+	}
+}
+
+func (r *ResizeCycleStats) addShrink(d time.Duration) {
+	r.Shrink += d
+	if d > r.MaxShrink {
+		r.MaxShrink = d
+	}
+}
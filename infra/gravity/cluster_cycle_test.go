@@ -0,0 +1,28 @@
+package gravity
+
+import (
+	"testing"
+
+	"github.com/gravitational/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateGrowsLinearly(t *testing.T) {
+	growth := stateGrowth{base: 1000}
+	require.NoError(t, growth.check(1, 1010))
+	assert.Equal(t, 10, growth.perCycle)
+	require.NoError(t, growth.check(2, 1020))
+	require.NoError(t, growth.check(10, 1100+stateGrowthSlack), "within the slack")
+
+	err := growth.check(10, 1101+stateGrowthSlack)
+	assert.True(t, trace.IsLimitExceeded(err), "expected limit exceeded, got %v", err)
+}
+
+func TestStateShrinksInFirstCycle(t *testing.T) {
+	growth := stateGrowth{base: 1000}
+	require.NoError(t, growth.check(1, 990))
+	assert.Equal(t, 0, growth.perCycle)
+	assert.NoError(t, growth.check(2, 1000+stateGrowthSlack))
+	assert.Error(t, growth.check(2, 1001+stateGrowthSlack))
+}
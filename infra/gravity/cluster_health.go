@@ -0,0 +1,97 @@
+package gravity
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gravitational/robotest/lib/wait"
+
+	"github.com/gravitational/trace"
+)
+
+// CheckWorkloadHealth waits for all pods in the cluster, except for completed ones,
+// to become ready
+func (c *TestContext) CheckWorkloadHealth(nodes []Gravity) error {
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	master := nodes[0]
+	retry := wait.Retryer{
+		Delay:       20 * time.Second,
+		Attempts:    30,
+		FieldLogger: c.Logger().WithField("node", master),
+	}
+	err := retry.Do(ctx, func() error {
+		notReady, err := notReadyPods(ctx, master)
+		if err != nil {
+			return wait.Continue("failed to query pods: %v", err)
+		}
+		if len(notReady) != 0 {
+			return wait.Continue("pods not ready: %v", strings.Join(notReady, ","))
+		}
+		return nil
+	})
+	return trace.Wrap(err)
+}
+
+// EtcdVersion returns the version of etcd running on the node
+func (c *TestContext) EtcdVersion(node Gravity) (string, error) {
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	out, err := node.RunInPlanet(ctx, "/usr/bin/etcd", "--version")
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	return parseEtcdVersion(out)
+}
+
+// AssertEtcdVersion verifies that all nodes run the expected version of etcd
+func (c *TestContext) AssertEtcdVersion(nodes []Gravity, expected string) error {
+	for _, node := range nodes {
+		version, err := c.EtcdVersion(node)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		if version != expected {
+			return trace.CompareFailed("%v runs etcd %v, expected %v", node, version, expected)
+		}
+	}
+	return nil
+}
+
+// notReadyPods returns the names (as namespace/name) of running pods that are not ready
+func notReadyPods(ctx context.Context, node Gravity) (names []string, err error) {
+	out, err := node.RunInPlanet(ctx, "/usr/bin/kubectl", "get", "pods", "--all-namespaces",
+		"--field-selector=status.phase!=Succeeded",
+		`-ojsonpath={range .items[*]}{.metadata.namespace}/{.metadata.name},{.status.conditions[?(@.type=="Ready")].status}{"\n"}{end}`)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if line == "" {
+			continue
+		}
+		v := strings.Split(line, ",")
+		if len(v) != 2 {
+			return nil, trace.Errorf("unexpected string %q", line)
+		}
+		if v[1] != "True" {
+			names = append(names, v[0])
+		}
+	}
+	return names, nil
+}
+
+// parseEtcdVersion extracts the version from the output of etcd --version
+func parseEtcdVersion(out string) (string, error) {
+	match := reEtcdVersion.FindStringSubmatch(out)
+	if match == nil {
+		return "", trace.BadParameter("unexpected etcd version output %q", out)
+	}
+	return match[1], nil
+}
+
+var reEtcdVersion = regexp.MustCompile(`etcd Version: (\S+)`)
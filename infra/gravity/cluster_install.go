@@ -31,7 +31,7 @@ func (c *TestContext) FromPreviousInstall(nodes []Gravity, subdir string) {
 // ProvisionInstaller deploys a specific installer
 func (c *TestContext) SetInstaller(nodes []Gravity, installerUrl string, tag string) error {
 	// Cloud Provider ops will install telekube for us, so we can just exit early
-	if c.provisionerCfg.CloudProvider == constants.Ops {
+	if c.provisionerConfig().CloudProvider == constants.Ops {
 		return nil
 	}
 
@@ -43,24 +43,28 @@ func (c *TestContext) SetInstaller(nodes []Gravity, installerUrl string, tag str
 		return trace.Wrap(err)
 	}
 
-	ctx, cancel = context.WithTimeout(c.ctx, c.timeouts.Install)
-	defer cancel()
+	// air-gapped nodes only have access to the internet while transferring the installer
+	return trace.Wrap(c.withEgress(nodes, func() error {
+		ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Install)
+		defer cancel()
 
-	errs := make(chan error, len(nodes))
-	for _, node := range nodes {
-		go func(node Gravity) {
-			errs <- node.SetInstaller(ctx, installerUrl, tag)
-		}(node)
-	}
+		errs := make(chan error, len(nodes))
+		for _, node := range nodes {
+			go func(node Gravity) {
+				errs <- node.SetInstaller(ctx, installerUrl, tag)
+			}(node)
+		}
 
-	_, err = utils.Collect(ctx, cancel, errs, nil)
-	return trace.Wrap(err)
+		_, err := utils.Collect(ctx, cancel, errs, nil)
+		return trace.Wrap(err)
+	}))
 }
 
 // OfflineInstall sets up cluster using nodes provided
 func (c *TestContext) OfflineInstall(nodes []Gravity, param InstallParam) error {
+	defer c.enterPhase("install")()
 	// Cloud Provider ops will install telekube for us, so we can just exit early
-	if c.provisionerCfg.CloudProvider == constants.Ops {
+	if c.provisionerConfig().CloudProvider == constants.Ops {
 		return nil
 	}
 
@@ -69,8 +73,20 @@ func (c *TestContext) OfflineInstall(nodes []Gravity, param InstallParam) error
 	ctx, cancel := context.WithTimeout(c.ctx, withDuration(c.timeouts.Install, len(nodes)))
 	defer cancel()
 
-	param.CloudProvider = c.provisionerCfg.CloudProvider
-	master := nodes[0].(*gravity)
+	return trace.Wrap(c.offlineInstall(ctx, cancel, nodes, param))
+}
+
+// offlineInstall installs the cluster on the nodes within the given context.
+// cancel is invoked to abort the install on all nodes once any node fails
+func (c *TestContext) offlineInstall(ctx context.Context, cancel func(), nodes []Gravity, param InstallParam) error {
+	if len(nodes) == 0 {
+		return trace.BadParameter("at least one node is required")
+	}
+	master, ok := nodes[0].(*gravity)
+	if !ok {
+		return trace.BadParameter("unsupported node %v", nodes[0])
+	}
+	param.CloudProvider = c.provisionerConfig().CloudProvider
 	if param.Token == "" {
 		param.Token = "ROBOTEST"
 	}
@@ -81,29 +97,43 @@ func (c *TestContext) OfflineInstall(nodes []Gravity, param InstallParam) error
 		param.GCENodeTag = gce.TranslateClusterName(param.Cluster)
 	}
 
+	joinAddr, err := peerAddr(master.Node(), param.AddressFamily)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	if len(param.NodeRoles) != 0 && param.NodeRolesCount() != len(nodes) {
+		return trace.BadParameter("node roles cover %v nodes, got %v nodes",
+			param.NodeRolesCount(), len(nodes))
+	}
+
 	errs := make(chan error, len(nodes))
 	go func() {
 		c.Logger().WithField("node", master).Info("Install on leader node.")
-		errs <- master.Install(ctx, param)
+		masterParam := param
+		masterParam.Role = param.RoleOf(0)
+		errs <- master.Install(ctx, masterParam)
 	}()
 
-	for _, node := range nodes[1:] {
-		go func(n Gravity) {
-			c.Logger().WithField("node", n).Info("Join.")
+	for i, node := range nodes[1:] {
+		go func(n Gravity, role string) {
+			c.Logger().WithFields(log.Fields{"node": n, "role": role}).Info("Join.")
 			err := n.Join(ctx, JoinCmd{
-				PeerAddr: master.Node().PrivateAddr(),
-				Token:    param.Token,
-				Role:     param.Role,
-				StateDir: param.StateDir,
+				PeerAddr:      joinAddr,
+				Token:         param.Token,
+				Role:          role,
+				StateDir:      param.StateDir,
+				AddressFamily: param.AddressFamily,
+				FIPS:          param.FIPS,
 			})
 			if err != nil {
 				n.Logger().WithError(err).Warn("Join failed.")
 			}
 			errs <- err
-		}(node)
+		}(node, param.RoleOf(i+1))
 	}
 
-	_, err := utils.Collect(ctx, cancel, errs, nil)
+	_, err = utils.Collect(ctx, cancel, errs, nil)
 	if err != nil {
 		c.Logger().WithError(err).Warn("Install failed.")
 		return trace.Wrap(err)
@@ -147,6 +177,7 @@ func waitFileInstaller(ctx context.Context, file string, logger log.FieldLogger)
 // Uninstall makes nodes leave cluster and uninstall gravity
 // it is not asserting internally
 func (c *TestContext) Uninstall(nodes []Gravity) error {
+	defer c.enterPhase("uninstall")()
 	ctx, cancel := context.WithTimeout(c.ctx, withDuration(c.timeouts.Install, len(nodes)))
 	defer cancel()
 
@@ -181,12 +212,15 @@ func (c *TestContext) UninstallApp(nodes []Gravity) error {
 
 // Upgrade performs an upgrade procedure on all nodes
 func (c *TestContext) Upgrade(nodes []Gravity, installerURL, gravityURL, subdir string) error {
+	defer c.enterPhase("upgrade")()
 	roles, err := c.NodesByRole(nodes)
 	if err != nil {
 		return trace.Wrap(err)
 	}
 	master := roles.ApiMaster
-	err = c.uploadInstaller(roles.ApiMaster, roles.Other, installerURL, gravityURL, subdir)
+	err = c.withEgress(nodes, func() error {
+		return c.uploadInstaller(roles.ApiMaster, roles.Other, installerURL, gravityURL, subdir)
+	})
 	if err != nil {
 		return trace.Wrap(err)
 	}
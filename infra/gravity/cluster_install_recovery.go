@@ -0,0 +1,150 @@
+package gravity
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/gravitational/robotest/lib/shell"
+	sshutils "github.com/gravitational/robotest/lib/ssh"
+	"github.com/gravitational/robotest/lib/wait"
+
+	"github.com/gravitational/trace"
+)
+
+// InterruptInstall starts the install on the nodes and kills the installer on the master node
+// once the specified phase of the install plan has completed.
+// The nodes are left with the partially completed install, see ResumeInstall and Reinstall
+func (c *TestContext) InterruptInstall(nodes []Gravity, param InstallParam, phase string) error {
+	if len(nodes) == 0 {
+		return trace.BadParameter("at least one node is required")
+	}
+	master, ok := nodes[0].(*gravity)
+	if !ok {
+		return trace.BadParameter("unsupported node %v", nodes[0])
+	}
+	c.Logger().WithField("phase", phase).Info("Offline install, interrupted after phase.")
+
+	ctx, cancel := context.WithTimeout(c.ctx, withDuration(c.timeouts.Install, len(nodes)))
+	defer cancel()
+
+	installCtx, cancelInstall := context.WithCancel(ctx)
+	defer cancelInstall()
+	installErr := make(chan error, 1)
+	go func() {
+		installErr <- c.offlineInstall(installCtx, cancelInstall, nodes, param)
+	}()
+
+	retry := wait.Retryer{
+		Delay:       10 * time.Second,
+		Attempts:    int(withDuration(c.timeouts.Install, len(nodes)) / (10 * time.Second)),
+		FieldLogger: master.Logger().WithField("phase", phase),
+	}
+	err := retry.Do(ctx, func() error {
+		select {
+		case err := <-installErr:
+			return wait.Abort(trace.CompareFailed(
+				"install finished before phase %v could be interrupted: %v", phase, err))
+		default:
+		}
+		state, err := master.phaseState(ctx, phase)
+		if err != nil {
+			return wait.Continue("install plan not available: %v", err)
+		}
+		if state != phaseStateCompleted {
+			return wait.Continue("phase %v is %v", phase, state)
+		}
+		return nil
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	master.Logger().Info("Kill installer.")
+	err = master.run(ctx, master.Logger(), killInstallerCmd, nil)
+	cancelInstall()
+	<-installErr
+	return trace.Wrap(err)
+}
+
+// ResumeInstall resumes the interrupted install on the master node
+// and waits for the cluster to become active
+func (c *TestContext) ResumeInstall(nodes []Gravity) error {
+	ctx, cancel := context.WithTimeout(c.ctx, withDuration(c.timeouts.Install, len(nodes)))
+	defer cancel()
+
+	c.Logger().WithField("node", nodes[0]).Info("Resume install.")
+	if err := nodes[0].ResumePlan(ctx); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(c.Status(nodes))
+}
+
+// Reinstall wipes the interrupted install from the nodes
+// and installs the cluster from scratch on the same nodes
+func (c *TestContext) Reinstall(nodes []Gravity, param InstallParam) error {
+	c.Logger().Info("Wipe install.")
+	if err := c.Uninstall(nodes); err != nil {
+		return trace.Wrap(err, "failed to wipe interrupted install")
+	}
+	if err := c.OfflineInstall(nodes, param); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(c.Status(nodes))
+}
+
+// phaseState returns the state of the specified phase of the active operation plan
+func (g *gravity) phaseState(ctx context.Context, phase string) (state string, err error) {
+	var out string
+	cmd := shell.InDir(g.installDir, shell.Sudo("./gravity", "plan", "--output=json"))
+	err = g.runAndParse(ctx, g.Logger(), cmd, nil, sshutils.ParseAsString(&out))
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	return findPhaseState([]byte(out), phase)
+}
+
+// findPhaseState returns the state of the phase with the given ID in the operation plan
+func findPhaseState(plan []byte, phase string) (string, error) {
+	var p planPhase
+	if err := json.Unmarshal(plan, &p); err != nil {
+		return "", trace.Wrap(err, "failed to parse operation plan")
+	}
+	if found := p.find(phase); found != nil {
+		return found.State, nil
+	}
+	return "", trace.NotFound("phase %v not found in operation plan", phase)
+}
+
+// planPhase is a minimal copy of the operation plan and phase definitions
+// from the gravitational/gravity project
+type planPhase struct {
+	// ID is the phase ID, i.e. /masters/node-1
+	ID string `json:"id"`
+	// State is the phase state
+	State string `json:"state"`
+	// Updated is the time the phase state was last updated
+	Updated time.Time `json:"updated"`
+	// Phases lists the subphases
+	Phases []planPhase `json:"phases"`
+}
+
+func (r planPhase) find(id string) *planPhase {
+	for _, phase := range r.Phases {
+		if phase.ID == id {
+			return &phase
+		}
+		if found := phase.find(id); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+const phaseStateCompleted = "completed"
+
+// killInstallerCmd kills the installer process (and the installer service
+// on versions running the installer as a systemd unit).
+// The bracket keeps the pattern from matching the shell running the command itself
+const killInstallerCmd = `sudo systemctl kill --signal=SIGKILL gravity-installer.service 2>/dev/null; ` +
+	`sudo pkill -9 -f '[g]ravity install' ; true`
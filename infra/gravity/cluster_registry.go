@@ -0,0 +1,213 @@
+package gravity
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/gravitational/robotest/lib/utils"
+
+	"github.com/gravitational/trace"
+)
+
+// CheckRegistry verifies the cluster-internal docker registries from each of the nodes:
+// each node pushes a test image tagged after the node to the registry of every master,
+// then each node pulls the images pushed by all nodes through the registry of every master
+// and through the registry of the leader. The test images are retagged images already present
+// on the nodes, so the check does not require access to the outside world.
+// The test images are deleted from the registries afterwards
+func (c *TestContext) CheckRegistry(nodes []Gravity) (err error) {
+	if len(nodes) == 0 {
+		return trace.BadParameter("empty node list")
+	}
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	status, err := nodes[0].Status(ctx)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	registries := masterRegistries(status.Cluster.Nodes)
+	if len(registries) == 0 {
+		return trace.NotFound("no master nodes in cluster status")
+	}
+
+	c.Logger().WithField("nodes", Nodes(nodes)).WithField("registries", registries).Info("Check cluster registry.")
+	var pushed registryImages
+	defer func() {
+		if errDelete := deleteRegistryImages(ctx, nodes[0], pushed.list()); errDelete != nil {
+			err = trace.NewAggregate(err, trace.Wrap(errDelete, "failed to delete test images from registry"))
+		}
+	}()
+	errs := make(chan error, len(nodes))
+	for _, node := range nodes {
+		go func(node Gravity) {
+			errs <- trace.Wrap(pushRegistryImages(ctx, node, registries, &pushed), "failed to push to registry from %v", node)
+		}(node)
+	}
+	if err := utils.CollectErrors(ctx, errs); err != nil {
+		return trace.Wrap(err)
+	}
+
+	var images []string
+	pullFrom := append(append([]string(nil), registries...), clusterRegistry)
+	for _, node := range nodes {
+		for _, registry := range pullFrom {
+			images = append(images, registryCheckImage(registry, node))
+		}
+	}
+	for _, node := range nodes {
+		go func(node Gravity) {
+			errs <- trace.Wrap(pullRegistryImages(ctx, node, images), "failed to pull from registry on %v", node)
+		}(node)
+	}
+	return trace.Wrap(utils.CollectErrors(ctx, errs))
+}
+
+// masterRegistries returns the addresses of the registries run by the master nodes
+func masterRegistries(nodes []NodeStatus) (registries []string) {
+	for _, node := range nodes {
+		if node.Role == "master" {
+			registries = append(registries, fmt.Sprintf("%v:%v", node.Addr, registryPort))
+		}
+	}
+	return registries
+}
+
+// pushRegistryImages tags a local image as the test image of the node and pushes it to each
+// of the registries. The pushed images are recorded in pushed
+func pushRegistryImages(ctx context.Context, node Gravity, registries []string, pushed *registryImages) error {
+	out, err := node.RunInPlanet(ctx, "/usr/bin/docker", "images", "--format={{.Repository}}:{{.Tag}}")
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	var source string
+	for _, image := range strings.Fields(out) {
+		if !strings.Contains(image, "<none>") {
+			source = image
+			break
+		}
+	}
+	if source == "" {
+		return trace.NotFound("no images to push")
+	}
+	for _, registry := range registries {
+		image := registryCheckImage(registry, node)
+		if _, err := node.RunInPlanet(ctx, "/usr/bin/docker", "tag", source, image); err != nil {
+			return trace.Wrap(err)
+		}
+		out, err := node.RunInPlanet(ctx, "/usr/bin/docker", "push", image)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		digest, err := parsePushDigest(out)
+		if err != nil {
+			return trace.Wrap(err, "failed to push %v", image)
+		}
+		pushed.add(registryImage{registry: registry, tag: registryCheckTag(node), digest: digest})
+		// remove the local tag so that the image is pulled from the registry later
+		if _, err := node.RunInPlanet(ctx, "/usr/bin/docker", "rmi", image); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// pullRegistryImages pulls the images from the cluster registries and removes them afterwards
+func pullRegistryImages(ctx context.Context, node Gravity, images []string) error {
+	for _, image := range images {
+		if _, err := node.RunInPlanet(ctx, "/usr/bin/docker", "pull", image); err != nil {
+			return trace.Wrap(err)
+		}
+		if _, err := node.RunInPlanet(ctx, "/usr/bin/docker", "rmi", image); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// deleteRegistryImages deletes the pushed test images from the registries
+// with the registry API, authenticated with the certificates of the node
+func deleteRegistryImages(ctx context.Context, node Gravity, images []registryImage) error {
+	var errs []error
+	for _, image := range images {
+		_, err := node.RunInPlanet(ctx, "/usr/bin/curl", registryDeleteArgs(image)...)
+		if err != nil {
+			errs = append(errs, trace.Wrap(err, "failed to delete %v", image))
+		}
+	}
+	return trace.NewAggregate(errs...)
+}
+
+// registryDeleteArgs returns the curl arguments to delete the manifest of the image
+func registryDeleteArgs(image registryImage) []string {
+	return []string{"--silent", "--show-error", "--fail", "--request", "DELETE",
+		"--cacert", "/var/state/root.cert", "--cert", "/var/state/kubelet.cert", "--key", "/var/state/kubelet.key",
+		fmt.Sprintf("https://%v/v2/%v/manifests/%v", image.registry, registryCheckRepository, image.digest)}
+}
+
+// parsePushDigest returns the digest of the manifest from the output of docker push:
+//
+//	1.0: digest: sha256:4c5c...e7e8 size: 528
+func parsePushDigest(out string) (string, error) {
+	match := rePushDigest.FindStringSubmatch(out)
+	if match == nil {
+		return "", trace.NotFound("no digest in docker push output %q", out)
+	}
+	return match[1], nil
+}
+
+// registryCheckImage returns the reference of the test image pushed by node to the registry
+func registryCheckImage(registry string, node Gravity) string {
+	return fmt.Sprintf("%v/%v:%v", registry, registryCheckRepository, registryCheckTag(node))
+}
+
+// registryCheckTag returns the tag of the test image pushed by node
+func registryCheckTag(node Gravity) string {
+	return reInvalidTag.ReplaceAllString(node.Node().PrivateAddr(), "-")
+}
+
+// registryImage is a test image pushed to one of the registries
+type registryImage struct {
+	registry string
+	tag      string
+	digest   string
+}
+
+func (r registryImage) String() string {
+	return fmt.Sprintf("%v/%v:%v@%v", r.registry, registryCheckRepository, r.tag, r.digest)
+}
+
+// registryImages collects the test images pushed by the nodes concurrently
+type registryImages struct {
+	mu     sync.Mutex
+	images []registryImage
+}
+
+func (r *registryImages) add(image registryImage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.images = append(r.images, image)
+}
+
+func (r *registryImages) list() []registryImage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]registryImage(nil), r.images...)
+}
+
+const (
+	// clusterRegistry is the address of the cluster-internal registry of the leader
+	clusterRegistry = "leader.telekube.local:5000"
+	// registryPort is the port of the registries on the master nodes
+	registryPort = 5000
+	// registryCheckRepository is the repository of the test images
+	registryCheckRepository = "robotest/registry-check"
+)
+
+var (
+	reInvalidTag = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+	rePushDigest = regexp.MustCompile(`digest: (sha256:[0-9a-f]{64})`)
+)
@@ -0,0 +1,208 @@
+package gravity
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gravitational/robotest/lib/wait"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// ReplaceNode replaces the dead cluster node with a freshly provisioned node the way
+// a failed node is recovered in the field: a new VM is provisioned and the installer
+// is transferred to it, the dead node is forcefully removed, the new node joins
+// the cluster with the role from p and the cluster is verified to have the replacement
+// (and not the dead node) as a member, both in the status and in etcd, and to be active.
+// nodes lists the current cluster nodes including the dead one.
+// Only supported for the nodes provisioned with terraform
+func (c *TestContext) ReplaceNode(nodes []Gravity, dead Gravity, installerURL string, p InstallParam) (remaining []Gravity, replacement Gravity, err error) {
+	defer c.enterPhase("replace")()
+	for _, node := range nodes {
+		if node != dead {
+			remaining = append(remaining, node)
+		}
+	}
+	if len(remaining) == 0 || len(remaining) == len(nodes) {
+		return nil, nil, trace.BadParameter("%v is not one of the cluster nodes %v", dead, Nodes(nodes))
+	}
+	replacement, err = c.provisionNode()
+	if err != nil {
+		return nil, nil, trace.Wrap(err)
+	}
+	err = c.SetInstaller([]Gravity{replacement}, installerURL, "install")
+	if err != nil {
+		return nil, nil, trace.Wrap(err)
+	}
+	master := remaining[0]
+	c.Logger().WithFields(logrus.Fields{
+		"dead":        dead,
+		"replacement": replacement,
+	}).Info("Replace node.")
+
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Leave)
+	defer cancel()
+	err = master.Remove(ctx, dead.Node().PrivateAddr(), Graceful(false))
+	if err != nil {
+		return nil, nil, trace.Wrap(err, "failed to remove %v", dead)
+	}
+
+	err = c.Expand(remaining, []Gravity{replacement}, p)
+	if err != nil {
+		return nil, nil, trace.Wrap(err)
+	}
+	err = c.waitForMembers(master, []Gravity{replacement}, p.AddressFamily)
+	if err != nil {
+		return nil, nil, trace.Wrap(err)
+	}
+	remaining = append(remaining, replacement)
+
+	err = c.assertEtcdMembers(master, remaining, dead)
+	if err != nil {
+		return nil, nil, trace.Wrap(err)
+	}
+	err = c.Status(remaining)
+	if err != nil {
+		return nil, nil, trace.Wrap(err)
+	}
+	return remaining, replacement, nil
+}
+
+// provisionNode provisions a new VM into the infrastructure of this test
+// and adds it to the provisioned nodes
+func (c *TestContext) provisionNode() (Gravity, error) {
+	c.nodesMu.Lock()
+	addNode := c.addNode
+	c.nodesMu.Unlock()
+	if addNode == nil {
+		return nil, trace.NotImplemented("provisioning additional nodes is not supported on %v",
+			c.provisionerConfig().CloudProvider)
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, addNodeTimeout)
+	defer cancel()
+	node, err := addNode(ctx)
+	if err != nil {
+		return nil, trace.Wrap(err, "failed to provision replacement node")
+	}
+	c.nodesMu.Lock()
+	c.nodes = append(c.nodes, node)
+	c.nodesMu.Unlock()
+	return node, nil
+}
+
+// assertEtcdMembers verifies that the etcd cluster queried on master has the expected
+// number of members (one per master node) and that the dead node is not one of them
+func (c *TestContext) assertEtcdMembers(master Gravity, nodes []Gravity, dead Gravity) error {
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	// NodesByRole reorders the nodes
+	roles, err := c.NodesByRole(append([]Gravity(nil), nodes...))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	masters := 1 + len(roles.ClusterBackup)
+	if roles.ClusterMaster == nil {
+		masters = len(roles.ClusterBackup)
+	}
+
+	retry := wait.Retryer{
+		Attempts:    30,
+		Delay:       10 * time.Second,
+		FieldLogger: c.Logger(),
+	}
+	return trace.Wrap(retry.Do(ctx, func() error {
+		members, err := etcdMembers(ctx, master)
+		if err != nil {
+			return wait.Continue("etcd members not available: %v", err)
+		}
+		for _, member := range members {
+			if member.hasPeer(dead.Node().PrivateAddr()) {
+				return wait.Continue("%v is still an etcd member", dead)
+			}
+		}
+		if len(members) != masters {
+			return wait.Continue("expected %v etcd members, got %v", masters, len(members))
+		}
+		return nil
+	}))
+}
+
+// etcdMembers returns the members of the etcd cluster queried on node
+func etcdMembers(ctx context.Context, node Gravity) ([]etcdMember, error) {
+	out, err := node.RunInPlanet(ctx, "/usr/bin/etcdctl", "member", "list")
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	members, err := parseEtcdMembers(out)
+	return members, trace.Wrap(err)
+}
+
+// etcdMember describes a member of the etcd cluster
+type etcdMember struct {
+	// ID is the hex member ID
+	ID string
+	// Name is the member name
+	Name string
+	// PeerURLs lists the peer URLs of the member
+	PeerURLs []string
+	// Leader is true if the member is the etcd leader
+	Leader bool
+}
+
+// hasPeer returns true if one of the peer URLs of the member points to addr
+func (r etcdMember) hasPeer(addr string) bool {
+	for _, peerURL := range r.PeerURLs {
+		u, err := url.Parse(peerURL)
+		if err != nil {
+			continue
+		}
+		if host, _, err := net.SplitHostPort(u.Host); err == nil && host == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// parseEtcdMembers parses the output of etcdctl member list, i.e.
+//
+//	8e9e05c52164694d: name=node-1 peerURLs=https://10.0.0.1:2380 clientURLs=https://10.0.0.1:2379 isLeader=true
+func parseEtcdMembers(out string) (members []etcdMember, err error) {
+	s := bufio.NewScanner(strings.NewReader(out))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if !reEtcdMemberID.MatchString(fields[0]) {
+			return nil, trace.BadParameter("unexpected etcd member line %q", line)
+		}
+		member := etcdMember{ID: strings.TrimSuffix(fields[0], ":")}
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			switch kv[0] {
+			case "name":
+				member.Name = kv[1]
+			case "peerURLs":
+				member.PeerURLs = strings.Split(kv[1], ",")
+			case "isLeader":
+				member.Leader = kv[1] == "true"
+			}
+		}
+		members = append(members, member)
+	}
+	return members, trace.Wrap(s.Err())
+}
+
+var reEtcdMemberID = regexp.MustCompile(`^[0-9a-f]+:$`)
@@ -2,19 +2,24 @@ package gravity
 
 import (
 	"context"
+	"net/url"
+	"path"
+	"time"
 
 	"github.com/gravitational/robotest/lib/constants"
 	"github.com/gravitational/robotest/lib/utils"
+	"github.com/gravitational/robotest/lib/wait"
 
 	"github.com/gravitational/trace"
 	"github.com/sirupsen/logrus"
 )
 
 func (c *TestContext) Expand(current, extra []Gravity, p InstallParam) error {
+	defer c.enterPhase("expand")()
 	if len(current) == 0 || len(extra) == 0 {
 		return trace.BadParameter("empty node list")
 	}
-	if c.provisionerCfg.CloudProvider == constants.Ops {
+	if c.provisionerConfig().CloudProvider == constants.Ops {
 		return trace.NotImplemented("not implemented")
 	}
 
@@ -26,24 +31,17 @@ func (c *TestContext) Expand(current, extra []Gravity, p InstallParam) error {
 	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
 	defer cancel()
 
-	master := current[0]
-	joinAddr := master.Node().PrivateAddr()
-	status, err := master.Status(ctx)
+	cmd, err := joinCmd(ctx, current[0], p)
 	if err != nil {
-		return trace.Wrap(err, "query status from [%v]", master)
+		return trace.Wrap(err)
 	}
 
-	ctx, cancel = context.WithTimeout(c.ctx, withDuration(c.timeouts.Install, len(extra)))
+	ctx, cancel = context.WithTimeout(c.ctx, withDuration(c.timeouts.Join, len(extra)))
 	defer cancel()
 
 	for _, node := range extra {
 		c.Logger().WithField("node", node).Info("Join.")
-		err = node.Join(ctx, JoinCmd{
-			PeerAddr: joinAddr,
-			Token:    status.Cluster.Token.Token,
-			Role:     p.Role,
-			StateDir: p.StateDir,
-		})
+		err = node.Join(ctx, *cmd)
 		if err != nil {
 			return trace.Wrap(err, "error joining cluster on node %s: %v", node.String(), err)
 		}
@@ -54,6 +52,7 @@ func (c *TestContext) Expand(current, extra []Gravity, p InstallParam) error {
 
 // ShrinkLeave will gracefully leave cluster
 func (c *TestContext) ShrinkLeave(nodesToKeep, nodesToRemove []Gravity) error {
+	defer c.enterPhase("shrink")()
 	ctx, cancel := context.WithTimeout(c.ctx, withDuration(c.timeouts.Leave, len(nodesToRemove)))
 	defer cancel()
 
@@ -87,3 +86,102 @@ func (c *TestContext) RemoveNode(nodesToKeep []Gravity, remove Gravity) error {
 	err = c.Status(nodesToKeep)
 	return trace.Wrap(err)
 }
+
+// ExpandWithAgentURL joins the extra nodes to the cluster by executing the agent script
+// served at the agent-token URL, the way nodes are added in the field, instead of
+// passing the peer address and token to gravity join.
+// The URL is based on the cluster web endpoint reported by gravity status.
+// addr optionally specifies the address (host:port) the URL points to, i.e. a load balancer
+// in front of the masters.
+// Once joined, the extra nodes are verified to be reported in the cluster status
+func (c *TestContext) ExpandWithAgentURL(current, extra []Gravity, p InstallParam, addr string) error {
+	if len(current) == 0 || len(extra) == 0 {
+		return trace.BadParameter("empty node list")
+	}
+	master := current[0]
+
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	status, err := master.Status(ctx)
+	if err != nil {
+		return trace.Wrap(err, "query status from [%v]", master)
+	}
+	joinURL, err := agentURL(status.Cluster, addr, p.Role)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	c.Logger().WithFields(logrus.Fields{
+		"current": current,
+		"extra":   extra,
+		"url":     joinURL,
+	}).Info("Expand with agent URL.")
+
+	ctx, cancel = context.WithTimeout(c.ctx, withDuration(c.timeouts.Join, len(extra)))
+	defer cancel()
+
+	for _, node := range extra {
+		c.Logger().WithField("node", node).Info("Join with agent URL.")
+		err = node.Join(ctx, JoinCmd{
+			AgentURL:      joinURL,
+			Role:          p.Role,
+			StateDir:      p.StateDir,
+			AddressFamily: p.AddressFamily,
+		})
+		if err != nil {
+			return trace.Wrap(err, "error joining cluster on node %s: %v", node.String(), err)
+		}
+	}
+
+	return trace.Wrap(c.waitForMembers(master, extra, p.AddressFamily))
+}
+
+// waitForMembers waits until the cluster status queried on master reports the given nodes
+func (c *TestContext) waitForMembers(master Gravity, nodes []Gravity, family string) error {
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	retry := wait.Retryer{
+		Attempts:    100,
+		Delay:       time.Second * 20,
+		FieldLogger: c.Logger(),
+	}
+	return trace.Wrap(retry.Do(ctx, func() error {
+		status, err := master.Status(ctx)
+		if err != nil {
+			return wait.Continue("status not available: %v", err)
+		}
+		members := make(map[string]struct{}, len(status.Cluster.Nodes))
+		for _, node := range status.Cluster.Nodes {
+			members[node.Addr] = struct{}{}
+		}
+		for _, node := range nodes {
+			addr, err := advertiseAddr(node.Node(), family)
+			if err != nil {
+				return wait.Abort(trace.Wrap(err))
+			}
+			if _, ok := members[addr]; !ok {
+				return wait.Continue("%v is not a cluster member yet", node)
+			}
+		}
+		return nil
+	}))
+}
+
+// agentURL returns the agent-token URL nodes with the given role join the cluster with.
+// The URL is based on the first cluster web endpoint in status, with the address
+// optionally replaced with addr (host:port)
+func agentURL(status ClusterStatus, addr, role string) (string, error) {
+	if len(status.Endpoints.Cluster.UI) == 0 {
+		return "", trace.NotFound("cluster web endpoint is not reported in the status of %v", status.Cluster)
+	}
+	u, err := url.Parse(status.Endpoints.Cluster.UI[0])
+	if err != nil {
+		return "", trace.Wrap(err, "invalid cluster web endpoint")
+	}
+	if addr != "" {
+		u.Host = addr
+	}
+	u.Path = path.Join("/t", status.Token.Token, role)
+	return u.String(), nil
+}
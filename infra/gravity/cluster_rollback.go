@@ -0,0 +1,110 @@
+package gravity
+
+import (
+	"context"
+
+	"github.com/gravitational/trace"
+)
+
+// UpgradeFault describes where and how to make an upgrade fail
+type UpgradeFault struct {
+	// Phases lists the phases of the upgrade plan to execute before the failure,
+	// i.e. ["/init", "/checks", "/pre-update", "/bootstrap"]
+	Phases []string `json:"phases" validate:"required,min=1"`
+	// Phase is the phase to fail
+	Phase string `json:"phase" validate:"required"`
+	// ScriptURL optionally specifies the fault injection script executed on all nodes
+	// before the phase. The phase is then expected to fail.
+	// Without the script, the upgrade is interrupted before the phase
+	ScriptURL string `json:"script_url,omitempty"`
+}
+
+// FailUpgrade starts the upgrade to the specified installer in manual mode and
+// executes the upgrade plan until the phase specified with fault fails.
+// The cluster is left with the failed upgrade operation, see Rollback
+func (c *TestContext) FailUpgrade(nodes []Gravity, installerURL, gravityURL, subdir string, fault UpgradeFault) error {
+	roles, err := c.NodesByRole(nodes)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	master := roles.ApiMaster
+	err = c.withEgress(nodes, func() error {
+		return c.uploadInstaller(master, roles.Other, installerURL, gravityURL, subdir)
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, withDuration(c.timeouts.Upgrade, len(nodes)))
+	defer cancel()
+
+	log := c.Logger().WithField("leader", master)
+	log.Info("Upgrade in manual mode.")
+	if err := master.UpgradeManual(ctx); err != nil {
+		return trace.Wrap(err)
+	}
+	for _, phase := range fault.Phases {
+		log.WithField("phase", phase).Info("Execute phase.")
+		if err := master.ExecutePhase(ctx, phase); err != nil {
+			return trace.Wrap(err, "phase %v failed before fault injection", phase)
+		}
+	}
+	if fault.ScriptURL == "" {
+		log.WithField("phase", fault.Phase).Info("Interrupt upgrade.")
+		return nil
+	}
+
+	log.WithField("script", fault.ScriptURL).Info("Inject fault.")
+	if err := c.ExecScript(nodes, fault.ScriptURL, nil); err != nil {
+		return trace.Wrap(err)
+	}
+	err = master.ExecutePhase(ctx, fault.Phase)
+	if err == nil {
+		return trace.CompareFailed("phase %v succeeded despite the injected fault", fault.Phase)
+	}
+	log.WithError(err).WithField("phase", fault.Phase).Info("Phase failed as expected.")
+	return nil
+}
+
+// Rollback rolls back the active operation (i.e. a failed upgrade) on the cluster
+func (c *TestContext) Rollback(nodes []Gravity) error {
+	defer c.enterPhase("rollback")()
+	roles, err := c.NodesByRole(nodes)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, withDuration(c.timeouts.Upgrade, len(nodes)))
+	defer cancel()
+
+	c.Logger().WithField("leader", roles.ApiMaster).Info("Rollback.")
+	return trace.Wrap(roles.ApiMaster.Rollback(ctx))
+}
+
+// AppVersion returns the version of the application installed on the cluster
+func (c *TestContext) AppVersion(nodes []Gravity) (string, error) {
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	status, err := nodes[0].Status(ctx)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	return status.Cluster.Application.Version, nil
+}
+
+// AssertAppVersion verifies that the cluster is active and runs
+// the expected version of the application
+func (c *TestContext) AssertAppVersion(nodes []Gravity, expected string) error {
+	if err := c.Status(nodes); err != nil {
+		return trace.Wrap(err)
+	}
+	version, err := c.AppVersion(nodes)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if version != expected {
+		return trace.CompareFailed("cluster runs version %v, expected %v", version, expected)
+	}
+	return nil
+}
@@ -45,6 +45,82 @@ func (c *TestContext) Status(nodes []Gravity) error {
 	return trace.Wrap(err)
 }
 
+// StatusOrError is the cluster status as reported by a node or the error querying it
+type StatusOrError struct {
+	// Status is the cluster status reported by the node
+	Status *GravityStatus
+	// Err is the error querying the status, i.e. if the node is unreachable
+	Err error
+}
+
+// StatusAll queries the cluster status on all nodes concurrently and returns
+// the status or the error for each node, so that the view of each side of a partitioned
+// cluster can be asserted. Each node is queried once and only given nodeStatusTimeout
+// to answer, degraded status is returned as-is
+func (c *TestContext) StatusAll(ctx context.Context, nodes []Gravity) map[Gravity]StatusOrError {
+	type result struct {
+		node Gravity
+		StatusOrError
+	}
+	results := make(chan result, len(nodes))
+	for _, node := range nodes {
+		go func(node Gravity) {
+			ctx, cancel := context.WithTimeout(ctx, nodeStatusTimeout)
+			defer cancel()
+			var status *GravityStatus
+			var err error
+			if g, ok := node.(*gravity); ok {
+				status, err = g.status(ctx)
+			} else {
+				status, err = node.Status(ctx)
+			}
+			results <- result{node: node, StatusOrError: StatusOrError{Status: status, Err: trace.Wrap(err)}}
+		}(node)
+	}
+	statuses := make(map[Gravity]StatusOrError, len(nodes))
+	for range nodes {
+		r := <-results
+		statuses[r.node] = r.StatusOrError
+		log := c.Logger().WithField("node", r.node)
+		if r.Err != nil {
+			log.WithError(r.Err).Info("Status not available.")
+			continue
+		}
+		log.WithField("status", r.Status.Cluster.Status).Info("Status.")
+	}
+	return statuses
+}
+
+// AssertNodeRoles verifies that the nodes have been installed with
+// the roles (node profiles) assigned by param, see InstallParam.RoleOf
+func (c *TestContext) AssertNodeRoles(nodes []Gravity, param InstallParam) error {
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	status, err := nodes[0].Status(ctx)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	profiles := make(map[string]string, len(status.Cluster.Nodes))
+	for _, node := range status.Cluster.Nodes {
+		profiles[node.Addr] = node.Profile
+	}
+	for i, node := range nodes {
+		addr, err := advertiseAddr(node.Node(), param.AddressFamily)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		profile, ok := profiles[addr]
+		if !ok {
+			return trace.NotFound("%v is not part of the cluster", node)
+		}
+		if expected := param.RoleOf(i); profile != expected {
+			return trace.CompareFailed("%v has role %v, expected %v", node, profile, expected)
+		}
+	}
+	return nil
+}
+
 // CheckTime walks around all nodes and checks whether their time is within acceptable limits
 func (c *TestContext) CheckTimeSync(nodes []Gravity) error {
 	timeNodes := []sshutils.SshNode{}
@@ -65,10 +141,14 @@ func (c *TestContext) CheckTimeSync(nodes []Gravity) error {
 // CollectLogs requests logs from all nodes.
 // prefix `postmortem` is reserved for cleanup procedure
 func (c *TestContext) CollectLogs(prefix string, nodes []Gravity) error {
+	return trace.Wrap(c.collectLogs(c.ctx, prefix, nodes))
+}
+
+func (c *TestContext) collectLogs(ctx context.Context, prefix string, nodes []Gravity) error {
 	if len(nodes) < 1 {
 		return nil
 	}
-	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.CollectLogs)
+	ctx, cancel := context.WithTimeout(ctx, c.timeouts.CollectLogs)
 	defer cancel()
 
 	nodes, err := c.reorderNodesForCollection(ctx, nodes)
@@ -94,25 +174,32 @@ func (c *TestContext) reorderNodesForCollection(ctx context.Context, nodes []Gra
 }
 
 func (c *TestContext) collectLogsFromNodes(ctx context.Context, nodes []Gravity, prefix string, firstNodeArgs, nodeArgs []string) error {
+	parallelism := c.provisionerConfig().LogCollection.Parallelism
+	if parallelism <= 0 {
+		parallelism = len(nodes)
+	}
+	sem := make(chan struct{}, parallelism)
 	errors := make(chan error, len(nodes))
-	go func(node Gravity) {
-		localPath, err := node.CollectLogs(ctx, prefix, firstNodeArgs...)
-		node.Logger().WithFields(log.Fields{
-			log.ErrorKey: err,
-			"path":       localPath,
-		}).Error("Fetching node logs.")
-		errors <- err
-	}(nodes[0])
-	for _, node := range nodes[1:] {
-		node := node
-		go func() {
-			localPath, err := node.CollectLogs(ctx, prefix, nodeArgs...)
+	for i, node := range nodes {
+		args := nodeArgs
+		if i == 0 {
+			args = firstNodeArgs
+		}
+		go func(node Gravity, args []string) {
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				errors <- trace.LimitExceeded("timed out waiting to collect logs from %v", node)
+				return
+			}
+			localPath, err := node.CollectLogs(ctx, prefix, args...)
 			node.Logger().WithFields(log.Fields{
 				log.ErrorKey: err,
 				"path":       localPath,
 			}).Error("Fetching node logs.")
 			errors <- err
-		}()
+		}(node, args)
 	}
 	return trace.Wrap(utils.CollectErrors(ctx, errors))
 }
@@ -133,7 +220,7 @@ type ClusterNodesByRole struct {
 
 // NodesByRole will conveniently organize nodes according to their roles in cluster
 func (c *TestContext) NodesByRole(nodes []Gravity) (roles *ClusterNodesByRole, err error) {
-	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.LeaderElection)
 	defer cancel()
 
 	roles = &ClusterNodesByRole{}
@@ -193,3 +280,6 @@ func apiserverNode(ctx context.Context, nodes []Gravity) (api Gravity, other []G
 
 	return api, nodes[1:], nil
 }
+
+// nodeStatusTimeout is the time a node is given to report its status with StatusAll
+const nodeStatusTimeout = time.Minute
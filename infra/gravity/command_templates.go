@@ -0,0 +1,192 @@
+package gravity
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/gravitational/robotest/lib/shell"
+
+	"github.com/gravitational/trace"
+	semver "github.com/hashicorp/go-version"
+)
+
+// CommandTemplateOverride overrides the templates of gravity commands
+// for a range of gravity versions, so that new or changed CLI flags
+// can be supported from configuration
+type CommandTemplateOverride struct {
+	// Versions specifies the constraint on the gravity version the override
+	// applies to, i.e. ">= 7.0, < 8.0"
+	Versions string `yaml:"versions" validate:"required"`
+	// Templates maps the command names (install, install_image, join, agent_join,
+	// uninstall, uninstall_app, app_install, check, rotate_certs) to text/template sources.
+	// See the built-in templates for the available fields
+	Templates map[string]string `yaml:"templates" validate:"required"`
+}
+
+// commandRegistry resolves the command templates for the gravity version
+type commandRegistry struct {
+	overrides []versionedTemplates
+}
+
+// versionedTemplates is a set of command templates for matching gravity versions
+type versionedTemplates struct {
+	versions  semver.Constraints
+	templates map[string]*template.Template
+}
+
+// newCommandRegistry returns a registry with the built-in templates and the given overrides.
+// Overrides are evaluated in order and the first override matching the version wins
+func newCommandRegistry(overrides []CommandTemplateOverride) (*commandRegistry, error) {
+	registry := &commandRegistry{}
+	for _, override := range overrides {
+		versions, err := semver.NewConstraint(override.Versions)
+		if err != nil {
+			return nil, trace.BadParameter("invalid version constraint %q: %v", override.Versions, err)
+		}
+		templates := make(map[string]*template.Template, len(override.Templates))
+		for name, source := range override.Templates {
+			if _, ok := builtinCommandTemplates[name]; !ok {
+				return nil, trace.BadParameter("unknown command %q, expected one of %v",
+					name, strings.Join(commandNames(), ", "))
+			}
+			templates[name], err = newCommandTemplate(name, source)
+			if err != nil {
+				return nil, trace.BadParameter("invalid %v command template for %v: %v",
+					name, override.Versions, err)
+			}
+		}
+		registry.overrides = append(registry.overrides, versionedTemplates{
+			versions:  versions,
+			templates: templates,
+		})
+	}
+	return registry, nil
+}
+
+// template returns the template of the command given with name for the gravity version v.
+// With unknown version, the built-in template is returned
+func (r *commandRegistry) template(name string, v *semver.Version) *template.Template {
+	if v != nil {
+		for _, override := range r.overrides {
+			if t, ok := override.templates[name]; ok && override.versions.Check(v) {
+				return t
+			}
+		}
+	}
+	return builtinCommandTemplates[name]
+}
+
+// renderCommand renders the command given with name for the gravity binary
+// in the current install directory
+func (g *gravity) renderCommand(ctx context.Context, name string, data interface{}) (string, error) {
+	var v *semver.Version
+	if version, err := g.Version(ctx); err == nil {
+		v, _ = version.Semver()
+	}
+	var buf bytes.Buffer
+	err := g.param.commandRegistry().template(name, v).Execute(&buf, data)
+	if err != nil {
+		return "", trace.Wrap(err, "failed to render %v command", name)
+	}
+	return buf.String(), nil
+}
+
+// commandRegistry returns the command template registry of this configuration
+func (config ProvisionerConfig) commandRegistry() *commandRegistry {
+	if config.commands != nil {
+		return config.commands
+	}
+	return defaultCommandRegistry
+}
+
+// newCommandTemplate parses the command template given with source.
+// Templates can use the quote function to quote values for the shell
+func newCommandTemplate(name, source string) (*template.Template, error) {
+	return template.New(name).Funcs(commandFuncs).Parse(source)
+}
+
+var commandFuncs = template.FuncMap{"quote": shell.Quote}
+
+func commandNames() (names []string) {
+	for name := range builtinCommandTemplates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+const (
+	installCommand      = "install"
+	installImageCommand = "install_image"
+	joinCommand         = "join"
+	agentJoinCommand    = "agent_join"
+	uninstallCommand    = "uninstall"
+	uninstallAppCommand = "uninstall_app"
+	appInstallCommand   = "app_install"
+	checkCommand        = "check"
+	rotateCertsCommand  = "rotate_certs"
+)
+
+var builtinCommandTemplates = map[string]*template.Template{
+	installCommand: template.Must(
+		newCommandTemplate(installCommand, `
+		cd {{quote .InstallDir}} && ./gravity version && sudo {{range $name, $value := .ExtraEnv}}{{$name}}={{quote $value}} {{end}}./gravity install --debug \
+		--advertise-addr={{quote .PrivateAddr}} --token="$GRAVITY_TOKEN" --flavor={{quote .Flavor}} \
+		{{if .DockerDevice}}--docker-device={{quote .DockerDevice}}{{end}} \
+		{{if .StorageDriver}}--storage-driver={{quote .StorageDriver}}{{end}} \
+		--system-log-file={{quote .AgentLogPath}} \
+		--cloud-provider=generic --state-dir={{quote .StateDir}} \
+		--httpprofile=localhost:6061 {{if .FIPS}}--fips{{end}} {{if .License}}--license="$GRAVITY_LICENSE"{{end}} \
+		{{if .Cluster}}--cluster={{quote .Cluster}}{{end}} \
+		{{if .OpsAdvertiseAddr}}--ops-advertise-addr={{quote .OpsAdvertiseAddr}}{{end}} \
+		{{range .ExtraArgs}}{{quote .}} {{end}}
+`)),
+	installImageCommand: template.Must(
+		newCommandTemplate(installImageCommand, `
+		cd {{quote .InstallDir}} && ./gravity version && sudo -E {{range $name, $value := .ExtraEnv}}{{$name}}={{quote $value}} {{end}}./gravity install --debug \
+		--image={{quote .Image}} \
+		{{if .Registry.Username}}--registry-username="$REGISTRY_USERNAME" --registry-password="$REGISTRY_PASSWORD"{{end}} \
+		{{if .Registry.Insecure}}--registry-insecure{{end}} \
+		--advertise-addr={{quote .PrivateAddr}} --token="$GRAVITY_TOKEN" --flavor={{quote .Flavor}} \
+		{{if .DockerDevice}}--docker-device={{quote .DockerDevice}}{{end}} \
+		{{if .StorageDriver}}--storage-driver={{quote .StorageDriver}}{{end}} \
+		--system-log-file={{quote .AgentLogPath}} \
+		--cloud-provider=generic --state-dir={{quote .StateDir}} \
+		--httpprofile=localhost:6061 {{if .FIPS}}--fips{{end}} {{if .License}}--license="$GRAVITY_LICENSE"{{end}} \
+		{{if .Cluster}}--cluster={{quote .Cluster}}{{end}} \
+		{{range .ExtraArgs}}{{quote .}} {{end}}
+`)),
+	joinCommand: template.Must(
+		newCommandTemplate(joinCommand, `
+		cd {{quote .InstallDir}} && sudo ./gravity join {{quote .PeerAddr}} \
+		--advertise-addr={{quote .PrivateAddr}} --token="$GRAVITY_TOKEN" --debug \
+		--role={{quote .Role}} {{if .DockerDevice}}--docker-device={{quote .DockerDevice}}{{end}} \
+		--system-log-file={{quote .AgentLogPath}} --state-dir={{quote .StateDir}} \
+		--httpprofile=localhost:6061{{if .FIPS}} --fips{{end}}`)),
+	agentJoinCommand: template.Must(
+		newCommandTemplate(agentJoinCommand,
+			`curl -s --tlsv1.2 --insecure {{quote .AgentURL}} | sudo bash`)),
+	uninstallCommand: template.Must(
+		newCommandTemplate(uninstallCommand,
+			`cd {{quote .InstallDir}} && sudo ./gravity system uninstall --confirm --system-log-file={{quote .AgentLogPath}}`)),
+	uninstallAppCommand: template.Must(
+		newCommandTemplate(uninstallAppCommand,
+			`cd {{quote .InstallDir}} && sudo ./gravity app uninstall $(./gravity app-package) --system-log-file={{quote .AgentLogPath}}`)),
+	appInstallCommand: template.Must(
+		newCommandTemplate(appInstallCommand, `
+		cd {{quote .InstallDir}} && sudo -E ./gravity app install {{quote .Image}} \
+		{{if .Registry.Username}}--registry-username="$REGISTRY_USERNAME" --registry-password="$REGISTRY_PASSWORD"{{end}} \
+		{{if .Registry.Insecure}}--registry-insecure{{end}} \
+		--system-log-file={{quote .AgentLogPath}}`)),
+	checkCommand: template.Must(
+		newCommandTemplate(checkCommand,
+			`cd {{quote .InstallDir}} && sudo ./gravity check --debug {{if .Profile}}--profile={{quote .Profile}}{{end}} app.yaml`)),
+	rotateCertsCommand: template.Must(
+		newCommandTemplate(rotateCertsCommand,
+			`cd {{quote .InstallDir}} && sudo ./gravity system rotate-certs {{quote .Cluster}} --debug {{if .Validity}}--validity={{quote .Validity}}{{end}} --system-log-file={{quote .AgentLogPath}}`)),
+}
+
+var defaultCommandRegistry = &commandRegistry{}
@@ -0,0 +1,147 @@
+package gravity
+
+import (
+	"bytes"
+	"testing"
+
+	semver "github.com/hashicorp/go-version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandRegistry(t *testing.T) {
+	registry, err := newCommandRegistry([]CommandTemplateOverride{
+		{
+			Versions:  ">= 7.0",
+			Templates: map[string]string{uninstallCommand: "cd {{.InstallDir}} && sudo ./gravity system uninstall --confirm"},
+		},
+		{
+			Versions:  ">= 6.0",
+			Templates: map[string]string{uninstallCommand: "uninstall-6", joinCommand: "join-6"},
+		},
+	})
+	require.NoError(t, err)
+
+	var testCases = []struct {
+		version  string
+		command  string
+		expected string
+	}{
+		{version: "7.0.1", command: uninstallCommand, expected: "cd /installer && sudo ./gravity system uninstall --confirm"},
+		{version: "6.3.2", command: uninstallCommand, expected: "uninstall-6"},
+		{version: "5.5.40", command: uninstallCommand, expected: "cd /installer && sudo ./gravity system uninstall --confirm --system-log-file=/var/log/gravity-system.log"},
+		{command: uninstallCommand, expected: "cd /installer && sudo ./gravity system uninstall --confirm --system-log-file=/var/log/gravity-system.log"},
+	}
+	params := commandParams{InstallDir: "/installer", AgentLogPath: "/var/log/gravity-system.log"}
+	for _, tc := range testCases {
+		var v *semver.Version
+		if tc.version != "" {
+			v = semver.Must(semver.NewVersion(tc.version))
+		}
+		var buf bytes.Buffer
+		require.NoError(t, registry.template(tc.command, v).Execute(&buf, params))
+		assert.Equal(t, tc.expected, buf.String(), tc.version)
+	}
+
+	_, err = newCommandRegistry([]CommandTemplateOverride{{Versions: ">= 7.0", Templates: map[string]string{"expand": "gravity expand"}}})
+	assert.Error(t, err, "unknown command")
+	_, err = newCommandRegistry([]CommandTemplateOverride{{Versions: "7.x", Templates: map[string]string{joinCommand: "gravity join"}}})
+	assert.Error(t, err, "invalid version constraint")
+}
+
+func TestInstallImageCommandHidesCredentials(t *testing.T) {
+	var buf bytes.Buffer
+	err := builtinCommandTemplates[installImageCommand].Execute(&buf, struct {
+		InstallDir, PrivateAddr, DockerDevice, StorageDriver, AgentLogPath string
+		License                                                            bool
+		Registry                                                           RegistryConfig
+		InstallParam
+	}{
+		InstallDir: "/installer",
+		Registry:   RegistryConfig{Username: "robotest", Password: "secret"},
+		InstallParam: InstallParam{
+			Image: "registry.example.com/telekube:7.0.12",
+		},
+	})
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), "--image=registry.example.com/telekube:7.0.12")
+	assert.Contains(t, buf.String(), `--registry-password="$REGISTRY_PASSWORD"`)
+	assert.NotContains(t, buf.String(), "secret")
+}
+
+func TestAgentJoinCommand(t *testing.T) {
+	var buf bytes.Buffer
+	err := builtinCommandTemplates[agentJoinCommand].Execute(&buf, JoinCmd{
+		AgentURL: "https://lb.example.com:3009/t/fac3b88014367fe4/worker",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `curl -s --tlsv1.2 --insecure https://lb.example.com:3009/t/fac3b88014367fe4/worker | sudo bash`, buf.String())
+
+	buf.Reset()
+	err = builtinCommandTemplates[agentJoinCommand].Execute(&buf, JoinCmd{AgentURL: "https://lb.example.com:3009/t/$(id)"})
+	require.NoError(t, err)
+	assert.Equal(t, `curl -s --tlsv1.2 --insecure 'https://lb.example.com:3009/t/$(id)' | sudo bash`, buf.String())
+}
+
+func TestCommandsQuoteValues(t *testing.T) {
+	var buf bytes.Buffer
+	err := builtinCommandTemplates[installCommand].Execute(&buf, struct {
+		InstallDir, PrivateAddr, DockerDevice, StorageDriver, AgentLogPath string
+		License                                                            bool
+		InstallParam
+	}{
+		InstallDir: "/home/robotest user/installer",
+		InstallParam: InstallParam{
+			Cluster:  "robotest; id",
+			Flavor:   "three $(id)",
+			StateDir: "/var/lib/gravity",
+		},
+	})
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), `cd '/home/robotest user/installer' && `)
+	assert.Contains(t, buf.String(), `--flavor='three $(id)'`)
+	assert.Contains(t, buf.String(), `--cluster='robotest; id'`)
+	assert.Contains(t, buf.String(), `--state-dir=/var/lib/gravity`)
+
+	buf.Reset()
+	err = builtinCommandTemplates[joinCommand].Execute(&buf, struct {
+		InstallDir, PrivateAddr, DockerDevice, AgentLogPath string
+		JoinCmd
+	}{
+		InstallDir: "/installer",
+		JoinCmd:    JoinCmd{PeerAddr: "10.0.0.1:3009 && id", Role: "node"},
+	})
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), `./gravity join '10.0.0.1:3009 && id' `)
+	assert.Contains(t, buf.String(), `--role=node `)
+	assert.NotContains(t, buf.String(), `--fips`)
+
+	buf.Reset()
+	err = builtinCommandTemplates[joinCommand].Execute(&buf, struct {
+		InstallDir, PrivateAddr, DockerDevice, AgentLogPath string
+		JoinCmd
+	}{
+		InstallDir: "/installer",
+		JoinCmd:    JoinCmd{PeerAddr: "10.0.0.1:3009", Role: "node", FIPS: true},
+	})
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), `--httpprofile=localhost:6061 --fips`)
+}
+
+func TestInstallCommandQuotesExtraArgs(t *testing.T) {
+	var buf bytes.Buffer
+	err := builtinCommandTemplates[installCommand].Execute(&buf, struct {
+		InstallDir, PrivateAddr, DockerDevice, StorageDriver, AgentLogPath string
+		License                                                            bool
+		InstallParam
+	}{
+		InstallDir: "/installer",
+		InstallParam: InstallParam{
+			ExtraArgs: []string{"--pod-network-mtu=1400", "--config=it's; rm -rf /"},
+			ExtraEnv:  map[string]string{"GRAVITY_PEER_CONNECT_TIMEOUT": "5m $(id)"},
+		},
+	})
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), `sudo GRAVITY_PEER_CONNECT_TIMEOUT='5m $(id)' ./gravity install`)
+	assert.Contains(t, buf.String(), `--pod-network-mtu=1400 '--config=it'\''s; rm -rf /'`)
+}
@@ -2,21 +2,30 @@ package gravity
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"hash/fnv"
 	"math/rand"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"testing"
 
+	"github.com/gravitational/robotest/infra"
 	"github.com/gravitational/robotest/infra/providers/aws"
 	"github.com/gravitational/robotest/infra/providers/azure"
 	"github.com/gravitational/robotest/infra/providers/gce"
 	"github.com/gravitational/robotest/infra/providers/ops"
+	"github.com/gravitational/robotest/infra/tele"
+	"github.com/gravitational/robotest/infra/terraform"
 	"github.com/gravitational/robotest/lib/constants"
+	"github.com/gravitational/robotest/lib/failure"
 
 	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/go-playground/validator.v9"
 	"gopkg.in/yaml.v2"
@@ -65,6 +74,14 @@ func (drv StorageDriver) Driver() string {
 	return string(drv)
 }
 
+// ArchURLs specifies the architecture-specific installer and gravity binary locations
+type ArchURLs struct {
+	// InstallerURL specifies the location of the installer tarball
+	InstallerURL string `yaml:"installer_url"`
+	// GravityURL specifies the location of the gravity binary
+	GravityURL string `yaml:"gravity_url"`
+}
+
 // ProvisionerConfig defines parameters required to provision hosts
 // CloudProvider, AWS, Azure, ScriptPath and InstallerURL
 type ProvisionerConfig struct {
@@ -78,17 +95,106 @@ type ProvisionerConfig struct {
 	GCE *gce.Config `yaml:"gce"`
 	// Ops defines Ops Center connection parameters
 	Ops *ops.Config `yaml:"ops"`
+	// OpsCenter optionally specifies the Ops Center to push cluster images to
+	// and connect clusters to for remote support
+	OpsCenter *OpsCenterConfig `yaml:"ops_center"`
 
 	// ScriptPath is the path to the terraform script or directory for provisioning
 	ScriptPath string `yaml:"script_path" validate:"required"`
 	// InstallerURL specifies the location of the installer tarball.
-	// Can either be a local path or S3 URL
-	InstallerURL string `yaml:"installer_url" validate:"required"`
+	// Can either be a local path or S3 URL.
+	// Required unless the installer is built, see Build
+	InstallerURL string `yaml:"installer_url"`
+	// Build optionally requests the installer to be built with tele from an
+	// application manifest before the tests start. The built installer replaces InstallerURL
+	Build *tele.BuildConfig `yaml:"build"`
 	// GravityURL specifies the location of the up-to-date gravity binary.
 	// Can either be a local path or S3 URL
 	GravityURL string `yaml:"gravity_url" validate:"required"`
 	// StateDir defines base directory where to keep state (i.e. terraform configs/vars)
 	StateDir string `yaml:"state_dir" validate:"required"`
+	// TerraformVars defines additional variables for the terraform script
+	// (i.e. instance type, disk size/type, zones) overriding the script defaults
+	TerraformVars map[string]interface{} `yaml:"terraform_vars"`
+	// TerraformBackend optionally specifies the remote backend for the terraform state.
+	// The state of each test is keyed by the test tag
+	TerraformBackend *terraform.Backend `yaml:"terraform_backend"`
+	// Arch specifies the CPU architecture of the nodes: amd64 (default) or arm64.
+	// arm64 is supported on AWS (Graviton) and GCE (T2A)
+	Arch string `yaml:"arch" validate:"omitempty,eq=amd64|eq=arm64"`
+	// ArchURLs optionally specifies the installer and gravity binary per architecture.
+	// The URLs for the selected architecture take precedence over InstallerURL and GravityURL
+	ArchURLs map[string]ArchURLs `yaml:"arch_urls"`
+	// Images optionally pins the image to provision the nodes with per OS (vendor:version),
+	// i.e. an AMI ID on AWS or {project}/{image} on GCE
+	Images map[string]string `yaml:"images"`
+	// ResolveImages enables resolution of the OS to the latest image in the cloud
+	// using the image catalog. Otherwise the images of the terraform script are used
+	ResolveImages bool `yaml:"resolve_images"`
+	// SELinux requests the nodes to run with SELinux in enforcing mode.
+	// Only supported on CentOS/RHEL
+	SELinux bool `yaml:"selinux"`
+	// Firewall requests the distribution firewall (firewalld or ufw)
+	// to be enabled on the nodes before install
+	Firewall bool `yaml:"firewall"`
+	// FIPS requests the nodes to boot with the kernel in FIPS mode.
+	// Only supported on CentOS/RHEL
+	FIPS bool `yaml:"fips"`
+	// FIPSInstallerURL optionally specifies the installer with FIPS gravity binaries
+	// to use instead of InstallerURL in FIPS mode
+	FIPSInstallerURL string `yaml:"fips_installer_url"`
+	// FIPSGravityURL optionally specifies the FIPS gravity binary
+	// to use instead of GravityURL in FIPS mode
+	FIPSGravityURL string `yaml:"fips_gravity_url"`
+	// Proxy requests an additional node running an HTTP(S) proxy
+	// the cluster nodes are configured to use
+	Proxy bool `yaml:"proxy"`
+	// AirGapped requests all outbound internet traffic from the nodes to be blocked
+	// except while transferring installers
+	AirGapped bool `yaml:"air_gapped"`
+	// BootstrapScript optionally specifies the path to a shell script that is merged
+	// into the VM bootstrap script (user-data) and run as root before the node
+	// is considered ready, i.e. to create partitions, set sysctls or enable FIPS
+	BootstrapScript string `yaml:"bootstrap_script"`
+	// ExtraDisks lists the additional block devices to attach to each node
+	// (i.e. dedicated etcd or docker devices).
+	// Device paths are available with infra.Node.Disks
+	ExtraDisks []infra.Disk `yaml:"extra_disks" validate:"dive"`
+	// IPv6 requests the nodes to be provisioned dual-stack with both IPv4 and IPv6 addresses.
+	// Only supported on AWS and GCE with IPv6-enabled subnets
+	IPv6 bool `yaml:"ipv6"`
+	// Subnets optionally specifies the number of subnets to spread the nodes over.
+	// Only supported on AWS and GCE
+	Subnets int `yaml:"subnets" validate:"gte=0"`
+	// ResourceTags defines the tagging policy for the provisioned cloud resources
+	ResourceTags ResourceTags `yaml:"resource_tags"`
+	// StreamLogs requests the journal and the gravity system log to be followed on all nodes
+	// for the lifetime of the test and written into per-node files in the state directory
+	StreamLogs bool `yaml:"stream_logs"`
+	// LogCollection optionally configures the collection of node logs
+	LogCollection LogCollectionConfig `yaml:"log_collection"`
+	// Timeouts optionally overrides the default operation timeouts,
+	// i.e. for slow environments
+	Timeouts OpTimeouts `yaml:"timeouts"`
+	// NodePrep optionally configures the preparation of the nodes before install,
+	// i.e. the state directory file system, kernel modules and sysctls
+	NodePrep *NodePrepConfig `yaml:"node_prep"`
+	// Registry optionally specifies the registry to install cluster images from
+	Registry *RegistryConfig `yaml:"registry"`
+	// Vault optionally specifies the Vault server to issue short-lived cloud credentials
+	// for the run from and to resolve vault:PATH#KEY secret references with
+	Vault *VaultConfig `yaml:"vault"`
+	// License optionally specifies the license to install the cluster with.
+	// Like the cloud credentials, it can reference a secret, i.e. file:/robotest/config/license.pem
+	License string `yaml:"license"`
+	// CommandTemplates optionally overrides the templates of gravity commands
+	// per range of gravity versions
+	CommandTemplates []CommandTemplateOverride `yaml:"command_templates" validate:"dive"`
+	// KnownIssues lists the acknowledged failures, each with the ticket tracking it and optionally
+	// an expiry date. Matching failures are reported as known issues and do not fail the run
+	KnownIssues []failure.KnownSignature `yaml:"known_issues"`
+	// Quarantine lists the quarantined tests, see WithQuarantine
+	Quarantine []Quarantine `yaml:"quarantine" validate:"dive"`
 
 	// Tag will group provisioned resources under for easy removal afterwards
 	tag string `validate:"required"`
@@ -101,28 +207,63 @@ type ProvisionerConfig struct {
 	// dockerDevice is a physical volume where Docker data would be stored
 	dockerDevice string `validate:"required"`
 	// clusterName is the name of the resulting robotest cluster
-	clusterName  string
+	clusterName string
+	// suite names the test suite the configuration is used with
+	suite        string
 	cloudRegions *cloudRegions
+	// commands resolves the command templates with CommandTemplates applied
+	commands *commandRegistry
+	// quarantine is the quarantine of the test the configuration is used with, if any
+	quarantine *Quarantine
 }
 
 // LoadConfig loads essential parameters from YAML
 func LoadConfig(t *testing.T, configBytes []byte) (cfg ProvisionerConfig) {
-	err := yaml.Unmarshal(configBytes, &cfg)
-	require.NoError(t, err, string(configBytes))
+	cfg, err := ParseConfig(configBytes)
+	require.NoError(t, err, "invalid provisioner configuration")
+	return cfg
+}
+
+// ParseConfig parses the provisioner configuration from YAML (or JSON).
+// References to environment variables in the form ${env:NAME} or ${env:NAME:-default}
+// are replaced with their values before parsing. Unknown fields are rejected
+// and the configuration is validated against the field constraints,
+// so that a misconfigured run fails before provisioning
+func ParseConfig(configBytes []byte) (cfg ProvisionerConfig, err error) {
+	configBytes, err = interpolateEnv(configBytes)
+	if err != nil {
+		return cfg, trace.Wrap(err)
+	}
+	err = yaml.UnmarshalStrict(configBytes, &cfg)
+	if err != nil {
+		return cfg, trace.BadParameter("failed to parse configuration: %v", err)
+	}
+	err = resolveSecrets(&cfg)
+	if err != nil {
+		return cfg, trace.Wrap(err)
+	}
 
 	switch cfg.CloudProvider {
 	case constants.Azure:
-		require.NotNil(t, cfg.Azure)
+		if cfg.Azure == nil {
+			return cfg, trace.BadParameter("azure configuration is required")
+		}
 		cfg.dockerDevice = cfg.Azure.DockerDevice
 		cfg.cloudRegions = newCloudRegions(strings.Split(cfg.Azure.Location, ","))
 	case constants.AWS:
-		require.NotNil(t, cfg.AWS)
+		if cfg.AWS == nil {
+			return cfg, trace.BadParameter("aws configuration is required")
+		}
 		cfg.dockerDevice = cfg.AWS.DockerDevice
 	case constants.GCE:
-		require.NotNil(t, cfg.GCE)
+		if cfg.GCE == nil {
+			return cfg, trace.BadParameter("gce configuration is required")
+		}
 		cfg.cloudRegions = newCloudRegions(strings.Split(cfg.GCE.Region, ","))
 	case constants.Ops:
-		require.NotNil(t, cfg.Ops)
+		if cfg.Ops == nil {
+			return cfg, trace.BadParameter("ops configuration is required")
+		}
 		// set AWS environment variables to be used by subsequent commands
 		os.Setenv("AWS_ACCESS_KEY_ID", cfg.Ops.EC2AccessKey)
 		os.Setenv("AWS_SECRET_ACCESS_KEY", cfg.Ops.EC2SecretKey)
@@ -132,9 +273,79 @@ func LoadConfig(t *testing.T, configBytes []byte) (cfg ProvisionerConfig) {
 		// on the installation directory
 		cfg.dockerDevice = "/var/lib/gravity"
 	default:
-		t.Fatalf("unknown cloud provider %s", cfg.CloudProvider)
+		return cfg, trace.BadParameter("unknown cloud provider %q", cfg.CloudProvider)
 	}
-	return cfg
+
+	cfg.commands, err = newCommandRegistry(cfg.CommandTemplates)
+	if err != nil {
+		return cfg, trace.Wrap(err)
+	}
+
+	if _, err := failure.NewSignatureDB(cfg.KnownIssues); err != nil {
+		return cfg, trace.Wrap(err, "invalid known_issues")
+	}
+	for _, known := range cfg.KnownIssues {
+		if !known.Acknowledged() {
+			return cfg, trace.BadParameter("known issue %q needs the ticket tracking the fix", known.Description)
+		}
+	}
+
+	if cfg.InstallerURL == "" && cfg.Build == nil {
+		return cfg, trace.BadParameter("installer_url is required unless the installer is built with tele")
+	}
+
+	// Node count is set per test
+	except := []string{"NodeCount"}
+	if cfg.Vault != nil && cfg.Vault.AWSRole != "" {
+		// AWS credentials are issued by Vault, see NewCredentialsProvider
+		except = append(except, "AWS.AccessKey", "AWS.SecretKey")
+	}
+	err = validator.New().StructExcept(&cfg, except...)
+	if err != nil {
+		return cfg, trace.BadParameter("invalid configuration:\n%v", formatValidationErrors(err))
+	}
+	return cfg, nil
+}
+
+// interpolateEnv replaces references to environment variables in data with their values.
+// ${env:NAME} requires the variable to be set, ${env:NAME:-default} falls back to default.
+// Other ${...} sequences (i.e. shell variables in command templates) are left intact
+func interpolateEnv(data []byte) ([]byte, error) {
+	var missing []string
+	out := envRef.ReplaceAllFunc(data, func(ref []byte) []byte {
+		match := envRef.FindSubmatch(ref)
+		name := string(match[1])
+		if value, ok := os.LookupEnv(name); ok {
+			return []byte(value)
+		}
+		if len(match[2]) != 0 {
+			return match[3]
+		}
+		missing = append(missing, name)
+		return ref
+	})
+	if len(missing) != 0 {
+		return nil, trace.BadParameter("environment variables referenced in configuration are not set: %v",
+			strings.Join(missing, ", "))
+	}
+	return out, nil
+}
+
+var envRef = regexp.MustCompile(`\$\{env:([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// BuildInstaller builds the installer if requested in the configuration
+// and returns the configuration with the installer URL pointing to the built installer
+func BuildInstaller(ctx context.Context, config ProvisionerConfig, log logrus.FieldLogger) (ProvisionerConfig, error) {
+	if config.Build == nil {
+		return config, nil
+	}
+	err := tele.Build(ctx, *config.Build, log)
+	if err != nil {
+		return config, trace.Wrap(err)
+	}
+	cfg := config
+	cfg.InstallerURL = config.Build.Output
+	return cfg, nil
 }
 
 // Tag returns the configured tag.
@@ -156,6 +367,14 @@ func (config ProvisionerConfig) WithTag(tag string) ProvisionerConfig {
 	return cfg
 }
 
+// WithSuite returns copy of config for the specified test suite.
+// The suite name is attached to the provisioned resources
+func (config ProvisionerConfig) WithSuite(suite string) ProvisionerConfig {
+	cfg := config
+	cfg.suite = suite
+	return cfg
+}
+
 // WithNodes returns copy of config with specific number of nodes
 func (config ProvisionerConfig) WithNodes(nodes uint) ProvisionerConfig {
 	extra := fmt.Sprintf("%dn", nodes)
@@ -193,6 +412,196 @@ func (config ProvisionerConfig) WithStorageDriver(storageDriver StorageDriver) P
 	return cfg
 }
 
+// WithTerraformVars returns copy of config with the specified terraform variables
+// merged on top of the configured ones.
+// The tag is extended with a short digest of the variables so that
+// the variants of the same test do not collide
+func (config ProvisionerConfig) WithTerraformVars(vars map[string]interface{}) ProvisionerConfig {
+	cfg := config
+	if len(vars) == 0 {
+		return cfg
+	}
+	cfg.TerraformVars = make(map[string]interface{}, len(config.TerraformVars)+len(vars))
+	for name, value := range config.TerraformVars {
+		cfg.TerraformVars[name] = value
+	}
+	for name, value := range vars {
+		cfg.TerraformVars[name] = value
+	}
+
+	tag := fmt.Sprintf("tf%s", varsDigest(vars))
+	cfg.tag = fmt.Sprintf("%s-%s", cfg.tag, tag)
+	cfg.StateDir = filepath.Join(cfg.StateDir, tag)
+
+	return cfg
+}
+
+// WithArch returns copy of config for nodes with the specified CPU architecture.
+// Installer and gravity URLs are replaced with those configured for the architecture
+func (config ProvisionerConfig) WithArch(arch string) ProvisionerConfig {
+	cfg := config
+	if arch == "" {
+		return cfg
+	}
+	cfg.Arch = arch
+	if urls, ok := config.ArchURLs[arch]; ok {
+		if urls.InstallerURL != "" {
+			cfg.InstallerURL = urls.InstallerURL
+		}
+		if urls.GravityURL != "" {
+			cfg.GravityURL = urls.GravityURL
+		}
+	}
+
+	cfg.tag = fmt.Sprintf("%s-%s", cfg.tag, arch)
+	cfg.StateDir = filepath.Join(cfg.StateDir, arch)
+
+	return cfg
+}
+
+// WithSELinux returns copy of config with SELinux enforcing mode requested on the nodes
+func (config ProvisionerConfig) WithSELinux(enforcing bool) ProvisionerConfig {
+	cfg := config
+	if !enforcing {
+		return cfg
+	}
+	cfg.SELinux = true
+	cfg.tag = fmt.Sprintf("%s-selinux", cfg.tag)
+	cfg.StateDir = filepath.Join(cfg.StateDir, "selinux")
+
+	return cfg
+}
+
+// WithFIPS returns copy of config with FIPS mode requested on the nodes.
+// The FIPS installer and gravity binary replace the regular ones if configured
+func (config ProvisionerConfig) WithFIPS(enabled bool) ProvisionerConfig {
+	cfg := config
+	if !enabled || cfg.FIPS {
+		return cfg
+	}
+	cfg.FIPS = true
+	if cfg.FIPSInstallerURL != "" {
+		cfg.InstallerURL = cfg.FIPSInstallerURL
+	}
+	if cfg.FIPSGravityURL != "" {
+		cfg.GravityURL = cfg.FIPSGravityURL
+	}
+	cfg.tag = fmt.Sprintf("%s-fips", cfg.tag)
+	cfg.StateDir = filepath.Join(cfg.StateDir, "fips")
+
+	return cfg
+}
+
+// WithFirewall returns copy of config with the distribution firewall enabled on the nodes
+func (config ProvisionerConfig) WithFirewall(enabled bool) ProvisionerConfig {
+	cfg := config
+	if !enabled {
+		return cfg
+	}
+	cfg.Firewall = true
+	cfg.tag = fmt.Sprintf("%s-fw", cfg.tag)
+	cfg.StateDir = filepath.Join(cfg.StateDir, "fw")
+
+	return cfg
+}
+
+// WithProxy returns copy of config with the nodes configured to use an HTTP(S) proxy
+func (config ProvisionerConfig) WithProxy(enabled bool) ProvisionerConfig {
+	cfg := config
+	if !enabled {
+		return cfg
+	}
+	cfg.Proxy = true
+	cfg.tag = fmt.Sprintf("%s-proxy", cfg.tag)
+	cfg.StateDir = filepath.Join(cfg.StateDir, "proxy")
+
+	return cfg
+}
+
+// WithAirGapped returns copy of config with internet access blocked on the nodes
+func (config ProvisionerConfig) WithAirGapped(enabled bool) ProvisionerConfig {
+	cfg := config
+	if !enabled {
+		return cfg
+	}
+	cfg.AirGapped = true
+	cfg.tag = fmt.Sprintf("%s-airgap", cfg.tag)
+	cfg.StateDir = filepath.Join(cfg.StateDir, "airgap")
+
+	return cfg
+}
+
+// WithExtraDisks returns copy of config with the specified additional disks
+// replacing the configured ones
+func (config ProvisionerConfig) WithExtraDisks(disks []infra.Disk) ProvisionerConfig {
+	cfg := config
+	if len(disks) == 0 {
+		return cfg
+	}
+	cfg.ExtraDisks = disks
+
+	tag := fmt.Sprintf("%dd", len(disks))
+	cfg.tag = fmt.Sprintf("%s-%s", cfg.tag, tag)
+	cfg.StateDir = filepath.Join(cfg.StateDir, tag)
+
+	return cfg
+}
+
+// WithSubnets returns copy of config with the nodes spread over the specified number of subnets
+func (config ProvisionerConfig) WithSubnets(subnets int) ProvisionerConfig {
+	cfg := config
+	if subnets < 2 {
+		return cfg
+	}
+	cfg.Subnets = subnets
+
+	tag := fmt.Sprintf("%dsn", subnets)
+	cfg.tag = fmt.Sprintf("%s-%s", cfg.tag, tag)
+	cfg.StateDir = filepath.Join(cfg.StateDir, tag)
+
+	return cfg
+}
+
+// WithIPv6 returns copy of config with the nodes provisioned dual-stack
+func (config ProvisionerConfig) WithIPv6(enabled bool) ProvisionerConfig {
+	cfg := config
+	if !enabled {
+		return cfg
+	}
+	cfg.IPv6 = true
+	cfg.tag = fmt.Sprintf("%s-ipv6", cfg.tag)
+	cfg.StateDir = filepath.Join(cfg.StateDir, "ipv6")
+
+	return cfg
+}
+
+// awsInstanceType returns the EC2 instance type for the configuration.
+// The default instance type for arm64 is a Graviton2 instance,
+// otherwise the terraform script default is used
+func (config ProvisionerConfig) awsInstanceType() string {
+	if config.AWS != nil && config.AWS.InstanceType != "" {
+		return config.AWS.InstanceType
+	}
+	if config.Arch == constants.ArchARM64 {
+		return "m6g.xlarge"
+	}
+	return "c3.xlarge"
+}
+
+// varsDigest computes a short stable digest of the specified variables
+func varsDigest(vars map[string]interface{}) string {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	h := fnv.New32a()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s=%v;", name, vars[name])
+	}
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
 // validateConfig checks that key parameters are present
 func validateConfig(config ProvisionerConfig) error {
 	switch config.CloudProvider {
@@ -205,14 +614,21 @@ func validateConfig(config ProvisionerConfig) error {
 	if err == nil {
 		return nil
 	}
+	return formatValidationErrors(err)
+}
 
+// formatValidationErrors converts the validation error into an aggregate
+// of errors for each failed field
+func formatValidationErrors(err error) error {
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return trace.Wrap(err)
+	}
 	var errs []error
-	if validationErrors, ok := err.(validator.ValidationErrors); ok {
-		for _, fieldError := range validationErrors {
-			errs = append(errs,
-				trace.BadParameter(` * %s="%v" fails "%s"`,
-					fieldError.Field(), fieldError.Value(), fieldError.Tag()))
-		}
+	for _, fieldError := range validationErrors {
+		errs = append(errs,
+			trace.BadParameter(` * %s="%v" fails "%s"`,
+				fieldError.Namespace(), fieldError.Value(), fieldError.Tag()))
 	}
 	return trace.NewAggregate(errs...)
 }
@@ -245,3 +661,13 @@ type cloudRegions struct {
 	idx     int
 	regions []string
 }
+
+// LogCollectionConfig configures the collection of node logs (gravity system report)
+type LogCollectionConfig struct {
+	// Parallelism limits the number of nodes to collect logs from concurrently.
+	// Defaults to all nodes
+	Parallelism int `yaml:"parallelism" validate:"gte=0"`
+	// Exclude lists the name patterns (shell globs) of the report entries to drop
+	// on the node before the report is downloaded, i.e. huge journal exports
+	Exclude []string `yaml:"exclude"`
+}
@@ -0,0 +1,109 @@
+package gravity
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gravitational/robotest/lib/constants"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithArch(t *testing.T) {
+	cfg := ProvisionerConfig{
+		CloudProvider: constants.AWS,
+		InstallerURL:  "s3://builds/installer-amd64.tar",
+		GravityURL:    "s3://builds/gravity-amd64",
+		StateDir:      "/state",
+		ArchURLs: map[string]ArchURLs{
+			constants.ArchARM64: {InstallerURL: "s3://builds/installer-arm64.tar"},
+		},
+	}.WithTag("install")
+
+	arm := cfg.WithArch(constants.ArchARM64)
+	assert.Equal(t, "install-arm64", arm.Tag())
+	assert.Equal(t, "/state/install/arm64", arm.StateDir)
+	assert.Equal(t, "s3://builds/installer-arm64.tar", arm.InstallerURL)
+	assert.Equal(t, "s3://builds/gravity-amd64", arm.GravityURL)
+	assert.Equal(t, "m6g.xlarge", arm.awsInstanceType())
+
+	assert.Equal(t, cfg, cfg.WithArch(""))
+	assert.Equal(t, "c3.xlarge", cfg.awsInstanceType())
+}
+
+func TestWithQuarantine(t *testing.T) {
+	cfg := ProvisionerConfig{Quarantine: []Quarantine{
+		{Suite: "sanity", Test: "upgrade*", Reason: "flaky upgrade", Ticket: "GRAV-1"},
+		{Suite: "ui", Reason: "broken UI"},
+	}}
+
+	assert.Equal(t, "GRAV-1", cfg.WithSuite("sanity").WithQuarantine("upgrade3").Quarantined().Ticket)
+	assert.Nil(t, cfg.WithSuite("sanity").WithQuarantine("install").Quarantined())
+	assert.Nil(t, cfg.WithSuite("stress").WithQuarantine("upgrade").Quarantined())
+	assert.Equal(t, "broken UI", cfg.WithSuite("ui").WithQuarantine("install").Quarantined().Reason)
+}
+
+func TestParseConfig(t *testing.T) {
+	os.Setenv("ROBOTEST_TEST_ACCESS_KEY", "key")
+	defer os.Unsetenv("ROBOTEST_TEST_ACCESS_KEY")
+	const config = `
+cloud: aws
+script_path: /robotest/terraform/aws
+installer_url: ${env:ROBOTEST_TEST_INSTALLER_URL:-s3://builds/installer.tar}
+gravity_url: s3://builds/gravity
+state_dir: /tmp/state
+aws:
+  access_key: ${env:ROBOTEST_TEST_ACCESS_KEY}
+  secret_key: secret
+  region: us-east-1
+  key_pair: ops
+  vpc: vpc-1
+  ssh_user: centos
+  key_path: /robotest/key
+  docker_device: /dev/xvdb
+`
+	cfg, err := ParseConfig([]byte(config))
+	require.NoError(t, err)
+	assert.Equal(t, "s3://builds/installer.tar", cfg.InstallerURL)
+	assert.Equal(t, "key", cfg.AWS.AccessKey)
+
+	cfg, err = ParseConfig([]byte(config + "terraform_vars:\n  bootstrap: echo ${HOME}\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "echo ${HOME}", cfg.TerraformVars["bootstrap"], "only env: references are interpolated")
+
+	_, err = ParseConfig([]byte(config + "unknown_field: true\n"))
+	assert.Error(t, err, "unknown field")
+
+	_, err = ParseConfig([]byte(strings.Replace(config, "${env:ROBOTEST_TEST_ACCESS_KEY}", "${env:ROBOTEST_TEST_UNSET}", 1)))
+	assert.Error(t, err, "unset variable")
+
+	_, err = ParseConfig([]byte(strings.Replace(config, "region: us-east-1", "", 1)))
+	assert.Error(t, err, "missing required field")
+
+	noInstaller := strings.Replace(config, "installer_url: ${env:ROBOTEST_TEST_INSTALLER_URL:-s3://builds/installer.tar}\n", "", 1)
+	_, err = ParseConfig([]byte(noInstaller))
+	assert.Error(t, err, "missing installer")
+
+	cfg, err = ParseConfig([]byte(noInstaller + "build:\n  manifest: /src/app.yaml\n  output: /tmp/state/installer.tar\n"))
+	require.NoError(t, err, "installer built with tele")
+	assert.Equal(t, "", cfg.InstallerURL)
+
+	_, err = ParseConfig([]byte(config + "known_issues:\n  - pattern: \"timed out (\"\n    description: slow nodes\n"))
+	assert.Error(t, err, "invalid known issue")
+
+	_, err = ParseConfig([]byte(config + "known_issues:\n  - pattern: \"timed out\"\n    description: slow nodes\n"))
+	assert.Error(t, err, "known issue without ticket")
+
+	_, err = ParseConfig([]byte(config + "known_issues:\n  - pattern: \"timed out\"\n    description: slow nodes\n    ticket: GRAV-1\n"))
+	assert.NoError(t, err)
+
+	noCredentials := strings.NewReplacer("  access_key: ${env:ROBOTEST_TEST_ACCESS_KEY}\n", "", "  secret_key: secret\n", "").Replace(config)
+	_, err = ParseConfig([]byte(noCredentials))
+	assert.Error(t, err, "missing credentials")
+
+	cfg, err = ParseConfig([]byte(noCredentials + "vault:\n  address: https://vault.example.com:8200\n  token: s.robotest\n  aws_role: robotest\n"))
+	require.NoError(t, err, "credentials issued by vault")
+	assert.Equal(t, "", cfg.AWS.AccessKey)
+}
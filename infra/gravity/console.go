@@ -0,0 +1,92 @@
+package gravity
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gravitational/robotest/infra/providers/aws"
+	"github.com/gravitational/robotest/infra/providers/gce"
+	"github.com/gravitational/robotest/lib/constants"
+	"github.com/gravitational/robotest/lib/utils"
+
+	"github.com/gravitational/trace"
+)
+
+// CollectConsoleOutput fetches the serial console output of the nodes from the cloud provider
+// into console/prefix/<node IP>.log in the state directory.
+// Unlike the logs collected over SSH, the console output covers kernel panics and boot failures.
+// Only supported on AWS and GCE
+func (c *TestContext) CollectConsoleOutput(prefix string, nodes []Gravity) error {
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.CollectLogs)
+	defer cancel()
+
+	errs := make(chan error, len(nodes))
+	for _, node := range nodes {
+		go func(node Gravity) {
+			c.Logger().WithField("node", node).Info("Fetch serial console output.")
+			errs <- trace.Wrap(c.collectConsoleOutput(ctx, prefix, node), "failed to fetch console output of %v", node)
+		}(node)
+	}
+	return trace.Wrap(utils.CollectErrors(ctx, errs))
+}
+
+// CollectUnreachableConsoleOutput fetches the serial console output
+// of the nodes that are powered off or cannot be reached over SSH
+func (c *TestContext) CollectUnreachableConsoleOutput(prefix string, nodes []Gravity) error {
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.CollectLogs)
+	defer cancel()
+
+	var unreachable []Gravity
+	for _, node := range nodes {
+		if !isReachable(ctx, node) {
+			unreachable = append(unreachable, node)
+		}
+	}
+	if len(unreachable) == 0 {
+		return nil
+	}
+	return trace.Wrap(c.CollectConsoleOutput(prefix, unreachable))
+}
+
+func (c *TestContext) collectConsoleOutput(ctx context.Context, prefix string, node Gravity) error {
+	var out string
+	var err error
+	privateIP := node.Node().PrivateAddr()
+	switch {
+	case c.provisionerConfig().CloudProvider == constants.AWS && c.provisionerConfig().AWS != nil:
+		out, err = aws.ConsoleOutput(ctx, c.provisionerConfig().awsCluster(), privateIP)
+	case c.provisionerConfig().CloudProvider == constants.GCE && c.provisionerConfig().GCE != nil:
+		out, err = gce.ConsoleOutput(ctx, c.provisionerConfig().gceCluster(), privateIP)
+	default:
+		return trace.NotImplemented("console output is not supported on %v", c.provisionerConfig().CloudProvider)
+	}
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	path := filepath.Join(c.provisionerConfig().StateDir, "console", prefix, fmt.Sprintf("%v.log", privateIP))
+	if err := os.MkdirAll(filepath.Dir(path), constants.SharedDirMask); err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	return trace.ConvertSystemError(ioutil.WriteFile(path, []byte(out), constants.SharedReadMask))
+}
+
+// isReachable determines whether the node can be reached over SSH
+func isReachable(ctx context.Context, node Gravity) bool {
+	if node.Offline() {
+		return false
+	}
+	g, ok := node.(*gravity)
+	if !ok {
+		return true
+	}
+	ctx, cancel := context.WithTimeout(ctx, reachabilityTimeout)
+	defer cancel()
+	return g.run(ctx, g.Logger(), "true", nil) == nil
+}
+
+// reachabilityTimeout limits the time to wait for the node to respond over SSH
+const reachabilityTimeout = 30 * time.Second
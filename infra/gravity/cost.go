@@ -0,0 +1,115 @@
+package gravity
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gravitational/robotest/infra/cost"
+	"github.com/gravitational/robotest/lib/constants"
+
+	"github.com/gravitational/trace"
+)
+
+// allocation records the infrastructure provisioned for a test
+type allocation struct {
+	usage   cost.Usage
+	created time.Time
+	// destroyed is zero until the infrastructure has been destroyed
+	destroyed time.Time
+}
+
+// recordAllocation accounts for the infrastructure provisioned with the specified configuration
+func (c *TestContext) recordAllocation(cfg ProvisionerConfig) *allocation {
+	alloc := &allocation{
+		usage:   resourceUsage(cfg),
+		created: time.Now(),
+	}
+	c.allocations = append(c.allocations, alloc)
+	return alloc
+}
+
+// EstimatedCost returns the estimated cloud cost of the infrastructure provisioned
+// by this test so far.
+// Infrastructure that has not been destroyed is accounted for until now
+func (c *TestContext) EstimatedCost() (estimate cost.Estimate, usage []cost.Usage) {
+	now := time.Now()
+	for _, alloc := range c.allocations {
+		u := alloc.usage
+		u.Duration = now.Sub(alloc.created)
+		if !alloc.destroyed.IsZero() {
+			u.Duration = alloc.destroyed.Sub(alloc.created)
+		}
+		usage = append(usage, u)
+		result, err := cost.Compute(u)
+		if err != nil {
+			c.Logger().WithError(err).Debug("Failed to estimate cost.")
+			continue
+		}
+		estimate = estimate.Add(*result)
+	}
+	return estimate, usage
+}
+
+// resourceUsage describes the resources provisioned with the specified configuration.
+// Terraform variables take precedence over the provider configuration
+// and the defaults mirror those of the terraform scripts
+func resourceUsage(cfg ProvisionerConfig) cost.Usage {
+	usage := cost.Usage{
+		Cloud: cfg.CloudProvider,
+		Nodes: int(cfg.NodeCount),
+	}
+	switch cfg.CloudProvider {
+	case constants.AWS:
+		usage.InstanceType = cfg.awsInstanceType()
+		usage.DiskGB = intVar(cfg.TerraformVars, "root_volume_size", 60) +
+			intVar(cfg.TerraformVars, "docker_volume_size", 80) +
+			intVar(cfg.TerraformVars, "etcd_volume_size", 30)
+	case constants.GCE:
+		if cfg.GCE != nil {
+			usage.InstanceType = cfg.GCE.VMType
+		}
+		usage.DiskGB = 64 + 50
+		usage.Preemptible = stringVar(cfg.TerraformVars, "preemptible", "true") == "true"
+	case constants.Azure:
+		if cfg.Azure != nil {
+			usage.InstanceType = cfg.Azure.VmType
+		}
+		usage.DiskGB = 3 * 64
+	}
+	for _, disk := range cfg.ExtraDisks {
+		usage.DiskGB += disk.SizeGB
+	}
+	usage.InstanceType = stringVar(cfg.TerraformVars, "instance_type", usage.InstanceType)
+	usage.InstanceType = stringVar(cfg.TerraformVars, "vm_type", usage.InstanceType)
+	return usage
+}
+
+func stringVar(vars map[string]interface{}, name, defaultValue string) string {
+	value, ok := vars[name]
+	if !ok {
+		return defaultValue
+	}
+	return fmt.Sprint(value)
+}
+
+func intVar(vars map[string]interface{}, name string, defaultValue int) int {
+	value, err := strconv.Atoi(stringVar(vars, name, strconv.Itoa(defaultValue)))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// destroyWith returns the destroy handler that records the time
+// the infrastructure has been destroyed
+func (r *allocation) destroyWith(destroy func(context.Context) error) func(context.Context) error {
+	return func(ctx context.Context) error {
+		err := destroy(ctx)
+		if err == nil && r.destroyed.IsZero() {
+			r.destroyed = time.Now()
+		}
+		return trace.Wrap(err)
+	}
+}
@@ -0,0 +1,251 @@
+package gravity
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gravitational/robotest/lib/constants"
+	"github.com/gravitational/robotest/lib/secret"
+	"github.com/gravitational/robotest/lib/vault"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// VaultConfig specifies the Vault server to issue the cloud credentials
+// for a run from and to resolve vault: secret references with
+type VaultConfig struct {
+	// Address is the address of the Vault server, i.e. https://vault.example.com:8200
+	Address string `yaml:"address" validate:"required"`
+	// Token is the token to authenticate with.
+	// Usually a secret reference, i.e. env:VAULT_TOKEN
+	Token string `yaml:"token" validate:"required"`
+	// Namespace optionally specifies the Vault Enterprise namespace
+	Namespace string `yaml:"namespace"`
+	// AWSRole optionally names the role of the AWS secrets engine
+	// to issue the AWS credentials with
+	AWSRole string `yaml:"aws_role"`
+	// AWSMount specifies the mount path of the AWS secrets engine. Defaults to aws
+	AWSMount string `yaml:"aws_mount"`
+	// GCPRoleset optionally names the roleset of the Google Cloud secrets engine
+	// to issue the GCE service account key with
+	GCPRoleset string `yaml:"gcp_roleset"`
+	// GCPMount specifies the mount path of the Google Cloud secrets engine. Defaults to gcp
+	GCPMount string `yaml:"gcp_mount"`
+	// TTL optionally requests the time to live of the issued credentials
+	TTL time.Duration `yaml:"ttl"`
+}
+
+// CredentialsProvider issues the cloud credentials for a run
+type CredentialsProvider interface {
+	// Issue returns copy of config with the issued cloud credentials
+	Issue(ctx context.Context, config ProvisionerConfig) (ProvisionerConfig, error)
+	// Revoke revokes the credentials issued so far
+	Revoke(ctx context.Context) error
+}
+
+// NewCredentialsProvider returns the credentials provider for the configuration.
+// Without Vault configured, the configured credentials are used as-is
+func NewCredentialsProvider(config ProvisionerConfig, log logrus.FieldLogger) (CredentialsProvider, error) {
+	if config.Vault == nil {
+		return staticCredentials{}, nil
+	}
+	client, err := config.Vault.client()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &vaultCredentials{
+		config: *config.Vault,
+		client: client,
+		log:    log.WithField("vault", config.Vault.Address),
+	}, nil
+}
+
+// staticCredentials uses the credentials from the configuration
+type staticCredentials struct{}
+
+// Issue returns config unchanged
+func (staticCredentials) Issue(ctx context.Context, config ProvisionerConfig) (ProvisionerConfig, error) {
+	return config, nil
+}
+
+// Revoke is a no-op
+func (staticCredentials) Revoke(context.Context) error {
+	return nil
+}
+
+// vaultCredentials issues short-lived credentials from the Vault secrets engines
+type vaultCredentials struct {
+	config VaultConfig
+	client *vault.Client
+	log    logrus.FieldLogger
+
+	mu     sync.Mutex
+	leases []string
+}
+
+// Issue returns copy of config with the credentials of the configured cloud
+// replaced with the credentials issued by Vault
+func (r *vaultCredentials) Issue(ctx context.Context, config ProvisionerConfig) (ProvisionerConfig, error) {
+	cfg := config
+	switch {
+	case cfg.CloudProvider == constants.AWS && r.config.AWSRole != "":
+		aws := *cfg.AWS
+		path := fmt.Sprintf("%v/creds/%v", defaultString(r.config.AWSMount, "aws"), r.config.AWSRole)
+		s, err := r.issue(ctx, path, nil)
+		if err != nil {
+			return cfg, trace.Wrap(err)
+		}
+		if aws.AccessKey, err = s.String("access_key"); err != nil {
+			return cfg, trace.Wrap(err)
+		}
+		if aws.SecretKey, err = s.String("secret_key"); err != nil {
+			return cfg, trace.Wrap(err)
+		}
+		// STS credentials (assumed_role and federation_token) are only valid with the session token
+		aws.SessionToken, _ = s.String("security_token")
+		secret.Register(aws.AccessKey, aws.SecretKey, aws.SessionToken)
+		cfg.AWS = &aws
+	case cfg.CloudProvider == constants.GCE && r.config.GCPRoleset != "":
+		gce := *cfg.GCE
+		path := fmt.Sprintf("%v/roleset/%v/key", defaultString(r.config.GCPMount, "gcp"), r.config.GCPRoleset)
+		s, err := r.issue(ctx, path, map[string]interface{}{})
+		if err != nil {
+			return cfg, trace.Wrap(err)
+		}
+		encoded, err := s.String("private_key_data")
+		if err != nil {
+			return cfg, trace.Wrap(err)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return cfg, trace.Wrap(err, "invalid service account key")
+		}
+		secret.Register(string(key))
+		gce.Credentials = filepath.Join(cfg.StateDir, "gce-credentials.json")
+		if err := os.MkdirAll(cfg.StateDir, constants.SharedDirMask); err != nil {
+			return cfg, trace.ConvertSystemError(err)
+		}
+		if err := ioutil.WriteFile(gce.Credentials, key, 0600); err != nil {
+			return cfg, trace.ConvertSystemError(err)
+		}
+		cfg.GCE = &gce
+	default:
+		r.log.WithField("cloud", cfg.CloudProvider).Info("No credentials to issue.")
+	}
+	return cfg, nil
+}
+
+// Revoke revokes the leases of the issued credentials
+func (r *vaultCredentials) Revoke(ctx context.Context) error {
+	r.mu.Lock()
+	leases := r.leases
+	r.leases = nil
+	r.mu.Unlock()
+	var errors []error
+	for _, lease := range leases {
+		if err := r.client.Revoke(ctx, lease); err != nil {
+			errors = append(errors, trace.Wrap(err, "failed to revoke lease %v", lease))
+			continue
+		}
+		r.log.WithField("lease", lease).Info("Revoked credentials.")
+	}
+	return trace.NewAggregate(errors...)
+}
+
+// issue reads the dynamic credentials at path and records the lease.
+// With data, the credentials are requested with a write (i.e. to pass the TTL)
+func (r *vaultCredentials) issue(ctx context.Context, path string, data map[string]interface{}) (*vault.Secret, error) {
+	var s *vault.Secret
+	var err error
+	if data == nil && r.config.TTL == 0 {
+		s, err = r.client.Read(ctx, path)
+	} else {
+		if data == nil {
+			data = make(map[string]interface{})
+		}
+		if r.config.TTL != 0 {
+			data["ttl"] = r.config.TTL.String()
+		}
+		s, err = r.client.Write(ctx, path, data)
+	}
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if s == nil {
+		return nil, trace.NotFound("no credentials returned from %v", path)
+	}
+	if s.LeaseID != "" {
+		r.mu.Lock()
+		r.leases = append(r.leases, s.LeaseID)
+		r.mu.Unlock()
+	}
+	r.log.WithFields(logrus.Fields{
+		"path":  path,
+		"lease": s.LeaseID,
+		"ttl":   time.Duration(s.LeaseDuration) * time.Second,
+	}).Info("Issued credentials.")
+	return s, nil
+}
+
+// client returns the Vault client for this configuration.
+// The token is resolved as a secret reference
+func (r VaultConfig) client() (*vault.Client, error) {
+	token, err := secret.Resolve(r.Token)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return vault.New(vault.Config{
+		Address:   r.Address,
+		Token:     token,
+		Namespace: r.Namespace,
+	})
+}
+
+// registerVaultResolver enables vault:PATH#KEY secret references that read
+// the field KEY of the secret at PATH, i.e. vault:secret/data/robotest#license.
+// Both KV version 1 and 2 secrets are supported
+func registerVaultResolver(config VaultConfig) error {
+	client, err := config.client()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	secret.RegisterResolver("vault", func(ref string) (string, error) {
+		i := strings.LastIndex(ref, "#")
+		if i < 0 {
+			return "", trace.BadParameter("expected vault:PATH#KEY, got vault:%v", ref)
+		}
+		path, key := ref[:i], ref[i+1:]
+		ctx, cancel := context.WithTimeout(context.Background(), vaultResolveTimeout)
+		defer cancel()
+		s, err := client.Read(ctx, path)
+		if err != nil {
+			return "", trace.Wrap(err)
+		}
+		if s == nil {
+			return "", trace.NotFound("no secret at %v", path)
+		}
+		if data, ok := s.Data["data"].(map[string]interface{}); ok {
+			// KV version 2 nests the secret data
+			s = &vault.Secret{Data: data}
+		}
+		return s.String(key)
+	})
+	return nil
+}
+
+func defaultString(value, defaultValue string) string {
+	if value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+const vaultResolveTimeout = time.Minute
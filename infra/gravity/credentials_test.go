@@ -0,0 +1,110 @@
+package gravity
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gravitational/robotest/infra/providers/aws"
+	"github.com/gravitational/robotest/infra/providers/gce"
+	"github.com/gravitational/robotest/lib/constants"
+	"github.com/gravitational/robotest/lib/secret"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVaultCredentials(t *testing.T) {
+	var revoked []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.robotest" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/aws/creds/robotest":
+			w.Write([]byte(`{"lease_id":"aws/creds/robotest/a1b2","lease_duration":3600,` +
+				`"data":{"access_key":"AKIAVAULT","secret_key":"vault-secret-key","security_token":"vault-session-token"}}`))
+		case "/v1/secret/data/robotest":
+			w.Write([]byte(`{"data":{"data":{"license":"vault-license"}}}`))
+		case "/v1/sys/leases/revoke":
+			var req map[string]string
+			json.NewDecoder(r.Body).Decode(&req)
+			revoked = append(revoked, req["lease_id"])
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	vault := &VaultConfig{Address: server.URL, Token: "s.robotest", AWSRole: "robotest"}
+	config := ProvisionerConfig{
+		CloudProvider: constants.AWS,
+		AWS:           &aws.Config{AccessKey: "static", SecretKey: "static"},
+		Vault:         vault,
+		License:       "vault:secret/data/robotest#license",
+	}
+	require.NoError(t, resolveSecrets(&config))
+	assert.Equal(t, "vault-license", config.License)
+
+	provider, err := NewCredentialsProvider(config, logrus.StandardLogger())
+	require.NoError(t, err)
+	issued, err := provider.Issue(context.Background(), config)
+	require.NoError(t, err)
+	assert.Equal(t, "AKIAVAULT", issued.AWS.AccessKey)
+	assert.Equal(t, "vault-secret-key", issued.AWS.SecretKey)
+	assert.Equal(t, "vault-session-token", issued.AWS.SessionToken)
+	assert.Equal(t, "static", config.AWS.AccessKey, "original configuration is unchanged")
+	assert.Equal(t, "key="+secret.Mask, secret.Redact("key=vault-secret-key"))
+
+	require.NoError(t, provider.Revoke(context.Background()))
+	assert.Equal(t, []string{"aws/creds/robotest/a1b2"}, revoked)
+}
+
+func TestVaultGCECredentials(t *testing.T) {
+	const key = `{"type":"service_account","private_key":"vault-private-key"}`
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if r.URL.Path != "/v1/gcp/roleset/robotest/key" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"lease_id":"gcp/key/robotest/c3d4","lease_duration":3600,` +
+			`"data":{"private_key_data":"` + base64.StdEncoding.EncodeToString([]byte(key)) + `"}}`))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "robotest-credentials")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	config := ProvisionerConfig{
+		CloudProvider: constants.GCE,
+		GCE:           &gce.Config{Credentials: "/robotest/static.json"},
+		Vault:         &VaultConfig{Address: server.URL, Token: "s.robotest", GCPRoleset: "robotest"},
+		StateDir:      filepath.Join(dir, "state"),
+	}
+	provider, err := NewCredentialsProvider(config, logrus.StandardLogger())
+	require.NoError(t, err)
+	issued, err := provider.Issue(context.Background(), config)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"PUT /v1/gcp/roleset/robotest/key"}, requests)
+	assert.Equal(t, "/robotest/static.json", config.GCE.Credentials, "original configuration is unchanged")
+
+	fi, err := os.Stat(issued.GCE.Credentials)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+	data, err := ioutil.ReadFile(issued.GCE.Credentials)
+	require.NoError(t, err)
+	assert.Equal(t, key, string(data))
+	assert.Equal(t, "key="+secret.Mask, secret.Redact("key="+key))
+}
@@ -0,0 +1,78 @@
+package gravity
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	sshutils "github.com/gravitational/robotest/lib/ssh"
+)
+
+// DebugHandler returns the HTTP handler exposing the state of the running tests:
+//
+//	GET /progress - the status and the last completed step of every test
+//	GET /sessions - the active SSH commands
+//	GET /logs?test=<name> - the recent journal lines of every node of the test
+//	POST /collect-logs?test=<name> - collect logs from the nodes of the test now
+func (s *testSuite) DebugHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/progress", s.tracker)
+	mux.HandleFunc("/sessions", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, sshutils.ActiveSessions())
+	})
+	mux.HandleFunc("/logs", func(w http.ResponseWriter, r *http.Request) {
+		test := s.findTest(r.URL.Query().Get("test"))
+		if test == nil {
+			http.Error(w, "no such test", http.StatusNotFound)
+			return
+		}
+		logs := make(map[string][]string)
+		for _, node := range test.provisionedNodes() {
+			if g, ok := node.(*gravity); ok {
+				logs[g.Node().PrivateAddr()] = g.recentLogLines()
+			}
+		}
+		writeJSON(w, logs)
+	})
+	mux.HandleFunc("/collect-logs", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "use POST", http.StatusMethodNotAllowed)
+			return
+		}
+		test := s.findTest(r.URL.Query().Get("test"))
+		if test == nil {
+			http.Error(w, "no such test", http.StatusNotFound)
+			return
+		}
+		prefix := fmt.Sprintf("debug-%v", time.Now().UTC().Format("20060102-150405"))
+		go func() {
+			err := test.CollectLogs(prefix, test.provisionedNodes())
+			test.Maybe("collect logs on request", err)
+		}()
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintf(w, "collecting logs into node-logs/%v\n", prefix)
+	})
+	return mux
+}
+
+// findTest returns the test with the specified name or nil
+func (s *testSuite) findTest(name string) *TestContext {
+	s.RLock()
+	defer s.RUnlock()
+	for _, test := range s.tests {
+		if test.name == name {
+			return test
+		}
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
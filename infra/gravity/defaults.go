@@ -2,11 +2,17 @@ package gravity
 
 import (
 	"time"
+
+	"github.com/gravitational/robotest/lib/defaults"
 )
 
 // default timeout to wait for cloud-init to complete
 var cloudInitTimeout = time.Minute * 30
 
+// default timeout to provision an additional VM into the cluster infrastructure
+// and wait for it to be ready to use
+var addNodeTimeout = time.Minute * 45
+
 // default timeout to wait for clocks to synchronize between nodes
 var clockSyncTimeout = time.Minute * 15
 
@@ -15,7 +21,7 @@ var diskWaitTimeout = time.Minute * 10
 
 const (
 	deadlineSSH = time.Minute * 5 // abort if we can't get it within this reasonable period
-	// retrySSH defines the frequency of SSH connect attempts
+	// retrySSH defines the maximum interval between SSH connect attempts
 	retrySSH = 5 * time.Second
 
 	autoscaleRetries = 20               // total number of attempts when checking autoscale changes
@@ -23,16 +29,24 @@ const (
 
 	// minimum required disk speed (10MB/s)
 	minDiskSpeed = uint64(1e7)
+
+	// drainTimeout limits the time to evict the pods from a node with kubectl drain
+	drainTimeout = 10 * time.Minute
 )
 
 var DefaultTimeouts = OpTimeouts{
-	Install:          time.Minute * 15, // install threshold per node
-	Upgrade:          time.Minute * 30, // upgrade threshold per node
-	Uninstall:        time.Minute * 5,  // uninstall threshold per node
-	UninstallApp:     time.Minute * 5,  // application uninstall threshold
-	Status:           time.Minute * 30, // sufficient for failover procedures
-	Leave:            time.Minute * 15, // threshold to leave cluster
-	CollectLogs:      time.Minute * 7,  // to collect logs from node
-	WaitForInstaller: time.Minute * 30, // wait for build to complete in parallel
-	AutoScaling:      time.Minute * 10, // wait for autoscaling operation
+	Install:          defaults.InstallTimeout,        // install threshold per node
+	Join:             defaults.JoinTimeout,           // join threshold per node
+	Upgrade:          defaults.UpgradeTimeout,        // upgrade threshold per node
+	Uninstall:        time.Minute * 5,                // uninstall threshold per node
+	UninstallApp:     time.Minute * 5,                // application uninstall threshold
+	Status:           defaults.StatusTimeout,         // sufficient for failover procedures
+	LeaderElection:   defaults.LeaderElectionTimeout, // wait for a new leader
+	Leave:            time.Minute * 15,               // threshold to leave cluster
+	CollectLogs:      time.Minute * 7,                // to collect logs from node
+	WaitForInstaller: time.Minute * 30,               // wait for build to complete in parallel
+	AutoScaling:      time.Minute * 10,               // wait for autoscaling operation
+	Backup:           time.Minute * 20,               // backup or restore application data
+	Teardown:         defaults.TeardownTimeout,       // destroy the infrastructure
+	Power:            time.Minute * 10,               // power a VM on or off and reconnect
 }
@@ -0,0 +1,583 @@
+// Package fake provides an in-memory implementation of gravity.Gravity.
+//
+// Fake nodes simulate the cluster membership changes of the gravity commands
+// (install, join, leave, remove) without provisioning any infrastructure.
+// Individual methods can be configured to take time or to fail on demand,
+// which allows the orchestration logic of the test suites to be exercised
+// in regular unit tests.
+package fake
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gravitational/robotest/infra"
+	"github.com/gravitational/robotest/infra/gravity"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+)
+
+// Method names a method of the gravity.Gravity interface
+// for latency and failure injection
+type Method string
+
+const (
+	SetInstaller  Method = "SetInstaller"
+	TransferFile  Method = "TransferFile"
+	ExecScript    Method = "ExecScript"
+	Install       Method = "Install"
+	Status        Method = "Status"
+	OfflineUpdate Method = "OfflineUpdate"
+	Join          Method = "Join"
+	Leave         Method = "Leave"
+	Remove        Method = "Remove"
+	Uninstall     Method = "Uninstall"
+	UninstallApp  Method = "UninstallApp"
+	PowerOff      Method = "PowerOff"
+	Reboot        Method = "Reboot"
+	CollectLogs   Method = "CollectLogs"
+	Upload        Method = "Upload"
+	Upgrade       Method = "Upgrade"
+	RunInPlanet   Method = "RunInPlanet"
+	Backup        Method = "Backup"
+	Restore       Method = "Restore"
+	UpgradeManual Method = "UpgradeManual"
+	ExecutePhase  Method = "ExecutePhase"
+	Rollback      Method = "Rollback"
+	ResumePlan    Method = "ResumePlan"
+	Version       Method = "Version"
+	IsLeader      Method = "IsLeader"
+	Drain         Method = "Drain"
+	Uncordon      Method = "Uncordon"
+)
+
+// DefaultVersion is the gravity version reported by fake nodes by default
+var DefaultVersion = gravity.Version{Edition: "open-source", Version: "5.5.0"}
+
+// Always specifies that an injected failure never expires
+const Always = -1
+
+// NewCluster returns a new empty cluster fake nodes can be installed into
+func NewCluster(name string) *Cluster {
+	return &Cluster{
+		name:     name,
+		members:  make(map[string]struct{}),
+		cordoned: make(map[string]struct{}),
+	}
+}
+
+// Cluster is the shared in-memory state of the cluster
+// the fake nodes are operating on
+type Cluster struct {
+	mu        sync.Mutex
+	name      string
+	app       string
+	token     string
+	installed bool
+	upgrades  int
+	members   map[string]struct{}
+	// cordoned lists the drained members
+	cordoned map[string]struct{}
+	// order maintains the join order of the members
+	order []string
+	// leader is the current leader, the oldest member unless set with SetLeader
+	leader string
+}
+
+// Members returns the private addresses of the nodes currently
+// in the cluster in the order they have joined
+func (r *Cluster) Members() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.order...)
+}
+
+// SetLeader makes the member given with addr the cluster leader, i.e. to simulate a failover
+func (r *Cluster) SetLeader(addr string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.members[addr]; !ok {
+		return trace.NotFound("node %v is not a cluster member", addr)
+	}
+	r.leader = addr
+	return nil
+}
+
+// Cordoned returns true if the member given with addr has been drained
+func (r *Cluster) Cordoned(addr string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.cordoned[addr]
+	return ok
+}
+
+// Upgrades returns the number of completed upgrade operations
+func (r *Cluster) Upgrades() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.upgrades
+}
+
+func (r *Cluster) install(addr string, param gravity.InstallParam) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.installed {
+		return trace.AlreadyExists("cluster %v is already installed", r.name)
+	}
+	if param.Cluster != "" {
+		r.name = param.Cluster
+	}
+	r.app = "telekube"
+	r.token = param.Token
+	if r.token == "" {
+		r.token = fmt.Sprintf("%v-token", r.name)
+	}
+	r.installed = true
+	r.addLocked(addr)
+	return nil
+}
+
+func (r *Cluster) join(addr string, param gravity.JoinCmd) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.installed {
+		return trace.NotFound("cluster %v is not installed", r.name)
+	}
+	if _, ok := r.members[param.PeerAddr]; !ok {
+		return trace.ConnectionProblem(nil, "peer %v is not a cluster member", param.PeerAddr)
+	}
+	if param.Token != r.token {
+		return trace.AccessDenied("invalid join token %q", param.Token)
+	}
+	if _, ok := r.members[addr]; ok {
+		return trace.AlreadyExists("node %v is already a cluster member", addr)
+	}
+	r.addLocked(addr)
+	return nil
+}
+
+func (r *Cluster) remove(addr string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.members[addr]; !ok {
+		return trace.NotFound("node %v is not a cluster member", addr)
+	}
+	delete(r.members, addr)
+	delete(r.cordoned, addr)
+	for i, member := range r.order {
+		if member == addr {
+			r.order = append(r.order[:i], r.order[i+1:]...)
+			break
+		}
+	}
+	if r.leader == addr {
+		r.leader = ""
+		if len(r.order) != 0 {
+			r.leader = r.order[0]
+		}
+	}
+	return nil
+}
+
+func (r *Cluster) isMember(addr string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.members[addr]
+	return ok
+}
+
+func (r *Cluster) upgrade() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.upgrades++
+}
+
+func (r *Cluster) status() *gravity.GravityStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	status := &gravity.GravityStatus{
+		Cluster: gravity.ClusterStatus{
+			Application: gravity.Application{Name: r.app},
+			Cluster:     r.name,
+			Status:      "active",
+			Token:       gravity.Token{Token: r.token},
+		},
+	}
+	for i, addr := range r.order {
+		// like gravity, the first three nodes become masters
+		role := "node"
+		if i < 3 {
+			role = "master"
+		}
+		status.Cluster.Nodes = append(status.Cluster.Nodes, gravity.NodeStatus{Addr: addr, Role: role})
+	}
+	return status
+}
+
+func (r *Cluster) setCordoned(addr string, cordoned bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.members[addr]; !ok {
+		return trace.NotFound("node %v is not a cluster member", addr)
+	}
+	if cordoned {
+		r.cordoned[addr] = struct{}{}
+	} else {
+		delete(r.cordoned, addr)
+	}
+	return nil
+}
+
+func (r *Cluster) isLeader(addr string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.leader == addr
+}
+
+func (r *Cluster) addLocked(addr string) {
+	r.members[addr] = struct{}{}
+	r.order = append(r.order, addr)
+	if r.leader == "" {
+		r.leader = addr
+	}
+}
+
+// New returns a new fake node with the specified addresses
+// operating on the given cluster
+func New(cluster *Cluster, publicAddr, privateAddr string) *Node {
+	return &Node{
+		cluster:  cluster,
+		node:     node{addr: publicAddr, privateAddr: privateAddr},
+		latency:  make(map[Method]time.Duration),
+		failures: make(map[Method]*failure),
+		calls:    make(map[Method]int),
+		log: logrus.WithFields(logrus.Fields{
+			"node": privateAddr,
+			"fake": true,
+		}),
+	}
+}
+
+// Node is a fake gravity node.
+// Implements gravity.Gravity
+type Node struct {
+	// PlanetCommand optionally handles the commands executed with RunInPlanet.
+	// If unspecified, RunInPlanet returns an empty output
+	PlanetCommand func(cmd string, args ...string) (string, error)
+	// BinaryVersion optionally specifies the version Version reports.
+	// Defaults to DefaultVersion
+	BinaryVersion *gravity.Version
+
+	cluster  *Cluster
+	node     node
+	log      logrus.FieldLogger
+	mu       sync.Mutex
+	latency  map[Method]time.Duration
+	failures map[Method]*failure
+	calls    map[Method]int
+	offline  bool
+}
+
+// SetLatency configures the time method takes to complete
+func (g *Node) SetLatency(method Method, d time.Duration) *Node {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.latency[method] = d
+	return g
+}
+
+// InjectFailure configures method to fail with err for the next times invocations.
+// Use Always to have the method fail on every invocation
+func (g *Node) InjectFailure(method Method, err error, times int) *Node {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.failures[method] = &failure{err: err, times: times}
+	return g
+}
+
+// Calls returns the number of times method has been invoked
+func (g *Node) Calls(method Method) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.calls[method]
+}
+
+// MarshalJSON implements json.Marshaler
+func (g *Node) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{
+		"public_ip": g.node.Addr(),
+		"ip":        g.node.PrivateAddr(),
+	})
+}
+
+// String returns public and private addresses of the node
+func (g *Node) String() string {
+	return fmt.Sprintf("fake(private_addr=%s, public_addr=%s)",
+		g.node.PrivateAddr(), g.node.Addr())
+}
+
+func (g *Node) SetInstaller(ctx context.Context, installerURL, subdir string) error {
+	return g.call(ctx, SetInstaller)
+}
+
+func (g *Node) TransferFile(ctx context.Context, url, subdir string) error {
+	return g.call(ctx, TransferFile)
+}
+
+func (g *Node) ExecScript(ctx context.Context, scriptURL string, args []string) error {
+	return g.call(ctx, ExecScript)
+}
+
+func (g *Node) Install(ctx context.Context, param gravity.InstallParam) error {
+	if err := g.call(ctx, Install); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(g.cluster.install(g.node.PrivateAddr(), param))
+}
+
+func (g *Node) Status(ctx context.Context) (*gravity.GravityStatus, error) {
+	if err := g.call(ctx, Status); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if !g.cluster.isMember(g.node.PrivateAddr()) {
+		return nil, trace.NotFound("node %v is not a cluster member", g)
+	}
+	return g.cluster.status(), nil
+}
+
+func (g *Node) OfflineUpdate(ctx context.Context, installerURL string) error {
+	return g.call(ctx, OfflineUpdate)
+}
+
+func (g *Node) Join(ctx context.Context, param gravity.JoinCmd) error {
+	if err := g.call(ctx, Join); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(g.cluster.join(g.node.PrivateAddr(), param))
+}
+
+func (g *Node) Leave(ctx context.Context, graceful gravity.Graceful) error {
+	if err := g.call(ctx, Leave); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(g.cluster.remove(g.node.PrivateAddr()))
+}
+
+func (g *Node) Remove(ctx context.Context, node string, graceful gravity.Graceful) error {
+	if err := g.call(ctx, Remove); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(g.cluster.remove(node))
+}
+
+func (g *Node) Uninstall(ctx context.Context) error {
+	return g.call(ctx, Uninstall)
+}
+
+func (g *Node) UninstallApp(ctx context.Context) error {
+	return g.call(ctx, UninstallApp)
+}
+
+func (g *Node) PowerOff(ctx context.Context, graceful gravity.Graceful) error {
+	if err := g.call(ctx, PowerOff); err != nil {
+		return trace.Wrap(err)
+	}
+	g.mu.Lock()
+	g.offline = true
+	g.mu.Unlock()
+	return nil
+}
+
+func (g *Node) Reboot(ctx context.Context, graceful gravity.Graceful) error {
+	return g.call(ctx, Reboot)
+}
+
+func (g *Node) CollectLogs(ctx context.Context, prefix string, args ...string) (localPath string, err error) {
+	if err := g.call(ctx, CollectLogs); err != nil {
+		return "", trace.Wrap(err)
+	}
+	return filepath.Join("node-logs", prefix, fmt.Sprintf("%v-logs.tgz", g.node.PrivateAddr())), nil
+}
+
+func (g *Node) Upload(ctx context.Context) error {
+	return g.call(ctx, Upload)
+}
+
+func (g *Node) Upgrade(ctx context.Context) error {
+	if err := g.call(ctx, Upgrade); err != nil {
+		return trace.Wrap(err)
+	}
+	g.cluster.upgrade()
+	return nil
+}
+
+func (g *Node) UpgradeManual(ctx context.Context) error {
+	return g.call(ctx, UpgradeManual)
+}
+
+func (g *Node) ExecutePhase(ctx context.Context, phase string) error {
+	return g.call(ctx, ExecutePhase)
+}
+
+func (g *Node) Rollback(ctx context.Context) error {
+	return g.call(ctx, Rollback)
+}
+
+func (g *Node) ResumePlan(ctx context.Context) error {
+	return g.call(ctx, ResumePlan)
+}
+
+func (g *Node) Backup(ctx context.Context, outputPath string) error {
+	return g.call(ctx, Backup)
+}
+
+func (g *Node) Restore(ctx context.Context, backupPath string) error {
+	return g.call(ctx, Restore)
+}
+
+func (g *Node) Version(ctx context.Context) (*gravity.Version, error) {
+	if err := g.call(ctx, Version); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if g.BinaryVersion != nil {
+		return g.BinaryVersion, nil
+	}
+	return &DefaultVersion, nil
+}
+
+func (g *Node) RunInPlanet(ctx context.Context, cmd string, args ...string) (string, error) {
+	if err := g.call(ctx, RunInPlanet); err != nil {
+		return "", trace.Wrap(err)
+	}
+	if g.PlanetCommand == nil {
+		return "", nil
+	}
+	return g.PlanetCommand(cmd, args...)
+}
+
+func (g *Node) IsLeader(ctx context.Context) (bool, error) {
+	if err := g.call(ctx, IsLeader); err != nil {
+		return false, trace.Wrap(err)
+	}
+	if !g.cluster.isMember(g.node.PrivateAddr()) {
+		return false, trace.NotFound("node %v is not a cluster member", g)
+	}
+	return g.cluster.isLeader(g.node.PrivateAddr()), nil
+}
+
+func (g *Node) Drain(ctx context.Context) error {
+	if err := g.call(ctx, Drain); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(g.cluster.setCordoned(g.node.PrivateAddr(), true))
+}
+
+func (g *Node) Uncordon(ctx context.Context) error {
+	if err := g.call(ctx, Uncordon); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(g.cluster.setCordoned(g.node.PrivateAddr(), false))
+}
+
+// Node returns the fake VM instance
+func (g *Node) Node() infra.Node {
+	return g.node
+}
+
+// Offline returns true if node was previously powered off
+func (g *Node) Offline() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.offline
+}
+
+// Client returns nil as fake nodes are not backed by an SSH connection
+func (g *Node) Client() *ssh.Client {
+	return nil
+}
+
+func (g *Node) Logger() logrus.FieldLogger {
+	return g.log
+}
+
+// call accounts for an invocation of method and simulates
+// the configured latency and failure
+func (g *Node) call(ctx context.Context, method Method) error {
+	g.mu.Lock()
+	g.calls[method]++
+	latency := g.latency[method]
+	offline := g.offline
+	var err error
+	if f, ok := g.failures[method]; ok && f.times != 0 {
+		err = f.err
+		if f.times > 0 {
+			f.times--
+		}
+	}
+	g.mu.Unlock()
+
+	if offline {
+		return trace.ConnectionProblem(nil, "node %v is offline", g)
+	}
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-ctx.Done():
+			return trace.Wrap(ctx.Err())
+		}
+	}
+	if err != nil {
+		g.log.WithError(err).Debugf("Inject failure into %v.", method)
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(ctx.Err())
+}
+
+type failure struct {
+	err   error
+	times int
+}
+
+// node is a fake VM instance.
+// Implements infra.Node
+type node struct {
+	addr, privateAddr string
+}
+
+func (r node) String() string {
+	return fmt.Sprintf("node(addr=%v)", r.addr)
+}
+
+func (r node) Addr() string {
+	return r.addr
+}
+
+func (r node) PrivateAddr() string {
+	return r.privateAddr
+}
+
+func (r node) Connect() (*ssh.Session, error) {
+	return nil, trace.NotImplemented("fake nodes do not support SSH")
+}
+
+func (r node) Client() (*ssh.Client, error) {
+	return nil, trace.NotImplemented("fake nodes do not support SSH")
+}
+
+func (r node) Disks() []infra.Disk {
+	return nil
+}
+
+func (r node) Subnet() string {
+	return ""
+}
+
+func (r node) PrivateAddrIPv6() string {
+	return ""
+}
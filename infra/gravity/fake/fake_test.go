@@ -0,0 +1,318 @@
+package fake
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gravitational/robotest/infra/gravity"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandShrink(t *testing.T) {
+	cluster := NewCluster("fake")
+	nodes := []gravity.Gravity{
+		New(cluster, "1.1.1.1", "10.0.0.1"),
+		New(cluster, "1.1.1.2", "10.0.0.2"),
+		New(cluster, "1.1.1.3", "10.0.0.3"),
+	}
+	c := newTestContext()
+
+	require.NoError(t, nodes[0].Install(c.Context(), gravity.InstallParam{Token: "token"}))
+	require.NoError(t, c.Expand(nodes[:1], nodes[1:], gravity.InstallParam{Role: "node"}))
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}, cluster.Members())
+	require.NoError(t, c.Status(nodes))
+
+	require.NoError(t, c.ShrinkLeave(nodes[:2], nodes[2:]))
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, cluster.Members())
+}
+
+func TestLeader(t *testing.T) {
+	cluster := NewCluster("fake")
+	nodes := []gravity.Gravity{
+		New(cluster, "1.1.1.1", "10.0.0.1"),
+		New(cluster, "1.1.1.2", "10.0.0.2"),
+		New(cluster, "1.1.1.3", "10.0.0.3"),
+	}
+	c := newTestContext()
+	require.NoError(t, nodes[0].Install(c.Context(), gravity.InstallParam{Token: "token"}))
+	require.NoError(t, c.Expand(nodes[:1], nodes[1:], gravity.InstallParam{Role: "node"}))
+
+	leader, err := c.Leader(c.Context(), nodes)
+	require.NoError(t, err)
+	assert.Equal(t, nodes[0], leader)
+	others, err := c.NonLeaders(c.Context(), nodes)
+	require.NoError(t, err)
+	assert.Equal(t, nodes[1:], others)
+
+	require.NoError(t, cluster.SetLeader("10.0.0.2"))
+	err = c.RunOnLeader(c.Context(), nodes, func(ctx context.Context, leader gravity.Gravity) error {
+		assert.Equal(t, nodes[1], leader)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestStatusAll(t *testing.T) {
+	cluster := NewCluster("fake")
+	nodes := []gravity.Gravity{
+		New(cluster, "1.1.1.1", "10.0.0.1"),
+		New(cluster, "1.1.1.2", "10.0.0.2"),
+	}
+	c := newTestContext()
+	require.NoError(t, nodes[0].Install(c.Context(), gravity.InstallParam{Token: "token"}))
+	require.NoError(t, c.Expand(nodes[:1], nodes[1:], gravity.InstallParam{Role: "node"}))
+	require.NoError(t, nodes[1].PowerOff(c.Context(), gravity.Graceful(false)))
+
+	statuses := c.StatusAll(c.Context(), nodes)
+	require.Len(t, statuses, 2)
+	require.NoError(t, statuses[nodes[0]].Err)
+	assert.Equal(t, "active", statuses[nodes[0]].Status.Cluster.Status)
+	assert.Error(t, statuses[nodes[1]].Err)
+	assert.Nil(t, statuses[nodes[1]].Status)
+}
+
+func TestFailureInjection(t *testing.T) {
+	cluster := NewCluster("fake")
+	master := New(cluster, "1.1.1.1", "10.0.0.1")
+	worker := New(cluster, "1.1.1.2", "10.0.0.2")
+	c := newTestContext()
+	require.NoError(t, master.Install(c.Context(), gravity.InstallParam{}))
+
+	errJoin := errors.New("join failed")
+	worker.InjectFailure(Join, errJoin, 1)
+	err := c.Expand([]gravity.Gravity{master}, []gravity.Gravity{worker}, gravity.InstallParam{})
+	require.Error(t, err)
+	assert.Equal(t, []string{"10.0.0.1"}, cluster.Members())
+
+	// Failure has been exhausted
+	err = c.Expand([]gravity.Gravity{master}, []gravity.Gravity{worker}, gravity.InstallParam{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, worker.Calls(Join))
+}
+
+func TestLatency(t *testing.T) {
+	cluster := NewCluster("fake")
+	node := New(cluster, "1.1.1.1", "10.0.0.1").SetLatency(Install, time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := node.Install(ctx, gravity.InstallParam{})
+	require.Error(t, err)
+	assert.Empty(t, cluster.Members())
+}
+
+func TestStepHooks(t *testing.T) {
+	cluster := NewCluster("fake")
+	nodes := []gravity.Gravity{
+		New(cluster, "1.1.1.1", "10.0.0.1"),
+		New(cluster, "1.1.1.2", "10.0.0.2"),
+	}
+	c := newTestContext()
+	require.NoError(t, nodes[0].Install(c.Context(), gravity.InstallParam{Token: "token"}))
+
+	var calls []string
+	removeBefore := gravity.AddBeforeHook("expand", func(c *gravity.TestContext, s *gravity.Scenario, step gravity.Step) error {
+		calls = append(calls, "before "+step.Name())
+		return nil
+	})
+	defer removeBefore()
+	removeAfter := gravity.AddAfterHook("", func(c *gravity.TestContext, s *gravity.Scenario, step gravity.Step) error {
+		calls = append(calls, "after "+step.Name())
+		return errors.New("ignored")
+	})
+	scenario := &gravity.Scenario{
+		Param:   gravity.InstallParam{Role: "node"},
+		Cluster: gravity.Cluster{Nodes: nodes},
+		Nodes:   nodes[:1],
+	}
+	c.RunSteps(scenario, gravity.ExpandStep{To: 2}, gravity.StatusStep{})
+	assert.Equal(t, []string{"before expand", "after expand", "after status"}, calls)
+
+	removeAfter()
+	calls = nil
+	c.RunSteps(scenario, gravity.StatusStep{})
+	assert.Empty(t, calls)
+	assert.Equal(t, nodes, scenario.Nodes)
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, cluster.Members())
+}
+
+func newTestContext() *gravity.TestContext {
+	return gravity.NewTestContext(context.Background(), gravity.DefaultTimeouts,
+		logrus.NewEntry(logrus.StandardLogger()))
+}
+
+func TestRunInPod(t *testing.T) {
+	node := New(NewCluster("fake"), "1.1.1.1", "10.0.0.1")
+	var commands [][]string
+	node.PlanetCommand = func(cmd string, args ...string) (string, error) {
+		commands = append(commands, args)
+		if args[0] == "get" {
+			return "registry-abc", nil
+		}
+		return "ok", nil
+	}
+	c := newTestContext()
+
+	out, err := gravity.RunInPod(c.Context(), node, "kube-system", "app=registry", "curl", "-s", "http://localhost:5000/v2/")
+	require.NoError(t, err)
+	assert.Equal(t, "ok", out)
+	require.Len(t, commands, 2)
+	assert.Equal(t, []string{"exec", "-n", "kube-system", "registry-abc", "--", "curl", "-s", "http://localhost:5000/v2/"}, commands[1])
+
+	node.PlanetCommand = func(cmd string, args ...string) (string, error) { return "", nil }
+	_, err = gravity.RunInPod(c.Context(), node, "kube-system", "app=registry", "true")
+	assert.True(t, trace.IsNotFound(err))
+}
+
+func TestCheckRegistry(t *testing.T) {
+	cluster := NewCluster("fake")
+	nodes := []gravity.Gravity{
+		New(cluster, "1.1.1.1", "10.0.0.1"),
+		New(cluster, "1.1.1.2", "10.0.0.2"),
+	}
+	var (
+		mu      sync.Mutex
+		pushed  []string
+		pulled  []string
+		deletes []string
+		digest  = strings.Repeat("a", 64)
+	)
+	for _, node := range nodes {
+		node.(*Node).PlanetCommand = func(cmd string, args ...string) (string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case args[0] == "images":
+				return "<none>:<none>\nbusybox:1.31\n", nil
+			case args[0] == "push":
+				pushed = append(pushed, args[1])
+				return "1.31: digest: sha256:" + digest + " size: 527", nil
+			case args[0] == "pull":
+				pulled = append(pulled, args[1])
+			case cmd == "/usr/bin/curl":
+				deletes = append(deletes, args[len(args)-1])
+			}
+			return "", nil
+		}
+	}
+	c := newTestContext()
+	require.NoError(t, nodes[0].Install(c.Context(), gravity.InstallParam{Token: "token"}))
+	require.NoError(t, c.Expand(nodes[:1], nodes[1:], gravity.InstallParam{Role: "node"}))
+
+	require.NoError(t, c.CheckRegistry(nodes))
+	assert.ElementsMatch(t, []string{
+		"10.0.0.1:5000/robotest/registry-check:10.0.0.1",
+		"10.0.0.2:5000/robotest/registry-check:10.0.0.1",
+		"10.0.0.1:5000/robotest/registry-check:10.0.0.2",
+		"10.0.0.2:5000/robotest/registry-check:10.0.0.2",
+	}, pushed)
+	// every node pulls the images of all nodes through every master and the leader
+	assert.Len(t, pulled, 2*2*3)
+	assert.Contains(t, pulled, "leader.telekube.local:5000/robotest/registry-check:10.0.0.2")
+	assert.ElementsMatch(t, []string{
+		"https://10.0.0.1:5000/v2/robotest/registry-check/manifests/sha256:" + digest,
+		"https://10.0.0.2:5000/v2/robotest/registry-check/manifests/sha256:" + digest,
+		"https://10.0.0.1:5000/v2/robotest/registry-check/manifests/sha256:" + digest,
+		"https://10.0.0.2:5000/v2/robotest/registry-check/manifests/sha256:" + digest,
+	}, deletes)
+}
+
+func TestAssertHooks(t *testing.T) {
+	node := New(NewCluster("fake"), "1.1.1.1", "10.0.0.1")
+	var listed []string
+	node.PlanetCommand = func(cmd string, args ...string) (string, error) {
+		if args[0] == "logs" {
+			return "", errors.New("no logs")
+		}
+		listed = args
+		return "kube-system\tapp-install-7f9a\t2030-01-01T10:05:00Z\t1\t\t\n" +
+			"kube-system\tapp-post-install-c21e\t2030-01-01T10:06:00Z\t\t1\t\n", nil
+	}
+	c := newTestContext()
+	start := time.Date(2030, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	err := c.AssertHooks([]gravity.Gravity{node}, "install", start, []string{"install", "rollback"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "app-post-install-c21e has not succeeded")
+	assert.Contains(t, err.Error(), "no job for hook rollback")
+	assert.Equal(t, []string{"get", "jobs", "-n", "kube-system", "-l", "app.gravitational.io/hook"}, listed[:6])
+
+	err = c.AssertHooks(nil, "install", start, nil)
+	assert.True(t, trace.IsBadParameter(err))
+}
+
+func TestSoakRejectsInvalidConfig(t *testing.T) {
+	c := newTestContext()
+	nodes := []gravity.Gravity{New(NewCluster("fake"), "1.1.1.1", "10.0.0.1")}
+
+	_, err := c.Soak(nil, gravity.SoakConfig{Duration: time.Hour, Interval: time.Minute})
+	assert.True(t, trace.IsBadParameter(err), "no nodes: %v", err)
+	_, err = c.Soak(nodes, gravity.SoakConfig{Duration: time.Hour, Interval: time.Minute, MinGrowth: -1})
+	assert.True(t, trace.IsBadParameter(err), "negative growth: %v", err)
+}
+
+func TestClusterHelpersRejectUnsupportedNodes(t *testing.T) {
+	c := newTestContext()
+	nodes := []gravity.Gravity{
+		New(NewCluster("fake"), "1.1.1.1", "10.0.0.1"),
+		New(NewCluster("fake"), "1.1.1.2", "10.0.0.2"),
+	}
+
+	err := c.OfflineInstall(nodes, gravity.InstallParam{})
+	assert.True(t, trace.IsBadParameter(err), "install: %v", err)
+	err = c.AssertPortsOpen(nodes)
+	assert.True(t, trace.IsBadParameter(err), "ports: %v", err)
+	err = c.AssertInstallFailedWith(nodes, errors.New("install failed"), "message")
+	assert.True(t, trace.IsBadParameter(err), "install failure: %v", err)
+}
+
+func TestEgressRejectsUnsupportedNodes(t *testing.T) {
+	c := newTestContext()
+	nodes := []gravity.Gravity{New(NewCluster("fake"), "1.1.1.1", "10.0.0.1")}
+
+	assert.True(t, trace.IsBadParameter(c.BlockEgress(nodes)))
+	assert.True(t, trace.IsBadParameter(c.AllowEgress(nodes)))
+	assert.True(t, trace.IsBadParameter(c.AssertEgressBlocked(nodes)))
+}
+
+func TestMetricsRejectUnsupportedNodes(t *testing.T) {
+	c := newTestContext()
+	nodes := []gravity.Gravity{New(NewCluster("fake"), "1.1.1.1", "10.0.0.1")}
+
+	assert.True(t, trace.IsBadParameter(c.CollectMetrics("test", nodes)))
+}
+
+func TestCaptureRejectsUnsupportedNodes(t *testing.T) {
+	c := newTestContext()
+	nodes := []gravity.Gravity{New(NewCluster("fake"), "1.1.1.1", "10.0.0.1")}
+
+	_, err := c.StartCapture(nodes, "test", gravity.CaptureFilter{})
+	assert.True(t, trace.IsBadParameter(err))
+}
+
+func TestInterruptInstallRejectsUnsupportedNodes(t *testing.T) {
+	c := newTestContext()
+
+	assert.True(t, trace.IsBadParameter(c.InterruptInstall(nil, gravity.InstallParam{}, "/init")))
+	nodes := []gravity.Gravity{New(NewCluster("fake"), "1.1.1.1", "10.0.0.1")}
+	assert.True(t, trace.IsBadParameter(c.InterruptInstall(nodes, gravity.InstallParam{}, "/init")))
+}
+
+func TestReplaceNodeRequiresProvisioner(t *testing.T) {
+	c := newTestContext()
+	cluster := NewCluster("fake")
+	nodes := []gravity.Gravity{New(cluster, "1.1.1.1", "10.0.0.1"), New(cluster, "1.1.1.2", "10.0.0.2")}
+
+	_, _, err := c.ReplaceNode(nodes, nodes[1], "https://example.com/installer.tar", gravity.InstallParam{})
+	assert.True(t, trace.IsNotImplemented(err), "expected not implemented, got %v", err)
+	_, _, err = c.ReplaceNode(nodes[:1], nodes[1], "https://example.com/installer.tar", gravity.InstallParam{})
+	assert.True(t, trace.IsBadParameter(err), "expected bad parameter, got %v", err)
+}
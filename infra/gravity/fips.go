@@ -0,0 +1,106 @@
+package gravity
+
+import (
+	"context"
+
+	sshutils "github.com/gravitational/robotest/lib/ssh"
+	"github.com/gravitational/robotest/lib/utils"
+
+	"github.com/gravitational/trace"
+)
+
+// AssertFIPS verifies that the kernel runs in FIPS mode on all nodes,
+// that the mode is visible to the cluster services inside planet
+// and that gravity status reports the cluster in FIPS mode
+func (c *TestContext) AssertFIPS(nodes []Gravity) error {
+	if len(nodes) == 0 {
+		return trace.BadParameter("at least one node is required")
+	}
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	errs := make(chan error, len(nodes))
+	for _, node := range nodes {
+		go func(node Gravity) {
+			g, ok := node.(*gravity)
+			if !ok {
+				errs <- trace.BadParameter("unsupported node %v", node)
+				return
+			}
+			enabled, err := fipsEnabled(ctx, g)
+			if err == nil && !enabled {
+				err = trace.CompareFailed("FIPS mode is disabled on %v", node)
+			}
+			if err != nil {
+				errs <- trace.Wrap(err)
+				return
+			}
+			out, err := g.RunInPlanet(ctx, "/bin/cat", fipsEnabledPath)
+			if err == nil && out != "1" {
+				err = trace.CompareFailed("FIPS mode is disabled in planet on %v", node)
+			}
+			errs <- trace.Wrap(err)
+		}(node)
+	}
+	if err := utils.CollectErrors(ctx, errs); err != nil {
+		return trace.Wrap(err)
+	}
+	status, err := nodes[0].Status(ctx)
+	if err != nil {
+		return trace.Wrap(err, "query status from [%v]", nodes[0])
+	}
+	if !status.Cluster.FIPS {
+		return trace.CompareFailed("gravity status does not report FIPS mode on %v", nodes[0])
+	}
+	return nil
+}
+
+// enableFIPS boots the node with the kernel in FIPS mode.
+// On RHEL/CentOS 8, fips-mode-setup is used, otherwise dracut-fips
+// is installed and fips=1 is added to the kernel boot parameters
+func enableFIPS(ctx context.Context, node *gravity) error {
+	if !isRedHatFamily(node.param.os.Vendor) {
+		return trace.BadParameter("FIPS mode is only supported on CentOS/RHEL, not %v", node.param.os.Vendor)
+	}
+	enabled, err := fipsEnabled(ctx, node)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	log := node.Logger()
+	if enabled {
+		log.Debug("FIPS mode is enabled.")
+		return nil
+	}
+
+	log.Info("Enable FIPS mode and reboot.")
+	err = node.run(ctx, log, `if which fips-mode-setup; then sudo fips-mode-setup --enable; `+
+		`else sudo yum install -y dracut-fips && sudo dracut -f && sudo grubby --update-kernel=ALL --args=fips=1; fi`, nil)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	err = node.Reboot(ctx, Graceful(true))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	enabled, err = fipsEnabled(ctx, node)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if !enabled {
+		return trace.CompareFailed("FIPS mode is disabled after reboot")
+	}
+	return nil
+}
+
+// fipsEnabled returns true if the kernel on the node runs in FIPS mode
+func fipsEnabled(ctx context.Context, node *gravity) (bool, error) {
+	var out string
+	err := node.runAndParse(ctx, node.Logger(), "cat "+fipsEnabledPath, nil, sshutils.ParseAsString(&out))
+	if err != nil {
+		return false, trace.Wrap(err)
+	}
+	return out == "1", nil
+}
+
+// fipsEnabledPath is the kernel flag set in FIPS mode
+const fipsEnabledPath = "/proc/sys/crypto/fips_enabled"
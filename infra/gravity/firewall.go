@@ -0,0 +1,118 @@
+package gravity
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gravitational/robotest/lib/utils"
+
+	"github.com/gravitational/trace"
+)
+
+// enableFirewall enables the distribution firewall on the node - firewalld on
+// CentOS/RHEL and ufw on Ubuntu/Debian - with only SSH allowed,
+// so that the install has to deal with a firewall as commonly found in the field
+func enableFirewall(ctx context.Context, node *gravity) error {
+	var commands []string
+	switch vendor := node.param.os.Vendor; {
+	case isRedHatFamily(vendor):
+		commands = []string{
+			"rpm -q firewalld || sudo yum install -y firewalld",
+			"sudo systemctl enable --now firewalld",
+			"sudo firewall-cmd --permanent --add-service=ssh",
+			"sudo firewall-cmd --reload",
+		}
+	case vendor == "ubuntu" || vendor == "debian":
+		commands = []string{
+			"which ufw || sudo DEBIAN_FRONTEND=noninteractive apt-get install -y ufw",
+			"sudo ufw default deny incoming",
+			"sudo ufw allow ssh",
+			"sudo ufw --force enable",
+		}
+	default:
+		return trace.BadParameter("firewall is not supported on %v", vendor)
+	}
+	log := node.Logger()
+	log.Info("Enable firewall.")
+	for _, cmd := range commands {
+		err := node.run(ctx, log, cmd, nil)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// AssertPortsOpen verifies that the ports required by the cluster
+// are reachable on the master node from all other nodes despite the firewall
+func (c *TestContext) AssertPortsOpen(nodes []Gravity) error {
+	if len(nodes) < 2 {
+		c.Logger().Info("Single node cluster, skip port checks.")
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	master := nodes[0]
+	errs := make(chan error, len(nodes)-1)
+	for _, node := range nodes[1:] {
+		g, ok := node.(*gravity)
+		if !ok {
+			return trace.BadParameter("unsupported node %v", node)
+		}
+		go func(node *gravity) {
+			errs <- checkPortsReachable(ctx, node, master.Node().PrivateAddr(), requiredMasterPorts)
+		}(g)
+	}
+	return trace.Wrap(utils.CollectErrors(ctx, errs))
+}
+
+// AssertInstallFailedWith verifies that the install failed with an error
+// containing the documented message by looking it up in the install log
+// on the master node
+func (c *TestContext) AssertInstallFailedWith(nodes []Gravity, installErr error, message string) error {
+	if installErr == nil {
+		return trace.CompareFailed("install succeeded, expected it to fail with %q", message)
+	}
+
+	if len(nodes) == 0 {
+		return trace.BadParameter("at least one node is required")
+	}
+	master, ok := nodes[0].(*gravity)
+	if !ok {
+		return trace.BadParameter("unsupported node %v", nodes[0])
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	err := logContains(ctx, master, message)
+	if err != nil {
+		return trace.Wrap(installErr, "install failed: %v", err)
+	}
+	return nil
+}
+
+// checkPortsReachable makes sure the specified TCP ports on addr can be connected to from the node
+func checkPortsReachable(ctx context.Context, node *gravity, addr string, ports []int) error {
+	var unreachable []string
+	for _, port := range ports {
+		cmd := fmt.Sprintf("timeout 5 bash -c '</dev/tcp/%v/%v'", addr, port)
+		err := node.run(ctx, node.Logger(), cmd, nil)
+		if err != nil {
+			unreachable = append(unreachable, fmt.Sprint(port))
+		}
+	}
+	if len(unreachable) != 0 {
+		return trace.CompareFailed("ports %v on %v are not reachable from %v",
+			strings.Join(unreachable, ","), addr, node)
+	}
+	return nil
+}
+
+// requiredMasterPorts lists the TCP ports of master node services other nodes
+// connect to: kubernetes API server, etcd, kubelet and serf.
+// See https://gravitational.com/gravity/docs/requirements/#network
+var requiredMasterPorts = []int{6443, 2379, 10250, 7496}
@@ -5,7 +5,9 @@ import (
 	"bytes"
 	"testing"
 
+	"github.com/gravitational/trace"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var testStatusStr = []byte(`
@@ -16,16 +18,16 @@ func TestGravityOutput(t *testing.T) {
 	expectedStatus := &GravityStatus{
 		Cluster: ClusterStatus{
 			Cluster:     "testcluster",
-			Application: Application{Name: "telekube"},
+			Application: Application{Name: "telekube", Version: "0.0.1"},
 			Status:      "active",
 			Token:       Token{Token: "fac3b88014367fe4e98a8664755e2be4"},
 			Nodes: []NodeStatus{
-				NodeStatus{Addr: "10.40.2.4"},
-				NodeStatus{Addr: "10.40.2.5"},
-				NodeStatus{Addr: "10.40.2.7"},
-				NodeStatus{Addr: "10.40.2.6"},
-				NodeStatus{Addr: "10.40.2.3"},
-				NodeStatus{Addr: "10.40.2.2"},
+				NodeStatus{Addr: "10.40.2.4", Profile: "node", Role: "master"},
+				NodeStatus{Addr: "10.40.2.5", Profile: "node", Role: "master"},
+				NodeStatus{Addr: "10.40.2.7", Profile: "node", Role: "master"},
+				NodeStatus{Addr: "10.40.2.6", Profile: "node", Role: "node"},
+				NodeStatus{Addr: "10.40.2.3", Profile: "node", Role: "node"},
+				NodeStatus{Addr: "10.40.2.2", Profile: "node", Role: "node"},
 			},
 		},
 	}
@@ -35,3 +37,34 @@ func TestGravityOutput(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, expectedStatus, &status, "parseStatus")
 }
+
+func TestAgentURL(t *testing.T) {
+	status := ClusterStatus{
+		Cluster:   "testcluster",
+		Token:     Token{Token: "fac3b88014367fe4e98a8664755e2be4"},
+		Endpoints: Endpoints{Cluster: ClusterEndpoints{UI: []string{"https://10.40.2.4:32009", "https://10.40.2.5:32009"}}},
+	}
+	url, err := agentURL(status, "", "worker")
+	require.NoError(t, err)
+	assert.Equal(t, "https://10.40.2.4:32009/t/fac3b88014367fe4e98a8664755e2be4/worker", url)
+
+	url, err = agentURL(status, "lb.example.com:3009", "worker")
+	require.NoError(t, err)
+	assert.Equal(t, "https://lb.example.com:3009/t/fac3b88014367fe4e98a8664755e2be4/worker", url, "load balancer address")
+
+	_, err = agentURL(ClusterStatus{Cluster: "testcluster"}, "lb.example.com:3009", "worker")
+	assert.True(t, trace.IsNotFound(err), "no cluster web endpoint")
+}
+
+func TestNodeRoles(t *testing.T) {
+	param := InstallParam{
+		Role:      "node",
+		NodeRoles: []RoleCount{{Role: "master", Count: 3}, {Role: "worker", Count: 2}},
+	}
+	var roles []string
+	for i := 0; i < 6; i++ {
+		roles = append(roles, param.RoleOf(i))
+	}
+	assert.Equal(t, []string{"master", "master", "master", "worker", "worker", "node"}, roles)
+	assert.Equal(t, 5, param.NodeRolesCount())
+}
@@ -0,0 +1,265 @@
+package gravity
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gravitational/robotest/lib/wait"
+
+	"github.com/gravitational/trace"
+)
+
+// InstallHelmChart installs the sample chart as release with the helm client inside planet
+// (helm 2 with tiller or helm 3, depending on the cluster) and waits for the release to deploy.
+// The sample chart only creates a config map, so it does not require any images.
+// Use AssertHelmRelease to verify the release, i.e. after an upgrade
+func (c *TestContext) InstallHelmChart(nodes []Gravity, release string) error {
+	if len(nodes) == 0 {
+		return trace.BadParameter("empty node list")
+	}
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	node := nodes[0]
+	c.Logger().WithField("release", release).Info("Install sample helm chart.")
+	helm3, err := isHelm3(ctx, node)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	chart, err := sampleChart()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	// the chart is passed encoded to survive the shell quoting
+	_, err = node.RunInPlanet(ctx, "/bin/sh", "-c", fmt.Sprintf("echo %v | base64 -d > %v",
+		base64.StdEncoding.EncodeToString(chart), sampleChartPath))
+	if err != nil {
+		return trace.Wrap(err, "failed to copy sample chart")
+	}
+	_, err = node.RunInPlanet(ctx, "/usr/bin/helm", helmInstallArgs(helm3, release)...)
+	if err != nil {
+		return trace.Wrap(err, "failed to install sample chart")
+	}
+	return trace.Wrap(c.AssertHelmRelease(nodes, release))
+}
+
+// AssertHelmRelease verifies the release installed with InstallHelmChart
+// is deployed and its resources are present in the cluster
+func (c *TestContext) AssertHelmRelease(nodes []Gravity, release string) error {
+	if len(nodes) == 0 {
+		return trace.BadParameter("empty node list")
+	}
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	node := nodes[0]
+	retry := wait.Retryer{
+		Attempts:    30,
+		Delay:       10 * time.Second,
+		FieldLogger: c.Logger().WithField("release", release),
+	}
+	return trace.Wrap(retry.Do(ctx, func() error {
+		helm3, err := isHelm3(ctx, node)
+		if err != nil {
+			return wait.Continue("failed to query helm version: %v", err)
+		}
+		out, err := node.RunInPlanet(ctx, "/usr/bin/helm", helmListArgs(helm3, release)...)
+		if err != nil {
+			return wait.Continue("failed to list helm releases: %v", err)
+		}
+		if strings.TrimSpace(out) != release {
+			return wait.Continue("release %v is not deployed", release)
+		}
+		out, err = node.RunInPlanet(ctx, "/usr/bin/kubectl", "get", "configmap", "-n", helmNamespace,
+			release+"-"+sampleChartName, "-ojsonpath={.data.release}")
+		if err != nil {
+			return wait.Continue("failed to query release resources: %v", err)
+		}
+		if strings.TrimSpace(out) != release {
+			return wait.Continue("unexpected config map data %q", out)
+		}
+		return nil
+	}))
+}
+
+// DeleteHelmRelease removes the release installed with InstallHelmChart
+func (c *TestContext) DeleteHelmRelease(nodes []Gravity, release string) error {
+	if len(nodes) == 0 {
+		return trace.BadParameter("empty node list")
+	}
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	node := nodes[0]
+	helm3, err := isHelm3(ctx, node)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	_, err = node.RunInPlanet(ctx, "/usr/bin/helm", helmDeleteArgs(helm3, release)...)
+	return trace.Wrap(err)
+}
+
+// HelmVersion returns the major version of the helm client inside planet: 2 or 3
+func (c *TestContext) HelmVersion(nodes []Gravity) (int, error) {
+	if len(nodes) == 0 {
+		return 0, trace.BadParameter("empty node list")
+	}
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	helm3, err := isHelm3(ctx, nodes[0])
+	if err != nil {
+		return 0, trace.Wrap(err)
+	}
+	if helm3 {
+		return 3, nil
+	}
+	return 2, nil
+}
+
+// AssertHelmMigrated verifies that the release installed with InstallHelmChart using helm 2
+// has been migrated to helm 3, i.e. by an upgrade to a version shipping helm 3.
+// The release must be deployed and its history must start with a revision made
+// before installed, so a release installed anew after the upgrade does not pass
+func (c *TestContext) AssertHelmMigrated(nodes []Gravity, release string, installed time.Time) error {
+	if err := c.AssertHelmRelease(nodes, release); err != nil {
+		return trace.Wrap(err)
+	}
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	node := nodes[0]
+	helm3, err := isHelm3(ctx, node)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if !helm3 {
+		return trace.CompareFailed("expected helm 3 on %v", node)
+	}
+	out, err := node.RunInPlanet(ctx, "/usr/bin/helm", "history", release, "--namespace", helmNamespace, "--output", "json")
+	if err != nil {
+		return trace.Wrap(err, "failed to query release history")
+	}
+	return trace.Wrap(checkMigratedHistory(out, installed))
+}
+
+// checkMigratedHistory verifies that the first revision in the helm 3 release history
+// (helm history --output json) has been made before installed
+func checkMigratedHistory(out string, installed time.Time) error {
+	var history []helmRevision
+	if err := json.Unmarshal([]byte(out), &history); err != nil {
+		return trace.Wrap(err, "failed to parse release history %q", out)
+	}
+	if len(history) == 0 {
+		return trace.NotFound("release has no history")
+	}
+	first := history[0]
+	for _, revision := range history[1:] {
+		if revision.Revision < first.Revision {
+			first = revision
+		}
+	}
+	if first.Updated.After(installed) {
+		return trace.CompareFailed("release revision %v has been made at %v, after the helm 2 install at %v: "+
+			"the release has been reinstalled instead of migrated", first.Revision, first.Updated, installed)
+	}
+	return nil
+}
+
+// helmRevision is a revision in the release history reported by helm 3
+type helmRevision struct {
+	Revision int       `json:"revision"`
+	Updated  time.Time `json:"updated"`
+	Status   string    `json:"status"`
+}
+
+// helmInstallArgs returns the helm arguments to install the sample chart as release
+func helmInstallArgs(helm3 bool, release string) []string {
+	args := []string{"install", release, sampleChartPath}
+	if !helm3 {
+		args = []string{"install", "--name", release, sampleChartPath}
+	}
+	return append(args, "--namespace", helmNamespace, "--wait")
+}
+
+// helmListArgs returns the helm arguments to list the release if deployed
+func helmListArgs(helm3 bool, release string) []string {
+	if helm3 {
+		return []string{"ls", "--deployed", "-q", "--namespace", helmNamespace, "--filter", "^" + release + "$"}
+	}
+	return []string{"ls", "--deployed", "-q", "^" + release + "$"}
+}
+
+// helmDeleteArgs returns the helm arguments to remove the release
+func helmDeleteArgs(helm3 bool, release string) []string {
+	if helm3 {
+		return []string{"uninstall", release, "--namespace", helmNamespace}
+	}
+	return []string{"delete", "--purge", release}
+}
+
+// isHelm3 returns true if the helm client inside planet is helm 3
+func isHelm3(ctx context.Context, node Gravity) (bool, error) {
+	out, err := node.RunInPlanet(ctx, "/usr/bin/helm", "version", "--client", "--short")
+	if err != nil {
+		return false, trace.Wrap(err)
+	}
+	// i.e. v3.2.4+g0ad800e for helm 3 and Client: v2.16.12+g47f0b88 for helm 2
+	return strings.HasPrefix(strings.TrimSpace(out), "v3."), nil
+}
+
+// sampleChart returns the packaged sample chart
+func sampleChart() ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, file := range []struct {
+		name, content string
+	}{
+		{"Chart.yaml", fmt.Sprintf("apiVersion: v1\nname: %v\nversion: 0.1.0\ndescription: Robotest sample chart\n", sampleChartName)},
+		{"values.yaml", "message: hello\n"},
+		{"templates/configmap.yaml", `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Release.Name }}-{{ .Chart.Name }}
+data:
+  release: {{ .Release.Name }}
+  message: {{ .Values.message }}
+`},
+	} {
+		err := tw.WriteHeader(&tar.Header{
+			Name: sampleChartName + "/" + file.name,
+			Mode: 0644,
+			Size: int64(len(file.content)),
+		})
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		if _, err := tw.Write([]byte(file.content)); err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return buf.Bytes(), nil
+}
+
+const (
+	// sampleChartName is the name of the sample chart
+	sampleChartName = "robotest-sample"
+	// sampleChartPath is the path of the packaged sample chart inside planet
+	sampleChartPath = "/tmp/robotest-sample-0.1.0.tgz"
+	// helmNamespace is the namespace the sample chart is installed into
+	helmNamespace = "default"
+)
@@ -0,0 +1,123 @@
+package gravity
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/gravitational/robotest/infra/providers/ops"
+	sshutils "github.com/gravitational/robotest/lib/ssh"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampleChart(t *testing.T) {
+	chart, err := sampleChart()
+	require.NoError(t, err)
+
+	rz, err := gzip.NewReader(bytes.NewReader(chart))
+	require.NoError(t, err)
+	r := tar.NewReader(rz)
+	files := make(map[string]string)
+	for {
+		hdr, err := r.Next()
+		if err != nil {
+			break
+		}
+		data, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		files[hdr.Name] = string(data)
+	}
+	assert.Len(t, files, 3)
+	assert.Equal(t, "apiVersion: v1\nname: robotest-sample\nversion: 0.1.0\ndescription: Robotest sample chart\n",
+		files["robotest-sample/Chart.yaml"])
+	assert.Equal(t, "message: hello\n", files["robotest-sample/values.yaml"])
+	assert.Contains(t, files["robotest-sample/templates/configmap.yaml"], "name: {{ .Release.Name }}-{{ .Chart.Name }}\n")
+	assert.Contains(t, files["robotest-sample/templates/configmap.yaml"], "release: {{ .Release.Name }}\n")
+}
+
+func TestHelmArgs(t *testing.T) {
+	var testCases = []struct {
+		helm3   bool
+		install []string
+		list    []string
+		delete  []string
+	}{
+		{
+			helm3:   false,
+			install: []string{"install", "--name", "test", "/tmp/robotest-sample-0.1.0.tgz", "--namespace", "default", "--wait"},
+			list:    []string{"ls", "--deployed", "-q", "^test$"},
+			delete:  []string{"delete", "--purge", "test"},
+		},
+		{
+			helm3:   true,
+			install: []string{"install", "test", "/tmp/robotest-sample-0.1.0.tgz", "--namespace", "default", "--wait"},
+			list:    []string{"ls", "--deployed", "-q", "--namespace", "default", "--filter", "^test$"},
+			delete:  []string{"uninstall", "test", "--namespace", "default"},
+		},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.install, helmInstallArgs(tc.helm3, "test"), "helm3=%v", tc.helm3)
+		assert.Equal(t, tc.list, helmListArgs(tc.helm3, "test"), "helm3=%v", tc.helm3)
+		assert.Equal(t, tc.delete, helmDeleteArgs(tc.helm3, "test"), "helm3=%v", tc.helm3)
+	}
+}
+
+func TestIsHelm3(t *testing.T) {
+	versionCmd := "cd /home/robotest/installer && sudo ./gravity enter -- --notty /usr/bin/helm -- version --client --short"
+	var testCases = []struct {
+		out      string
+		expected bool
+	}{
+		{out: "v3.2.4+g0ad800e\n", expected: true},
+		{out: "Client: v2.16.12+g47f0b88\n", expected: false},
+	}
+	for _, tc := range testCases {
+		g := &gravity{
+			node:       ops.New("1.1.1.1", "10.0.0.1", "robotest", ""),
+			transport:  sshutils.NewReplayer(sshutils.Interaction{Command: versionCmd, Stdout: tc.out}),
+			installDir: "/home/robotest/installer",
+			log:        logrus.NewEntry(logrus.StandardLogger()),
+		}
+		helm3, err := isHelm3(context.Background(), g)
+		require.NoError(t, err, tc.out)
+		assert.Equal(t, tc.expected, helm3, tc.out)
+	}
+}
+
+func TestChecksMigratedHistory(t *testing.T) {
+	installed := time.Date(2020, 7, 9, 10, 20, 0, 0, time.UTC)
+	var testCases = []struct {
+		history string
+		check   func(error) bool
+		comment string
+	}{
+		{
+			history: `[{"revision":1,"updated":"2020-07-09T10:18:32.367969Z","status":"superseded"},
+{"revision":2,"updated":"2020-07-09T11:02:10.1Z","status":"deployed"}]`,
+			check:   func(err error) bool { return err == nil },
+			comment: "history migrated from helm 2",
+		},
+		{
+			history: `[{"revision":1,"updated":"2020-07-09T11:02:10.1Z","status":"deployed"}]`,
+			check:   trace.IsCompareFailed,
+			comment: "release installed anew after the upgrade",
+		},
+		{
+			history: `[]`,
+			check:   trace.IsNotFound,
+			comment: "empty history",
+		},
+	}
+	for _, tc := range testCases {
+		err := checkMigratedHistory(tc.history, installed)
+		assert.True(t, tc.check(err), "%v: unexpected error %v", tc.comment, err)
+	}
+}
@@ -0,0 +1,164 @@
+package gravity
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gravitational/robotest/lib/constants"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// HookJob describes the Kubernetes job of an application lifecycle hook
+type HookJob struct {
+	// Namespace is the namespace of the job
+	Namespace string
+	// Name is the name of the job
+	Name string
+	// Created is the time the job has been created
+	Created time.Time
+	// Succeeded is the number of pods of the job that have completed successfully
+	Succeeded int
+	// Failed is the number of pods of the job that have failed
+	Failed int
+	// Active is the number of pods of the job still running
+	Active int
+}
+
+// String returns the namespaced name of the job
+func (r HookJob) String() string {
+	return fmt.Sprintf("%v/%v", r.Namespace, r.Name)
+}
+
+// AssertHooks verifies the application lifecycle hooks (install, update, rollback, etc.)
+// run by the operation started at start: the hook jobs created since then are required
+// to have succeeded and each of hooks has to match the name of at least one of the jobs,
+// i.e. "install" for the install hook. Only the jobs gravity runs the hooks with are considered,
+// see hookNamespace and hookJobSelector. The output of the jobs is saved into
+// hooks/prefix/<namespace>-<job>.log in the state directory
+func (c *TestContext) AssertHooks(nodes []Gravity, prefix string, start time.Time, hooks []string) error {
+	if len(nodes) == 0 {
+		return trace.BadParameter("at least one node is required")
+	}
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	node := nodes[0]
+	jobs, err := hookJobs(ctx, node, start.Add(-hookClockSkew))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	var errs []error
+	for _, job := range jobs {
+		log := c.Logger().WithFields(logrus.Fields{
+			"job":       job.String(),
+			"succeeded": job.Succeeded,
+			"failed":    job.Failed,
+			"active":    job.Active,
+		})
+		log.Info("Hook job.")
+		if err := c.saveHookLogs(ctx, node, prefix, job); err != nil {
+			log.WithError(err).Warn("Failed to save hook job output.")
+		}
+		if job.Succeeded == 0 {
+			errs = append(errs, trace.CompareFailed("hook job %v has not succeeded", job))
+		}
+	}
+	for _, hook := range hooks {
+		if !hookRan(jobs, hook) {
+			errs = append(errs, trace.NotFound("no job for hook %v", hook))
+		}
+	}
+	return trace.NewAggregate(errs...)
+}
+
+func (c *TestContext) saveHookLogs(ctx context.Context, node Gravity, prefix string, job HookJob) error {
+	out, err := node.RunInPlanet(ctx, "/usr/bin/kubectl", "logs", "-n", job.Namespace,
+		"job/"+job.Name, "--all-containers=true")
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	path := filepath.Join(c.provisionerConfig().StateDir, "hooks", prefix,
+		fmt.Sprintf("%v-%v.log", job.Namespace, job.Name))
+	if err := os.MkdirAll(filepath.Dir(path), constants.SharedDirMask); err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	return trace.ConvertSystemError(ioutil.WriteFile(path, []byte(out), constants.SharedReadMask))
+}
+
+// hookJobs returns the hook jobs created after since
+func hookJobs(ctx context.Context, node Gravity, since time.Time) ([]HookJob, error) {
+	out, err := node.RunInPlanet(ctx, "/usr/bin/kubectl", "get", "jobs", "-n", hookNamespace, "-l", hookJobSelector,
+		`-ojsonpath={range .items[*]}{.metadata.namespace}{"\t"}{.metadata.name}{"\t"}{.metadata.creationTimestamp}`+
+			`{"\t"}{.status.succeeded}{"\t"}{.status.failed}{"\t"}{.status.active}{"\n"}{end}`)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	jobs, err := parseHookJobs(out)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var recent []HookJob
+	for _, job := range jobs {
+		if !job.Created.Before(since) {
+			recent = append(recent, job)
+		}
+	}
+	return recent, nil
+}
+
+// parseHookJobs parses the jobs listed as tab-separated namespace, name, creation time
+// and the number of succeeded, failed and active pods, the counts being empty if zero
+func parseHookJobs(out string) (jobs []HookJob, err error) {
+	for _, line := range strings.Split(out, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 6 {
+			return nil, trace.BadParameter("unexpected job line %q", line)
+		}
+		job := HookJob{Namespace: fields[0], Name: fields[1]}
+		if job.Created, err = time.Parse(time.RFC3339, fields[2]); err != nil {
+			return nil, trace.BadParameter("invalid creation time in job line %q", line)
+		}
+		for i, count := range []*int{&job.Succeeded, &job.Failed, &job.Active} {
+			if fields[3+i] == "" {
+				continue
+			}
+			if *count, err = strconv.Atoi(fields[3+i]); err != nil {
+				return nil, trace.BadParameter("invalid pod count in job line %q", line)
+			}
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// hookRan returns true if one of the jobs is named after the hook
+func hookRan(jobs []HookJob, hook string) bool {
+	for _, job := range jobs {
+		if strings.Contains(job.Name, hook) {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	// hookNamespace is the namespace gravity runs the hook jobs in
+	hookNamespace = "kube-system"
+	// hookJobSelector selects the jobs gravity has created for the application hooks
+	// as opposed to the other jobs in hookNamespace, i.e. those of cron jobs
+	hookJobSelector = "app.gravitational.io/hook"
+)
+
+// hookClockSkew is the tolerated difference between the clocks of robotest and the cluster
+const hookClockSkew = time.Minute
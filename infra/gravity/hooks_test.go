@@ -0,0 +1,25 @@
+package gravity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHookJobs(t *testing.T) {
+	out := "kube-system\ttelekube-install-7f9a\t2020-01-01T10:05:00Z\t1\t\t\n" +
+		"kube-system\ttelekube-post-update-c21e\t2020-01-01T11:00:00Z\t\t2\t1\n"
+	jobs, err := parseHookJobs(out)
+	require.NoError(t, err)
+	assert.Equal(t, []HookJob{
+		{Namespace: "kube-system", Name: "telekube-install-7f9a", Created: time.Date(2020, 1, 1, 10, 5, 0, 0, time.UTC), Succeeded: 1},
+		{Namespace: "kube-system", Name: "telekube-post-update-c21e", Created: time.Date(2020, 1, 1, 11, 0, 0, 0, time.UTC), Failed: 2, Active: 1},
+	}, jobs)
+	assert.True(t, hookRan(jobs, "post-update"))
+	assert.False(t, hookRan(jobs, "rollback"))
+
+	_, err = parseHookJobs("kube-system\tjob\n")
+	assert.Error(t, err)
+}
@@ -0,0 +1,307 @@
+package gravity
+
+import (
+	"bytes"
+	"encoding/base64"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gravitational/robotest/lib/constants"
+	"github.com/gravitational/robotest/lib/xlog"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// HTMLReportConfig configures the HTML report of a run
+type HTMLReportConfig struct {
+	// Report is the report of the run
+	Report RunReport
+	// LinkBase is the directory the artifact links are made relative to,
+	// i.e. the directory of the report file. Absolute links are used if empty
+	LinkBase string
+	// UIArtifacts optionally names the report directory of the UI tests
+	// with the failure artifacts (screenshots, console logs) of each spec in artifacts/<spec>
+	UIArtifacts string
+	// Log optionally specifies the logger to warn about the artifacts that cannot be read.
+	// Defaults to the standard logger
+	Log logrus.FieldLogger
+}
+
+// WriteHTMLReport renders the standalone HTML report of the run into path,
+// see RenderHTMLReport
+func WriteHTMLReport(path string, config HTMLReportConfig) error {
+	if config.LinkBase == "" {
+		config.LinkBase = filepath.Dir(path)
+	}
+	var buf bytes.Buffer
+	if err := RenderHTMLReport(&buf, config); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.ConvertSystemError(ioutil.WriteFile(path, buf.Bytes(), constants.SharedReadMask))
+}
+
+// RenderHTMLReport renders the standalone HTML report of the run: the tests of the suite
+// with their attempts, the time spent in each phase, the failures and the artifacts of each attempt.
+// The logs (the tail of each) and the images found in the state directories of the tests
+// and in the UI artifacts are embedded, the other artifacts linked
+func RenderHTMLReport(w io.Writer, config HTMLReportConfig) error {
+	if config.Log == nil {
+		config.Log = logrus.StandardLogger()
+	}
+	page := htmlReport{
+		RunReport: config.Report,
+		Duration:  config.Report.Completed.Sub(config.Report.Started).Round(time.Second),
+		Classes:   make(map[string]int),
+		Failures:  GroupBySignature(config.Report.Results),
+	}
+	for _, result := range Classify(config.Report.Results) {
+		page.Classes[result.Class]++
+		test := htmlTest{Name: result.Test, Class: result.Class, Quarantine: result.Quarantine}
+		if result.Quarantine != nil {
+			page.Quarantined++
+		}
+		for _, attempt := range result.Attempts {
+			artifacts, err := collectArtifacts(attempt.StateDir, config.LinkBase, config.Log)
+			if err != nil {
+				return trace.Wrap(err)
+			}
+			test.Attempts = append(test.Attempts, htmlAttempt{TestStatus: attempt, Artifacts: artifacts})
+		}
+		page.Tests = append(page.Tests, test)
+	}
+	if config.UIArtifacts != "" {
+		specs, err := collectSpecs(filepath.Join(config.UIArtifacts, "artifacts"), config.LinkBase, config.Log)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		page.Specs = specs
+	}
+	return trace.Wrap(htmlReportTemplate.Execute(w, page))
+}
+
+type htmlReport struct {
+	RunReport
+	Duration time.Duration
+	// Classes counts the tests per class
+	Classes map[string]int
+	// Quarantined counts the quarantined tests
+	Quarantined int
+	// Failures groups the failed attempts by signature
+	Failures []SignatureGroup
+	Tests    []htmlTest
+	Specs    []htmlSpec
+}
+
+type htmlTest struct {
+	Name       string
+	Class      string
+	Quarantine *Quarantine
+	Attempts   []htmlAttempt
+}
+
+type htmlAttempt struct {
+	TestStatus
+	Artifacts []htmlArtifact
+}
+
+// htmlSpec is a UI spec with failure artifacts
+type htmlSpec struct {
+	Name      string
+	Artifacts []htmlArtifact
+}
+
+// htmlArtifact is a file of a test: logs are embedded as Text, images as Image
+type htmlArtifact struct {
+	Path  string
+	Link  string
+	Text  string
+	Image template.URL
+	// Truncated is true if only the tail of the log is embedded
+	Truncated bool
+}
+
+// collectArtifacts returns the artifacts under dir, if it exists.
+// Files and directories that cannot be read are skipped with a warning
+func collectArtifacts(dir, linkBase string, log logrus.FieldLogger) (artifacts []htmlArtifact, err error) {
+	if dir == "" {
+		return nil, nil
+	}
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil, nil
+	}
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			log.WithError(err).Warnf("Skip unreadable artifact %v.", path)
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+		artifact, err := newArtifact(dir, path, linkBase, info)
+		if err != nil {
+			log.WithError(err).Warnf("Skip unreadable artifact %v.", path)
+			return nil
+		}
+		artifacts = append(artifacts, *artifact)
+		return nil
+	})
+	return artifacts, trace.Wrap(err)
+}
+
+// collectSpecs returns the UI specs with the artifacts in the subdirectories of dir
+func collectSpecs(dir, linkBase string, log logrus.FieldLogger) (specs []htmlSpec, err error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, trace.ConvertSystemError(err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		artifacts, err := collectArtifacts(filepath.Join(dir, entry.Name()), linkBase, log)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		specs = append(specs, htmlSpec{Name: entry.Name(), Artifacts: artifacts})
+	}
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Name < specs[j].Name })
+	return specs, nil
+}
+
+func newArtifact(dir, path, linkBase string, info os.FileInfo) (*htmlArtifact, error) {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	artifact := htmlArtifact{Path: rel, Link: artifactLink(path, linkBase)}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".log", ".txt":
+		data, err := readTail(path, info.Size())
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		artifact.Text = string(data)
+		artifact.Truncated = info.Size() > maxEmbeddedLog
+	case ".png":
+		if info.Size() > maxEmbeddedImage {
+			break
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, trace.ConvertSystemError(err)
+		}
+		artifact.Image = template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(data))
+	}
+	return &artifact, nil
+}
+
+// artifactLink returns the link to the file relative to linkBase, if possible
+func artifactLink(path, linkBase string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	if linkBase != "" {
+		if base, err := filepath.Abs(linkBase); err == nil {
+			if rel, err := filepath.Rel(base, abs); err == nil {
+				return filepath.ToSlash(rel)
+			}
+		}
+	}
+	return "file://" + filepath.ToSlash(abs)
+}
+
+// readTail returns the last maxEmbeddedLog bytes of the file of size
+func readTail(path string, size int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, trace.ConvertSystemError(err)
+	}
+	defer f.Close()
+	if size > maxEmbeddedLog {
+		if _, err := f.Seek(size-maxEmbeddedLog, io.SeekStart); err != nil {
+			return nil, trace.ConvertSystemError(err)
+		}
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(f, maxEmbeddedLog))
+	return data, trace.ConvertSystemError(err)
+}
+
+const (
+	// maxEmbeddedLog is the size of the tail of a log embedded into the HTML report
+	maxEmbeddedLog = 64 * 1024
+	// maxEmbeddedImage is the size of the largest image embedded into the HTML report
+	maxEmbeddedImage = 4 * 1024 * 1024
+)
+
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"round": func(d time.Duration) time.Duration { return d.Round(time.Second) },
+	"json":  xlog.ToJSON,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>robotest {{.Suite}} {{.Tag}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin: 0.5em 0; }
+td, th { border: 1px solid #ccc; padding: 0.2em 0.6em; text-align: left; }
+pre { background: #f6f6f6; padding: 0.5em; max-height: 30em; overflow: auto; }
+img { max-width: 100%; border: 1px solid #ccc; }
+.passed { color: #2a7d2a; } .flake { color: #b8860b; } .failure, .infrastructure { color: #c0392b; } .cancelled, .timed_out { color: #777; } .known_issue { color: #8e44ad; } .quarantined { color: #777; font-style: italic; }
+</style>
+</head>
+<body>
+<h1>robotest {{.Suite}} {{.Tag}}</h1>
+<p>Started {{.Started.Format "2006-01-02 15:04:05 MST"}}, took {{.Duration}}.
+{{range $class, $count := .Classes}}<span class="{{$class}}">{{$count}} {{$class}}</span> {{end}}{{if .Quarantined}}<span class="quarantined">{{.Quarantined}} quarantined</span>{{end}}</p>
+<table>
+<tr><th>Test</th><th>Class</th><th>Attempts</th><th>Quarantine</th></tr>
+{{range .Tests}}<tr><td><a href="#{{.Name}}">{{.Name}}</a></td><td class="{{.Class}}">{{.Class}}</td><td>{{len .Attempts}}</td>
+<td>{{with .Quarantine}}{{.Ticket}} {{.Reason}}{{end}}</td></tr>
+{{end}}</table>
+{{if .Failures}}<h2>Failures</h2>
+<table>
+<tr><th>Signature</th><th>Category</th><th>Error</th><th>Attempts</th><th>Known issue</th></tr>
+{{range .Failures}}<tr><td>{{.Signature}}</td><td>{{.Category}}</td><td>{{.Error}}</td><td>{{range .Attempts}}{{.}} {{end}}</td>
+<td>{{with .KnownIssue}}{{.Ticket}} {{.Description}}{{if .Issue}} <a href="{{.Issue}}">{{.Issue}}</a>{{end}}{{if not .Expires.IsZero}} (until {{.Expires.Format "2006-01-02"}}){{end}}{{end}}</td></tr>
+{{end}}</table>{{end}}
+{{range .Tests}}
+<h2 id="{{.Name}}">{{.Name}} <span class="{{.Class}}">{{.Class}}</span>{{if .Quarantine}} <span class="quarantined">quarantined</span>{{end}}</h2>
+{{range .Attempts}}
+<h3>Attempt {{.Attempt}}: {{.Status}}</h3>
+<p>{{.Name}}{{if .LogUrl}} - <a href="{{.LogUrl}}">logs</a>{{end}}{{if .Preempted}} - preempted{{end}}{{if .TimedOutPhase}} - timed out at phase {{.TimedOutPhase}}{{end}}</p>
+{{if .Param}}<pre>{{json .Param}}</pre>{{end}}
+{{if .Error}}<p>Failure ({{.Category}}, signature {{.Signature}}):</p><pre>{{.Error}}</pre>{{end}}
+{{if .Phases}}<table>
+<tr><th>Phase</th><th>Duration</th></tr>
+{{range .Phases}}<tr><td>{{.Phase}}</td><td>{{round .Duration}}</td></tr>
+{{end}}</table>{{end}}
+{{template "artifacts" .Artifacts}}
+{{end}}
+{{end}}
+{{if .Specs}}<h2>UI specs</h2>
+{{range .Specs}}<h3>{{.Name}}</h3>
+{{template "artifacts" .Artifacts}}
+{{end}}{{end}}
+</body>
+</html>
+{{define "artifacts"}}{{if .}}<details><summary>Artifacts ({{len .}})</summary>
+<ul>
+{{range .}}<li><a href="{{.Link}}">{{.Path}}</a>
+{{if .Image}}<br><img src="{{.Image}}" alt="{{.Path}}">{{end}}
+{{if .Text}}<details><summary>{{if .Truncated}}tail{{else}}contents{{end}}</summary><pre>{{.Text}}</pre></details>{{end}}
+</li>
+{{end}}</ul>
+</details>{{end}}{{end}}
+`))
@@ -0,0 +1,58 @@
+package gravity
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderHTMLReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "html-report")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	stateDir := filepath.Join(dir, "install-1")
+	specDir := filepath.Join(dir, "ui", "artifacts", "login_spec")
+	for path, content := range map[string]string{
+		filepath.Join(stateDir, "inventory", "10.0.0.1.txt"):    "kernel 4.19 <b>",
+		filepath.Join(stateDir, "node-logs", "crashreport.tgz"): "binary",
+		filepath.Join(stateDir, "huge.log"):                     strings.Repeat("x", maxEmbeddedLog) + "tail",
+		filepath.Join(specDir, "screenshot.png"):                "png",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+	// a dangling link cannot be read
+	require.NoError(t, os.Symlink(filepath.Join(dir, "missing.log"), filepath.Join(stateDir, "removed.log")))
+
+	start := time.Now()
+	var buf bytes.Buffer
+	err = RenderHTMLReport(&buf, HTMLReportConfig{
+		Report: RunReport{Suite: "sanity", Tag: "nightly", Started: start, Completed: start.Add(time.Hour),
+			Results: []TestStatus{
+				{Name: "install-1", Test: "install-1", Attempt: 1, Status: TestStatusFailed,
+					Error: "install failed", Category: "gravity_operation", StateDir: stateDir,
+					Phases: []PhaseDuration{{Phase: "install", Duration: 90 * time.Second}}},
+			}},
+		LinkBase:    dir,
+		UIArtifacts: filepath.Join(dir, "ui"),
+	})
+	require.NoError(t, err)
+	out := buf.String()
+	assert.Contains(t, out, `<a href="#install-1">install-1</a>`)
+	assert.Contains(t, out, "install failed")
+	assert.Contains(t, out, "<td>install</td><td>1m30s</td>")
+	assert.Contains(t, out, `<a href="install-1/node-logs/crashreport.tgz">`)
+	assert.Contains(t, out, "kernel 4.19 &lt;b&gt;")
+	assert.NotContains(t, out, strings.Repeat("x", maxEmbeddedLog+1))
+	assert.Contains(t, out, "xtail")
+	assert.Contains(t, out, `<img src="data:image/png;base64,cG5n"`)
+	assert.NotContains(t, out, "removed.log", "unreadable artifacts are skipped")
+}
@@ -0,0 +1,135 @@
+package gravity
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+
+	"github.com/gravitational/robotest/lib/failure"
+	"github.com/gravitational/robotest/lib/shell"
+	"github.com/gravitational/robotest/lib/utils"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// RegistryConfig specifies the registry to pull cluster and application images from
+type RegistryConfig struct {
+	// Image specifies the default reference of the cluster image to install,
+	// i.e. registry.example.com/gravitational/telekube:7.0.12
+	Image string `yaml:"image"`
+	// Username optionally specifies the user to authenticate to the registry with
+	Username string `yaml:"username"`
+	// Password specifies the password to authenticate to the registry with
+	Password string `yaml:"password"`
+	// Insecure allows pulling from registries with self-signed certificates
+	Insecure bool `yaml:"insecure"`
+}
+
+// registry returns the registry configuration and the environment that passes
+// the registry credentials to the command without exposing them in the command line
+func (config ProvisionerConfig) registry() (RegistryConfig, map[string]string) {
+	if config.Registry == nil {
+		return RegistryConfig{}, nil
+	}
+	registry := *config.Registry
+	if registry.Username == "" {
+		return registry, nil
+	}
+	return registry, map[string]string{
+		"REGISTRY_USERNAME": registry.Username,
+		"REGISTRY_PASSWORD": registry.Password,
+	}
+}
+
+// SetGravityBinary transfers the gravity binary given with gravityURL into the
+// specified sub-directory in user's home on all nodes and makes it the install directory.
+// This prepares the nodes to install the cluster from an image instead of the installer tarball
+func (c *TestContext) SetGravityBinary(nodes []Gravity, gravityURL, subdir string) error {
+	return trace.Wrap(c.withEgress(nodes, func() error {
+		ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Install)
+		defer cancel()
+
+		errs := make(chan error, len(nodes))
+		for _, node := range nodes {
+			go func(node Gravity) {
+				g, ok := node.(*gravity)
+				if !ok {
+					errs <- trace.BadParameter("unsupported node %v", node)
+					return
+				}
+				errs <- g.setGravityBinary(ctx, gravityURL, subdir)
+			}(node)
+		}
+
+		_, err := utils.Collect(ctx, cancel, errs, nil)
+		return trace.Wrap(err)
+	}))
+}
+
+// ImageInstall installs the cluster from the cluster image in the registry.
+// Unless param.Image is set, the image configured for the registry is installed.
+// The gravity binary is expected in the install directory, see SetGravityBinary
+func (c *TestContext) ImageInstall(nodes []Gravity, param InstallParam) error {
+	if param.Image == "" && c.provisionerConfig().Registry != nil {
+		param.Image = c.provisionerConfig().Registry.Image
+	}
+	if param.Image == "" {
+		return trace.BadParameter("cluster image is required to install from a registry")
+	}
+
+	c.Logger().WithField("image", param.Image).Info("Install from image.")
+
+	ctx, cancel := context.WithTimeout(c.ctx, withDuration(c.timeouts.Install, len(nodes)))
+	defer cancel()
+
+	return trace.Wrap(c.offlineInstall(ctx, cancel, nodes, param))
+}
+
+// InstallAppImage installs the application image given with reference from the registry
+// into the cluster on the specified master node with gravity app install
+func (c *TestContext) InstallAppImage(master Gravity, image string) error {
+	g, ok := master.(*gravity)
+	if !ok {
+		return trace.BadParameter("unsupported node %v", master)
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Install)
+	defer cancel()
+
+	registry, env := c.provisionerConfig().registry()
+	cmd, err := g.renderCommand(ctx, appInstallCommand, struct {
+		commandParams
+		Image    string
+		Registry RegistryConfig
+	}{
+		commandParams: g.commandParams(),
+		Image:         image,
+		Registry:      registry,
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	g.Logger().WithField("image", image).Info("Install application image.")
+	return trace.Wrap(failure.GravityOperation(g.run(ctx, g.Logger(), cmd, env)))
+}
+
+// setGravityBinary transfers the gravity binary into subdir and makes it the install directory
+func (g *gravity) setGravityBinary(ctx context.Context, gravityURL, subdir string) error {
+	u, err := url.Parse(gravityURL)
+	if err != nil {
+		return trace.Wrap(err, "parsing %s", gravityURL)
+	}
+	dir := filepath.Join(g.param.homeDir, subdir)
+	log := g.Logger().WithFields(logrus.Fields{"gravity_url": gravityURL, "installer_dir": dir})
+
+	if err := g.TransferFile(ctx, gravityURL, subdir); err != nil {
+		return trace.Wrap(err)
+	}
+	// the binary keeps the name from the URL
+	cmd := fmt.Sprintf("cd %v && if [ ! -f gravity ]; then %v; fi && chmod +x gravity && ./gravity version",
+		shell.Quote(dir), shell.New("mv", path.Base(u.Path), "gravity"))
+	return trace.Wrap(g.run(ctx, log, cmd, nil))
+}
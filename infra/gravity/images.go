@@ -0,0 +1,83 @@
+package gravity
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gravitational/robotest/infra/images"
+	"github.com/gravitational/robotest/lib/constants"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// resolveImage returns the image to provision the nodes with.
+// Images pinned in the configuration take precedence, otherwise the image is
+// resolved from the catalog if image resolution is enabled.
+// Returns an empty image to use the default image of the terraform script
+func resolveImage(ctx context.Context, cfg ProvisionerConfig, region string, log logrus.FieldLogger) (string, error) {
+	if image, ok := cfg.Images[cfg.os.String()]; ok {
+		return image, nil
+	}
+	if !cfg.ResolveImages {
+		return "", nil
+	}
+	query := images.Query{
+		Cloud:   cfg.CloudProvider,
+		Vendor:  cfg.os.Vendor,
+		Version: cfg.os.Version,
+		Arch:    cfg.Arch,
+	}
+	if region == "" {
+		return "", trace.BadParameter("%v region is required to resolve images", cfg.CloudProvider)
+	}
+	key := imageKey{Query: query, region: region}
+	if image, ok := resolvedImages.Load(key); ok {
+		return image.(string), nil
+	}
+	src, err := images.Lookup(query)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	var resolver images.Resolver
+	switch cfg.CloudProvider {
+	case constants.AWS:
+		sess, err := session.NewSession(&aws.Config{
+			Region:      aws.String(region),
+			Credentials: credentials.NewStaticCredentials(cfg.AWS.AccessKey, cfg.AWS.SecretKey, cfg.AWS.SessionToken),
+		})
+		if err != nil {
+			return "", trace.Wrap(err)
+		}
+		resolver = images.NewAWSResolver(sess)
+	case constants.GCE:
+		resolver, err = images.NewGCEResolver(ctx, cfg.GCE.Credentials)
+		if err != nil {
+			return "", trace.Wrap(err)
+		}
+	default:
+		return "", trace.BadParameter("image resolution is not supported on %v", cfg.CloudProvider)
+	}
+	image, err := resolver.Resolve(ctx, *src)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	log.WithFields(logrus.Fields{"os": query.String(), "image": image}).Info("Resolved OS image.")
+	resolvedImages.Store(key, image)
+	return image, nil
+}
+
+// imageKey identifies an image resolved for the OS in the cloud region.
+// AWS AMIs are specific to the region, so the same OS resolves to
+// a different image in each region
+type imageKey struct {
+	images.Query
+	region string
+}
+
+// resolvedImages caches the images resolved during this run (by imageKey) so that
+// all tests use the same image for the same OS in the same region
+var resolvedImages sync.Map
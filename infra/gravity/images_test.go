@@ -0,0 +1,33 @@
+package gravity
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gravitational/robotest/infra/images"
+	"github.com/gravitational/robotest/lib/constants"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolvesCachedImageByRegion(t *testing.T) {
+	cfg := ProvisionerConfig{
+		CloudProvider: constants.AWS,
+		ResolveImages: true,
+		os:            OS{Vendor: "centos", Version: "7.9"},
+	}
+	query := images.Query{Cloud: constants.AWS, Vendor: "centos", Version: "7.9"}
+	resolvedImages.Store(imageKey{Query: query, region: "us-test-1"}, "ami-test")
+	defer resolvedImages.Delete(imageKey{Query: query, region: "us-test-1"})
+	log := logrus.NewEntry(logrus.StandardLogger())
+
+	image, err := resolveImage(context.TODO(), cfg, "us-test-1", log)
+	require.NoError(t, err)
+	assert.Equal(t, "ami-test", image)
+
+	_, err = resolveImage(context.TODO(), cfg, "", log)
+	assert.True(t, trace.IsBadParameter(err), "expected bad parameter, got %v", err)
+}
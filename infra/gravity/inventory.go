@@ -0,0 +1,83 @@
+package gravity
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	sshutils "github.com/gravitational/robotest/lib/ssh"
+	"github.com/gravitational/robotest/lib/utils"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// CollectInventory records the hardware and OS inventory of the nodes (kernel, CPU, memory,
+// block devices, kernel modules, sysctls and installed packages) into inventory/<node IP>.txt
+// in the state directory, so that failures can be correlated with differences between the environments.
+// Nodes that are offline are skipped
+func (c *TestContext) CollectInventory(nodes []Gravity) error {
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.CollectLogs)
+	defer cancel()
+
+	c.Logger().WithField("nodes", nodes).Debug("Collecting inventory from nodes.")
+	var targets []*gravity
+	for _, node := range nodes {
+		g, ok := node.(*gravity)
+		if !ok {
+			return trace.BadParameter("unsupported node %v", node)
+		}
+		targets = append(targets, g)
+	}
+	errs := make(chan error, len(targets))
+	for _, node := range targets {
+		go func(node *gravity) {
+			if node.Offline() {
+				errs <- nil
+				return
+			}
+			errs <- trace.Wrap(node.collectInventory(ctx), "failed to collect inventory from %v", node)
+		}(node)
+	}
+	return trace.Wrap(utils.CollectErrors(ctx, errs))
+}
+
+func (g *gravity) collectInventory(ctx context.Context) error {
+	localPath := filepath.Join(g.param.StateDir, "inventory", fmt.Sprintf("%v.txt", g.Node().PrivateAddr()))
+	err := sshutils.PipeCommand(ctx, g.Client(), g.Logger(), inventoryCmd(), localPath)
+	g.Logger().WithFields(logrus.Fields{
+		logrus.ErrorKey: err,
+		"path":          localPath,
+	}).Info("Fetching inventory.")
+	return trace.Wrap(err)
+}
+
+// inventoryCmd returns the command that prints all inventory sections.
+// A failing section does not fail the command, as not all tools are available on all distributions
+func inventoryCmd() string {
+	var cmds []string
+	for _, section := range inventorySections {
+		cmds = append(cmds, fmt.Sprintf("echo '### %v'; (%v) 2>&1; echo", section.name, section.cmd))
+	}
+	return strings.Join(cmds, "; ")
+}
+
+type inventorySection struct {
+	// name identifies the section in the inventory file
+	name string
+	// cmd is the command that prints the section
+	cmd string
+}
+
+// inventorySections lists the sections of the node inventory
+var inventorySections = []inventorySection{
+	{name: "kernel", cmd: "uname -a && cat /proc/cmdline"},
+	{name: "os", cmd: "cat /etc/os-release"},
+	{name: "cpu", cmd: "cat /proc/cpuinfo"},
+	{name: "memory", cmd: "free -m"},
+	{name: "block devices", cmd: "lsblk -o NAME,TYPE,SIZE,ROTA,FSTYPE,MOUNTPOINT"},
+	{name: "modules", cmd: "lsmod"},
+	{name: "sysctls", cmd: "sudo sysctl -a 2>/dev/null | sort"},
+	{name: "packages", cmd: "if command -v rpm >/dev/null; then rpm -qa | sort; else dpkg-query -W; fi"},
+}
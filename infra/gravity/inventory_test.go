@@ -0,0 +1,31 @@
+package gravity
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInventoryCmd(t *testing.T) {
+	cmd := inventoryCmd()
+	for _, section := range inventorySections {
+		assert.Contains(t, cmd, "echo '### "+section.name+"'; ("+section.cmd+") 2>&1; echo", section.name)
+	}
+
+	// a failing section does not fail the command or the following sections
+	saved := inventorySections
+	defer func() { inventorySections = saved }()
+	inventorySections = []inventorySection{
+		{name: "missing", cmd: "robotest-missing-tool"},
+		{name: "kernel", cmd: "echo linux"},
+	}
+	out, err := exec.Command("/bin/sh", "-c", inventoryCmd()).CombinedOutput()
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	require.True(t, len(lines) >= 4, "unexpected output %q", out)
+	assert.Equal(t, "### missing", lines[0])
+	assert.Equal(t, []string{"### kernel", "linux"}, lines[len(lines)-2:])
+}
@@ -0,0 +1,103 @@
+package gravity
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gravitational/robotest/lib/defaults"
+	"github.com/gravitational/robotest/lib/shell"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// IOThrottle limits the I/O of a block device to simulate slow storage.
+// Zero limits are not applied
+type IOThrottle struct {
+	// Dir selects the block device backing the directory. Defaults to the gravity state directory
+	Dir string
+	// ReadBPS and WriteBPS limit the throughput in bytes per second
+	ReadBPS, WriteBPS uint64
+	// ReadIOPS and WriteIOPS limit the number of I/O operations per second
+	ReadIOPS, WriteIOPS uint64
+}
+
+// ThrottleIO limits the I/O of the block device on each of the nodes.
+// The limits apply to the system and user slices, i.e. the installer, etcd and
+// the Kubernetes services, with the cgroup v2 io.max or the cgroup v1 blkio throttle
+// depending on the node. The limits are lifted with UnthrottleIO or on reboot
+func (c *TestContext) ThrottleIO(nodes []Gravity, throttle IOThrottle) error {
+	if throttle == (IOThrottle{Dir: throttle.Dir}) {
+		return trace.BadParameter("no I/O limits specified")
+	}
+	c.Logger().WithFields(logrus.Fields{
+		"nodes":      Nodes(nodes),
+		"read_bps":   throttle.ReadBPS,
+		"write_bps":  throttle.WriteBPS,
+		"read_iops":  throttle.ReadIOPS,
+		"write_iops": throttle.WriteIOPS,
+	}).Info("Throttle disk I/O.")
+	return trace.Wrap(c.runOnNodes(nodes, ioThrottleCmd(throttle)))
+}
+
+// UnthrottleIO lifts the I/O limits set with ThrottleIO from the nodes
+func (c *TestContext) UnthrottleIO(nodes []Gravity, dir string) error {
+	c.Logger().WithField("nodes", Nodes(nodes)).Info("Lift disk I/O throttle.")
+	return trace.Wrap(c.runOnNodes(nodes, ioThrottleCmd(IOThrottle{Dir: dir})))
+}
+
+// ioThrottleCmd returns the command to set the I/O limits on the whole disk backing
+// the directory of throttle. A throttle without limits lifts the limits
+func ioThrottleCmd(throttle IOThrottle) string {
+	dir := throttle.Dir
+	if dir == "" {
+		dir = defaults.GravityDir
+	}
+	// throttling is only supported for whole disks, so resolve the parent of a partition
+	device := fmt.Sprintf(`src=$(df --output=source %v | tail -1) && `+
+		`disk=$(lsblk -no PKNAME $src | head -1) && `+
+		`disk=${disk:-$(basename $(readlink -f $src))} && `+
+		`dev=$(cat /sys/class/block/$disk/dev)`, shell.Quote(dir))
+
+	// cgroup v2 takes all limits at once, with max for no limit
+	limits := []string{
+		"rbps=" + ioMaxLimit(throttle.ReadBPS),
+		"wbps=" + ioMaxLimit(throttle.WriteBPS),
+		"riops=" + ioMaxLimit(throttle.ReadIOPS),
+		"wiops=" + ioMaxLimit(throttle.WriteIOPS),
+	}
+	var v2, v1 []string
+	v2 = append(v2, "echo +io | sudo tee /sys/fs/cgroup/cgroup.subtree_control >/dev/null")
+	for _, slice := range ioThrottleSlices {
+		v2 = append(v2, fmt.Sprintf(`echo "$dev %v" | sudo tee /sys/fs/cgroup/%v/io.max >/dev/null`,
+			strings.Join(limits, " "), slice))
+		// cgroup v1 takes each limit separately, with 0 for no limit
+		for _, limit := range []struct {
+			file  string
+			value uint64
+		}{
+			{"read_bps_device", throttle.ReadBPS},
+			{"write_bps_device", throttle.WriteBPS},
+			{"read_iops_device", throttle.ReadIOPS},
+			{"write_iops_device", throttle.WriteIOPS},
+		} {
+			v1 = append(v1, fmt.Sprintf(`echo "$dev %v" | sudo tee /sys/fs/cgroup/blkio/%v/blkio.throttle.%v >/dev/null`,
+				limit.value, slice, limit.file))
+		}
+	}
+	return fmt.Sprintf("%v && if [ -f /sys/fs/cgroup/cgroup.controllers ]; then %v; else %v; fi",
+		device, strings.Join(v2, " && "), strings.Join(v1, " && "))
+}
+
+func ioMaxLimit(limit uint64) string {
+	if limit == 0 {
+		return "max"
+	}
+	return fmt.Sprint(limit)
+}
+
+// DefaultSlowDisk is the I/O throttle that makes etcd report slow disk warnings
+var DefaultSlowDisk = IOThrottle{ReadBPS: 10 << 20, WriteBPS: 1 << 20, WriteIOPS: 50}
+
+// ioThrottleSlices lists the systemd slices the I/O limits are applied to
+var ioThrottleSlices = []string{"system.slice", "user.slice"}
@@ -0,0 +1,34 @@
+package gravity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIOThrottleCmd(t *testing.T) {
+	const device = `src=$(df --output=source '/var/lib/my gravity' | tail -1) && ` +
+		`disk=$(lsblk -no PKNAME $src | head -1) && ` +
+		`disk=${disk:-$(basename $(readlink -f $src))} && ` +
+		`dev=$(cat /sys/class/block/$disk/dev)`
+	cmd := ioThrottleCmd(IOThrottle{Dir: "/var/lib/my gravity", WriteBPS: 1048576, WriteIOPS: 50})
+	assert.Equal(t, device+` && if [ -f /sys/fs/cgroup/cgroup.controllers ]; then `+
+		`echo +io | sudo tee /sys/fs/cgroup/cgroup.subtree_control >/dev/null && `+
+		`echo "$dev rbps=max wbps=1048576 riops=max wiops=50" | sudo tee /sys/fs/cgroup/system.slice/io.max >/dev/null && `+
+		`echo "$dev rbps=max wbps=1048576 riops=max wiops=50" | sudo tee /sys/fs/cgroup/user.slice/io.max >/dev/null; else `+
+		`echo "$dev 0" | sudo tee /sys/fs/cgroup/blkio/system.slice/blkio.throttle.read_bps_device >/dev/null && `+
+		`echo "$dev 1048576" | sudo tee /sys/fs/cgroup/blkio/system.slice/blkio.throttle.write_bps_device >/dev/null && `+
+		`echo "$dev 0" | sudo tee /sys/fs/cgroup/blkio/system.slice/blkio.throttle.read_iops_device >/dev/null && `+
+		`echo "$dev 50" | sudo tee /sys/fs/cgroup/blkio/system.slice/blkio.throttle.write_iops_device >/dev/null && `+
+		`echo "$dev 0" | sudo tee /sys/fs/cgroup/blkio/user.slice/blkio.throttle.read_bps_device >/dev/null && `+
+		`echo "$dev 1048576" | sudo tee /sys/fs/cgroup/blkio/user.slice/blkio.throttle.write_bps_device >/dev/null && `+
+		`echo "$dev 0" | sudo tee /sys/fs/cgroup/blkio/user.slice/blkio.throttle.read_iops_device >/dev/null && `+
+		`echo "$dev 50" | sudo tee /sys/fs/cgroup/blkio/user.slice/blkio.throttle.write_iops_device >/dev/null; fi`, cmd)
+}
+
+func TestIOUnthrottleCmd(t *testing.T) {
+	cmd := ioThrottleCmd(IOThrottle{})
+	assert.Contains(t, cmd, `df --output=source /var/lib/gravity `, "defaults to the gravity state directory")
+	assert.Contains(t, cmd, `echo "$dev rbps=max wbps=max riops=max wiops=max" | sudo tee /sys/fs/cgroup/system.slice/io.max`)
+	assert.Contains(t, cmd, `echo "$dev 0" | sudo tee /sys/fs/cgroup/blkio/user.slice/blkio.throttle.write_iops_device`)
+}
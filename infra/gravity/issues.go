@@ -0,0 +1,102 @@
+package gravity
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gravitational/robotest/lib/issues"
+	"github.com/gravitational/robotest/lib/xlog"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// ConsistentFailures returns the tests that failed on all of several attempts
+// with a product failure (see failure.Category.IsProduct) of the same signature.
+// Quarantined tests and tests that were not retried are skipped
+func ConsistentFailures(results []TestResult) (failures []TestResult) {
+	for _, result := range results {
+		if result.Class != ClassFailure || result.Quarantine != nil || len(result.Attempts) <= 1 {
+			continue
+		}
+		signature := FailureSignature(result.Attempts[0])
+		consistent := signature != ""
+		for _, attempt := range result.Attempts {
+			if !attempt.Category.IsProduct() || FailureSignature(attempt) != signature {
+				consistent = false
+				break
+			}
+		}
+		if consistent {
+			failures = append(failures, result)
+		}
+	}
+	return failures
+}
+
+// FileIssues files the consistent failures of the run with the tracker,
+// commenting on the open issue of the same failure signature instead of filing a duplicate.
+// Failures to file an issue are logged and returned once all failures have been processed
+func FileIssues(ctx context.Context, tracker issues.Tracker, report RunReport, log logrus.FieldLogger) error {
+	var errs []error
+	for _, result := range ConsistentFailures(Classify(report.Results)) {
+		issue := failureIssue(report, result)
+		logger := log.WithFields(logrus.Fields{"test": result.Test, "signature": issue.Signature})
+		url, created, err := tracker.File(ctx, issue)
+		if err != nil {
+			logger.WithError(err).Warn("Failed to file issue.")
+			errs = append(errs, trace.Wrap(err, "failed to file issue of %v", result.Test))
+			continue
+		}
+		if created {
+			logger.WithField("issue", url).Info("Filed issue.")
+		} else {
+			logger.WithField("issue", url).Info("Commented on issue.")
+		}
+	}
+	return trace.NewAggregate(errs...)
+}
+
+// failureIssue describes the consistent failure of the test
+func failureIssue(report RunReport, result TestResult) issues.Issue {
+	last := result.Attempts[len(result.Attempts)-1]
+	message := strings.SplitN(strings.TrimSpace(last.Error), "\n", 2)[0]
+	var body strings.Builder
+	fmt.Fprintf(&body, "Test `%v` of the %v suite failed on all %v attempts of run `%v`.\n\n",
+		result.Test, report.Suite, len(result.Attempts), report.Tag)
+	fmt.Fprintf(&body, "Category: %v\n\n", last.Category)
+	if known := last.KnownIssue; known != nil {
+		fmt.Fprintf(&body, "Known issue: %v %v\n\n", known.Description, known.Issue)
+	}
+	fmt.Fprintf(&body, "Error:\n```\n%v\n```\n\n", strings.TrimSpace(last.Error))
+	for _, attempt := range result.Attempts {
+		if attempt.LogUrl != "" {
+			fmt.Fprintf(&body, "Logs of attempt %v: %v\n", attempt.Attempt, attempt.LogUrl)
+		}
+	}
+	fmt.Fprintf(&body, "\nEnvironment:\n```\n%v\n```\n", xlog.ToJSON(last.Param))
+	return issues.Issue{
+		Signature: FailureSignature(last),
+		Title:     fmt.Sprintf("robotest: %v: %v", testName(report, result.Test), truncate(message, maxIssueTitle)),
+		Body:      body.String(),
+	}
+}
+
+// testName returns the name of the test without the tag of the run
+func testName(report RunReport, test string) string {
+	if report.Tag == "" {
+		return test
+	}
+	return strings.TrimPrefix(test, report.Tag+"-")
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max] + "..."
+}
+
+// maxIssueTitle is the length of the error message in the issue title
+const maxIssueTitle = 120
@@ -23,7 +23,7 @@ const (
 func KubectlGetPods(ctx context.Context, g Gravity, namespace, label string) ([]Pod, error) {
 	args := []string{
 		"get", "pods", "-n", namespace,
-		`-ojsonpath='{range .items[*]}{.metadata.name},{.status.conditions[?(@.type=="Ready")].status},{.status.hostIP}{"\n"}{end}'`,
+		`-ojsonpath={range .items[*]}{.metadata.name},{.status.conditions[?(@.type=="Ready")].status},{.status.hostIP}{"\n"}{end}`,
 	}
 	if label != "" {
 		args = append(args, "-l", label)
@@ -51,6 +51,27 @@ func KubectlGetPods(ctx context.Context, g Gravity, namespace, label string) ([]
 	return pods, nil
 }
 
+// RunInPod runs the command in a running pod matching the label selector in namespace
+// with kubectl exec inside planet and returns its output.
+// The command runs in the default container of the pod
+func RunInPod(ctx context.Context, g Gravity, namespace, selector, cmd string, args ...string) (string, error) {
+	out, err := g.RunInPlanet(ctx, "/usr/bin/kubectl", "get", "pods", "-n", namespace, "-l", selector,
+		"--field-selector=status.phase=Running", "-ojsonpath={.items[*].metadata.name}")
+	if err != nil {
+		return "", trace.Wrap(err, "failed to find pod %v in %v", selector, namespace)
+	}
+	pods := strings.Fields(out)
+	if len(pods) == 0 {
+		return "", trace.NotFound("no running pod %v in %v", selector, namespace)
+	}
+	pod := pods[0]
+	out, err = g.RunInPlanet(ctx, "/usr/bin/kubectl", append([]string{"exec", "-n", namespace, pod, "--", cmd}, args...)...)
+	if err != nil {
+		return "", trace.Wrap(err, "failed to run %v in pod %v/%v", cmd, namespace, pod)
+	}
+	return out, nil
+}
+
 func KubectlDeletePod(ctx context.Context, g Gravity, namespace, pod string) error {
 	out, err := g.RunInPlanet(ctx, "/usr/bin/kubectl", "delete", "po", "-n", namespace, pod)
 	if err != nil {
@@ -0,0 +1,150 @@
+package gravity
+
+import (
+	"context"
+	"time"
+
+	"github.com/gravitational/robotest/lib/wait"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// Leader returns the current cluster leader among nodes.
+// The leader is discovered by querying IsLeader on all nodes that are not offline
+// and is only accepted if exactly one node claims leadership and no node failed to answer,
+// otherwise the discovery is retried until a leader is elected.
+// The discovered leader is cached and re-validated on the next call
+func (c *TestContext) Leader(ctx context.Context, nodes []Gravity) (Gravity, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeouts.LeaderElection)
+	defer cancel()
+
+	if leader := c.cachedLeader(nodes); leader != nil {
+		isLeader, err := leader.IsLeader(ctx)
+		if err == nil && isLeader {
+			return leader, nil
+		}
+		c.Logger().WithField("leader", leader).Info("Cached leader is no longer the leader.")
+		c.setLeader(nil)
+	}
+
+	retry := wait.Retryer{
+		Attempts:    60,
+		Delay:       10 * time.Second,
+		FieldLogger: c.Logger(),
+	}
+	var leader Gravity
+	err := retry.Do(ctx, func() (err error) {
+		leader, err = discoverLeader(ctx, nodes)
+		if err != nil {
+			return wait.Continue("no leader: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	c.Logger().WithField("leader", leader).Info("Discovered leader.")
+	c.setLeader(leader)
+	return leader, nil
+}
+
+// NonLeaders returns the nodes that are not the current cluster leader
+func (c *TestContext) NonLeaders(ctx context.Context, nodes []Gravity) ([]Gravity, error) {
+	leader, err := c.Leader(ctx, nodes)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var others []Gravity
+	for _, node := range nodes {
+		if node != leader {
+			others = append(others, node)
+		}
+	}
+	return others, nil
+}
+
+// RunOnLeader runs fn with the current cluster leader among nodes.
+// If fn fails and the leadership has moved meanwhile, the cached leader is invalidated
+// and the error notes the leadership change
+func (c *TestContext) RunOnLeader(ctx context.Context, nodes []Gravity, fn func(ctx context.Context, leader Gravity) error) error {
+	leader, err := c.Leader(ctx, nodes)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	err = fn(ctx, leader)
+	if err == nil {
+		return nil
+	}
+	if isLeader, errLeader := leader.IsLeader(ctx); errLeader != nil || !isLeader {
+		c.setLeader(nil)
+		return trace.Wrap(err, "leadership moved from %v", leader)
+	}
+	return trace.Wrap(err)
+}
+
+// discoverLeader queries IsLeader on all nodes that are not offline
+// and returns the only node that claims leadership
+func discoverLeader(ctx context.Context, nodes []Gravity) (Gravity, error) {
+	type result struct {
+		node     Gravity
+		isLeader bool
+		err      error
+	}
+	var online []Gravity
+	for _, node := range nodes {
+		if !node.Offline() {
+			online = append(online, node)
+		}
+	}
+	results := make(chan result, len(online))
+	for _, node := range online {
+		go func(node Gravity) {
+			isLeader, err := node.IsLeader(ctx)
+			results <- result{node: node, isLeader: isLeader, err: err}
+		}(node)
+	}
+	var leaders []Gravity
+	var errors []error
+	for range online {
+		r := <-results
+		switch {
+		case r.err != nil:
+			errors = append(errors, trace.Wrap(r.err, "failed to query leadership on %v", r.node))
+		case r.isLeader:
+			leaders = append(leaders, r.node)
+		}
+	}
+	if len(errors) != 0 {
+		return nil, trace.NewAggregate(errors...)
+	}
+	switch len(leaders) {
+	case 0:
+		return nil, trace.NotFound("none of %v is the leader", Nodes(online))
+	case 1:
+		return leaders[0], nil
+	default:
+		return nil, trace.CompareFailed("multiple nodes claim leadership: %v", Nodes(leaders))
+	}
+}
+
+// cachedLeader returns the cached leader if it is one of nodes
+func (c *TestContext) cachedLeader(nodes []Gravity) Gravity {
+	c.nodesMu.Lock()
+	defer c.nodesMu.Unlock()
+	for _, node := range nodes {
+		if node == c.leader && !node.Offline() {
+			return node
+		}
+	}
+	return nil
+}
+
+func (c *TestContext) setLeader(leader Gravity) {
+	c.nodesMu.Lock()
+	defer c.nodesMu.Unlock()
+	if leader != nil || c.leader != nil {
+		c.Logger().WithFields(logrus.Fields{"old": c.leader, "new": leader}).Debug("Update cached leader.")
+	}
+	c.leader = leader
+}
@@ -0,0 +1,104 @@
+package gravity
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gravitational/trace"
+	semver "github.com/hashicorp/go-version"
+)
+
+// leaderStrategy queries whether a node is the cluster leader
+// for a range of gravity versions
+type leaderStrategy struct {
+	// name identifies the strategy in errors and logs
+	name string
+	// versions specifies the constraint on the gravity version the strategy applies to
+	versions semver.Constraints
+	// isLeader returns true if node is the cluster leader given its cluster status
+	isLeader func(ctx context.Context, node *gravity, status *GravityStatus) (bool, error)
+}
+
+// leaderStrategy returns the leadership strategy for the gravity version on this node.
+// With unknown version, the etcd leader key is consulted
+func (g *gravity) leaderStrategy(ctx context.Context) leaderStrategy {
+	version, err := g.Version(ctx)
+	if err != nil {
+		g.Logger().WithError(err).Warn("Failed to query gravity version, will query etcd for the leader.")
+		return etcdKeyLeader
+	}
+	v, err := version.Semver()
+	if err != nil {
+		g.Logger().WithError(err).Warn("Unknown gravity version, will query etcd for the leader.")
+		return etcdKeyLeader
+	}
+	for _, strategy := range leaderStrategies {
+		if strategy.versions.Check(v) {
+			return strategy
+		}
+	}
+	return etcdKeyLeader
+}
+
+// queryStatusLeader reads the leader flag of the node from the cluster status
+func queryStatusLeader(ctx context.Context, node *gravity, status *GravityStatus) (bool, error) {
+	for _, member := range status.Cluster.Nodes {
+		if member.Addr == node.Node().PrivateAddr() || member.Addr == node.Node().PrivateAddrIPv6() {
+			return member.Leader, nil
+		}
+	}
+	return false, trace.NotFound("%v is not in the cluster status", node)
+}
+
+// queryPlanetLeader queries the leader with planet's view of the leader election
+func queryPlanetLeader(ctx context.Context, node *gravity, status *GravityStatus) (bool, error) {
+	out, err := node.RunInPlanet(ctx, "/usr/bin/planet", "leader", "view",
+		fmt.Sprintf("--leader-key=%v", planetLeaderKey(status)),
+		"--etcd-endpoints=https://127.0.0.1:2379",
+		"--etcd-cafile=/var/state/root.cert",
+		"--etcd-certfile=/var/state/etcd.cert",
+		"--etcd-keyfile=/var/state/etcd.key")
+	if err != nil {
+		return false, trace.Wrap(err)
+	}
+	return isNodeAddr(node, out), nil
+}
+
+// queryEtcdKeyLeader queries the leader from the planet leader key in etcd
+func queryEtcdKeyLeader(ctx context.Context, node *gravity, status *GravityStatus) (bool, error) {
+	out, err := node.RunInPlanet(ctx, "/usr/bin/etcdctl", "get", planetLeaderKey(status))
+	if err != nil {
+		return false, trace.Wrap(err)
+	}
+	return isNodeAddr(node, out), nil
+}
+
+// planetLeaderKey returns the etcd key planet maintains the address of the leader in
+func planetLeaderKey(status *GravityStatus) string {
+	return fmt.Sprintf("/planet/cluster/%v/master", status.Cluster.Cluster)
+}
+
+// isNodeAddr returns true if the output names one of the private addresses of the node
+func isNodeAddr(node *gravity, out string) bool {
+	addr := strings.TrimSpace(out)
+	return addr != "" && (addr == node.Node().PrivateAddr() || addr == node.Node().PrivateAddrIPv6())
+}
+
+var (
+	// leaderStrategies lists the leadership strategies, the first strategy
+	// matching the gravity version wins
+	leaderStrategies = []leaderStrategy{
+		{name: "gravity status", versions: mustConstraint(">= 7.0.0"), isLeader: queryStatusLeader},
+		{name: "planet leader view", versions: mustConstraint(">= 5.5.0"), isLeader: queryPlanetLeader},
+	}
+	etcdKeyLeader = leaderStrategy{name: "etcd leader key", isLeader: queryEtcdKeyLeader}
+)
+
+func mustConstraint(constraint string) semver.Constraints {
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
@@ -0,0 +1,70 @@
+package gravity
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gravitational/robotest/infra/providers/ops"
+	sshutils "github.com/gravitational/robotest/lib/ssh"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsLeaderStrategies(t *testing.T) {
+	const status = `{"cluster":{"domain":"test","state":"active","nodes":[
+{"advertise_ip":"10.0.0.1","profile":"node","leader":true},
+{"advertise_ip":"10.0.0.2","profile":"node"}]}}`
+	statusCmd := "sudo gravity status --output=json --system-log-file=gravity-system.log"
+	planetCmd := func(args string) string {
+		return "cd /home/robotest/installer && sudo ./gravity enter -- --notty " + args
+	}
+	var testCases = []struct {
+		version      string
+		interactions []sshutils.Interaction
+		comment      string
+	}{
+		{
+			version:      "7.0.12",
+			interactions: []sshutils.Interaction{{Command: statusCmd, Stdout: status}},
+			comment:      "leader flag in status",
+		},
+		{
+			version: "6.1.9",
+			interactions: []sshutils.Interaction{
+				{Command: statusCmd, Stdout: status},
+				{
+					Command: planetCmd("/usr/bin/planet -- leader view --leader-key=/planet/cluster/test/master " +
+						"--etcd-endpoints=https://127.0.0.1:2379 --etcd-cafile=/var/state/root.cert " +
+						"--etcd-certfile=/var/state/etcd.cert --etcd-keyfile=/var/state/etcd.key"),
+					Stdout: "10.0.0.1\n",
+				},
+			},
+			comment: "planet leader view",
+		},
+		{
+			version: "5.2.15",
+			interactions: []sshutils.Interaction{
+				{Command: statusCmd, Stdout: status},
+				{Command: planetCmd("/usr/bin/etcdctl -- get /planet/cluster/test/master"), Stdout: "10.0.0.1\n"},
+			},
+			comment: "etcd leader key",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.comment, func(t *testing.T) {
+			g := &gravity{
+				node:       ops.New("1.1.1.1", "10.0.0.1", "robotest", ""),
+				transport:  sshutils.NewReplayer(tc.interactions...),
+				installDir: "/home/robotest/installer",
+				log:        logrus.NewEntry(logrus.StandardLogger()),
+				version:    &Version{Version: tc.version},
+				versionDir: "/home/robotest/installer",
+			}
+			isLeader, err := g.IsLeader(context.Background())
+			require.NoError(t, err)
+			assert.True(t, isLeader)
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package gravity
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"text/template"
+	"time"
+
+	"github.com/gravitational/robotest/lib/shell"
+	"github.com/gravitational/robotest/lib/wait"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// LoadGeneratorConfig configures the in-cluster load generator, see StartLoad
+type LoadGeneratorConfig struct {
+	// Replicas is the number of load generator pods. Defaults to 1
+	Replicas int
+	// Rate is the number of objects each pod creates and deletes per second. Defaults to 5
+	Rate int
+	// Objects bounds the number of objects each pod keeps at a time. Defaults to 100
+	Objects int
+	// ObjectSize is the size of the data of each object in bytes. Defaults to 1024
+	ObjectSize int
+	// Image is the image with kubectl and a shell to run the load generator with.
+	// Defaults to defaultLoadImage, override for air-gapped clusters
+	Image string
+}
+
+// StartLoad deploys the load generator to the cluster and waits for its pods to run.
+// Each pod churns config maps against the Kubernetes API: it creates objects at the configured
+// rate and deletes the oldest ones to keep a bounded working set, thereby loading the API server
+// and etcd during the operations of the test.
+// Failed API requests are ignored by the generator so that it survives failovers and upgrades.
+// The load generator is removed with StopLoad
+func (c *TestContext) StartLoad(nodes []Gravity, config LoadGeneratorConfig) error {
+	if len(nodes) == 0 {
+		return trace.BadParameter("at least one node is required")
+	}
+	config = config.withDefaults()
+	manifest, err := loadManifest(config)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	c.Logger().WithFields(logrus.Fields{
+		"replicas": config.Replicas,
+		"rate":     config.Rate,
+		"objects":  config.Objects,
+	}).Info("Start load generator.")
+
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+	if err := applyManifest(ctx, nodes[0], manifest); err != nil {
+		return trace.Wrap(err, "failed to deploy load generator")
+	}
+	return trace.Wrap(c.waitForLoad(ctx, nodes[0], config.Replicas))
+}
+
+// AssertLoadRunning verifies that the load generator started with StartLoad still runs
+func (c *TestContext) AssertLoadRunning(nodes []Gravity) error {
+	if len(nodes) == 0 {
+		return trace.BadParameter("at least one node is required")
+	}
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+	return trace.Wrap(c.waitForLoad(ctx, nodes[0], 0))
+}
+
+// StopLoad removes the load generator together with the objects it has created
+func (c *TestContext) StopLoad(nodes []Gravity) error {
+	if len(nodes) == 0 {
+		return trace.BadParameter("at least one node is required")
+	}
+	c.Logger().Info("Stop load generator.")
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+	_, err := nodes[0].RunInPlanet(ctx, "/usr/bin/kubectl", "delete", "namespace", loadNamespace,
+		"--ignore-not-found", "--wait=true")
+	return trace.Wrap(err)
+}
+
+// waitForLoad waits until replicas load generator pods are ready,
+// or all pods if replicas is zero
+func (c *TestContext) waitForLoad(ctx context.Context, node Gravity, replicas int) error {
+	retry := wait.Retryer{
+		Attempts:    30,
+		Delay:       10 * time.Second,
+		FieldLogger: c.Logger().WithField("node", node),
+	}
+	return trace.Wrap(retry.Do(ctx, func() error {
+		pods, err := KubectlGetPods(ctx, node, loadNamespace, loadLabel)
+		if err != nil {
+			return wait.Continue("failed to query load generator pods: %v", err)
+		}
+		ready := 0
+		for _, pod := range pods {
+			if pod.Ready {
+				ready++
+			}
+		}
+		expected := replicas
+		if expected == 0 {
+			expected = len(pods)
+		}
+		if len(pods) == 0 || ready < expected {
+			return wait.Continue("%v out of %v load generator pods ready", ready, expected)
+		}
+		return nil
+	}))
+}
+
+// applyManifest creates or updates the Kubernetes resources from manifest with kubectl inside planet
+func applyManifest(ctx context.Context, node Gravity, manifest []byte) error {
+	g, ok := node.(*gravity)
+	if !ok {
+		return trace.BadParameter("unsupported node %v", node)
+	}
+	// the manifest is passed encoded to survive the shell quoting
+	cmd := fmt.Sprintf(`echo %v | base64 -d | (%v)`, base64.StdEncoding.EncodeToString(manifest),
+		shell.InDir(g.installDir, shell.Sudo("./gravity", "enter", "--", "--notty", "/usr/bin/kubectl", "--", "apply", "-f", "-")))
+	return trace.Wrap(g.run(ctx, g.Logger(), cmd, nil))
+}
+
+// loadManifest renders the load generator manifest for config
+func loadManifest(config LoadGeneratorConfig) ([]byte, error) {
+	var buf bytes.Buffer
+	err := loadTemplate.Execute(&buf, struct {
+		LoadGeneratorConfig
+		Namespace string
+		Name      string
+		// IntervalMillis is the interval between the objects in milliseconds
+		IntervalMillis int64
+	}{
+		LoadGeneratorConfig: config,
+		Namespace:           loadNamespace,
+		Name:                loadName,
+		IntervalMillis:      int64(time.Second/time.Millisecond) / int64(config.Rate),
+	})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (r LoadGeneratorConfig) withDefaults() LoadGeneratorConfig {
+	if r.Replicas == 0 {
+		r.Replicas = 1
+	}
+	if r.Rate == 0 {
+		r.Rate = 5
+	}
+	if r.Objects == 0 {
+		r.Objects = 100
+	}
+	if r.ObjectSize == 0 {
+		r.ObjectSize = 1024
+	}
+	if r.Image == "" {
+		r.Image = defaultLoadImage
+	}
+	return r
+}
+
+const (
+	loadNamespace = "robotest-load"
+	loadName      = "load-generator"
+	loadLabel     = "app=" + loadName
+	// defaultLoadImage is the image the load generator runs by default
+	defaultLoadImage = "bitnami/kubectl:1.17"
+)
+
+var loadTemplate = template.Must(template.New("load").Parse(`apiVersion: v1
+kind: Namespace
+metadata:
+  name: {{.Namespace}}
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: {{.Name}}
+  namespace: {{.Namespace}}
+rules:
+- apiGroups: [""]
+  resources: ["configmaps"]
+  verbs: ["create", "delete", "get", "list"]
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: {{.Name}}
+  namespace: {{.Namespace}}
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: Role
+  name: {{.Name}}
+subjects:
+- kind: ServiceAccount
+  name: default
+  namespace: {{.Namespace}}
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: {{.Name}}
+  namespace: {{.Namespace}}
+spec:
+  replicas: {{.Replicas}}
+  selector:
+    matchLabels:
+      app: {{.Name}}
+  template:
+    metadata:
+      labels:
+        app: {{.Name}}
+    spec:
+      securityContext:
+        runAsUser: 1001
+        runAsNonRoot: true
+      containers:
+      - name: load
+        image: {{.Image}}
+        command: ["/bin/sh", "-c"]
+        args:
+        - |
+          data=$(head -c {{.ObjectSize}} /dev/zero | tr '\0' x)
+          # the objects are scheduled at a fixed interval, so the time the requests take
+          # does not lower the rate
+          next=$(($(date +%s%N) / 1000000))
+          i=0
+          while true; do
+            kubectl create configmap load-$HOSTNAME-$i --from-literal=data=$data >/dev/null
+            kubectl delete configmap load-$HOSTNAME-$((i-{{.Objects}})) --ignore-not-found >/dev/null
+            if [ $((i % {{.Objects}})) -eq 0 ]; then kubectl get configmaps >/dev/null; fi
+            i=$((i+1))
+            next=$((next + {{.IntervalMillis}}))
+            delay=$((next - $(date +%s%N) / 1000000))
+            if [ $delay -gt 0 ]; then sleep $((delay / 1000)).$(printf %03d $((delay % 1000))); fi
+          done
+`))
@@ -0,0 +1,57 @@
+package gravity
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestLoadManifest(t *testing.T) {
+	manifest, err := loadManifest(LoadGeneratorConfig{Replicas: 3, Rate: 4}.withDefaults())
+	require.NoError(t, err)
+
+	var kinds []string
+	var deployment struct {
+		Spec struct {
+			Replicas int `yaml:"replicas"`
+			Template struct {
+				Spec struct {
+					Containers []struct {
+						Image string   `yaml:"image"`
+						Args  []string `yaml:"args"`
+					} `yaml:"containers"`
+				} `yaml:"spec"`
+			} `yaml:"template"`
+		} `yaml:"spec"`
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(manifest))
+	for {
+		var object map[string]interface{}
+		err := decoder.Decode(&object)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		kind, _ := object["kind"].(string)
+		kinds = append(kinds, kind)
+		if kind == "Deployment" {
+			data, err := yaml.Marshal(object)
+			require.NoError(t, err)
+			require.NoError(t, yaml.Unmarshal(data, &deployment))
+		}
+	}
+	assert.Equal(t, []string{"Namespace", "Role", "RoleBinding", "Deployment"}, kinds)
+	assert.Equal(t, 3, deployment.Spec.Replicas)
+	containers := deployment.Spec.Template.Spec.Containers
+	require.Len(t, containers, 1)
+	assert.Equal(t, defaultLoadImage, containers[0].Image)
+	require.Len(t, containers[0].Args, 1)
+	script := containers[0].Args[0]
+	assert.Contains(t, script, "head -c 1024 /dev/zero")
+	assert.Contains(t, script, "load-$HOSTNAME-$((i-100))")
+	assert.Contains(t, script, "next=$((next + 250))")
+}
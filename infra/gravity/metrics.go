@@ -0,0 +1,89 @@
+package gravity
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/gravitational/robotest/lib/shell"
+	sshutils "github.com/gravitational/robotest/lib/ssh"
+	"github.com/gravitational/robotest/lib/utils"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// CollectMetrics scrapes the metrics endpoints of the cluster services (etcd, kubelet, satellite)
+// on all nodes into metrics/prefix/<node IP>-<endpoint>.prom in the state directory.
+// Endpoints that cannot be scraped (i.e. etcd on regular nodes) are skipped
+func (c *TestContext) CollectMetrics(prefix string, nodes []Gravity) error {
+	targets, err := gravityNodes(nodes)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.CollectLogs)
+	defer cancel()
+
+	c.Logger().WithField("nodes", nodes).Debug("Collecting metrics from nodes.")
+	errs := make(chan error, len(targets))
+	for _, node := range targets {
+		go func(node *gravity) {
+			if node.Offline() {
+				errs <- nil
+				return
+			}
+			errs <- node.collectMetrics(ctx, prefix)
+		}(node)
+	}
+	return trace.Wrap(utils.CollectErrors(ctx, errs))
+}
+
+// collectMetrics scrapes the metrics endpoints on the node.
+// Returns an error only if none of the endpoints could be scraped
+func (g *gravity) collectMetrics(ctx context.Context, prefix string) error {
+	var errs []error
+	for _, endpoint := range metricsEndpoints {
+		localPath := filepath.Join(g.param.StateDir, "metrics", prefix,
+			fmt.Sprintf("%v-%v.prom", g.Node().PrivateAddr(), endpoint.name))
+		cmd := shell.InDir(g.installDir, shell.Sudo("./gravity", "enter", "--", "--notty", "/usr/bin/curl", "--",
+			"--silent", "--fail").Args(endpoint.args...))
+		err := sshutils.PipeCommand(ctx, g.Client(), g.Logger(), cmd, localPath)
+		g.Logger().WithFields(logrus.Fields{
+			logrus.ErrorKey: err,
+			"endpoint":      endpoint.name,
+			"path":          localPath,
+		}).Info("Fetching metrics.")
+		if err != nil {
+			errs = append(errs, trace.Wrap(err, endpoint.name))
+		}
+	}
+	if len(errs) == len(metricsEndpoints) {
+		return trace.NewAggregate(errs...)
+	}
+	return nil
+}
+
+type metricsEndpoint struct {
+	// name identifies the endpoint
+	name string
+	// args lists the curl arguments to scrape the endpoint inside planet
+	args []string
+}
+
+// metricsEndpoints lists the endpoints to scrape on each node
+var metricsEndpoints = []metricsEndpoint{
+	{
+		name: "etcd",
+		args: []string{"--cacert=/var/state/root.cert", "--cert=/var/state/etcd.cert",
+			"--key=/var/state/etcd.key", "https://127.0.0.1:2379/metrics"},
+	},
+	{
+		name: "kubelet",
+		args: []string{"--cacert=/var/state/root.cert", "--cert=/var/state/kubelet.cert",
+			"--key=/var/state/kubelet.key", "https://127.0.0.1:10250/metrics"},
+	},
+	{
+		name: "satellite",
+		args: []string{"http://127.0.0.1:7580/metrics"},
+	},
+}
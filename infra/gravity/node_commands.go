@@ -1,20 +1,23 @@
 package gravity
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"path/filepath"
 	"regexp"
 	"strings"
-	"text/template"
+	"sync"
 	"time"
 
 	"github.com/gravitational/robotest/infra"
 	"github.com/gravitational/robotest/lib/constants"
 	"github.com/gravitational/robotest/lib/defaults"
+	"github.com/gravitational/robotest/lib/failure"
+	"github.com/gravitational/robotest/lib/secret"
+	"github.com/gravitational/robotest/lib/shell"
 	sshutils "github.com/gravitational/robotest/lib/ssh"
+	"github.com/gravitational/robotest/lib/utils"
 	"github.com/gravitational/robotest/lib/wait"
 
 	"github.com/cenkalti/backoff"
@@ -64,8 +67,30 @@ type Gravity interface {
 	Upload(ctx context.Context) error
 	// Upgrade takes currently active installer (see SetInstaller) and tries to perform upgrade
 	Upgrade(ctx context.Context) error
+	// UpgradeManual starts the upgrade with the currently active installer in manual mode.
+	// The upgrade plan is then executed phase by phase with ExecutePhase
+	UpgradeManual(ctx context.Context) error
+	// ExecutePhase executes the specified phase of the active operation plan
+	ExecutePhase(ctx context.Context, phase string) error
+	// Rollback rolls back all phases of the active operation plan and completes the operation
+	Rollback(ctx context.Context) error
+	// ResumePlan resumes the execution of the active operation plan, i.e. an interrupted install
+	ResumePlan(ctx context.Context) error
+	// Backup runs the application backup hook and stores the backup at outputPath on the node
+	Backup(ctx context.Context, outputPath string) error
+	// Restore runs the application restore hook with the backup at backupPath on the node
+	Restore(ctx context.Context, backupPath string) error
+	// Version returns the version of the gravity binary in the install directory
+	Version(ctx context.Context) (*Version, error)
 	// RunInPlanet runs specific command inside Planet container and returns its result
 	RunInPlanet(ctx context.Context, cmd string, args ...string) (string, error)
+	// Drain cordons this node and evicts its pods, i.e. before maintenance
+	Drain(ctx context.Context) error
+	// Uncordon makes this node schedulable again after Drain
+	Uncordon(ctx context.Context) error
+	// IsLeader returns true if this node is the current cluster leader,
+	// i.e. runs the active Kubernetes control plane
+	IsLeader(ctx context.Context) (bool, error)
 	// Node returns underlying VM instance
 	Node() infra.Node
 	// Offline returns true if node was previously powered off
@@ -113,6 +138,63 @@ type InstallParam struct {
 	InstallerURL string `json:"installer_url,omitempty"`
 	// OpsAdvertiseAddr is optional Ops Center advertise address to pass to the install command
 	OpsAdvertiseAddr string `json:"ops_advertise_addr,omitempty"`
+	// AddressFamily optionally selects the address family (ipv4 or ipv6) of the node addresses
+	// used for the cluster. Defaults to ipv4, ipv6 requires dual-stack nodes
+	AddressFamily string `json:"address_family,omitempty" validate:"omitempty,eq=ipv4|eq=ipv6"`
+	// NodeRoles optionally assigns the roles (node profiles) to the nodes in order,
+	// i.e. 3 masters followed by 2 workers. Nodes not covered use Role
+	NodeRoles []RoleCount `json:"node_roles,omitempty" validate:"dive"`
+	// Image optionally specifies the reference of the cluster image to install
+	// from a registry instead of the installer tarball, i.e. registry.example.com/app:1.0.0.
+	// Requires the gravity binary in the install directory, see SetGravityBinary
+	Image string `json:"image,omitempty"`
+	// FIPS requests the cluster to be installed in FIPS mode.
+	// Requires the nodes in FIPS mode and FIPS gravity binaries
+	FIPS bool `json:"fips,omitempty"`
+	// ExtraArgs optionally lists additional arguments to pass to the install command as is,
+	// i.e. to exercise new gravity flags. Each argument is quoted for the shell
+	ExtraArgs []string `json:"extra_args,omitempty"`
+	// ExtraEnv optionally specifies additional environment variables for the install command
+	ExtraEnv map[string]string `json:"extra_env,omitempty"`
+}
+
+// checkExtraEnv verifies the names of the extra environment variables
+func (p InstallParam) checkExtraEnv() error {
+	for name := range p.ExtraEnv {
+		if !reEnvName.MatchString(name) {
+			return trace.BadParameter("invalid environment variable name %q", name)
+		}
+	}
+	return nil
+}
+
+var reEnvName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// RoleCount assigns the role to the given number of nodes
+type RoleCount struct {
+	// Role is the node role as defined in app.yaml
+	Role string `json:"role" validate:"required"`
+	// Count is the number of nodes with the role
+	Count uint `json:"count" validate:"gte=1"`
+}
+
+// RoleOf returns the role of the node with the given index
+func (p InstallParam) RoleOf(i int) string {
+	for _, role := range p.NodeRoles {
+		if i < int(role.Count) {
+			return role.Role
+		}
+		i -= int(role.Count)
+	}
+	return p.Role
+}
+
+// NodeRolesCount returns the number of nodes covered by NodeRoles
+func (p InstallParam) NodeRolesCount() (count int) {
+	for _, role := range p.NodeRoles {
+		count += int(role.Count)
+	}
+	return count
 }
 
 // JoinCmd represents various parameters for Join
@@ -127,6 +209,16 @@ type JoinCmd struct {
 	Role string
 	// StateDir is where all gravity data will be stored on the joining node
 	StateDir string
+	// AddressFamily selects the address family of the advertise address
+	// of the joining node. Defaults to ipv4
+	AddressFamily string
+	// AgentURL optionally specifies the agent-token URL to join with instead of
+	// PeerAddr and Token, i.e. https://10.0.0.1:3009/t/<token>/node.
+	// The agent script served at the URL is executed on the node
+	AgentURL string
+	// FIPS requests the node to join in FIPS mode.
+	// Not applicable with AgentURL
+	FIPS bool
 }
 
 // IsDegraded determines whether the cluster is in degraded state
@@ -152,18 +244,45 @@ type ClusterStatus struct {
 	Token Token `json:"token"`
 	// Nodes describes the nodes in the cluster
 	Nodes []NodeStatus `json:"nodes"`
+	// Endpoints lists the cluster endpoints
+	Endpoints Endpoints `json:"endpoints"`
+	// FIPS is true if the cluster has been installed in FIPS mode
+	FIPS bool `json:"fips,omitempty"`
+}
+
+// Endpoints lists the cluster endpoints
+type Endpoints struct {
+	// Cluster lists the cluster system endpoints
+	Cluster ClusterEndpoints `json:"cluster"`
+}
+
+// ClusterEndpoints lists the cluster system endpoints
+type ClusterEndpoints struct {
+	// UI lists the URLs of the cluster web endpoint, i.e. https://10.0.0.1:32009
+	UI []string `json:"ui,omitempty"`
 }
 
 // Application defines the cluster application
 type Application struct {
 	// Name is the name of the cluster application
 	Name string `json:"name"`
+	// Version is the version of the cluster application
+	Version string `json:"version"`
 }
 
 // NodeStatus describes the status of a cluster node
 type NodeStatus struct {
 	// Addr is the advertised address of this cluster node
 	Addr string `json:"advertise_ip"`
+	// Profile is the node profile (role) the node has been installed with
+	Profile string `json:"profile"`
+	// Role is the cluster role of the node, master or node
+	Role string `json:"role"`
+	// Leader is true if the node is the cluster leader.
+	// Only reported by gravity 7.0 and later
+	Leader bool `json:"leader,omitempty"`
+	// FailedProbes lists the health probes failing on the node
+	FailedProbes []string `json:"failed_probes,omitempty"`
 }
 
 // Token describes the cluster join token
@@ -173,12 +292,20 @@ type Token struct {
 }
 
 type gravity struct {
-	node       infra.Node
+	node infra.Node
+	// sshMu guards ssh, which is replaced when the node is disconnected or reconnected
+	sshMu      sync.RWMutex
 	ssh        *ssh.Client
+	transport  sshutils.Transport
 	installDir string
 	param      cloudDynamicParams
 	ts         time.Time
 	log        logrus.FieldLogger
+	// logs retains the recent journal lines of the node
+	logs *utils.LineBuffer
+	// version caches the version of the gravity binary in versionDir
+	version    *Version
+	versionDir string
 }
 
 func (g *gravity) MarshalJSON() ([]byte, error) {
@@ -190,12 +317,15 @@ func (g *gravity) MarshalJSON() ([]byte, error) {
 
 // waits for SSH to be up on node and returns client
 func sshClient(ctx context.Context, node infra.Node, log logrus.FieldLogger) (*ssh.Client, error) {
-	ctx, cancel := context.WithTimeout(ctx, deadlineSSH)
-	defer cancel()
-
 	var client *ssh.Client
-	b := backoff.NewConstantBackOff(retrySSH)
-	err := wait.RetryWithInterval(ctx, b, func() (err error) {
+	retry := wait.Retryer{
+		Delay:       time.Second,
+		MaxDelay:    retrySSH,
+		Jitter:      defaults.RetryJitter,
+		Budget:      deadlineSSH,
+		FieldLogger: log,
+	}
+	err := retry.Do(ctx, func() (err error) {
 		client, err = node.Client()
 		if err == nil {
 			log.Debug("Connected via SSH.")
@@ -204,7 +334,7 @@ func sshClient(ctx context.Context, node infra.Node, log logrus.FieldLogger) (*s
 
 		log.WithError(err).Debug("Waiting for SSH.")
 		return trace.Wrap(err)
-	}, log)
+	})
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
@@ -227,9 +357,48 @@ func (g *gravity) Node() infra.Node {
 
 // Client returns SSH client to the node
 func (g *gravity) Client() *ssh.Client {
+	g.sshMu.RLock()
+	defer g.sshMu.RUnlock()
 	return g.ssh
 }
 
+// connectedClient returns SSH client to the node or an error if the node is offline
+func (g *gravity) connectedClient() (*ssh.Client, error) {
+	client := g.Client()
+	if client == nil {
+		return nil, trace.ConnectionProblem(nil, "node %v is offline", g)
+	}
+	return client, nil
+}
+
+// setClient replaces the SSH client to the node and returns the previous one.
+// A nil client marks the node offline
+func (g *gravity) setClient(client *ssh.Client) (prev *ssh.Client) {
+	g.sshMu.Lock()
+	defer g.sshMu.Unlock()
+	prev, g.ssh = g.ssh, client
+	return prev
+}
+
+// run executes the command cmd on the node discarding its output
+func (g *gravity) run(ctx context.Context, log logrus.FieldLogger, cmd string, env map[string]string) error {
+	return sshutils.RunWith(ctx, g.commandTransport(), log, cmd, env)
+}
+
+// runAndParse executes the command cmd on the node and feeds its output to parse
+func (g *gravity) runAndParse(ctx context.Context, log logrus.FieldLogger, cmd string, env map[string]string, parse sshutils.OutputParseFn) error {
+	return g.commandTransport().RunAndParse(ctx, log, cmd, env, parse)
+}
+
+// commandTransport returns the transport to run commands with.
+// Unless overridden, commands are executed using the current SSH client
+func (g *gravity) commandTransport() sshutils.Transport {
+	if g.transport != nil {
+		return g.transport
+	}
+	return sshutils.ClientTransport(g.Client())
+}
+
 // Install runs gravity install with params
 func (g *gravity) Install(ctx context.Context, param InstallParam) error {
 	// cmd specify additional configuration for the install command
@@ -240,47 +409,56 @@ func (g *gravity) Install(ctx context.Context, param InstallParam) error {
 		DockerDevice  string
 		StorageDriver string
 		AgentLogPath  string
+		Registry      RegistryConfig
+		License       bool
 		InstallParam
 	}
 
+	if err := param.checkExtraEnv(); err != nil {
+		return trace.Wrap(err)
+	}
+
 	dockerDevice := g.param.dockerDevice
-	if g.param.storageDriver != constants.DeviceMapper {
+	if g.param.storageDriver != constants.DeviceMapper || !g.capabilities(ctx).dockerDevice {
 		// Docker device is not used with non-devicemapper storage drivers
+		// and is not supported since 7.x
 		dockerDevice = ""
 	}
 
+	privateAddr, err := advertiseAddr(g.Node(), param.AddressFamily)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
 	config := cmd{
 		InstallDir:    g.installDir,
-		PrivateAddr:   g.Node().PrivateAddr(),
+		PrivateAddr:   privateAddr,
 		DockerDevice:  dockerDevice,
 		StorageDriver: g.param.storageDriver.Driver(),
 		AgentLogPath:  defaults.AgentLogPath,
+		License:       g.param.License != "",
 		InstallParam:  param,
 	}
 
-	var buf bytes.Buffer
-	err := installCmdTemplate.Execute(&buf, config)
+	name := installCommand
+	env := g.param.secretEnv(param.Token)
+	if param.Image != "" {
+		name = installImageCommand
+		var registryEnv map[string]string
+		config.Registry, registryEnv = g.param.registry()
+		for key, value := range registryEnv {
+			env[key] = value
+		}
+	}
+	command, err := g.renderCommand(ctx, name, config)
 	if err != nil {
-		return trace.Wrap(err, buf.String())
+		return trace.Wrap(err)
 	}
 
-	err = sshutils.Run(ctx, g.Client(), g.Logger(), buf.String(), nil)
-	return trace.Wrap(err, param)
+	err = g.runWithSecrets(ctx, command, env)
+	return trace.Wrap(failure.GravityOperation(err), param)
 }
 
-var installCmdTemplate = template.Must(
-	template.New("gravity_install").Parse(`
-		cd {{.InstallDir}} && ./gravity version && sudo ./gravity install --debug \
-		--advertise-addr={{.PrivateAddr}} --token={{.Token}} --flavor={{.Flavor}} \
-		--docker-device={{.DockerDevice}} \
-		{{if .StorageDriver}}--storage-driver={{.StorageDriver}}{{end}} \
-		--system-log-file={{ .AgentLogPath }} \
-		--cloud-provider=generic --state-dir={{.StateDir}} \
-		--httpprofile=localhost:6061 \
-		{{if .Cluster}}--cluster={{.Cluster}}{{end}} \
-		{{if .OpsAdvertiseAddr}}--ops-advertise-addr={{.OpsAdvertiseAddr}}{{end}}
-`))
-
 // Status queries cluster status
 func (g *gravity) Status(ctx context.Context) (status *GravityStatus, err error) {
 	b := backoff.NewExponentialBackOff()
@@ -302,10 +480,10 @@ func (g *gravity) Status(ctx context.Context) (status *GravityStatus, err error)
 }
 
 func (g *gravity) status(ctx context.Context) (*GravityStatus, error) {
-	cmd := fmt.Sprintf("sudo gravity status --output=json --system-log-file=%v",
-		defaults.AgentLogPath)
+	cmd := shell.Sudo("gravity", "status", "--output=json",
+		"--system-log-file="+defaults.AgentLogPath).String()
 	status := GravityStatus{}
-	err := sshutils.RunAndParse(ctx, g.Client(), g.Logger(), cmd, nil, parseStatus(&status))
+	err := g.runAndParse(ctx, g.Logger(), cmd, nil, parseStatus(&status))
 	if err != nil {
 		if exitErr, ok := trace.Unwrap(err).(sshutils.ExitStatusError); ok {
 			g.Logger().WithFields(logrus.Fields{
@@ -336,35 +514,36 @@ func (g *gravity) Join(ctx context.Context, param JoinCmd) error {
 	}
 
 	dockerDevice := g.param.dockerDevice
-	if g.param.storageDriver != constants.DeviceMapper {
+	if g.param.storageDriver != constants.DeviceMapper || !g.capabilities(ctx).dockerDevice {
 		// Docker device is not used with non-devicemapper storage drivers
+		// and is not supported since 7.x
 		dockerDevice = ""
 	}
 
-	var buf bytes.Buffer
-	err := joinCmdTemplate.Execute(&buf, cmd{
+	privateAddr, err := advertiseAddr(g.Node(), param.AddressFamily)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	name := joinCommand
+	if param.AgentURL != "" {
+		name = agentJoinCommand
+	}
+	command, err := g.renderCommand(ctx, name, cmd{
 		InstallDir:   g.installDir,
-		PrivateAddr:  g.Node().PrivateAddr(),
+		PrivateAddr:  privateAddr,
 		DockerDevice: dockerDevice,
 		AgentLogPath: defaults.AgentLogPath,
 		JoinCmd:      param,
 	})
 	if err != nil {
-		return trace.Wrap(err, buf.String())
+		return trace.Wrap(err)
 	}
 
-	err = sshutils.Run(ctx, g.Client(), g.Logger(), buf.String(), nil)
-	return trace.Wrap(err, param)
+	err = g.runWithSecrets(ctx, command, g.param.secretEnv(param.Token))
+	return trace.Wrap(failure.GravityOperation(err), param)
 }
 
-var joinCmdTemplate = template.Must(
-	template.New("gravity_join").Parse(`
-		cd {{.InstallDir}} && sudo ./gravity join {{.PeerAddr}} \
-		--advertise-addr={{.PrivateAddr}} --token={{.Token}} --debug \
-		--role={{.Role}} --docker-device={{.DockerDevice}} \
-		--system-log-file={{.AgentLogPath}} --state-dir={{.StateDir}} \
-		--httpprofile=localhost:6061`))
-
 // Leave makes given node leave the cluster
 func (g *gravity) Leave(ctx context.Context, graceful Graceful) error {
 	var cmd string
@@ -379,20 +558,20 @@ func (g *gravity) Leave(ctx context.Context, graceful Graceful) error {
 
 // Remove ejects node from cluster
 func (g *gravity) Remove(ctx context.Context, node string, graceful Graceful) error {
-	var cmd string
-	if graceful {
-		cmd = fmt.Sprintf(`remove --confirm %s`, node)
-	} else {
-		cmd = fmt.Sprintf(`remove --confirm --force %s`, node)
+	cmd := shell.New("remove", "--confirm")
+	if !graceful {
+		cmd.Args("--force")
 	}
-	return trace.Wrap(g.runOp(ctx, cmd, nil))
+	return trace.Wrap(g.runOp(ctx, cmd.Args(node).String(), nil))
 }
 
 // Uninstall removes gravity installation. It requires Leave beforehand
 func (g *gravity) Uninstall(ctx context.Context) error {
-	cmd := fmt.Sprintf(`cd %s && sudo ./gravity system uninstall --confirm --system-log-file=%v`,
-		g.installDir, defaults.AgentLogPath)
-	err := sshutils.Run(ctx, g.Client(), g.Logger(), cmd, nil)
+	cmd, err := g.renderCommand(ctx, uninstallCommand, g.commandParams())
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	err = g.run(ctx, g.Logger(), cmd, nil)
 	return trace.Wrap(err, cmd)
 }
 
@@ -400,12 +579,31 @@ func (g *gravity) Uninstall(ctx context.Context) error {
 // This is usually required to properly clean up cloud resources
 // internally managed by kubernetes in case of kubernetes cloud integration
 func (g *gravity) UninstallApp(ctx context.Context) error {
-	cmd := fmt.Sprintf("cd %s && sudo ./gravity app uninstall $(./gravity app-package) --system-log-file=%v",
-		g.installDir, defaults.AgentLogPath)
-	err := sshutils.Run(ctx, g.Client(), g.Logger(), cmd, nil)
+	cmd, err := g.renderCommand(ctx, uninstallAppCommand, g.commandParams())
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	err = g.run(ctx, g.Logger(), cmd, nil)
 	return trace.Wrap(err, cmd)
 }
 
+// commandParams returns the parameters available to all command templates
+func (g *gravity) commandParams() commandParams {
+	return commandParams{
+		InstallDir:   g.installDir,
+		AgentLogPath: defaults.AgentLogPath,
+	}
+}
+
+// commandParams defines the parameters of the commands without
+// command-specific configuration
+type commandParams struct {
+	// InstallDir is the directory with the gravity binary
+	InstallDir string
+	// AgentLogPath is the path to the gravity system log file
+	AgentLogPath string
+}
+
 // PowerOff forcibly halts a machine
 func (g *gravity) PowerOff(ctx context.Context, graceful Graceful) error {
 	var cmd string
@@ -415,17 +613,17 @@ func (g *gravity) PowerOff(ctx context.Context, graceful Graceful) error {
 		cmd = "sudo poweroff -f"
 	}
 
-	err := sshutils.RunAndParse(ctx, g.Client(), g.Logger(), cmd, nil, nil)
+	err := g.runAndParse(ctx, g.Logger(), cmd, nil, nil)
 	if err != nil {
 		return trace.Wrap(err)
 	}
-	g.ssh = nil
+	g.setClient(nil)
 	// TODO: reliably destinguish between force close of SSH control channel and command being unable to run
 	return nil
 }
 
 func (g *gravity) Offline() bool {
-	return g.ssh == nil
+	return g.Client() == nil
 }
 
 // Reboot gracefully restarts a machine and waits for it to become available again
@@ -437,7 +635,7 @@ func (g *gravity) Reboot(ctx context.Context, graceful Graceful) error {
 		cmd = "sudo reboot -f"
 	}
 
-	err := sshutils.RunAndParse(ctx, g.Client(), g.Logger(), cmd, nil, nil)
+	err := g.runAndParse(ctx, g.Logger(), cmd, nil, nil)
 	if err != nil {
 		return trace.Wrap(err)
 	}
@@ -448,7 +646,7 @@ func (g *gravity) Reboot(ctx context.Context, graceful Graceful) error {
 		return trace.Wrap(err, "SSH reconnect")
 	}
 
-	g.ssh = client
+	g.setClient(client)
 	return nil
 }
 
@@ -457,15 +655,33 @@ func (g *gravity) Reboot(ctx context.Context, graceful Graceful) error {
 // arguments to the report command.
 // Returns the local path where the report files will be stored
 func (g *gravity) CollectLogs(ctx context.Context, prefix string, args ...string) (localPath string, err error) {
-	if g.ssh == nil {
+	if g.Offline() {
 		return "", trace.AccessDenied("cannot collect logs from an offline node %v", g)
 	}
 
 	localPath = filepath.Join(g.param.StateDir, "node-logs", prefix,
 		fmt.Sprintf("%v-logs.tgz", g.Node().PrivateAddr()))
-	return localPath, trace.Wrap(sshutils.PipeCommand(ctx, g.Client(), g.Logger(),
-		fmt.Sprintf("cd %v && sudo ./gravity system report %v", g.installDir,
-			strings.Join(args, " ")), localPath))
+	cmd := shell.InDir(g.installDir, shell.Sudo("./gravity", "system", "report").Args(args...))
+	if exclude := g.param.LogCollection.Exclude; len(exclude) != 0 {
+		cmd = filterReportCmd(cmd, exclude)
+	}
+	err = sshutils.PipeCommand(ctx, g.Client(), g.Logger(), cmd, localPath)
+	if err != nil {
+		return localPath, trace.Wrap(err)
+	}
+	return localPath, trace.Wrap(secret.RedactArchive(localPath))
+}
+
+// filterReportCmd wraps the report command to drop the report entries matching
+// the exclude patterns from the report tarball before it is written to stdout
+func filterReportCmd(reportCmd string, exclude []string) string {
+	var patterns []string
+	for _, pattern := range exclude {
+		patterns = append(patterns, shell.New("-name", pattern).String())
+	}
+	return fmt.Sprintf(`dir=$(mktemp -d) && trap 'sudo rm -rf $dir' EXIT && `+
+		`(%v) | sudo tar -xz -C $dir && sudo find $dir \( %v \) -prune -exec rm -rf {} + && sudo tar -cz -C $dir .`,
+		reportCmd, strings.Join(patterns, " -o "))
 }
 
 // SetInstaller transfers and prepares installer package given with installerUrl.
@@ -483,7 +699,7 @@ func (g *gravity) SetInstaller(ctx context.Context, installerURL string, subdir
 		return trace.Wrap(err)
 	}
 
-	err = sshutils.Run(ctx, g.Client(), log, fmt.Sprintf("tar -xvf %s -C %s", tgz, installDir), nil)
+	err = g.run(ctx, log, shell.New("tar", "-xvf", tgz, "-C", installDir).String(), nil)
 	if err != nil {
 		return trace.Wrap(err)
 	}
@@ -512,7 +728,8 @@ func (g *gravity) TransferFile(ctx context.Context, url, subdir string) error {
 	return nil
 }
 
-// ExecScript will transfer and execute script provided with given args
+// ExecScript will transfer and execute script provided with given args.
+// The arguments are quoted, so they are passed to the script as is
 func (g *gravity) ExecScript(ctx context.Context, scriptUrl string, args []string) error {
 	log := g.Logger().WithFields(logrus.Fields{
 		"script": scriptUrl, "args": args})
@@ -526,29 +743,58 @@ func (g *gravity) ExecScript(ctx context.Context, scriptUrl string, args []strin
 		return trace.Wrap(err)
 	}
 
-	err = sshutils.Run(ctx, g.Client(), log,
-		fmt.Sprintf("sudo /bin/bash -x %s %s", spath, strings.Join(args, " ")), nil)
+	err = g.run(ctx, log, shell.Sudo("/bin/bash", "-x", spath).Args(args...).String(), nil)
 	return trace.Wrap(err)
 }
 
 // Upload uploads packages in current installer dir to cluster
 func (g *gravity) Upload(ctx context.Context) error {
-	err := sshutils.Run(ctx, g.Client(), g.Logger(), fmt.Sprintf(`cd %s && sudo ./upload`, g.installDir), nil)
+	err := g.run(ctx, g.Logger(), shell.InDir(g.installDir, shell.Sudo("./upload")), nil)
 	return trace.Wrap(err)
 }
 
 // Upgrade takes current installer and tries to perform upgrade
 func (g *gravity) Upgrade(ctx context.Context) error {
 	executablePath := filepath.Join(g.installDir, "gravity")
-	return trace.Wrap(g.runOp(ctx,
-		fmt.Sprintf("upgrade $(%v app-package --state-dir=%v) --etcd-retry-timeout=%v",
-			executablePath,
-			g.installDir,
-			defaults.EtcdRetryTimeout),
+	var env map[string]string
+	if g.capabilities(ctx).blockingOperations {
 		// Run update unattended (changed in 5.4).
 		// Do this via the environment though to avoid breaking versions that
 		// update in a non-blocking mode by default
-		map[string]string{"GRAVITY_BLOCKING_OPERATION": "false"}))
+		env = map[string]string{"GRAVITY_BLOCKING_OPERATION": "false"}
+	}
+	cmd := shell.New("upgrade").
+		Raw(fmt.Sprintf("$(%v)", shell.New(executablePath, "app-package", "--state-dir="+g.installDir))).
+		Args(fmt.Sprintf("--etcd-retry-timeout=%v", defaults.EtcdRetryTimeout))
+	return trace.Wrap(g.runOp(ctx, cmd.String(), env))
+}
+
+// UpgradeManual starts the upgrade with the current installer in manual mode
+func (g *gravity) UpgradeManual(ctx context.Context) error {
+	cmd := shell.InDir(g.installDir, shell.Sudo("./gravity", "upgrade").
+		Raw(fmt.Sprintf("$(%v)", shell.New("./gravity", "app-package", "--state-dir="+g.installDir))).
+		Args("--manual", fmt.Sprintf("--etcd-retry-timeout=%v", defaults.EtcdRetryTimeout)))
+	return trace.Wrap(failure.GravityOperation(g.run(ctx, g.Logger(), cmd, nil)))
+}
+
+// ExecutePhase executes the specified phase of the active operation plan
+func (g *gravity) ExecutePhase(ctx context.Context, phase string) error {
+	cmd := shell.InDir(g.installDir, shell.Sudo("./gravity", "plan", "execute", "--phase="+phase))
+	return trace.Wrap(failure.GravityOperation(g.run(ctx, g.Logger(), cmd, nil)))
+}
+
+// Rollback rolls back the active operation plan and marks the operation completed
+func (g *gravity) Rollback(ctx context.Context) error {
+	cmd := shell.InDir(g.installDir,
+		shell.Sudo("./gravity", "plan", "rollback", "--confirm"),
+		shell.Sudo("./gravity", "plan", "complete"))
+	return trace.Wrap(failure.GravityOperation(g.run(ctx, g.Logger(), cmd, nil)))
+}
+
+// ResumePlan resumes the active operation plan
+func (g *gravity) ResumePlan(ctx context.Context) error {
+	cmd := shell.InDir(g.installDir, shell.Sudo("./gravity", "plan", "resume", "--system-log-file="+defaults.AgentLogPath))
+	return trace.Wrap(failure.GravityOperation(g.run(ctx, g.Logger(), cmd, nil)))
 }
 
 // for cases when gravity doesn't return just opcode but an extended message
@@ -559,60 +805,116 @@ const (
 	opStatusFailed    = "failed"
 )
 
-// runOp launches specific command and waits for operation to complete, ignoring transient errors
+// Backup runs the application backup hook and stores the backup at outputPath
+func (g *gravity) Backup(ctx context.Context, outputPath string) error {
+	cmd := shell.InDir(g.installDir, shell.Sudo("./gravity", "backup", outputPath))
+	return trace.Wrap(failure.GravityOperation(g.run(ctx, g.Logger(), cmd, nil)))
+}
+
+// Restore runs the application restore hook with the backup at backupPath
+func (g *gravity) Restore(ctx context.Context, backupPath string) error {
+	cmd := shell.InDir(g.installDir, shell.Sudo("./gravity", "restore", backupPath))
+	return trace.Wrap(failure.GravityOperation(g.run(ctx, g.Logger(), cmd, nil)))
+}
+
+// runOp launches specific command and waits for operation to complete, ignoring transient errors.
+// command is the command line of the gravity subcommand with the arguments quoted.
+// See waitForOperation for details
 func (g *gravity) runOp(ctx context.Context, command string, env map[string]string) error {
 	var code string
 	executablePath := filepath.Join(g.installDir, "gravity")
 	logPath := filepath.Join(g.installDir, defaults.AgentLogPath)
-	err := sshutils.RunAndParse(ctx, g.Client(), g.Logger(),
-		fmt.Sprintf(`sudo -E %v %v --insecure --quiet --system-log-file=%v`,
-			executablePath, command, logPath),
-		env, sshutils.ParseAsString(&code))
+	cmd := shell.Sudo("-E", executablePath).Raw(command).Args("--insecure", "--quiet", "--system-log-file="+logPath)
+	err := g.runAndParse(ctx, g.Logger(), cmd.String(), env, sshutils.ParseAsString(&code))
 	if err != nil {
-		return trace.Wrap(err)
+		return trace.Wrap(failure.GravityOperation(err))
 	}
 	if match := reGravityExtended.FindStringSubmatch(code); len(match) == 2 {
 		code = match[1]
 	}
 
-	retry := wait.Retryer{
-		Attempts:    1000,
-		Delay:       time.Second * 20,
-		FieldLogger: g.Logger().WithField("retry-operation", code),
+	return trace.Wrap(failure.GravityOperation(g.waitForOperation(ctx, code)))
+}
+
+// RunInPlanet executes given command inside Planet container.
+// The arguments are quoted, so they are passed to the command as is
+func (g *gravity) RunInPlanet(ctx context.Context, cmd string, args ...string) (string, error) {
+	var out string
+	err := g.runAndParse(ctx, g.Logger(), planetCommand(g.installDir, cmd, args...), nil, sshutils.ParseAsString(&out))
+	if err != nil {
+		return "", trace.Wrap(err)
 	}
 
-	err = retry.Do(ctx, func() error {
-		var response string
-		cmd := fmt.Sprintf(`cd %s && ./gravity status --operation-id=%s -q`, g.installDir, code)
-		err := sshutils.RunAndParse(ctx, g.Client(), g.Logger(),
-			cmd, nil, sshutils.ParseAsString(&response))
-		if err != nil {
-			return wait.Continue(cmd)
-		}
+	return out, nil
+}
 
-		switch strings.TrimSpace(response) {
-		case opStatusCompleted:
-			return nil
-		case opStatusFailed:
-			return wait.Abort(trace.Errorf("%s: response=%s, err=%v", cmd, response, err))
-		default:
-			return wait.Continue("non-final / unknown op status: %q", response)
-		}
-	})
-	return trace.Wrap(err)
+// planetCommand returns the command line that runs cmd with args inside Planet
+// with the gravity binary in installDir
+func planetCommand(installDir, cmd string, args ...string) string {
+	return shell.InDir(installDir, shell.Sudo("./gravity", "enter", "--", "--notty", cmd, "--").Args(args...))
 }
 
-// RunInPlanet executes given command inside Planet container
-func (g *gravity) RunInPlanet(ctx context.Context, cmd string, args ...string) (string, error) {
-	c := fmt.Sprintf(`cd %s && sudo ./gravity enter -- --notty %s -- %s`,
-		g.installDir, cmd, strings.Join(args, " "))
+// Drain cordons the node and evicts its pods with kubectl inside planet.
+// The node is verified to be reported as unschedulable afterwards
+func (g *gravity) Drain(ctx context.Context) error {
+	_, err := g.RunInPlanet(ctx, "/usr/bin/kubectl", "drain", g.Node().PrivateAddr(),
+		"--ignore-daemonsets", "--delete-local-data", "--force", fmt.Sprintf("--timeout=%v", drainTimeout))
+	if err != nil {
+		return trace.Wrap(err, "failed to drain %v", g)
+	}
+	return trace.Wrap(g.assertCordoned(ctx, true))
+}
 
-	var out string
-	err := sshutils.RunAndParse(ctx, g.Client(), g.Logger(), c, nil, sshutils.ParseAsString(&out))
+// Uncordon makes the node schedulable again with kubectl inside planet.
+// The node is verified to be reported as schedulable afterwards
+func (g *gravity) Uncordon(ctx context.Context) error {
+	_, err := g.RunInPlanet(ctx, "/usr/bin/kubectl", "uncordon", g.Node().PrivateAddr())
 	if err != nil {
-		return "", trace.Wrap(err)
+		return trace.Wrap(err, "failed to uncordon %v", g)
 	}
+	return trace.Wrap(g.assertCordoned(ctx, false))
+}
+
+// assertCordoned verifies that the node is reported as cordoned (unschedulable) if cordoned is true,
+// or as schedulable otherwise
+func (g *gravity) assertCordoned(ctx context.Context, cordoned bool) error {
+	out, err := g.RunInPlanet(ctx, "/usr/bin/kubectl", "get", "node", g.Node().PrivateAddr(),
+		"--output=jsonpath={.spec.unschedulable}")
+	if err != nil {
+		return trace.Wrap(err, "failed to query the node %v", g)
+	}
+	if unschedulable := strings.TrimSpace(out) == "true"; unschedulable != cordoned {
+		return trace.CompareFailed("expected %v to be cordoned=%v, got unschedulable=%q", g, cordoned, strings.TrimSpace(out))
+	}
+	return nil
+}
+
+// IsLeader returns true if this node is the current cluster leader.
+// The leadership is queried with the strategy for the gravity version, see leaderStrategies
+func (g *gravity) IsLeader(ctx context.Context) (bool, error) {
+	status, err := g.status(ctx)
+	if err != nil {
+		return false, trace.Wrap(err)
+	}
+	strategy := g.leaderStrategy(ctx)
+	isLeader, err := strategy.isLeader(ctx, g, status)
+	if err != nil {
+		return false, trace.Wrap(err, "failed to query leadership with %v", strategy.name)
+	}
+	return isLeader, nil
+}
 
+// gravityNodes returns the nodes as the gravity nodes they are expected to be.
+// Returns an error if any of the nodes is not supported, i.e. a fake node
+func gravityNodes(nodes []Gravity) (out []*gravity, err error) {
+	out = make([]*gravity, 0, len(nodes))
+	for _, node := range nodes {
+		g, ok := node.(*gravity)
+		if !ok {
+			return nil, trace.BadParameter("unsupported node %v", node)
+		}
+		out = append(out, g)
+	}
 	return out, nil
 }
 
@@ -0,0 +1,112 @@
+package gravity
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/gravitational/robotest/infra/providers/ops"
+	sshutils "github.com/gravitational/robotest/lib/ssh"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunOpReplay(t *testing.T) {
+	replayer, err := sshutils.LoadReplayer("testdata/runop.json")
+	require.NoError(t, err)
+
+	g := &gravity{
+		transport:  replayer,
+		installDir: "/home/robotest/installer",
+		log:        logrus.NewEntry(logrus.StandardLogger()),
+	}
+	err = g.Leave(context.Background(), Graceful(true))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, replayer.Remaining(), "all recorded interactions served")
+}
+
+func TestFilterReportCmd(t *testing.T) {
+	cmd := filterReportCmd("sudo ./gravity system report", []string{"*journal*", "etcd-backup.json"})
+	assert.Equal(t, `dir=$(mktemp -d) && trap 'sudo rm -rf $dir' EXIT && `+
+		`(sudo ./gravity system report) | sudo tar -xz -C $dir && `+
+		`sudo find $dir \( -name '*journal*' -o -name etcd-backup.json \) -prune -exec rm -rf {} + && `+
+		`sudo tar -cz -C $dir .`, cmd)
+
+	cmd = filterReportCmd("sudo ./gravity system report", []string{"it's $(id)"})
+	assert.Contains(t, cmd, `sudo find $dir \( -name 'it'\''s $(id)' \) -prune`, "patterns are shell-quoted")
+}
+
+func TestPlanetCommand(t *testing.T) {
+	cmd := planetCommand("/home/robotest/installer", "/usr/bin/kubectl", "get", "jobs",
+		`-ojsonpath={range .items[*]}{.metadata.name}{"\t"}{.status.succeeded}{"\n"}{end}`)
+	assert.Equal(t, `cd /home/robotest/installer && sudo ./gravity enter -- --notty /usr/bin/kubectl -- get jobs `+
+		`'-ojsonpath={range .items[*]}{.metadata.name}{"\t"}{.status.succeeded}{"\n"}{end}'`, cmd)
+}
+
+func TestStartCaptureCmd(t *testing.T) {
+	cmd := startCaptureCmd("failover", CaptureFilter{Ports: []int{7496, 2379}, Peers: []string{"10.0.0.1"}})
+	assert.Equal(t, "sudo nohup tcpdump -i any -n -Z root -C 100 -W 5 -w /var/tmp/robotest-failover.pcap "+
+		"'(port 7496 or port 2379) and (host 10.0.0.1)' > /dev/null 2>&1 &", cmd)
+
+	cmd = startCaptureCmd("all", CaptureFilter{FileSizeMB: 10, Files: 2})
+	assert.Equal(t, "sudo nohup tcpdump -i any -n -Z root -C 10 -W 2 -w /var/tmp/robotest-all.pcap > /dev/null 2>&1 &", cmd)
+
+	pattern := regexp.MustCompile(capturePattern("all"))
+	assert.True(t, pattern.MatchString("tcpdump -i any -n -Z root -C 10 -W 2 -w /var/tmp/robotest-all.pcap"))
+	assert.False(t, pattern.MatchString("sudo pkill -INT -f '"+capturePattern("all")+"'"), "matches itself")
+}
+
+func TestFetchCaptureCmd(t *testing.T) {
+	assert.Equal(t, `cd /var/tmp && sudo find . -maxdepth 1 -name 'robotest-recover.pcap*' -print0 | `+
+		`sudo tar -cz -C /var/tmp --null -T -`, fetchCaptureCmd("recover"))
+}
+
+func TestDrainVerifiesCordoned(t *testing.T) {
+	planetCmd := func(args string) string {
+		return "cd /home/robotest/installer && sudo ./gravity enter -- --notty /usr/bin/kubectl -- " + args
+	}
+	getNode := planetCmd("get node 10.0.0.1 '--output=jsonpath={.spec.unschedulable}'")
+	var testCases = []struct {
+		interactions []sshutils.Interaction
+		success      bool
+		comment      string
+	}{
+		{
+			interactions: []sshutils.Interaction{
+				{Command: planetCmd("drain 10.0.0.1 --ignore-daemonsets --delete-local-data --force --timeout=10m0s")},
+				{Command: getNode, Stdout: "true"},
+				{Command: planetCmd("uncordon 10.0.0.1")},
+				{Command: getNode, Stdout: ""},
+			},
+			success: true,
+			comment: "cordoned, then uncordoned",
+		},
+		{
+			interactions: []sshutils.Interaction{
+				{Command: planetCmd("drain 10.0.0.1 --ignore-daemonsets --delete-local-data --force --timeout=10m0s")},
+				{Command: getNode, Stdout: ""},
+			},
+			comment: "schedulable after drain",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.comment, func(t *testing.T) {
+			g := &gravity{
+				node:       ops.New("1.1.1.1", "10.0.0.1", "robotest", ""),
+				transport:  sshutils.NewReplayer(tc.interactions...),
+				installDir: "/home/robotest/installer",
+				log:        logrus.NewEntry(logrus.StandardLogger()),
+			}
+			err := g.Drain(context.Background())
+			if !tc.success {
+				assert.True(t, trace.IsCompareFailed(err), "expected compare failed, got %v", err)
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, g.Uncordon(context.Background()))
+		})
+	}
+}
@@ -1,19 +1,155 @@
 package gravity
 
 import (
+	"bufio"
 	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/gravitational/robotest/lib/constants"
+	"github.com/gravitational/robotest/lib/shell"
 	sshutil "github.com/gravitational/robotest/lib/ssh"
+	"github.com/gravitational/robotest/lib/utils"
 
 	"github.com/gravitational/trace"
 )
 
 func (g *gravity) streamLogs(ctx context.Context) error {
-	return trace.Wrap(sshutil.Run(ctx, g.Client(), g.Logger().WithField("source", "journalctl"),
-		"sudo /bin/journalctl --follow --output=cat", nil))
+	client, err := g.connectedClient()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(sshutil.RunAndParse(ctx, client, g.Logger().WithField("source", "journalctl"),
+		"sudo /bin/journalctl --follow --output=cat", nil, g.retainLines))
+}
+
+// retainLines reads the lines from r into the buffer of recent log lines
+func (g *gravity) retainLines(r *bufio.Reader) error {
+	for {
+		line, err := r.ReadString('\n')
+		if line != "" && g.logs != nil {
+			g.logs.Add(strings.TrimRight(line, "\r\n"))
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return trace.Wrap(err)
+		}
+	}
+}
+
+// recentLogLines returns the recent journal lines of the node
+func (g *gravity) recentLogLines() []string {
+	if g.logs == nil {
+		return nil
+	}
+	return g.logs.Lines()
 }
 
 func (g *gravity) streamStartupLogs(ctx context.Context) error {
-	return trace.Wrap(sshutil.Run(ctx, g.Client(), g.Logger().WithField("source", "journalctl"),
+	client, err := g.connectedClient()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(sshutil.Run(ctx, client, g.Logger().WithField("source", "journalctl"),
 		"sudo /bin/journalctl --identifier=startup-script --lines=all --output=cat --follow", nil))
 }
+
+// streamLogsToFiles follows the journal and the gravity system log on the node
+// until the context is cancelled, writing them into per-node files
+// under node-logs/stream in the state directory
+func (g *gravity) streamLogsToFiles(ctx context.Context) {
+	dir := filepath.Join(g.param.StateDir, "node-logs", "stream")
+	go g.followToFile(ctx, filepath.Join(dir, fmt.Sprintf("%v-journal.log", g.Node().PrivateAddr())),
+		func(since time.Time) string {
+			if since.IsZero() {
+				return "sudo /bin/journalctl --follow --lines=all --output=short-iso"
+			}
+			return shell.Sudo("/bin/journalctl", "--follow", "--output=short-iso",
+				fmt.Sprintf("--since=@%v", since.Unix())).String()
+		})
+	go g.followToFile(ctx, filepath.Join(dir, fmt.Sprintf("%v-system.log", g.Node().PrivateAddr())),
+		func(since time.Time) string {
+			lines := "+1"
+			if !since.IsZero() {
+				lines = "0"
+			}
+			return fmt.Sprintf("%v 2>/dev/null",
+				shell.Sudo("tail", "--lines="+lines, "--follow=name", "--retry").Args(systemLogPaths...))
+		})
+}
+
+// followToFile runs the command built with cmd and appends its output to the file at path
+// with each line prefixed with the receipt time. The command is restarted if the connection
+// to the node is lost or once the node is back online, with cmd given the time of the last restart
+func (g *gravity) followToFile(ctx context.Context, path string, cmd func(since time.Time) string) {
+	log := g.Logger().WithField("path", path)
+	err := os.MkdirAll(filepath.Dir(path), constants.SharedDirMask)
+	if err != nil {
+		log.WithError(err).Warn("Failed to create log directory.")
+		return
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, constants.SharedReadMask)
+	if err != nil {
+		log.WithError(err).Warn("Failed to create log file.")
+		return
+	}
+	defer f.Close()
+
+	var since time.Time
+	for {
+		start := time.Now()
+		client, err := g.connectedClient()
+		if err == nil {
+			err = sshutil.RunAndParse(ctx, client, log, cmd(since), nil, timestampLines(f))
+			// the lines up to the start of the stream have been received
+			since = start
+		}
+		if ctx.Err() != nil || utils.IsContextCancelledError(err) {
+			return
+		}
+		log.WithError(err).Debug("Log stream interrupted, will reconnect.")
+		select {
+		case <-time.After(logStreamReconnectDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// timestampLines returns the output parser that writes the lines into w
+// prefixed with the time they have been received
+func timestampLines(w io.Writer) sshutil.OutputParseFn {
+	return func(r *bufio.Reader) error {
+		for {
+			line, err := r.ReadString('\n')
+			if line != "" {
+				if _, errWrite := fmt.Fprintf(w, "%v %v", time.Now().UTC().Format(time.RFC3339Nano), line); errWrite != nil {
+					return trace.ConvertSystemError(errWrite)
+				}
+			}
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return trace.Wrap(err)
+			}
+		}
+	}
+}
+
+// systemLogPaths lists the locations of the gravity system log: telekube-system.log
+// on older versions and gravity-system.log on newer
+var systemLogPaths = []string{"/var/log/telekube-system.log", "/var/log/gravity-system.log"}
+
+// recentLogLines is the number of recent journal lines retained per node
+const recentLogLines = 200
+
+// logStreamReconnectDelay is the delay before the log stream is restarted
+// after the connection to the node has been lost
+const logStreamReconnectDelay = 10 * time.Second
@@ -0,0 +1,179 @@
+package gravity
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gravitational/robotest/infra"
+	"github.com/gravitational/robotest/lib/defaults"
+	"github.com/gravitational/robotest/lib/shell"
+
+	"github.com/gravitational/trace"
+)
+
+// NodePrepConfig configures the preparation of the nodes before install:
+// the state directory file system, kernel modules and sysctls.
+// The result is validated with preflight checks before the node is handed to the test
+type NodePrepConfig struct {
+	// StateDevice optionally specifies the block device to create the state
+	// directory file system on, i.e. /dev/xvdc
+	StateDevice string `yaml:"state_device"`
+	// StateDir specifies the mount point of the state directory.
+	// Defaults to /var/lib/gravity
+	StateDir string `yaml:"state_dir"`
+	// FSType specifies the file system to create on StateDevice.
+	// Defaults to ext4
+	FSType string `yaml:"fs_type" validate:"omitempty,eq=ext4|eq=xfs"`
+	// KernelModules lists the kernel modules to load.
+	// Defaults to the modules required by gravity
+	KernelModules []string `yaml:"kernel_modules"`
+	// Sysctls lists the kernel parameters to set in addition to the ones
+	// required by gravity
+	Sysctls map[string]string `yaml:"sysctls"`
+}
+
+// prepareNode prepares the node as configured and validates the result
+func prepareNode(ctx context.Context, node *gravity, config NodePrepConfig) error {
+	log := node.Logger()
+	log.Info("Prepare node.")
+	if bd, ok := node.Node().(infra.BlockDeviceNode); ok && config.StateDevice != "" {
+		config.StateDevice = bd.Device(config.StateDevice)
+	}
+	for _, cmd := range nodePrepCommands(config) {
+		err := node.run(ctx, log, cmd, nil)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return trace.Wrap(checkNodePrep(ctx, node, config))
+}
+
+// checkNodePrep verifies that the state directory is mounted and that the kernel modules
+// are loaded and the sysctls are set on the node
+func checkNodePrep(ctx context.Context, node *gravity, config NodePrepConfig) error {
+	var failures []string
+	for _, check := range nodePrepChecks(config) {
+		err := node.run(ctx, node.Logger(), check.command, nil)
+		if err != nil {
+			node.Logger().WithError(err).WithField("check", check.description).Warn("Preflight check failed.")
+			failures = append(failures, check.description)
+		}
+	}
+	if len(failures) != 0 {
+		return trace.CompareFailed("node %v failed preflight checks: %v", node, strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// nodePrepCommands returns the commands that prepare the node.
+// The commands are idempotent
+func nodePrepCommands(config NodePrepConfig) (commands []string) {
+	if config.StateDevice != "" {
+		dir, fsType := config.stateDir(), config.fsType()
+		force := "-F"
+		if fsType == "xfs" {
+			force = "-f"
+		}
+		commands = append(commands,
+			fmt.Sprintf("%v || %v", shell.Sudo("blkid", config.StateDevice),
+				shell.Sudo("mkfs."+fsType, force, config.StateDevice)),
+			shell.Sudo("mkdir", "-p", dir).String(),
+			fmt.Sprintf("%v || %v | %v", shell.New("grep", "-q", "^"+config.StateDevice+" ", "/etc/fstab"),
+				shell.New("echo", fmt.Sprintf("%v %v %v defaults 0 2", config.StateDevice, dir, fsType)),
+				shell.Sudo("tee", "-a", "/etc/fstab")),
+			fmt.Sprintf("%v || %v", shell.New("mountpoint", "-q", dir), shell.Sudo("mount", dir)),
+		)
+	}
+	modules := config.kernelModules()
+	for _, module := range modules {
+		commands = append(commands, shell.Sudo("modprobe", module).String())
+	}
+	commands = append(commands, fmt.Sprintf("%v | %v", shell.New("printf", `%s\n`).Args(modules...),
+		shell.Sudo("tee", "/etc/modules-load.d/robotest.conf")))
+	sysctls := config.sysctls()
+	var lines []string
+	for _, key := range sortedKeys(sysctls) {
+		commands = append(commands, shell.Sudo("sysctl", "-w", key+"="+sysctls[key]).String())
+		lines = append(lines, fmt.Sprintf("%v = %v", key, sysctls[key]))
+	}
+	commands = append(commands, fmt.Sprintf("%v | %v", shell.New("printf", `%s\n`).Args(lines...),
+		shell.Sudo("tee", "/etc/sysctl.d/99-robotest.conf")))
+	return commands
+}
+
+// nodePrepChecks returns the preflight checks validating the node preparation
+func nodePrepChecks(config NodePrepConfig) (checks []nodePrepCheck) {
+	if config.StateDevice != "" {
+		checks = append(checks, nodePrepCheck{
+			description: fmt.Sprintf("%v is mounted", config.stateDir()),
+			command:     fmt.Sprintf("mountpoint -q %v", config.stateDir()),
+		})
+	}
+	for _, module := range config.kernelModules() {
+		// built-in modules are listed in /sys/module as well
+		checks = append(checks, nodePrepCheck{
+			description: fmt.Sprintf("kernel module %v is loaded", module),
+			command:     fmt.Sprintf("test -d /sys/module/%v", module),
+		})
+	}
+	sysctls := config.sysctls()
+	for _, key := range sortedKeys(sysctls) {
+		checks = append(checks, nodePrepCheck{
+			description: fmt.Sprintf("%v is %v", key, sysctls[key]),
+			command:     fmt.Sprintf(`test "$(sysctl -n %v)" = %q`, key, sysctls[key]),
+		})
+	}
+	return checks
+}
+
+// nodePrepCheck is a single node preparation check
+type nodePrepCheck struct {
+	// description describes the expected state
+	description string
+	// command exits successfully if the node is in the expected state
+	command string
+}
+
+func (r NodePrepConfig) stateDir() string {
+	if r.StateDir != "" {
+		return r.StateDir
+	}
+	return defaults.GravityDir
+}
+
+func (r NodePrepConfig) fsType() string {
+	if r.FSType != "" {
+		return r.FSType
+	}
+	return "ext4"
+}
+
+func (r NodePrepConfig) kernelModules() []string {
+	if len(r.KernelModules) != 0 {
+		return r.KernelModules
+	}
+	return requiredKernelModules
+}
+
+// sysctls returns the configured sysctls merged over the ones required by gravity
+func (r NodePrepConfig) sysctls() map[string]string {
+	sysctls := make(map[string]string, len(requiredSysctls)+len(r.Sysctls))
+	for key, value := range requiredSysctls {
+		sysctls[key] = value
+	}
+	for key, value := range r.Sysctls {
+		sysctls[key] = value
+	}
+	return sysctls
+}
+
+var (
+	// requiredKernelModules lists the kernel modules gravity requires
+	requiredKernelModules = []string{"br_netfilter", "overlay", "ebtable_filter", "ip_tables", "iptable_filter", "iptable_nat"}
+	// requiredSysctls lists the kernel parameters gravity requires
+	requiredSysctls = map[string]string{
+		"net.bridge.bridge-nf-call-iptables": "1",
+		"net.ipv4.ip_forward":                "1",
+	}
+)
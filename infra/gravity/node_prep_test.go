@@ -0,0 +1,34 @@
+package gravity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodePrepCommands(t *testing.T) {
+	config := NodePrepConfig{
+		StateDevice:   "/dev/xvdc",
+		FSType:        "xfs",
+		KernelModules: []string{"overlay"},
+		Sysctls:       map[string]string{"fs.inotify.max_user_watches": "1048576"},
+	}
+	assert.Equal(t, []string{
+		"sudo blkid /dev/xvdc || sudo mkfs.xfs -f /dev/xvdc",
+		"sudo mkdir -p /var/lib/gravity",
+		"grep -q '^/dev/xvdc ' /etc/fstab || echo '/dev/xvdc /var/lib/gravity xfs defaults 0 2' | sudo tee -a /etc/fstab",
+		"mountpoint -q /var/lib/gravity || sudo mount /var/lib/gravity",
+		"sudo modprobe overlay",
+		`printf '%s\n' overlay | sudo tee /etc/modules-load.d/robotest.conf`,
+		"sudo sysctl -w fs.inotify.max_user_watches=1048576",
+		"sudo sysctl -w net.bridge.bridge-nf-call-iptables=1",
+		"sudo sysctl -w net.ipv4.ip_forward=1",
+		`printf '%s\n' 'fs.inotify.max_user_watches = 1048576' 'net.bridge.bridge-nf-call-iptables = 1' 'net.ipv4.ip_forward = 1' | sudo tee /etc/sysctl.d/99-robotest.conf`,
+	}, nodePrepCommands(config))
+
+	checks := nodePrepChecks(config)
+	assert.Len(t, checks, 5)
+	assert.Equal(t, "mountpoint -q /var/lib/gravity", checks[0].command)
+	assert.Equal(t, "test -d /sys/module/overlay", checks[1].command)
+	assert.Equal(t, `test "$(sysctl -n net.ipv4.ip_forward)" = "1"`, checks[4].command)
+}
@@ -0,0 +1,159 @@
+package gravity
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gravitational/robotest/lib/defaults"
+	"github.com/gravitational/robotest/lib/grafana"
+	"github.com/gravitational/robotest/lib/shell"
+	"github.com/gravitational/robotest/lib/wait"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// waitForOperation waits for the operation given with id to complete.
+// Instead of polling the operation state with a new SSH command every time, the state and the
+// operation plan are followed by a watch loop on the node over a single SSH session that reports
+// every operation state and plan phase transition.
+// The session is re-established if lost.
+// The operation state and top-level phase transitions are annotated in Grafana, see SetAnnotations
+func (g *gravity) waitForOperation(ctx context.Context, id string) error {
+	log := g.Logger().WithField("operation", id)
+	retry := wait.Retryer{
+		Delay:       defaults.OperationPollDelay,
+		MaxDelay:    defaults.OperationPollMaxDelay,
+		Jitter:      defaults.RetryJitter,
+		Budget:      defaults.OperationWaitBudget,
+		FieldLogger: log,
+	}
+	cmd := operationWatchCmd(g.installDir, id, operationWatchInterval)
+	annotate := func(text string, tags ...string) {
+		annotateTest(ctx, log, g.param.Tag(), grafana.Annotation{
+			Time: time.Now(),
+			Tags: append([]string{"operation"}, tags...),
+			Text: fmt.Sprintf("%v: operation %v %v", g.param.Tag(), id, text),
+		})
+	}
+	// the watcher is kept across watch sessions so that only the changes are reported
+	watcher := &operationWatcher{
+		log:     log,
+		phases:  make(map[string]string),
+		onState: func(state string) { annotate(state, state) },
+		onPhase: func(phase, state string) { annotate(fmt.Sprintf("phase %v %v", phase, state), "phase", state) },
+	}
+	return trace.Wrap(retry.Do(ctx, func() error {
+		err := g.runAndParse(ctx, log, cmd, nil, watcher.parse)
+		switch {
+		case watcher.state == opStatusCompleted:
+			return nil
+		case watcher.state == opStatusFailed:
+			return wait.Abort(trace.Errorf("operation %v failed", id))
+		case err != nil:
+			return wait.Continue("lost operation %v state watch: %v", id, err)
+		default:
+			return wait.Continue("operation %v state watch ended in state %q", id, watcher.state)
+		}
+	}))
+}
+
+// operationWatchCmd returns the command that checks the state and the plan of the operation given with id
+// every interval, prints either whenever it changes and exits once the operation has completed or failed.
+// The state is printed as "state <state>" and the plan, on a single line, as "plan <json>"
+func operationWatchCmd(installDir, id string, interval time.Duration) string {
+	return fmt.Sprintf(`cd %[1]v && prev="" && prevplan="" && while true; do `+
+		`state=$(%[2]v 2>/dev/null); `+
+		`plan=$(%[3]v 2>/dev/null | tr -d '\n'); `+
+		`if [ -n "$plan" ] && [ "$plan" != "$prevplan" ]; then echo "%[4]v $plan"; prevplan="$plan"; fi; `+
+		`if [ "$state" != "$prev" ]; then echo "%[5]v $state"; prev="$state"; fi; `+
+		`case "$state" in %[6]v|%[7]v) exit 0;; esac; `+
+		`sleep %[8]v; done`,
+		shell.Quote(installDir),
+		shell.New("./gravity", "status", "--operation-id="+id, "-q"),
+		shell.Sudo("./gravity", "plan", "--operation-id="+id, "--output=json"),
+		watchPlanPrefix, watchStatePrefix,
+		opStatusCompleted, opStatusFailed, int(interval.Seconds()))
+}
+
+// operationWatcher follows the operation state and the phases of the operation plan
+// reported by the watch command, see operationWatchCmd
+type operationWatcher struct {
+	log logrus.FieldLogger
+	// state is the last reported operation state
+	state string
+	// phases maps the IDs of the plan phases to their last reported state
+	phases map[string]string
+	// onState is called with each new operation state
+	onState func(state string)
+	// onPhase is called with each new state of a top-level phase
+	onPhase func(phase, state string)
+}
+
+// parse consumes the output of the watch command and logs the operation state
+// and phase transitions
+func (r *operationWatcher) parse(rd *bufio.Reader) error {
+	for {
+		line, err := rd.ReadString('\n')
+		r.update(strings.TrimSpace(line))
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return trace.Wrap(err)
+		}
+	}
+}
+
+func (r *operationWatcher) update(line string) {
+	switch {
+	case strings.HasPrefix(line, watchStatePrefix+" "):
+		state := strings.TrimSpace(strings.TrimPrefix(line, watchStatePrefix))
+		if state == "" || state == r.state {
+			return
+		}
+		r.log.WithFields(logrus.Fields{"from": r.state, "to": state}).Info("Operation state changed.")
+		r.state = state
+		r.onState(state)
+	case strings.HasPrefix(line, watchPlanPrefix+" "):
+		var plan planPhase
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, watchPlanPrefix)), &plan); err != nil {
+			r.log.WithError(err).Warn("Failed to parse operation plan.")
+			return
+		}
+		r.updatePhases(plan.Phases, true)
+	}
+}
+
+// updatePhases records the states of the phases and their subphases and logs the transitions
+func (r *operationWatcher) updatePhases(phases []planPhase, topLevel bool) {
+	for _, phase := range phases {
+		if prev, ok := r.phases[phase.ID]; !ok || prev != phase.State {
+			r.phases[phase.ID] = phase.State
+			// the phases are only reported once they have changed from their initial state
+			if ok || phase.State != phaseStateUnstarted {
+				r.log.WithFields(logrus.Fields{"phase": phase.ID, "from": prev, "to": phase.State}).Info("Phase state changed.")
+				if topLevel {
+					r.onPhase(phase.ID, phase.State)
+				}
+			}
+		}
+		r.updatePhases(phase.Phases, false)
+	}
+}
+
+const (
+	// operationWatchInterval defines how often the operation state is checked on the node
+	operationWatchInterval = 2 * time.Second
+	// watchStatePrefix prefixes the operation state in the output of the watch command
+	watchStatePrefix = "state"
+	// watchPlanPrefix prefixes the operation plan in the output of the watch command
+	watchPlanPrefix = "plan"
+	// phaseStateUnstarted is the state of a phase that has not been executed yet
+	phaseStateUnstarted = "unstarted"
+)
@@ -0,0 +1,39 @@
+package gravity
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOperationWatcher(t *testing.T) {
+	var states, phases []string
+	watcher := &operationWatcher{
+		log:     logrus.NewEntry(logrus.StandardLogger()),
+		phases:  make(map[string]string),
+		onState: func(state string) { states = append(states, state) },
+		onPhase: func(phase, state string) { phases = append(phases, phase+" "+state) },
+	}
+	sessions := []string{
+		`plan {"phases": [{"id": "/init", "state": "in_progress", "phases": [{"id": "/init/node-1", "state": "in_progress"}]}, {"id": "/masters", "state": "unstarted"}]}
+state in_progress
+`,
+		// the watch is re-established with the same state
+		`plan {"phases": [{"id": "/init", "state": "completed", "phases": [{"id": "/init/node-1", "state": "completed"}]}, {"id": "/masters", "state": "in_progress"}]}
+state in_progress
+plan invalid
+plan {"phases": [{"id": "/init", "state": "completed", "phases": [{"id": "/init/node-1", "state": "completed"}]}, {"id": "/masters", "state": "completed"}]}
+state completed`,
+	}
+	for _, session := range sessions {
+		require.NoError(t, watcher.parse(bufio.NewReader(strings.NewReader(session))))
+	}
+	assert.Equal(t, []string{"in_progress", "completed"}, states)
+	assert.Equal(t, []string{"/init in_progress", "/init completed", "/masters in_progress", "/masters completed"}, phases)
+	assert.Equal(t, "completed", watcher.phases["/init/node-1"])
+	assert.Equal(t, opStatusCompleted, watcher.state)
+}
@@ -0,0 +1,304 @@
+package gravity
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gravitational/robotest/lib/wait"
+
+	"github.com/gravitational/trace"
+)
+
+// OpsCenterConfig specifies the Ops Center to push cluster images to
+// and connect clusters to
+type OpsCenterConfig struct {
+	// URL specifies the Ops Center URL, i.e. https://opscenter.example.com
+	URL string `yaml:"url" validate:"required"`
+	// Key specifies the API key to log into the Ops Center with
+	Key string `yaml:"key" validate:"required"`
+	// ClusterToken specifies the token clusters connect to the Ops Center with
+	ClusterToken string `yaml:"cluster_token"`
+	// TunnelPort specifies the reverse tunnel port of the Ops Center.
+	// Defaults to 3024
+	TunnelPort int `yaml:"tunnel_port"`
+}
+
+// PushToOpsCenter pushes the cluster image (installer tarball) at the local path
+// to the configured Ops Center
+func (c *TestContext) PushToOpsCenter(installerPath string) error {
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.WaitForInstaller)
+	defer cancel()
+
+	if err := c.teleLogin(ctx); err != nil {
+		return trace.Wrap(err)
+	}
+	c.Logger().WithField("installer", installerPath).Info("Push cluster image to Ops Center.")
+	return trace.Wrap(runTele(ctx, "push", installerPath))
+}
+
+// PullFromOpsCenter downloads the installer of the application (name:version)
+// from the configured Ops Center into the local output path
+func (c *TestContext) PullFromOpsCenter(app, outputPath string) error {
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.WaitForInstaller)
+	defer cancel()
+
+	if err := c.teleLogin(ctx); err != nil {
+		return trace.Wrap(err)
+	}
+	c.Logger().WithField("app", app).Info("Pull installer from Ops Center.")
+	return trace.Wrap(runTele(ctx, "pull", app, fmt.Sprintf("--output=%v", outputPath)))
+}
+
+// InstallLink creates the one-time installation link of the application (name:version
+// or repository/name:version) in the configured Ops Center.
+// The link downloads the installer once, see DownloadInstaller
+func (c *TestContext) InstallLink(app string) (string, error) {
+	cfg := c.provisionerConfig().OpsCenter
+	if cfg == nil {
+		return "", trace.BadParameter("no Ops Center configured")
+	}
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	c.Logger().WithField("app", app).Info("Create Ops Center install link.")
+	link, err := createInstallLink(ctx, opsCenterHTTPClient(), *cfg, app)
+	return link, trace.Wrap(err)
+}
+
+// DownloadInstaller downloads the installer from the one-time installation link
+// into the local output path
+func (c *TestContext) DownloadInstaller(link, outputPath string) error {
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.WaitForInstaller)
+	defer cancel()
+
+	c.Logger().WithField("installer", outputPath).Info("Download installer from Ops Center install link.")
+	return trace.Wrap(downloadFile(ctx, opsCenterHTTPClient(), link, outputPath))
+}
+
+// ConnectToOpsCenter connects the cluster to the configured Ops Center for remote
+// support by creating a trusted cluster resource on the master node
+func (c *TestContext) ConnectToOpsCenter(master Gravity) error {
+	cfg := c.provisionerConfig().OpsCenter
+	if cfg == nil {
+		return trace.BadParameter("no Ops Center configured")
+	}
+	if cfg.ClusterToken == "" {
+		return trace.BadParameter("Ops Center cluster token is required to connect clusters")
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	tunnelPort := cfg.TunnelPort
+	if tunnelPort == 0 {
+		tunnelPort = defaultTunnelPort
+	}
+	webProxyAddr := u.Host
+	if u.Port() == "" {
+		webProxyAddr = fmt.Sprintf("%v:443", u.Hostname())
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	g, ok := master.(*gravity)
+	if !ok {
+		return trace.BadParameter("unsupported node %v", master)
+	}
+	c.Logger().WithField("ops_center", cfg.URL).Info("Connect cluster to Ops Center.")
+	cmd := fmt.Sprintf(`cat > /tmp/trusted_cluster.yaml <<'EOF'
+kind: trusted_cluster
+version: v2
+metadata:
+  name: %[1]v
+spec:
+  enabled: true
+  pull_updates: true
+  token: %[2]v
+  tunnel_addr: "%[1]v:%[3]v"
+  web_proxy_addr: "%[4]v"
+EOF
+cd %[5]v && sudo ./gravity resource create /tmp/trusted_cluster.yaml`,
+		u.Hostname(), cfg.ClusterToken, tunnelPort, webProxyAddr, g.installDir)
+	return trace.Wrap(g.run(ctx, g.Logger(), cmd, nil))
+}
+
+// VerifyRemoteSupport verifies that the cluster is connected to and active
+// in the configured Ops Center
+func (c *TestContext) VerifyRemoteSupport(master Gravity) error {
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	status, err := master.Status(ctx)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if err := c.teleLogin(ctx); err != nil {
+		return trace.Wrap(err)
+	}
+	clusterName := status.Cluster.Cluster
+	retry := wait.Retryer{
+		Delay:       15 * time.Second,
+		Attempts:    40,
+		FieldLogger: c.Logger().WithField("cluster", clusterName),
+	}
+	err = retry.Do(ctx, func() error {
+		status, err := getTeleClusterStatus(clusterName)
+		if err != nil {
+			return wait.Continue("cluster %v not available in Ops Center: %v", clusterName, err)
+		}
+		if status != "active" {
+			return wait.Continue("cluster %v is %q in Ops Center", clusterName, status)
+		}
+		return nil
+	})
+	return trace.Wrap(err)
+}
+
+func (c *TestContext) teleLogin(ctx context.Context) error {
+	cfg := c.provisionerConfig().OpsCenter
+	if cfg == nil {
+		return trace.BadParameter("no Ops Center configured")
+	}
+	return trace.Wrap(runTele(ctx, "login", "-o", cfg.URL, "--key", cfg.Key))
+}
+
+func runTele(ctx context.Context, args ...string) error {
+	out, err := exec.CommandContext(ctx, "tele", args...).CombinedOutput()
+	if err != nil {
+		return trace.Wrap(err, "tele %v: %s", args[0], out)
+	}
+	return nil
+}
+
+// createInstallLink creates the install token for the application in the Ops Center
+// and returns the link to download the installer with the token
+func createInstallLink(ctx context.Context, client *http.Client, cfg OpsCenterConfig, app string) (string, error) {
+	repository, name, version, err := parseApp(app)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	base, err := url.Parse(cfg.URL)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	body, err := json.Marshal(installTokenRequest{
+		App:     fmt.Sprintf("%v/%v:%v", repository, name, version),
+		Account: opsCenterAccountID,
+	})
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	tokens := *base
+	tokens.Path = path.Join(base.Path, "/portalapi/v1/tokens/install")
+	req, err := http.NewRequest(http.MethodPost, tokens.String(), bytes.NewReader(body))
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+cfg.Key)
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", trace.ConnectionProblem(err, "failed to reach the Ops Center")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		out, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", trace.BadParameter("failed to create install token: %v: %s", resp.Status, out)
+	}
+	var token installToken
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", trace.Wrap(err)
+	}
+	if token.Token == "" {
+		return "", trace.BadParameter("Ops Center returned no install token")
+	}
+	link := *base
+	link.Path = path.Join(base.Path, "/portalapi/v1/apps", repository, name, version, "installer")
+	link.RawQuery = url.Values{"install_token": []string{token.Token}}.Encode()
+	return link.String(), nil
+}
+
+// downloadFile downloads the file at the URL into the local path
+func downloadFile(ctx context.Context, client *http.Client, link, outputPath string) error {
+	req, err := http.NewRequest(http.MethodGet, link, nil)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return trace.ConnectionProblem(err, "failed to reach the Ops Center")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		out, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		return trace.BadParameter("failed to download installer: %v: %s", resp.Status, out)
+	}
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return trace.Wrap(err)
+	}
+	return trace.ConvertSystemError(f.Close())
+}
+
+// parseApp parses the application locator, either name:version
+// or repository/name:version
+func parseApp(app string) (repository, name, version string, err error) {
+	parts := strings.Split(app, ":")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", "", trace.BadParameter("expected application as name:version, got %q", app)
+	}
+	repository, name = defaultAppRepository, parts[0]
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		repository, name = name[:i], name[i+1:]
+	}
+	if repository == "" || name == "" {
+		return "", "", "", trace.BadParameter("expected application as repository/name:version, got %q", app)
+	}
+	return repository, name, parts[1], nil
+}
+
+// opsCenterHTTPClient returns the client for the Ops Center API.
+// Ops Centers of the tests usually have self-signed certificates
+func opsCenterHTTPClient() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+}
+
+type installTokenRequest struct {
+	App     string `json:"app"`
+	Account string `json:"account"`
+}
+
+type installToken struct {
+	Token string `json:"token"`
+}
+
+const (
+	// defaultTunnelPort is the default Ops Center reverse tunnel port
+	defaultTunnelPort = 3024
+	// defaultAppRepository is the repository of applications given without one
+	defaultAppRepository = "gravitational.io"
+	// opsCenterAccountID is the ID of the system account the install tokens are created for
+	opsCenterAccountID = "00000000-0000-0000-0000-000000000001"
+)
@@ -0,0 +1,83 @@
+package gravity
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstallsFromLink(t *testing.T) {
+	var (
+		auth    string
+		request installTokenRequest
+		token   string
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ops/portalapi/v1/tokens/install", func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(installToken{Token: "one-time"})
+	})
+	mux.HandleFunc("/ops/portalapi/v1/apps/gravitational.io/telekube/5.5.0/installer", func(w http.ResponseWriter, r *http.Request) {
+		token = r.URL.Query().Get("install_token")
+		w.Write([]byte("installer"))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx := context.Background()
+	cfg := OpsCenterConfig{URL: srv.URL + "/ops", Key: "secret"}
+	link, err := createInstallLink(ctx, srv.Client(), cfg, "telekube:5.5.0")
+	require.NoError(t, err)
+	assert.Equal(t, srv.URL+"/ops/portalapi/v1/apps/gravitational.io/telekube/5.5.0/installer?install_token=one-time", link)
+	assert.Equal(t, "Bearer secret", auth)
+	assert.Equal(t, installTokenRequest{App: "gravitational.io/telekube:5.5.0", Account: opsCenterAccountID}, request)
+
+	dir, err := ioutil.TempDir("", "robotest-opscenter")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "installer.tar")
+	require.NoError(t, downloadFile(ctx, srv.Client(), link, path))
+	assert.Equal(t, "one-time", token)
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "installer", string(data))
+
+	_, err = createInstallLink(ctx, srv.Client(), cfg, "telekube")
+	assert.Error(t, err, "application without version")
+	assert.Error(t, downloadFile(ctx, srv.Client(), srv.URL+"/ops/missing", path), "missing installer")
+}
+
+func TestParsesApp(t *testing.T) {
+	var testCases = []struct {
+		app                       string
+		repository, name, version string
+		err                       bool
+	}{
+		{app: "telekube:5.5.0", repository: "gravitational.io", name: "telekube", version: "5.5.0"},
+		{app: "example.com/app:1.0.0", repository: "example.com", name: "app", version: "1.0.0"},
+		{app: "telekube", err: true},
+		{app: "/app:1.0.0", err: true},
+		{app: "telekube:", err: true},
+	}
+	for _, tc := range testCases {
+		repository, name, version, err := parseApp(tc.app)
+		if tc.err {
+			assert.Error(t, err, tc.app)
+			continue
+		}
+		require.NoError(t, err, tc.app)
+		assert.Equal(t, []string{tc.repository, tc.name, tc.version}, []string{repository, name, version}, tc.app)
+	}
+}
@@ -0,0 +1,118 @@
+package gravity
+
+import (
+	"context"
+
+	sshutils "github.com/gravitational/robotest/lib/ssh"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// OSUpdate selects the operating system updates to apply with PatchOS
+type OSUpdate string
+
+const (
+	// OSUpdateKernel upgrades the kernel package only
+	OSUpdateKernel OSUpdate = "kernel"
+	// OSUpdateAll applies all available distribution updates
+	OSUpdateAll OSUpdate = "all"
+)
+
+// PatchOS applies the operating system updates on the nodes one node at a time,
+// the way the hosts are patched between gravity upgrades: the node is drained,
+// updated and rebooted, then uncordoned once the cluster status is reported on all nodes
+// and the workload is healthy again.
+// With OSUpdateKernel, the node is expected to boot with a new kernel release
+func (c *TestContext) PatchOS(nodes []Gravity, update OSUpdate) error {
+	for _, node := range nodes {
+		g, ok := node.(*gravity)
+		if !ok {
+			return trace.BadParameter("unsupported node %v", node)
+		}
+		if err := c.patchNode(nodes, g, update); err != nil {
+			return trace.Wrap(err, "failed to patch %v", node)
+		}
+	}
+	return nil
+}
+
+func (c *TestContext) patchNode(nodes []Gravity, node *gravity, update OSUpdate) error {
+	cmd, err := osUpdateCommand(node.param.os.Vendor, update)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Upgrade)
+	defer cancel()
+
+	before, err := kernelRelease(ctx, node)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	log := c.Logger().WithFields(logrus.Fields{"node": node, "update": update})
+
+	log.Info("Drain node.")
+	if err := node.Drain(ctx); err != nil {
+		return trace.Wrap(err)
+	}
+
+	log.Info("Update operating system.")
+	err = c.withEgress([]Gravity{node}, func() error {
+		return node.run(ctx, log, cmd, nil)
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	log.Info("Reboot.")
+	if err := node.Reboot(ctx, Graceful(true)); err != nil {
+		return trace.Wrap(err)
+	}
+	after, err := kernelRelease(ctx, node)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	log.WithFields(logrus.Fields{"before": before, "after": after}).Info("Kernel release.")
+	if after == before {
+		if update == OSUpdateKernel {
+			return trace.CompareFailed("kernel release %v has not changed after the kernel update", before)
+		}
+		log.Warn("Kernel release has not changed.")
+	}
+
+	if err := c.Status(nodes); err != nil {
+		return trace.Wrap(err)
+	}
+	if err := node.Uncordon(ctx); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(c.CheckWorkloadHealth(nodes))
+}
+
+// osUpdateCommand returns the command to apply the update on the distribution given with vendor
+func osUpdateCommand(vendor string, update OSUpdate) (string, error) {
+	switch {
+	case isRedHatFamily(vendor) && update == OSUpdateKernel:
+		return "sudo yum update -y kernel", nil
+	case isRedHatFamily(vendor) && update == OSUpdateAll:
+		return "sudo yum update -y", nil
+	case update == OSUpdateKernel:
+		// install the latest release of the kernel flavor the node runs, i.e. linux-image-aws
+		return `sudo apt-get update && sudo DEBIAN_FRONTEND=noninteractive apt-get install -y ` +
+			`"linux-image-$(uname -r | sed 's/^.*-//')"`, nil
+	case update == OSUpdateAll:
+		return "sudo apt-get update && sudo DEBIAN_FRONTEND=noninteractive apt-get upgrade -y", nil
+	}
+	return "", trace.BadParameter("unknown OS update %q, expected %v or %v", update, OSUpdateKernel, OSUpdateAll)
+}
+
+// kernelRelease returns the release of the kernel running on the node
+func kernelRelease(ctx context.Context, node *gravity) (string, error) {
+	var out string
+	err := node.runAndParse(ctx, node.Logger(), "uname -r", nil, sshutils.ParseAsString(&out))
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	return out, nil
+}
@@ -20,6 +20,13 @@ func parseStatus(status *GravityStatus) sshutils.OutputParseFn {
 	}
 }
 
+func parseVersion(version *Version) sshutils.OutputParseFn {
+	return func(r *bufio.Reader) error {
+		decoder := json.NewDecoder(r)
+		return trace.Wrap(decoder.Decode(version))
+	}
+}
+
 // from https://github.com/gravitational/gravity/blob/master/lib/utils/parse.go
 //
 // ParseDDOutput parses the output of "dd" command and returns the reported
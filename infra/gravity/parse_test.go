@@ -1,8 +1,11 @@
 package gravity
 
 import (
+	"encoding/base64"
 	"flag"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -46,3 +49,86 @@ func TestDDOutputParser(t *testing.T) {
 		assert.Equal(t, bps, testCase.expectedBps, testCase.comment)
 	}
 }
+
+func TestEtcdVersionParser(t *testing.T) {
+	version, err := parseEtcdVersion(`etcd Version: 3.4.9
+Git SHA: 54ba95891
+Go Version: go1.12.17
+Go OS/Arch: linux/amd64`)
+	require.NoError(t, err)
+	assert.Equal(t, "3.4.9", version)
+
+	_, err = parseEtcdVersion("command not found")
+	assert.Error(t, err)
+}
+
+func TestEtcdMembersParser(t *testing.T) {
+	members, err := parseEtcdMembers(`
+8e9e05c52164694d: name=10_0_0_1 peerURLs=https://10.0.0.1:2380 clientURLs=https://10.0.0.1:2379 isLeader=true
+91bc3c398fb3c146: name=10_0_0_2 peerURLs=https://10.0.0.2:2380 clientURLs=https://10.0.0.2:2379 isLeader=false
+`)
+	require.NoError(t, err)
+	require.Len(t, members, 2)
+	assert.Equal(t, "8e9e05c52164694d", members[0].ID)
+	assert.True(t, members[0].Leader)
+	assert.True(t, members[1].hasPeer("10.0.0.2"))
+	assert.False(t, members[1].hasPeer("10.0.0.1"))
+
+	_, err = parseEtcdMembers("Error: client: etcd cluster is unavailable or misconfigured")
+	assert.Error(t, err)
+}
+
+func TestCertificateParser(t *testing.T) {
+	cert, err := parseCertificate("serial=5E2C1A\nnotBefore=Jun  5 10:20:30 2020 GMT\nnotAfter=Jun  5 10:20:30 2030 GMT\n")
+	require.NoError(t, err)
+	assert.Equal(t, "5E2C1A", cert.serial)
+	assert.Equal(t, time.Date(2020, time.June, 5, 10, 20, 30, 0, time.UTC), cert.notBefore.UTC())
+	assert.Equal(t, time.Date(2030, time.June, 5, 10, 20, 30, 0, time.UTC), cert.notAfter.UTC())
+
+	_, err = parseCertificate("unable to load certificate")
+	assert.Error(t, err)
+	_, err = parseCertificate("serial=5E2C1A\nnotAfter=never\n")
+	assert.Error(t, err)
+}
+
+func TestStaleLeases(t *testing.T) {
+	since := time.Date(2030, time.June, 5, 10, 0, 0, 0, time.UTC)
+	stale, err := staleLeases("kube-node-lease/node-1\t2030-06-05T10:00:01.000000Z\n"+
+		"kube-system/kube-scheduler\t2020-06-05T10:00:00.000000Z\n"+
+		"kube-system/released\t\n", since)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"kube-system/kube-scheduler (2020-06-05T10:00:00Z)"}, stale)
+
+	_, err = staleLeases("kube-node-lease/node-1\tyesterday\n", since)
+	assert.Error(t, err)
+}
+
+func TestStaleTokens(t *testing.T) {
+	since := time.Unix(1906000000, 0)
+	claims := func(iat int64) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp":%v,"iat":%v}`, iat+3600, iat)))
+	}
+	stale, err := staleTokens("/pods/1/token "+claims(1906000001)+"\n/pods/2/token "+claims(1000000000)+"\n", since)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/pods/2/token (2001-09-09T01:46:40Z)"}, stale)
+
+	_, err = staleTokens("/pods/1/token !invalid\n", since)
+	assert.Error(t, err)
+}
+
+func TestPlanPhaseState(t *testing.T) {
+	plan := []byte(`{"operation_id":"c2f4","phases":[
+{"id":"/init","state":"completed","phases":[{"id":"/init/node-1","state":"completed"}]},
+{"id":"/masters","state":"in_progress","phases":[{"id":"/masters/node-1","state":"unstarted"}]}]}`)
+
+	state, err := findPhaseState(plan, "/init")
+	require.NoError(t, err)
+	assert.Equal(t, "completed", state)
+
+	state, err = findPhaseState(plan, "/masters/node-1")
+	require.NoError(t, err)
+	assert.Equal(t, "unstarted", state)
+
+	_, err = findPhaseState(plan, "/app")
+	assert.Error(t, err)
+}
@@ -0,0 +1,52 @@
+package gravity
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gravitational/robotest/lib/shell"
+
+	"github.com/gravitational/trace"
+)
+
+// Partition isolates node from the peers on the network: all traffic between
+// the private addresses of node and each of the peers is dropped on node.
+// The partition is removed with Heal
+func (c *TestContext) Partition(node Gravity, peers []Gravity) error {
+	var addrs []string
+	for _, peer := range peers {
+		addrs = append(addrs, peer.Node().PrivateAddr())
+	}
+	c.Logger().WithField("node", node).WithField("peers", Nodes(peers)).Info("Partition node.")
+	return trace.Wrap(c.runOnNodes([]Gravity{node}, partitionCmd(addrs)))
+}
+
+// Heal removes the network partitions from the nodes
+func (c *TestContext) Heal(nodes []Gravity) error {
+	c.Logger().WithField("nodes", Nodes(nodes)).Info("Heal network partition.")
+	return trace.Wrap(c.runOnNodes(nodes, healCmd))
+}
+
+// partitionChain is the iptables chain with the network partition rules
+const partitionChain = "ROBOTEST-PARTITION"
+
+func partitionCmd(addrs []string) string {
+	rules := []string{
+		fmt.Sprintf("(sudo iptables -N %[1]v || sudo iptables -F %[1]v)", partitionChain),
+	}
+	for _, addr := range addrs {
+		rules = append(rules,
+			shell.Sudo("iptables", "-A", partitionChain, "-s", addr, "-j", "DROP").String(),
+			shell.Sudo("iptables", "-A", partitionChain, "-d", addr, "-j", "DROP").String())
+	}
+	for _, chain := range []string{"INPUT", "OUTPUT"} {
+		rules = append(rules, fmt.Sprintf("(sudo iptables -C %[1]v -j %[2]v || sudo iptables -I %[1]v 1 -j %[2]v)",
+			chain, partitionChain))
+	}
+	return strings.Join(rules, " && ")
+}
+
+var healCmd = fmt.Sprintf(
+	"while sudo iptables -D INPUT -j %[1]v 2>/dev/null; do :; done; "+
+		"while sudo iptables -D OUTPUT -j %[1]v 2>/dev/null; do :; done; "+
+		"sudo iptables -F %[1]v 2>/dev/null || true", partitionChain)
@@ -0,0 +1,307 @@
+package gravity
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/gravitational/robotest/infra"
+	"github.com/gravitational/robotest/infra/providers/aws"
+	"github.com/gravitational/robotest/infra/providers/gce"
+	"github.com/gravitational/robotest/infra/vagrant"
+	"github.com/gravitational/robotest/lib/constants"
+	"github.com/gravitational/robotest/lib/defaults"
+	sshutils "github.com/gravitational/robotest/lib/ssh"
+	"github.com/gravitational/robotest/lib/utils"
+	"github.com/gravitational/robotest/lib/wait"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// PowerController controls the power state of the VMs out of band, with the cloud provider API.
+// Unlike Gravity.PowerOff and Gravity.Reboot, this does not require the node to be reachable
+// and can power the node back on
+type PowerController interface {
+	// PowerOn starts the powered off VM
+	PowerOn(ctx context.Context, node infra.Node) error
+	// PowerOff stops the VM
+	PowerOff(ctx context.Context, node infra.Node, graceful Graceful) error
+	// HardReset resets the VM without shutting down the operating system
+	HardReset(ctx context.Context, node infra.Node) error
+	// PublicAddr returns the current public address of the VM
+	PublicAddr(ctx context.Context, node infra.Node) (string, error)
+}
+
+// NewPowerController returns the power controller for the cloud provider of config.
+// Only supported on AWS and GCE, see also libvirtPower
+func NewPowerController(config ProvisionerConfig) (PowerController, error) {
+	switch {
+	case config.CloudProvider == constants.AWS && config.AWS != nil:
+		return awsPower{config: config.awsCluster()}, nil
+	case config.CloudProvider == constants.GCE && config.GCE != nil:
+		return gcePower{config: config.gceCluster()}, nil
+	}
+	return nil, trace.NotImplemented("power control is not supported on %v", config.CloudProvider)
+}
+
+// awsCluster returns the AWS configuration with the name of the provisioned cluster
+// the instances are tagged with, see makeDynamicParams
+func (config ProvisionerConfig) awsCluster() aws.Config {
+	cluster := *config.AWS
+	cluster.ClusterName = config.tag
+	return cluster
+}
+
+// gceCluster returns the GCE configuration with the node tag of the provisioned cluster
+// the instances are labeled with, see makeDynamicParams
+func (config ProvisionerConfig) gceCluster() gce.Config {
+	cluster := *config.GCE
+	cluster.NodeTag = gce.TranslateClusterName(config.tag)
+	return cluster
+}
+
+// PowerOn powers on the node with the cloud provider API and reconnects to it.
+// If the public address of the node has changed, the node is updated with
+// the new address before reconnecting.
+// The node is expected to have been powered off
+func (c *TestContext) PowerOn(node Gravity) error {
+	return trace.Wrap(c.withPower(node, func(ctx context.Context, power PowerController, g *gravity) error {
+		c.Logger().WithField("node", node).Info("Power on.")
+		if err := power.PowerOn(ctx, g.Node()); err != nil {
+			return trace.Wrap(err)
+		}
+		if err := c.updateAddr(ctx, power, g); err != nil {
+			return trace.Wrap(err)
+		}
+		return trace.Wrap(g.reconnect(ctx))
+	}))
+}
+
+// PowerOff powers off the node with the cloud provider API.
+// The node is offline afterwards, see PowerOn
+func (c *TestContext) PowerOff(node Gravity, graceful Graceful) error {
+	return trace.Wrap(c.withPower(node, func(ctx context.Context, power PowerController, g *gravity) error {
+		c.Logger().WithField("node", node).Info("Power off.")
+		if err := power.PowerOff(ctx, g.Node(), graceful); err != nil {
+			return trace.Wrap(err)
+		}
+		g.disconnect()
+		return nil
+	}))
+}
+
+// HardReset resets the node with the cloud provider API and reconnects to it
+// once it has booted again. If the public address of the node has changed,
+// the node is updated with the new address before reconnecting
+func (c *TestContext) HardReset(node Gravity) error {
+	return trace.Wrap(c.withPower(node, func(ctx context.Context, power PowerController, g *gravity) error {
+		c.Logger().WithField("node", node).Info("Hard reset.")
+		bootID, err := g.bootID(ctx)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		g.disconnect()
+		if err := power.HardReset(ctx, g.Node()); err != nil {
+			return trace.Wrap(err)
+		}
+		if err := c.updateAddr(ctx, power, g); err != nil {
+			return trace.Wrap(err)
+		}
+		return trace.Wrap(g.reconnectRebooted(ctx, bootID))
+	}))
+}
+
+// RecoverNode powers the powered off cluster node back on and waits until
+// it has rejoined the cluster and the status is reported on all nodes
+func (c *TestContext) RecoverNode(nodes []Gravity, node Gravity) error {
+	if err := c.PowerOn(node); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(c.Status(nodes))
+}
+
+// StopStartAll stops all nodes with the cloud provider API and starts them again,
+// the way VMs are cycled in a maintenance window, and verifies that the cluster comes back.
+// Changed public addresses of the nodes are picked up, see PowerOn
+func (c *TestContext) StopStartAll(nodes []Gravity) error {
+	ctx, cancel := context.WithTimeout(c.ctx, withDuration(c.timeouts.Power, 2))
+	defer cancel()
+
+	errs := make(chan error, len(nodes))
+	for _, node := range nodes {
+		go func(node Gravity) {
+			errs <- trace.Wrap(c.PowerOff(node, Graceful(true)), "failed to stop %v", node)
+		}(node)
+	}
+	if err := utils.CollectErrors(ctx, errs); err != nil {
+		return trace.Wrap(err)
+	}
+	for _, node := range nodes {
+		go func(node Gravity) {
+			errs <- trace.Wrap(c.PowerOn(node), "failed to start %v", node)
+		}(node)
+	}
+	if err := utils.CollectErrors(ctx, errs); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(c.Status(nodes))
+}
+
+// updateAddr updates the node with its current public address
+func (c *TestContext) updateAddr(ctx context.Context, power PowerController, g *gravity) error {
+	addr, err := power.PublicAddr(ctx, g.Node())
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if addr == g.Node().Addr() {
+		return nil
+	}
+	node, ok := g.Node().(infra.ReaddressableNode)
+	if !ok {
+		return trace.BadParameter("public address of %v changed to %v, but the node cannot be updated", g, addr)
+	}
+	c.Logger().WithFields(logrus.Fields{"node": g, "addr": addr}).Info("Public address changed.")
+	return trace.Wrap(node.SetAddr(addr))
+}
+
+func (c *TestContext) withPower(node Gravity, fn func(ctx context.Context, power PowerController, g *gravity) error) error {
+	g, ok := node.(*gravity)
+	if !ok {
+		return trace.BadParameter("unsupported node %v", node)
+	}
+	power, err := powerController(c.provisionerConfig(), g.Node())
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Power)
+	defer cancel()
+	return trace.Wrap(fn(ctx, power, g))
+}
+
+// powerController returns the power controller for the node
+func powerController(config ProvisionerConfig, node infra.Node) (PowerController, error) {
+	if vagrant.IsNode(node) {
+		return libvirtPower{}, nil
+	}
+	return NewPowerController(config)
+}
+
+// reconnectRebooted reconnects to the node once it has booted again,
+// i.e. its boot ID has changed from bootID
+func (g *gravity) reconnectRebooted(ctx context.Context, bootID string) error {
+	retry := wait.Retryer{
+		Delay:       time.Second,
+		MaxDelay:    retrySSH,
+		Jitter:      defaults.RetryJitter,
+		Budget:      deadlineSSH,
+		FieldLogger: g.Logger(),
+	}
+	return trace.Wrap(retry.Do(ctx, func() error {
+		if err := g.reconnect(ctx); err != nil {
+			return wait.Abort(err)
+		}
+		id, err := g.bootID(ctx)
+		if err != nil {
+			g.disconnect()
+			return trace.Wrap(err)
+		}
+		if id == bootID {
+			// the node has not gone down yet
+			g.disconnect()
+			return wait.Continue("node %v has not rebooted", g)
+		}
+		return nil
+	}))
+}
+
+// bootID returns the ID of the current boot of the node
+func (g *gravity) bootID(ctx context.Context) (string, error) {
+	var out string
+	err := g.runAndParse(ctx, g.Logger(), "cat /proc/sys/kernel/random/boot_id", nil, sshutils.ParseAsString(&out))
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// reconnect waits for the node to become reachable over SSH and reconnects to it
+func (g *gravity) reconnect(ctx context.Context) error {
+	client, err := sshClient(ctx, g.Node(), g.Logger())
+	if err != nil {
+		return trace.Wrap(err, "SSH reconnect")
+	}
+	if prev := g.setClient(client); prev != nil {
+		prev.Close()
+	}
+	return nil
+}
+
+// disconnect closes the SSH connection and marks the node offline
+func (g *gravity) disconnect() {
+	if prev := g.setClient(nil); prev != nil {
+		prev.Close()
+	}
+}
+
+type awsPower struct {
+	config aws.Config
+}
+
+func (r awsPower) PowerOn(ctx context.Context, node infra.Node) error {
+	return trace.Wrap(aws.PowerOn(ctx, r.config, node.PrivateAddr()))
+}
+
+func (r awsPower) PowerOff(ctx context.Context, node infra.Node, graceful Graceful) error {
+	return trace.Wrap(aws.PowerOff(ctx, r.config, node.PrivateAddr(), bool(graceful)))
+}
+
+func (r awsPower) HardReset(ctx context.Context, node infra.Node) error {
+	return trace.Wrap(aws.HardReset(ctx, r.config, node.PrivateAddr()))
+}
+
+func (r awsPower) PublicAddr(ctx context.Context, node infra.Node) (string, error) {
+	addr, err := aws.PublicIP(ctx, r.config, node.PrivateAddr())
+	return addr, trace.Wrap(err)
+}
+
+type gcePower struct {
+	config gce.Config
+}
+
+func (r gcePower) PowerOn(ctx context.Context, node infra.Node) error {
+	return trace.Wrap(gce.PowerOn(ctx, r.config, node.PrivateAddr()))
+}
+
+func (r gcePower) PowerOff(ctx context.Context, node infra.Node, graceful Graceful) error {
+	return trace.Wrap(gce.PowerOff(ctx, r.config, node.PrivateAddr()))
+}
+
+func (r gcePower) HardReset(ctx context.Context, node infra.Node) error {
+	return trace.Wrap(gce.HardReset(ctx, r.config, node.PrivateAddr()))
+}
+
+func (r gcePower) PublicAddr(ctx context.Context, node infra.Node) (string, error) {
+	addr, err := gce.PublicIP(ctx, r.config, node.PrivateAddr())
+	return addr, trace.Wrap(err)
+}
+
+// libvirtPower controls the power state of the vagrant nodes with virsh
+type libvirtPower struct{}
+
+func (libvirtPower) PowerOn(ctx context.Context, node infra.Node) error {
+	return trace.Wrap(vagrant.PowerOn(ctx, node))
+}
+
+func (libvirtPower) PowerOff(ctx context.Context, node infra.Node, graceful Graceful) error {
+	return trace.Wrap(vagrant.PowerOff(ctx, node, bool(graceful)))
+}
+
+func (libvirtPower) HardReset(ctx context.Context, node infra.Node) error {
+	return trace.Wrap(vagrant.HardReset(ctx, node))
+}
+
+func (libvirtPower) PublicAddr(ctx context.Context, node infra.Node) (string, error) {
+	addr, err := vagrant.PublicIP(ctx, node)
+	return addr, trace.Wrap(err)
+}
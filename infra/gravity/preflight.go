@@ -0,0 +1,216 @@
+package gravity
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gravitational/robotest/lib/shell"
+	"github.com/gravitational/robotest/lib/utils"
+
+	"github.com/gravitational/trace"
+)
+
+// Misconfiguration names a deliberate node misconfiguration the preflight checks
+// are expected to detect
+type Misconfiguration string
+
+const (
+	// MisconfigMissingModule unloads br_netfilter and prevents it from being loaded
+	MisconfigMissingModule Misconfiguration = "missing_module"
+	// MisconfigLowDisk fills the state directory file system up to the last 512MiB
+	MisconfigLowDisk Misconfiguration = "low_disk"
+	// MisconfigIPForward disables IPv4 forwarding
+	MisconfigIPForward Misconfiguration = "ip_forward"
+)
+
+// PreflightResult describes the outcome of gravity check on a node
+type PreflightResult struct {
+	// Node is the node the checks ran on
+	Node Gravity
+	// Passed is true if gravity check exited successfully
+	Passed bool
+	// Output is the combined output of gravity check
+	Output string
+}
+
+// Misconfigure applies the given misconfigurations to all nodes.
+// stateDir specifies the state directory the low disk misconfiguration fills up
+func (c *TestContext) Misconfigure(nodes []Gravity, stateDir string, misconfigs []Misconfiguration) error {
+	var commands []string
+	for _, misconfig := range misconfigs {
+		cmds, err := misconfigCommands(misconfig, stateDir)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		commands = append(commands, cmds...)
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	errs := make(chan error, len(nodes))
+	for _, node := range nodes {
+		go func(node Gravity) {
+			g, ok := node.(*gravity)
+			if !ok {
+				errs <- trace.BadParameter("unsupported node %v", node)
+				return
+			}
+			g.Logger().WithField("misconfigurations", misconfigs).Info("Misconfigure node.")
+			for _, cmd := range commands {
+				if err := g.run(ctx, g.Logger(), cmd, nil); err != nil {
+					errs <- trace.Wrap(err)
+					return
+				}
+			}
+			errs <- nil
+		}(node)
+	}
+	return trace.Wrap(utils.CollectErrors(ctx, errs))
+}
+
+// Preflight runs gravity check from the install directory on all nodes
+// with the node profiles of param and returns the results
+func (c *TestContext) Preflight(nodes []Gravity, param InstallParam) ([]PreflightResult, error) {
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Install)
+	defer cancel()
+
+	results := make([]PreflightResult, len(nodes))
+	errs := make(chan error, len(nodes))
+	for i, node := range nodes {
+		go func(i int, node Gravity) {
+			g, ok := node.(*gravity)
+			if !ok {
+				errs <- trace.BadParameter("unsupported node %v", node)
+				return
+			}
+			result, err := g.preflight(ctx, param.RoleOf(i))
+			results[i] = result
+			errs <- trace.Wrap(err)
+		}(i, node)
+	}
+	if err := utils.CollectErrors(ctx, errs); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return results, nil
+}
+
+// AssertPreflight runs gravity check on all nodes and verifies the outcome.
+// Without expected failures, the checks are required to pass on all nodes.
+// Otherwise, the checks are required to fail on all nodes and to report
+// each of the expected messages
+func (c *TestContext) AssertPreflight(nodes []Gravity, param InstallParam, expectFailures []string) error {
+	results, err := c.Preflight(nodes, param)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	var errors []error
+	for _, result := range results {
+		if err := checkPreflightResult(result, expectFailures); err != nil {
+			c.Logger().WithError(err).WithField("output", result.Output).Warn("Unexpected preflight result.")
+			errors = append(errors, err)
+		}
+	}
+	return trace.NewAggregate(errors...)
+}
+
+// preflight runs gravity check with the given node profile
+func (g *gravity) preflight(ctx context.Context, profile string) (PreflightResult, error) {
+	cmd, err := g.renderCommand(ctx, checkCommand, struct {
+		commandParams
+		Profile string
+	}{
+		commandParams: g.commandParams(),
+		Profile:       profile,
+	})
+	if err != nil {
+		return PreflightResult{}, trace.Wrap(err)
+	}
+	result := PreflightResult{Node: g}
+	// failed checks are an expected outcome, so capture the exit code
+	// instead of failing the command
+	cmd = fmt.Sprintf("%v 2>&1; echo %v$?", strings.TrimSpace(cmd), preflightExitMarker)
+	err = g.runAndParse(ctx, g.Logger(), cmd, nil, parsePreflight(&result))
+	if err != nil {
+		return PreflightResult{}, trace.Wrap(err)
+	}
+	return result, nil
+}
+
+// parsePreflight parses the output of gravity check followed by the exit code line
+func parsePreflight(result *PreflightResult) func(r *bufio.Reader) error {
+	return func(r *bufio.Reader) error {
+		out, err := ioutil.ReadAll(r)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		matches := rePreflightExit.FindAllSubmatchIndex(out, -1)
+		if len(matches) == 0 {
+			return trace.BadParameter("missing exit code in gravity check output")
+		}
+		match := matches[len(matches)-1]
+		code, err := strconv.Atoi(string(out[match[2]:match[3]]))
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		result.Passed = code == 0
+		result.Output = strings.TrimSpace(string(out[:match[0]]))
+		return nil
+	}
+}
+
+// checkPreflightResult verifies the result against the expected failure messages
+func checkPreflightResult(result PreflightResult, expectFailures []string) error {
+	if len(expectFailures) == 0 {
+		if !result.Passed {
+			return trace.CompareFailed("preflight checks failed on %v", result.Node)
+		}
+		return nil
+	}
+	if result.Passed {
+		return trace.CompareFailed("preflight checks passed on %v, expected them to fail with %q",
+			result.Node, expectFailures)
+	}
+	var missing []string
+	for _, message := range expectFailures {
+		if !strings.Contains(result.Output, message) {
+			missing = append(missing, fmt.Sprintf("%q", message))
+		}
+	}
+	if len(missing) != 0 {
+		return trace.CompareFailed("preflight checks on %v did not report %v",
+			result.Node, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func misconfigCommands(misconfig Misconfiguration, stateDir string) ([]string, error) {
+	switch misconfig {
+	case MisconfigMissingModule:
+		return []string{
+			"echo 'install br_netfilter /bin/false' | sudo tee /etc/modprobe.d/robotest-preflight.conf",
+			"sudo modprobe -r br_netfilter || true",
+		}, nil
+	case MisconfigLowDisk:
+		return []string{
+			shell.Sudo("mkdir", "-p", stateDir).String(),
+			fmt.Sprintf("sudo fallocate -l $(( $(df --output=avail -B1 %v | tail -1) - 512*1024*1024 )) %v",
+				shell.Quote(stateDir), shell.Quote(stateDir+"/robotest-preflight.fill")),
+		}, nil
+	case MisconfigIPForward:
+		return []string{"sudo sysctl -w net.ipv4.ip_forward=0"}, nil
+	default:
+		return nil, trace.BadParameter("unknown misconfiguration %q, expected one of %v, %v, %v",
+			misconfig, MisconfigMissingModule, MisconfigLowDisk, MisconfigIPForward)
+	}
+}
+
+// preflightExitMarker prefixes the exit code of gravity check in the command output
+const preflightExitMarker = "robotest-preflight-exit:"
+
+var rePreflightExit = regexp.MustCompile(preflightExitMarker + `(\d+)`)
@@ -0,0 +1,36 @@
+package gravity
+
+import (
+	"context"
+	"testing"
+
+	sshutils "github.com/gravitational/robotest/lib/ssh"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreflight(t *testing.T) {
+	replayer := sshutils.NewReplayer(sshutils.Interaction{
+		Command: "cd /home/robotest/installer && sudo ./gravity check --debug --profile=node app.yaml 2>&1; echo robotest-preflight-exit:$?",
+		Stdout: `[ERROR]: kernel module "br_netfilter" not loaded
+robotest-preflight-exit:255`,
+	})
+	g := &gravity{
+		transport:  replayer,
+		installDir: "/home/robotest/installer",
+		log:        logrus.NewEntry(logrus.StandardLogger()),
+		version:    &Version{Version: "6.1.9"},
+		versionDir: "/home/robotest/installer",
+	}
+	result, err := g.preflight(context.Background(), "node")
+	require.NoError(t, err)
+	assert.False(t, result.Passed)
+	assert.Equal(t, `[ERROR]: kernel module "br_netfilter" not loaded`, result.Output)
+
+	assert.Error(t, checkPreflightResult(result, nil))
+	assert.NoError(t, checkPreflightResult(result, []string{"br_netfilter"}))
+	assert.Error(t, checkPreflightResult(result, []string{"br_netfilter", "disk space"}))
+	assert.Error(t, checkPreflightResult(PreflightResult{Passed: true}, []string{"br_netfilter"}))
+}
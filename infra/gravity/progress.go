@@ -0,0 +1,103 @@
+package gravity
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Progress tracks the status and the last completed step of every test in the suite.
+// It can be rendered as a table or served as JSON over HTTP for live monitoring
+type Progress struct {
+	mu    sync.Mutex
+	tests map[string]*TestProgress
+}
+
+// TestProgress describes the progress of a single test
+type TestProgress struct {
+	// Name names the test (cluster)
+	Name string `json:"name"`
+	// Status is the test status
+	Status string `json:"status"`
+	// Step names the last step completed by the test
+	Step string `json:"step,omitempty"`
+	// Error describes the failure of the last step, if any
+	Error string `json:"error,omitempty"`
+	// Started specifies the time the test was started
+	Started time.Time `json:"started"`
+	// Updated specifies the time of the last update
+	Updated time.Time `json:"updated"`
+}
+
+func newProgress() *Progress {
+	return &Progress{tests: make(map[string]*TestProgress)}
+}
+
+// Snapshot returns the progress of all tests sorted by name
+func (r *Progress) Snapshot() []TestProgress {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tests := make([]TestProgress, 0, len(r.tests))
+	for _, test := range r.tests {
+		tests = append(tests, *test)
+	}
+	sort.Slice(tests, func(i, j int) bool {
+		return tests[i].Name < tests[j].Name
+	})
+	return tests
+}
+
+// Render writes the progress of all tests as a table to w
+func (r *Progress) Render(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "TEST\tSTATUS\tLAST STEP\tELAPSED\tUPDATED")
+	now := time.Now()
+	for _, test := range r.Snapshot() {
+		step := test.Step
+		if test.Error != "" {
+			step = fmt.Sprintf("%v: %v", step, test.Error)
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v ago\n", test.Name, test.Status, step,
+			now.Sub(test.Started).Round(time.Second),
+			now.Sub(test.Updated).Round(time.Second))
+	}
+	return tw.Flush()
+}
+
+// ServeHTTP serves the progress of all tests as JSON
+func (r *Progress) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, r.Snapshot())
+}
+
+func (r *Progress) updateStatus(name, status string) {
+	r.update(name, func(test *TestProgress) {
+		test.Status = status
+	})
+}
+
+func (r *Progress) updateStep(name, step string, err error) {
+	r.update(name, func(test *TestProgress) {
+		test.Step = step
+		test.Error = errorMessage(err)
+	})
+}
+
+func (r *Progress) update(name string, fn func(*TestProgress)) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	test, ok := r.tests[name]
+	if !ok {
+		test = &TestProgress{Name: name, Started: now}
+		r.tests[name] = test
+	}
+	fn(test)
+	test.Updated = now
+}
@@ -0,0 +1,25 @@
+package gravity
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgressSnapshot(t *testing.T) {
+	progress := newProgress()
+	progress.updateStatus("install-1-T1", TestStatusRunning)
+	progress.updateStep("install-1-T1", "provision nodes", nil)
+	progress.updateStatus("resize-1-T1", TestStatusRunning)
+	progress.updateStep("resize-1-T1", "expand", fmt.Errorf("join failed"))
+
+	var tests []TestProgress
+	for _, test := range progress.Snapshot() {
+		tests = append(tests, TestProgress{Name: test.Name, Status: test.Status, Step: test.Step, Error: test.Error})
+	}
+	assert.Equal(t, []TestProgress{
+		{Name: "install-1-T1", Status: TestStatusRunning, Step: "provision nodes"},
+		{Name: "resize-1-T1", Status: TestStatusRunning, Step: "expand", Error: "join failed"},
+	}, tests)
+}
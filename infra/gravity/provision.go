@@ -16,6 +16,8 @@ import (
 	"github.com/gravitational/robotest/infra"
 	"github.com/gravitational/robotest/infra/terraform"
 	"github.com/gravitational/robotest/lib/constants"
+	"github.com/gravitational/robotest/lib/failure"
+	"github.com/gravitational/robotest/lib/shell"
 	sshutil "github.com/gravitational/robotest/lib/ssh"
 	"github.com/gravitational/robotest/lib/utils"
 	"github.com/gravitational/robotest/lib/wait"
@@ -63,7 +65,10 @@ func configureVMs(baseCtx context.Context, log logrus.FieldLogger, params cloudD
 // Provision will attempt to provision the requested cluster
 func (c *TestContext) Provision(cfg ProvisionerConfig) (cluster Cluster, err error) {
 	// store the configuration used for provisioning
+	c.nodesMu.Lock()
 	c.provisionerCfg = cfg
+	c.nodesMu.Unlock()
+	defer c.enterPhase("provision")()
 
 	switch cfg.CloudProvider {
 	case constants.Azure, constants.AWS, constants.GCE:
@@ -88,6 +93,16 @@ func (c *TestContext) Provision(cfg ProvisionerConfig) (cluster Cluster, err err
 		cluster.Nodes = nil
 		cluster.Destroy = nil
 	}
+	if err != nil && !trace.IsBadParameter(err) {
+		// configuration errors are not infrastructure failures
+		err = failure.Provisioning(err)
+	}
+	if err == nil {
+		c.nodesMu.Lock()
+		c.nodes = cluster.Nodes
+		c.addNode = cluster.addNode
+		c.nodesMu.Unlock()
+	}
 
 	return cluster, trace.Wrap(err)
 }
@@ -113,7 +128,9 @@ func (c *TestContext) provisionOps(cfg ProvisionerConfig) (cluster Cluster, err
 
 	// generate a random cluster name
 	clusterName := fmt.Sprint(c.name, "-", uuid.NewV4().String())
+	c.nodesMu.Lock()
 	c.provisionerCfg.clusterName = clusterName
+	c.nodesMu.Unlock()
 	c.Logger().Debug("Generated cluster name: ", clusterName)
 
 	c.Logger().Debug("validating configuration")
@@ -192,7 +209,7 @@ Loop:
 		return cluster, trace.Wrap(err)
 	}
 
-	err = validateDiskSpeed(c.Context(), gravityNodes, cfg.dockerDevice, c.Logger())
+	err = validateDiskSpeed(c.Context(), gravityNodes, c.Logger())
 	if err != nil {
 		return cluster, trace.Wrap(err)
 	}
@@ -212,15 +229,22 @@ func (c *TestContext) provisionCloud(cfg ProvisionerConfig) (cluster Cluster, co
 		return cluster, nil, trace.Wrap(err)
 	}
 
-	infra, err := runTerraform(c.Context(), cfg, c.Logger())
+	tfConfig := cfg
+	if cfg.Proxy {
+		// provision an additional node to act as the proxy
+		tfConfig.NodeCount++
+	}
+	infra, err := runTerraform(c.Context(), tfConfig, c.timeouts.Teardown, c.Logger())
 	if err != nil {
 		return cluster, nil, trace.Wrap(err)
 	}
+	alloc := c.recordAllocation(infra.params.ProvisionerConfig)
+	infra.destroyFn = alloc.destroyWith(infra.destroyFn)
 	defer func() {
 		if err == nil || infra.destroyFn == nil {
 			return
 		}
-		if errDestroy := destroyResource(infra.destroyFn); errDestroy != nil {
+		if errDestroy := destroyResource(infra.destroyFn, c.timeouts.Teardown); errDestroy != nil {
 			log.WithError(errDestroy).Error("Failed to destroy resources.")
 		}
 	}()
@@ -232,16 +256,31 @@ func (c *TestContext) provisionCloud(cfg ProvisionerConfig) (cluster Cluster, co
 	gravityNodes, err := connectVMs(ctx, c.Logger(), infra.params, infra.nodes)
 	if err != nil {
 		log.WithError(err).Error("Some nodes failed to connect, tear down as unusable.")
-		return cluster, nil, trace.NewAggregate(err, destroyResource(infra.destroyFn))
+		return cluster, nil, trace.NewAggregate(err, destroyResource(infra.destroyFn, c.timeouts.Teardown))
 	}
 	// Start streaming logs as soon as connected
 	c.streamLogs(gravityNodes)
 
+	var proxy *gravity
+	if cfg.Proxy {
+		proxy, gravityNodes = gravityNodes[len(gravityNodes)-1], gravityNodes[:len(gravityNodes)-1]
+	}
+
 	log.Debug("Configuring VMs.")
 	err = configureVMs(ctx, c.Logger(), infra.params, gravityNodes)
 	if err != nil {
 		log.WithError(err).Error("Some nodes failed to initialize, tear down as non-usable.")
-		return cluster, nil, trace.NewAggregate(err, destroyResource(infra.destroyFn))
+		return cluster, nil, trace.NewAggregate(err, destroyResource(infra.destroyFn, c.timeouts.Teardown))
+	}
+
+	if proxy != nil {
+		log.WithField("proxy", proxy).Debug("Configuring proxy.")
+		err = configureProxyVM(ctx, c.Logger(), proxy, infra.params, gravityNodes)
+		if err != nil {
+			log.WithError(err).Error("Failed to configure proxy, tear down as non-usable.")
+			return cluster, nil, trace.NewAggregate(err, destroyResource(infra.destroyFn, c.timeouts.Teardown))
+		}
+		cluster.Proxy = proxy
 	}
 
 	err = c.postProvision(gravityNodes)
@@ -251,7 +290,7 @@ func (c *TestContext) provisionCloud(cfg ProvisionerConfig) (cluster Cluster, co
 	}
 
 	if cfg.CloudProvider == constants.Azure {
-		err = validateDiskSpeed(c.Context(), gravityNodes, cfg.dockerDevice, c.Logger())
+		err = validateDiskSpeed(c.Context(), gravityNodes, c.Logger())
 		if err != nil {
 			return cluster, nil, trace.Wrap(err)
 		}
@@ -259,16 +298,66 @@ func (c *TestContext) provisionCloud(cfg ProvisionerConfig) (cluster Cluster, co
 
 	log.WithField("nodes", gravityNodes).Debug("Provisioning complete.")
 
-	nodes := asNodes(gravityNodes)
-	cluster.Nodes = nodes
-	cluster.Destroy = wrapDestroyFunc(c, cfg.Tag(), nodes, infra.destroyFn)
+	cluster.Nodes = asNodes(gravityNodes)
+	cluster.Destroy = wrapDestroyFunc(c, cfg.Tag(), cluster.Nodes, infra.destroyFn)
+	cluster.addNode = func(ctx context.Context) (*gravity, error) {
+		return c.addCloudNode(ctx, infra, proxy, gravityNodes)
+	}
 
 	return cluster, &infra.params.terraform, nil
 }
 
+// addCloudNode provisions an additional VM into the infrastructure and prepares it
+// the same way as the cluster nodes, including the proxy configuration if the cluster
+// nodes use the proxy
+func (c *TestContext) addCloudNode(ctx context.Context, infra *terraformResp, proxy *gravity, clusterNodes []*gravity) (*gravity, error) {
+	log := c.Logger()
+	log.Debug("Provisioning additional VM.")
+	nodes, err := infra.addNodes(ctx, 1)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	gravityNodes, err := connectVMs(ctx, c.Logger(), infra.params, nodes)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	c.streamLogs(gravityNodes)
+
+	err = configureVMs(ctx, c.Logger(), infra.params, gravityNodes)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if proxy != nil {
+		nodes := append(append([]*gravity(nil), clusterNodes...), gravityNodes...)
+		env := proxyEnv(proxy.Node().PrivateAddr(), nodes)
+		err = configureProxyEnv(ctx, gravityNodes[0], env)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+
+	err = c.postProvision(gravityNodes)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	if infra.params.CloudProvider == constants.Azure {
+		err = validateDiskSpeed(ctx, gravityNodes, c.Logger())
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	log.WithField("node", gravityNodes[0]).Debug("Provisioning complete.")
+	return gravityNodes[0], nil
+}
+
 func (c *TestContext) streamLogs(gravityNodes []*gravity) {
 	c.Logger().Debug("Streaming logs.")
 	for _, node := range gravityNodes {
+		if c.provisionerConfig().StreamLogs {
+			node.streamLogsToFiles(c.monitorCtx)
+		}
 		go func(node *gravity) {
 			err := node.streamStartupLogs(c.monitorCtx)
 			if err != nil && !utils.IsContextCancelledError(err) {
@@ -307,6 +396,11 @@ func (c *TestContext) postProvision(gravityNodes []*gravity) error {
 	if err := sshutil.WaitTimeSync(ctx, timeNodes); err != nil {
 		return trace.Wrap(err)
 	}
+
+	// the inventory is informational, so do not fail provisioning without it
+	if err := c.CollectInventory(asNodes(gravityNodes)); err != nil {
+		c.Logger().WithError(err).Warn("Failed to collect inventory.")
+	}
 	return nil
 }
 
@@ -393,12 +487,18 @@ func connectVMs(ctx context.Context, log logrus.FieldLogger, params cloudDynamic
 }
 
 func connectVM(ctx context.Context, log logrus.FieldLogger, node infra.Node, param cloudDynamicParams) (*gravity, error) {
+	if bd, ok := node.(infra.BlockDeviceNode); ok {
+		// i.e. the docker device is an NVMe device named after the EBS volume on AWS Nitro instances
+		param.dockerDevice = bd.Device(param.dockerDevice)
+	}
 	g := &gravity{
 		node:  node,
 		param: param,
 		ts:    time.Now(),
+		logs:  utils.NewLineBuffer(recentLogLines),
 		log: log.WithFields(logrus.Fields{
 			"ip":        node.PrivateAddr(),
+			"node":      node.PrivateAddr(),
 			"public_ip": node.Addr(),
 		}),
 	}
@@ -408,7 +508,7 @@ func connectVM(ctx context.Context, log logrus.FieldLogger, node infra.Node, par
 		return nil, trace.Wrap(err)
 	}
 
-	g.ssh = client
+	g.setClient(client)
 	return g, nil
 }
 
@@ -434,14 +534,57 @@ func configureVM(ctx context.Context, log logrus.FieldLogger, node *gravity, par
 		return trace.Wrap(err)
 	}
 
+	if param.SELinux {
+		err = enforceSELinux(ctx, node)
+		if err != nil {
+			return trace.Wrap(err, "failed to enable SELinux")
+		}
+	}
+
+	if param.FIPS {
+		err = enableFIPS(ctx, node)
+		if err != nil {
+			return trace.Wrap(err, "failed to enable FIPS mode")
+		}
+	}
+
+	if param.Firewall {
+		err = enableFirewall(ctx, node)
+		if err != nil {
+			return trace.Wrap(err, "failed to enable firewall")
+		}
+	}
+
+	if param.NodePrep != nil {
+		err = prepareNode(ctx, node, *param.NodePrep)
+		if err != nil {
+			return trace.Wrap(err, "failed to prepare node")
+		}
+	}
+
 	return nil
 }
 
-func validateDiskSpeed(ctx context.Context, nodes []*gravity, device string, logger logrus.FieldLogger) error {
+// configureProxyVM bootstraps the proxy node and configures the cluster nodes to use it.
+// Node customizations (SELinux, FIPS, firewall, node preparation) only apply to cluster nodes
+func configureProxyVM(ctx context.Context, log logrus.FieldLogger, proxy *gravity, param cloudDynamicParams, nodes []*gravity) error {
+	param.SELinux = false
+	param.FIPS = false
+	param.Firewall = false
+	param.NodePrep = nil
+	err := configureVM(ctx, log, proxy, param)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(setupProxy(ctx, proxy, nodes))
+}
+
+// validateDiskSpeed verifies the speed of the root and docker devices of the nodes
+func validateDiskSpeed(ctx context.Context, nodes []*gravity, logger logrus.FieldLogger) error {
 	logger.Debug("Ensuring disk speed is adequate across nodes.")
 	ctx, cancel := context.WithTimeout(ctx, diskWaitTimeout)
 	defer cancel()
-	err := waitDisks(ctx, nodes, []string{"/iotest", device}, logger)
+	err := waitDisks(ctx, nodes, logger)
 	if err != nil {
 		err = trace.Wrap(err, "VM disks did not meet performance requirements, tear down as non-usable")
 		logger.WithError(err).Error("VM disks did not meet performance requirements, tear down as non-usable.")
@@ -452,12 +595,12 @@ func validateDiskSpeed(ctx context.Context, nodes []*gravity, device string, log
 
 // waitDisks is a necessary workaround for Azure VMs to wait until their disk initialization processes are complete
 // otherwise it'll fail telekube pre-install checks
-func waitDisks(ctx context.Context, nodes []*gravity, paths []string, logger logrus.FieldLogger) error {
+func waitDisks(ctx context.Context, nodes []*gravity, logger logrus.FieldLogger) error {
 	errs := make(chan error, len(nodes))
 
 	for _, node := range nodes {
 		go func(node *gravity) {
-			errs <- waitDisk(ctx, node, paths, minDiskSpeed, logger)
+			errs <- waitDisk(ctx, node, []string{"/iotest", node.param.dockerDevice}, minDiskSpeed, logger)
 		}(node)
 	}
 
@@ -471,7 +614,7 @@ func waitDisk(ctx context.Context, node *gravity, paths []string, minSpeed uint6
 			if !strings.HasPrefix(path, "/dev") {
 				defer func() {
 					errRemove := sshutil.Run(ctx, node.Client(), node.Logger(),
-						fmt.Sprintf("sudo /bin/rm -f %s", path), nil)
+						shell.Sudo("/bin/rm", "-f", path).String(), nil)
 					if errRemove != nil {
 						logger.Warnf("Failed to remove path: %v.", errRemove)
 					}
@@ -479,7 +622,7 @@ func waitDisk(ctx context.Context, node *gravity, paths []string, minSpeed uint6
 			}
 			var out string
 			err := sshutil.RunAndParse(ctx, node.Client(), node.Logger(),
-				fmt.Sprintf("sudo dd if=/dev/zero of=%s bs=100K count=1024 conv=fdatasync 2>&1", path),
+				fmt.Sprintf("%v 2>&1", shell.Sudo("dd", "if=/dev/zero", "of="+path, "bs=100K", "count=1024", "conv=fdatasync")),
 				nil, sshutil.ParseAsString(&out))
 			if err != nil {
 				return wait.Abort(trace.Wrap(err))
@@ -500,8 +643,8 @@ func waitDisk(ctx context.Context, node *gravity, paths []string, minSpeed uint6
 
 // destroyResource executes the specified destroy handler using
 // default context
-func destroyResource(handler func(context.Context) error) error {
-	ctx, cancel := context.WithTimeout(context.Background(), finalTeardownTimeout)
+func destroyResource(handler func(context.Context) error, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 	return trace.Wrap(handler(ctx))
 }
@@ -512,4 +655,10 @@ type Cluster struct {
 	Nodes []Gravity
 	// Destroy is the resource destruction handler
 	Destroy DestroyFn
+	// Proxy is the HTTP(S) proxy node the cluster nodes access
+	// the outside world through, if requested
+	Proxy Gravity
+	// addNode provisions an additional node into the cluster infrastructure,
+	// if supported by the provisioner
+	addNode func(ctx context.Context) (*gravity, error)
 }
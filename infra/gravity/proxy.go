@@ -0,0 +1,113 @@
+package gravity
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gravitational/robotest/lib/shell"
+	"github.com/gravitational/robotest/lib/utils"
+
+	"github.com/gravitational/trace"
+)
+
+// setupProxy turns the node into an HTTP(S) proxy (squid) and configures
+// the cluster nodes to access the outside world through it
+func setupProxy(ctx context.Context, proxy *gravity, nodes []*gravity) error {
+	err := installSquid(ctx, proxy)
+	if err != nil {
+		return trace.Wrap(err, "failed to install proxy on %v", proxy)
+	}
+
+	env := proxyEnv(proxy.Node().PrivateAddr(), nodes)
+	errs := make(chan error, len(nodes))
+	for _, node := range nodes {
+		go func(node *gravity) {
+			errs <- configureProxyEnv(ctx, node, env)
+		}(node)
+	}
+	return trace.Wrap(utils.CollectErrors(ctx, errs))
+}
+
+// installSquid installs and starts squid on the node.
+// The default squid configuration allows access from private networks
+func installSquid(ctx context.Context, node *gravity) error {
+	var install string
+	switch vendor := node.param.os.Vendor; {
+	case isRedHatFamily(vendor):
+		install = "sudo yum install -y squid"
+	case vendor == "ubuntu" || vendor == "debian":
+		install = "sudo apt-get update && sudo DEBIAN_FRONTEND=noninteractive apt-get install -y squid"
+	default:
+		return trace.BadParameter("proxy is not supported on %v", vendor)
+	}
+	log := node.Logger()
+	log.Info("Install proxy.")
+	for _, cmd := range []string{install, "sudo systemctl enable squid", "sudo systemctl restart squid"} {
+		err := node.run(ctx, log, cmd, nil)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// configureProxyEnv configures the system-wide proxy environment on the node
+// and uses it for the commands robotest runs on the node, i.e. to download the installer
+func configureProxyEnv(ctx context.Context, node *gravity, env map[string]string) error {
+	var lines []string
+	for _, name := range sortedKeys(env) {
+		lines = append(lines, fmt.Sprintf("%v=%v", name, env[name]))
+		lines = append(lines, fmt.Sprintf("%v=%v", strings.ToUpper(name), env[name]))
+	}
+	cmd := shell.New("printf", `%s\n`, strings.Join(lines, "\n")).String() + " | sudo tee -a /etc/environment"
+	err := node.run(ctx, node.Logger(), cmd, nil)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	nodeEnv := make(map[string]string, len(node.param.env)+len(env))
+	for name, value := range node.param.env {
+		nodeEnv[name] = value
+	}
+	for name, value := range env {
+		nodeEnv[name] = value
+	}
+	node.param.env = nodeEnv
+	return nil
+}
+
+// proxyEnv returns the proxy environment for the nodes.
+// Cluster-local traffic - the nodes themselves, the default gravity pod and
+// service subnets and the cloud metadata service - bypass the proxy
+func proxyEnv(proxyAddr string, nodes []*gravity) map[string]string {
+	proxyURL := fmt.Sprintf("http://%v:%v", proxyAddr, squidPort)
+	noProxy := []string{"localhost", "127.0.0.1", ".local", "169.254.169.254",
+		defaultPodSubnet, defaultServiceSubnet}
+	for _, node := range nodes {
+		noProxy = append(noProxy, node.Node().PrivateAddr())
+	}
+	return map[string]string{
+		"http_proxy":  proxyURL,
+		"https_proxy": proxyURL,
+		"no_proxy":    strings.Join(noProxy, ","),
+	}
+}
+
+func sortedKeys(m map[string]string) (keys []string) {
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+const (
+	// squidPort is the default squid listen port
+	squidPort = 3128
+	// defaultPodSubnet is the default gravity pod network
+	defaultPodSubnet = "10.244.0.0/16"
+	// defaultServiceSubnet is the default gravity service network
+	defaultServiceSubnet = "10.100.0.0/16"
+)
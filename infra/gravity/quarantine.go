@@ -0,0 +1,50 @@
+package gravity
+
+import (
+	"path"
+)
+
+// Quarantine describes the quarantined tests of a test suite.
+// Quarantined tests are run and reported as usual but do not fail the suite
+type Quarantine struct {
+	// Suite names the test suite, i.e. sanity. Matches all suites if empty
+	Suite string `json:"suite,omitempty" yaml:"suite"`
+	// Test is the shell pattern of the test names of the test set, i.e. upgrade*.
+	// Matches all tests of the suite if empty
+	Test string `json:"test,omitempty" yaml:"test"`
+	// Reason describes why the tests are quarantined
+	Reason string `json:"reason" yaml:"reason" validate:"required"`
+	// Ticket optionally names the ticket tracking the fix
+	Ticket string `json:"ticket,omitempty" yaml:"ticket"`
+}
+
+// matches returns true if the quarantine applies to the test of the suite
+func (r Quarantine) matches(suite, test string) bool {
+	if r.Suite != "" && r.Suite != suite {
+		return false
+	}
+	if r.Test == "" {
+		return true
+	}
+	matched, err := path.Match(r.Test, test)
+	return err == nil && matched
+}
+
+// WithQuarantine returns copy of config for the specified test of the test set,
+// quarantined if the test matches the quarantine list of the configuration
+func (config ProvisionerConfig) WithQuarantine(test string) ProvisionerConfig {
+	cfg := config
+	cfg.quarantine = nil
+	for i, quarantine := range config.Quarantine {
+		if quarantine.matches(config.suite, test) {
+			cfg.quarantine = &config.Quarantine[i]
+			break
+		}
+	}
+	return cfg
+}
+
+// Quarantined returns the quarantine of the test, if the test is quarantined
+func (config ProvisionerConfig) Quarantined() *Quarantine {
+	return config.quarantine
+}
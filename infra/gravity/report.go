@@ -0,0 +1,237 @@
+package gravity
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gravitational/robotest/lib/constants"
+
+	"github.com/gravitational/trace"
+)
+
+// RunReport is the JSON report of a test suite run
+type RunReport struct {
+	// Suite names the test suite
+	Suite string `json:"suite"`
+	// Tag is the tag of the run, the prefix of the test names
+	Tag string `json:"tag"`
+	// Started is the time the run started
+	Started time.Time `json:"started"`
+	// Completed is the time the run completed
+	Completed time.Time `json:"completed"`
+	// Results lists the status of each test attempt
+	Results []TestStatus `json:"results"`
+}
+
+// WriteReport writes the report as JSON into path
+func WriteReport(path string, report RunReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.ConvertSystemError(ioutil.WriteFile(path, data, constants.SharedReadMask))
+}
+
+// ReadReport reads the report written with WriteReport
+func ReadReport(path string) (*RunReport, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, trace.ConvertSystemError(err)
+	}
+	var report RunReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, trace.BadParameter("invalid report %v: %v", path, err)
+	}
+	return &report, nil
+}
+
+// ReportDiff describes the differences of a run from a baseline run
+type ReportDiff struct {
+	// Changed lists the tests with a different outcome
+	Changed []OutcomeChange
+	// Added lists the tests only present in the run
+	Added []string
+	// Removed lists the tests only present in the baseline run
+	Removed []string
+	// NewlyFlaky lists the tests that are flaky in the run but were not in the baseline run
+	NewlyFlaky []string
+	// Phases lists the differences of the time spent in each phase by the tests present in both runs
+	Phases []PhaseDelta
+}
+
+// OutcomeChange is a change of the outcome of a test, see Classify
+type OutcomeChange struct {
+	// Test names the test without the tag of the run
+	Test string
+	// Before is the class of the outcome in the baseline run
+	Before string
+	// After is the class of the outcome in the run
+	After string
+	// Quarantined is true if the test is quarantined in the run
+	Quarantined bool
+}
+
+// PhaseDelta is the difference of the time a test spent in a phase
+type PhaseDelta struct {
+	// Test names the test without the tag of the run
+	Test string
+	// Phase names the phase
+	Phase string
+	// Before is the time spent in the phase in the baseline run
+	Before time.Duration
+	// After is the time spent in the phase in the run
+	After time.Duration
+}
+
+// change formats the change of the duration with its sign
+func (r PhaseDelta) change() string {
+	delta := (r.After - r.Before).Round(time.Second)
+	if delta < 0 {
+		return delta.String()
+	}
+	return "+" + delta.String()
+}
+
+// CompareReports compares the run to the baseline run. The tests are matched by name
+// without the tag of the run, the phase durations are taken from the last attempt of each test
+func CompareReports(baseline, run RunReport) ReportDiff {
+	before := resultsByTest(baseline)
+	after := resultsByTest(run)
+	var diff ReportDiff
+	for _, test := range sortedTests(after) {
+		result := after[test]
+		prev, ok := before[test]
+		if !ok {
+			diff.Added = append(diff.Added, test)
+			continue
+		}
+		if prev.Class != result.Class {
+			diff.Changed = append(diff.Changed, OutcomeChange{Test: test, Before: prev.Class, After: result.Class,
+				Quarantined: result.Quarantine != nil})
+		}
+		if result.Class == ClassFlake && prev.Class != ClassFlake {
+			diff.NewlyFlaky = append(diff.NewlyFlaky, test)
+		}
+		diff.Phases = append(diff.Phases, phaseDeltas(test, prev, result)...)
+	}
+	for _, test := range sortedTests(before) {
+		if _, ok := after[test]; !ok {
+			diff.Removed = append(diff.Removed, test)
+		}
+	}
+	return diff
+}
+
+// String formats the differences for the console
+func (r ReportDiff) String() string {
+	var b strings.Builder
+	fmt.Fprintln(&b, "Outcome changes:")
+	for _, change := range r.Changed {
+		if change.Quarantined {
+			fmt.Fprintf(&b, "  %v %v -> %v (quarantined)\n", change.Test, change.Before, change.After)
+		} else {
+			fmt.Fprintf(&b, "  %v %v -> %v\n", change.Test, change.Before, change.After)
+		}
+	}
+	fmt.Fprintln(&b, "Newly flaky:")
+	for _, test := range r.NewlyFlaky {
+		fmt.Fprintf(&b, "  %v\n", test)
+	}
+	fmt.Fprintln(&b, "Added tests:")
+	for _, test := range r.Added {
+		fmt.Fprintf(&b, "  %v\n", test)
+	}
+	fmt.Fprintln(&b, "Removed tests:")
+	for _, test := range r.Removed {
+		fmt.Fprintf(&b, "  %v\n", test)
+	}
+	fmt.Fprintln(&b, "Phase durations:")
+	for _, delta := range r.Phases {
+		fmt.Fprintf(&b, "  %v %v %v -> %v (%v)\n", delta.Test, delta.Phase,
+			delta.Before.Round(time.Second), delta.After.Round(time.Second), delta.change())
+	}
+	return b.String()
+}
+
+// Regressed returns true if a test passed in the baseline run but not in the run.
+// Known issues and quarantined tests are not regressions
+func (r ReportDiff) Regressed() bool {
+	for _, change := range r.Changed {
+		if change.Quarantined || change.After == ClassKnownIssue {
+			continue
+		}
+		if isPass(change.Before) && !isPass(change.After) {
+			return true
+		}
+	}
+	return false
+}
+
+func isPass(class string) bool {
+	return class == ClassPassed || class == ClassFlake
+}
+
+// resultsByTest returns the classified results of the run by test name without the tag of the run
+func resultsByTest(report RunReport) map[string]TestResult {
+	results := make(map[string]TestResult)
+	for _, result := range Classify(report.Results) {
+		results[testName(report, result.Test)] = result
+	}
+	return results
+}
+
+func sortedTests(results map[string]TestResult) []string {
+	tests := make([]string, 0, len(results))
+	for test := range results {
+		tests = append(tests, test)
+	}
+	sort.Strings(tests)
+	return tests
+}
+
+// phaseDeltas returns the differences of the time spent in the phases present in both results
+func phaseDeltas(test string, before, after TestResult) (deltas []PhaseDelta) {
+	prev, next := phaseTotals(before), phaseTotals(after)
+	for _, phase := range phaseOrder(after) {
+		duration, ok := prev[phase]
+		if !ok {
+			continue
+		}
+		deltas = append(deltas, PhaseDelta{
+			Test:   test,
+			Phase:  phase,
+			Before: duration,
+			After:  next[phase],
+		})
+	}
+	return deltas
+}
+
+// phaseTotals returns the time spent in each phase by the last attempt, a repeated phase adding up
+func phaseTotals(result TestResult) map[string]time.Duration {
+	totals := make(map[string]time.Duration)
+	for _, phase := range lastAttempt(result).Phases {
+		totals[phase.Phase] += phase.Duration
+	}
+	return totals
+}
+
+// phaseOrder returns the phases of the last attempt in the order they were first entered
+func phaseOrder(result TestResult) (phases []string) {
+	seen := make(map[string]bool)
+	for _, phase := range lastAttempt(result).Phases {
+		if !seen[phase.Phase] {
+			seen[phase.Phase] = true
+			phases = append(phases, phase.Phase)
+		}
+	}
+	return phases
+}
+
+func lastAttempt(result TestResult) TestStatus {
+	return result.Attempts[len(result.Attempts)-1]
+}
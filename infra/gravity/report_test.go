@@ -0,0 +1,111 @@
+package gravity
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gravitational/robotest/lib/failure"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareReports(t *testing.T) {
+	baseline := RunReport{Tag: "nightly-1", Results: []TestStatus{
+		{Test: "nightly-1-install-1", Attempt: 1, Status: TestStatusPassed, Phases: []PhaseDuration{
+			{Phase: "provision", Duration: 5 * time.Minute},
+			{Phase: "install", Duration: 10 * time.Minute},
+		}},
+		{Test: "nightly-1-upgrade-1", Attempt: 1, Status: TestStatusPassed},
+		{Test: "nightly-1-resize-1", Attempt: 1, Status: TestStatusPassed},
+	}}
+	run := RunReport{Tag: "nightly-2", Results: []TestStatus{
+		{Test: "nightly-2-install-1", Attempt: 1, Status: TestStatusPassed, Phases: []PhaseDuration{
+			{Phase: "provision", Duration: 4 * time.Minute},
+			{Phase: "install", Duration: 8 * time.Minute},
+			{Phase: "status", Duration: time.Minute},
+			{Phase: "install", Duration: 4 * time.Minute},
+		}},
+		{Test: "nightly-2-upgrade-1", Attempt: 1, Status: TestStatusFailed},
+		{Test: "nightly-2-upgrade-1", Attempt: 2, Status: TestStatusPassed},
+		{Test: "nightly-2-expand-1", Attempt: 1, Status: TestStatusPassed},
+	}}
+
+	diff := CompareReports(baseline, run)
+	assert.Equal(t, ReportDiff{
+		Changed:    []OutcomeChange{{Test: "upgrade-1", Before: ClassPassed, After: ClassFlake}},
+		Added:      []string{"expand-1"},
+		Removed:    []string{"resize-1"},
+		NewlyFlaky: []string{"upgrade-1"},
+		Phases: []PhaseDelta{
+			{Test: "install-1", Phase: "provision", Before: 5 * time.Minute, After: 4 * time.Minute},
+			{Test: "install-1", Phase: "install", Before: 10 * time.Minute, After: 12 * time.Minute},
+		},
+	}, diff)
+	assert.False(t, diff.Regressed())
+	assert.Contains(t, diff.String(), "install-1 install 10m0s -> 12m0s (+2m0s)")
+	assert.Contains(t, diff.String(), "install-1 provision 5m0s -> 4m0s (-1m0s)")
+
+	run.Results[1].Status, run.Results[2].Status = TestStatusFailed, TestStatusFailed
+	assert.True(t, CompareReports(baseline, run).Regressed())
+	run.Results[2].Quarantine = &Quarantine{Reason: "flaky upgrade"}
+	assert.False(t, CompareReports(baseline, run).Regressed(), "quarantined")
+}
+
+func TestReportRoundtrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "report")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "report.json")
+	report := RunReport{Suite: "sanity", Tag: "nightly-1", Results: []TestStatus{
+		{Name: "nightly-1-install-1", Test: "nightly-1-install-1", Attempt: 1, Status: TestStatusPassed,
+			Phases: []PhaseDuration{{Phase: "install", Duration: time.Minute}}},
+	}}
+	require.NoError(t, WriteReport(path, report))
+	read, err := ReadReport(path)
+	require.NoError(t, err)
+	assert.Equal(t, report, *read)
+}
+
+func TestConsistentFailures(t *testing.T) {
+	results := Classify([]TestStatus{
+		{Test: "install-1", Attempt: 1, Status: TestStatusFailed, Category: failure.CategoryGravityOperation, Error: "install failed\ndetails 1"},
+		{Test: "install-1", Attempt: 2, Status: TestStatusFailed, Category: failure.CategoryGravityOperation, Error: "install failed\ndetails 2"},
+		{Test: "upgrade-1", Attempt: 1, Status: TestStatusFailed, Category: failure.CategoryAssertion, Error: "status degraded"},
+		{Test: "upgrade-1", Attempt: 2, Status: TestStatusFailed, Category: failure.CategoryAssertion, Error: "timeout"},
+		{Test: "resize-1", Attempt: 1, Status: TestStatusFailed, Category: failure.CategoryUnknown, Error: "unknown"},
+		{Test: "expand-1", Attempt: 1, Status: TestStatusFailed, Category: failure.CategorySSHTransport, Error: "ssh"},
+		{Test: "expand-1", Attempt: 2, Status: TestStatusFailed, Category: failure.CategoryAssertion, Error: "ssh"},
+		{Test: "shrink-1", Attempt: 1, Status: TestStatusFailed, Category: failure.CategoryGravityOperation, Error: "shrink failed"},
+	})
+	var tests []string
+	for _, result := range ConsistentFailures(results) {
+		tests = append(tests, result.Test)
+	}
+	assert.Equal(t, []string{"install-1"}, tests)
+}
+
+func TestGroupBySignature(t *testing.T) {
+	statuses := []TestStatus{
+		{Name: "run-install-1", Test: "run-install-1", Category: failure.CategoryAssertion, Error: "no leader on 10.0.0.1"},
+		{Name: "run-install-1-T2", Test: "run-install-1", Category: failure.CategoryAssertion, Error: "no leader on 10.0.0.2"},
+		{Name: "run-upgrade-1", Test: "run-upgrade-1", Category: failure.CategoryGravityOperation, Error: "run-upgrade-1-node-0 timed out"},
+		{Name: "run-resize-1", Test: "run-resize-1", Status: TestStatusPassed},
+	}
+	db, err := failure.NewSignatureDB([]failure.KnownSignature{{Pattern: "<run>-node-<n> timed out", Description: "slow nodes"}})
+	require.NoError(t, err)
+	MatchKnownIssues(statuses, db)
+
+	groups := GroupBySignature(statuses)
+	require.Len(t, groups, 2)
+	assert.Equal(t, "no leader on <ip>", groups[0].Error)
+	assert.Equal(t, []string{"run-install-1", "run-install-1-T2"}, groups[0].Attempts)
+	assert.Nil(t, groups[0].KnownIssue)
+	assert.Equal(t, "<run>-node-<n> timed out", groups[1].Error)
+	require.NotNil(t, groups[1].KnownIssue)
+	assert.Equal(t, "slow nodes", groups[1].KnownIssue.Description)
+}
@@ -0,0 +1,100 @@
+package gravity
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gravitational/robotest/lib/secret"
+	"github.com/gravitational/robotest/lib/shell"
+	sshutils "github.com/gravitational/robotest/lib/ssh"
+
+	"github.com/gravitational/trace"
+)
+
+// resolveSecrets replaces the secret references (env:NAME, file:PATH, vault:PATH#KEY, see secret.Resolve)
+// in the configuration with their values and registers the values for redaction,
+// so that tokens, licenses and cloud credentials do not leak into logs and artifacts
+func resolveSecrets(cfg *ProvisionerConfig) error {
+	if cfg.Vault != nil {
+		if err := registerVaultResolver(*cfg.Vault); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	var refs []*string
+	if cfg.AWS != nil {
+		refs = append(refs, &cfg.AWS.AccessKey, &cfg.AWS.SecretKey, &cfg.AWS.SessionToken)
+	}
+	if cfg.Azure != nil {
+		refs = append(refs, &cfg.Azure.ClientSecret)
+	}
+	if cfg.Ops != nil {
+		refs = append(refs, &cfg.Ops.EC2AccessKey, &cfg.Ops.EC2SecretKey)
+	}
+	if cfg.OpsCenter != nil {
+		refs = append(refs, &cfg.OpsCenter.Key, &cfg.OpsCenter.ClusterToken)
+	}
+	if cfg.Registry != nil {
+		refs = append(refs, &cfg.Registry.Password)
+	}
+	refs = append(refs, &cfg.License)
+	for _, ref := range refs {
+		if *ref == "" {
+			continue
+		}
+		value, err := secret.Resolve(*ref)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		*ref = value
+	}
+	return nil
+}
+
+// secretEnv returns the environment that passes the join token and the license
+// to the install and join commands, see runWithSecrets
+func (config ProvisionerConfig) secretEnv(token string) map[string]string {
+	secret.Register(token)
+	env := map[string]string{"GRAVITY_TOKEN": token}
+	if config.License != "" {
+		env["GRAVITY_LICENSE"] = config.License
+	}
+	return env
+}
+
+// runWithSecrets executes the command cmd on the node with the secrets exported into its environment.
+// Unlike the environment passed to run, the secrets are not sent with the session command,
+// which is visible in the process list of the node: they are written into a file readable
+// only by the SSH user, which the command sources and removes before running
+func (g *gravity) runWithSecrets(ctx context.Context, cmd string, secrets map[string]string) error {
+	path := filepath.Join(g.param.homeDir, secretsFile)
+	err := sshutils.WriteFile(ctx, g.Client(), g.Logger(), path, []byte(secretsScript(secrets)))
+	if err != nil {
+		return trace.Wrap(err, "failed to write secrets")
+	}
+	return trace.Wrap(g.run(ctx, g.Logger(), withSecrets(path, cmd), nil))
+}
+
+// secretsScript returns the shell script that exports the secrets
+func secretsScript(secrets map[string]string) string {
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "export %v=%v\n", name, shell.Quote(secrets[name]))
+	}
+	return b.String()
+}
+
+// withSecrets returns the command cmd preceded by sourcing and removing the secrets script at path
+func withSecrets(path, cmd string) string {
+	return fmt.Sprintf(". %[1]v; rm -f %[1]v; %v", shell.Quote(path), cmd)
+}
+
+// secretsFile names the file in the home directory of the SSH user the secrets are passed with
+const secretsFile = ".robotest-secrets"
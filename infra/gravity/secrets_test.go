@@ -0,0 +1,21 @@
+package gravity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecretsScript(t *testing.T) {
+	script := secretsScript(map[string]string{
+		"GRAVITY_TOKEN":   "it's $(id)",
+		"GRAVITY_LICENSE": "license",
+	})
+	assert.Equal(t, "export GRAVITY_LICENSE=license\nexport GRAVITY_TOKEN='it'\\''s $(id)'\n", script)
+}
+
+func TestWithSecrets(t *testing.T) {
+	assert.Equal(t, ". /home/robotest/.robotest-secrets; rm -f /home/robotest/.robotest-secrets; "+
+		`cd /home/robotest/installer && sudo ./gravity join --token="$GRAVITY_TOKEN"`,
+		withSecrets("/home/robotest/.robotest-secrets", `cd /home/robotest/installer && sudo ./gravity join --token="$GRAVITY_TOKEN"`))
+}
@@ -0,0 +1,99 @@
+package gravity
+
+import (
+	"context"
+	"strings"
+
+	sshutils "github.com/gravitational/robotest/lib/ssh"
+	"github.com/gravitational/robotest/lib/utils"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+)
+
+// AssertSELinuxEnforcing verifies that SELinux is in enforcing mode on all nodes
+func (c *TestContext) AssertSELinuxEnforcing(nodes []Gravity) error {
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	errs := make(chan error, len(nodes))
+	for _, node := range nodes {
+		go func(node Gravity) {
+			mode, err := selinuxMode(ctx, node.Client(), node.Logger())
+			if err == nil && mode != selinuxEnforcing {
+				err = trace.CompareFailed("SELinux is %v on %v, expected %v", mode, node, selinuxEnforcing)
+			}
+			errs <- trace.Wrap(err)
+		}(node)
+	}
+	return trace.Wrap(utils.CollectErrors(ctx, errs))
+}
+
+// enforceSELinux switches SELinux on the node into enforcing mode.
+// If SELinux is disabled, the node is rebooted with file system relabeling
+func enforceSELinux(ctx context.Context, node *gravity) error {
+	if !isRedHatFamily(node.param.os.Vendor) {
+		return trace.BadParameter("SELinux is only supported on CentOS/RHEL, not %v", node.param.os.Vendor)
+	}
+	mode, err := selinuxMode(ctx, node.Client(), node.Logger())
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	log := node.Logger().WithField("selinux", mode)
+	if mode == selinuxEnforcing {
+		log.Debug("SELinux is enforcing.")
+		return nil
+	}
+
+	err = node.run(ctx, log, `sudo sed -i 's/^SELINUX=.*/SELINUX=enforcing/' /etc/selinux/config`, nil)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if mode == selinuxPermissive {
+		log.Info("Switch SELinux to enforcing mode.")
+		return trace.Wrap(node.run(ctx, log, "sudo setenforce 1", nil))
+	}
+
+	log.Info("Enable SELinux and reboot.")
+	err = node.run(ctx, log, "sudo touch /.autorelabel", nil)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	err = node.Reboot(ctx, Graceful(true))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	mode, err = selinuxMode(ctx, node.Client(), node.Logger())
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if mode != selinuxEnforcing {
+		return trace.CompareFailed("SELinux is %v after reboot, expected %v", mode, selinuxEnforcing)
+	}
+	return nil
+}
+
+// selinuxMode returns the current SELinux mode as reported by getenforce
+func selinuxMode(ctx context.Context, client *ssh.Client, log logrus.FieldLogger) (string, error) {
+	var out string
+	err := sshutils.RunAndParse(ctx, client, log, "getenforce", nil, sshutils.ParseAsString(&out))
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+func isRedHatFamily(vendor string) bool {
+	switch vendor {
+	case "centos", "redhat":
+		return true
+	default:
+		return false
+	}
+}
+
+const (
+	selinuxEnforcing  = "Enforcing"
+	selinuxPermissive = "Permissive"
+)
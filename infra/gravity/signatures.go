@@ -0,0 +1,90 @@
+package gravity
+
+import (
+	"sort"
+
+	"github.com/gravitational/robotest/lib/failure"
+)
+
+// FailureSignature returns the signature of the failure of the attempt, see failure.Signature.
+// The signature is computed from the error for the reports of the runs that did not record it
+func FailureSignature(status TestStatus) string {
+	if status.Signature != "" || status.Error == "" {
+		return status.Signature
+	}
+	return failure.Signature(status.Category, status.Error, status.Name, status.Test)
+}
+
+var knownIssues *failure.SignatureDB
+
+// SetKnownIssues sets the database of the known failures.
+// Tests failing with a known failure are reported as known issues instead of failing the suite
+func SetKnownIssues(db *failure.SignatureDB) {
+	knownIssues = db
+}
+
+// knownIssue returns the known failure the error of the test matches, if any
+func (c *TestContext) knownIssue() *failure.KnownSignature {
+	if c == nil {
+		return nil
+	}
+	message := errorMessage(c.err)
+	if message == "" {
+		return nil
+	}
+	signature := failure.Signature(failure.CategoryOf(c.err), message, c.name, c.test)
+	return knownIssues.Match(signature, failure.Normalize(message, c.name, c.test))
+}
+
+// MatchKnownIssues records the known failure each failed attempt matches in the database, if any
+func MatchKnownIssues(statuses []TestStatus, db *failure.SignatureDB) {
+	for i, status := range statuses {
+		if status.Error == "" {
+			continue
+		}
+		normalized := failure.Normalize(status.Error, status.Name, status.Test)
+		statuses[i].KnownIssue = db.Match(FailureSignature(status), normalized)
+	}
+}
+
+// SignatureGroup groups the failed attempts with the same failure signature
+type SignatureGroup struct {
+	// Signature is the failure signature
+	Signature string
+	// Category is the failure category
+	Category failure.Category
+	// Error is the normalized error of the failure
+	Error string
+	// Attempts lists the names of the failed attempts
+	Attempts []string
+	// KnownIssue is the known failure of the signature, if any
+	KnownIssue *failure.KnownSignature
+}
+
+// GroupBySignature groups the failed attempts by the failure signature,
+// the most frequent failures first
+func GroupBySignature(statuses []TestStatus) (groups []SignatureGroup) {
+	index := make(map[string]int)
+	for _, status := range statuses {
+		signature := FailureSignature(status)
+		if signature == "" {
+			continue
+		}
+		i, ok := index[signature]
+		if !ok {
+			i = len(groups)
+			index[signature] = i
+			groups = append(groups, SignatureGroup{
+				Signature:  signature,
+				Category:   status.Category,
+				Error:      failure.Normalize(status.Error, status.Name, status.Test),
+				KnownIssue: status.KnownIssue,
+			})
+		}
+		groups[i].Attempts = append(groups[i].Attempts, status.Name)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return len(groups[i].Attempts) > len(groups[j].Attempts)
+	})
+	return groups
+}
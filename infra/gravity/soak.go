@@ -0,0 +1,261 @@
+package gravity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gravitational/robotest/lib/constants"
+	"github.com/gravitational/robotest/lib/defaults"
+	sshutils "github.com/gravitational/robotest/lib/ssh"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// SoakConfig configures a soak run, see Soak
+type SoakConfig struct {
+	// Duration is how long to keep the cluster running
+	Duration time.Duration
+	// Interval is the time between the health samples
+	Interval time.Duration
+	// MinGrowth is the relative growth of a sampled value over the run
+	// that is flagged if the value grows monotonically, i.e. 0.2 for 20%.
+	// Zero disables the trend detection
+	MinGrowth float64
+}
+
+// SoakSample is a health sample taken during a soak run
+type SoakSample struct {
+	// Time is the time the sample has been taken
+	Time time.Time `json:"time"`
+	// Healthy is true if the status has been reported on all nodes
+	Healthy bool `json:"healthy"`
+	// EtcdDBSize is the largest etcd database size in bytes across the nodes
+	EtcdDBSize float64 `json:"etcd_db_size"`
+	// DiskUsage is the highest usage of the gravity state directory filesystem
+	// across the nodes in percent
+	DiskUsage float64 `json:"disk_usage"`
+	// APILatency is the time to list the pods with the Kubernetes API
+	APILatency time.Duration `json:"api_latency"`
+}
+
+// SoakReport is the result of a soak run
+type SoakReport struct {
+	// Samples lists the health samples in order
+	Samples []SoakSample `json:"samples"`
+	// Trends lists the values that have been growing monotonically over the run
+	Trends []string `json:"trends,omitempty"`
+}
+
+// Soak keeps the cluster running for the configured duration and samples the
+// status, the etcd database size, the disk usage and the API latency every interval.
+// Sampled values that grow monotonically over the run are reported as trends
+// and fail the run (unless disabled with MinGrowth), as well as samples with the status
+// not available on all nodes
+func (c *TestContext) Soak(nodes []Gravity, config SoakConfig) (*SoakReport, error) {
+	if len(nodes) == 0 {
+		return nil, trace.BadParameter("at least one node is required")
+	}
+	if config.Interval <= 0 || config.Duration < config.Interval {
+		return nil, trace.BadParameter("invalid soak interval %v for duration %v", config.Interval, config.Duration)
+	}
+	if config.MinGrowth < 0 {
+		return nil, trace.BadParameter("invalid minimum growth %v", config.MinGrowth)
+	}
+	c.Logger().WithFields(logrus.Fields{
+		"duration": config.Duration,
+		"interval": config.Interval,
+	}).Info("Start soak.")
+
+	report := &SoakReport{}
+	unhealthy := 0
+	deadline := time.Now().Add(config.Duration)
+	for {
+		sample := c.soakSample(nodes)
+		report.Samples = append(report.Samples, sample)
+		if !sample.Healthy {
+			unhealthy++
+		}
+		c.Logger().WithFields(logrus.Fields{
+			"healthy":      sample.Healthy,
+			"etcd_db_size": sample.EtcdDBSize,
+			"disk_usage":   sample.DiskUsage,
+			"api_latency":  sample.APILatency,
+		}).Info("Soak sample.")
+		if time.Now().Add(config.Interval).After(deadline) {
+			break
+		}
+		select {
+		case <-time.After(config.Interval):
+		case <-c.ctx.Done():
+			return report, trace.Wrap(c.ctx.Err())
+		}
+	}
+
+	if config.MinGrowth != 0 {
+		report.Trends = soakTrends(report.Samples, config.MinGrowth)
+	}
+	if err := c.saveSoakReport(report); err != nil {
+		c.Logger().WithError(err).Warn("Failed to save soak report.")
+	}
+	var errors []error
+	if unhealthy != 0 {
+		errors = append(errors, trace.CompareFailed("cluster unhealthy in %v out of %v samples",
+			unhealthy, len(report.Samples)))
+	}
+	if len(report.Trends) != 0 {
+		errors = append(errors, trace.CompareFailed("growing monotonically: %v", strings.Join(report.Trends, ", ")))
+	}
+	return report, trace.NewAggregate(errors...)
+}
+
+// saveSoakReport writes the report to soak.json in the state directory
+func (c *TestContext) saveSoakReport(report *SoakReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if err := os.MkdirAll(c.provisionerConfig().StateDir, constants.SharedDirMask); err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	path := filepath.Join(c.provisionerConfig().StateDir, "soak.json")
+	return trace.ConvertSystemError(ioutil.WriteFile(path, data, constants.SharedReadMask))
+}
+
+// soakSample samples the cluster health on the nodes.
+// Without nodes, the sample is unhealthy
+func (c *TestContext) soakSample(nodes []Gravity) (sample SoakSample) {
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	sample.Time = time.Now()
+	if len(nodes) == 0 {
+		return sample
+	}
+	sample.Healthy = true
+	for node, status := range c.StatusAll(ctx, nodes) {
+		if status.Err != nil || status.Status.IsDegraded() {
+			sample.Healthy = false
+		}
+		g, ok := node.(*gravity)
+		if !ok || status.Err != nil {
+			continue
+		}
+		if size, err := etcdDBSize(ctx, g); err == nil && size > sample.EtcdDBSize {
+			sample.EtcdDBSize = size
+		}
+		if usage, err := diskUsage(ctx, g, defaults.GravityDir); err == nil && usage > sample.DiskUsage {
+			sample.DiskUsage = usage
+		}
+	}
+	start := time.Now()
+	if _, err := nodes[0].RunInPlanet(ctx, "/usr/bin/kubectl", "get", "pods", "--all-namespaces"); err != nil {
+		sample.Healthy = false
+	} else {
+		sample.APILatency = time.Since(start)
+	}
+	return sample
+}
+
+// soakTrends returns the names of the sampled values that grow monotonically
+// by at least minGrowth over the samples
+func soakTrends(samples []SoakSample, minGrowth float64) (trends []string) {
+	values := map[string][]float64{}
+	for _, sample := range samples {
+		values["etcd_db_size"] = append(values["etcd_db_size"], sample.EtcdDBSize)
+		values["disk_usage"] = append(values["disk_usage"], sample.DiskUsage)
+		if sample.APILatency != 0 {
+			values["api_latency"] = append(values["api_latency"], float64(sample.APILatency))
+		}
+	}
+	for name, values := range values {
+		if growsMonotonically(values, minGrowth) {
+			trends = append(trends, name)
+		}
+	}
+	sort.Strings(trends)
+	return trends
+}
+
+// growsMonotonically returns true if the means of soakTrendWindows consecutive windows
+// of values are increasing and the last mean exceeds the first by at least minGrowth.
+// Windows smooth the noise of the individual samples
+func growsMonotonically(values []float64, minGrowth float64) bool {
+	if len(values) < soakTrendWindows {
+		return false
+	}
+	means := make([]float64, soakTrendWindows)
+	for i := range means {
+		window := values[i*len(values)/soakTrendWindows : (i+1)*len(values)/soakTrendWindows]
+		for _, value := range window {
+			means[i] += value
+		}
+		means[i] /= float64(len(window))
+	}
+	for i := 1; i < len(means); i++ {
+		if means[i] <= means[i-1] {
+			return false
+		}
+	}
+	return means[0] > 0 && means[len(means)-1]/means[0]-1 >= minGrowth
+}
+
+// etcdDBSize returns the size of the etcd database on the node in bytes
+func etcdDBSize(ctx context.Context, node *gravity) (float64, error) {
+	args := []string{"--silent", "--fail"}
+	for _, endpoint := range metricsEndpoints {
+		if endpoint.name == "etcd" {
+			args = append(args, endpoint.args...)
+		}
+	}
+	out, err := node.RunInPlanet(ctx, "/usr/bin/curl", args...)
+	if err != nil {
+		return 0, trace.Wrap(err)
+	}
+	return parseMetric(out, "etcd_mvcc_db_total_size_in_bytes", "etcd_debugging_mvcc_db_total_size_in_bytes")
+}
+
+// diskUsage returns the usage of the filesystem of dir on the node in percent
+func diskUsage(ctx context.Context, node *gravity, dir string) (float64, error) {
+	var out string
+	err := node.runAndParse(ctx, node.Logger(), fmt.Sprintf("df --output=pcent %v | tail -1", dir),
+		nil, sshutils.ParseAsString(&out))
+	if err != nil {
+		return 0, trace.Wrap(err)
+	}
+	usage, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(out), "%"), 64)
+	if err != nil {
+		return 0, trace.BadParameter("invalid disk usage %q", out)
+	}
+	return usage, nil
+}
+
+// parseMetric returns the value of the first of the metrics given with names
+// found in the Prometheus text exposition out
+func parseMetric(out string, names ...string) (float64, error) {
+	for _, name := range names {
+		for _, line := range strings.Split(out, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) != 2 || fields[0] != name {
+				continue
+			}
+			value, err := strconv.ParseFloat(fields[1], 64)
+			if err != nil {
+				return 0, trace.BadParameter("invalid value of %v: %q", name, fields[1])
+			}
+			return value, nil
+		}
+	}
+	return 0, trace.NotFound("no metric %v", strings.Join(names, " or "))
+}
+
+// soakTrendWindows is the number of windows the samples are split into to detect trends
+const soakTrendWindows = 4
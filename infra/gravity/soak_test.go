@@ -0,0 +1,59 @@
+package gravity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGrowsMonotonically(t *testing.T) {
+	var testCases = []struct {
+		values   []float64
+		expected bool
+		comment  string
+	}{
+		{
+			values:   []float64{10, 12, 11, 14, 13, 16, 15, 18},
+			expected: true,
+			comment:  "noisy growth",
+		},
+		{
+			values:   []float64{10, 10, 10, 10, 10, 10, 10, 10},
+			expected: false,
+			comment:  "flat",
+		},
+		{
+			values:   []float64{100, 101, 101, 102, 102, 103, 103, 104},
+			expected: false,
+			comment:  "growth below threshold",
+		},
+		{
+			values:   []float64{10, 10, 30, 30, 12, 12, 40, 40},
+			expected: false,
+			comment:  "drop in between",
+		},
+		{
+			values:   []float64{1, 2, 3},
+			expected: false,
+			comment:  "too few samples",
+		},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, growsMonotonically(tc.values, 0.2), tc.comment)
+	}
+}
+
+func TestMetricParser(t *testing.T) {
+	out := `# HELP etcd_debugging_mvcc_db_total_size_in_bytes Total size of the underlying database in bytes.
+# TYPE etcd_debugging_mvcc_db_total_size_in_bytes gauge
+etcd_debugging_mvcc_db_total_size_in_bytes 4.009984e+06
+etcd_server_has_leader 1
+`
+	value, err := parseMetric(out, "etcd_mvcc_db_total_size_in_bytes", "etcd_debugging_mvcc_db_total_size_in_bytes")
+	require.NoError(t, err)
+	assert.Equal(t, 4009984.0, value)
+
+	_, err = parseMetric(out, "etcd_mvcc_db_total_size_in_bytes")
+	assert.Error(t, err)
+}
@@ -0,0 +1,278 @@
+package gravity
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// ClusterState is a snapshot of the cluster state for comparison, see DiffState.
+// It maps the objects of the gravity status, the Kubernetes resources and
+// the etcd members to their descriptions:
+//
+//	cluster/state: active
+//	node/10.0.0.1: profile=node leader=true
+//	kube/Deployment/kube-system/coredns: coredns/coredns:1.2.6
+//	etcd/10_0_0_1.example: peers=https://10.0.0.1:2380 leader=true
+//
+// A part of the state that cannot be captured is recorded as <part>/error
+type ClusterState map[string]string
+
+// StateDiff describes the difference between two cluster states
+type StateDiff struct {
+	// Name names the operation the states have been captured around
+	Name string `json:"name"`
+	// Added lists the objects only present after the operation
+	Added map[string]string `json:"added,omitempty"`
+	// Removed lists the objects only present before the operation
+	Removed map[string]string `json:"removed,omitempty"`
+	// Changed lists the objects with a different description after the operation
+	Changed map[string]StateChange `json:"changed,omitempty"`
+}
+
+// StateChange describes an object that has changed between two cluster states
+type StateChange struct {
+	// Before describes the object before the operation
+	Before string `json:"before"`
+	// After describes the object after the operation
+	After string `json:"after"`
+}
+
+// Empty returns true if the states are identical
+func (r StateDiff) Empty() bool {
+	return len(r.Added) == 0 && len(r.Removed) == 0 && len(r.Changed) == 0
+}
+
+// String summarizes the difference as one line per object
+func (r StateDiff) String() string {
+	var lines []string
+	for key, value := range r.Added {
+		lines = append(lines, fmt.Sprintf("+ %v: %v", key, value))
+	}
+	for key, value := range r.Removed {
+		lines = append(lines, fmt.Sprintf("- %v: %v", key, value))
+	}
+	for key, change := range r.Changed {
+		lines = append(lines, fmt.Sprintf("~ %v: %v -> %v", key, change.Before, change.After))
+	}
+	// sort by object, not by the kind of change
+	sort.Slice(lines, func(i, j int) bool { return lines[i][2:] < lines[j][2:] })
+	return strings.Join(lines, "\n")
+}
+
+// DiffState returns the difference between the cluster states before and after the named operation
+func DiffState(name string, before, after ClusterState) StateDiff {
+	diff := StateDiff{
+		Name:    name,
+		Added:   make(map[string]string),
+		Removed: make(map[string]string),
+		Changed: make(map[string]StateChange),
+	}
+	for key, value := range after {
+		prev, ok := before[key]
+		switch {
+		case !ok:
+			diff.Added[key] = value
+		case prev != value:
+			diff.Changed[key] = StateChange{Before: prev, After: value}
+		}
+	}
+	for key, value := range before {
+		if _, ok := after[key]; !ok {
+			diff.Removed[key] = value
+		}
+	}
+	return diff
+}
+
+// CaptureState captures the state of the cluster as seen from node
+func (c *TestContext) CaptureState(node Gravity) (ClusterState, error) {
+	g, ok := node.(*gravity)
+	if !ok {
+		return nil, trace.BadParameter("unsupported node %v", node)
+	}
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	state := make(ClusterState)
+	if err := captureStatus(ctx, g, state); err != nil {
+		state["cluster/error"] = err.Error()
+	}
+	if err := captureResources(ctx, g, state); err != nil {
+		state["kube/error"] = err.Error()
+	}
+	if err := captureEtcdMembers(ctx, g, state); err != nil {
+		state["etcd/error"] = err.Error()
+	}
+	return state, nil
+}
+
+// WithStateDiff captures the cluster state before and after the named operation fn
+// and records the difference in the test report.
+// Failure to capture the state does not fail the operation
+func (c *TestContext) WithStateDiff(name string, nodes []Gravity, fn func() error) error {
+	if len(nodes) == 0 {
+		return trace.BadParameter("empty node list")
+	}
+	before, ok := c.captureStateOf(name, nodes)
+	if !ok {
+		return trace.Wrap(fn())
+	}
+	err := fn()
+	if after, ok := c.captureStateOf(name, nodes); ok {
+		c.recordStateDiff(DiffState(name, before, after))
+	}
+	return trace.Wrap(err)
+}
+
+// StateDiffSteps lists the steps of test scenarios that change the cluster,
+// see AddStateDiffHooks
+var StateDiffSteps = []string{"install", "expand", "shrink", "failover", "upgrade"}
+
+// AddStateDiffHooks registers the hooks to capture the cluster state before
+// and after each of the given steps and record the difference in the test report,
+// like WithStateDiff. The state before the install is empty.
+// Returns the function to remove the hooks
+func AddStateDiffHooks(steps ...string) (remove func()) {
+	var removes []func()
+	for _, step := range steps {
+		removes = append(removes,
+			AddBeforeHook(step, captureStateBefore),
+			AddAfterHook(step, recordStateAfter))
+	}
+	return func() {
+		for _, remove := range removes {
+			remove()
+		}
+	}
+}
+
+func captureStateBefore(c *TestContext, s *Scenario, step Step) error {
+	state := make(ClusterState)
+	if len(s.Nodes) != 0 {
+		var ok bool
+		if state, ok = c.captureStateOf(step.Name(), s.Nodes); !ok {
+			return nil
+		}
+	}
+	c.nodesMu.Lock()
+	defer c.nodesMu.Unlock()
+	if c.statesBefore == nil {
+		c.statesBefore = make(map[string]ClusterState)
+	}
+	c.statesBefore[step.Name()] = state
+	return nil
+}
+
+func recordStateAfter(c *TestContext, s *Scenario, step Step) error {
+	c.nodesMu.Lock()
+	before, ok := c.statesBefore[step.Name()]
+	delete(c.statesBefore, step.Name())
+	c.nodesMu.Unlock()
+	if !ok || len(s.Nodes) == 0 {
+		return nil
+	}
+	if after, ok := c.captureStateOf(step.Name(), s.Nodes); ok {
+		c.recordStateDiff(DiffState(step.Name(), before, after))
+	}
+	return nil
+}
+
+// captureStateOf captures the cluster state as seen from the first of the nodes for
+// the named operation. Failures are logged, the state is only returned if captured
+func (c *TestContext) captureStateOf(name string, nodes []Gravity) (state ClusterState, ok bool) {
+	state, err := c.CaptureState(nodes[0])
+	if err != nil {
+		c.Logger().WithField("operation", name).WithError(err).Warn("Failed to capture cluster state.")
+		return nil, false
+	}
+	return state, true
+}
+
+// recordStateDiff logs the difference and records it in the test report
+func (c *TestContext) recordStateDiff(diff StateDiff) {
+	c.Logger().WithFields(logrus.Fields{
+		"operation": diff.Name,
+		"added":     len(diff.Added),
+		"removed":   len(diff.Removed),
+		"changed":   len(diff.Changed),
+	}).Infof("Cluster state diff:\n%v", diff)
+	c.nodesMu.Lock()
+	c.stateDiffs = append(c.stateDiffs, diff)
+	c.nodesMu.Unlock()
+}
+
+// recordedStateDiffs returns the cluster state differences recorded by this test
+func (c *TestContext) recordedStateDiffs() []StateDiff {
+	c.nodesMu.Lock()
+	defer c.nodesMu.Unlock()
+	return append([]StateDiff(nil), c.stateDiffs...)
+}
+
+func captureStatus(ctx context.Context, g *gravity, state ClusterState) error {
+	// unlike Status, do not wait for the cluster to become healthy
+	status, err := g.status(ctx)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	state["cluster/application"] = fmt.Sprintf("%v:%v",
+		status.Cluster.Application.Name, status.Cluster.Application.Version)
+	state["cluster/state"] = status.Cluster.Status
+	for _, node := range status.Cluster.Nodes {
+		desc := fmt.Sprintf("profile=%v leader=%v", node.Profile, node.Leader)
+		if len(node.FailedProbes) != 0 {
+			desc += fmt.Sprintf(" failed_probes=%v", strings.Join(node.FailedProbes, ","))
+		}
+		state["node/"+node.Addr] = desc
+	}
+	return nil
+}
+
+// captureResources records the resources listed by kubectl get all with the images they run.
+// Objects owned by other objects (i.e. the pods and replica sets of a deployment) have
+// generated names that change with every rollout, so only their owners are recorded
+func captureResources(ctx context.Context, g *gravity, state ClusterState) error {
+	out, err := g.RunInPlanet(ctx, "/usr/bin/kubectl", "get", "all", "--all-namespaces",
+		`-ojsonpath={range .items[*]}{.kind}/{.metadata.namespace}/{.metadata.name}{"\t"}`+
+			`{.metadata.ownerReferences[*].kind}{"\t"}`+
+			`{.spec.template.spec.containers[*].image}{.spec.containers[*].image}{"\n"}{end}`)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(parseResources(out, state))
+}
+
+// parseResources records the resources from the output of captureResources,
+// one line per resource with the tab-separated object, owner kinds and images
+func parseResources(out string, state ClusterState) error {
+	for _, line := range strings.Split(out, "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) != 3 {
+			return trace.BadParameter("unexpected resource line %q", line)
+		}
+		if fields[1] != "" {
+			continue
+		}
+		state["kube/"+fields[0]] = fields[2]
+	}
+	return nil
+}
+
+func captureEtcdMembers(ctx context.Context, g *gravity, state ClusterState) error {
+	members, err := etcdMembers(ctx, g)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	for _, member := range members {
+		state["etcd/"+member.Name] = fmt.Sprintf("peers=%v leader=%v",
+			strings.Join(member.PeerURLs, ","), member.Leader)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package gravity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffState(t *testing.T) {
+	before := ClusterState{
+		"cluster/application":              "telekube:6.1.0",
+		"cluster/state":                    "active",
+		"kube/Pod/kube-system/coredns-abc": "coredns/coredns:1.2.6",
+		"etcd/10_0_0_1.example":            "peers=https://10.0.0.1:2380 leader=true",
+	}
+	after := ClusterState{
+		"cluster/application":              "telekube:7.0.0",
+		"cluster/state":                    "active",
+		"kube/Pod/kube-system/coredns-def": "coredns/coredns:1.6.2",
+		"etcd/10_0_0_1.example":            "peers=https://10.0.0.1:2380 leader=true",
+	}
+	diff := DiffState("upgrade", before, after)
+	assert.Equal(t, StateDiff{
+		Name:    "upgrade",
+		Added:   map[string]string{"kube/Pod/kube-system/coredns-def": "coredns/coredns:1.6.2"},
+		Removed: map[string]string{"kube/Pod/kube-system/coredns-abc": "coredns/coredns:1.2.6"},
+		Changed: map[string]StateChange{"cluster/application": {Before: "telekube:6.1.0", After: "telekube:7.0.0"}},
+	}, diff)
+	assert.Equal(t, `~ cluster/application: telekube:6.1.0 -> telekube:7.0.0
+- kube/Pod/kube-system/coredns-abc: coredns/coredns:1.2.6
++ kube/Pod/kube-system/coredns-def: coredns/coredns:1.6.2`, diff.String())
+	assert.True(t, DiffState("noop", before, before).Empty())
+}
+
+func TestParseResources(t *testing.T) {
+	out := "Deployment/kube-system/coredns\t\tcoredns/coredns:1.2.6\n" +
+		"ReplicaSet/kube-system/coredns-5d4b\tDeployment\tcoredns/coredns:1.2.6\n" +
+		"Pod/kube-system/coredns-5d4b-x2v9\tReplicaSet\tcoredns/coredns:1.2.6\n" +
+		"Pod/default/debug\t\tbusybox:1.31\n" +
+		"Service/default/kubernetes\t\t\n"
+	state := make(ClusterState)
+	require.NoError(t, parseResources(out, state))
+	assert.Equal(t, ClusterState{
+		"kube/Deployment/kube-system/coredns": "coredns/coredns:1.2.6",
+		"kube/Pod/default/debug":              "busybox:1.31",
+		"kube/Service/default/kubernetes":     "",
+	}, state)
+
+	assert.Error(t, parseResources("Pod/default/debug busybox:1.31\n", state))
+}
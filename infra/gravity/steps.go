@@ -0,0 +1,371 @@
+package gravity
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gravitational/trace"
+)
+
+// Scenario is the state of a test scenario composed of steps
+type Scenario struct {
+	// Config is the provisioner configuration to provision the nodes with
+	Config ProvisionerConfig
+	// Param specifies the install parameters for install and expand
+	Param InstallParam
+	// Cluster is the provisioned cluster
+	Cluster Cluster
+	// Nodes lists the nodes of the installed cluster
+	Nodes []Gravity
+}
+
+// Step is a building block of a test scenario, i.e. install or upgrade
+type Step interface {
+	// Name names the step, hooks are registered for steps by name
+	Name() string
+	// Run runs the step on the scenario
+	Run(c *TestContext, s *Scenario) error
+}
+
+// NewStep returns a step with the given name that runs fn
+func NewStep(name string, fn func(c *TestContext, s *Scenario) error) Step {
+	return funcStep{name: name, fn: fn}
+}
+
+// HookFunc is a hook run before or after a step, i.e. to collect the status after every step
+type HookFunc func(c *TestContext, s *Scenario, step Step) error
+
+// AddBeforeHook registers fn to run before every step with the given name,
+// or before all steps if step is empty.
+// A failing before hook fails the step.
+// Returns the function to remove the hook
+func AddBeforeHook(step string, fn HookFunc) (remove func()) {
+	return hooks.add(true, step, fn)
+}
+
+// AddAfterHook registers fn to run after every step with the given name,
+// or after all steps if step is empty.
+// After hooks run even if the step has failed. Errors of after hooks are logged
+// and do not fail the step.
+// Returns the function to remove the hook
+func AddAfterHook(step string, fn HookFunc) (remove func()) {
+	return hooks.add(false, step, fn)
+}
+
+// RunSteps runs the steps in order with the registered hooks.
+// The test is failed on the first failing step
+func (c *TestContext) RunSteps(s *Scenario, steps ...Step) {
+	for _, step := range steps {
+		c.SetPhase(step.Name())
+		c.OK(step.Name(), c.runStep(s, step))
+	}
+}
+
+func (c *TestContext) runStep(s *Scenario, step Step) error {
+	for _, fn := range hooks.matching(true, step.Name()) {
+		if err := fn(c, s, step); err != nil {
+			return trace.Wrap(err, "before %v hook failed", step.Name())
+		}
+	}
+	err := step.Run(c, s)
+	for _, fn := range hooks.matching(false, step.Name()) {
+		c.Maybe(fmt.Sprintf("after %v hook", step.Name()), fn(c, s, step))
+	}
+	return trace.Wrap(err)
+}
+
+// StatusHook logs the cluster status on each installed node, see StatusAll.
+// Nodes that do not respond are logged but do not fail the hook
+func StatusHook(c *TestContext, s *Scenario, step Step) error {
+	if len(s.Nodes) == 0 {
+		return nil
+	}
+	c.StatusAll(c.ctx, s.Nodes)
+	return nil
+}
+
+// ProvisionStep provisions the nodes with the scenario configuration.
+// The cluster is destroyed with Scenario.Cluster.Destroy
+type ProvisionStep struct{}
+
+// Name returns the name of this step
+func (ProvisionStep) Name() string { return "provision" }
+
+// Run provisions the nodes
+func (ProvisionStep) Run(c *TestContext, s *Scenario) (err error) {
+	s.Cluster, err = c.Provision(s.Config)
+	return trace.Wrap(err)
+}
+
+// InstallStep installs the cluster on the provisioned nodes
+type InstallStep struct {
+	// Nodes is the number of nodes to install on. Defaults to all provisioned nodes
+	Nodes int
+	// InstallerURL optionally overrides the installer of the provisioner configuration
+	InstallerURL string
+}
+
+// Name returns the name of this step
+func (InstallStep) Name() string { return "install" }
+
+// Run downloads the installer to all provisioned nodes and installs the cluster
+func (r InstallStep) Run(c *TestContext, s *Scenario) error {
+	count := len(s.Cluster.Nodes)
+	if r.Nodes != 0 {
+		count = r.Nodes
+	}
+	if count > len(s.Cluster.Nodes) {
+		return trace.BadParameter("cannot install on %v nodes, %v provisioned", count, len(s.Cluster.Nodes))
+	}
+	installerURL := s.Config.InstallerURL
+	if r.InstallerURL != "" {
+		installerURL = r.InstallerURL
+	}
+	if err := c.SetInstaller(s.Cluster.Nodes, installerURL, "install"); err != nil {
+		return trace.Wrap(err)
+	}
+	nodes := s.Cluster.Nodes[:count]
+	if err := c.OfflineInstall(nodes, s.Param); err != nil {
+		return trace.Wrap(err)
+	}
+	s.Nodes = nodes
+	return trace.Wrap(c.Status(s.Nodes))
+}
+
+// ExpandStep joins the next provisioned nodes to the cluster
+type ExpandStep struct {
+	// To is the number of cluster nodes after the expand
+	To int
+}
+
+// Name returns the name of this step
+func (ExpandStep) Name() string { return "expand" }
+
+// Run expands the cluster
+func (r ExpandStep) Run(c *TestContext, s *Scenario) error {
+	if r.To <= len(s.Nodes) || r.To > len(s.Cluster.Nodes) {
+		return trace.BadParameter("cannot expand from %v to %v nodes with %v provisioned",
+			len(s.Nodes), r.To, len(s.Cluster.Nodes))
+	}
+	extra := s.Cluster.Nodes[len(s.Nodes):r.To]
+	if err := c.Expand(s.Nodes, extra, s.Param); err != nil {
+		return trace.Wrap(err)
+	}
+	s.Nodes = s.Cluster.Nodes[:r.To]
+	return trace.Wrap(c.Status(s.Nodes))
+}
+
+// ShrinkStep removes the last joined nodes from the cluster
+type ShrinkStep struct {
+	// To is the number of cluster nodes after the shrink
+	To int
+}
+
+// Name returns the name of this step
+func (ShrinkStep) Name() string { return "shrink" }
+
+// Run gracefully shrinks the cluster
+func (r ShrinkStep) Run(c *TestContext, s *Scenario) error {
+	if r.To <= 0 || r.To >= len(s.Nodes) {
+		return trace.BadParameter("cannot shrink from %v to %v nodes", len(s.Nodes), r.To)
+	}
+	if err := c.ShrinkLeave(s.Nodes[:r.To], s.Nodes[r.To:]); err != nil {
+		return trace.Wrap(err)
+	}
+	s.Nodes = s.Nodes[:r.To]
+	return trace.Wrap(c.Status(s.Nodes))
+}
+
+// UpgradeStep upgrades the cluster
+type UpgradeStep struct {
+	// InstallerURL is the installer to upgrade to
+	InstallerURL string
+	// GravityURL optionally specifies the gravity binary to upgrade with
+	GravityURL string
+}
+
+// Name returns the name of this step
+func (UpgradeStep) Name() string { return "upgrade" }
+
+// Run upgrades the cluster
+func (r UpgradeStep) Run(c *TestContext, s *Scenario) error {
+	if err := c.Upgrade(s.Nodes, r.InstallerURL, r.GravityURL, "upgrade"); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(c.Status(s.Nodes))
+}
+
+// FailoverStep partitions the leader from the other nodes on the network
+// and verifies a new leader is elected, then heals the partition
+type FailoverStep struct {
+	// Duration is how long the leader is kept partitioned
+	Duration time.Duration
+}
+
+// Name returns the name of this step
+func (FailoverStep) Name() string { return "failover" }
+
+// Run partitions the leader and verifies the cluster once the partition has been healed
+func (r FailoverStep) Run(c *TestContext, s *Scenario) error {
+	leader, err := c.Leader(c.ctx, s.Nodes)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	others, err := c.NonLeaders(c.ctx, s.Nodes)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if err := c.Partition(leader, others); err != nil {
+		return trace.Wrap(err)
+	}
+	c.Sleep("partitioned leader", r.Duration)
+	_, err = c.Leader(c.ctx, others)
+	if errHeal := c.Heal([]Gravity{leader}); errHeal != nil {
+		return trace.NewAggregate(err, errHeal)
+	}
+	if err != nil {
+		return trace.Wrap(err, "no leader elected with %v partitioned", leader)
+	}
+	return trace.Wrap(c.Status(s.Nodes))
+}
+
+// StartLoadStep deploys the load generator to the cluster, see StartLoad
+type StartLoadStep struct {
+	// Config configures the load generator
+	Config LoadGeneratorConfig
+}
+
+// Name returns the name of this step
+func (StartLoadStep) Name() string { return "start_load" }
+
+// Run starts the load generator
+func (r StartLoadStep) Run(c *TestContext, s *Scenario) error {
+	return trace.Wrap(c.StartLoad(s.Nodes, r.Config))
+}
+
+// StopLoadStep verifies the load generator still runs and removes it, see StopLoad
+type StopLoadStep struct{}
+
+// Name returns the name of this step
+func (StopLoadStep) Name() string { return "stop_load" }
+
+// Run stops the load generator
+func (StopLoadStep) Run(c *TestContext, s *Scenario) error {
+	if err := c.AssertLoadRunning(s.Nodes); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(c.StopLoad(s.Nodes))
+}
+
+// StatusStep verifies the cluster status
+type StatusStep struct{}
+
+// Name returns the name of this step
+func (StatusStep) Name() string { return "status" }
+
+// Run verifies the cluster status on all installed nodes
+func (StatusStep) Run(c *TestContext, s *Scenario) error {
+	return trace.Wrap(c.Status(s.Nodes))
+}
+
+// CheckRegistryStep verifies the cluster registry from all installed nodes
+type CheckRegistryStep struct{}
+
+// Name returns the name of this step
+func (CheckRegistryStep) Name() string { return "check_registry" }
+
+// Run pushes and pulls the test images on all installed nodes
+func (CheckRegistryStep) Run(c *TestContext, s *Scenario) error {
+	return trace.Wrap(c.CheckRegistry(s.Nodes))
+}
+
+// CollectLogsStep collects the logs from all provisioned nodes
+type CollectLogsStep struct {
+	// Prefix names the directory to collect the logs into
+	Prefix string
+}
+
+// Name returns the name of this step
+func (CollectLogsStep) Name() string { return "collect_logs" }
+
+// Run collects the logs
+func (r CollectLogsStep) Run(c *TestContext, s *Scenario) error {
+	return trace.Wrap(c.CollectLogs(r.Prefix, s.Cluster.Nodes))
+}
+
+type funcStep struct {
+	name string
+	fn   func(c *TestContext, s *Scenario) error
+}
+
+func (r funcStep) Name() string { return r.name }
+
+func (r funcStep) Run(c *TestContext, s *Scenario) error { return r.fn(c, s) }
+
+// stepHooks is the registry of step hooks
+type stepHooks struct {
+	mu     sync.Mutex
+	before []stepHook
+	after  []stepHook
+	// nextID is the ID of the next registered hook
+	nextID int
+}
+
+// stepHook is a hook registered for steps with the given name, or all steps if empty
+type stepHook struct {
+	id   int
+	step string
+	fn   HookFunc
+}
+
+func (r *stepHooks) add(before bool, step string, fn HookFunc) (remove func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hook := stepHook{id: r.nextID, step: step, fn: fn}
+	r.nextID++
+	if before {
+		r.before = append(r.before, hook)
+	} else {
+		r.after = append(r.after, hook)
+	}
+	return func() {
+		r.remove(hook.id)
+	}
+}
+
+// remove unregisters the hook with the given ID
+func (r *stepHooks) remove(id int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.before = withoutHook(r.before, id)
+	r.after = withoutHook(r.after, id)
+}
+
+func withoutHook(hooks []stepHook, id int) (result []stepHook) {
+	for _, hook := range hooks {
+		if hook.id != id {
+			result = append(result, hook)
+		}
+	}
+	return result
+}
+
+// matching returns the before or after hooks registered for the step
+// given with name in registration order
+func (r *stepHooks) matching(before bool, name string) (fns []HookFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hooks := r.after
+	if before {
+		hooks = r.before
+	}
+	for _, hook := range hooks {
+		if hook.step == "" || hook.step == name {
+			fns = append(fns, hook.fn)
+		}
+	}
+	return fns
+}
+
+var hooks = &stepHooks{}
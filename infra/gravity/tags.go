@@ -0,0 +1,86 @@
+package gravity
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gravitational/robotest/lib/constants"
+)
+
+// ResourceTags defines the tagging policy for the provisioned cloud resources.
+// All resources created by the AWS and GCE provisioners are tagged
+// (labeled on GCE) with the owner, run ID, suite and expiry timestamp
+// to enable external cleanup automation and cost attribution
+type ResourceTags struct {
+	// Owner identifies the person or the system responsible for the resources
+	Owner string `yaml:"owner"`
+	// TTL specifies the expected lifetime of the resources.
+	// The expiry tag is set to the provisioning time plus TTL
+	TTL time.Duration `yaml:"ttl"`
+	// Extra specifies additional tags to attach
+	Extra map[string]string `yaml:"extra"`
+}
+
+const (
+	// TagOwner is the tag with the owner of the resources
+	TagOwner = "owner"
+	// TagRunID is the tag with the unique ID of the test run
+	TagRunID = "run-id"
+	// TagSuite is the tag with the name of the test suite
+	TagSuite = "suite"
+	// TagExpiry is the tag with the expiry time of the resources (as UNIX timestamp)
+	TagExpiry = "expiry"
+
+	// defaultResourceTTL defines the default expected lifetime of the resources
+	defaultResourceTTL = 12 * time.Hour
+)
+
+// resourceTags computes the tags for the resources provisioned with
+// the specified configuration at the given time
+func resourceTags(cfg ProvisionerConfig, now time.Time) map[string]string {
+	ttl := cfg.ResourceTags.TTL
+	if ttl == 0 {
+		ttl = defaultResourceTTL
+	}
+	tags := make(map[string]string, len(cfg.ResourceTags.Extra)+4)
+	for key, value := range cfg.ResourceTags.Extra {
+		tags[key] = value
+	}
+	if cfg.ResourceTags.Owner != "" {
+		tags[TagOwner] = cfg.ResourceTags.Owner
+	}
+	if cfg.suite != "" {
+		tags[TagSuite] = cfg.suite
+	}
+	tags[TagRunID] = cfg.tag
+	tags[TagExpiry] = strconv.FormatInt(now.Add(ttl).Unix(), 10)
+	if cfg.CloudProvider != constants.GCE {
+		return tags
+	}
+	labels := make(map[string]string, len(tags))
+	for key, value := range tags {
+		labels[gceLabel(key)] = gceLabel(value)
+	}
+	return labels
+}
+
+// gceLabel converts the value to the format accepted for GCE labels:
+// at most 63 lowercase letters, digits, underscores and dashes
+func gceLabel(value string) string {
+	label := strings.Map(func(r rune) rune {
+		r = unicode.ToLower(r)
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' || r == '-' {
+			return r
+		}
+		return '-'
+	}, value)
+	if len(label) > gceMaxLabelLength {
+		label = label[:gceMaxLabelLength]
+	}
+	return label
+}
+
+// gceMaxLabelLength is the maximum length of a GCE label key or value
+const gceMaxLabelLength = 63
@@ -0,0 +1,39 @@
+package gravity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gravitational/robotest/lib/constants"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResourceTags(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	cfg := ProvisionerConfig{
+		CloudProvider: constants.AWS,
+		ResourceTags: ResourceTags{
+			Owner: "CI Bot",
+			TTL:   time.Hour,
+			Extra: map[string]string{"team": "Platform"},
+		},
+	}.WithTag("install-3n").WithSuite("sanity")
+
+	assert.Equal(t, map[string]string{
+		TagOwner:  "CI Bot",
+		TagRunID:  "install-3n",
+		TagSuite:  "sanity",
+		TagExpiry: "1500003600",
+		"team":    "Platform",
+	}, resourceTags(cfg, now))
+
+	cfg.CloudProvider = constants.GCE
+	assert.Equal(t, map[string]string{
+		TagOwner:  "ci-bot",
+		TagRunID:  "install-3n",
+		TagSuite:  "sanity",
+		TagExpiry: "1500003600",
+		"team":    "platform",
+	}, resourceTags(cfg, now))
+}
@@ -1,9 +1,10 @@
 package gravity
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
-	"os"
 	"path/filepath"
 	"runtime/debug"
 	"sync"
@@ -14,7 +15,10 @@ import (
 	"github.com/gravitational/robotest/infra/terraform"
 	"github.com/gravitational/robotest/lib/constants"
 	"github.com/gravitational/robotest/lib/defaults"
+	sshutils "github.com/gravitational/robotest/lib/ssh"
+	"github.com/gravitational/robotest/lib/system"
 	"github.com/gravitational/robotest/lib/wait"
+	"github.com/gravitational/robotest/lib/xlog"
 
 	"github.com/gravitational/trace"
 	"github.com/sirupsen/logrus"
@@ -32,6 +36,15 @@ type ProvisionerPolicy struct {
 	AlwaysCollectLogs bool
 	// ResourceListFile keeps record of allocated and not cleaned up resources
 	ResourceListFile string
+	// DestroyOnInterrupt instructs to remove cloud resources of tests interrupted by the test suite
+	// cancellation (i.e. on SIGINT/SIGTERM) or node preemption. Otherwise the resources are
+	// checkpointed in the test state directory. Implied by DestroyOnFailure
+	DestroyOnInterrupt bool
+	// CollectLogsOnInterrupt requests to fetch logs from VMs of tests interrupted by the test suite cancellation
+	CollectLogsOnInterrupt bool
+	// MaxAttempts specifies the maximum number of attempts to run a failing test,
+	// each on a fresh cluster. Defaults to defaults.MaxRetriesPerTest
+	MaxAttempts int
 }
 
 var policy ProvisionerPolicy
@@ -40,9 +53,14 @@ func SetProvisionerPolicy(p ProvisionerPolicy) {
 	policy = p
 }
 
-var testStatus = map[bool]string{true: "failed", false: "ok"}
+func (r ProvisionerPolicy) maxAttempts() int {
+	if r.MaxAttempts <= 0 {
+		return defaults.MaxRetriesPerTest
+	}
+	return r.MaxAttempts
+}
 
-const finalTeardownTimeout = 20 * time.Minute
+var testStatus = map[bool]string{true: "failed", false: "ok"}
 
 // wrapDestroyFunc returns a function that wraps the specified set of nodes
 // and the given clean up function that implements report collection and resource clean up.
@@ -59,25 +77,18 @@ func wrapDestroyFunc(c *TestContext, tag string, nodes []Gravity, destroy func(c
 			}
 		}()
 
+		c.SetPhase(phaseTeardown)
 		log := c.Logger().WithFields(logrus.Fields{
 			"nodes":              nodes,
 			"provisioner_policy": policy,
 			"test_status":        testStatus[c.Failed()],
 		})
 
-		skipLogCollection := false
-		ctx := c.Context()
-
-		if ctx.Err() != nil && !policy.DestroyOnFailure {
-			log.WithError(ctx.Err()).Info("Skipping destroy.")
-			return trace.Wrap(ctx.Err())
-		}
-
-		if ctx.Err() != nil {
-			skipLogCollection = true
+		if c.Context().Err() != nil {
+			return trace.Wrap(teardownInterrupted(c, tag, nodes, destroy, log))
 		}
 
-		if !skipLogCollection && (c.Failed() || policy.AlwaysCollectLogs) {
+		if c.Failed() || policy.AlwaysCollectLogs {
 			log.Debug("Collecting logs from nodes...")
 			err := c.CollectLogs("postmortem", nodes)
 			if err != nil {
@@ -85,6 +96,21 @@ func wrapDestroyFunc(c *TestContext, tag string, nodes []Gravity, destroy func(c
 			}
 		}
 
+		if c.Failed() {
+			log.Debug("Collecting metrics from nodes...")
+			err := c.CollectMetrics("postmortem", nodes)
+			if err != nil {
+				log.WithError(err).Warn("Failed to collect node metrics.")
+			}
+		}
+
+		if c.Failed() {
+			err := c.CollectUnreachableConsoleOutput("postmortem", nodes)
+			if err != nil {
+				log.WithError(err).Warn("Failed to collect serial console output.")
+			}
+		}
+
 		if !policy.DestroyOnSuccess ||
 			(c.Failed() && !policy.DestroyOnFailure) {
 			log.Info("not destroying VMs per policy")
@@ -96,7 +122,7 @@ func wrapDestroyFunc(c *TestContext, tag string, nodes []Gravity, destroy func(c
 
 		log.Info("Destroying VMs.")
 
-		err := destroyResource(destroy)
+		err := destroyResource(destroy, c.timeouts.Teardown)
 		if err != nil {
 			log.WithError(err).Error("Failed to destroy VM resources.")
 		} else {
@@ -109,6 +135,105 @@ func wrapDestroyFunc(c *TestContext, tag string, nodes []Gravity, destroy func(c
 	}
 }
 
+// teardownInterrupted tears down the infrastructure of the test interrupted by the test suite
+// cancellation or node preemption.
+// It waits for the SSH commands in flight to stop, optionally collects logs
+// and then either destroys the infrastructure or checkpoints it for later clean up
+func teardownInterrupted(c *TestContext, tag string, nodes []Gravity, destroy func(context.Context) error, log logrus.FieldLogger) error {
+	interrupted := c.Context().Err()
+	log = log.WithField("interrupted", interrupted)
+
+	// Close the monitor processes
+	c.monitorCancel()
+	ctx, cancel := context.WithTimeout(context.Background(), interruptGracePeriod)
+	defer cancel()
+	if err := sshutils.WaitSessions(ctx, nodeHosts(nodes)); err != nil {
+		log.WithError(err).Warn("SSH commands did not stop in time.")
+	}
+
+	// the tests interrupted due to the test suite budget are always collected and destroyed
+	overBudget := c.suite.overBudget()
+	if (policy.CollectLogsOnInterrupt || overBudget) && !c.preempted {
+		log.Info("Collecting logs from interrupted nodes.")
+		if err := c.collectLogs(context.Background(), "interrupted", nodes); err != nil {
+			log.WithError(err).Warn("Failed to collect node logs.")
+		}
+	}
+
+	if !policy.DestroyOnInterrupt && !policy.DestroyOnFailure && !overBudget {
+		path, err := c.checkpoint(tag, nodes)
+		if err != nil {
+			log.WithError(err).Error("Failed to checkpoint infrastructure.")
+			return trace.NewAggregate(interrupted, err)
+		}
+		log.WithField("checkpoint", path).Info("Skipping destroy, infrastructure checkpointed.")
+		return trace.Wrap(interrupted)
+	}
+
+	log.Info("Destroying VMs.")
+	err := destroyResource(destroy, c.timeouts.Teardown)
+	if err != nil {
+		log.WithError(err).Error("Failed to destroy VM resources.")
+		return trace.Wrap(err)
+	}
+	if errDestroy := resourceDestroyed(tag); errDestroy != nil {
+		log.WithError(errDestroy).Warn("Failed to remove resource account.")
+	}
+	return nil
+}
+
+// checkpoint records the infrastructure of the test in the state directory
+// so that it can be inspected or destroyed later.
+// Returns the path of the checkpoint file
+func (c *TestContext) checkpoint(tag string, nodes []Gravity) (path string, err error) {
+	type node struct {
+		Addr        string `json:"addr"`
+		PrivateAddr string `json:"private_addr"`
+	}
+	checkpoint := struct {
+		Tag           string    `json:"tag"`
+		CloudProvider string    `json:"cloud_provider"`
+		StateDir      string    `json:"state_dir"`
+		Nodes         []node    `json:"nodes"`
+		Created       time.Time `json:"created"`
+	}{
+		Tag:           tag,
+		CloudProvider: c.provisionerConfig().CloudProvider,
+		StateDir:      c.provisionerConfig().StateDir,
+		Created:       time.Now().UTC(),
+	}
+	for _, n := range nodes {
+		checkpoint.Nodes = append(checkpoint.Nodes, node{Addr: n.Node().Addr(), PrivateAddr: n.Node().PrivateAddr()})
+	}
+	data, err := json.MarshalIndent(checkpoint, "", "  ")
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	path = filepath.Join(c.provisionerConfig().StateDir, checkpointFile)
+	err = system.WriteFileAtomic(path, data, constants.SharedReadMask)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	return path, nil
+}
+
+// nodeHosts returns the public and private addresses of the specified nodes
+func nodeHosts(nodes []Gravity) (hosts []string) {
+	for _, node := range nodes {
+		hosts = append(hosts, node.Node().Addr(), node.Node().PrivateAddr())
+	}
+	return hosts
+}
+
+const (
+	// interruptGracePeriod is the time to wait for the SSH commands in flight
+	// to stop once a test has been interrupted
+	interruptGracePeriod = 30 * time.Second
+	// checkpointFile names the file in the test state directory that records
+	// the infrastructure left behind by an interrupted test
+	checkpointFile = "checkpoint.json"
+)
+
 var resourceAllocations = struct {
 	sync.Mutex
 	tags map[string]bool
@@ -141,20 +266,13 @@ func saveResourceAllocations() error {
 		return nil
 	}
 
-	file, err := os.OpenFile(policy.ResourceListFile, os.O_RDWR|os.O_CREATE, constants.SharedReadMask)
-	if err != nil {
-		return trace.ConvertSystemError(err)
-	}
-	defer file.Close()
-
+	// write the list atomically to never leave a partially written file behind
+	var buf bytes.Buffer
 	for res := range resourceAllocations.tags {
-		_, err = fmt.Fprintln(file, res)
-		if err != nil {
-			return trace.ConvertSystemError(err)
-		}
+		fmt.Fprintln(&buf, res)
 	}
 
-	return nil
+	return trace.Wrap(system.WriteFileAtomic(policy.ResourceListFile, buf.Bytes(), constants.SharedReadMask))
 }
 
 // makeDynamicParams takes base config, validates it and returns cloudDynamicParams
@@ -202,6 +320,20 @@ func makeDynamicParams(baseConfig ProvisionerConfig) (*cloudDynamicParams, error
 		ScriptPath:    baseConfig.ScriptPath,
 		NumNodes:      int(baseConfig.NodeCount),
 		OS:            baseConfig.os.String(),
+		Vars:          baseConfig.TerraformVars,
+		Tags:          resourceTags(baseConfig, time.Now()),
+		ExtraDisks:    baseConfig.ExtraDisks,
+		Subnets:       baseConfig.Subnets,
+		IPv6:          baseConfig.IPv6,
+		Arch:          baseConfig.Arch,
+
+		BootstrapScriptPath: baseConfig.BootstrapScript,
+	}
+
+	if baseConfig.TerraformBackend != nil {
+		backend := *baseConfig.TerraformBackend
+		param.terraform.Backend = &backend
+		param.terraform.RunID = baseConfig.tag
 	}
 
 	if baseConfig.AWS != nil {
@@ -211,11 +343,15 @@ func makeDynamicParams(baseConfig ProvisionerConfig) (*cloudDynamicParams, error
 		param.terraform.AWS = &config
 		param.terraform.AWS.ClusterName = baseConfig.tag
 		param.terraform.AWS.SSHUser = param.user
+		param.terraform.AWS.InstanceType = baseConfig.awsInstanceType()
 		param.env = map[string]string{
 			"AWS_ACCESS_KEY_ID":     param.terraform.AWS.AccessKey,
 			"AWS_SECRET_ACCESS_KEY": param.terraform.AWS.SecretKey,
 			"AWS_DEFAULT_REGION":    param.terraform.AWS.Region,
 		}
+		if param.terraform.AWS.SessionToken != "" {
+			param.env["AWS_SESSION_TOKEN"] = param.terraform.AWS.SessionToken
+		}
 	}
 
 	switch {
@@ -237,7 +373,22 @@ func makeDynamicParams(baseConfig ProvisionerConfig) (*cloudDynamicParams, error
 	return &param, nil
 }
 
-func runTerraform(ctx context.Context, baseConfig ProvisionerConfig, logger logrus.FieldLogger) (resp *terraformResp, err error) {
+// region returns the cloud region to provision the nodes in
+func (r cloudDynamicParams) region() string {
+	switch {
+	case r.CloudProvider == constants.AWS && r.terraform.AWS != nil:
+		return r.terraform.AWS.Region
+	case r.CloudProvider == constants.GCE && r.terraform.GCE != nil:
+		return r.terraform.GCE.Region
+	}
+	return ""
+}
+
+// runTerraform provisions the infrastructure with terraform, retrying with a new tag on failure.
+// teardown is the time allotted for destroying the partially provisioned infrastructure
+// of an interrupted attempt
+func runTerraform(ctx context.Context, baseConfig ProvisionerConfig, teardown time.Duration, logger logrus.FieldLogger) (resp *terraformResp, err error) {
+	logger = xlog.Module(logger, xlog.ModuleInfra)
 	retryer := wait.Retryer{
 		Delay:       defaults.TerraformRetryDelay,
 		Attempts:    defaults.TerraformRetries,
@@ -261,7 +412,12 @@ func runTerraform(ctx context.Context, baseConfig ProvisionerConfig, logger logr
 			return wait.Abort(trace.Wrap(err))
 		}
 
-		resp, err = runTerraformOnce(ctx, cfg, *params, logger)
+		params.terraform.Image, err = resolveImage(ctx, cfg, params.region(), logger)
+		if err != nil {
+			return wait.Abort(trace.Wrap(err))
+		}
+
+		resp, err = runTerraformOnce(ctx, cfg, *params, teardown, logger)
 		if err == nil {
 			return nil
 		}
@@ -277,6 +433,7 @@ func runTerraformOnce(
 	baseContext context.Context,
 	baseConfig ProvisionerConfig,
 	params cloudDynamicParams,
+	teardown time.Duration,
 	logger logrus.FieldLogger,
 ) (resp *terraformResp, err error) {
 	// there's an internal retry in provisioners,
@@ -296,7 +453,7 @@ func runTerraformOnce(
 
 		_, err = p.Create(ctx, false)
 		if ctx.Err() != nil {
-			teardownCtx, cancel := context.WithTimeout(context.Background(), finalTeardownTimeout)
+			teardownCtx, cancel := context.WithTimeout(context.Background(), teardown)
 			defer cancel()
 			err1 := trace.Errorf("[terraform interrupted on apply due to upper context=%v, result=%v]", ctx.Err(), err)
 			err2 := trace.Wrap(p.Destroy(teardownCtx))
@@ -314,6 +471,7 @@ func runTerraformOnce(
 		return &terraformResp{
 			nodes:     p.NodePool().Nodes(),
 			destroyFn: p.Destroy,
+			addNodes:  p.AddNodes,
 			params:    params,
 		}, nil
 	}
@@ -325,5 +483,8 @@ func runTerraformOnce(
 type terraformResp struct {
 	nodes     []infra.Node
 	destroyFn func(context.Context) error
-	params    cloudDynamicParams
+	// addNodes provisions the specified number of additional nodes
+	// into the infrastructure and returns them
+	addNodes func(ctx context.Context, count int) ([]infra.Node, error)
+	params   cloudDynamicParams
 }
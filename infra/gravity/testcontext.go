@@ -3,8 +3,11 @@ package gravity
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
+	"github.com/gravitational/robotest/lib/failure"
+	"github.com/gravitational/robotest/lib/grafana"
 	"github.com/gravitational/robotest/lib/xlog"
 
 	"cloud.google.com/go/bigquery"
@@ -21,22 +24,55 @@ const (
 // whether test must be failed
 // provisioner has its own timeout / restart logic which is dependant on cloud provider and terraform
 type OpTimeouts struct {
-	Install          time.Duration
-	Upgrade          time.Duration
-	Status           time.Duration
-	Uninstall        time.Duration
-	UninstallApp     time.Duration
-	Leave            time.Duration
-	CollectLogs      time.Duration
-	WaitForInstaller time.Duration
-	AutoScaling      time.Duration
+	Install          time.Duration `yaml:"install"`
+	Join             time.Duration `yaml:"join"`
+	Upgrade          time.Duration `yaml:"upgrade"`
+	Status           time.Duration `yaml:"status"`
+	LeaderElection   time.Duration `yaml:"leader_election"`
+	Uninstall        time.Duration `yaml:"uninstall"`
+	UninstallApp     time.Duration `yaml:"uninstall_app"`
+	Leave            time.Duration `yaml:"leave"`
+	CollectLogs      time.Duration `yaml:"collect_logs"`
+	WaitForInstaller time.Duration `yaml:"wait_for_installer"`
+	AutoScaling      time.Duration `yaml:"autoscaling"`
+	Backup           time.Duration `yaml:"backup"`
+	Teardown         time.Duration `yaml:"teardown"`
+	Power            time.Duration `yaml:"power"`
+}
+
+// Merge returns these timeouts with the non-zero timeouts of other taking precedence
+func (r OpTimeouts) Merge(other OpTimeouts) OpTimeouts {
+	merge := func(timeout *time.Duration, override time.Duration) {
+		if override != 0 {
+			*timeout = override
+		}
+	}
+	merge(&r.Install, other.Install)
+	merge(&r.Join, other.Join)
+	merge(&r.Upgrade, other.Upgrade)
+	merge(&r.Status, other.Status)
+	merge(&r.LeaderElection, other.LeaderElection)
+	merge(&r.Uninstall, other.Uninstall)
+	merge(&r.UninstallApp, other.UninstallApp)
+	merge(&r.Leave, other.Leave)
+	merge(&r.CollectLogs, other.CollectLogs)
+	merge(&r.WaitForInstaller, other.WaitForInstaller)
+	merge(&r.AutoScaling, other.AutoScaling)
+	merge(&r.Backup, other.Backup)
+	merge(&r.Teardown, other.Teardown)
+	merge(&r.Power, other.Power)
+	return r
 }
 
 // TestContext aggregates common parameters for better test suite readability
 type TestContext struct {
-	err            error
-	timestamp      time.Time
-	name           string
+	err       error
+	timestamp time.Time
+	name      string
+	// test names the scheduled test and attempt is the attempt number
+	// this context runs
+	test           string
+	attempt        int
 	ctx            context.Context
 	cancel         context.CancelFunc
 	timeouts       OpTimeouts
@@ -48,6 +84,7 @@ type TestContext struct {
 	status         string
 	provisionerCfg ProvisionerConfig
 	fields         logrus.Fields
+	allocations    []*allocation
 
 	// Context and cancel function for the SSH channel monitor process.
 	// Monitor process is usually a long-running process that is active
@@ -60,6 +97,60 @@ type TestContext struct {
 	// preempted indicates that a node belonging to this test context
 	// was preempted
 	preempted bool
+	// quarantine is the quarantine of the test, if the test is quarantined
+	quarantine *Quarantine
+
+	nodesMu sync.Mutex
+	// nodes lists the nodes provisioned by this test
+	nodes []Gravity
+	// addNode provisions an additional node into the infrastructure
+	// provisioned by this test, if supported
+	addNode func(ctx context.Context) (*gravity, error)
+	// leader caches the cluster leader last discovered with Leader
+	leader Gravity
+	// chaos is the chaos scheduler started by this test, if any
+	chaos *Chaos
+	// stateDiffs lists the cluster state differences recorded with WithStateDiff
+	stateDiffs []StateDiff
+	// statesBefore maps the steps in progress to the cluster state captured
+	// before them, see AddStateDiffHooks
+	statesBefore map[string]ClusterState
+	// phase names the phase of the test, see SetPhase
+	phase string
+	// phaseStart is the time the current phase started
+	phaseStart time.Time
+	// phases lists the durations of the completed phases
+	phases []PhaseDuration
+	// timedOutPhase names the phase the test was in when the test suite budget was exceeded
+	timedOutPhase string
+}
+
+// phaseTeardown is the phase of the infrastructure teardown, see wrapDestroyFunc
+const phaseTeardown = "teardown"
+
+// PhaseDuration is the time a test spent in a phase, see SetPhase
+type PhaseDuration struct {
+	// Phase names the phase
+	Phase string `json:"phase"`
+	// Duration is the time spent in the phase
+	Duration time.Duration `json:"duration"`
+}
+
+// NewTestContext returns a test context that is not attached to a test suite.
+// It is used to drive the cluster orchestration logic against nodes
+// that have not been provisioned by this package (e.g. fake nodes in unit tests)
+func NewTestContext(ctx context.Context, timeouts OpTimeouts, log logrus.FieldLogger) *TestContext {
+	ctx, cancel := context.WithCancel(ctx)
+	return &TestContext{
+		timestamp:     time.Now(),
+		name:          "standalone",
+		ctx:           ctx,
+		cancel:        cancel,
+		timeouts:      timeouts,
+		log:           log,
+		monitorCtx:    ctx,
+		monitorCancel: cancel,
+	}
 }
 
 // Run allows a running test to spawn a subtest
@@ -97,6 +188,86 @@ func (c *TestContext) Error() error {
 	return c.err
 }
 
+// SetPhase sets the phase of the test, i.e. install or upgrade,
+// attached as the phase field to all entries logged by the test
+// and annotated in Grafana when the phase changes, see SetAnnotations.
+// The time spent in each phase is recorded in the test status.
+// An empty phase ends the current phase
+func (c *TestContext) SetPhase(phase string) {
+	c.checkBudget(phase)
+	c.setPhase(phase)
+}
+
+// enterPhase sets the phase of an operation that can be nested in another one,
+// i.e. the expand of ReplaceNode, and returns the function that restores the phase
+// of the outer operation. Resuming the outer phase is not subject to the budget
+func (c *TestContext) enterPhase(phase string) (exit func()) {
+	c.nodesMu.Lock()
+	outer := c.phase
+	c.nodesMu.Unlock()
+	c.SetPhase(phase)
+	return func() {
+		c.setPhase(outer)
+	}
+}
+
+func (c *TestContext) setPhase(phase string) {
+	c.nodesMu.Lock()
+	changed := c.phase != phase
+	if changed && c.phase != "" {
+		c.phases = append(c.phases, PhaseDuration{Phase: c.phase, Duration: time.Since(c.phaseStart)})
+	}
+	if changed {
+		c.phaseStart = time.Now()
+	}
+	c.phase = phase
+	c.nodesMu.Unlock()
+	if changed && phase != "" {
+		c.annotate(grafana.Annotation{
+			Time: time.Now(),
+			Tags: []string{"phase", phase},
+			Text: fmt.Sprintf("%v: %v", c.name, phase),
+		})
+	}
+}
+
+// provisionerConfig returns the configuration the test has been provisioned with.
+// The configuration is set by Provision and read by the log hook of the test
+// concurrently, so it is only accessed under the lock
+func (c *TestContext) provisionerConfig() ProvisionerConfig {
+	c.nodesMu.Lock()
+	defer c.nodesMu.Unlock()
+	return c.provisionerCfg
+}
+
+// stateDir returns the state directory of the provisioned test
+func (c *TestContext) stateDir() string {
+	return c.provisionerConfig().StateDir
+}
+
+// recordedPhases returns the durations of the completed phases in order
+func (c *TestContext) recordedPhases() []PhaseDuration {
+	c.nodesMu.Lock()
+	defer c.nodesMu.Unlock()
+	return append([]PhaseDuration(nil), c.phases...)
+}
+
+// logFields returns the fields that change over the lifetime of the test
+// to attach to every entry logged by the test
+func (c *TestContext) logFields() logrus.Fields {
+	c.nodesMu.Lock()
+	defer c.nodesMu.Unlock()
+	fields := logrus.Fields{}
+	if c.phase != "" {
+		fields["phase"] = c.phase
+	}
+	if c.provisionerCfg.CloudProvider != "" {
+		fields["cloud"] = c.provisionerCfg.CloudProvider
+		fields["os"] = c.provisionerCfg.os.String()
+	}
+	return fields
+}
+
 // WithFields assigns additional logging fields to this context
 func (c *TestContext) WithFields(fields logrus.Fields) *TestContext {
 	c.fields = fields
@@ -118,6 +289,7 @@ func (c *TestContext) OK(msg string, err error) {
 		fields[name] = value
 	}
 
+	c.progress().updateStep(c.name, msg, err)
 	if err == nil {
 		c.log.WithFields(fields).Info(msg)
 		return
@@ -144,6 +316,7 @@ func (c *TestContext) Maybe(msg string, err error) {
 		fields[name] = value
 	}
 
+	c.progress().updateStep(c.name, msg, err)
 	if err == nil {
 		c.log.WithFields(fields).Info(msg)
 		return
@@ -166,6 +339,7 @@ func (c *TestContext) Require(msg string, condition bool, args ...interface{}) {
 		return
 	}
 	c.log.WithField("args", args).Errorf("failed check: %s", msg)
+	c.err = failure.Assertion(trace.BadParameter("failed check: %s", msg))
 	panic(msg)
 }
 
@@ -187,6 +361,14 @@ type progressMessage struct {
 	suite, uuid string
 	name        string
 	param       interface{}
+	// estimatedCost is the estimated cloud cost of the test.
+	// Only reported with the final test status
+	estimatedCost *float64
+	// category names the origin of the test failure.
+	// Only reported with the final test status
+	category failure.Category
+	// quarantined is true if the test is quarantined
+	quarantined bool
 }
 
 func (msg progressMessage) Save() (row map[string]bigquery.Value, insertID string, err error) {
@@ -199,6 +381,13 @@ func (msg progressMessage) Save() (row map[string]bigquery.Value, insertID strin
 
 	row["name"] = msg.name
 	row["status"] = msg.status
+	if msg.estimatedCost != nil {
+		row["estimated_cost"] = *msg.estimatedCost
+	}
+	if msg.category != "" {
+		row["failure_category"] = string(msg.category)
+	}
+	row["quarantined"] = msg.quarantined
 
 	bqParam, ok := msg.param.(bigquery.ValueSaver)
 	if !ok {
@@ -216,16 +405,23 @@ func (msg progressMessage) Save() (row map[string]bigquery.Value, insertID strin
 
 func (c *TestContext) updateStatus(status string) {
 	c.status = status
+	c.progress().updateStatus(c.name, status)
 
 	log := c.Logger().WithFields(logrus.Fields{"param": xlog.ToJSON(c.param), "name": c.name})
 	switch c.status {
 	case TestStatusScheduled, TestStatusRunning:
 		log.Info(c.status)
 		return
-	case TestStatusPassed:
+	}
+
+	estimate, usage := c.EstimatedCost()
+	total := estimate.Total()
+	log = log.WithFields(logrus.Fields{"estimated_cost": fmt.Sprintf("$%.2f", total), "usage": usage})
+	category := failure.CategoryOf(c.err)
+	if c.status == TestStatusPassed {
 		log.Info(c.status)
-	default:
-		log.Error(c.status)
+	} else {
+		log.WithField("failure_category", category).Error(c.status)
 	}
 
 	progress := c.suite.progress
@@ -234,11 +430,14 @@ func (c *TestContext) updateStatus(status string) {
 	}
 
 	msg := progressMessage{
-		status: status,
-		uuid:   c.uid,
-		suite:  c.suite.uid,
-		name:   c.name,
-		param:  c.param,
+		status:        status,
+		uuid:          c.uid,
+		suite:         c.suite.uid,
+		name:          c.name,
+		param:         c.param,
+		estimatedCost: &total,
+		category:      category,
+		quarantined:   c.quarantine != nil,
 	}
 	data, _, err := msg.Save()
 	if err != nil {
@@ -254,6 +453,22 @@ func (c *TestContext) updateStatus(status string) {
 	}
 }
 
+// provisionedNodes returns the nodes provisioned by this test
+func (c *TestContext) provisionedNodes() []Gravity {
+	c.nodesMu.Lock()
+	defer c.nodesMu.Unlock()
+	return c.nodes
+}
+
+// progress returns the progress tracker of the test suite.
+// Returns nil for test contexts not attached to a test suite
+func (c *TestContext) progress() *Progress {
+	if c.suite == nil {
+		return nil
+	}
+	return c.suite.tracker
+}
+
 func (c *TestContext) markPreempted(node Gravity) {
 	// Consider the abort to be an indication of node preemption and
 	// cancel the test
@@ -0,0 +1,64 @@
+package gravity
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgressMessageRow(t *testing.T) {
+	msg := progressMessage{
+		status:      TestStatusFailed,
+		suite:       "suite-uid",
+		uuid:        "test-uid",
+		name:        "install-1",
+		param:       testParam{"os": "ubuntu:18"},
+		category:    "assertion",
+		quarantined: true,
+	}
+	row, _, err := msg.Save()
+	require.NoError(t, err)
+	assert.Equal(t, true, row["quarantined"])
+	assert.Equal(t, "assertion", row["failure_category"])
+	assert.Equal(t, "ubuntu:18", row["os"])
+	assert.NotContains(t, row, "estimated_cost")
+
+	msg.quarantined = false
+	row, _, err = msg.Save()
+	require.NoError(t, err)
+	assert.Equal(t, false, row["quarantined"])
+}
+
+// testParam is a test parameter saved as its own columns
+type testParam map[string]bigquery.Value
+
+func (r testParam) Save() (map[string]bigquery.Value, string, error) {
+	return r, "", nil
+}
+
+func TestMergesTimeouts(t *testing.T) {
+	base := OpTimeouts{Install: time.Hour, Status: time.Minute, Teardown: 20 * time.Minute}
+	merged := base.Merge(OpTimeouts{Status: 5 * time.Minute, Power: 10 * time.Minute})
+	assert.Equal(t, OpTimeouts{
+		Install:  time.Hour,
+		Status:   5 * time.Minute,
+		Teardown: 20 * time.Minute,
+		Power:    10 * time.Minute,
+	}, merged)
+	assert.Equal(t, time.Minute, base.Status, "receiver is not modified")
+	assert.Equal(t, base, base.Merge(OpTimeouts{}), "zero timeouts keep the defaults")
+}
+
+func TestMergesAllTimeouts(t *testing.T) {
+	// every field of other takes precedence, so that a new timeout is not forgotten in Merge
+	var other OpTimeouts
+	v := reflect.ValueOf(&other).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		v.Field(i).Set(reflect.ValueOf(time.Duration(i+1) * time.Second))
+	}
+	assert.Equal(t, other, OpTimeouts{}.Merge(other))
+}
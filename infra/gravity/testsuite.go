@@ -3,13 +3,16 @@ package gravity
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"net/url"
 	"runtime/debug"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/gravitational/robotest/infra/cost"
 	"github.com/gravitational/robotest/lib/defaults"
+	"github.com/gravitational/robotest/lib/failure"
 	"github.com/gravitational/robotest/lib/wait"
 	"github.com/gravitational/robotest/lib/xlog"
 
@@ -30,6 +33,16 @@ type TestSuite interface {
 	Run() []TestStatus
 	// Logger provides preconfigured logger
 	Logger() logrus.FieldLogger
+	// Progress returns the progress tracker of the running tests
+	Progress() *Progress
+	// DebugHandler returns the HTTP handler exposing the state of the running tests
+	DebugHandler() http.Handler
+	// ShipLogs ships the entries of logger to the cloud logging attributed to the run of job
+	ShipLogs(logger *logrus.Logger, job string) error
+	// AddLogHook adds the hook to the loggers of the suite and of the tests scheduled with Run
+	AddLogHook(hook logrus.Hook)
+	// SetBudget limits the wall-clock time of Run
+	SetBudget(budget time.Duration)
 	// Close disposes background resources
 	Close()
 }
@@ -56,6 +69,37 @@ type TestStatus struct {
 	Status        string
 	LogUrl        string
 	Param         interface{}
+	// EstimatedCost is the estimated cloud cost of the infrastructure provisioned by the test
+	EstimatedCost cost.Estimate
+	// Usage lists the infrastructure provisioned by the test
+	Usage []cost.Usage
+	// Test names the scheduled test this is an attempt of
+	Test string
+	// Attempt is the number of this attempt, starting with 1
+	Attempt int
+	// Error describes the failure, if any
+	Error string
+	// Category names the origin of the failure, if any
+	Category failure.Category
+	// Preempted indicates that a node of the test was preempted
+	Preempted bool
+	// Chaos records the faults injected by the chaos scheduler, if started
+	Chaos *ChaosReport
+	// StateDiffs lists the differences of the cluster state around the operations of the test
+	StateDiffs []StateDiff
+	// Phases lists the time spent in each phase of the test in order
+	Phases []PhaseDuration
+	// StateDir is the directory with the artifacts of the test (logs, reports, etc.), once provisioned
+	StateDir string
+	// Signature identifies the failure across runs, if any, see failure.Signature
+	Signature string
+	// KnownIssue is the known failure the failure matches, if any, see MatchKnownIssues
+	KnownIssue *failure.KnownSignature
+	// Quarantine is the quarantine of the test, if the test is quarantined
+	Quarantine *Quarantine
+	// TimedOutPhase names the phase the test was interrupted at when the test suite
+	// exceeded its budget, see TestSuite.SetBudget
+	TimedOutPhase string
 }
 
 // testSuite logically groups multiple test runs for centralized progress and status reporting
@@ -65,6 +109,7 @@ type testSuite struct {
 	googleProjectID string
 	client          *xlog.GCLClient
 	progress        *xlog.ProgressReporter
+	tracker         *Progress
 	uid             string
 
 	tests     []*TestContext
@@ -72,11 +117,17 @@ type testSuite struct {
 	t         *testing.T
 
 	failFast, isFailingFast bool
+	// budget optionally limits the wall-clock time of Run, see SetBudget
+	budget       time.Duration
+	isOverBudget bool
 
 	ctx    context.Context
 	cancel context.CancelFunc
 
-	logger logrus.FieldLogger
+	logger *logrus.Logger
+	fields logrus.Fields
+	// logHooks lists the hooks added to the loggers of the tests
+	logHooks []logrus.Hook
 }
 
 // NewRun creates new group run environment
@@ -92,7 +143,9 @@ func NewSuite(ctx context.Context, t *testing.T, googleProjectID string, fields
 		logger.WithError(err).Error("cloud logging not available")
 	}
 
-	progress, err := xlog.NewProgressReporter(ctx, googleProjectID, defaults.BQDataset, defaults.BQTable)
+	// the progress rows have columns (i.e. estimated_cost) missing from older table schemas
+	progress, err := xlog.NewProgressReporter(ctx, googleProjectID, defaults.BQDataset, defaults.BQTable,
+		xlog.IgnoreUnknownValues())
 	if err != nil {
 		logger.WithError(err).Error("cloud progress reporting not available")
 	}
@@ -104,6 +157,7 @@ func NewSuite(ctx context.Context, t *testing.T, googleProjectID string, fields
 		googleProjectID: googleProjectID,
 		client:          client,
 		progress:        progress,
+		tracker:         newProgress(),
 		uid:             uid,
 		scheduled:       scheduled,
 		t:               t,
@@ -111,6 +165,7 @@ func NewSuite(ctx context.Context, t *testing.T, googleProjectID string, fields
 		ctx:             ctx,
 		cancel:          cancel,
 		logger:          logger,
+		fields:          fields,
 	}
 }
 
@@ -118,9 +173,17 @@ func (s *testSuite) Logger() logrus.FieldLogger {
 	return s.logger
 }
 
+// Progress returns the progress tracker of the running tests
+func (s *testSuite) Progress() *Progress {
+	return s.tracker
+}
+
 // Cancel will request everything to teardown
 func (s *testSuite) Cancel(reason string, args ...interface{}) {
-	if s.failingFast() {
+	s.RLock()
+	cancelled := s.isFailingFast
+	s.RUnlock()
+	if cancelled {
 		return
 	}
 	s.Lock()
@@ -135,7 +198,25 @@ func (s *testSuite) failingFast() bool {
 	s.RLock()
 	defer s.RUnlock()
 
-	return s.isFailingFast
+	return s.isFailingFast || s.isOverBudget
+}
+
+// ShipLogs ships the entries of logger to the cloud logging, see xlog.GCLClient.RunHook.
+// The run is identified by the suite UID
+func (s *testSuite) ShipLogs(logger *logrus.Logger, job string) error {
+	if s.client == nil {
+		return trace.BadParameter("cloud logging not available")
+	}
+	logger.Hooks.Add(s.client.RunHook("orchestration", job, s.uid, s.fields))
+	return nil
+}
+
+// AddLogHook adds the hook to the loggers of the suite and of the tests
+func (s *testSuite) AddLogHook(hook logrus.Hook) {
+	s.Lock()
+	defer s.Unlock()
+	s.logger.Hooks.Add(hook)
+	s.logHooks = append(s.logHooks, hook)
 }
 
 func (s *testSuite) Close() {
@@ -178,8 +259,13 @@ func (s *testSuite) wrap(fn TestFunc, baseConfig ProvisionerConfig, param interf
 		t.Helper()
 		t.Parallel()
 
-		b := newPreemptiveBackoff(defaults.MaxRetriesPerTest, defaults.MaxPreemptedRetriesPerTest)
+		b := newPreemptiveBackoff(policy.maxAttempts(), defaults.MaxPreemptedRetriesPerTest)
 		try := 0
+		// known is the known failure of the last failed attempt, unknown is set
+		// once an attempt failed otherwise. The test is only a known issue
+		// if all failed attempts failed with an acknowledged known failure
+		var known *failure.KnownSignature
+		var unknown bool
 		err := wait.RetryWithInterval(s.ctx, b, func() error {
 			t.Helper()
 
@@ -192,6 +278,19 @@ func (s *testSuite) wrap(fn TestFunc, baseConfig ProvisionerConfig, param interf
 			}
 
 			testCtx, err := s.runTestFunc(t, fn, cfg, param)
+			testCtx.test = baseConfig.Tag()
+			testCtx.attempt = try
+			if err != nil {
+				if issue := testCtx.knownIssue(); issue != nil && issue.Acknowledged() {
+					known = issue
+				} else {
+					unknown = true
+				}
+			}
+			if phase := testCtx.timedOut(); phase != "" {
+				// interrupted due to the test suite budget, there's no time to retry it
+				return &backoff.PermanentError{Err: trace.LimitExceeded("test %q timed out at phase %q", cfg.Tag(), phase)}
+			}
 			if err == nil {
 				return nil
 			}
@@ -209,9 +308,9 @@ func (s *testSuite) wrap(fn TestFunc, baseConfig ProvisionerConfig, param interf
 				return nil
 			}
 
-			if trace.IsBadParameter(err) {
+			if trace.IsBadParameter(err) || failure.CategoryOf(err) == failure.CategoryAssertion {
 				// this usually means either a panic inside test,
-				// or bad configuration parameters passed to it
+				// a failed check or bad configuration parameters passed to it
 				// there's no reason to retry it
 				return &backoff.PermanentError{Err: trace.Wrap(err)}
 			}
@@ -224,6 +323,22 @@ func (s *testSuite) wrap(fn TestFunc, baseConfig ProvisionerConfig, param interf
 			return
 		}
 
+		if known != nil && !unknown {
+			s.Logger().WithError(err).WithFields(logrus.Fields{
+				"ticket": known.Ticket,
+				"issue":  known.Issue,
+			}).Warnf("Test %q failed with known issue %q.", baseConfig.Tag(), known.Description)
+			t.Logf("Known issue %v %v: %v", known.Ticket, known.Description, err)
+			return
+		}
+
+		if quarantine := baseConfig.Quarantined(); quarantine != nil {
+			s.Logger().WithError(err).WithField("ticket", quarantine.Ticket).Warnf(
+				"Quarantined test %q failed: %v.", baseConfig.Tag(), quarantine.Reason)
+			t.Logf("Quarantined %v %v: %v", quarantine.Ticket, quarantine.Reason, err)
+			return
+		}
+
 		if s.failFast {
 			s.Cancel("Test %s failed, FailFast=true, cancelling other.", t.Name())
 		}
@@ -253,23 +368,39 @@ func (s *testSuite) runTestFunc(t *testing.T, testFunc TestFunc, cfg Provisioner
 	monitorCtx, monitorCancel := context.WithCancel(ctx)
 	defer monitorCancel()
 
+	logger := xlog.NewLogger(s.client, t, labels)
 	testCtx = &TestContext{
 		name:     cfg.Tag(),
 		ctx:      ctx,
 		cancel:   cancel,
-		timeouts: DefaultTimeouts,
+		timeouts: DefaultTimeouts.Merge(cfg.Timeouts),
 		uid:      uid,
 		suite:    s,
 		param:    param,
 		logLink:  logLink,
-		log: xlog.NewLogger(s.client, t, labels).WithFields(logrus.Fields{
-			"name": cfg.Tag(),
+		// the quarantine is reported with the progress of the test from the start
+		quarantine: cfg.Quarantined(),
+		log: logger.WithFields(logrus.Fields{
+			"name":           cfg.Tag(),
+			"run_id":         s.uid,
+			"suite":          cfg.suite,
+			xlog.ModuleField: xlog.ModuleGravity,
 		}),
 		monitorCtx:    monitorCtx,
 		monitorCancel: monitorCancel,
 	}
 
+	// the phase, OS and cloud are attached to the entries of all loggers derived from the test logger
+	xlog.PrependHook(logger, xlog.FieldsHook(testCtx.logFields))
+	s.RLock()
+	for _, hook := range s.logHooks {
+		logger.Hooks.Add(hook)
+	}
+	s.RUnlock()
+
 	defer func() {
+		// end the last phase once the test, including its teardown, has completed
+		testCtx.SetPhase("")
 		r := recover()
 		if r == nil {
 			testCtx.updateStatus(TestStatusPassed)
@@ -317,23 +448,41 @@ func (s *testSuite) runTestFunc(t *testing.T, testFunc TestFunc, cfg Provisioner
 
 // Run executes all tests in this suite and returns test results
 func (s *testSuite) Run() []TestStatus {
+	stopBudget := s.startBudget()
 	s.t.Run("run", func(t *testing.T) {
 		for tag, fn := range s.scheduled {
 			t.Run(tag, fn)
 		}
 	})
+	stopBudget()
 
 	status := []TestStatus{}
 	for _, test := range s.tests {
+		estimate, usage := test.EstimatedCost()
 		status = append(status, TestStatus{
-			Name:     test.name,
-			Status:   test.status,
-			Param:    test.param,
-			UID:      test.uid,
-			SuiteUID: test.suite.uid,
-			LogUrl:   test.logLink,
+			Name:          test.name,
+			Status:        test.status,
+			Param:         test.param,
+			UID:           test.uid,
+			SuiteUID:      test.suite.uid,
+			LogUrl:        test.logLink,
+			EstimatedCost: estimate,
+			Usage:         usage,
+			Test:          test.test,
+			Attempt:       test.attempt,
+			Error:         errorMessage(test.err),
+			Category:      failure.CategoryOf(test.err),
+			Signature:     failure.Signature(failure.CategoryOf(test.err), errorMessage(test.err), test.name, test.test),
+			Preempted:     test.preempted,
+			Chaos:         test.chaosReport(),
+			StateDiffs:    test.recordedStateDiffs(),
+			Phases:        test.recordedPhases(),
+			StateDir:      test.stateDir(),
+			Quarantine:    test.quarantine,
+			TimedOutPhase: test.timedOut(),
 		})
 	}
+	MatchKnownIssues(status, knownIssues)
 	return status
 }
 
@@ -0,0 +1,460 @@
+package gravity
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gravitational/robotest/lib/shell"
+	sshutils "github.com/gravitational/robotest/lib/ssh"
+	"github.com/gravitational/robotest/lib/utils"
+	"github.com/gravitational/robotest/lib/wait"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// TimeJump moves the clock on all nodes forward by offset across a reboot,
+// the way a cluster comes back after it has been powered off for a long time:
+// time synchronization is disabled, the system and hardware clocks are set forward
+// and the nodes are rebooted at once.
+// Once the nodes are back, the cluster status, the validity of the cluster
+// certificates at the new time, the rotation of the certificates that have expired
+// by then, the renewal of the Kubernetes leases and service account tokens,
+// the etcd health and the leader election are verified
+func (c *TestContext) TimeJump(nodes []Gravity, offset time.Duration) error {
+	if len(nodes) == 0 {
+		return trace.BadParameter("at least one node is required")
+	}
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Power)
+	defer cancel()
+
+	before, err := c.nodeCertificates(ctx, nodes)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	// the leases and tokens are expected to be renewed past the time the clocks have been moved to
+	since := before[0].time.Add(offset)
+	for _, certs := range before {
+		if t := certs.time.Add(offset); t.Before(since) {
+			since = t
+		}
+	}
+
+	c.Logger().WithFields(logrus.Fields{"nodes": Nodes(nodes), "offset": offset}).Info("Jump clocks forward across reboot.")
+	errs := make(chan error, len(nodes))
+	for _, node := range nodes {
+		go func(node Gravity) {
+			g, ok := node.(*gravity)
+			if !ok {
+				errs <- trace.BadParameter("unsupported node %v", node)
+				return
+			}
+			errs <- trace.Wrap(jumpClock(ctx, g, offset), "failed to jump clock on %v", node)
+		}(node)
+	}
+	if err := utils.CollectErrors(ctx, errs); err != nil {
+		return trace.Wrap(err)
+	}
+
+	if err := c.Status(nodes); err != nil {
+		return trace.Wrap(err)
+	}
+	if err := c.AssertCertificatesValid(nodes); err != nil {
+		return trace.Wrap(err)
+	}
+	if err := c.assertCertificatesRotated(nodes, before); err != nil {
+		return trace.Wrap(err)
+	}
+	if err := c.assertLeasesRenewed(nodes[0], since); err != nil {
+		return trace.Wrap(err)
+	}
+	if err := c.assertTokensRenewed(nodes, since); err != nil {
+		return trace.Wrap(err)
+	}
+	if err := c.assertEtcdHealthy(nodes); err != nil {
+		return trace.Wrap(err)
+	}
+	_, err = c.Leader(c.ctx, nodes)
+	return trace.Wrap(err)
+}
+
+// AssertCertificatesValid verifies that the cluster certificates on all nodes
+// are valid at the current time of the node
+func (c *TestContext) AssertCertificatesValid(nodes []Gravity) error {
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	errs := make(chan error, len(nodes))
+	for _, node := range nodes {
+		go func(node Gravity) {
+			g, ok := node.(*gravity)
+			if !ok {
+				errs <- trace.BadParameter("unsupported node %v", node)
+				return
+			}
+			now, err := nodeTime(ctx, g)
+			if err != nil {
+				errs <- trace.Wrap(err)
+				return
+			}
+			certs, err := certificateInfo(ctx, g)
+			if err != nil {
+				errs <- trace.Wrap(err)
+				return
+			}
+			var expired []string
+			for _, name := range planetCertificates {
+				if now.After(certs[name].notAfter) {
+					expired = append(expired, fmt.Sprintf("%v (%v)", name, certs[name].notAfter.Format(time.RFC3339)))
+				}
+			}
+			if len(expired) != 0 {
+				errs <- trace.CompareFailed("certificates expired on %v at %v: %v",
+					node, now.Format(time.RFC3339), strings.Join(expired, ", "))
+				return
+			}
+			errs <- nil
+		}(node)
+	}
+	return trace.Wrap(utils.CollectErrors(ctx, errs))
+}
+
+// assertCertificatesRotated verifies that the cluster certificates that have expired
+// at the current time of the node since they were captured in before have been rotated,
+// i.e. have been reissued with a new serial number or validity period.
+// The certificates that are still valid are not required to be rotated
+func (c *TestContext) assertCertificatesRotated(nodes []Gravity, before []nodeCertificates) error {
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	after, err := c.nodeCertificates(ctx, nodes)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	var stale []string
+	for i, certs := range after {
+		for _, name := range planetCertificates {
+			prev, cert := before[i].certs[name], certs.certs[name]
+			if !certs.time.After(prev.notAfter) {
+				continue
+			}
+			if cert.serial == prev.serial && cert.notBefore.Equal(prev.notBefore) {
+				stale = append(stale, fmt.Sprintf("%v on %v (serial %v)", name, nodes[i], cert.serial))
+				continue
+			}
+			c.Logger().WithFields(logrus.Fields{
+				"node":   nodes[i],
+				"cert":   name,
+				"serial": cert.serial,
+			}).Info("Certificate rotated.")
+		}
+	}
+	if len(stale) != 0 {
+		return trace.CompareFailed("expired certificates not rotated: %v", strings.Join(stale, ", "))
+	}
+	return nil
+}
+
+// assertLeasesRenewed verifies that all Kubernetes leases, i.e. the node heartbeats and
+// the leader elections, are renewed after since
+func (c *TestContext) assertLeasesRenewed(node Gravity, since time.Time) error {
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	retry := wait.Retryer{
+		Attempts:    30,
+		Delay:       10 * time.Second,
+		FieldLogger: c.Logger().WithField("since", since.Format(time.RFC3339)),
+	}
+	return trace.Wrap(retry.Do(ctx, func() error {
+		out, err := node.RunInPlanet(ctx, "/usr/bin/kubectl", "get", "leases", "--all-namespaces",
+			`-ojsonpath={range .items[*]}{.metadata.namespace}/{.metadata.name}{"\t"}{.spec.renewTime}{"\n"}{end}`)
+		if err != nil {
+			return wait.Continue("failed to list leases: %v", err)
+		}
+		stale, err := staleLeases(out, since)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		if len(stale) != 0 {
+			return wait.Continue("leases not renewed: %v", strings.Join(stale, ", "))
+		}
+		return nil
+	}))
+}
+
+// assertTokensRenewed verifies that the projected service account tokens of the pods
+// on all nodes have been reissued after since.
+// The tokens are only verified if the kubelet projects them, i.e. with bound service account tokens
+func (c *TestContext) assertTokensRenewed(nodes []Gravity, since time.Time) error {
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	retry := wait.Retryer{
+		Attempts:    30,
+		Delay:       10 * time.Second,
+		FieldLogger: c.Logger().WithField("since", since.Format(time.RFC3339)),
+	}
+	return trace.Wrap(retry.Do(ctx, func() error {
+		var stale []string
+		for _, node := range nodes {
+			out, err := node.RunInPlanet(ctx, "/bin/sh", "-c", listProjectedTokensCommand)
+			if err != nil {
+				return wait.Continue("failed to list service account tokens on %v: %v", node, err)
+			}
+			tokens, err := staleTokens(out, since)
+			if err != nil {
+				return trace.Wrap(err, "invalid service account token on %v", node)
+			}
+			for _, token := range tokens {
+				stale = append(stale, fmt.Sprintf("%v on %v", token, node))
+			}
+		}
+		if len(stale) != 0 {
+			return wait.Continue("service account tokens not renewed: %v", strings.Join(stale, ", "))
+		}
+		return nil
+	}))
+}
+
+// staleLeases returns the names of the leases in the output of kubectl get leases
+// (one namespace/name and renew time per line) that have not been renewed after since.
+// Leases without the renew time are not held and are ignored
+func staleLeases(out string, since time.Time) (stale []string, err error) {
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		renewed, err := time.Parse(time.RFC3339Nano, fields[1])
+		if err != nil {
+			return nil, trace.BadParameter("invalid renew time %q of lease %v", fields[1], fields[0])
+		}
+		if renewed.Before(since) {
+			stale = append(stale, fmt.Sprintf("%v (%v)", fields[0], renewed.Format(time.RFC3339)))
+		}
+	}
+	return stale, nil
+}
+
+// staleTokens returns the paths of the tokens in the output of listProjectedTokensCommand
+// (one path and the encoded JWT claims per line) that have not been issued after since
+func staleTokens(out string, since time.Time) (stale []string, err error) {
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(fields[1], "="))
+		if err != nil {
+			return nil, trace.BadParameter("invalid token claims in %v", fields[0])
+		}
+		var claims struct {
+			IssuedAt int64 `json:"iat"`
+		}
+		if err := json.Unmarshal(data, &claims); err != nil {
+			return nil, trace.BadParameter("invalid token claims in %v: %v", fields[0], err)
+		}
+		if issued := time.Unix(claims.IssuedAt, 0); issued.Before(since) {
+			stale = append(stale, fmt.Sprintf("%v (%v)", fields[0], issued.UTC().Format(time.RFC3339)))
+		}
+	}
+	return stale, nil
+}
+
+// assertEtcdHealthy verifies that the etcd cluster reports healthy on all nodes.
+// Leases of the etcd members are expected to have been re-established after the clock change
+func (c *TestContext) assertEtcdHealthy(nodes []Gravity) error {
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeouts.Status)
+	defer cancel()
+
+	retry := wait.Retryer{
+		Attempts:    30,
+		Delay:       10 * time.Second,
+		FieldLogger: c.Logger(),
+	}
+	return trace.Wrap(retry.Do(ctx, func() error {
+		errs := make(chan error, len(nodes))
+		for _, node := range nodes {
+			go func(node Gravity) {
+				_, err := node.RunInPlanet(ctx, "/usr/bin/etcdctl", "cluster-health")
+				errs <- trace.Wrap(err, "etcd is not healthy on %v", node)
+			}(node)
+		}
+		if err := utils.CollectErrors(ctx, errs); err != nil {
+			return wait.Continue(err.Error())
+		}
+		return nil
+	}))
+}
+
+// jumpClock sets the clocks on the node forward by offset and reboots it.
+// If the hypervisor resets the clock of the VM on boot, the system clock
+// is set forward again once the node is back
+func jumpClock(ctx context.Context, node *gravity, offset time.Duration) error {
+	log := node.Logger().WithField("offset", offset)
+	err := node.run(ctx, log, disableTimeSyncCommand, nil)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	before, err := nodeTime(ctx, node)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	target := before.Add(offset)
+	err = node.run(ctx, log, setClockCommand(target)+" && sudo hwclock --systohc", nil)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	log.Info("Reboot.")
+	if err := node.Reboot(ctx, Graceful(true)); err != nil {
+		return trace.Wrap(err)
+	}
+	now, err := nodeTime(ctx, node)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if now.Before(target) {
+		log.WithField("time", now.Format(time.RFC3339)).Warn("Clock reset on boot, set it forward again.")
+		if err := node.run(ctx, log, setClockCommand(now.Add(offset)), nil); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// nodeTime returns the current time of the system clock on the node
+func nodeTime(ctx context.Context, node *gravity) (time.Time, error) {
+	var out string
+	err := node.runAndParse(ctx, node.Logger(), "date +%s", nil, sshutils.ParseAsString(&out))
+	if err != nil {
+		return time.Time{}, trace.Wrap(err)
+	}
+	seconds, err := strconv.ParseInt(out, 10, 64)
+	if err != nil {
+		return time.Time{}, trace.BadParameter("invalid time %q on %v", out, node)
+	}
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+// nodeCertificates describes the planet certificates on a node
+type nodeCertificates struct {
+	// time is the time of the node when the certificates were captured
+	time time.Time
+	// certs maps the certificate names to the certificates
+	certs map[string]certificate
+}
+
+// certificate describes a cluster certificate
+type certificate struct {
+	serial    string
+	notBefore time.Time
+	notAfter  time.Time
+}
+
+// nodeCertificates returns the planet certificates on every node, in the order of the nodes
+func (c *TestContext) nodeCertificates(ctx context.Context, nodes []Gravity) ([]nodeCertificates, error) {
+	result := make([]nodeCertificates, 0, len(nodes))
+	for _, node := range nodes {
+		g, ok := node.(*gravity)
+		if !ok {
+			return nil, trace.BadParameter("unsupported node %v", node)
+		}
+		now, err := nodeTime(ctx, g)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		certs, err := certificateInfo(ctx, g)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		result = append(result, nodeCertificates{time: now, certs: certs})
+	}
+	return result, nil
+}
+
+// certificateInfo returns the planet certificates on the node
+func certificateInfo(ctx context.Context, node Gravity) (map[string]certificate, error) {
+	certs := make(map[string]certificate, len(planetCertificates))
+	for _, name := range planetCertificates {
+		out, err := node.RunInPlanet(ctx, "/usr/bin/openssl", "x509", "-noout", "-serial", "-startdate", "-enddate",
+			"-in", planetStateDir+"/"+name)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		cert, err := parseCertificate(out)
+		if err != nil {
+			return nil, trace.Wrap(err, "invalid certificate %v", name)
+		}
+		certs[name] = *cert
+	}
+	return certs, nil
+}
+
+// parseCertificate parses the output of openssl x509 -serial -startdate -enddate:
+//
+//	serial=5E2C1A
+//	notBefore=Jun  5 10:20:30 2020 GMT
+//	notAfter=Jun  5 10:20:30 2030 GMT
+func parseCertificate(out string) (*certificate, error) {
+	var cert certificate
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		var err error
+		kv := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(kv) != 2 {
+			return nil, trace.BadParameter("invalid certificate output %q", out)
+		}
+		switch kv[0] {
+		case "serial":
+			cert.serial = kv[1]
+		case "notBefore":
+			cert.notBefore, err = parseCertificateDate(kv[1])
+		case "notAfter":
+			cert.notAfter, err = parseCertificateDate(kv[1])
+		}
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	if cert.notAfter.IsZero() {
+		return nil, trace.BadParameter("no certificate end date in %q", out)
+	}
+	return &cert, nil
+}
+
+// parseCertificateDate parses the certificate date as printed by openssl,
+// i.e. Jun  5 10:20:30 2030 GMT
+func parseCertificateDate(value string) (time.Time, error) {
+	t, err := time.Parse("Jan _2 15:04:05 2006 MST", value)
+	if err != nil {
+		return time.Time{}, trace.BadParameter("invalid certificate date %q", value)
+	}
+	return t, nil
+}
+
+// setClockCommand returns the command to set the system clock to t
+func setClockCommand(t time.Time) string {
+	return shell.Sudo("date", "-s", fmt.Sprintf("@%v", t.Unix())).String()
+}
+
+// disableTimeSyncCommand stops the time synchronization services
+// so that the clock is not set back after the jump
+const disableTimeSyncCommand = `sudo timedatectl set-ntp false 2>/dev/null; ` +
+	`for s in chronyd ntpd ntp systemd-timesyncd; do sudo systemctl disable --now $s 2>/dev/null; done; true`
+
+// listProjectedTokensCommand prints the path and the encoded JWT claims of every
+// service account token the kubelet has projected into the pods on the node
+const listProjectedTokensCommand = `for f in /var/lib/kubelet/pods/*/volumes/kubernetes.io~projected/*/token; do ` +
+	`[ -f "$f" ] && echo "$f $(cut -d. -f2 "$f")"; done; true`
+
+// planetStateDir is the directory with the cluster certificates inside planet
+const planetStateDir = "/var/state"
+
+// planetCertificates lists the cluster certificates verified inside planet
+var planetCertificates = []string{"root.cert", "apiserver.cert", "etcd.cert", "kubelet.cert"}
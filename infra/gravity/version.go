@@ -0,0 +1,110 @@
+package gravity
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gravitational/robotest/lib/shell"
+
+	"github.com/gravitational/trace"
+	semver "github.com/hashicorp/go-version"
+	"github.com/sirupsen/logrus"
+)
+
+// Version describes the version of the gravity binary as reported
+// by gravity version --output=json
+type Version struct {
+	// Edition is the gravity edition, i.e. open-source or enterprise
+	Edition string `json:"edition"`
+	// Version is the semantic version of the binary
+	Version string `json:"version"`
+	// GitCommit is the commit the binary has been built from
+	GitCommit string `json:"gitCommit"`
+	// Helm is the version of the embedded helm
+	Helm string `json:"helm"`
+}
+
+// String returns the textual representation of this version
+func (r Version) String() string {
+	if r.Edition == "" {
+		return r.Version
+	}
+	return fmt.Sprintf("%v (%v)", r.Version, r.Edition)
+}
+
+// Semver returns the parsed semantic version
+func (r Version) Semver() (*semver.Version, error) {
+	v, err := semver.NewVersion(r.Version)
+	if err != nil {
+		return nil, trace.BadParameter("invalid gravity version %q: %v", r.Version, err)
+	}
+	return v, nil
+}
+
+// Version returns the version of the gravity binary in the current install directory.
+// The version is detected once per install directory
+func (g *gravity) Version(ctx context.Context) (*Version, error) {
+	if g.version != nil && g.versionDir == g.installDir {
+		return g.version, nil
+	}
+	cmd := shell.InDir(g.installDir, shell.New("./gravity", "version", "--output=json"))
+	var version Version
+	err := g.runAndParse(ctx, g.Logger(), cmd, nil, parseVersion(&version))
+	if err != nil {
+		return nil, trace.Wrap(err, cmd)
+	}
+	g.Logger().WithFields(version.logFields()).Debug("Detected gravity version.")
+	g.version = &version
+	g.versionDir = g.installDir
+	return &version, nil
+}
+
+// capabilities describes the command line behavior of gravity
+// that differs across the release lines
+type capabilities struct {
+	// dockerDevice specifies whether install and join accept --docker-device.
+	// The flag has been removed with the devicemapper storage driver in 7.x
+	dockerDevice bool
+	// blockingOperations specifies whether operations like upgrade block
+	// until completion by default (since 5.4)
+	blockingOperations bool
+}
+
+// capabilitiesFor returns the capabilities of the gravity binary with the given version
+func capabilitiesFor(v *semver.Version) capabilities {
+	return capabilities{
+		dockerDevice:       v.LessThan(version7),
+		blockingOperations: !v.LessThan(version54),
+	}
+}
+
+// capabilities returns the capabilities of the gravity binary in the current install directory.
+// If the version cannot be determined, the capabilities of the latest supported
+// release line before 7.x are assumed
+func (g *gravity) capabilities(ctx context.Context) capabilities {
+	version, err := g.Version(ctx)
+	if err == nil {
+		var v *semver.Version
+		v, err = version.Semver()
+		if err == nil {
+			return capabilitiesFor(v)
+		}
+	}
+	g.Logger().WithError(err).Warn("Failed to detect gravity version, assume defaults.")
+	return defaultCapabilities
+}
+
+// logFields returns the log fields describing this version
+func (r Version) logFields() logrus.Fields {
+	return logrus.Fields{"gravity_version": r.Version, "gravity_edition": r.Edition}
+}
+
+var (
+	version54 = semver.Must(semver.NewVersion("5.4.0"))
+	version7  = semver.Must(semver.NewVersion("7.0.0-alpha"))
+
+	defaultCapabilities = capabilities{
+		dockerDevice:       true,
+		blockingOperations: true,
+	}
+)
@@ -0,0 +1,48 @@
+package gravity
+
+import (
+	"context"
+	"testing"
+
+	sshutils "github.com/gravitational/robotest/lib/ssh"
+
+	semver "github.com/hashicorp/go-version"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionDetection(t *testing.T) {
+	replayer := sshutils.NewReplayer(sshutils.Interaction{
+		Command: "cd /home/robotest/installer && ./gravity version --output=json",
+		Stdout:  `{"edition":"open-source","version":"6.1.9","gitCommit":"e1b3b4d","helm":"v2.14"}`,
+	})
+	g := &gravity{
+		transport:  replayer,
+		installDir: "/home/robotest/installer",
+		log:        logrus.NewEntry(logrus.StandardLogger()),
+	}
+	for i := 0; i < 2; i++ {
+		version, err := g.Version(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, &Version{Edition: "open-source", Version: "6.1.9", GitCommit: "e1b3b4d", Helm: "v2.14"}, version)
+	}
+	assert.Equal(t, capabilities{dockerDevice: true, blockingOperations: true}, g.capabilities(context.Background()))
+	assert.Equal(t, 0, replayer.Remaining(), "version detected once")
+}
+
+func TestCapabilities(t *testing.T) {
+	var testCases = []struct {
+		version  string
+		expected capabilities
+	}{
+		{version: "5.2.15", expected: capabilities{dockerDevice: true}},
+		{version: "5.5.40", expected: capabilities{dockerDevice: true, blockingOperations: true}},
+		{version: "7.0.0-beta.1", expected: capabilities{blockingOperations: true}},
+		{version: "7.0.12", expected: capabilities{blockingOperations: true}},
+	}
+	for _, tc := range testCases {
+		v := semver.Must(semver.NewVersion(tc.version))
+		assert.Equal(t, tc.expected, capabilitiesFor(v), tc.version)
+	}
+}
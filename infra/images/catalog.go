@@ -0,0 +1,207 @@
+// Package images implements the catalog of the OS images robotest
+// provisions nodes with.
+//
+// The catalog maps an OS (as vendor:version, i.e. centos:7.9 or ubuntu:20.04)
+// to the image source in each cloud - the image owner and name pattern on AWS,
+// the image project and family on GCE - which is then resolved to the latest
+// image at runtime. Images can be pinned to skip the resolution.
+package images
+
+import (
+	"strings"
+
+	"github.com/gravitational/robotest/lib/constants"
+
+	"github.com/gravitational/trace"
+)
+
+// Query describes the image to look up
+type Query struct {
+	// Cloud specifies the cloud provider
+	Cloud string
+	// Vendor specifies the OS vendor, i.e. centos
+	Vendor string
+	// Version specifies the OS version, i.e. 7.9.
+	// A major version selects the latest minor version,
+	// latest selects the latest version in the catalog
+	Version string
+	// Arch specifies the CPU architecture.
+	// Defaults to amd64
+	Arch string
+}
+
+// String returns the textual representation of this query
+func (r Query) String() string {
+	return r.Vendor + ":" + r.Version
+}
+
+// Source specifies where to find the images of a particular OS in the cloud
+type Source struct {
+	// Owner specifies the ID of the AWS account publishing the images
+	Owner string
+	// Name specifies the AWS image name pattern
+	Name string
+	// Project specifies the GCE project publishing the images
+	Project string
+	// Family specifies the GCE image family
+	Family string
+	// Arch specifies the architecture of the image.
+	// In the catalog, restricts the source to the given architecture.
+	// In the lookup result, specifies the AWS architecture name
+	Arch string
+}
+
+// Lookup returns the image source for the specified query
+func Lookup(query Query) (*Source, error) {
+	arch := query.Arch
+	if arch == "" {
+		arch = constants.ArchAMD64
+	}
+	names, ok := archNames[arch]
+	if !ok {
+		return nil, trace.BadParameter("unsupported architecture %q", arch)
+	}
+	// catalog is sorted by version, look up the latest version first
+	for i := len(catalog) - 1; i >= 0; i-- {
+		entry := catalog[i]
+		if entry.vendor != query.Vendor || !versionMatches(entry.version, query.Version) {
+			continue
+		}
+		version := query.Version
+		if version == versionLatest {
+			version = entry.version
+		}
+		var src *Source
+		var replacer *strings.Replacer
+		switch query.Cloud {
+		case constants.AWS:
+			src = entry.aws
+			replacer = strings.NewReplacer("{version}", version,
+				"{arch}", names.aws, "{debArch}", arch, "{gnuArch}", names.gnu)
+		case constants.GCE:
+			src = entry.gce
+			replacer = strings.NewReplacer("{version}", version, "{arch}", names.gceFamily)
+		default:
+			return nil, trace.BadParameter("image catalog does not support cloud %q", query.Cloud)
+		}
+		if src == nil || (src.Arch != "" && src.Arch != arch) {
+			continue
+		}
+		result := *src
+		result.Name = replacer.Replace(result.Name)
+		result.Family = replacer.Replace(result.Family)
+		result.Arch = names.aws
+		return &result, nil
+	}
+	return nil, trace.NotFound("no %v image for %v (%v) in catalog", query.Cloud, query, arch)
+}
+
+type archName struct {
+	// aws is the architecture name on AWS
+	aws string
+	// gnu is the GNU name of the architecture
+	gnu string
+	// gceFamily is the suffix of the GCE image family for the architecture
+	gceFamily string
+}
+
+var archNames = map[string]archName{
+	constants.ArchAMD64: {aws: "x86_64", gnu: "x86_64"},
+	constants.ArchARM64: {aws: "arm64", gnu: "aarch64", gceFamily: "-arm64"},
+}
+
+// versionMatches determines whether the requested version belongs to
+// the catalog version: either exactly, as a minor version of it or as its
+// major version (i.e. ubuntu:18 for 18.04).
+// The latest version matches any catalog version
+func versionMatches(catalogVersion, version string) bool {
+	return version == catalogVersion || version == versionLatest ||
+		strings.HasPrefix(version, catalogVersion+".") ||
+		strings.HasPrefix(catalogVersion, version+".")
+}
+
+// versionLatest selects the latest version of the OS in the catalog
+const versionLatest = "latest"
+
+type entry struct {
+	vendor string
+	// version specifies the OS version the entry applies to.
+	// Minor versions are matched by the major version entry
+	version string
+	aws     *Source
+	gce     *Source
+}
+
+// catalog lists the supported images.
+// In the name patterns, {version} is replaced with the requested version,
+// {arch} with the cloud-specific architecture (image family suffix on GCE),
+// {debArch} and {gnuArch} with the Debian and GNU architecture names.
+// Source.Arch restricts the source to a single architecture
+var catalog = []entry{
+	{
+		vendor:  "ubuntu",
+		version: "16.04",
+		aws:     &Source{Owner: ownerCanonical, Name: "ubuntu/images/hvm-ssd/ubuntu-xenial-16.04-amd64-server-*", Arch: constants.ArchAMD64},
+		gce:     &Source{Project: "ubuntu-os-cloud", Family: "ubuntu-1604-lts", Arch: constants.ArchAMD64},
+	},
+	{
+		vendor:  "ubuntu",
+		version: "18.04",
+		aws:     &Source{Owner: ownerCanonical, Name: "ubuntu/images/hvm-ssd/ubuntu-bionic-18.04-{debArch}-server-*"},
+		gce:     &Source{Project: "ubuntu-os-cloud", Family: "ubuntu-1804-lts{arch}"},
+	},
+	{
+		vendor:  "ubuntu",
+		version: "20.04",
+		aws:     &Source{Owner: ownerCanonical, Name: "ubuntu/images/hvm-ssd/ubuntu-focal-20.04-{debArch}-server-*"},
+		gce:     &Source{Project: "ubuntu-os-cloud", Family: "ubuntu-2004-lts{arch}"},
+	},
+	{
+		vendor:  "centos",
+		version: "7",
+		aws:     &Source{Owner: ownerCentOS, Name: "CentOS {version}* {gnuArch}*"},
+		gce:     &Source{Project: "centos-cloud", Family: "centos-7", Arch: constants.ArchAMD64},
+	},
+	{
+		vendor:  "redhat",
+		version: "7",
+		aws:     &Source{Owner: ownerRedHat, Name: "RHEL-{version}*_HVM-*-{arch}-*"},
+		gce:     &Source{Project: "rhel-cloud", Family: "rhel-7", Arch: constants.ArchAMD64},
+	},
+	{
+		vendor:  "redhat",
+		version: "8",
+		aws:     &Source{Owner: ownerRedHat, Name: "RHEL-{version}*_HVM-*-{arch}-*"},
+		gce:     &Source{Project: "rhel-cloud", Family: "rhel-8", Arch: constants.ArchAMD64},
+	},
+	{
+		vendor:  "debian",
+		version: "9",
+		aws:     &Source{Owner: ownerDebianLegacy, Name: "debian-stretch-hvm-x86_64-gp2-*", Arch: constants.ArchAMD64},
+		gce:     &Source{Project: "debian-cloud", Family: "debian-9", Arch: constants.ArchAMD64},
+	},
+	{
+		vendor:  "debian",
+		version: "10",
+		aws:     &Source{Owner: ownerDebian, Name: "debian-10-{debArch}-*"},
+		gce:     &Source{Project: "debian-cloud", Family: "debian-10", Arch: constants.ArchAMD64},
+	},
+	{
+		vendor:  "suse",
+		version: "12",
+		gce:     &Source{Project: "suse-cloud", Family: "sles-12", Arch: constants.ArchAMD64},
+	},
+	{
+		vendor:  "suse",
+		version: "15",
+		gce:     &Source{Project: "suse-cloud", Family: "sles-15{arch}"},
+	},
+}
+
+const (
+	ownerCanonical    = "099720109477"
+	ownerCentOS       = "125523088429"
+	ownerRedHat       = "309956199498"
+	ownerDebian       = "136693071363"
+	ownerDebianLegacy = "379101102735"
+)
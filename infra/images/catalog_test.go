@@ -0,0 +1,35 @@
+package images
+
+import (
+	"testing"
+
+	"github.com/gravitational/robotest/lib/constants"
+
+	"github.com/gravitational/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookup(t *testing.T) {
+	src, err := Lookup(Query{Cloud: constants.AWS, Vendor: "centos", Version: "7.9"})
+	require.NoError(t, err)
+	assert.Equal(t, &Source{Owner: ownerCentOS, Name: "CentOS 7.9* x86_64*", Arch: "x86_64"}, src)
+
+	src, err = Lookup(Query{Cloud: constants.AWS, Vendor: "ubuntu", Version: "20.04", Arch: constants.ArchARM64})
+	require.NoError(t, err)
+	assert.Equal(t, "ubuntu/images/hvm-ssd/ubuntu-focal-20.04-arm64-server-*", src.Name)
+	assert.Equal(t, "arm64", src.Arch)
+
+	src, err = Lookup(Query{Cloud: constants.GCE, Vendor: "ubuntu", Version: "18"})
+	require.NoError(t, err)
+	assert.Equal(t, "ubuntu-1804-lts", src.Family)
+
+	src, err = Lookup(Query{Cloud: constants.GCE, Vendor: "ubuntu", Version: "latest", Arch: constants.ArchARM64})
+	require.NoError(t, err)
+	assert.Equal(t, "ubuntu-2004-lts-arm64", src.Family)
+
+	_, err = Lookup(Query{Cloud: constants.GCE, Vendor: "centos", Version: "7", Arch: constants.ArchARM64})
+	assert.True(t, trace.IsNotFound(err))
+	_, err = Lookup(Query{Cloud: constants.AWS, Vendor: "suse", Version: "15"})
+	assert.True(t, trace.IsNotFound(err))
+}
@@ -0,0 +1,85 @@
+package images
+
+import (
+	"context"
+	"io/ioutil"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/gravitational/trace"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	compute "google.golang.org/api/compute/v1"
+)
+
+// Resolver resolves an image source to the current image in a cloud
+type Resolver interface {
+	// Resolve returns the ID of the latest image from the specified source
+	Resolve(ctx context.Context, src Source) (image string, err error)
+}
+
+// NewAWSResolver returns a resolver for AMIs in the region of the specified session
+func NewAWSResolver(sess *session.Session) Resolver {
+	return &awsResolver{ec2: ec2.New(sess)}
+}
+
+type awsResolver struct {
+	ec2 *ec2.EC2
+}
+
+// Resolve returns the ID of the most recent available AMI matching the source
+func (r *awsResolver) Resolve(ctx context.Context, src Source) (string, error) {
+	out, err := r.ec2.DescribeImagesWithContext(ctx, &ec2.DescribeImagesInput{
+		Owners: aws.StringSlice([]string{src.Owner}),
+		Filters: []*ec2.Filter{
+			{Name: aws.String("name"), Values: aws.StringSlice([]string{src.Name})},
+			{Name: aws.String("architecture"), Values: aws.StringSlice([]string{src.Arch})},
+			{Name: aws.String("state"), Values: aws.StringSlice([]string{"available"})},
+		},
+	})
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	if len(out.Images) == 0 {
+		return "", trace.NotFound("no AMI matches %q (owner %v, %v)", src.Name, src.Owner, src.Arch)
+	}
+	// creation dates are in ISO 8601 format and sort lexicographically
+	sort.Slice(out.Images, func(i, j int) bool {
+		return aws.StringValue(out.Images[i].CreationDate) > aws.StringValue(out.Images[j].CreationDate)
+	})
+	return aws.StringValue(out.Images[0].ImageId), nil
+}
+
+// NewGCEResolver returns a resolver for GCE images using the specified
+// service account file
+func NewGCEResolver(ctx context.Context, credentialsPath string) (Resolver, error) {
+	data, err := ioutil.ReadFile(credentialsPath)
+	if err != nil {
+		return nil, trace.ConvertSystemError(err)
+	}
+	creds, err := google.CredentialsFromJSON(ctx, data, compute.ComputeReadonlyScope)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	service, err := compute.New(oauth2.NewClient(ctx, creds.TokenSource))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &gceResolver{service: service}, nil
+}
+
+type gceResolver struct {
+	service *compute.Service
+}
+
+// Resolve returns the latest non-deprecated image of the source family
+// as {project}/{image}
+func (r *gceResolver) Resolve(ctx context.Context, src Source) (string, error) {
+	image, err := r.service.Images.GetFromFamily(src.Project, src.Family).Context(ctx).Do()
+	if err != nil {
+		return "", trace.Wrap(err, "failed to look up image family %v/%v", src.Project, src.Family)
+	}
+	return src.Project + "/" + image.Name, nil
+}
@@ -65,6 +65,13 @@ type Infra interface {
 	Config() Config
 }
 
+// Wizard describes the infrastructure bootstrapped with the wizard installer
+type Wizard interface {
+	Infra
+	// Process returns the installer process the wizard is running
+	Process() *WizardProcess
+}
+
 // Provisioner defines a means of creating a cluster from scratch and managing the nodes.
 //
 // Cluster can be created with a pool of nodes only a subset of which is active at any
@@ -93,8 +100,9 @@ type Provisioner interface {
 	// installerNode should be the result of calling Provisioner.Create
 	// addrs is guaranteed to have at least one element
 	SelectInterface(installer Node, addrs []string) (int, error)
-	// StartInstall initiates installation in the specified session
-	StartInstall(session *ssh.Session) error
+	// InstallerCommand returns the command that starts the interactive
+	// installer on the installer node
+	InstallerCommand() (string, error)
 	// UploadUpdate initiates uploading of new application version
 	// in the specified session
 	UploadUpdate(session *ssh.Session) error
@@ -120,10 +128,25 @@ type NodePool interface {
 	// SizeAllocated returns the number of allocated nodes in this pool
 	SizeAllocated() int
 	// Allocate allocates amount new nodes from the pool and returns
-	// a slice of allocated nodes
+	// a slice of allocated nodes.
+	// Nodes reserved for a role with SetLabels (see LabelRole) are not allocated
 	Allocate(amount int) ([]Node, error)
 	// Free releases specified nodes back to the node pool
 	Free([]Node) error
+	// AllocateWithLabels allocates amount free nodes matching selector,
+	// i.e. to reserve the nodes of a specific zone for a node profile.
+	// Nodes are picked deterministically and either all or none are allocated
+	AllocateWithLabels(amount int, selector Selector) ([]Node, error)
+	// NodesWithLabels returns the sub-pool of nodes matching selector
+	NodesWithLabels(selector Selector) []Node
+	// Labels returns the labels of the node given with addr
+	Labels(addr string) (map[string]string, error)
+	// SetLabels adds labels to the node given with addr.
+	// An empty value removes the label
+	SetLabels(addr string, labels map[string]string) error
+	// Rekey moves the node given with addr to its new public address newAddr,
+	// i.e. after the address of the node has changed, see ReaddressableNode
+	Rekey(addr, newAddr string) error
 }
 
 // Node defines an interface to a remote node
@@ -139,6 +162,42 @@ type Node interface {
 	// Client connects to this node and returns a new SSH Client object
 	// that can be used to execute remote commands
 	Client() (*ssh.Client, error)
+	// Disks returns the additional block devices attached to this node
+	Disks() []Disk
+	// Subnet returns the name of the subnet this node is attached to.
+	// Returns an empty string if the subnet is not known
+	Subnet() string
+	// PrivateAddrIPv6 returns the private IPv6 address of the node.
+	// Returns an empty string if the node has no IPv6 address
+	PrivateAddrIPv6() string
+}
+
+// ReaddressableNode is a node whose public address can change,
+// i.e. after the VM has been stopped and started again
+type ReaddressableNode interface {
+	Node
+	// SetAddr updates the public address of the node
+	SetAddr(addr string) error
+}
+
+// BlockDeviceNode is a node with node-specific block device paths,
+// i.e. the NVMe devices of the EBS volumes on AWS Nitro instances
+type BlockDeviceNode interface {
+	Node
+	// Device returns the path on the node of the block device attached as name
+	// (i.e. /dev/xvdb), or name if the device has no node-specific path
+	Device(name string) string
+}
+
+// Disk describes an additional block device attached to a node
+type Disk struct {
+	// Device specifies the path of the block device on the node.
+	// It is assigned by the provisioner
+	Device string `json:"device,omitempty" yaml:"device,omitempty"`
+	// SizeGB specifies the size of the disk in GB
+	SizeGB int `json:"size_gb" yaml:"size_gb" validate:"gte=1"`
+	// Type optionally specifies the cloud-specific type of the disk (i.e. io1 or pd-ssd)
+	Type string `json:"type,omitempty" yaml:"type,omitempty"`
 }
 
 // ExternalStateLoader loads provisioner state from external source
@@ -1,17 +1,37 @@
 package infra
 
-import "github.com/gravitational/trace"
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gravitational/trace"
+)
 
 // NewNodePool creates a new instance of NodePool from specified nodes
-// and allocation state
+// and allocation state.
+// Nodes are labeled with their subnet and the labels of nodes implementing LabeledNode
 func NewNodePool(nodes []Node, alloced []string) *nodePool {
 	nodeMap := make(map[string]Node, len(nodes))
+	labels := make(map[string]map[string]string, len(nodes))
 	for _, node := range nodes {
 		nodeMap[node.Addr()] = node
+		nodeLabels := make(map[string]string)
+		if subnet := node.Subnet(); subnet != "" {
+			nodeLabels[LabelSubnet] = subnet
+		}
+		if labeled, ok := node.(LabeledNode); ok {
+			for key, value := range labeled.Labels() {
+				nodeLabels[key] = value
+			}
+		}
+		labels[node.Addr()] = nodeLabels
 	}
 	p := &nodePool{
 		nodes:     nodeMap,
 		allocated: make(map[string]struct{}),
+		labels:    labels,
 	}
 	for _, alloc := range alloced {
 		p.allocated[alloc] = struct{}{}
@@ -19,28 +39,183 @@ func NewNodePool(nodes []Node, alloced []string) *nodePool {
 	return p
 }
 
+// LabeledNode is a node with provisioner-assigned labels,
+// i.e. the zone or instance type
+type LabeledNode interface {
+	// Labels returns the labels of this node
+	Labels() map[string]string
+}
+
+// Selector selects nodes by labels. A node matches if it has all labels of the selector.
+// An empty selector matches all nodes
+type Selector map[string]string
+
+// ParseSelector parses the selector in the form key=value[,key=value...],
+// i.e. role=master,zone=us-east-1a
+func ParseSelector(s string) (Selector, error) {
+	selector := make(Selector)
+	for _, term := range strings.Split(s, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		kv := strings.SplitN(term, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, trace.BadParameter("invalid selector term %q, expected key=value", term)
+		}
+		selector[kv[0]] = kv[1]
+	}
+	return selector, nil
+}
+
+// Matches returns true if labels contain all labels of this selector
+func (r Selector) Matches(labels map[string]string) bool {
+	for key, value := range r {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// String formats the selector as key=value[,key=value...]
+func (r Selector) String() string {
+	terms := make([]string, 0, len(r))
+	for key, value := range r {
+		terms = append(terms, fmt.Sprintf("%v=%v", key, value))
+	}
+	sort.Strings(terms)
+	return strings.Join(terms, ",")
+}
+
+const (
+	// LabelRole names the role (node profile) the node is reserved for
+	LabelRole = "role"
+	// LabelZone names the availability zone of the node
+	LabelZone = "zone"
+	// LabelInstanceType names the instance (machine) type of the node
+	LabelInstanceType = "instance-type"
+	// LabelSubnet names the subnet the node is attached to
+	LabelSubnet = "subnet"
+)
+
 // nodePool implements NodePool
 type nodePool struct {
+	// mu guards the fields below
+	mu        sync.Mutex
 	nodes     map[string]Node
 	allocated map[string]struct{}
+	labels    map[string]map[string]string
 }
 
+// Allocate allocates amount free nodes that have not been reserved for a role
+// with SetLabels (see LabelRole). Reserved nodes are only allocated with AllocateWithLabels
 func (r *nodePool) Allocate(amount int) (nodes []Node, err error) {
-	if amount+r.SizeAllocated() > r.Size() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var free []Node
+	for _, node := range r.freeNodes(nil) {
+		if r.labels[node.Addr()][LabelRole] == "" {
+			free = append(free, node)
+		}
+	}
+	if amount > len(free) {
 		return nil, trace.NotFound("cannot allocate %v node(s): capacity exceeded (by %v)",
-			amount, amount+r.SizeAllocated()-r.Size())
+			amount, amount-len(free))
+	}
+	return r.allocate(free[:amount]), nil
+}
+
+// AllocateWithLabels allocates amount free nodes matching selector.
+// Nodes are picked in the order of their addresses, so the allocation is deterministic.
+// Either all requested nodes are allocated or none
+func (r *nodePool) AllocateWithLabels(amount int, selector Selector) (nodes []Node, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	free := r.freeNodes(selector)
+	if len(free) < amount {
+		return nil, trace.NotFound("cannot allocate %v node(s) with labels %q: only %v available",
+			amount, selector, len(free))
+	}
+	return r.allocate(free[:amount]), nil
+}
+
+// freeNodes returns the unallocated nodes matching selector in the order of their addresses
+func (r *nodePool) freeNodes(selector Selector) (free []Node) {
+	for _, node := range r.nodesWithLabels(selector) {
+		if _, allocated := r.allocated[node.Addr()]; !allocated {
+			free = append(free, node)
+		}
+	}
+	return free
+}
+
+// allocate marks the nodes allocated
+func (r *nodePool) allocate(nodes []Node) []Node {
+	for _, node := range nodes {
+		r.allocated[node.Addr()] = struct{}{}
+	}
+	return nodes
+}
+
+// NodesWithLabels returns all nodes matching selector in the order of their addresses
+func (r *nodePool) NodesWithLabels(selector Selector) (nodes []Node) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.nodesWithLabels(selector)
+}
+
+func (r *nodePool) nodesWithLabels(selector Selector) (nodes []Node) {
+	addrs := make([]string, 0, len(r.nodes))
+	for addr := range r.nodes {
+		if selector.Matches(r.labels[addr]) {
+			addrs = append(addrs, addr)
+		}
+	}
+	sort.Strings(addrs)
+	for _, addr := range addrs {
+		nodes = append(nodes, r.nodes[addr])
+	}
+	return nodes
+}
+
+// Labels returns the labels of the node given with addr
+func (r *nodePool) Labels(addr string) (map[string]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	labels, exists := r.labels[addr]
+	if !exists {
+		return nil, trace.NotFound("node %q not found", addr)
 	}
-	for _, node := range r.nodes {
-		if _, exists := r.allocated[node.Addr()]; amount > 0 && !exists {
-			r.allocated[node.Addr()] = struct{}{}
-			amount = amount - 1
-			nodes = append(nodes, node)
+	copy := make(map[string]string, len(labels))
+	for key, value := range labels {
+		copy[key] = value
+	}
+	return copy, nil
+}
+
+// SetLabels adds labels to the node given with addr.
+// An empty value removes the label
+func (r *nodePool) SetLabels(addr string, labels map[string]string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	nodeLabels, exists := r.labels[addr]
+	if !exists {
+		return trace.NotFound("node %q not found", addr)
+	}
+	for key, value := range labels {
+		if value == "" {
+			delete(nodeLabels, key)
+			continue
 		}
+		nodeLabels[key] = value
 	}
-	return nodes, nil
+	return nil
 }
 
 func (r *nodePool) Free(nodes []Node) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	for _, node := range nodes {
 		if _, exists := r.allocated[node.Addr()]; !exists {
 			return trace.NotFound("cannot free unallocated node %q", node.Addr())
@@ -52,6 +227,8 @@ func (r *nodePool) Free(nodes []Node) error {
 }
 
 func (r *nodePool) Nodes() (nodes []Node) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	nodes = make([]Node, 0, len(r.nodes))
 	for addr := range r.nodes {
 		node := r.nodes[addr]
@@ -61,6 +238,8 @@ func (r *nodePool) Nodes() (nodes []Node) {
 }
 
 func (r *nodePool) AllocatedNodes() (nodes []Node) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	nodes = make([]Node, 0, len(r.allocated))
 	for addr := range r.allocated {
 		node := r.nodes[addr]
@@ -70,11 +249,47 @@ func (r *nodePool) AllocatedNodes() (nodes []Node) {
 }
 
 func (r *nodePool) Node(addr string) (Node, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	if node, exists := r.nodes[addr]; exists {
 		return node, nil
 	}
 	return nil, trace.NotFound("node %q not found", addr)
 }
 
-func (r *nodePool) Size() int          { return len(r.nodes) }
-func (r *nodePool) SizeAllocated() int { return len(r.allocated) }
+// Rekey moves the node with its labels and allocation state to newAddr
+func (r *nodePool) Rekey(addr, newAddr string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	node, exists := r.nodes[addr]
+	if !exists {
+		return trace.NotFound("node %q not found", addr)
+	}
+	if addr == newAddr {
+		return nil
+	}
+	if _, exists := r.nodes[newAddr]; exists {
+		return trace.AlreadyExists("node %q already exists", newAddr)
+	}
+	delete(r.nodes, addr)
+	r.nodes[newAddr] = node
+	r.labels[newAddr] = r.labels[addr]
+	delete(r.labels, addr)
+	if _, allocated := r.allocated[addr]; allocated {
+		delete(r.allocated, addr)
+		r.allocated[newAddr] = struct{}{}
+	}
+	return nil
+}
+
+func (r *nodePool) Size() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.nodes)
+}
+
+func (r *nodePool) SizeAllocated() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.allocated)
+}
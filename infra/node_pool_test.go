@@ -104,16 +104,149 @@ func TestDoesnotFreeNonExisting(t *testing.T) {
 	}
 }
 
+func TestAllocatesWithLabels(t *testing.T) {
+	// setup
+	nodes := []Node{
+		labeledNode{node{"c"}, map[string]string{LabelZone: "us-east-1a"}},
+		labeledNode{node{"a"}, map[string]string{LabelZone: "us-east-1a"}},
+		labeledNode{node{"b"}, map[string]string{LabelZone: "us-east-1b"}},
+		labeledNode{node{"d"}, map[string]string{LabelZone: "us-east-1a"}},
+	}
+	pool := NewNodePool(nodes, []string{"c"})
+	selector, err := ParseSelector("zone=us-east-1a")
+	if err != nil {
+		t.Fatalf("failed to parse selector: %v", err)
+	}
+
+	// exercise
+	allocatedNodes, err := pool.AllocateWithLabels(2, selector)
+
+	// verify
+	if err != nil {
+		t.Fatalf("failed to allocate nodes: %v", err)
+	}
+	if !reflect.DeepEqual(allocatedNodes, []Node{nodes[1], nodes[3]}) {
+		t.Errorf("unexpected allocation: want %v, got %v", []Node{nodes[1], nodes[3]}, allocatedNodes)
+	}
+
+	// exercise
+	allocatedNodes, err = pool.AllocateWithLabels(1, selector)
+
+	// verify
+	if err == nil {
+		t.Errorf("expected an error, but allocated %v", allocatedNodes)
+	}
+	if pool.SizeAllocated() != 3 {
+		t.Errorf("expected 3 allocated nodes but got %v", pool.SizeAllocated())
+	}
+
+	// exercise
+	err = pool.SetLabels("b", map[string]string{LabelRole: "worker"})
+	if err != nil {
+		t.Fatalf("failed to set labels: %v", err)
+	}
+	allocatedNodes, err = pool.AllocateWithLabels(1, Selector{LabelRole: "worker", LabelZone: "us-east-1b"})
+
+	// verify
+	if err != nil {
+		t.Fatalf("failed to allocate node: %v", err)
+	}
+	if !reflect.DeepEqual(allocatedNodes, []Node{nodes[2]}) {
+		t.Errorf("unexpected allocation: want %v, got %v", nodes[2], allocatedNodes)
+	}
+}
+
+func TestAllocateSkipsReservedNodes(t *testing.T) {
+	// setup
+	nodes := []Node{node{"a"}, node{"b"}, node{"c"}}
+	pool := NewNodePool(nodes, nil)
+	err := pool.SetLabels("a", map[string]string{LabelRole: "master"})
+	if err != nil {
+		t.Fatalf("failed to set labels: %v", err)
+	}
+
+	// exercise
+	allocatedNodes, err := pool.Allocate(2)
+
+	// verify
+	if err != nil {
+		t.Fatalf("failed to allocate nodes: %v", err)
+	}
+	if !reflect.DeepEqual(allocatedNodes, []Node{nodes[1], nodes[2]}) {
+		t.Errorf("unexpected allocation: want %v, got %v", []Node{nodes[1], nodes[2]}, allocatedNodes)
+	}
+
+	// exercise
+	allocatedNodes, err = pool.Allocate(1)
+
+	// verify
+	if err == nil {
+		t.Errorf("expected an error, but allocated reserved %v", allocatedNodes)
+	}
+	allocatedNodes, err = pool.AllocateWithLabels(1, Selector{LabelRole: "master"})
+	if err != nil {
+		t.Fatalf("failed to allocate reserved node: %v", err)
+	}
+	if !reflect.DeepEqual(allocatedNodes, []Node{nodes[0]}) {
+		t.Errorf("unexpected allocation: want %v, got %v", nodes[0], allocatedNodes)
+	}
+}
+
+func TestRekeys(t *testing.T) {
+	// setup
+	nodes := []Node{
+		labeledNode{node{"a"}, map[string]string{LabelZone: "us-east-1a"}},
+		labeledNode{node{"b"}, nil},
+	}
+	pool := NewNodePool(nodes, []string{"a"})
+
+	// exercise
+	err := pool.Rekey("a", "c")
+
+	// verify
+	if err != nil {
+		t.Fatalf("failed to rekey node: %v", err)
+	}
+	if _, err := pool.Node("a"); !trace.IsNotFound(err) {
+		t.Errorf("expected node a to be gone, got %v", err)
+	}
+	if node, err := pool.Node("c"); err != nil || !reflect.DeepEqual(node, nodes[0]) {
+		t.Errorf("expected node c to be %v, got %v (%v)", nodes[0], node, err)
+	}
+	if labels, _ := pool.Labels("c"); labels[LabelZone] != "us-east-1a" {
+		t.Errorf("expected labels to move with the node, got %v", labels)
+	}
+	if !reflect.DeepEqual(pool.AllocatedNodes(), []Node{nodes[0]}) {
+		t.Errorf("expected allocation to move with the node, got %v", pool.AllocatedNodes())
+	}
+	if err := pool.Rekey("c", "b"); !trace.IsAlreadyExists(err) {
+		t.Errorf("expected an error rekeying to an existing node, got %v", err)
+	}
+	if err := pool.Rekey("a", "d"); !trace.IsNotFound(err) {
+		t.Errorf("expected an error rekeying a missing node, got %v", err)
+	}
+}
+
 type node struct {
 	addr string
 }
 
-func (r node) String() string      { return fmt.Sprintf("node(%v)", r.addr) }
-func (r node) Addr() string        { return r.addr }
-func (r node) PrivateAddr() string { return r.addr }
+func (r node) String() string          { return fmt.Sprintf("node(%v)", r.addr) }
+func (r node) Addr() string            { return r.addr }
+func (r node) PrivateAddr() string     { return r.addr }
+func (r node) Disks() []Disk           { return nil }
+func (r node) Subnet() string          { return "" }
+func (r node) PrivateAddrIPv6() string { return "" }
 func (r node) Client() (*ssh.Client, error) {
 	return nil, trace.BadParameter("not implemented")
 }
 func (r node) Connect() (*ssh.Session, error) {
 	return nil, trace.BadParameter("not implemented")
 }
+
+type labeledNode struct {
+	node
+	labels map[string]string
+}
+
+func (r labeledNode) Labels() map[string]string { return r.labels }
@@ -6,6 +6,9 @@ type Config struct {
 	AccessKey string `json:"access_key" yaml:"access_key" validate:"required"`
 	// SecretKey http://docs.aws.amazon.com/general/latest/gr/managing-aws-access-keys.html
 	SecretKey string `json:"secret_key" yaml:"secret_key" validate:"required"`
+	// SessionToken optionally specifies the session token of temporary credentials.
+	// Set with the credentials issued by Vault
+	SessionToken string `json:"session_token,omitempty" yaml:"session_token"`
 	// Region specifies the EC2 region to install into
 	Region string `json:"region" yaml:"region" validate:"required"`
 	// KeyPair specifies the name of the SSH key pair to use for provisioning
@@ -0,0 +1,95 @@
+package aws
+
+import (
+	"context"
+	"encoding/base64"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/gravitational/trace"
+)
+
+// ConsoleOutput returns the serial console output of the instance of the cluster with the given private IP
+func ConsoleOutput(ctx context.Context, config Config, privateIP string) (string, error) {
+	svc, err := newEC2(config)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	instanceID, err := instanceByPrivateIP(ctx, svc, config, privateIP)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+
+	out, err := svc.GetConsoleOutputWithContext(ctx, &ec2.GetConsoleOutputInput{InstanceId: instanceID})
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	if out.Output == nil {
+		return "", trace.NotFound("no console output for instance %v", *instanceID)
+	}
+	data, err := base64.StdEncoding.DecodeString(*out.Output)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	return string(data), nil
+}
+
+func newEC2(config Config) (*ec2.EC2, error) {
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String(config.Region),
+		Credentials: credentials.NewStaticCredentials(config.AccessKey, config.SecretKey, config.SessionToken),
+	})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return ec2.New(sess), nil
+}
+
+// instanceByPrivateIP returns the ID of the instance of the cluster (see Config.ClusterName)
+// with the given private IP. Terminated instances are ignored.
+// The cluster is required so that an instance of another cluster with an overlapping
+// private address range is never matched
+func instanceByPrivateIP(ctx context.Context, svc *ec2.EC2, config Config, privateIP string) (*string, error) {
+	if config.ClusterName == "" {
+		return nil, trace.BadParameter("cluster name is required to look up instance %v", privateIP)
+	}
+	resp, err := svc.DescribeInstancesWithContext(ctx, &ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("private-ip-address"),
+				Values: []*string{aws.String(privateIP)},
+			},
+			{
+				Name:   aws.String("tag:Name"),
+				Values: []*string{aws.String(config.ClusterName)},
+			},
+			{
+				Name:   aws.String("tag:Origin"),
+				Values: []*string{aws.String("robotest")},
+			},
+			{
+				Name:   aws.String("instance-state-name"),
+				Values: aws.StringSlice([]string{"pending", "running", "stopping", "stopped"}),
+			},
+		},
+	})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var instanceIDs []*string
+	for _, reservation := range resp.Reservations {
+		for _, instance := range reservation.Instances {
+			instanceIDs = append(instanceIDs, instance.InstanceId)
+		}
+	}
+	switch len(instanceIDs) {
+	case 0:
+		return nil, trace.NotFound("no instance of cluster %v with private IP %v", config.ClusterName, privateIP)
+	case 1:
+		return instanceIDs[0], nil
+	default:
+		return nil, trace.BadParameter("multiple instances of cluster %v with private IP %v", config.ClusterName, privateIP)
+	}
+}
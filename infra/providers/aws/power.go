@@ -0,0 +1,84 @@
+package aws
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/gravitational/trace"
+)
+
+// PowerOn starts the stopped instance with the given private IP and waits until it is running
+func PowerOn(ctx context.Context, config Config, privateIP string) error {
+	svc, err := newEC2(config)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	instanceID, err := instanceByPrivateIP(ctx, svc, config, privateIP)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	_, err = svc.StartInstancesWithContext(ctx, &ec2.StartInstancesInput{InstanceIds: []*string{instanceID}})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	input := &ec2.DescribeInstancesInput{InstanceIds: []*string{instanceID}}
+	return trace.Wrap(svc.WaitUntilInstanceRunningWithContext(ctx, input))
+}
+
+// PowerOff stops the instance with the given private IP and waits until it is stopped.
+// Unless graceful, the instance is stopped without shutting down the operating system
+func PowerOff(ctx context.Context, config Config, privateIP string, graceful bool) error {
+	svc, err := newEC2(config)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	instanceID, err := instanceByPrivateIP(ctx, svc, config, privateIP)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	_, err = svc.StopInstancesWithContext(ctx, &ec2.StopInstancesInput{
+		InstanceIds: []*string{instanceID},
+		Force:       aws.Bool(!graceful),
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	input := &ec2.DescribeInstancesInput{InstanceIds: []*string{instanceID}}
+	return trace.Wrap(svc.WaitUntilInstanceStoppedWithContext(ctx, input))
+}
+
+// HardReset force stops the instance with the given private IP and starts it again.
+// EC2 has no reset: a reboot shuts down the operating system first.
+// Like PowerOn, this changes the public IP of instances without an elastic IP
+func HardReset(ctx context.Context, config Config, privateIP string) error {
+	if err := PowerOff(ctx, config, privateIP, false); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(PowerOn(ctx, config, privateIP))
+}
+
+// PublicIP returns the public IP of the instance with the given private IP.
+// The public IP changes when a stopped instance is started without an elastic IP
+func PublicIP(ctx context.Context, config Config, privateIP string) (string, error) {
+	svc, err := newEC2(config)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	instanceID, err := instanceByPrivateIP(ctx, svc, config, privateIP)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	resp, err := svc.DescribeInstancesWithContext(ctx, &ec2.DescribeInstancesInput{InstanceIds: []*string{instanceID}})
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	for _, reservation := range resp.Reservations {
+		for _, instance := range reservation.Instances {
+			if instance.PublicIpAddress != nil {
+				return *instance.PublicIpAddress, nil
+			}
+		}
+	}
+	return "", trace.NotFound("instance %v has no public IP", *instanceID)
+}
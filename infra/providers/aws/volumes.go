@@ -0,0 +1,47 @@
+package aws
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/gravitational/trace"
+)
+
+// BlockDeviceVolumes returns the EBS volumes attached to the instance with the given private IP,
+// mapped by the device name they have been attached as (i.e. /dev/xvdb)
+func BlockDeviceVolumes(ctx context.Context, config Config, privateIP string) (map[string]string, error) {
+	svc, err := newEC2(config)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	instanceID, err := instanceByPrivateIP(ctx, svc, config, privateIP)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	resp, err := svc.DescribeInstancesWithContext(ctx, &ec2.DescribeInstancesInput{InstanceIds: []*string{instanceID}})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	volumes := make(map[string]string)
+	for _, reservation := range resp.Reservations {
+		for _, instance := range reservation.Instances {
+			for _, mapping := range instance.BlockDeviceMappings {
+				if mapping.DeviceName == nil || mapping.Ebs == nil || mapping.Ebs.VolumeId == nil {
+					continue
+				}
+				volumes[aws.StringValue(mapping.DeviceName)] = aws.StringValue(mapping.Ebs.VolumeId)
+			}
+		}
+	}
+	return volumes, nil
+}
+
+// NVMeDevice returns the path of the EBS volume on Nitro instances.
+// Nitro instances expose EBS volumes as NVMe devices enumerated in the order
+// they are discovered, so the volume is looked up by the serial number (the volume ID)
+// instead of the device name it has been attached as
+func NVMeDevice(volumeID string) string {
+	return "/dev/disk/by-id/nvme-Amazon_Elastic_Block_Store_" + strings.Replace(volumeID, "-", "", 1)
+}
@@ -0,0 +1,90 @@
+package gce
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path"
+
+	"github.com/gravitational/trace"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	compute "google.golang.org/api/compute/v1"
+)
+
+// ConsoleOutput returns the serial console (port 1) output of the instance of the cluster with the given private IP
+func ConsoleOutput(ctx context.Context, config Config, privateIP string) (string, error) {
+	service, project, err := newCompute(ctx, config)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	instance, err := instanceByPrivateIP(ctx, service, config, project, privateIP)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+
+	out, err := service.Instances.GetSerialPortOutput(project, path.Base(instance.Zone), instance.Name).
+		Port(1).Context(ctx).Do()
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	return out.Contents, nil
+}
+
+// newCompute returns the compute service and the project for the configuration
+func newCompute(ctx context.Context, config Config) (*compute.Service, string, error) {
+	data, err := ioutil.ReadFile(config.Credentials)
+	if err != nil {
+		return nil, "", trace.ConvertSystemError(err)
+	}
+	creds, err := google.CredentialsFromJSON(ctx, data, compute.ComputeScope)
+	if err != nil {
+		return nil, "", trace.Wrap(err)
+	}
+	service, err := compute.New(oauth2.NewClient(ctx, creds.TokenSource))
+	if err != nil {
+		return nil, "", trace.Wrap(err)
+	}
+	project := config.Project
+	if project == "" {
+		project = creds.ProjectID
+	}
+	return service, project, nil
+}
+
+// instanceByPrivateIP returns the instance of the cluster (see Config.NodeTag) with the given private IP.
+// The cluster is required so that an instance of another cluster with an overlapping
+// private address range is never matched
+func instanceByPrivateIP(ctx context.Context, service *compute.Service, config Config, project, privateIP string) (*compute.Instance, error) {
+	if config.NodeTag == "" {
+		return nil, trace.BadParameter("node tag is required to look up instance %v", privateIP)
+	}
+	var instances []*compute.Instance
+	err := service.Instances.AggregatedList(project).Filter(fmt.Sprintf("labels.cluster = %q", config.NodeTag)).Pages(ctx,
+		func(page *compute.InstanceAggregatedList) error {
+			for _, scope := range page.Items {
+				for _, item := range scope.Instances {
+					if item.Labels["cluster"] != config.NodeTag {
+						continue
+					}
+					for _, iface := range item.NetworkInterfaces {
+						if iface.NetworkIP == privateIP {
+							instances = append(instances, item)
+						}
+					}
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	switch len(instances) {
+	case 0:
+		return nil, trace.NotFound("no instance of cluster %v with private IP %v", config.NodeTag, privateIP)
+	case 1:
+		return instances[0], nil
+	default:
+		return nil, trace.BadParameter("multiple instances of cluster %v with private IP %v", config.NodeTag, privateIP)
+	}
+}
@@ -0,0 +1,90 @@
+package gce
+
+import (
+	"context"
+	"path"
+	"time"
+
+	"github.com/gravitational/trace"
+	compute "google.golang.org/api/compute/v1"
+)
+
+// PowerOn starts the stopped instance with the given private IP and waits for the operation to complete
+func PowerOn(ctx context.Context, config Config, privateIP string) error {
+	return trace.Wrap(instanceOp(ctx, config, privateIP, func(service *compute.Service, project, zone, name string) (*compute.Operation, error) {
+		return service.Instances.Start(project, zone, name).Context(ctx).Do()
+	}))
+}
+
+// PowerOff stops the instance with the given private IP and waits for the operation to complete.
+// GCE always sends an ACPI shutdown to the guest before stopping the instance
+func PowerOff(ctx context.Context, config Config, privateIP string) error {
+	return trace.Wrap(instanceOp(ctx, config, privateIP, func(service *compute.Service, project, zone, name string) (*compute.Operation, error) {
+		return service.Instances.Stop(project, zone, name).Context(ctx).Do()
+	}))
+}
+
+// HardReset resets the instance with the given private IP without shutting down the guest
+func HardReset(ctx context.Context, config Config, privateIP string) error {
+	return trace.Wrap(instanceOp(ctx, config, privateIP, func(service *compute.Service, project, zone, name string) (*compute.Operation, error) {
+		return service.Instances.Reset(project, zone, name).Context(ctx).Do()
+	}))
+}
+
+// PublicIP returns the external IP of the instance with the given private IP.
+// Ephemeral external IPs change when a stopped instance is started
+func PublicIP(ctx context.Context, config Config, privateIP string) (string, error) {
+	service, project, err := newCompute(ctx, config)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	instance, err := instanceByPrivateIP(ctx, service, config, project, privateIP)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	for _, iface := range instance.NetworkInterfaces {
+		for _, access := range iface.AccessConfigs {
+			if access.NatIP != "" {
+				return access.NatIP, nil
+			}
+		}
+	}
+	return "", trace.NotFound("instance %v has no external IP", instance.Name)
+}
+
+// instanceOp starts the operation on the instance with the given private IP
+// and waits for the operation to complete
+func instanceOp(ctx context.Context, config Config, privateIP string,
+	start func(service *compute.Service, project, zone, name string) (*compute.Operation, error)) error {
+	service, project, err := newCompute(ctx, config)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	instance, err := instanceByPrivateIP(ctx, service, config, project, privateIP)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	zone := path.Base(instance.Zone)
+	op, err := start(service, project, zone, instance.Name)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	for op.Status != "DONE" {
+		select {
+		case <-time.After(operationPollInterval):
+		case <-ctx.Done():
+			return trace.Wrap(ctx.Err())
+		}
+		op, err = service.ZoneOperations.Get(project, zone, op.Name).Context(ctx).Do()
+		if err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	if op.Error != nil && len(op.Error.Errors) != 0 {
+		return trace.BadParameter("operation %v on %v failed: %v", op.OperationType, instance.Name, op.Error.Errors[0].Message)
+	}
+	return nil
+}
+
+// operationPollInterval is the interval to poll the status of zone operations
+const operationPollInterval = 5 * time.Second
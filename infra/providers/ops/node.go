@@ -2,6 +2,7 @@ package ops
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/gravitational/robotest/infra"
 	sshutils "github.com/gravitational/robotest/lib/ssh"
@@ -11,6 +12,8 @@ import (
 )
 
 type node struct {
+	// mu guards publicIP
+	mu         sync.RWMutex
 	publicIP   string
 	privateIP  string
 	sshKeyPath string
@@ -29,9 +32,20 @@ func New(publicIP string, privateIP string, sshUser string, sshKeyPath string) i
 }
 
 func (r *node) Addr() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	return r.publicIP
 }
 
+// SetAddr updates the public address of the node.
+// Implements infra.ReaddressableNode
+func (r *node) SetAddr(addr string) error {
+	r.mu.Lock()
+	r.publicIP = addr
+	r.mu.Unlock()
+	return nil
+}
+
 func (r *node) PrivateAddr() string {
 	return r.privateIP
 }
@@ -51,9 +65,27 @@ func (r *node) Client() (*ssh.Client, error) {
 		return nil, trace.Wrap(err)
 	}
 
-	return sshutils.Client(fmt.Sprintf("%v:22", r.publicIP), r.sshUser, signer)
+	return sshutils.Client(fmt.Sprintf("%v:22", r.Addr()), r.sshUser, signer)
+}
+
+// Disks returns the additional block devices of this node.
+// Additional disks are not supported by the ops center provisioner
+func (r *node) Disks() []infra.Disk {
+	return nil
+}
+
+// Subnet returns the subnet of this node.
+// The subnet is not known to the ops center provisioner
+func (r *node) Subnet() string {
+	return ""
+}
+
+// PrivateAddrIPv6 returns the IPv6 address of this node.
+// IPv6 is not supported by the ops center provisioner
+func (r *node) PrivateAddrIPv6() string {
+	return ""
 }
 
-func (r node) String() string {
-	return fmt.Sprintf("node(addr=%v, private_addr=%v)", r.publicIP, r.privateIP)
+func (r *node) String() string {
+	return fmt.Sprintf("node(addr=%v, private_addr=%v)", r.Addr(), r.privateIP)
 }
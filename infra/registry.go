@@ -0,0 +1,85 @@
+package infra
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/gravitational/trace"
+)
+
+// ProvisionerFactory creates the provisioners of a registered kind
+type ProvisionerFactory interface {
+	// New returns a new provisioner that keeps its state in stateDir
+	New(config Config, stateDir string) (Provisioner, error)
+	// NewFromState returns a provisioner for the infrastructure
+	// described by the previously saved state
+	NewFromState(config Config, state ProvisionerState) (Provisioner, error)
+}
+
+// RegisterProvisioner makes the provisioner factory available under name,
+// so that custom provisioners can be compiled in without changes to robotest:
+//
+//	func init() {
+//		infra.RegisterProvisioner("maas", maasFactory{})
+//	}
+//
+// Panics if factory is nil or a factory has already been registered under name
+func RegisterProvisioner(name string, factory ProvisionerFactory) {
+	provisionersMu.Lock()
+	defer provisionersMu.Unlock()
+	if factory == nil {
+		panic("infra: RegisterProvisioner factory is nil")
+	}
+	if _, dup := provisioners[name]; dup {
+		panic(fmt.Sprintf("infra: RegisterProvisioner called twice for provisioner %v", name))
+	}
+	provisioners[name] = factory
+}
+
+// NewProvisioner returns a new provisioner of the kind registered under name
+func NewProvisioner(name string, config Config, stateDir string) (Provisioner, error) {
+	factory, err := provisionerFactory(name)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	provisioner, err := factory.New(config, stateDir)
+	return provisioner, trace.Wrap(err)
+}
+
+// NewProvisionerFromState returns a provisioner of the kind registered under name
+// for the infrastructure described by state
+func NewProvisionerFromState(name string, config Config, state ProvisionerState) (Provisioner, error) {
+	factory, err := provisionerFactory(name)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	provisioner, err := factory.NewFromState(config, state)
+	return provisioner, trace.Wrap(err)
+}
+
+// Provisioners returns the sorted names of the registered provisioners
+func Provisioners() (names []string) {
+	provisionersMu.RLock()
+	defer provisionersMu.RUnlock()
+	for name := range provisioners {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func provisionerFactory(name string) (ProvisionerFactory, error) {
+	provisionersMu.RLock()
+	factory, ok := provisioners[name]
+	provisionersMu.RUnlock()
+	if !ok {
+		return nil, trace.NotFound("unknown provisioner %q, registered provisioners: %v", name, Provisioners())
+	}
+	return factory, nil
+}
+
+var (
+	provisionersMu sync.RWMutex
+	provisioners   = make(map[string]ProvisionerFactory)
+)
@@ -0,0 +1,32 @@
+package infra
+
+import (
+	"testing"
+
+	"github.com/gravitational/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvisionerRegistry(t *testing.T) {
+	RegisterProvisioner("test", testFactory{})
+	assert.Contains(t, Provisioners(), "test")
+	assert.Panics(t, func() { RegisterProvisioner("test", testFactory{}) })
+
+	_, err := NewProvisioner("test", Config{Settings: map[string]interface{}{"pool": "robotest"}}, "/tmp")
+	require.Error(t, err)
+	assert.Equal(t, "pool robotest", trace.Unwrap(err).Error())
+
+	_, err = NewProvisioner("missing", Config{}, "/tmp")
+	assert.True(t, trace.IsNotFound(err))
+}
+
+type testFactory struct{}
+
+func (testFactory) New(config Config, stateDir string) (Provisioner, error) {
+	return nil, trace.BadParameter("pool %v", config.Settings["pool"])
+}
+
+func (testFactory) NewFromState(config Config, state ProvisionerState) (Provisioner, error) {
+	return nil, trace.NotImplemented("not implemented")
+}
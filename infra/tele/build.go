@@ -0,0 +1,126 @@
+// Package tele builds cluster images (installer tarballs) from application
+// manifests with tele, so that unreleased manifests can be tested without
+// publishing the installer first.
+package tele
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+
+	"github.com/gravitational/robotest/lib/constants"
+	"github.com/gravitational/robotest/lib/shell"
+	sshutils "github.com/gravitational/robotest/lib/ssh"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// BuildConfig defines how to build the installer
+type BuildConfig struct {
+	// Manifest specifies the path to the application manifest (app.yaml).
+	// With BuildNode, the path is on the build node
+	Manifest string `yaml:"manifest" validate:"required"`
+	// Output specifies the local path of the resulting installer tarball
+	Output string `yaml:"output" validate:"required"`
+	// Version optionally overrides the application version
+	Version string `yaml:"version"`
+	// StateDir optionally specifies the tele state directory
+	// to cache the dependencies across builds
+	StateDir string `yaml:"state_dir"`
+	// Tele specifies the path to the tele binary.
+	// Defaults to tele in PATH
+	Tele string `yaml:"tele"`
+	// Args lists additional arguments to tele build
+	Args []string `yaml:"args"`
+	// BuildNode optionally specifies the node to run the build on.
+	// The installer is copied to Output once built
+	BuildNode *BuildNode `yaml:"build_node"`
+}
+
+// BuildNode specifies SSH access to the build node
+type BuildNode struct {
+	// Addr specifies the SSH address of the node as host:port
+	Addr string `yaml:"addr" validate:"required"`
+	// User specifies the SSH user
+	User string `yaml:"user" validate:"required"`
+	// KeyPath specifies the path to the SSH private key
+	KeyPath string `yaml:"key_path" validate:"required"`
+}
+
+// Build builds the installer as configured and stores it at config.Output
+func Build(ctx context.Context, config BuildConfig, log logrus.FieldLogger) error {
+	log = log.WithFields(logrus.Fields{"manifest": config.Manifest, "output": config.Output})
+	if config.BuildNode != nil {
+		return trace.Wrap(buildOnNode(ctx, config, log))
+	}
+
+	err := os.MkdirAll(filepath.Dir(config.Output), constants.SharedDirMask)
+	if err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	log.Info("Build installer.")
+	cmd := exec.CommandContext(ctx, config.tele(), config.args(config.Output)...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return trace.Wrap(err, "tele build failed: %s", out)
+	}
+	log.Debugf("Installer built: %s.", out)
+	return nil
+}
+
+// buildOnNode runs tele build on the build node and copies the installer to config.Output
+func buildOnNode(ctx context.Context, config BuildConfig, log logrus.FieldLogger) error {
+	signer, err := sshutils.MakePrivateKeySignerFromFile(config.BuildNode.KeyPath)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	client, err := sshutils.Client(config.BuildNode.Addr, config.BuildNode.User, signer)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer client.Close()
+
+	log = log.WithField("build_node", config.BuildNode.Addr)
+	remotePath := path.Join("/tmp", "robotest-build", filepath.Base(config.Output))
+	log.Info("Build installer on build node.")
+	err = sshutils.Run(ctx, client, log, config.remoteCommand(remotePath), nil)
+	if err != nil {
+		return trace.Wrap(err, "tele build failed on %v", config.BuildNode.Addr)
+	}
+	log.Info("Copy installer from build node.")
+	if err := os.Remove(config.Output); err != nil && !os.IsNotExist(err) {
+		return trace.ConvertSystemError(err)
+	}
+	return trace.Wrap(sshutils.PipeCommand(ctx, client, log, shell.New("cat", remotePath).String(), config.Output))
+}
+
+// remoteCommand returns the command line to build the installer into output on the build node
+func (r BuildConfig) remoteCommand(output string) string {
+	return shell.And(
+		shell.New("mkdir", "-p", path.Dir(output)),
+		shell.New(r.tele(), r.args(output)...),
+	)
+}
+
+func (r BuildConfig) tele() string {
+	if r.Tele != "" {
+		return r.Tele
+	}
+	return "tele"
+}
+
+// args returns the tele build command line arguments to build the installer into output
+func (r BuildConfig) args(output string) []string {
+	args := []string{"build", r.Manifest, fmt.Sprintf("--output=%v", output), "--overwrite"}
+	if r.Version != "" {
+		args = append(args, fmt.Sprintf("--version=%v", r.Version))
+	}
+	if r.StateDir != "" {
+		args = append(args, fmt.Sprintf("--state-dir=%v", r.StateDir))
+	}
+	return append(args, r.Args...)
+}
@@ -0,0 +1,31 @@
+package tele
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildArgs(t *testing.T) {
+	config := BuildConfig{
+		Manifest: "/src/app.yaml",
+		Version:  "0.0.1-dev",
+		StateDir: "/cache",
+		Args:     []string{"--skip-version-check"},
+	}
+	assert.Equal(t, "tele", config.tele())
+	assert.Equal(t, []string{
+		"build", "/src/app.yaml", "--output=/state/installer.tar", "--overwrite",
+		"--version=0.0.1-dev", "--state-dir=/cache", "--skip-version-check",
+	}, config.args("/state/installer.tar"))
+}
+
+func TestQuotesRemoteCommand(t *testing.T) {
+	config := BuildConfig{
+		Manifest: "/src/my app/app.yaml",
+		Args:     []string{"--set-image=repo/app:1.0 latest"},
+	}
+	assert.Equal(t, "mkdir -p /tmp/robotest-build && tele build '/src/my app/app.yaml' "+
+		"--output=/tmp/robotest-build/installer.tar --overwrite '--set-image=repo/app:1.0 latest'",
+		config.remoteCommand("/tmp/robotest-build/installer.tar"))
+}
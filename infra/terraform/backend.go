@@ -0,0 +1,114 @@
+package terraform
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+
+	"github.com/gravitational/robotest/lib/constants"
+
+	"github.com/gravitational/trace"
+)
+
+// Backend defines the remote backend to store terraform state in.
+// With a remote backend, the state is keyed by the run ID and does not
+// depend on the local state directory, so the resources can be destroyed
+// even if the workspace has been lost
+type Backend struct {
+	// Type specifies the backend type
+	Type string `json:"type" yaml:"type" validate:"required,eq=s3|eq=gcs"`
+	// Bucket specifies the name of the bucket to store the state in
+	Bucket string `json:"bucket" yaml:"bucket" validate:"required"`
+	// Prefix specifies an optional path prefix for the state objects
+	Prefix string `json:"prefix,omitempty" yaml:"prefix"`
+	// Region specifies the bucket region.
+	// Only applicable to the s3 backend
+	Region string `json:"region,omitempty" yaml:"region"`
+	// LockTable specifies the name of the DynamoDB table used for state locking.
+	// Only applicable to the s3 backend, gcs backend supports locking natively
+	LockTable string `json:"lock_table,omitempty" yaml:"lock_table"`
+}
+
+const (
+	backendS3  = "s3"
+	backendGCS = "gcs"
+	// tfBackendFile names the file with the backend configuration block.
+	// Remaining backend parameters are provided during init
+	tfBackendFile = "robotest_backend.tf"
+	// tfBackendConfigFile names the file with the backend parameters
+	tfBackendConfigFile = "robotest.backend.hcl"
+)
+
+// Check validates the backend configuration
+func (r Backend) Check() error {
+	switch r.Type {
+	case backendS3:
+		if r.Region == "" {
+			return trace.BadParameter("s3 backend requires a region")
+		}
+	case backendGCS:
+	default:
+		return trace.BadParameter("unsupported terraform backend %q", r.Type)
+	}
+	if r.Bucket == "" {
+		return trace.BadParameter("terraform backend requires a bucket")
+	}
+	return nil
+}
+
+// StatePath returns the location of the state for the specified run within the bucket
+func (r Backend) StatePath(runID string) string {
+	switch r.Type {
+	case backendS3:
+		return path.Join(r.Prefix, runID, "terraform.tfstate")
+	default:
+		// gcs backend names the state object after the workspace
+		// under the specified prefix
+		return path.Join(r.Prefix, runID)
+	}
+}
+
+// writeBackend generates the terraform configuration file enabling the backend
+// in the specified directory
+func (r Backend) writeBackend(dir string) error {
+	config := fmt.Sprintf("terraform {\n  backend %q {}\n}\n", r.Type)
+	err := ioutil.WriteFile(filepath.Join(dir, tfBackendFile), []byte(config), constants.SharedReadWriteMask)
+	return trace.ConvertSystemError(err)
+}
+
+// writeBackendConfig stores the backend parameters for the given run into
+// the file at path to be consumed by terraform init.
+// AWS and GCE credentials are reused from the cloud configuration
+// as terraform is not run with the calling environment.
+// The parameters are passed as a file to avoid leaking credentials into the logs
+func (r Backend) writeBackendConfig(path, runID string, config Config) error {
+	var buf bytes.Buffer
+	add := func(key, value string) {
+		if value != "" {
+			fmt.Fprintf(&buf, "%v = %q\n", key, value)
+		}
+	}
+	switch r.Type {
+	case backendS3:
+		add("bucket", r.Bucket)
+		add("key", r.StatePath(runID))
+		add("region", r.Region)
+		add("dynamodb_table", r.LockTable)
+		add("encrypt", "true")
+		if config.AWS != nil {
+			add("access_key", config.AWS.AccessKey)
+			add("secret_key", config.AWS.SecretKey)
+			add("token", config.AWS.SessionToken)
+		}
+	case backendGCS:
+		add("bucket", r.Bucket)
+		add("prefix", r.StatePath(runID))
+		if config.GCE != nil {
+			add("credentials", config.GCE.Credentials)
+		}
+	}
+	err := ioutil.WriteFile(path, buf.Bytes(), 0600)
+	return trace.ConvertSystemError(err)
+}
@@ -0,0 +1,70 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/gravitational/robotest/lib/constants"
+
+	"github.com/gravitational/trace"
+)
+
+// injectBootstrapScript merges the custom bootstrap script into the VM bootstrap
+// scripts (user-data) of the terraform configuration in the state directory
+func (r *terraform) injectBootstrapScript() error {
+	if r.BootstrapScriptPath == "" {
+		return nil
+	}
+	script, err := ioutil.ReadFile(r.BootstrapScriptPath)
+	if err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	paths, err := filepath.Glob(filepath.Join(r.stateDir, "bootstrap", "*.sh"))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if len(paths) == 0 {
+		return trace.NotFound("no bootstrap scripts in %v", r.ScriptPath)
+	}
+	for _, path := range paths {
+		bootstrap, err := ioutil.ReadFile(path)
+		if err != nil {
+			return trace.ConvertSystemError(err)
+		}
+		// GCE bootstrap scripts are rendered as terraform templates
+		templated := r.CloudProvider == constants.GCE
+		merged := mergeBootstrap(string(bootstrap), string(script), templated)
+		err = ioutil.WriteFile(path, []byte(merged), constants.SharedReadWriteMask)
+		if err != nil {
+			return trace.ConvertSystemError(err)
+		}
+	}
+	return nil
+}
+
+// mergeBootstrap inserts the custom script into the bootstrap script
+// right before the bootstrap is marked complete, so that robotest only
+// connects to the node after the custom script has run.
+// The custom script is appended if the bootstrap script has no completion marker.
+// If templated is set, the custom script is escaped from template interpolation
+func mergeBootstrap(bootstrap, script string, templated bool) string {
+	if templated {
+		script = strings.Replace(script, "${", "$${", -1)
+		script = strings.Replace(script, "%{", "%%{", -1)
+	}
+	snippet := "\n# custom bootstrap script\n" + strings.TrimRight(script, "\n") + "\n\n"
+	i := strings.LastIndex(bootstrap, bootstrapCompleteMarker)
+	if i < 0 {
+		return strings.TrimRight(bootstrap, "\n") + "\n" + snippet
+	}
+	if lineStart := strings.LastIndex(bootstrap[:i], "\n"); lineStart >= 0 {
+		i = lineStart + 1
+	} else {
+		i = 0
+	}
+	return bootstrap[:i] + snippet + bootstrap[i:]
+}
+
+// bootstrapCompleteMarker names the file created once the VM bootstrap is complete
+const bootstrapCompleteMarker = "/var/lib/bootstrap_complete"
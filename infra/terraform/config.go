@@ -23,6 +23,15 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	if c.Backend != nil {
+		if err := c.Backend.Check(); err != nil {
+			errors = append(errors, err)
+		}
+		if c.RunID == "" {
+			errors = append(errors, trace.BadParameter("run ID is required with a remote backend"))
+		}
+	}
+
 	if len(errors) != 0 {
 		return trace.NewAggregate(errors...)
 	}
@@ -39,6 +48,18 @@ func (c *Config) Validate() error {
 		if c.Azure == nil {
 			return trace.BadParameter("Azure configuration is required")
 		}
+		if len(c.ExtraDisks) != 0 {
+			return trace.BadParameter("additional disks are not supported on Azure")
+		}
+		if c.Subnets > 1 {
+			return trace.BadParameter("multiple subnets are not supported on Azure")
+		}
+		if c.IPv6 {
+			return trace.BadParameter("IPv6 is not supported on Azure")
+		}
+		if c.Arch == constants.ArchARM64 {
+			return trace.BadParameter("arm64 nodes are not supported on Azure")
+		}
 		if c.Azure.SSHUser == "" || c.Azure.SSHKeyPath == "" {
 			return trace.BadParameter("Azure SSH access configuration is required")
 		}
@@ -96,4 +117,36 @@ type Config struct {
 	VarFilePath string `json:"var_file_path" yaml:"var_file_path"`
 	// OnpremProvider specifies usage of onprem provider for installation
 	OnpremProvider bool `json:"onprem_provider" yaml:"onprem_provider"`
+	// Vars defines additional terraform variables (i.e. instance type or disk sizes).
+	// Vars take precedence over both the cloud configuration and the variables
+	// from VarFilePath
+	Vars map[string]interface{} `json:"vars,omitempty" yaml:"vars,omitempty"`
+	// Backend optionally specifies the remote backend to store the terraform state in.
+	// If unspecified, the state is kept in the local state directory
+	Backend *Backend `json:"backend,omitempty" yaml:"backend,omitempty"`
+	// RunID uniquely identifies the provisioned infrastructure.
+	// It is used as the key of the remote state
+	RunID string `json:"run_id,omitempty" yaml:"run_id,omitempty"`
+	// Tags defines the tags (labels on GCE) to attach to all provisioned resources
+	Tags map[string]string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	// ExtraDisks lists the additional block devices to attach to each node.
+	// Only supported on AWS and GCE
+	ExtraDisks []infra.Disk `json:"extra_disks,omitempty" yaml:"extra_disks,omitempty" validate:"dive"`
+	// Subnets specifies the number of subnets to spread the nodes over (round-robin).
+	// On AWS, each subnet is the default subnet of a separate availability zone.
+	// Only supported on AWS and GCE
+	Subnets int `json:"subnets,omitempty" yaml:"subnets,omitempty" validate:"gte=0"`
+	// IPv6 requests an IPv6 address in addition to the IPv4 address
+	// for each node (dual-stack). Requires IPv6-enabled subnets.
+	// Only supported on AWS and GCE
+	IPv6 bool `json:"ipv6,omitempty" yaml:"ipv6,omitempty"`
+	// BootstrapScriptPath optionally specifies the path to the script to run
+	// as part of the VM bootstrap (user-data), i.e. to create partitions or set sysctls
+	BootstrapScriptPath string `json:"-" yaml:"bootstrap_script_path,omitempty"`
+	// Image optionally specifies the image to provision the nodes with
+	// instead of the image selected by the terraform script for the OS
+	Image string `json:"image,omitempty" yaml:"image,omitempty"`
+	// Arch specifies the CPU architecture of the nodes.
+	// Defaults to amd64, arm64 is only supported on AWS and GCE
+	Arch string `json:"arch,omitempty" yaml:"arch,omitempty" validate:"omitempty,eq=amd64|eq=arm64"`
 }
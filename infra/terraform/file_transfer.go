@@ -36,12 +36,16 @@ func (t *terraform) makeRemoteCommand(fileUrl, command string) (string, error) {
 			return "", trace.Errorf("AWS config missing, cannot use S3 URLs %s", fileUrl)
 		}
 
+		var sessionToken string
+		if t.Config.AWS.SessionToken != "" {
+			sessionToken = fmt.Sprintf("AWS_SESSION_TOKEN=%s ", t.Config.AWS.SessionToken)
+		}
 		fetchCmd = fmt.Sprintf(`AWS_ACCESS_KEY_ID=%s \
 			AWS_SECRET_ACCESS_KEY=%s \
 			AWS_DEFAULT_REGION=%s \
-			aws s3 cp %s - > %s`,
+			%saws s3 cp %s - > %s`,
 			t.Config.AWS.AccessKey, t.Config.AWS.SecretKey, t.Config.AWS.Region,
-			fileUrl, outFile)
+			sessionToken, fileUrl, outFile)
 	case "http":
 	case "https":
 		fetchCmd = fmt.Sprintf("wget %s -O %s/", fileUrl, outFile)
@@ -2,32 +2,114 @@ package terraform
 
 import (
 	"fmt"
+	"sync"
 
+	"github.com/gravitational/robotest/infra"
+	"github.com/gravitational/robotest/infra/providers/aws"
+
+	"github.com/gravitational/trace"
 	"golang.org/x/crypto/ssh"
 )
 
 type node struct {
-	owner     *terraform
+	owner *terraform
+	// mu guards publicIP
+	mu        sync.RWMutex
 	publicIP  string
 	privateIP string
+	disks     []infra.Disk
+	subnet    string
+	ipv6      string
+	labels    map[string]string
+	// devices maps the names the block devices have been attached as
+	// to their paths on the node, see setVolumes
+	devices map[string]string
 }
 
 func (r *node) Addr() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	return r.publicIP
 }
 
+// SetAddr updates the public address of the node and moves the node
+// to the new address in the node pool.
+// Implements infra.ReaddressableNode
+func (r *node) SetAddr(addr string) error {
+	r.mu.Lock()
+	prev := r.publicIP
+	r.publicIP = addr
+	r.mu.Unlock()
+	if r.owner == nil || r.owner.pool == nil {
+		return nil
+	}
+	return trace.Wrap(r.owner.pool.Rekey(prev, addr))
+}
+
 func (r *node) PrivateAddr() string {
 	return r.privateIP
 }
 
 func (r *node) Connect() (*ssh.Session, error) {
-	return r.owner.Connect(fmt.Sprintf("%v:22", r.publicIP))
+	return r.owner.Connect(fmt.Sprintf("%v:22", r.Addr()))
 }
 
 func (r *node) Client() (*ssh.Client, error) {
-	return r.owner.Client(fmt.Sprintf("%v:22", r.publicIP))
+	return r.owner.Client(fmt.Sprintf("%v:22", r.Addr()))
+}
+
+func (r *node) Disks() []infra.Disk {
+	return r.disks
+}
+
+// Device returns the path of the block device attached as name.
+// Implements infra.BlockDeviceNode
+func (r *node) Device(name string) string {
+	if device, ok := r.devices[name]; ok {
+		return device
+	}
+	return name
+}
+
+// setVolumes assigns the NVMe device paths of the EBS volumes (mapped by the device
+// name they have been attached as) to the block devices and the additional disks of the node
+func (r *node) setVolumes(volumes map[string]string) {
+	r.devices = make(map[string]string, len(volumes))
+	for name, volumeID := range volumes {
+		r.devices[name] = aws.NVMeDevice(volumeID)
+	}
+	for i, disk := range r.disks {
+		r.disks[i].Device = r.Device(disk.Device)
+	}
+}
+
+func (r *node) Subnet() string {
+	return r.subnet
+}
+
+func (r *node) PrivateAddrIPv6() string {
+	return r.ipv6
+}
+
+// Labels returns the zone and instance type labels of the node.
+// Implements infra.LabeledNode
+func (r *node) Labels() map[string]string {
+	return r.labels
+}
+
+// nodeLabels returns the labels of the node in zone with the instance type.
+// Empty values are not labeled
+func nodeLabels(zone, instanceType string) map[string]string {
+	labels := make(map[string]string)
+	if zone != "" {
+		labels[infra.LabelZone] = zone
+	}
+	if instanceType != "" {
+		labels[infra.LabelInstanceType] = instanceType
+	}
+	return labels
 }
 
-func (r node) String() string {
-	return fmt.Sprintf("node(addr=%v)", r.publicIP)
+func (r *node) String() string {
+	return fmt.Sprintf("node(addr=%v)", r.Addr())
 }
@@ -5,18 +5,23 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/gravitational/robotest/infra"
+	"github.com/gravitational/robotest/infra/providers/aws"
 	"github.com/gravitational/robotest/infra/providers/azure"
 	"github.com/gravitational/robotest/lib/constants"
 	"github.com/gravitational/robotest/lib/defaults"
+	"github.com/gravitational/robotest/lib/failure"
 	sshutils "github.com/gravitational/robotest/lib/ssh"
 	"github.com/gravitational/robotest/lib/system"
 
@@ -27,6 +32,7 @@ import (
 
 const (
 	tfVarsFile           = "robotest.tfvars.json"
+	tfExtraVarsFile      = "robotest.extra.tfvars.json"
 	terraformRepeatAfter = time.Second * 5
 )
 
@@ -69,7 +75,7 @@ func NewFromState(config Config, stateConfig infra.ProvisionerState) (*terraform
 
 	nodes := make([]infra.Node, 0, len(stateConfig.Nodes))
 	for _, n := range stateConfig.Nodes {
-		nodes = append(nodes, &node{publicIP: n.Addr, owner: t})
+		nodes = append(nodes, &node{publicIP: n.Addr, owner: t, disks: t.extraDisks()})
 	}
 	t.pool = infra.NewNodePool(nodes, stateConfig.Allocated)
 
@@ -77,13 +83,10 @@ func NewFromState(config Config, stateConfig infra.ProvisionerState) (*terraform
 }
 
 func (r *terraform) Create(ctx context.Context, withInstaller bool) (installer infra.Node, err error) {
-	nfiles, err := system.CopyAll(r.ScriptPath, r.stateDir)
+	err = r.copyScripts()
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
-	if nfiles == 0 {
-		return nil, trace.NotFound("no terraform configuration at %v", r.ScriptPath)
-	}
 
 	// sometimes terraform cannot receive all required params
 	// most often public IPs take time to allocate (on Azure)
@@ -104,19 +107,45 @@ func (r *terraform) Create(ctx context.Context, withInstaller bool) (installer i
 		}
 
 		if !trace.IsRetryError(err) {
-			return nil, trace.Wrap(err, "terraform failed")
+			return nil, trace.Wrap(failure.Provisioning(err), "terraform failed")
 		}
 		log.WithError(err).Warningf("Terraform experienced transient error, will retry in %v.",
 			terraformRepeatAfter)
 
 		select {
 		case <-ctx.Done():
-			return nil, trace.Wrap(ctx.Err(), "terraform creation timed out")
+			return nil, trace.Wrap(failure.Provisioning(ctx.Err()), "terraform creation timed out")
 		case <-time.After(terraformRepeatAfter):
 		}
 	}
 }
 
+// AddNodes provisions count additional nodes into the cluster created with Create
+// by applying the configuration with the increased capacity.
+// The existing nodes are left intact. Returns the new nodes
+func (r *terraform) AddNodes(ctx context.Context, count int) (nodes []infra.Node, err error) {
+	existing := make(map[string]bool)
+	for _, node := range r.pool.Nodes() {
+		existing[node.PrivateAddr()] = true
+	}
+	// the capacity is increased even if the apply fails as the state
+	// might have the new nodes partially created
+	r.NumNodes += count
+	err = r.terraform(ctx)
+	if err != nil {
+		return nil, trace.Wrap(failure.Provisioning(err), "terraform failed")
+	}
+	for _, node := range r.pool.Nodes() {
+		if !existing[node.PrivateAddr()] {
+			nodes = append(nodes, node)
+		}
+	}
+	if len(nodes) != count {
+		return nil, trace.BadParameter("expected %v new nodes, got %v", count, infra.Nodes(nodes))
+	}
+	return nodes, nil
+}
+
 // LoadFromExternalState parses terraform output from terraform state file
 func (r *terraform) LoadFromExternalState(rdr io.Reader, withInstaller bool) (infra.Node, error) {
 	err := r.loadFromState(rdr)
@@ -145,7 +174,31 @@ func (r *terraform) terraform(ctx context.Context) (err error) {
 	defer f.Close()
 
 	err = r.loadFromState(f)
-	return trace.Wrap(err)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(r.resolveVolumes(ctx))
+}
+
+// resolveVolumes looks up the EBS volumes of the nodes on AWS Nitro instances, which expose
+// the volumes as NVMe devices instead of under the device names they have been attached as.
+// Only arm64 (Graviton) instances are known to be Nitro instances
+func (r *terraform) resolveVolumes(ctx context.Context) error {
+	if r.CloudProvider != constants.AWS || r.AWS == nil || r.Arch != constants.ArchARM64 {
+		return nil
+	}
+	for _, n := range r.pool.Nodes() {
+		node, ok := n.(*node)
+		if !ok {
+			return trace.BadParameter("unexpected node %v", n)
+		}
+		volumes, err := aws.BlockDeviceVolumes(ctx, *r.AWS, node.privateIP)
+		if err != nil {
+			return trace.Wrap(err, "failed to look up the volumes of %v", node)
+		}
+		node.setVolumes(volumes)
+	}
+	return nil
 }
 
 func (r *terraform) loadFromState(rdr io.Reader) error {
@@ -164,10 +217,29 @@ func (r *terraform) loadFromState(rdr io.Reader) error {
 
 	nodes := make([]infra.Node, 0, len(outputs.PublicAddrs.Addrs))
 	for i, addr := range outputs.PublicAddrs.Addrs {
+		var subnet, ipv6, zone, instanceType string
+		if i < len(outputs.Subnets.Subnets) {
+			// GCE reports subnets as self links
+			subnet = path.Base(outputs.Subnets.Subnets[i])
+		}
+		if i < len(outputs.Zones.Zones) {
+			// GCE reports zones and machine types as self links
+			zone = path.Base(outputs.Zones.Zones[i])
+		}
+		if i < len(outputs.InstanceTypes.Types) {
+			instanceType = path.Base(outputs.InstanceTypes.Types[i])
+		}
+		if i < len(outputs.PrivateAddrsIPv6.Addrs) {
+			ipv6 = outputs.PrivateAddrsIPv6.Addrs[i]
+		}
 		nodes = append(nodes, &node{
 			privateIP: outputs.PrivateAddrs.Addrs[i],
 			publicIP:  addr,
 			owner:     r,
+			disks:     r.extraDisks(),
+			subnet:    subnet,
+			ipv6:      ipv6,
+			labels:    nodeLabels(zone, instanceType),
 		})
 	}
 	r.pool = infra.NewNodePool(nodes, nil)
@@ -209,16 +281,21 @@ func (r *terraform) Destroy(ctx context.Context) error {
 		return nil
 	}
 
-	varsPath := filepath.Join(r.stateDir, tfVarsFile)
-	destroyCommand := []string{
-		"destroy", "-auto-approve",
-		"-var", fmt.Sprintf("nodes=%d", r.NumNodes),
-		"-var", fmt.Sprintf("os=%s", r.OS),
-		fmt.Sprintf("-var-file=%s", varsPath),
-	}
-	if r.VarFilePath != "" {
-		destroyCommand = append(destroyCommand, fmt.Sprintf("-var-file=%s", r.VarFilePath))
+	if r.Backend != nil && !r.initialized() {
+		// The workspace is gone: recreate it from the scripts
+		// and retrieve the state from the remote backend
+		r.Infof("Restore workspace for run %v from remote state.", r.RunID)
+		err := r.copyScripts()
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		err = r.init(ctx)
+		if err != nil {
+			return trace.Wrap(err)
+		}
 	}
+
+	destroyCommand := append([]string{"destroy", "-auto-approve"}, r.varArgs()...)
 	_, err := r.command(ctx, destroyCommand)
 	return trace.Wrap(err)
 }
@@ -248,16 +325,16 @@ func (r *terraform) Client(addr string) (*ssh.Client, error) {
 	return sshutils.Client(addr, r.sshUser, signer)
 }
 
-func (r *terraform) StartInstall(session *ssh.Session) error {
+func (r *terraform) InstallerCommand() (string, error) {
 	command := fmt.Sprintf("./gravity install --mode=interactive --log-file=%s", r.InstallerLogPath())
 	if r.Config.OnpremProvider {
 		command = fmt.Sprintf("%s %s", command, "--cloud-provider=onprem")
 	}
 	cmd, err := r.makeRemoteCommand(r.Config.InstallerURL, command)
 	if err != nil {
-		return trace.Wrap(err, "Installer")
+		return "", trace.Wrap(err, "Installer")
 	}
-	return session.Start(cmd)
+	return cmd, nil
 }
 
 func (r *terraform) UploadUpdate(session *ssh.Session) error {
@@ -277,7 +354,7 @@ func (r *terraform) InstallerLogPath() string {
 func (r *terraform) State() infra.ProvisionerState {
 	nodes := make([]infra.StateNode, 0, r.pool.Size())
 	for _, n := range r.pool.Nodes() {
-		nodes = append(nodes, infra.StateNode{Addr: n.(*node).publicIP, KeyPath: r.sshKeyPath})
+		nodes = append(nodes, infra.StateNode{Addr: n.Addr(), KeyPath: r.sshKeyPath})
 	}
 	allocated := make([]string, 0, r.pool.SizeAllocated())
 	for _, node := range r.pool.AllocatedNodes() {
@@ -295,32 +372,14 @@ func (r *terraform) State() infra.ProvisionerState {
 }
 
 func (r *terraform) boot(ctx context.Context) (rc io.ReadCloser, err error) {
-	out, err := r.command(ctx, []string{
-		"init", "-input=false", "-get-plugins=false",
-		fmt.Sprintf("-plugin-dir=%v", constants.TerraformPluginDir),
-		r.stateDir},
-	)
+	err = r.init(ctx)
 	if err != nil {
-		return nil, trace.Wrap(err, "failed to init terraform: %s", out)
+		return nil, trace.Wrap(err)
 	}
 
-	varsPath := filepath.Join(r.stateDir, tfVarsFile)
-	err = r.saveVarsJSON(varsPath)
-	if err != nil {
-		return nil, trace.Wrap(err, "failed to store terraform vars")
-	}
+	applyCommand := append([]string{"apply", "-input=false", "-auto-approve"}, r.varArgs()...)
 
-	applyCommand := []string{
-		"apply", "-input=false", "-auto-approve",
-		"-var", fmt.Sprintf("nodes=%d", r.NumNodes),
-		"-var", fmt.Sprintf("os=%s", r.OS),
-		fmt.Sprintf("-var-file=%s", varsPath),
-	}
-	if r.VarFilePath != "" {
-		applyCommand = append(applyCommand, fmt.Sprintf("-var-file=%s", r.VarFilePath))
-	}
-
-	out, err = r.command(ctx, applyCommand)
+	out, err := r.command(ctx, applyCommand)
 	if err != nil {
 		return nil, trace.Wrap(err, "failed to boot terraform cluster: %s", out)
 	}
@@ -405,6 +464,223 @@ func (r *terraform) saveVarsJSON(varFile string) error {
 	return trace.Wrap(enc.Encode(config))
 }
 
+// copyScripts copies the terraform scripts into the state directory
+func (r *terraform) copyScripts() error {
+	nfiles, err := system.CopyAll(r.ScriptPath, r.stateDir)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if nfiles == 0 {
+		return trace.NotFound("no terraform configuration at %v", r.ScriptPath)
+	}
+	return trace.Wrap(r.injectBootstrapScript())
+}
+
+// init initializes the terraform workspace in the state directory
+// and stores the variable files
+func (r *terraform) init(ctx context.Context) error {
+	initCommand := []string{
+		"init", "-input=false", "-get-plugins=false",
+		fmt.Sprintf("-plugin-dir=%v", constants.TerraformPluginDir),
+	}
+	if r.Backend != nil {
+		err := r.Backend.writeBackend(r.stateDir)
+		if err != nil {
+			return trace.Wrap(err, "failed to configure terraform backend")
+		}
+		configPath := filepath.Join(r.stateDir, tfBackendConfigFile)
+		err = r.Backend.writeBackendConfig(configPath, r.RunID, r.Config)
+		if err != nil {
+			return trace.Wrap(err, "failed to configure terraform backend")
+		}
+		initCommand = append(initCommand, fmt.Sprintf("-backend-config=%v", configPath))
+	}
+	initCommand = append(initCommand, r.stateDir)
+
+	out, err := r.command(ctx, initCommand)
+	if err != nil {
+		return trace.Wrap(err, "failed to init terraform: %s", out)
+	}
+
+	err = r.saveVarsJSON(filepath.Join(r.stateDir, tfVarsFile))
+	if err != nil {
+		return trace.Wrap(err, "failed to store terraform vars")
+	}
+
+	err = r.saveExtraVarsJSON(filepath.Join(r.stateDir, tfExtraVarsFile))
+	if err != nil {
+		return trace.Wrap(err, "failed to store extra terraform vars")
+	}
+	return nil
+}
+
+// initialized determines whether the terraform workspace has been initialized
+func (r *terraform) initialized() bool {
+	_, err := os.Stat(filepath.Join(r.stateDir, ".terraform"))
+	return err == nil
+}
+
+// varArgs returns the variable arguments for terraform apply/destroy.
+// Variable files are specified in the order of precedence with the
+// last file taking precedence
+func (r *terraform) varArgs() []string {
+	args := []string{
+		"-var", fmt.Sprintf("nodes=%d", r.NumNodes),
+		"-var", fmt.Sprintf("os=%s", r.OS),
+		fmt.Sprintf("-var-file=%s", filepath.Join(r.stateDir, tfVarsFile)),
+	}
+	if r.VarFilePath != "" {
+		args = append(args, fmt.Sprintf("-var-file=%s", r.VarFilePath))
+	}
+	if len(r.extraVars()) != 0 {
+		args = append(args, fmt.Sprintf("-var-file=%s", filepath.Join(r.stateDir, tfExtraVarsFile)))
+	}
+	return args
+}
+
+// extraVars returns the additional terraform variables together with the image,
+// CPU architecture, additional disks and resource tags.
+// The latter are only supported by the AWS and GCE scripts
+func (r *terraform) extraVars() map[string]interface{} {
+	vars := make(map[string]interface{}, len(r.Vars)+1)
+	for name, value := range r.Vars {
+		vars[name] = value
+	}
+	if r.Arch != "" && r.CloudProvider != constants.Azure {
+		vars["arch"] = r.Arch
+	}
+	if r.Image != "" && r.CloudProvider != constants.Azure {
+		vars["image"] = r.Image
+	}
+	if len(r.ExtraDisks) != 0 {
+		var sizes, types, devices []string
+		for _, disk := range r.extraDisks() {
+			sizes = append(sizes, strconv.Itoa(disk.SizeGB))
+			types = append(types, disk.Type)
+			devices = append(devices, disk.Device)
+		}
+		vars["extra_disk_sizes"] = sizes
+		vars["extra_disk_types"] = types
+		if r.CloudProvider == constants.AWS {
+			vars["extra_disk_devices"] = devices
+		}
+	}
+	if r.IPv6 && r.CloudProvider != constants.Azure {
+		vars["ipv6"] = true
+	}
+	if r.Subnets > 1 {
+		vars["subnets"] = r.Subnets
+	}
+	if r.CloudProvider == constants.GCE && r.GCE != nil {
+		// the robotest subnet is IPv4-only, so dual-stack nodes are provisioned
+		// in dedicated subnets only
+		count := r.Subnets - 1
+		if r.IPv6 {
+			count = r.Subnets
+			if count == 0 {
+				count = 1
+			}
+		}
+		if count > 0 {
+			vars["subnet_cidrs"] = subnetCIDRs(r.GCE.NodeTag, count)
+		}
+	}
+	if len(r.Tags) == 0 {
+		return vars
+	}
+	switch r.CloudProvider {
+	case constants.AWS:
+		vars["tags"] = r.Tags
+	case constants.GCE:
+		vars["labels"] = r.Tags
+	}
+	return vars
+}
+
+// extraDisks returns the configured additional disks with the device paths
+// and default types assigned.
+// On AWS, the devices follow the docker (/dev/xvdb) and etcd (/dev/xvdc) devices
+// (see resolveVolumes for Nitro instances).
+// On GCE, the devices are named after the attachment device name
+func (r *terraform) extraDisks() []infra.Disk {
+	disks := make([]infra.Disk, 0, len(r.ExtraDisks))
+	for i, disk := range r.ExtraDisks {
+		switch r.CloudProvider {
+		case constants.AWS:
+			disk.Device = fmt.Sprintf("/dev/xvd%c", 'd'+i)
+			if disk.Type == "" {
+				disk.Type = "gp2"
+			}
+		case constants.GCE:
+			disk.Device = fmt.Sprintf("/dev/disk/by-id/google-extra-%d", i)
+			if disk.Type == "" {
+				disk.Type = "pd-ssd"
+			}
+		}
+		disks = append(disks, disk)
+	}
+	return disks
+}
+
+// subnetCIDRs returns count /24 blocks for the additional subnets of the cluster.
+// The blocks are picked from 10.192.0.0/11 based on the cluster name
+// to avoid conflicts between clusters provisioned in parallel
+func subnetCIDRs(cluster string, count int) []string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(cluster))
+	// 10.192.0.0/11 has 2^13 /24 blocks
+	const blocks = 1 << 13
+	base := h.Sum32() % blocks
+	cidrs := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		block := (base + uint32(i)) % blocks
+		cidrs = append(cidrs, fmt.Sprintf("10.%d.%d.0/24", 192+block/256, block%256))
+	}
+	return cidrs
+}
+
+// saveExtraVarsJSON serializes the additional terraform variables into given file as JSON
+func (r *terraform) saveExtraVarsJSON(varFile string) error {
+	vars := r.extraVars()
+	if len(vars) == 0 {
+		return nil
+	}
+	data, err := json.MarshalIndent(jsonCompatible(vars), " ", " ")
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	err = ioutil.WriteFile(varFile, data, constants.SharedReadWriteMask)
+	return trace.Wrap(trace.ConvertSystemError(err),
+		"failed to save terraform variables file %v", varFile)
+}
+
+// jsonCompatible converts the maps decoded from YAML (which use interface{} keys)
+// into maps that can be serialized as JSON
+func jsonCompatible(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			out[fmt.Sprint(key)] = jsonCompatible(value)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			out[key] = jsonCompatible(value)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, 0, len(v))
+		for _, value := range v {
+			out = append(out, jsonCompatible(value))
+		}
+		return out
+	default:
+		return value
+	}
+}
+
 // MarshalJSON serializes this state object as JSON
 func (r *State) MarshalJSON() ([]byte, error) {
 	type state State
@@ -453,6 +729,22 @@ type outputs struct {
 	PrivateAddrs struct {
 		Addrs []string `json:"value"`
 	} `json:"private_ips"`
+	// PrivateAddrsIPv6 lists private IPv6 addresses of infrastructure nodes
+	PrivateAddrsIPv6 struct {
+		Addrs []string `json:"value"`
+	} `json:"ipv6_ips"`
+	// Subnets lists the subnets of infrastructure nodes
+	Subnets struct {
+		Subnets []string `json:"value"`
+	} `json:"subnets"`
+	// Zones lists the availability zones of infrastructure nodes
+	Zones struct {
+		Zones []string `json:"value"`
+	} `json:"zones"`
+	// InstanceTypes lists the instance (machine) types of infrastructure nodes
+	InstanceTypes struct {
+		Types []string `json:"value"`
+	} `json:"instance_types"`
 	// LoadBalancerAddr specifies the IP address of the cloud Load Balancer
 	LoadBalancerAddr struct {
 		Addr string `json:"value"`
@@ -0,0 +1,187 @@
+package terraform
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gravitational/robotest/infra"
+	"github.com/gravitational/robotest/infra/providers/aws"
+	"github.com/gravitational/robotest/infra/providers/gce"
+	"github.com/gravitational/robotest/lib/constants"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestExtraVars(t *testing.T) {
+	var config Config
+	err := yaml.Unmarshal([]byte(`
+vars:
+  instance_type: c5.4xlarge
+  extra_disks:
+    - size: 100
+      type: io1
+`), &config)
+	require.NoError(t, err)
+
+	data, err := json.Marshal(jsonCompatible(config.Vars))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"instance_type":"c5.4xlarge","extra_disks":[{"size":100,"type":"io1"}]}`, string(data))
+
+	r := &terraform{Config: config, stateDir: "/state"}
+	r.NumNodes = 3
+	r.OS = "ubuntu:18"
+	assert.Equal(t, []string{
+		"-var", "nodes=3",
+		"-var", "os=ubuntu:18",
+		"-var-file=/state/robotest.tfvars.json",
+		"-var-file=/state/robotest.extra.tfvars.json",
+	}, r.varArgs())
+}
+
+func TestExtraDisks(t *testing.T) {
+	r := &terraform{Config: Config{
+		CloudProvider: constants.AWS,
+		ExtraDisks:    []infra.Disk{{SizeGB: 100, Type: "io1"}, {SizeGB: 20}},
+		Tags:          map[string]string{"owner": "ci"},
+	}}
+	assert.Equal(t, []infra.Disk{
+		{Device: "/dev/xvdd", SizeGB: 100, Type: "io1"},
+		{Device: "/dev/xvde", SizeGB: 20, Type: "gp2"},
+	}, r.extraDisks())
+	assert.Equal(t, map[string]interface{}{
+		"extra_disk_sizes":   []string{"100", "20"},
+		"extra_disk_types":   []string{"io1", "gp2"},
+		"extra_disk_devices": []string{"/dev/xvdd", "/dev/xvde"},
+		"tags":               map[string]string{"owner": "ci"},
+	}, r.extraVars())
+
+	r.CloudProvider = constants.GCE
+	assert.Equal(t, "/dev/disk/by-id/google-extra-1", r.extraDisks()[1].Device)
+	assert.NotContains(t, r.extraVars(), "extra_disk_devices")
+}
+
+func TestSubnets(t *testing.T) {
+	r := &terraform{Config: Config{
+		CloudProvider: constants.GCE,
+		GCE:           &gce.Config{NodeTag: "robotest-1234"},
+		Subnets:       3,
+	}}
+	vars := r.extraVars()
+	assert.Equal(t, 3, vars["subnets"])
+	cidrs := vars["subnet_cidrs"].([]string)
+	assert.Len(t, cidrs, 2)
+	assert.NotEqual(t, cidrs[0], cidrs[1])
+	assert.Equal(t, cidrs, subnetCIDRs("robotest-1234", 2))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		assert.NoError(t, err)
+		assert.True(t, robotestSubnets.Contains(network.IP), cidr)
+	}
+
+	r.IPv6 = true
+	assert.Equal(t, subnetCIDRs("robotest-1234", 3), r.extraVars()["subnet_cidrs"], "dual-stack")
+	r.Subnets = 0
+	assert.Equal(t, subnetCIDRs("robotest-1234", 1), r.extraVars()["subnet_cidrs"], "dual-stack single subnet")
+
+	r.CloudProvider = constants.AWS
+	assert.NotContains(t, r.extraVars(), "subnet_cidrs")
+}
+
+var _, robotestSubnets, _ = net.ParseCIDR("10.192.0.0/11")
+
+func TestNodeVolumes(t *testing.T) {
+	r := &terraform{Config: Config{
+		CloudProvider: constants.AWS,
+		ExtraDisks:    []infra.Disk{{SizeGB: 100}},
+	}}
+	n := &node{owner: r, disks: r.extraDisks()}
+	assert.Equal(t, "/dev/xvdb", n.Device("/dev/xvdb"))
+
+	n.setVolumes(map[string]string{
+		"/dev/xvdb": "vol-0123456789abcdef0",
+		"/dev/xvdd": "vol-0fedcba9876543210",
+	})
+	assert.Equal(t, "/dev/disk/by-id/nvme-Amazon_Elastic_Block_Store_vol0123456789abcdef0", n.Device("/dev/xvdb"))
+	assert.Equal(t, "/dev/xvdc", n.Device("/dev/xvdc"), "unknown device")
+	assert.Equal(t, "/dev/disk/by-id/nvme-Amazon_Elastic_Block_Store_vol0fedcba9876543210", n.Disks()[0].Device)
+	assert.Equal(t, "/dev/xvdd", r.extraDisks()[0].Device, "disks of other nodes")
+}
+
+func TestNodeLabels(t *testing.T) {
+	r := &terraform{FieldLogger: logrus.New(), Config: Config{CloudProvider: constants.GCE}}
+	err := r.loadFromState(strings.NewReader(`{
+		"public_ips": {"value": ["1.1.1.1", "1.1.1.2"]},
+		"private_ips": {"value": ["10.0.0.1", "10.0.0.2"]},
+		"zones": {"value": ["https://www.googleapis.com/compute/v1/projects/robotest/zones/us-central1-a", "us-central1-b"]},
+		"instance_types": {"value": ["https://www.googleapis.com/compute/v1/projects/robotest/zones/us-central1-a/machineTypes/n1-standard-4", "n1-standard-8"]}
+	}`))
+	require.NoError(t, err)
+	nodes := r.pool.NodesWithLabels(infra.Selector{infra.LabelZone: "us-central1-a"})
+	require.Len(t, nodes, 1)
+	assert.Equal(t, "10.0.0.1", nodes[0].PrivateAddr())
+	node, err := r.pool.Node("1.1.1.2")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{infra.LabelZone: "us-central1-b", infra.LabelInstanceType: "n1-standard-8"},
+		node.(infra.LabeledNode).Labels())
+}
+
+func TestMergeBootstrap(t *testing.T) {
+	bootstrap := `#!/bin/bash
+mkfs.ext4 /dev/xvdc
+# robotest might SSH before bootstrap script is complete (and will fail)
+touch /var/lib/bootstrap_complete
+`
+	assert.Equal(t, `#!/bin/bash
+mkfs.ext4 /dev/xvdc
+# robotest might SSH before bootstrap script is complete (and will fail)
+
+# custom bootstrap script
+sysctl -w net.ipv4.ip_forward=1
+
+touch /var/lib/bootstrap_complete
+`, mergeBootstrap(bootstrap, "sysctl -w net.ipv4.ip_forward=1\n", false))
+
+	assert.Equal(t, `#!/bin/bash
+
+# custom bootstrap script
+echo $${HOME}
+
+`, mergeBootstrap("#!/bin/bash\n", "echo ${HOME}", true))
+}
+
+func TestBackendConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "robotest-tf")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	backend := Backend{Type: "s3", Bucket: "robotest-state", Prefix: "ci", Region: "us-east-1", LockTable: "locks"}
+	require.NoError(t, backend.Check())
+	assert.Equal(t, "ci/run-1/terraform.tfstate", backend.StatePath("run-1"))
+
+	path := filepath.Join(dir, tfBackendConfigFile)
+	err = backend.writeBackendConfig(path, "run-1", Config{AWS: &aws.Config{AccessKey: "key", SecretKey: "secret"}})
+	require.NoError(t, err)
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `bucket = "robotest-state"
+key = "ci/run-1/terraform.tfstate"
+region = "us-east-1"
+dynamodb_table = "locks"
+encrypt = "true"
+access_key = "key"
+secret_key = "secret"
+`, string(data))
+
+	gcs := Backend{Type: "gcs", Bucket: "robotest-state"}
+	require.NoError(t, gcs.Check())
+	assert.Equal(t, "run-1", gcs.StatePath("run-1"))
+	assert.Error(t, Backend{Type: "local", Bucket: "b"}.Check())
+}
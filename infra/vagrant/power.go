@@ -0,0 +1,146 @@
+package vagrant
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/gravitational/robotest/infra"
+
+	"github.com/gravitational/trace"
+)
+
+// IsNode returns true if node is a vagrant node
+func IsNode(n infra.Node) bool {
+	_, ok := n.(*node)
+	return ok
+}
+
+// PowerOn starts the shut off libvirt domain of the node with virsh
+func PowerOn(ctx context.Context, node infra.Node) error {
+	domain, err := domainOf(ctx, node)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	_, err = virsh(ctx, "start", domain)
+	return trace.Wrap(err)
+}
+
+// PowerOff shuts down the libvirt domain of the node and waits until it is shut off.
+// Unless graceful, the domain is stopped without shutting down the operating system
+func PowerOff(ctx context.Context, node infra.Node, graceful bool) error {
+	domain, err := domainOf(ctx, node)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	cmd := "destroy"
+	if graceful {
+		cmd = "shutdown"
+	}
+	if _, err := virsh(ctx, cmd, domain); err != nil {
+		return trace.Wrap(err)
+	}
+	for {
+		state, err := virsh(ctx, "domstate", domain)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		if strings.TrimSpace(string(state)) == "shut off" {
+			return nil
+		}
+		select {
+		case <-time.After(powerPollInterval):
+		case <-ctx.Done():
+			return trace.Wrap(ctx.Err())
+		}
+	}
+}
+
+// HardReset resets the libvirt domain of the node without shutting down the operating system
+func HardReset(ctx context.Context, node infra.Node) error {
+	domain, err := domainOf(ctx, node)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	_, err = virsh(ctx, "reset", domain)
+	return trace.Wrap(err)
+}
+
+// PublicIP returns the address the libvirt domain of the node leased on the vagrant network.
+// The lease is usually renewed with the same address after the domain is started again
+func PublicIP(ctx context.Context, node infra.Node) (string, error) {
+	domain, err := domainOf(ctx, node)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	out, err := virsh(ctx, "domifaddr", domain)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	addrs := parseDomainAddrs(out)
+	if len(addrs) == 0 {
+		return "", trace.NotFound("domain %v has no address", domain)
+	}
+	return addrs[0], nil
+}
+
+// domainOf returns the name of the libvirt domain of the node.
+// The domain is recorded when the nodes are discovered, otherwise (i.e. for the nodes
+// restored from state) it is looked up by the address of the running domain
+func domainOf(ctx context.Context, n infra.Node) (string, error) {
+	if node, ok := n.(*node); ok && node.domain != "" {
+		return node.domain, nil
+	}
+	out, err := virsh(ctx, "list", "--name")
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	for _, domain := range strings.Fields(string(out)) {
+		out, err := virsh(ctx, "domifaddr", domain)
+		if err != nil {
+			return "", trace.Wrap(err)
+		}
+		for _, addr := range parseDomainAddrs(out) {
+			if addr == n.Addr() {
+				return domain, nil
+			}
+		}
+	}
+	return "", trace.NotFound("failed to find libvirt domain of %v", n)
+}
+
+// parseDomainAddrs returns the addresses from the output of virsh domifaddr:
+//
+//	 Name       MAC address          Protocol     Address
+//	-------------------------------------------------------------------------------
+//	 vnet0      52:54:00:7b:24:2c    ipv4         192.168.121.57/24
+func parseDomainAddrs(out []byte) (addrs []string) {
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 4 {
+			continue
+		}
+		ip, _, err := net.ParseCIDR(fields[3])
+		if err != nil {
+			continue
+		}
+		addrs = append(addrs, ip.String())
+	}
+	return addrs
+}
+
+func virsh(ctx context.Context, args ...string) ([]byte, error) {
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, "virsh", args...)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		return nil, trace.Wrap(err, "virsh %v failed: %s", strings.Join(args, " "), out.Bytes())
+	}
+	return out.Bytes(), nil
+}
+
+const powerPollInterval = 2 * time.Second
@@ -129,8 +129,8 @@ func (r *vagrant) Client(addrIP string) (*ssh.Client, error) {
 	return node.Client()
 }
 
-func (r *vagrant) StartInstall(session *ssh.Session) error {
-	return session.Start(installerCommand)
+func (r *vagrant) InstallerCommand() (string, error) {
+	return installerCommand, nil
 }
 
 func (r *vagrant) UploadUpdate(session *ssh.Session) error {
@@ -215,7 +215,7 @@ func (r *vagrant) discoverNodes() ([]infra.Node, error) {
 		return nil, trace.Wrap(err, "failed to query SSH config: %s", out)
 	}
 
-	nodes, err := parseSSHConfig(out, r.getIPLibvirt)
+	nodes, err := parseSSHConfig(out, r.lookupLibvirt)
 	if err != nil {
 		return nil, trace.Wrap(err, "failed to parse SSH config")
 	}
@@ -223,14 +223,14 @@ func (r *vagrant) discoverNodes() ([]infra.Node, error) {
 	return nodes, nil
 }
 
-func (r *vagrant) getIPLibvirt(nodename string) (string, error) {
+// lookupLibvirt returns the public IP and the name of the libvirt domain of the node
+func (r *vagrant) lookupLibvirt(nodename string) (addr, domainName string, err error) {
 	var out bytes.Buffer
 	cmd := exec.Command("virsh", "list", "--name")
-	err := system.ExecL(cmd, io.MultiWriter(&out, r), r.Entry)
+	err = system.ExecL(cmd, io.MultiWriter(&out, r), r.Entry)
 	if err != nil {
-		return "", trace.Wrap(err, "failed to discover VM public IP: %s", out)
+		return "", "", trace.Wrap(err, "failed to discover VM public IP: %s", out)
 	}
-	var domainName string
 	for _, name := range strings.Split(out.String(), "\n") {
 		if strings.Contains(name, nodename) {
 			domainName = name
@@ -238,20 +238,20 @@ func (r *vagrant) getIPLibvirt(nodename string) (string, error) {
 		}
 	}
 	if domainName == "" {
-		return "", trace.NotFound("failed to find libvirt domain for node %q", nodename)
+		return "", "", trace.NotFound("failed to find libvirt domain for node %q", nodename)
 	}
 
 	out.Reset()
 	cmd = exec.Command("virsh", "dumpxml", domainName)
 	err = system.ExecL(cmd, io.MultiWriter(&out, r), r.Entry)
 	if err != nil {
-		return "", trace.Wrap(err, "failed to discover VM public IP: %s", out.Bytes())
+		return "", "", trace.Wrap(err, "failed to discover VM public IP: %s", out.Bytes())
 	}
 
 	var domain domain
 	err = xml.Unmarshal(out.Bytes(), &domain)
 	if err != nil {
-		return "", trace.Wrap(err, "failed to discover VM public IP: %s", out.Bytes())
+		return "", "", trace.Wrap(err, "failed to discover VM public IP: %s", out.Bytes())
 	}
 
 	var macAddr string
@@ -262,19 +262,19 @@ func (r *vagrant) getIPLibvirt(nodename string) (string, error) {
 		}
 	}
 	if macAddr == "" {
-		return "", trace.NotFound("failed to find MAC address for node %q", nodename)
+		return "", "", trace.NotFound("failed to find MAC address for node %q", nodename)
 	}
 
 	arpFile, err := os.Open("/proc/net/arp")
 	if err != nil {
-		return "", trace.Wrap(err, "failed to read arp table")
+		return "", "", trace.Wrap(err, "failed to read arp table")
 	}
 	defer arpFile.Close()
 
 	s := bufio.NewScanner(arpFile)
 	// Skip the header
 	if !s.Scan() {
-		return "", trace.Wrap(err, "failed to read arp table")
+		return "", "", trace.Wrap(err, "failed to read arp table")
 	}
 	for s.Scan() {
 		line := s.Text()
@@ -284,14 +284,14 @@ func (r *vagrant) getIPLibvirt(nodename string) (string, error) {
 			continue
 		}
 		if macAddr == fields[3] {
-			return fields[0], nil
+			return fields[0], domainName, nil
 		}
 	}
 	if err := s.Err(); err != nil {
-		return "", trace.Wrap(err, "failed to read arp table")
+		return "", "", trace.Wrap(err, "failed to read arp table")
 	}
 
-	return "", trace.NotFound("failed to find IP address for node %q", nodename)
+	return "", "", trace.NotFound("failed to find IP address for node %q", nodename)
 }
 
 func (r *vagrant) command(args []string, opts ...system.CommandOptionSetter) ([]byte, error) {
@@ -342,6 +342,24 @@ func (r *node) Client() (*ssh.Client, error) {
 	return sshutils.Client(fmt.Sprintf("%v:22", r.addrIP), "vagrant", signer)
 }
 
+// Disks returns the additional block devices of this node.
+// Additional disks are not supported with vagrant
+func (r *node) Disks() []infra.Disk {
+	return nil
+}
+
+// Subnet returns the subnet of this node.
+// Vagrant nodes share a single private network
+func (r *node) Subnet() string {
+	return ""
+}
+
+// PrivateAddrIPv6 returns the IPv6 address of this node.
+// IPv6 is not supported with vagrant
+func (r *node) PrivateAddrIPv6() string {
+	return ""
+}
+
 func (r node) String() string {
 	return fmt.Sprintf("node(addr=%v)", r.addrIP)
 }
@@ -350,7 +368,7 @@ func args(opts ...string) (result []string) {
 	return opts
 }
 
-func parseSSHConfig(config []byte, getIP func(string) (string, error)) (nodes []infra.Node, err error) {
+func parseSSHConfig(config []byte, lookup func(host string) (addr, domain string, err error)) (nodes []infra.Node, err error) {
 	s := bufio.NewScanner(bytes.NewReader(config))
 	var host string
 	// nodes maps node IP address to node
@@ -366,11 +384,11 @@ func parseSSHConfig(config []byte, getIP func(string) (string, error)) (nodes []
 			if err != nil {
 				identityFile = path
 			}
-			addrIP, err := getIP(host)
+			addrIP, domain, err := lookup(host)
 			if err != nil {
 				return nil, trace.Wrap(err, "failed to determine IP address of the host %q", host)
 			}
-			nodes = append(nodes, &node{addrIP: addrIP, identityFile: identityFile})
+			nodes = append(nodes, &node{addrIP: addrIP, identityFile: identityFile, domain: domain})
 		}
 	}
 	return nodes, nil
@@ -388,6 +406,8 @@ type vagrant struct {
 type node struct {
 	identityFile string
 	addrIP       string
+	// domain names the libvirt domain of the node, if known
+	domain string
 }
 
 type domain struct {
@@ -43,10 +43,10 @@ func TestParsesSSHConfig(t *testing.T) {
 			expected: []infra.Node{&node{identityFile: "/path/to/box/virtualbox/private_key", addrIP: "127.0.0.1"}},
 		},
 	}
-	getIP := func(host string) (string, error) { return "127.0.0.1", nil }
+	lookup := func(host string) (string, string, error) { return "127.0.0.1", "", nil }
 
 	for _, testCase := range testCases {
-		obtained, err := parseSSHConfig(testCase.config, getIP)
+		obtained, err := parseSSHConfig(testCase.config, lookup)
 		if err != nil {
 			t.Errorf("failed to parse SSH config: %v", err)
 		}
@@ -56,3 +56,16 @@ func TestParsesSSHConfig(t *testing.T) {
 		}
 	}
 }
+
+func TestParsesDomainAddrs(t *testing.T) {
+	out := []byte(` Name       MAC address          Protocol     Address
+-------------------------------------------------------------------------------
+ vnet0      52:54:00:7b:24:2c    ipv4         192.168.121.57/24
+ vnet1      52:54:00:3e:11:0a    ipv4         172.28.128.3/24
+
+`)
+	expected := []string{"192.168.121.57", "172.28.128.3"}
+	if obtained := parseDomainAddrs(out); !reflect.DeepEqual(obtained, expected) {
+		t.Errorf("expected %v but got %v", expected, obtained)
+	}
+}
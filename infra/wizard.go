@@ -5,121 +5,27 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net"
 	"net/url"
-	"os"
 	"regexp"
 	"strings"
 
-	"golang.org/x/crypto/ssh"
-
 	"github.com/gravitational/robotest/lib/loc"
-	"github.com/gravitational/robotest/lib/wait"
 	"github.com/gravitational/trace"
 
 	log "github.com/sirupsen/logrus"
 )
 
 func startWizard(provisioner Provisioner, installer Node) (cluster *wizardCluster, err error) {
-	var session *ssh.Session
-	err = wait.Retry(context.TODO(), func() error {
-		session, err = installer.Connect()
-		if err != nil {
-			log.Debug(trace.DebugReport(err))
-		}
-		return trace.Wrap(err)
-	})
-	if err != nil {
-		return nil, trace.Wrap(err)
-	}
-	defer func() {
-		if err == nil {
-			return
-		}
-		errClose := session.Close()
-		if errClose != nil {
-			log.Errorf("failed to close wizard SSH session: %v", errClose)
-		}
-	}()
-
-	var stdin io.WriteCloser
-	stdin, err = session.StdinPipe()
-	if err != nil {
-		return nil, trace.Wrap(err)
-	}
-	defer stdin.Close()
-
-	var stdout io.Reader
-	stdout, err = session.StdoutPipe()
-	if err != nil {
-		return nil, trace.Wrap(err)
-	}
-
-	reader, writer := io.Pipe()
-	go func() {
-		_, err := io.Copy(io.MultiWriter(os.Stdout, writer), stdout)
-		if err != nil {
-			log.Errorf("failed to read from remote stdout: %v", err)
-		}
-		reader.Close()
-		writer.Close()
-	}()
-	defer func() {
-		if err != nil {
-			reader.Close()
-			writer.Close()
-		}
-	}()
-
-	var stderr io.Reader
-	stderr, err = session.StderrPipe()
-	if err != nil {
+	process := newWizardProcess(provisioner, installer)
+	if err := process.start(); err != nil {
 		return nil, trace.Wrap(err)
 	}
-	go func() {
-		_, err := io.Copy(os.Stderr, stderr)
-		if err != nil {
-			log.Errorf("failed to read from remote stderr: %v", err)
-		}
-	}()
-
-	// launch the installer
-	log.Debugf("starting installer...")
-	err = provisioner.StartInstall(session)
-	if err != nil {
-		return nil, trace.Wrap(err)
-	}
-
-	var installerURL *url.URL
-	log.Debugf("configuring wizard...")
-	installerURL, err = configureWizard(reader, stdin, provisioner, installer)
-	if err != nil {
-		return nil, trace.Wrap(err)
-	}
-
-	if installerURL == nil {
-		err = trace.NotFound("failed to fetch installer URL. Check installer output for details.")
-		return nil, err
-	}
-
-	var application *loc.Locator
-	application, err = extractPackage(*installerURL)
-	if err != nil {
-		return nil, trace.Wrap(err)
-	}
-
-	// Discard all stdout content after the necessary wizard details have been obtained
-	go func() {
-		_, _ = io.Copy(ioutil.Discard, reader)
-	}()
-
 	// TODO: make sure that all io.Copy goroutines shutdown in Close
 	return &wizardCluster{
-		provisioner:  provisioner,
-		application:  *application,
-		installerURL: *installerURL,
-		session:      session,
+		provisioner: provisioner,
+		application: process.application,
+		process:     process,
 	}, nil
 }
 
@@ -220,7 +126,7 @@ const (
 )
 
 func (r *wizardCluster) Close() error {
-	return r.session.Close()
+	return r.process.Close()
 }
 
 func (r *wizardCluster) Destroy() error {
@@ -228,7 +134,7 @@ func (r *wizardCluster) Destroy() error {
 }
 
 func (r *wizardCluster) OpsCenterURL() string {
-	url := r.installerURL
+	url := r.process.InstallerURL()
 	url.RawQuery = ""
 	url.Path = ""
 	return url.String()
@@ -242,13 +148,16 @@ func (r *wizardCluster) Config() Config {
 	return r.config
 }
 
+func (r *wizardCluster) Process() *WizardProcess {
+	return r.process
+}
+
 // wizardCluster implements Infra
 type wizardCluster struct {
-	config       Config
-	provisioner  Provisioner
-	session      *ssh.Session
-	installerURL url.URL
-	application  loc.Locator
+	config      Config
+	provisioner Provisioner
+	process     *WizardProcess
+	application loc.Locator
 }
 
 var (
@@ -0,0 +1,326 @@
+package infra
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gravitational/robotest/lib/loc"
+	"github.com/gravitational/robotest/lib/utils"
+	"github.com/gravitational/robotest/lib/wait"
+
+	"github.com/gravitational/trace"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+)
+
+// WizardProcess controls the interactive installer process the wizard
+// runs on the installer node.
+//
+// The installer is started with the command returned by Provisioner.InstallerCommand
+// and its process ID is recorded on the node so the process can be monitored
+// and stopped independently of the SSH session it was started in
+type WizardProcess struct {
+	provisioner Provisioner
+	node        Node
+	// output retains the most recent lines of installer output
+	output *utils.LineBuffer
+
+	mu           sync.Mutex
+	session      *ssh.Session
+	exited       chan struct{}
+	installerURL url.URL
+	application  loc.Locator
+	restarts     int
+}
+
+func newWizardProcess(provisioner Provisioner, node Node) *WizardProcess {
+	return &WizardProcess{
+		provisioner: provisioner,
+		node:        node,
+		output:      utils.NewLineBuffer(wizardOutputLines),
+	}
+}
+
+// InstallerURL returns the URL of the installer as reported by the running process
+func (r *WizardProcess) InstallerURL() url.URL {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.installerURL
+}
+
+// Restarts returns the number of times the installer process has been restarted
+func (r *WizardProcess) Restarts() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.restarts
+}
+
+// Output returns the most recent lines of installer output, oldest first
+func (r *WizardProcess) Output() []string {
+	return r.output.Lines()
+}
+
+// Exited returns a channel that is closed when the SSH session
+// the installer has been started in terminates
+func (r *WizardProcess) Exited() <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.exited
+}
+
+// PID returns the ID of the installer process on the installer node
+func (r *WizardProcess) PID() (int, error) {
+	var out bytes.Buffer
+	err := Run(r.node, fmt.Sprintf("cat %v", wizardPIDFile), &out)
+	if err != nil {
+		return 0, trace.Wrap(err, "failed to read installer PID")
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(out.String()))
+	if err != nil {
+		return 0, trace.BadParameter("invalid installer PID %q", out.String())
+	}
+	return pid, nil
+}
+
+// Running returns true if the installer process is running on the installer node
+func (r *WizardProcess) Running() (bool, error) {
+	var out bytes.Buffer
+	cmd := fmt.Sprintf("test -f %[1]v && sudo kill -0 $(cat %[1]v) 2>/dev/null && echo running || echo stopped",
+		wizardPIDFile)
+	err := Run(r.node, cmd, &out)
+	if err != nil {
+		return false, trace.Wrap(err)
+	}
+	return strings.TrimSpace(out.String()) == "running", nil
+}
+
+// TailLog writes the last lines of the installer log file on the installer node to w
+func (r *WizardProcess) TailLog(w io.Writer, lines int) error {
+	return trace.Wrap(Run(r.node, fmt.Sprintf("tail -n %v %v", lines, r.provisioner.InstallerLogPath()), w))
+}
+
+// Stop terminates the installer process and all of its children
+// and waits for it to exit
+func (r *WizardProcess) Stop(ctx context.Context) error {
+	running, err := r.Running()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if running {
+		log.WithField("node", r.node).Info("Stop installer process.")
+		// The installer command is started as the leader of its own process group by sshd
+		cmd := fmt.Sprintf("sudo kill -TERM -- -$(cat %v)", wizardPIDFile)
+		if err := Run(r.node, cmd, ioutil.Discard); err != nil {
+			return trace.Wrap(err, "failed to stop installer")
+		}
+		err = wait.Retry(ctx, func() error {
+			running, err := r.Running()
+			if err != nil {
+				return trace.Wrap(err)
+			}
+			if running {
+				return wait.Continue("installer process is still running")
+			}
+			return nil
+		})
+		if err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return trace.Wrap(r.Close())
+}
+
+// Restart stops the installer process if it is still running and starts a new one
+func (r *WizardProcess) Restart(ctx context.Context) error {
+	if err := r.Stop(ctx); err != nil {
+		return trace.Wrap(err)
+	}
+	if err := r.start(); err != nil {
+		return trace.Wrap(err)
+	}
+	r.mu.Lock()
+	r.restarts++
+	r.mu.Unlock()
+	return nil
+}
+
+// EnsureRunning restarts the installer process if it is not running.
+// Returns true if the process has been restarted
+func (r *WizardProcess) EnsureRunning(ctx context.Context) (restarted bool, err error) {
+	running, err := r.Running()
+	if err != nil {
+		return false, trace.Wrap(err)
+	}
+	if running {
+		return false, nil
+	}
+	log.WithField("node", r.node).Warn("Installer process is not running, restart.")
+	if err := r.Restart(ctx); err != nil {
+		return false, trace.Wrap(err)
+	}
+	return true, nil
+}
+
+// Close closes the SSH session the installer has been started in
+func (r *WizardProcess) Close() error {
+	r.mu.Lock()
+	session := r.session
+	r.session = nil
+	r.mu.Unlock()
+	if session == nil {
+		return nil
+	}
+	return session.Close()
+}
+
+// start starts the installer process and configures it until
+// it reports the installer URL
+func (r *WizardProcess) start() (err error) {
+	command, err := r.provisioner.InstallerCommand()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	var session *ssh.Session
+	err = wait.Retry(context.TODO(), func() error {
+		session, err = r.node.Connect()
+		if err != nil {
+			log.Debug(trace.DebugReport(err))
+		}
+		return trace.Wrap(err)
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		errClose := session.Close()
+		if errClose != nil {
+			log.Errorf("failed to close wizard SSH session: %v", errClose)
+		}
+	}()
+
+	var stdin io.WriteCloser
+	stdin, err = session.StdinPipe()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer stdin.Close()
+
+	var stdout io.Reader
+	stdout, err = session.StdoutPipe()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		_, err := io.Copy(io.MultiWriter(os.Stdout, &lineWriter{buf: r.output}, writer), stdout)
+		if err != nil {
+			log.Errorf("failed to read from remote stdout: %v", err)
+		}
+		reader.Close()
+		writer.Close()
+	}()
+	defer func() {
+		if err != nil {
+			reader.Close()
+			writer.Close()
+		}
+	}()
+
+	var stderr io.Reader
+	stderr, err = session.StderrPipe()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	go func() {
+		_, err := io.Copy(io.MultiWriter(os.Stderr, &lineWriter{buf: r.output}), stderr)
+		if err != nil {
+			log.Errorf("failed to read from remote stderr: %v", err)
+		}
+	}()
+
+	// launch the installer recording the process ID of the shell
+	// that runs it
+	log.Debugf("starting installer...")
+	err = session.Start(fmt.Sprintf("echo $$ > %v; %v", wizardPIDFile, command))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		_ = session.Wait()
+		close(exited)
+	}()
+
+	var installerURL *url.URL
+	log.Debugf("configuring wizard...")
+	installerURL, err = configureWizard(reader, stdin, r.provisioner, r.node)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	if installerURL == nil {
+		err = trace.NotFound("failed to fetch installer URL. Check installer output for details.")
+		return err
+	}
+
+	var application *loc.Locator
+	application, err = extractPackage(*installerURL)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	// Discard all stdout content after the necessary wizard details have been obtained
+	go func() {
+		_, _ = io.Copy(ioutil.Discard, reader)
+	}()
+
+	r.mu.Lock()
+	r.session = session
+	r.exited = exited
+	r.installerURL = *installerURL
+	r.application = *application
+	r.mu.Unlock()
+	return nil
+}
+
+// lineWriter is an io.Writer that retains complete lines written to it
+type lineWriter struct {
+	buf     *utils.LineBuffer
+	partial []byte
+}
+
+// Write splits p into lines and adds complete lines to the buffer
+func (r *lineWriter) Write(p []byte) (int, error) {
+	data := append(r.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		r.buf.Add(strings.TrimRight(string(data[:i]), "\r"))
+		data = data[i+1:]
+	}
+	r.partial = append(r.partial[:0], data...)
+	return len(p), nil
+}
+
+const (
+	// wizardPIDFile specifies the path to the file on the installer node
+	// with the ID of the installer process
+	wizardPIDFile = "/tmp/robotest-wizard.pid"
+	// wizardOutputLines specifies the number of installer output lines to retain
+	wizardOutputLines = 200
+)
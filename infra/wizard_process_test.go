@@ -0,0 +1,19 @@
+package infra
+
+import (
+	"testing"
+
+	"github.com/gravitational/robotest/lib/utils"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineWriterRetainsCompleteLines(t *testing.T) {
+	w := &lineWriter{buf: utils.NewLineBuffer(2)}
+	for _, data := range []string{"first\r\nsec", "ond\n", "third\npartial"} {
+		n, err := w.Write([]byte(data))
+		assert.NoError(t, err)
+		assert.Equal(t, len(data), n)
+	}
+	assert.Equal(t, []string{"second", "third"}, w.buf.Lines())
+}
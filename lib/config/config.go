@@ -19,12 +19,15 @@ type ConfigFn func(param interface{}) (gravity.TestFunc, error)
 type entry struct {
 	fn       ConfigFn
 	defaults interface{}
+	labels   []string
 }
 
 // Entry is a pair of initialized test function and its parameters
 type Entry struct {
 	TestFunc gravity.TestFunc `json:"-"`
 	Param    interface{}
+	// Labels lists the labels of the test
+	Labels []string `json:",omitempty"`
 }
 
 type TestSet map[string]Entry
@@ -53,9 +56,10 @@ func New() *Config {
 	return &Config{map[string]entry{}}
 }
 
-// Add adds new entry to configuration
-func (c *Config) Add(key string, fn ConfigFn, defaults interface{}) {
-	c.entries[key] = entry{fn, defaults}
+// Add adds new entry to configuration.
+// Labels (i.e. upgrade, resilience, slow) can be used to select tests with Filter
+func (c *Config) Add(key string, fn ConfigFn, defaults interface{}, labels ...string) {
+	c.entries[key] = entry{fn, defaults, labels}
 }
 
 // Parse will take list of function=JSON, base config map, and return list of initialized test functions to run
@@ -81,13 +85,22 @@ func (c *Config) Parse(args []string) (fns TestSet, err error) {
 			continue
 		}
 
-		e, err := makeFunction(entry.fn, data, entry.defaults)
+		instances, err := expandMatrix(data)
 		if err != nil {
 			errs = append(errs, trace.Errorf("%s : %v", key, err))
 			continue
 		}
 
-		fns.add(key, *e)
+		for _, data := range instances {
+			e, err := makeFunction(entry.fn, data, entry.defaults)
+			if err != nil {
+				errs = append(errs, trace.Errorf("%s : %v", key, err))
+				continue
+			}
+			e.Labels = entry.labels
+
+			fns.add(key, *e)
+		}
 	}
 
 	if len(errs) != 0 {
@@ -97,6 +110,22 @@ func (c *Config) Parse(args []string) (fns TestSet, err error) {
 	return fns, nil
 }
 
+// Filter returns the tests whose labels match the focus expression
+// and do not match the skip expression. Either expression is optional
+func (t TestSet) Filter(focus, skip LabelExpr) TestSet {
+	out := TestSet{}
+	for key, e := range t {
+		if focus != nil && !focus.Matches(e.Labels) {
+			continue
+		}
+		if skip != nil && skip.Matches(e.Labels) {
+			continue
+		}
+		out[key] = e
+	}
+	return out
+}
+
 var withArgs = regexp.MustCompile(`^(\S+)=(.+)$`)
 
 func makeFunction(fn ConfigFn, data string, defaults interface{}) (*Entry, error) {
@@ -116,7 +145,7 @@ func makeFunction(fn ConfigFn, data string, defaults interface{}) (*Entry, error
 		return nil, trace.Wrap(err)
 	}
 
-	return &Entry{testFn, param}, nil
+	return &Entry{TestFunc: testFn, Param: param}, nil
 }
 
 // parseJSON parses JSON data using defaults object
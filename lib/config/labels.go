@@ -0,0 +1,138 @@
+package config
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/gravitational/trace"
+)
+
+// LabelExpr is a boolean expression over test labels, i.e.
+//
+//	upgrade && !slow
+//	(resilience || upgrade) && !aws-only
+//
+// A label evaluates to true if the test has it.
+// Operators are ! (not), && (and) and || (or) in the order of precedence
+type LabelExpr interface {
+	// Matches evaluates the expression against the labels of a test
+	Matches(labels []string) bool
+}
+
+// ParseLabelExpr parses the label expression
+func ParseLabelExpr(expr string) (LabelExpr, error) {
+	tokens := labelToken.FindAllString(expr, -1)
+	if strings.Join(tokens, "") != strings.Join(strings.Fields(expr), "") {
+		return nil, trace.BadParameter("invalid characters in label expression %q", expr)
+	}
+	p := &labelParser{tokens: tokens}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, trace.Wrap(err, "invalid label expression %q", expr)
+	}
+	if p.pos != len(p.tokens) {
+		return nil, trace.BadParameter("unexpected %q in label expression %q", p.tokens[p.pos], expr)
+	}
+	return e, nil
+}
+
+var labelToken = regexp.MustCompile(`&&|\|\||!|\(|\)|[A-Za-z0-9_:.-]+`)
+
+type labelParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *labelParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *labelParser) parseOr() (LabelExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	for p.peek() == "||" {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		left = orExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *labelParser) parseAnd() (LabelExpr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	for p.peek() == "&&" {
+		p.pos++
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		left = andExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *labelParser) parseNot() (LabelExpr, error) {
+	switch token := p.peek(); token {
+	case "!":
+		p.pos++
+		e, err := p.parseNot()
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		return notExpr{e}, nil
+	case "(":
+		p.pos++
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		if p.peek() != ")" {
+			return nil, trace.BadParameter("missing closing parenthesis")
+		}
+		p.pos++
+		return e, nil
+	case "", ")", "&&", "||":
+		return nil, trace.BadParameter("expected a label, got %q", token)
+	default:
+		p.pos++
+		return labelExpr(token), nil
+	}
+}
+
+type labelExpr string
+
+func (r labelExpr) Matches(labels []string) bool {
+	for _, label := range labels {
+		if label == string(r) {
+			return true
+		}
+	}
+	return false
+}
+
+type notExpr struct{ e LabelExpr }
+
+func (r notExpr) Matches(labels []string) bool { return !r.e.Matches(labels) }
+
+type andExpr struct{ left, right LabelExpr }
+
+func (r andExpr) Matches(labels []string) bool {
+	return r.left.Matches(labels) && r.right.Matches(labels)
+}
+
+type orExpr struct{ left, right LabelExpr }
+
+func (r orExpr) Matches(labels []string) bool {
+	return r.left.Matches(labels) || r.right.Matches(labels)
+}
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLabelExpr(t *testing.T) {
+	labels := []string{"upgrade", "slow"}
+	var testCases = []struct {
+		expr    string
+		matches bool
+	}{
+		{"upgrade", true},
+		{"!slow", false},
+		{"upgrade && !slow", false},
+		{"resilience || upgrade", true},
+		{"!(resilience || aws-only) && slow", true},
+		{"resilience || upgrade && !slow", false},
+	}
+	for _, tc := range testCases {
+		e, err := ParseLabelExpr(tc.expr)
+		require.NoError(t, err, tc.expr)
+		assert.Equal(t, tc.matches, e.Matches(labels), tc.expr)
+	}
+
+	for _, expr := range []string{"", "upgrade &&", "(upgrade", "upgrade slow", "upgrade & slow"} {
+		_, err := ParseLabelExpr(expr)
+		assert.Error(t, err, expr)
+	}
+}
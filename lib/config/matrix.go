@@ -0,0 +1,130 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+
+	"github.com/gravitational/trace"
+)
+
+// matrix declares the axes of a test matrix in the test parameters:
+//
+//	install={"nodes":1,"matrix":{"axes":{"os":["ubuntu:16","centos:7"],"storage_driver":["overlay2","devicemapper"]},
+//	  "exclude":[{"os":"centos:7","storage_driver":"overlay2"}]}}
+//
+// Each axis is a parameter field with the list of values to test with.
+// The test is expanded into an instance per combination of axis values (the cross-product)
+// except for the combinations matching any of the exclusions
+type matrix struct {
+	// Axes maps parameter fields to the values to test
+	Axes map[string][]json.RawMessage `json:"axes"`
+	// Exclude lists partial combinations of axis values to skip
+	Exclude []map[string]json.RawMessage `json:"exclude"`
+}
+
+// expandMatrix expands the JSON test parameters with an optional matrix
+// into parameters for each matrix combination.
+// Parameters without a matrix are returned as-is
+func expandMatrix(data string) ([]string, error) {
+	if data == "" {
+		return []string{data}, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(data), &fields); err != nil {
+		// Leave the decoding errors to parseJSON which reports them in terms of
+		// the test parameters
+		return []string{data}, nil
+	}
+	matrixData, ok := fields[matrixField]
+	if !ok {
+		return []string{data}, nil
+	}
+	delete(fields, matrixField)
+
+	var m matrix
+	if err := json.Unmarshal(matrixData, &m); err != nil {
+		return nil, trace.Wrap(err, "invalid matrix %s", matrixData)
+	}
+	if len(m.Axes) == 0 {
+		return nil, trace.BadParameter("matrix requires at least one axis")
+	}
+
+	axes := make([]string, 0, len(m.Axes))
+	for axis, values := range m.Axes {
+		if len(values) == 0 {
+			return nil, trace.BadParameter("matrix axis %q has no values", axis)
+		}
+		axes = append(axes, axis)
+	}
+	sort.Strings(axes)
+
+	var result []string
+	for _, combination := range m.combinations(axes) {
+		if m.excluded(combination) {
+			continue
+		}
+		for axis, value := range combination {
+			fields[axis] = value
+		}
+		out, err := json.Marshal(fields)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		result = append(result, string(out))
+	}
+	if len(result) == 0 {
+		return nil, trace.BadParameter("all matrix combinations are excluded")
+	}
+	return result, nil
+}
+
+// combinations returns the cross-product of axis values in axes order
+func (r matrix) combinations(axes []string) []map[string]json.RawMessage {
+	combinations := []map[string]json.RawMessage{{}}
+	for _, axis := range axes {
+		var next []map[string]json.RawMessage
+		for _, combination := range combinations {
+			for _, value := range r.Axes[axis] {
+				c := make(map[string]json.RawMessage, len(combination)+1)
+				for k, v := range combination {
+					c[k] = v
+				}
+				c[axis] = value
+				next = append(next, c)
+			}
+		}
+		combinations = next
+	}
+	return combinations
+}
+
+// excluded returns true if combination matches any of the exclusions
+func (r matrix) excluded(combination map[string]json.RawMessage) bool {
+	for _, exclusion := range r.Exclude {
+		if matches(combination, exclusion) {
+			return true
+		}
+	}
+	return false
+}
+
+func matches(combination, exclusion map[string]json.RawMessage) bool {
+	for axis, value := range exclusion {
+		if !jsonEqual(combination[axis], value) {
+			return false
+		}
+	}
+	return true
+}
+
+func jsonEqual(a, b json.RawMessage) bool {
+	var bufA, bufB bytes.Buffer
+	if json.Compact(&bufA, a) != nil || json.Compact(&bufB, b) != nil {
+		return false
+	}
+	return bytes.Equal(bufA.Bytes(), bufB.Bytes())
+}
+
+// matrixField is the name of the test parameter field declaring the matrix
+const matrixField = "matrix"
@@ -0,0 +1,28 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandMatrix(t *testing.T) {
+	data := `{"nodes":1,"matrix":{"axes":{"os":["ubuntu:16","centos:7"],"storage_driver":["overlay2","devicemapper"]},` +
+		`"exclude":[{"os":"centos:7","storage_driver":"overlay2"}]}}`
+
+	instances, err := expandMatrix(data)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		`{"nodes":1,"os":"ubuntu:16","storage_driver":"overlay2"}`,
+		`{"nodes":1,"os":"ubuntu:16","storage_driver":"devicemapper"}`,
+		`{"nodes":1,"os":"centos:7","storage_driver":"devicemapper"}`,
+	}, instances)
+
+	instances, err = expandMatrix(`{"nodes":1}`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"nodes":1}`}, instances)
+
+	_, err = expandMatrix(`{"matrix":{"axes":{"os":["ubuntu:16"]},"exclude":[{"os":"ubuntu:16"}]}}`)
+	assert.Error(t, err)
+}
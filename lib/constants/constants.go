@@ -57,3 +57,10 @@ const (
 	// Ops specifies a special cloud provider - a telekube Ops Center
 	Ops = "ops"
 )
+
+const (
+	// ArchAMD64 is the x86-64 CPU architecture
+	ArchAMD64 = "amd64"
+	// ArchARM64 is the 64-bit ARM CPU architecture
+	ArchARM64 = "arm64"
+)
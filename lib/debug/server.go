@@ -0,0 +1,26 @@
+package debug
+
+import (
+	"net/http"
+	"net/http/pprof"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// StartServer starts the debug HTTP server on httpEndpoint serving the profiling
+// endpoints under /debug/pprof/ and handler for everything else
+func StartServer(httpEndpoint string, handler http.Handler) {
+	log.Infof("[DEBUG] http %v", httpEndpoint)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/", handler)
+
+	go func() {
+		log.Println(http.ListenAndServe(httpEndpoint, mux))
+	}()
+}
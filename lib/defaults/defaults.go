@@ -20,6 +20,15 @@ const (
 	RetryMaxDelay = time.Minute
 	// RetryAttempts defines the maximum number of retry attempts
 	RetryAttempts = 100
+	// RetryJitter defines the fraction of the retry interval to randomize it by
+	RetryJitter = 0.2
+
+	// OperationPollDelay defines the initial interval between operation status checks
+	OperationPollDelay = 5 * time.Second
+	// OperationPollMaxDelay defines the maximum interval between operation status checks
+	OperationPollMaxDelay = 30 * time.Second
+	// OperationWaitBudget defines the maximum time to wait for an operation to complete
+	OperationWaitBudget = 6 * time.Hour
 
 	// SSHConnectTimeout defines the timeout for establishing an SSH connection
 	SSHConnectTimeout = 30 * time.Second
@@ -62,4 +71,21 @@ const (
 
 	// TmpDir is temporary file folder
 	TmpDir = "/tmp"
+
+	// InstallTimeout specifies the time allotted for install per node
+	InstallTimeout = 15 * time.Minute
+	// JoinTimeout specifies the time allotted for joining the cluster per node
+	JoinTimeout = 15 * time.Minute
+	// UpgradeTimeout specifies the time allotted for upgrade per node
+	UpgradeTimeout = 30 * time.Minute
+	// StatusTimeout specifies the time allotted for cluster status checks,
+	// sufficient for failover procedures
+	StatusTimeout = 30 * time.Minute
+	// LeaderElectionTimeout specifies the time allotted for the cluster
+	// to elect a new leader
+	LeaderElectionTimeout = 30 * time.Minute
+	// TeardownTimeout specifies the time allotted for destroying the infrastructure
+	TeardownTimeout = 20 * time.Minute
+	// WizardRestartTimeout specifies the time allotted for restarting the wizard installer
+	WizardRestartTimeout = 10 * time.Minute
 )
@@ -0,0 +1,171 @@
+// Package failure categorizes errors by their origin so that test reports
+// can tell infrastructure failures (cloud provisioning, SSH connectivity)
+// apart from genuine product regressions and test assertions.
+package failure
+
+import (
+	"context"
+	"net"
+
+	"github.com/gravitational/trace"
+	"golang.org/x/crypto/ssh"
+)
+
+// Category names the origin of a failure
+type Category string
+
+const (
+	// CategoryProvisioning is a failure to provision the test infrastructure
+	CategoryProvisioning Category = "provisioning"
+	// CategorySSHTransport is a failure to communicate with a node over SSH
+	CategorySSHTransport Category = "ssh_transport"
+	// CategoryGravityOperation is a failure of a gravity command or operation
+	CategoryGravityOperation Category = "gravity_operation"
+	// CategoryAssertion is a failed test expectation
+	CategoryAssertion Category = "assertion"
+	// CategoryUnknown is a failure of unknown origin
+	CategoryUnknown Category = "unknown"
+)
+
+// IsInfrastructure returns true if the category describes a failure of the
+// test infrastructure rather than of the product under test
+func (r Category) IsInfrastructure() bool {
+	return r == CategoryProvisioning || r == CategorySSHTransport
+}
+
+// IsProduct returns true if the category describes a failure of the product under test
+func (r Category) IsProduct() bool {
+	return r == CategoryGravityOperation || r == CategoryAssertion
+}
+
+// ProvisioningError is a failure to provision the test infrastructure
+type ProvisioningError struct {
+	Err error
+}
+
+// Error implements error
+func (r *ProvisioningError) Error() string { return r.Err.Error() }
+
+// Category returns the failure category
+func (r *ProvisioningError) Category() Category { return CategoryProvisioning }
+
+// SSHTransportError is a failure to connect to a node or to establish
+// an SSH session, as opposed to a command completing with an error
+type SSHTransportError struct {
+	Err error
+}
+
+// Error implements error
+func (r *SSHTransportError) Error() string { return r.Err.Error() }
+
+// Category returns the failure category
+func (r *SSHTransportError) Category() Category { return CategorySSHTransport }
+
+// GravityOperationError is a failure of a gravity command or cluster operation
+type GravityOperationError struct {
+	Err error
+}
+
+// Error implements error
+func (r *GravityOperationError) Error() string { return r.Err.Error() }
+
+// Category returns the failure category
+func (r *GravityOperationError) Category() Category { return CategoryGravityOperation }
+
+// AssertionError is a failed test expectation
+type AssertionError struct {
+	Err error
+}
+
+// Error implements error
+func (r *AssertionError) Error() string { return r.Err.Error() }
+
+// Category returns the failure category
+func (r *AssertionError) Category() Category { return CategoryAssertion }
+
+// Provisioning marks err as a provisioning failure.
+// Errors that have already been categorized are returned as-is
+func Provisioning(err error) error {
+	return categorize(err, func(err error) error { return &ProvisioningError{Err: err} })
+}
+
+// SSHTransport marks err as an SSH transport failure.
+// Errors that have already been categorized are returned as-is
+func SSHTransport(err error) error {
+	return categorize(err, func(err error) error { return &SSHTransportError{Err: err} })
+}
+
+// GravityOperation marks err as a gravity operation failure.
+// Errors that have already been categorized are returned as-is
+func GravityOperation(err error) error {
+	return categorize(err, func(err error) error { return &GravityOperationError{Err: err} })
+}
+
+// Assertion marks err as a failed test expectation.
+// Errors that have already been categorized are returned as-is
+func Assertion(err error) error {
+	return categorize(err, func(err error) error { return &AssertionError{Err: err} })
+}
+
+// CategoryOf returns the category of the specified error.
+// Besides the explicitly categorized errors, it recognizes SSH sessions terminated
+// without an exit status and network errors as SSH transport failures.
+// Returns an empty category for nil errors and CategoryUnknown for errors
+// of unknown origin
+func CategoryOf(err error) Category {
+	if err == nil {
+		return ""
+	}
+	if category := categoryOf(err); category != "" {
+		return category
+	}
+	return CategoryUnknown
+}
+
+func categoryOf(err error) Category {
+	for i := 0; i < maxHops && err != nil; i++ {
+		// context errors implement net.Error but mean that the operation
+		// timed out or was cancelled rather than that the transport failed
+		if err == context.DeadlineExceeded || err == context.Canceled {
+			return ""
+		}
+		switch e := err.(type) {
+		case categorizedError:
+			return e.Category()
+		case trace.Aggregate:
+			for _, err := range e.Errors() {
+				if category := categoryOf(err); category != "" {
+					return category
+				}
+			}
+			return ""
+		case *ssh.ExitMissingError, net.Error:
+			return CategorySSHTransport
+		case trace.Error:
+			if e.OrigError() == err {
+				return ""
+			}
+			err = e.OrigError()
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+func categorize(err error, wrap func(error) error) error {
+	if err == nil {
+		return nil
+	}
+	if categoryOf(err) != "" {
+		return err
+	}
+	return trace.Wrap(wrap(err))
+}
+
+type categorizedError interface {
+	Category() Category
+}
+
+// maxHops limits the depth of the error chain to inspect
+const maxHops = 50
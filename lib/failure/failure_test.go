@@ -0,0 +1,82 @@
+package failure
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gravitational/trace"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestCategoryOf(t *testing.T) {
+	var testCases = []struct {
+		err      error
+		category Category
+		comment  string
+	}{
+		{err: nil, category: "", comment: "no error"},
+		{err: fmt.Errorf("failure"), category: CategoryUnknown, comment: "uncategorized error"},
+		{err: trace.Wrap(Provisioning(fmt.Errorf("quota exceeded")), "terraform"), category: CategoryProvisioning, comment: "wrapped provisioning error"},
+		{err: GravityOperation(SSHTransport(fmt.Errorf("connection refused"))), category: CategorySSHTransport, comment: "first category wins"},
+		{err: trace.Wrap(&ssh.ExitMissingError{}), category: CategorySSHTransport, comment: "session terminated without exit status"},
+		{err: trace.NewAggregate(fmt.Errorf("failure"), Assertion(fmt.Errorf("no leader"))), category: CategoryAssertion, comment: "categorized error in aggregate"},
+		{err: trace.Wrap(context.DeadlineExceeded), category: CategoryUnknown, comment: "timeout is not a transport failure"},
+		{err: &net.OpError{Op: "dial", Err: fmt.Errorf("connection refused")}, category: CategorySSHTransport, comment: "network error"},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.category, CategoryOf(tc.err), tc.comment)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	var testCases = []struct {
+		message  string
+		expected string
+	}{
+		{
+			message:  "failed to join 10.0.2.15:61009 after 1m30.5s",
+			expected: "failed to join <ip> after <duration>",
+		},
+		{
+			message:  "2019-10-27T02:02:29Z operation 5d3f0b0a-1cd5-4e8e-9b7b-2c0b6e0a4b1e failed",
+			expected: "<time> operation <uuid> failed",
+		},
+		{
+			message:  "node robotest-a1b2-install-1-node-0 [fd00::1]:3022 exit status 255,\n\tcontainer 3f4e5d6c7b8a",
+			expected: "node <run>-node-<n> <ip> exit status <n>, container <hex>",
+		},
+		{
+			message:  "std::vector at fe80::1ff:fe23:4567:890a and 2001:db8:0:0:0:0:2:1",
+			expected: "std::vector at <ip> and <ip>",
+		},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, Normalize(tc.message, "robotest-a1b2-install-1"), tc.message)
+	}
+	assert.Equal(t, Signature(CategoryAssertion, "no leader on 10.0.0.1", "run-1"),
+		Signature(CategoryAssertion, "no leader on 10.0.0.2", "run-2"))
+	assert.NotEqual(t, Signature(CategoryAssertion, "no leader on 10.0.0.1"),
+		Signature(CategoryGravityOperation, "no leader on 10.0.0.1"))
+}
+
+func TestSignatureDB(t *testing.T) {
+	db, err := NewSignatureDB([]KnownSignature{
+		{Signature: "abc", Description: "flannel race"},
+		{Pattern: "^etcd cluster is unavailable", Description: "etcd", Issue: "https://github.com/gravitational/gravity/issues/1"},
+		{Signature: "ghi", Description: "expired", Ticket: "GRAV-1", Expires: time.Now().Add(-time.Hour)},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "flannel race", db.Match("abc", "anything").Description)
+	assert.Equal(t, "etcd", db.Match("def", "etcd cluster is unavailable on <ip>").Description)
+	assert.Nil(t, db.Match("def", "no leader"))
+	assert.Nil(t, db.Match("ghi", "anything"), "expired")
+	assert.False(t, db.Match("abc", "anything").Acknowledged())
+	assert.True(t, KnownSignature{Ticket: "GRAV-1"}.Acknowledged())
+
+	_, err = NewSignatureDB([]KnownSignature{{Description: "neither"}})
+	assert.Error(t, err)
+}
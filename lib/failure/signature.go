@@ -0,0 +1,152 @@
+package failure
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gravitational/trace"
+	"gopkg.in/yaml.v2"
+)
+
+// Normalize strips the parts of the error message that differ between runs of the same failure:
+// IP addresses, timestamps, UUIDs, hexadecimal identifiers, durations, numbers and the specified
+// run identifiers (i.e. the test tags), and collapses the whitespace
+func Normalize(message string, runIDs ...string) string {
+	for _, id := range runIDs {
+		if id != "" {
+			message = strings.Replace(message, id, "<run>", -1)
+		}
+	}
+	for _, r := range normalizers {
+		message = r.pattern.ReplaceAllString(message, r.replacement)
+	}
+	return strings.Join(strings.Fields(message), " ")
+}
+
+// Signature returns the signature of the failure of the category with the message:
+// the hash of the category and the normalized message, see Normalize
+func Signature(category Category, message string, runIDs ...string) string {
+	if message == "" {
+		return ""
+	}
+	hash := sha256.Sum256([]byte(string(category) + "\n" + Normalize(message, runIDs...)))
+	return hex.EncodeToString(hash[:])[:16]
+}
+
+// KnownSignature describes a known failure in the signature database
+type KnownSignature struct {
+	// Signature is the signature of the failure, see Signature
+	Signature string `json:"signature,omitempty" yaml:"signature,omitempty"`
+	// Pattern optionally matches the normalized message of the failure instead of the signature
+	Pattern string `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	// Description describes the failure
+	Description string `json:"description" yaml:"description"`
+	// Issue optionally links the issue of the failure
+	Issue string `json:"issue,omitempty" yaml:"issue,omitempty"`
+	// Ticket optionally names the ticket of the failure, i.e. PROJ-123
+	Ticket string `json:"ticket,omitempty" yaml:"ticket,omitempty"`
+	// Expires optionally limits the time the failure is known for,
+	// so that a failure does not stay acknowledged after its fix was due
+	Expires time.Time `json:"expires,omitempty" yaml:"expires,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// Expired returns true if the failure is no longer known at now
+func (r KnownSignature) Expired(now time.Time) bool {
+	return !r.Expires.IsZero() && now.After(r.Expires)
+}
+
+// Acknowledged returns true if the failure is tracked with a ticket.
+// Only acknowledged failures keep the tests failing with them from failing the suite,
+// the other known failures only annotate the reports
+func (r KnownSignature) Acknowledged() bool {
+	return r.Ticket != ""
+}
+
+// SignatureDB is the database of known failures
+type SignatureDB struct {
+	signatures []KnownSignature
+}
+
+// NewSignatureDB returns the database of the known failures
+func NewSignatureDB(signatures []KnownSignature) (*SignatureDB, error) {
+	db := &SignatureDB{}
+	for _, known := range signatures {
+		if known.Signature == "" && known.Pattern == "" {
+			return nil, trace.BadParameter("known failure %q needs a signature or a pattern", known.Description)
+		}
+		if known.Pattern != "" {
+			pattern, err := regexp.Compile(known.Pattern)
+			if err != nil {
+				return nil, trace.BadParameter("invalid pattern of known failure %q: %v", known.Description, err)
+			}
+			known.pattern = pattern
+		}
+		db.signatures = append(db.signatures, known)
+	}
+	return db, nil
+}
+
+// LoadSignatureDB reads the database of the known failures from the YAML (or JSON) file
+// with a list of known failures
+func LoadSignatureDB(path string) (*SignatureDB, error) {
+	signatures, err := ReadSignatures(path)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	db, err := NewSignatureDB(signatures)
+	return db, trace.Wrap(err, "invalid signature database %v", path)
+}
+
+// ReadSignatures reads the list of known failures from the YAML (or JSON) file
+func ReadSignatures(path string) ([]KnownSignature, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, trace.ConvertSystemError(err)
+	}
+	var signatures []KnownSignature
+	if err := yaml.Unmarshal(data, &signatures); err != nil {
+		return nil, trace.BadParameter("invalid signature database %v: %v", path, err)
+	}
+	return signatures, nil
+}
+
+// Match returns the known failure with the signature or matching the normalized message, if any.
+// Expired failures are not matched
+func (r *SignatureDB) Match(signature, normalized string) *KnownSignature {
+	if r == nil || signature == "" {
+		return nil
+	}
+	now := time.Now()
+	for i, known := range r.signatures {
+		if known.Expired(now) {
+			continue
+		}
+		if known.Signature == signature || (known.pattern != nil && known.pattern.MatchString(normalized)) {
+			return &r.signatures[i]
+		}
+	}
+	return nil
+}
+
+type normalizer struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// normalizers are applied in order, the more specific patterns first
+var normalizers = []normalizer{
+	{regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`), "<uuid>"},
+	{regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})?( [A-Z]{3,4})?`), "<time>"},
+	{regexp.MustCompile(`\b\d{2}:\d{2}:\d{2}(\.\d+)?\b`), "<time>"},
+	{regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}(:\d+)?\b`), "<ip>"},
+	{regexp.MustCompile(`\[?\b((?:[0-9a-fA-F]{1,4}:){2,7}[0-9a-fA-F]{1,4}|(?:[0-9a-fA-F]{1,4}:)+:(?:[0-9a-fA-F]{1,4}(?::[0-9a-fA-F]{1,4})*)?)\]?(:\d+)?`), "<ip>"},
+	{regexp.MustCompile(`\b(\d+(\.\d+)?(h|ms|µs|us|ns|m|s))+\b`), "<duration>"},
+	{regexp.MustCompile(`\b[0-9a-f]{8,}\b`), "<hex>"},
+	{regexp.MustCompile(`\b\d+\b`), "<n>"},
+}
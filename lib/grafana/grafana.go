@@ -0,0 +1,100 @@
+// Package grafana emits annotations via the Grafana HTTP API so that the events
+// of a test run (i.e. an injected fault) can be read in context on the monitoring graphs.
+package grafana
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gravitational/trace"
+)
+
+// Client creates annotations in Grafana
+type Client struct {
+	client *http.Client
+	url    string
+	token  string
+}
+
+// Annotation is an event shown on the graphs
+type Annotation struct {
+	// Time is the time of the event
+	Time time.Time
+	// TimeEnd optionally makes the annotation a region ending at TimeEnd
+	TimeEnd time.Time
+	// Tags lists the tags to filter the annotations by in the dashboards
+	Tags []string
+	// Text describes the event
+	Text string
+}
+
+// New returns a client of the Grafana at url authenticated with the API token, if not empty
+func New(url, token string) (*Client, error) {
+	if url == "" {
+		return nil, trace.BadParameter("Grafana URL is required")
+	}
+	return &Client{
+		client: &http.Client{Timeout: requestTimeout},
+		url:    strings.TrimSuffix(url, "/"),
+		token:  token,
+	}, nil
+}
+
+// Annotate creates the annotation
+func (r *Client) Annotate(ctx context.Context, annotation Annotation) error {
+	data, err := json.Marshal(annotation.request())
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	req, err := http.NewRequest(http.MethodPost, r.url+"/api/annotations", bytes.NewReader(data))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+	resp, err := r.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	defer resp.Body.Close()
+	out, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return trace.BadParameter("failed to create annotation: %v %s", resp.Status, out)
+	}
+	return nil
+}
+
+func (r Annotation) request() annotationRequest {
+	req := annotationRequest{
+		Time: toMillis(r.Time),
+		Tags: r.Tags,
+		Text: r.Text,
+	}
+	if !r.TimeEnd.IsZero() {
+		req.TimeEnd = toMillis(r.TimeEnd)
+	}
+	return req
+}
+
+// annotationRequest is the body of the annotation request, see
+// https://grafana.com/docs/grafana/latest/http_api/annotations/
+type annotationRequest struct {
+	Time    int64    `json:"time"`
+	TimeEnd int64    `json:"timeEnd,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+	Text    string   `json:"text"`
+}
+
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// requestTimeout is the timeout of a single request to Grafana
+const requestTimeout = 10 * time.Second
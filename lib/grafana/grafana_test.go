@@ -0,0 +1,43 @@
+package grafana
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnnotate(t *testing.T) {
+	var got annotationRequest
+	var path, auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth = r.URL.Path, r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL+"/", "secret")
+	require.NoError(t, err)
+	start := time.Unix(1500000000, 0)
+	err = client.Annotate(context.Background(), Annotation{
+		Time:    start,
+		TimeEnd: start.Add(time.Minute),
+		Tags:    []string{"robotest", "fault"},
+		Text:    "Partition 10.0.0.1.",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "/api/annotations", path)
+	require.Equal(t, "Bearer secret", auth)
+	require.Equal(t, annotationRequest{
+		Time:    1500000000000,
+		TimeEnd: 1500000060000,
+		Tags:    []string{"robotest", "fault"},
+		Text:    "Partition 10.0.0.1.",
+	}, got)
+}
@@ -0,0 +1,87 @@
+package issues
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gravitational/trace"
+)
+
+// GitHubConfig configures the GitHub tracker
+type GitHubConfig struct {
+	// Repository is the repository to file the issues in, as owner/name
+	Repository string
+	// Token is the API token
+	Token string
+	// Labels lists the labels of the new issues
+	Labels []string
+	// URL is the API URL, defaults to https://api.github.com
+	URL string
+}
+
+// NewGitHub returns the tracker filing the issues in the GitHub repository.
+// The signature marker is added to the body of the issues to find them by
+func NewGitHub(config GitHubConfig) (*GitHub, error) {
+	if len(strings.Split(config.Repository, "/")) != 2 {
+		return nil, trace.BadParameter("expected the repository as owner/name, got %q", config.Repository)
+	}
+	if config.Token == "" {
+		return nil, trace.BadParameter("GitHub token is required")
+	}
+	if config.URL == "" {
+		config.URL = "https://api.github.com"
+	}
+	config.URL = strings.TrimSuffix(config.URL, "/")
+	return &GitHub{config: config}, nil
+}
+
+// GitHub files issues in a GitHub repository
+type GitHub struct {
+	config GitHubConfig
+}
+
+// File files the issue, or comments on the open issue with the same signature
+func (r *GitHub) File(ctx context.Context, issue Issue) (issueURL string, created bool, err error) {
+	marker := Marker(issue.Signature)
+	query := fmt.Sprintf(`repo:%v is:issue is:open in:body "%v"`, r.config.Repository, marker)
+	var found struct {
+		Items []githubIssue `json:"items"`
+	}
+	err = r.do(ctx, http.MethodGet, "/search/issues?q="+url.QueryEscape(query), nil, &found)
+	if err != nil {
+		return "", false, trace.Wrap(err, "failed to search issues")
+	}
+	if len(found.Items) != 0 {
+		existing := found.Items[0]
+		err = r.do(ctx, http.MethodPost, fmt.Sprintf("/repos/%v/issues/%v/comments", r.config.Repository, existing.Number),
+			map[string]string{"body": issue.Body}, nil)
+		return existing.HTMLURL, false, trace.Wrap(err, "failed to comment on issue %v", existing.Number)
+	}
+	var filed githubIssue
+	err = r.do(ctx, http.MethodPost, fmt.Sprintf("/repos/%v/issues", r.config.Repository), map[string]interface{}{
+		"title":  issue.Title,
+		"body":   fmt.Sprintf("%v\n\nSignature: `%v`", issue.Body, marker),
+		"labels": r.config.Labels,
+	}, &filed)
+	if err != nil {
+		return "", false, trace.Wrap(err, "failed to file issue")
+	}
+	return filed.HTMLURL, true, nil
+}
+
+func (r *GitHub) do(ctx context.Context, method, path string, in, out interface{}) error {
+	req, err := http.NewRequest(method, r.config.URL+path, nil)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	req.Header.Set("Authorization", "token "+r.config.Token)
+	return trace.Wrap(doJSON(ctx, req, in, out))
+}
+
+type githubIssue struct {
+	Number  int    `json:"number"`
+	HTMLURL string `json:"html_url"`
+}
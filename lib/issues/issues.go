@@ -0,0 +1,72 @@
+// Package issues files the consistent test failures in an issue tracker (GitHub or Jira),
+// deduplicating the issues by the signature of the failure: a failure with the signature
+// of an open issue is added as a comment to the issue instead of filing a new one.
+package issues
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/gravitational/trace"
+)
+
+// Issue describes a failure to file
+type Issue struct {
+	// Signature identifies the failure, see Marker
+	Signature string
+	// Title is the title of a new issue
+	Title string
+	// Body describes the failure, used as the description of a new issue
+	// or the comment on an existing one
+	Body string
+}
+
+// Tracker files issues
+type Tracker interface {
+	// File files the issue, or comments on the open issue with the same signature.
+	// Returns the URL of the issue and whether it has been created
+	File(ctx context.Context, issue Issue) (url string, created bool, err error)
+}
+
+// Marker returns the text identifying the issues of the failure with signature
+func Marker(signature string) string {
+	return "robotest-signature-" + signature
+}
+
+// doJSON sends the request with the JSON body and decodes the JSON response into out, if not nil
+func doJSON(ctx context.Context, req *http.Request, in, out interface{}) error {
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(data))
+		req.ContentLength = int64(len(data))
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	client := &http.Client{Timeout: requestTimeout}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return trace.BadParameter("%v %v: %v %s", req.Method, req.URL.Path, resp.Status, data)
+	}
+	if out == nil {
+		return nil
+	}
+	return trace.Wrap(json.Unmarshal(data, out))
+}
+
+// requestTimeout is the timeout of a single request to the tracker
+const requestTimeout = 30 * time.Second
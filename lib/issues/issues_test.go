@@ -0,0 +1,70 @@
+package issues
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitHubDeduplicates(t *testing.T) {
+	var open []int
+	var requests, auths, queries, bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auths = append(auths, r.Header.Get("Authorization"))
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch r.URL.Path {
+		case "/search/issues":
+			queries = append(queries, r.URL.Query().Get("q"))
+			var items []githubIssue
+			for _, number := range open {
+				items = append(items, githubIssue{Number: number, HTMLURL: "https://github.com/owner/repo/issues/1"})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"items": items})
+		case "/repos/owner/repo/issues":
+			var issue struct {
+				Body string `json:"body"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&issue); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			bodies = append(bodies, issue.Body)
+			open = append(open, 1)
+			json.NewEncoder(w).Encode(githubIssue{Number: 1, HTMLURL: "https://github.com/owner/repo/issues/1"})
+		case "/repos/owner/repo/issues/1/comments":
+			w.Write([]byte("{}"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tracker, err := NewGitHub(GitHubConfig{Repository: "owner/repo", Token: "secret", URL: server.URL})
+	require.NoError(t, err)
+	issue := Issue{Signature: "abc", Title: "install failed", Body: "details"}
+	url, created, err := tracker.File(context.Background(), issue)
+	require.NoError(t, err)
+	require.True(t, created)
+	require.Equal(t, "https://github.com/owner/repo/issues/1", url)
+	_, created, err = tracker.File(context.Background(), issue)
+	require.NoError(t, err)
+	require.False(t, created)
+	require.Equal(t, []string{
+		"GET /search/issues",
+		"POST /repos/owner/repo/issues",
+		"GET /search/issues",
+		"POST /repos/owner/repo/issues/1/comments",
+	}, requests)
+	for _, auth := range auths {
+		require.Equal(t, "token secret", auth)
+	}
+	for _, query := range queries {
+		require.Contains(t, query, `repo:owner/repo is:issue is:open in:body "robotest-signature-abc"`)
+	}
+	require.Len(t, bodies, 1)
+	require.Contains(t, bodies[0], "robotest-signature-abc")
+}
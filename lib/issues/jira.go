@@ -0,0 +1,96 @@
+package issues
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gravitational/trace"
+)
+
+// JiraConfig configures the Jira tracker
+type JiraConfig struct {
+	// URL is the URL of the Jira instance
+	URL string
+	// Project is the key of the project to file the issues in
+	Project string
+	// IssueType is the type of the new issues, defaults to Bug
+	IssueType string
+	// User and Token authenticate with the API
+	User, Token string
+	// Labels lists additional labels of the new issues
+	Labels []string
+}
+
+// NewJira returns the tracker filing the issues in the Jira project.
+// The signature marker is added as a label to the issues to find them by
+func NewJira(config JiraConfig) (*Jira, error) {
+	if config.URL == "" || config.Project == "" {
+		return nil, trace.BadParameter("Jira URL and project are required")
+	}
+	if config.User == "" || config.Token == "" {
+		return nil, trace.BadParameter("Jira user and token are required")
+	}
+	if config.IssueType == "" {
+		config.IssueType = "Bug"
+	}
+	config.URL = strings.TrimSuffix(config.URL, "/")
+	return &Jira{config: config}, nil
+}
+
+// Jira files issues in a Jira project
+type Jira struct {
+	config JiraConfig
+}
+
+// File files the issue, or comments on the unresolved issue with the same signature
+func (r *Jira) File(ctx context.Context, issue Issue) (issueURL string, created bool, err error) {
+	marker := Marker(issue.Signature)
+	jql := fmt.Sprintf(`project = "%v" AND labels = "%v" AND statusCategory != Done`, r.config.Project, marker)
+	var found struct {
+		Issues []jiraIssue `json:"issues"`
+	}
+	err = r.do(ctx, http.MethodGet, "/rest/api/2/search?fields=key&jql="+url.QueryEscape(jql), nil, &found)
+	if err != nil {
+		return "", false, trace.Wrap(err, "failed to search issues")
+	}
+	if len(found.Issues) != 0 {
+		key := found.Issues[0].Key
+		err = r.do(ctx, http.MethodPost, fmt.Sprintf("/rest/api/2/issue/%v/comment", key),
+			map[string]string{"body": issue.Body}, nil)
+		return r.browseURL(key), false, trace.Wrap(err, "failed to comment on issue %v", key)
+	}
+	var filed jiraIssue
+	err = r.do(ctx, http.MethodPost, "/rest/api/2/issue", map[string]interface{}{
+		"fields": map[string]interface{}{
+			"project":     map[string]string{"key": r.config.Project},
+			"issuetype":   map[string]string{"name": r.config.IssueType},
+			"summary":     issue.Title,
+			"description": issue.Body,
+			"labels":      append([]string{marker}, r.config.Labels...),
+		},
+	}, &filed)
+	if err != nil {
+		return "", false, trace.Wrap(err, "failed to file issue")
+	}
+	return r.browseURL(filed.Key), true, nil
+}
+
+func (r *Jira) browseURL(key string) string {
+	return r.config.URL + "/browse/" + key
+}
+
+func (r *Jira) do(ctx context.Context, method, path string, in, out interface{}) error {
+	req, err := http.NewRequest(method, r.config.URL+path, nil)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	req.SetBasicAuth(r.config.User, r.config.Token)
+	return trace.Wrap(doJSON(ctx, req, in, out))
+}
+
+type jiraIssue struct {
+	Key string `json:"key"`
+}
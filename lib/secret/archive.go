@@ -0,0 +1,82 @@
+package secret
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/gravitational/trace"
+)
+
+// RedactArchive rewrites the gzipped tarball at path with the registered
+// secrets redacted from the regular files in it
+func RedactArchive(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	defer in.Close()
+	out, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+
+	err = redactArchive(in, out)
+	if err != nil {
+		return trace.Wrap(err, "failed to redact %v", path)
+	}
+	if err := out.Close(); err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	return trace.ConvertSystemError(os.Rename(out.Name(), path))
+}
+
+func redactArchive(r io.Reader, w io.Writer) error {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer zr.Close()
+	zw := gzip.NewWriter(w)
+	tr := tar.NewReader(zr)
+	tw := tar.NewWriter(zw)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			if err := tw.WriteHeader(hdr); err != nil {
+				return trace.Wrap(err)
+			}
+			if _, err := io.Copy(tw, tr); err != nil {
+				return trace.Wrap(err)
+			}
+			continue
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		data = RedactBytes(data)
+		hdr.Size = int64(len(data))
+		if err := tw.WriteHeader(hdr); err != nil {
+			return trace.Wrap(err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(zw.Close())
+}
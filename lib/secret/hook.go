@@ -0,0 +1,40 @@
+package secret
+
+import (
+	"errors"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Hook is a logrus hook that redacts the registered secrets from the log message
+// and the string and error fields of log entries. It has to be added before the
+// hooks that forward the entries elsewhere
+type Hook struct{}
+
+// Levels returns all logging levels
+func (Hook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire redacts the message and the fields of the entry
+func (Hook) Fire(e *logrus.Entry) error {
+	e.Message = Redact(e.Message)
+	// the data is shared with the entry the logged entry has been derived from
+	data := make(logrus.Fields, len(e.Data))
+	for key, value := range e.Data {
+		switch v := value.(type) {
+		case string:
+			data[key] = Redact(v)
+		case error:
+			if redacted := Redact(v.Error()); redacted != v.Error() {
+				data[key] = errors.New(redacted)
+			} else {
+				data[key] = v
+			}
+		default:
+			data[key] = value
+		}
+	}
+	e.Data = data
+	return nil
+}
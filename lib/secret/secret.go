@@ -0,0 +1,145 @@
+// Package secret resolves secrets (tokens, licenses, cloud credentials)
+// from their references and keeps track of the resolved values so that
+// they can be redacted from logs and collected artifacts.
+package secret
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gravitational/trace"
+)
+
+// Resolve returns the value of the secret given with ref and registers
+// it for redaction. ref is one of:
+//
+//	env:NAME   - value of the environment variable NAME
+//	file:PATH  - contents of the file at PATH without surrounding whitespace
+//	<scheme>:… - value returned by the resolver registered for the scheme
+//
+// Any other value is taken as the secret itself
+func Resolve(ref string) (string, error) {
+	value, err := resolve(ref)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	Register(value)
+	return value, nil
+}
+
+// ResolverFunc returns the value of the secret given with path
+// within the scheme of the resolver
+type ResolverFunc func(path string) (string, error)
+
+// RegisterResolver registers the resolver for references with the given scheme,
+// i.e. "vault" for vault:secret/robotest#token
+func RegisterResolver(scheme string, fn ResolverFunc) {
+	mu.Lock()
+	defer mu.Unlock()
+	resolvers[scheme] = fn
+}
+
+// Register registers values as secrets to be redacted.
+// Values shorter than minLength are ignored as they would redact unrelated text
+func Register(values ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, value := range values {
+		if len(value) < minLength {
+			continue
+		}
+		secrets[value] = struct{}{}
+	}
+	// replace longer secrets first so that secrets containing others are fully masked
+	var oldnew []string
+	for _, value := range sortedSecrets() {
+		oldnew = append(oldnew, value, Mask)
+	}
+	replacer = strings.NewReplacer(oldnew...)
+}
+
+// Redact replaces all registered secrets in s with Mask
+func Redact(s string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	if replacer == nil {
+		return s
+	}
+	return replacer.Replace(s)
+}
+
+// RedactBytes replaces all registered secrets in b with Mask
+func RedactBytes(b []byte) []byte {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, value := range sortedSecrets() {
+		b = bytes.Replace(b, []byte(value), []byte(Mask), -1)
+	}
+	return b
+}
+
+func resolve(ref string) (string, error) {
+	i := strings.Index(ref, ":")
+	if i < 0 {
+		return ref, nil
+	}
+	scheme, path := ref[:i], ref[i+1:]
+	switch scheme {
+	case "env":
+		value, ok := os.LookupEnv(path)
+		if !ok {
+			return "", trace.NotFound("environment variable %v is not set", path)
+		}
+		return value, nil
+	case "file":
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", trace.ConvertSystemError(err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	mu.RLock()
+	fn, ok := resolvers[scheme]
+	mu.RUnlock()
+	if !ok {
+		// not a reference, i.e. a literal value with a colon
+		return ref, nil
+	}
+	value, err := fn(path)
+	if err != nil {
+		return "", trace.Wrap(err, "failed to resolve %v secret %v", scheme, path)
+	}
+	return value, nil
+}
+
+// sortedSecrets returns the registered secrets, longest first.
+// Requires mu to be held
+func sortedSecrets() (values []string) {
+	for value := range secrets {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if len(values[i]) != len(values[j]) {
+			return len(values[i]) > len(values[j])
+		}
+		return values[i] < values[j]
+	})
+	return values
+}
+
+// Mask replaces the redacted secrets
+const Mask = "**REDACTED**"
+
+// minLength is the minimum length of a secret to be redacted
+const minLength = 4
+
+var (
+	mu        sync.RWMutex
+	secrets   = make(map[string]struct{})
+	resolvers = make(map[string]ResolverFunc)
+	replacer  *strings.Replacer
+)
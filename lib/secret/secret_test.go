@@ -0,0 +1,79 @@
+package secret
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveAndRedact(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secret")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "license.pem")
+	require.NoError(t, ioutil.WriteFile(path, []byte("license-3f8e\n"), 0600))
+	os.Setenv("ROBOTEST_TEST_SECRET", "token-7c21")
+	defer os.Unsetenv("ROBOTEST_TEST_SECRET")
+
+	value, err := Resolve("env:ROBOTEST_TEST_SECRET")
+	require.NoError(t, err)
+	assert.Equal(t, "token-7c21", value)
+	value, err = Resolve("file:" + path)
+	require.NoError(t, err)
+	assert.Equal(t, "license-3f8e", value)
+	value, err = Resolve("https://literal")
+	require.NoError(t, err)
+	assert.Equal(t, "https://literal", value)
+	_, err = Resolve("env:ROBOTEST_TEST_UNSET")
+	assert.Error(t, err)
+
+	assert.Equal(t, "--token=**REDACTED** --license=**REDACTED**",
+		Redact("--token=token-7c21 --license=license-3f8e"))
+
+	entry := logrus.NewEntry(logrus.New()).WithFields(logrus.Fields{
+		"cmd":   "./gravity join --token=token-7c21",
+		"error": errors.New("join with token-7c21 failed"),
+	})
+	entry.Message = "token-7c21"
+	// logrus fires the hooks on a copy of the entry sharing the data
+	logged := *entry
+	require.NoError(t, Hook{}.Fire(&logged))
+	assert.Equal(t, Mask, logged.Message)
+	assert.Equal(t, "./gravity join --token=**REDACTED**", logged.Data["cmd"])
+	assert.EqualError(t, logged.Data["error"].(error), "join with **REDACTED** failed")
+	assert.Equal(t, "./gravity join --token=token-7c21", entry.Data["cmd"], "shared data")
+}
+
+func TestRedactArchive(t *testing.T) {
+	Register("secret-9d4a")
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+	content := []byte("install --token=secret-9d4a\n")
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "gravity-system.log", Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+	_, err := tw.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, zw.Close())
+
+	var out bytes.Buffer
+	require.NoError(t, redactArchive(&buf, &out))
+	zr, err := gzip.NewReader(&out)
+	require.NoError(t, err)
+	tr := tar.NewReader(zr)
+	hdr, err := tr.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "gravity-system.log", hdr.Name)
+	data, err := ioutil.ReadAll(tr)
+	require.NoError(t, err)
+	assert.Equal(t, "install --token=**REDACTED**\n", string(data))
+}
@@ -0,0 +1,72 @@
+// Package shell builds commands for the remote POSIX shell with the arguments quoted,
+// so that values with spaces or shell metacharacters are passed as single words
+package shell
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Quote quotes value as a single word for the shell.
+// Values that consist of characters without special meaning are returned as is
+func Quote(value string) string {
+	if value == "" {
+		return "''"
+	}
+	if reSafe.MatchString(value) {
+		return value
+	}
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
+
+// Command is a shell command built from words
+type Command struct {
+	words []string
+}
+
+// New returns a command that runs name with args. The name and args are quoted
+func New(name string, args ...string) *Command {
+	return (&Command{}).Args(name).Args(args...)
+}
+
+// Sudo returns a command that runs name with args as root
+func Sudo(name string, args ...string) *Command {
+	return (&Command{words: []string{"sudo"}}).Args(name).Args(args...)
+}
+
+// Args appends the arguments to the command, quoted
+func (r *Command) Args(args ...string) *Command {
+	for _, arg := range args {
+		r.words = append(r.words, Quote(arg))
+	}
+	return r
+}
+
+// Raw appends the words to the command as is, i.e. command substitutions
+// or variables to be expanded by the shell
+func (r *Command) Raw(words ...string) *Command {
+	r.words = append(r.words, words...)
+	return r
+}
+
+// String returns the command line
+func (r *Command) String() string {
+	return strings.Join(r.words, " ")
+}
+
+// And returns the command line that runs the commands in order while they succeed
+func And(commands ...*Command) string {
+	lines := make([]string, 0, len(commands))
+	for _, command := range commands {
+		lines = append(lines, command.String())
+	}
+	return strings.Join(lines, " && ")
+}
+
+// InDir returns the command line that runs the commands in order in the directory dir
+func InDir(dir string, commands ...*Command) string {
+	return And(append([]*Command{New("cd", dir)}, commands...)...)
+}
+
+// reSafe matches the values that do not need quoting
+var reSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
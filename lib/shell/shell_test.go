@@ -0,0 +1,30 @@
+package shell
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuote(t *testing.T) {
+	var testCases = []struct {
+		value    string
+		expected string
+	}{
+		{value: "--state-dir=/var/lib/gravity", expected: "--state-dir=/var/lib/gravity"},
+		{value: "", expected: "''"},
+		{value: "two words", expected: "'two words'"},
+		{value: "it's", expected: `'it'\''s'`},
+		{value: "$(reboot); `id`", expected: "'$(reboot); `id`'"},
+		{value: "*.log", expected: "'*.log'"},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, Quote(tc.value), tc.value)
+	}
+}
+
+func TestCommand(t *testing.T) {
+	cmd := InDir("/home/robotest/install dir",
+		Sudo("./gravity", "backup", "/tmp/backup file.tar.gz").Raw("$(./gravity app-package)"))
+	assert.Equal(t, `cd '/home/robotest/install dir' && sudo ./gravity backup '/tmp/backup file.tar.gz' $(./gravity app-package)`, cmd)
+}
@@ -1,11 +1,14 @@
 package sshutils
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"net/url"
 	"path/filepath"
 
+	"github.com/gravitational/robotest/lib/failure"
+	"github.com/gravitational/robotest/lib/shell"
 	"github.com/gravitational/robotest/lib/wait"
 
 	"github.com/gravitational/trace"
@@ -51,6 +54,37 @@ func TransferFile(ctx context.Context, client *ssh.Client, log logrus.FieldLogge
 	return "", trace.Wrap(err)
 }
 
+// WriteFile writes data into the remote file at path readable only by the SSH user.
+// The data is streamed over the session input, so unlike data passed with the command
+// or its environment, it does not show up in the command line of the remote shell
+func WriteFile(ctx context.Context, client *ssh.Client, log logrus.FieldLogger, path string, data []byte) error {
+	if client == nil {
+		return failure.SSHTransport(trace.ConnectionProblem(nil, "no SSH connection"))
+	}
+	session, err := client.NewSession()
+	if err != nil {
+		return failure.SSHTransport(err)
+	}
+	defer session.Close()
+
+	session.Stdin = bytes.NewReader(data)
+	cmd := fmt.Sprintf("umask 077 && cat > %v", shell.Quote(path))
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- trace.Wrap(session.Run(cmd))
+	}()
+	select {
+	case <-ctx.Done():
+		_ = session.Signal(ssh.SIGTERM)
+		return trace.Wrap(ctx.Err())
+	case err = <-errCh:
+		if err != nil {
+			log.WithError(err).WithField("path", path).Debug("Failed to write file.")
+		}
+		return trace.Wrap(err, cmd)
+	}
+}
+
 const (
 	// TestRegularFile file exists and is a regular file
 	TestRegularFile = "-f"
@@ -0,0 +1,198 @@
+package sshutils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/gravitational/robotest/lib/secret"
+	"github.com/gravitational/robotest/lib/utils"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// Interaction describes a single recorded command/response pair
+type Interaction struct {
+	// Command is the command that has been executed
+	Command string `json:"command"`
+	// Env lists the environment the command has been executed with
+	Env map[string]string `json:"env,omitempty"`
+	// Stdout is the captured command output
+	Stdout string `json:"stdout,omitempty"`
+	// ExitStatus is the exit code of the command
+	ExitStatus int `json:"exit_status,omitempty"`
+	// Error describes the failure not related to the exit status,
+	// e.g. a broken connection
+	Error string `json:"error,omitempty"`
+}
+
+// NewRecorder returns a new transport that executes commands using the specified
+// transport and records all interactions.
+// Use Save to persist the recorded interactions as a golden file
+func NewRecorder(transport Transport) *Recorder {
+	return &Recorder{transport: transport}
+}
+
+// Recorder is a Transport that captures the command/response pairs
+// of the underlying transport
+type Recorder struct {
+	transport Transport
+	mu        sync.Mutex
+	records   []Interaction
+}
+
+// RunAndParse runs the command using the underlying transport and records the outcome.
+// Implements Transport
+func (r *Recorder) RunAndParse(ctx context.Context, log logrus.FieldLogger, cmd string, env map[string]string, parse OutputParseFn) error {
+	var stdout utils.SafeByteBuffer
+	// stdout is always consumed so that it is recorded even if the caller
+	// does not parse it
+	teeParse := func(r *bufio.Reader) error {
+		_, err := io.Copy(&stdout, r)
+		return trace.Wrap(err)
+	}
+	if parse != nil {
+		teeParse = func(r *bufio.Reader) error {
+			return parse(bufio.NewReader(io.TeeReader(r, &stdout)))
+		}
+	}
+	err := r.transport.RunAndParse(ctx, log, cmd, env, teeParse)
+	record := Interaction{
+		Command: cmd,
+		Env:     env,
+		Stdout:  stdout.String(),
+	}
+	if exitErr, ok := trace.Unwrap(err).(ExitStatusError); ok {
+		record.ExitStatus = exitErr.ExitStatus()
+	} else if err != nil {
+		record.Error = trace.UserMessage(err)
+	}
+	r.mu.Lock()
+	r.records = append(r.records, record)
+	r.mu.Unlock()
+	return err
+}
+
+// Interactions returns the list of interactions recorded so far
+func (r *Recorder) Interactions() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Interaction(nil), r.records...)
+}
+
+// Save writes the recorded interactions to the golden file at path
+func (r *Recorder) Save(path string) error {
+	data, err := json.MarshalIndent(r.Interactions(), "", "  ")
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	// golden files end up in artifacts, so keep tokens and credentials out
+	data = secret.RedactBytes(data)
+	return trace.ConvertSystemError(ioutil.WriteFile(path, data, 0644))
+}
+
+// LoadReplayer returns a new replay transport for the golden file at path
+func LoadReplayer(path string) (*Replayer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, trace.ConvertSystemError(err)
+	}
+	defer f.Close()
+	var records []Interaction
+	err = json.NewDecoder(f).Decode(&records)
+	if err != nil {
+		return nil, trace.Wrap(err, "failed to decode golden file %v", path)
+	}
+	return NewReplayer(records...), nil
+}
+
+// NewReplayer returns a new replay transport that serves the specified interactions
+func NewReplayer(records ...Interaction) *Replayer {
+	responses := make(map[string][]Interaction)
+	for _, record := range records {
+		responses[record.Command] = append(responses[record.Command], record)
+	}
+	return &Replayer{responses: responses}
+}
+
+// Replayer is a Transport that serves previously recorded interactions.
+// Responses for the same command are served in the order they were recorded
+type Replayer struct {
+	mu        sync.Mutex
+	responses map[string][]Interaction
+}
+
+// RunAndParse serves the next recorded response for cmd.
+// Returns trace.NotFound if there are no more recorded responses for the command.
+// Implements Transport
+func (r *Replayer) RunAndParse(ctx context.Context, log logrus.FieldLogger, cmd string, env map[string]string, parse OutputParseFn) error {
+	if ctx.Err() != nil {
+		return trace.Wrap(ctx.Err())
+	}
+	record, err := r.next(cmd)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	log.WithField("cmd", cmd).Debug("Replay.")
+	if parse != nil {
+		err = parse(bufio.NewReader(bytes.NewBufferString(record.Stdout)))
+		if err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	if record.ExitStatus != 0 {
+		return trace.Wrap(&ReplayExitError{Command: cmd, Status: record.ExitStatus})
+	}
+	if record.Error != "" {
+		return trace.ConnectionProblem(nil, record.Error)
+	}
+	return nil
+}
+
+// Remaining returns the number of recorded responses that have not been served yet
+func (r *Replayer) Remaining() (count int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, responses := range r.responses {
+		count += len(responses)
+	}
+	return count
+}
+
+func (r *Replayer) next(cmd string) (*Interaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	responses := r.responses[cmd]
+	if len(responses) == 0 {
+		return nil, trace.NotFound("no recorded response for %q", cmd)
+	}
+	r.responses[cmd] = responses[1:]
+	return &responses[0], nil
+}
+
+// ReplayExitError is returned by the replay transport for commands
+// that have been recorded with a non-0 exit code
+type ReplayExitError struct {
+	// Command is the replayed command
+	Command string
+	// Status is the recorded exit code
+	Status int
+}
+
+// Error returns the textual representation of this error
+func (r *ReplayExitError) Error() string {
+	return fmt.Sprintf("command %q exited with status %v", r.Command, r.Status)
+}
+
+// ExitStatus returns the recorded exit code.
+// Implements ExitStatusError
+func (r *ReplayExitError) ExitStatus() int {
+	return r.Status
+}
@@ -0,0 +1,61 @@
+package sshutils
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "robotest-ssh")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "golden.json")
+	log := logrus.NewEntry(logrus.StandardLogger())
+	ctx := context.Background()
+
+	recorder := NewRecorder(NewReplayer(
+		Interaction{Command: "hostname", Stdout: "node-1\n"},
+		Interaction{Command: "false", ExitStatus: 1},
+		Interaction{Command: "uptime", Stdout: "up 1 day\n"},
+	))
+	var out string
+	require.NoError(t, recorder.RunAndParse(ctx, log, "hostname", nil, ParseAsString(&out)))
+	assert.Equal(t, "node-1", out)
+	err = recorder.RunAndParse(ctx, log, "false", nil, nil)
+	require.Error(t, err)
+	require.NoError(t, recorder.RunAndParse(ctx, log, "uptime", nil, nil))
+	assert.Equal(t, "up 1 day\n", recorder.Interactions()[2].Stdout, "stdout is recorded without a parser")
+	require.NoError(t, recorder.Save(path))
+
+	replayer, err := LoadReplayer(path)
+	require.NoError(t, err)
+	assert.Equal(t, 3, replayer.Remaining())
+
+	var line string
+	err = replayer.RunAndParse(ctx, log, "hostname", nil, func(r *bufio.Reader) error {
+		line, err = r.ReadString('\n')
+		return err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "node-1", strings.TrimSpace(line))
+
+	err = RunWith(ctx, replayer, log, "false", nil)
+	exitErr, ok := trace.Unwrap(err).(ExitStatusError)
+	require.True(t, ok, "expected exit status error, got %v", err)
+	assert.Equal(t, 1, exitErr.ExitStatus())
+
+	require.NoError(t, replayer.RunAndParse(ctx, log, "uptime", nil, nil))
+
+	err = replayer.RunAndParse(ctx, log, "hostname", nil, nil)
+	assert.True(t, trace.IsNotFound(err), "expected responses to be exhausted, got %v", err)
+}
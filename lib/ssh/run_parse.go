@@ -9,8 +9,11 @@ import (
 	"io/ioutil"
 	"strings"
 
-	"github.com/gravitational/trace"
+	"github.com/gravitational/robotest/lib/failure"
+	"github.com/gravitational/robotest/lib/shell"
+	"github.com/gravitational/robotest/lib/xlog"
 
+	"github.com/gravitational/trace"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/crypto/ssh"
 )
@@ -54,22 +57,22 @@ func RunAndParse(
 	env map[string]string,
 	parse OutputParseFn,
 ) (err error) {
-	log = log.WithField("cmd", cmd)
+	log = xlog.Module(log, xlog.ModuleSSH).WithField("cmd", cmd)
 
 	session, err := client.NewSession()
 	if err != nil {
-		return trace.Wrap(err)
+		return failure.SSHTransport(err)
 	}
 	defer session.Close()
 
 	err = session.RequestPty(term, termH, termW, termModes)
 	if err != nil {
-		return trace.Wrap(err)
+		return failure.SSHTransport(err)
 	}
 
 	envStrings := []string{}
 	for k, v := range env {
-		envStrings = append(envStrings, fmt.Sprintf("%s=%s", k, v))
+		envStrings = append(envStrings, fmt.Sprintf("%s=%s", k, shell.Quote(v)))
 	}
 
 	session.Stdin = new(bytes.Buffer)
@@ -89,11 +92,17 @@ func RunAndParse(
 		return trace.Wrap(err)
 	}
 
-	sessionCommand := fmt.Sprintf("%s %s", strings.Join(envStrings, " "), cmd)
+	sessionCommand := cmd
+	if len(envStrings) != 0 {
+		// export the environment so that it applies to all parts
+		// of a compound command, i.e. cd dir && sudo -E cmd
+		sessionCommand = fmt.Sprintf("export %s; %s", strings.Join(envStrings, " "), cmd)
+	}
 	err = session.Start(sessionCommand)
 	if err != nil {
-		return trace.Wrap(err)
+		return failure.SSHTransport(err)
 	}
+	defer trackSession(client.RemoteAddr().String(), cmd)()
 
 	errCh := make(chan error, 2)
 	expectErrors := 1
@@ -11,6 +11,7 @@ import (
 
 	"github.com/gravitational/robotest/lib/constants"
 	"github.com/gravitational/robotest/lib/utils"
+	"github.com/gravitational/robotest/lib/xlog"
 
 	"github.com/gravitational/trace"
 
@@ -20,6 +21,7 @@ import (
 
 // PutFile transfers local file to remote host directory
 func PutFile(ctx context.Context, client *ssh.Client, log logrus.FieldLogger, srcPath, dstDir string) (remotePath string, err error) {
+	log = xlog.Module(log, scpModule)
 	mkdirCmd := fmt.Sprintf("mkdir -p %s", dstDir)
 	err = Run(ctx, client, log, mkdirCmd, nil)
 	if err != nil {
@@ -101,6 +103,7 @@ func scpSendFile(out io.WriteCloser, file *os.File, fi os.FileInfo, buf []byte)
 
 // PipeCommand will run a remote command and store as local file
 func PipeCommand(ctx context.Context, client *ssh.Client, log logrus.FieldLogger, cmd, dst string) error {
+	log = xlog.Module(log, scpModule)
 	session, err := client.NewSession()
 	if err != nil {
 		return trace.Wrap(err)
@@ -158,3 +161,6 @@ func PipeCommand(ctx context.Context, client *ssh.Client, log logrus.FieldLogger
 	}
 	return nil
 }
+
+// scpModule is the log module of the file transfers
+const scpModule = xlog.ModuleSSH + ".scp"
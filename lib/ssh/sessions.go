@@ -0,0 +1,91 @@
+package sshutils
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gravitational/trace"
+)
+
+// Session describes an active SSH command
+type Session struct {
+	// Addr is the address of the remote host
+	Addr string `json:"addr"`
+	// Command is the command being executed
+	Command string `json:"command"`
+	// Started specifies the time the command was started
+	Started time.Time `json:"started"`
+}
+
+// ActiveSessions returns the SSH commands currently executed, oldest first
+func ActiveSessions() []Session {
+	sessions.Lock()
+	defer sessions.Unlock()
+	active := make([]Session, 0, len(sessions.active))
+	for _, session := range sessions.active {
+		active = append(active, session)
+	}
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].Started.Before(active[j].Started)
+	})
+	return active
+}
+
+// WaitSessions waits until no SSH commands are executed on any of the specified hosts
+func WaitSessions(ctx context.Context, hosts []string) error {
+	ticker := time.NewTicker(sessionPollInterval)
+	defer ticker.Stop()
+	for {
+		if !hasSessions(hosts) {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return trace.LimitExceeded("SSH commands still running on %v", hosts)
+		}
+	}
+}
+
+func hasSessions(hosts []string) bool {
+	for _, session := range ActiveSessions() {
+		host, _, err := net.SplitHostPort(session.Addr)
+		if err != nil {
+			host = session.Addr
+		}
+		for _, h := range hosts {
+			if h == host {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// trackSession records the command as active until the returned function is called
+func trackSession(addr, command string) (done func()) {
+	sessions.Lock()
+	defer sessions.Unlock()
+	sessions.next++
+	id := sessions.next
+	sessions.active[id] = Session{Addr: addr, Command: command, Started: time.Now()}
+	return func() {
+		sessions.Lock()
+		defer sessions.Unlock()
+		delete(sessions.active, id)
+	}
+}
+
+var sessions = struct {
+	sync.Mutex
+	next   uint64
+	active map[uint64]Session
+}{
+	active: make(map[uint64]Session),
+}
+
+// sessionPollInterval defines how often the active sessions are checked in WaitSessions
+const sessionPollInterval = 100 * time.Millisecond
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/gravitational/robotest/lib/defaults"
+	"github.com/gravitational/robotest/lib/failure"
 
 	"github.com/gravitational/trace"
 	"github.com/sirupsen/logrus"
@@ -32,7 +33,7 @@ func Connect(addr, user string, signer ssh.Signer) (*ssh.Session, error) {
 
 	session, err := client.NewSession()
 	if err != nil {
-		return nil, trace.Wrap(err)
+		return nil, failure.SSHTransport(err)
 	}
 
 	return session, nil
@@ -173,7 +174,11 @@ func client(addr, user string, signer ssh.Signer, dialer sshDialer) (*ssh.Client
 		},
 	}
 
-	return dialer.Dial("tcp", addr, conf)
+	client, err := dialer.Dial("tcp", addr, conf)
+	if err != nil {
+		return nil, failure.SSHTransport(err)
+	}
+	return client, nil
 }
 
 const (
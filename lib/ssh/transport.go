@@ -0,0 +1,49 @@
+package sshutils
+
+import (
+	"context"
+
+	"github.com/gravitational/robotest/lib/failure"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+)
+
+// Transport executes commands on a remote host.
+// It abstracts the SSH session so that command builders and output parsers
+// can be exercised against recorded interactions instead of live VMs
+type Transport interface {
+	// RunAndParse runs the command cmd with environment env and feeds
+	// the command's stdout to parse.
+	// See RunAndParse for the error semantics
+	RunAndParse(ctx context.Context, log logrus.FieldLogger, cmd string, env map[string]string, parse OutputParseFn) error
+}
+
+// ClientTransport returns a Transport that executes commands
+// using the specified SSH client
+func ClientTransport(client *ssh.Client) Transport {
+	return clientTransport{client: client}
+}
+
+// RunWith runs the command cmd using the specified transport discarding its output
+func RunWith(ctx context.Context, t Transport, log logrus.FieldLogger, cmd string, env map[string]string) error {
+	err := t.RunAndParse(ctx, log, cmd, env, ParseDiscard)
+	if err != nil {
+		return trace.Wrap(err, "command %q failed", cmd)
+	}
+	return nil
+}
+
+// RunAndParse runs the command cmd using the underlying SSH client.
+// Implements Transport
+func (r clientTransport) RunAndParse(ctx context.Context, log logrus.FieldLogger, cmd string, env map[string]string, parse OutputParseFn) error {
+	if r.client == nil {
+		return failure.SSHTransport(trace.ConnectionProblem(nil, "no SSH connection"))
+	}
+	return RunAndParse(ctx, r.client, log, cmd, env, parse)
+}
+
+type clientTransport struct {
+	client *ssh.Client
+}
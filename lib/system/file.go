@@ -63,6 +63,44 @@ func CopyFileWithPerms(src, dst string, perm os.FileMode) error {
 	return nil
 }
 
+// WriteFileAtomic writes data to the file at path atomically.
+// If path does not exist, WriteFileAtomic creates it with permissions perm.
+// If the write fails, the file is preserved
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "")
+	if err != nil {
+		return trace.ConvertSystemError(err)
+	}
+
+	cleanup := func() {
+		err := os.Remove(tmp.Name())
+		if err != nil {
+			log.Warnf("Failed to remove %v: %v.", tmp.Name(), err)
+		}
+	}
+
+	_, err = tmp.Write(data)
+	if err != nil {
+		tmp.Close()
+		cleanup()
+		return trace.ConvertSystemError(err)
+	}
+	if err = tmp.Close(); err != nil {
+		cleanup()
+		return trace.ConvertSystemError(err)
+	}
+	if err = os.Chmod(tmp.Name(), perm); err != nil {
+		cleanup()
+		return trace.ConvertSystemError(err)
+	}
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		cleanup()
+		return trace.ConvertSystemError(err)
+	}
+	return nil
+}
+
 // Recursively copy
 // dst must always be a directory
 // src may be either a dir or a file
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"sync"
+)
+
+// LineBuffer is a goroutine safe buffer that retains the most recent lines
+type LineBuffer struct {
+	mutex sync.Mutex
+	lines []string
+	next  int
+	full  bool
+}
+
+// NewLineBuffer returns a new buffer that retains up to size most recent lines
+func NewLineBuffer(size int) *LineBuffer {
+	return &LineBuffer{lines: make([]string, size)}
+}
+
+// Add adds the line to the buffer evicting the oldest line if the buffer is full
+func (r *LineBuffer) Add(line string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if len(r.lines) == 0 {
+		return
+	}
+	r.lines[r.next] = line
+	r.next = (r.next + 1) % len(r.lines)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Lines returns the retained lines, oldest first
+func (r *LineBuffer) Lines() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if !r.full {
+		return append([]string(nil), r.lines[:r.next]...)
+	}
+	return append(append([]string(nil), r.lines[r.next:]...), r.lines[:r.next]...)
+}
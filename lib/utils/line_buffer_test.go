@@ -0,0 +1,17 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineBuffer(t *testing.T) {
+	buf := NewLineBuffer(3)
+	buf.Add("1")
+	buf.Add("2")
+	assert.Equal(t, []string{"1", "2"}, buf.Lines())
+	buf.Add("3")
+	buf.Add("4")
+	assert.Equal(t, []string{"2", "3", "4"}, buf.Lines())
+}
@@ -0,0 +1,138 @@
+// Package vault implements a minimal client for the HashiCorp Vault HTTP API
+// sufficient to read secrets, issue dynamic credentials and revoke their leases.
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gravitational/trace"
+)
+
+// Config specifies the Vault server to connect to
+type Config struct {
+	// Address is the address of the Vault server, i.e. https://vault.example.com:8200
+	Address string
+	// Token is the token to authenticate with
+	Token string
+	// Namespace optionally specifies the Vault Enterprise namespace
+	Namespace string
+}
+
+// New returns a new client for the server given with config
+func New(config Config) (*Client, error) {
+	if config.Address == "" {
+		return nil, trace.BadParameter("vault address is required")
+	}
+	if config.Token == "" {
+		return nil, trace.BadParameter("vault token is required")
+	}
+	return &Client{
+		config: config,
+		client: &http.Client{Timeout: requestTimeout},
+	}, nil
+}
+
+// Client is a Vault API client
+type Client struct {
+	config Config
+	client *http.Client
+}
+
+// Secret is a secret returned by Vault
+type Secret struct {
+	// LeaseID identifies the lease of dynamic credentials
+	LeaseID string `json:"lease_id"`
+	// LeaseDuration is the lease duration in seconds
+	LeaseDuration int `json:"lease_duration"`
+	// Data is the secret data
+	Data map[string]interface{} `json:"data"`
+}
+
+// String returns the value of the string field key of the secret data
+func (r Secret) String(key string) (string, error) {
+	value, ok := r.Data[key].(string)
+	if !ok {
+		return "", trace.NotFound("no string field %q in secret", key)
+	}
+	return value, nil
+}
+
+// Read reads the secret at path, i.e. aws/creds/robotest
+func (c *Client) Read(ctx context.Context, path string) (*Secret, error) {
+	return c.do(ctx, http.MethodGet, path, nil)
+}
+
+// Write writes data to path and returns the resulting secret, if any
+func (c *Client) Write(ctx context.Context, path string, data map[string]interface{}) (*Secret, error) {
+	return c.do(ctx, http.MethodPut, path, data)
+}
+
+// Revoke revokes the lease given with leaseID
+func (c *Client) Revoke(ctx context.Context, leaseID string) error {
+	_, err := c.Write(ctx, "sys/leases/revoke", map[string]interface{}{"lease_id": leaseID})
+	return trace.Wrap(err)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, data map[string]interface{}) (*Secret, error) {
+	var body bytes.Buffer
+	if data != nil {
+		if err := json.NewEncoder(&body).Encode(data); err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	url := fmt.Sprintf("%v/v1/%v", strings.TrimSuffix(c.config.Address, "/"), strings.TrimPrefix(path, "/"))
+	req, err := http.NewRequest(method, url, &body)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("X-Vault-Token", c.config.Token)
+	if c.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.config.Namespace)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, trace.ConnectionProblem(err, "failed to connect to vault at %v", c.config.Address)
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusNoContent:
+		return nil, nil
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, trace.NotFound("no secret at %v", path)
+	case resp.StatusCode == http.StatusForbidden:
+		return nil, trace.AccessDenied("access to %v denied: %s", path, errorMessage(respBody))
+	case resp.StatusCode >= http.StatusBadRequest:
+		return nil, trace.BadParameter("vault request %v %v failed with %v: %s",
+			method, path, resp.StatusCode, errorMessage(respBody))
+	}
+	var secret Secret
+	if err := json.Unmarshal(respBody, &secret); err != nil {
+		return nil, trace.Wrap(err, "failed to decode vault response")
+	}
+	return &secret, nil
+}
+
+// errorMessage extracts the errors from a Vault error response
+func errorMessage(body []byte) string {
+	var resp struct {
+		Errors []string `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil || len(resp.Errors) == 0 {
+		return string(body)
+	}
+	return strings.Join(resp.Errors, "; ")
+}
+
+const requestTimeout = 30 * time.Second
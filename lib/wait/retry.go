@@ -3,7 +3,7 @@ package wait
 import (
 	"context"
 	"fmt"
-	"math"
+	"math/rand"
 	"time"
 
 	"github.com/gravitational/robotest/lib/defaults"
@@ -53,8 +53,9 @@ func Retry(ctx context.Context, fn func() error) error {
 	return r.Do(ctx, fn)
 }
 
-// Do retries the given function fn for the configured number of attempts until it succeeds
-// or all attempts have been exhausted
+// Do retries the given function fn until it succeeds, all attempts have been exhausted,
+// the retry budget has been spent or the context has expired.
+// Gives up early if the next attempt would start after the context deadline
 func (r Retryer) Do(ctx context.Context, fn func() error) (err error) {
 	if r.FieldLogger == nil {
 		r.FieldLogger = log.NewEntry(log.StandardLogger())
@@ -64,7 +65,8 @@ func (r Retryer) Do(ctx context.Context, fn func() error) (err error) {
 		return trace.Wrap(ctx.Err())
 	}
 
-	for i := 1; i <= r.Attempts; i += 1 {
+	start := time.Now()
+	for i := 1; r.Attempts <= 0 || i <= r.Attempts; i += 1 {
 		err = fn()
 		if err == nil {
 			r.Debug("succeded")
@@ -75,18 +77,28 @@ func (r Retryer) Do(ctx context.Context, fn func() error) (err error) {
 		if deadline, ok := ctx.Deadline(); ok {
 			le = le.WithField("timeout-in", fmt.Sprintf("%v", time.Until(deadline)))
 		}
+		delay := r.delay(i)
 		switch origErr := err.(type) {
 		case AbortRetry:
 			le.WithError(err).Error("aborted")
 			return origErr.Err
 		case ContinueRetry:
-			le.Debugf("%v retry in %v", origErr.Message, r.Delay)
+			le.Debugf("%v retry in %v", origErr.Message, delay)
 		default:
-			le.Debugf("unsuccessful attempt %v: %v, retry in %v", i, trace.UserMessage(err), r.Delay)
+			le.Debugf("unsuccessful attempt %v: %v, retry in %v", i, trace.UserMessage(err), delay)
+		}
+
+		if r.Budget > 0 && time.Since(start)+delay > r.Budget {
+			r.Errorf("retry budget of %v exhausted after %v attempts:\n%v", r.Budget, i, trace.DebugReport(err))
+			return err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			r.Errorf("next attempt would exceed the deadline, giving up after %v attempts:\n%v", i, trace.DebugReport(err))
+			return err
 		}
 
 		select {
-		case <-time.After(backoff(r.Delay, i)):
+		case <-time.After(delay):
 		case <-ctx.Done():
 			r.Error("context timed out")
 			return err
@@ -96,17 +108,40 @@ func (r Retryer) Do(ctx context.Context, fn func() error) (err error) {
 	return err
 }
 
-// Retryer is a process that can retry a function
+// Retryer is a process that can retry a function.
+// The interval between attempts starts with Delay and doubles with every attempt up to MaxDelay
 type Retryer struct {
-	// Delay specifies the interval between retry attempts
+	// Delay specifies the initial interval between retry attempts
 	Delay time.Duration
+	// MaxDelay specifies the maximum interval between retry attempts.
+	// Defaults to defaults.RetryMaxDelay
+	MaxDelay time.Duration
+	// Jitter specifies the fraction of the interval to randomize it by,
+	// i.e. with 0.2 the interval varies by up to 20% in either direction.
+	// This keeps concurrent retries of the same operation from synchronizing
+	Jitter float64
 	// Attempts specifies the number of attempts to execute before failing.
-	// Should be >= 1, zero value is not useful
+	// If zero, the number of attempts is only limited by Budget and the context
 	Attempts int
+	// Budget optionally limits the total time spent retrying
+	Budget time.Duration
 	// FieldLogger specifies the log sink
 	log.FieldLogger
 }
 
+// delay returns the interval to wait after the specified (1-based) attempt
+func (r Retryer) delay(attempt int) time.Duration {
+	maxDelay := r.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = defaults.RetryMaxDelay
+	}
+	delay := backoff(r.Delay, maxDelay, attempt)
+	if r.Jitter > 0 {
+		delay = time.Duration(float64(delay) * (1 - r.Jitter + 2*r.Jitter*rand.Float64()))
+	}
+	return delay
+}
+
 // RetryWithInterval retries the specified operation fn using the specified
 // backoff interval
 func RetryWithInterval(ctx context.Context, interval libbackoff.BackOff, fn func() error, logger log.FieldLogger) error {
@@ -128,11 +163,13 @@ func NewUnlimitedExponentialBackoff() libbackoff.BackOff {
 	return b
 }
 
-func backoff(baseDelay time.Duration, errCount int) time.Duration {
-	delay := baseDelay * time.Duration(math.Pow(2, float64(errCount)-1))
-	if delay > defaults.RetryMaxDelay {
-		return defaults.RetryMaxDelay
-	} else {
-		return delay
+func backoff(baseDelay, maxDelay time.Duration, errCount int) time.Duration {
+	delay := baseDelay
+	for i := 1; i < errCount && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		return maxDelay
 	}
+	return delay
 }
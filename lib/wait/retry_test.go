@@ -0,0 +1,52 @@
+package wait
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryerDelay(t *testing.T) {
+	r := Retryer{Delay: time.Second, MaxDelay: 5 * time.Second}
+	var delays []time.Duration
+	for i := 1; i <= 5; i++ {
+		delays = append(delays, r.delay(i))
+	}
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}, delays)
+	assert.Equal(t, 5*time.Second, r.delay(1000), "large attempt counts do not overflow")
+
+	r.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		delay := r.delay(1)
+		assert.True(t, delay >= 500*time.Millisecond && delay <= 1500*time.Millisecond, "jittered delay %v", delay)
+	}
+}
+
+func TestRetryerBudget(t *testing.T) {
+	r := Retryer{Delay: 10 * time.Millisecond, MaxDelay: 10 * time.Millisecond, Budget: 55 * time.Millisecond}
+	attempts := 0
+	err := r.Do(context.TODO(), func() error {
+		attempts++
+		return fmt.Errorf("failed")
+	})
+	assert.Error(t, err)
+	assert.True(t, attempts >= 2 && attempts <= 6, "attempts %v", attempts)
+}
+
+func TestRetryerStopsBeforeDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.TODO(), 100*time.Millisecond)
+	defer cancel()
+	r := Retryer{Delay: time.Minute, Attempts: 10}
+	attempts := 0
+	start := time.Now()
+	err := r.Do(ctx, func() error {
+		attempts++
+		return fmt.Errorf("failed")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, attempts)
+	assert.True(t, time.Since(start) < 100*time.Millisecond, "does not wait for the deadline")
+}
@@ -3,18 +3,27 @@ package xlog
 import (
 	"io/ioutil"
 
+	"github.com/gravitational/robotest/lib/secret"
+
 	"github.com/sirupsen/logrus"
 )
 
-// ConsoleLogger returns logger which writes everything to file plus console for events above certain level
+// ConsoleLogger returns logger which writes everything to file plus console for events above certain level.
+// The level is overridden per module and the console output is JSON as set with Configure
 func ConsoleLogger(consoleLevel logrus.Level, stackDepth int) *logrus.Logger {
 	log := logrus.New()
 	log.Level = logrus.DebugLevel
 	log.Out = ioutil.Discard
+	// redact secrets before the entries are forwarded by the other hooks
+	log.Hooks.Add(secret.Hook{})
 
 	consoleLog := logrus.New()
-	consoleLog.Level = consoleLevel
+	// the entries are filtered by the hook
+	consoleLog.Level = logrus.DebugLevel
 	consoleLog.Formatter = &logrus.TextFormatter{DisableTimestamp: true}
+	if jsonOutput() {
+		consoleLog.Formatter = &logrus.JSONFormatter{}
+	}
 	log.Hooks.Add(&consoleHook{consoleLog, consoleLevel, stackDepth})
 
 	return log
@@ -27,7 +36,7 @@ type consoleHook struct {
 }
 
 func (hook *consoleHook) Fire(e *logrus.Entry) error {
-	if e.Level > hook.level {
+	if !enabled(e, hook.level) {
 		return nil
 	}
 
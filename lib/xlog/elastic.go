@@ -0,0 +1,301 @@
+package xlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gravitational/trace"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ElasticExporter indexes the test results and the key log events (warnings and errors)
+// into Elasticsearch (or OpenSearch): the results into the <prefix>-results index
+// and the events into the <prefix>-events index, both created with the mappings below
+type ElasticExporter struct {
+	client *http.Client
+	url    string
+	prefix string
+
+	// mu guards events against the hooks fired after Close
+	mu     sync.Mutex
+	events chan ElasticEvent
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// ElasticResult is the document indexed for each test result
+type ElasticResult struct {
+	Timestamp time.Time `json:"@timestamp"`
+	// RunID identifies the test the result is of, see ElasticEvent.RunID
+	RunID string `json:"run_id"`
+	// SuiteUID identifies the run of the test suite
+	SuiteUID string `json:"suite_uid"`
+	Suite    string `json:"suite"`
+	// Test names the scheduled test, the same for all attempts
+	Test          string  `json:"test"`
+	Attempt       int     `json:"attempt"`
+	Status        string  `json:"status"`
+	Category      string  `json:"category,omitempty"`
+	Error         string  `json:"error,omitempty"`
+	Preempted     bool    `json:"preempted"`
+	Quarantined   bool    `json:"quarantined"`
+	LogURL        string  `json:"log_url,omitempty"`
+	EstimatedCost float64 `json:"estimated_cost"`
+	// Param is the test configuration as JSON
+	Param string `json:"param,omitempty"`
+}
+
+// ElasticEvent is the document indexed for each key log event
+type ElasticEvent struct {
+	Timestamp time.Time `json:"@timestamp"`
+	Level     string    `json:"level"`
+	Message   string    `json:"message"`
+	// RunID identifies the test that logged the event, empty outside of tests
+	RunID  string `json:"run_id,omitempty"`
+	Suite  string `json:"suite,omitempty"`
+	Test   string `json:"test,omitempty"`
+	Phase  string `json:"phase,omitempty"`
+	Node   string `json:"node,omitempty"`
+	Module string `json:"module,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// NewElasticExporter returns the exporter to the Elasticsearch cluster at url
+// with the indices named after prefix, creating the indices if necessary
+func NewElasticExporter(ctx context.Context, url, prefix string) (*ElasticExporter, error) {
+	if url == "" || prefix == "" {
+		return nil, trace.BadParameter("Elasticsearch URL and index prefix are required")
+	}
+	exporter := &ElasticExporter{
+		client: &http.Client{Timeout: elasticTimeout},
+		url:    strings.TrimSuffix(url, "/"),
+		prefix: prefix,
+		events: make(chan ElasticEvent, elasticEventQueue),
+	}
+	for index, mapping := range map[string]string{
+		exporter.resultsIndex(): resultsMapping,
+		exporter.eventsIndex():  eventsMapping,
+	} {
+		if err := exporter.createIndex(ctx, index, mapping); err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	exporter.wg.Add(1)
+	go exporter.indexEvents()
+	return exporter, nil
+}
+
+// IndexResult indexes the test result
+func (r *ElasticExporter) IndexResult(ctx context.Context, result ElasticResult) error {
+	return trace.Wrap(r.index(ctx, r.resultsIndex(), result))
+}
+
+// Hook returns the logrus hook that indexes the warnings and errors.
+// The events are indexed in the background and dropped if the queue is full
+// so that logging is never blocked by the cluster
+func (r *ElasticExporter) Hook() logrus.Hook {
+	return elasticHook{r}
+}
+
+// Close indexes the queued events and stops the exporter.
+// The events logged after Close are dropped
+func (r *ElasticExporter) Close() {
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.events)
+	}
+	r.mu.Unlock()
+	r.wg.Wait()
+}
+
+func (r *ElasticExporter) indexEvents() {
+	defer r.wg.Done()
+	for event := range r.events {
+		ctx, cancel := context.WithTimeout(context.Background(), elasticTimeout)
+		err := r.index(ctx, r.eventsIndex(), event)
+		cancel()
+		if err != nil {
+			// the standard logger is not used as it might have the hook of this exporter
+			fmt.Fprintf(os.Stderr, "Failed to index log event: %v.\n", trace.UserMessage(err))
+		}
+	}
+}
+
+// createIndex creates the index with the mapping unless the index already exists
+func (r *ElasticExporter) createIndex(ctx context.Context, index, mapping string) error {
+	status, out, err := r.roundTrip(ctx, http.MethodPut, index, []byte(mapping))
+	if err != nil {
+		return trace.Wrap(err, "failed to create index %v", index)
+	}
+	if isSuccess(status) || (status == http.StatusBadRequest && errorType(out) == indexExistsError) {
+		return nil
+	}
+	return trace.BadParameter("failed to create index %v: %v %s", index, status, out)
+}
+
+func (r *ElasticExporter) index(ctx context.Context, index string, doc interface{}) error {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(r.do(ctx, http.MethodPost, index+"/_doc", data))
+}
+
+func (r *ElasticExporter) do(ctx context.Context, method, path string, body []byte) error {
+	status, out, err := r.roundTrip(ctx, method, path, body)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if !isSuccess(status) {
+		return trace.BadParameter("%v %v: %v %s", method, path, status, out)
+	}
+	return nil
+}
+
+// roundTrip sends the request and returns the status code and the body of the response
+func (r *ElasticExporter) roundTrip(ctx context.Context, method, path string, body []byte) (status int, out []byte, err error) {
+	req, err := http.NewRequest(method, r.url+"/"+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, trace.Wrap(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, nil, trace.ConvertSystemError(err)
+	}
+	defer resp.Body.Close()
+	out, _ = ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, out, nil
+}
+
+func isSuccess(status int) bool {
+	return status >= 200 && status <= 299
+}
+
+// errorType returns the type of the error in the Elasticsearch error response,
+// i.e. {"error":{"type":"resource_already_exists_exception",...},"status":400}
+func errorType(out []byte) string {
+	var resp struct {
+		Error struct {
+			Type string `json:"type"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(out, &resp); err != nil {
+		return ""
+	}
+	return resp.Error.Type
+}
+
+func (r *ElasticExporter) resultsIndex() string {
+	return r.prefix + "-results"
+}
+
+func (r *ElasticExporter) eventsIndex() string {
+	return r.prefix + "-events"
+}
+
+type elasticHook struct {
+	*ElasticExporter
+}
+
+// Fire queues the entry for indexing
+func (r elasticHook) Fire(e *logrus.Entry) error {
+	event := ElasticEvent{
+		Timestamp: e.Time,
+		Level:     e.Level.String(),
+		Message:   e.Message,
+		RunID:     stringField(e, "run_id"),
+		Suite:     stringField(e, "suite"),
+		Test:      stringField(e, "name"),
+		Phase:     stringField(e, "phase"),
+		Node:      stringField(e, "node"),
+		Module:    stringField(e, ModuleField),
+	}
+	if err, ok := e.Data[logrus.ErrorKey].(error); ok {
+		event.Error = trace.UserMessage(err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	select {
+	case r.events <- event:
+	default:
+	}
+	return nil
+}
+
+// Levels returns the levels of the key events
+func (r elasticHook) Levels() []logrus.Level {
+	return []logrus.Level{
+		logrus.PanicLevel,
+		logrus.FatalLevel,
+		logrus.ErrorLevel,
+		logrus.WarnLevel,
+	}
+}
+
+func stringField(e *logrus.Entry, key string) string {
+	if value, ok := e.Data[key]; ok {
+		return fmt.Sprint(value)
+	}
+	return ""
+}
+
+const (
+	// elasticTimeout is the timeout of a single request to Elasticsearch
+	elasticTimeout = 30 * time.Second
+	// elasticEventQueue is the number of events queued for indexing
+	elasticEventQueue = 1000
+	// indexExistsError is the error type of the response to the creation of an existing index
+	indexExistsError = "resource_already_exists_exception"
+)
+
+const resultsMapping = `{
+  "mappings": {
+    "properties": {
+      "@timestamp": {"type": "date"},
+      "run_id": {"type": "keyword"},
+      "suite_uid": {"type": "keyword"},
+      "suite": {"type": "keyword"},
+      "test": {"type": "keyword"},
+      "attempt": {"type": "integer"},
+      "status": {"type": "keyword"},
+      "category": {"type": "keyword"},
+      "error": {"type": "text"},
+      "preempted": {"type": "boolean"},
+      "quarantined": {"type": "boolean"},
+      "log_url": {"type": "keyword", "index": false},
+      "estimated_cost": {"type": "double"},
+      "param": {"type": "text"}
+    }
+  }
+}`
+
+const eventsMapping = `{
+  "mappings": {
+    "properties": {
+      "@timestamp": {"type": "date"},
+      "level": {"type": "keyword"},
+      "message": {"type": "text"},
+      "run_id": {"type": "keyword"},
+      "suite": {"type": "keyword"},
+      "test": {"type": "keyword"},
+      "phase": {"type": "keyword"},
+      "node": {"type": "keyword"},
+      "module": {"type": "keyword"},
+      "error": {"type": "text"}
+    }
+  }
+}`
@@ -0,0 +1,81 @@
+package xlog
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gravitational/trace"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestElasticExporter(t *testing.T) {
+	var mu sync.Mutex
+	requests := map[string][]map[string]interface{}{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var doc map[string]interface{}
+		data, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(data, &doc); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		key := r.Method + " " + r.URL.Path
+		if r.URL.Path == "/test-results" && len(requests[key]) > 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"type":"resource_already_exists_exception"}}`))
+		}
+		requests[key] = append(requests[key], doc)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	exporter, err := NewElasticExporter(ctx, server.URL, "test")
+	require.NoError(t, err)
+	exporter.Close()
+	// indices already exist
+	exporter, err = NewElasticExporter(ctx, server.URL+"/", "test")
+	require.NoError(t, err)
+
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	logger.Hooks.Add(exporter.Hook())
+	log := logger.WithFields(logrus.Fields{"run_id": "run-1", "phase": "install", "node": "10.0.0.1"})
+	log.Info("Not a key event.")
+	log.WithError(trace.NotFound("no such node")).Warn("Failed to reach node.")
+	require.NoError(t, exporter.IndexResult(ctx, ElasticResult{RunID: "run-1", Status: "FAILED", Attempt: 1}))
+	exporter.Close()
+	log.Error("After close.")
+
+	require.Len(t, requests["PUT /test-results"], 2)
+	require.Len(t, requests["PUT /test-events"], 2)
+	require.Contains(t, requests["PUT /test-events"][0], "mappings")
+	require.Len(t, requests["POST /test-results/_doc"], 1)
+	require.Equal(t, "FAILED", requests["POST /test-results/_doc"][0]["status"])
+	events := requests["POST /test-events/_doc"]
+	require.Len(t, events, 1)
+	require.Equal(t, "warning", events[0]["level"])
+	require.Equal(t, "Failed to reach node.", events[0]["message"])
+	require.Equal(t, "run-1", events[0]["run_id"])
+	require.Equal(t, "install", events[0]["phase"])
+	require.Equal(t, "10.0.0.1", events[0]["node"])
+	require.Equal(t, "no such node", events[0]["error"])
+}
+
+func TestElasticExporterFailsToCreateIndex(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"type":"mapper_parsing_exception","reason":"resource_already_exists_exception"}}`))
+	}))
+	defer server.Close()
+
+	_, err := NewElasticExporter(context.Background(), server.URL, "test")
+	require.Error(t, err, "only the error type is matched")
+}
@@ -0,0 +1,41 @@
+package xlog
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+// FieldsHook adds the fields it returns to every entry at the time the entry is logged,
+// i.e. for the fields that change over the lifetime of the logger.
+// Fields set on the entry take precedence
+type FieldsHook func() logrus.Fields
+
+// Fire adds the fields to the entry
+func (r FieldsHook) Fire(e *logrus.Entry) error {
+	fields := r()
+	if len(fields) == 0 {
+		return nil
+	}
+	// the data is shared with the entry the logged entry has been derived from
+	data := make(logrus.Fields, len(e.Data)+len(fields))
+	for key, value := range fields {
+		data[key] = value
+	}
+	for key, value := range e.Data {
+		data[key] = value
+	}
+	e.Data = data
+	return nil
+}
+
+// Levels returns logging levels supported by logrus
+func (r FieldsHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// PrependHook registers the hook with logger to fire before the hooks already registered,
+// so that the existing hooks, i.e. the console and the cloud logging, see the changes of hook
+func PrependHook(logger *logrus.Logger, hook logrus.Hook) {
+	for _, level := range hook.Levels() {
+		logger.Hooks[level] = append([]logrus.Hook{hook}, logger.Hooks[level]...)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"cloud.google.com/go/pubsub"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/oauth2/google"
+	mrpb "google.golang.org/genproto/googleapis/api/monitoredres"
 )
 
 const (
@@ -36,6 +37,7 @@ type GCLClient struct {
 	pubsubClient    *pubsub.Client
 	topic           *pubsub.Topic
 	ctx             context.Context
+	projectID       string
 }
 
 func (client *GCLClient) Close() {
@@ -55,7 +57,7 @@ func NewGCLClient(ctx context.Context, projectID string) (client *GCLClient, err
 		return nil, trace.Errorf("no cloud logging project ID provided")
 	}
 
-	client = &GCLClient{ctx: ctx}
+	client = &GCLClient{ctx: ctx, projectID: projectID}
 
 	// URL shortener API
 	client.shortenerClient, err = google.DefaultClient(ctx, urlShortenerScope)
@@ -106,6 +108,35 @@ func (c *GCLClient) Context() context.Context {
 
 // Hook returns logrus log hook
 func (c *GCLClient) Hook(name string, fields logrus.Fields) *GCLHook {
+	return &GCLHook{
+		log:          c.gclClient.Logger(name, cl.CommonLabels(labels(fields))),
+		commonFields: fields,
+	}
+}
+
+// RunHook returns the logrus hook that ships the entries into the log name
+// attributed to the run: the entries are logged against the generic_task resource
+// labeled with the robotest namespace, job as the job and the run ID as the task.
+// The hook is meant for the orchestration logs outside of the tests,
+// i.e. the standard logger, as the tests log with the hooks returned by Hook
+func (c *GCLClient) RunHook(name, job, runID string, fields logrus.Fields) *GCLHook {
+	resource := &mrpb.MonitoredResource{
+		Type: "generic_task",
+		Labels: map[string]string{
+			"project_id": c.projectID,
+			"location":   "global",
+			"namespace":  "robotest",
+			"job":        job,
+			"task_id":    runID,
+		},
+	}
+	return &GCLHook{
+		log:          c.gclClient.Logger(name, cl.CommonLabels(labels(fields)), cl.CommonResource(resource)),
+		commonFields: fields,
+	}
+}
+
+func labels(fields logrus.Fields) map[string]string {
 	labels := map[string]string{}
 	for k, v := range fields {
 		switch value := v.(type) {
@@ -115,11 +146,7 @@ func (c *GCLClient) Hook(name string, fields logrus.Fields) *GCLHook {
 			labels[k] = ToJSON(value)
 		}
 	}
-
-	return &GCLHook{
-		log:          c.gclClient.Logger(name, cl.CommonLabels(labels)),
-		commonFields: fields,
-	}
+	return labels
 }
 
 func ToJSON(obj interface{}) string {
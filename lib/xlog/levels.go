@@ -0,0 +1,141 @@
+package xlog
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// ModuleField is the log field naming the module an entry originates from
+const ModuleField = "module"
+
+const (
+	// ModuleInfra is the module of the infrastructure provisioning, i.e. terraform
+	ModuleInfra = "infra"
+	// ModuleSSH is the module of the SSH transport
+	ModuleSSH = "ssh"
+	// ModuleGravity is the module of the cluster orchestration
+	ModuleGravity = "gravity"
+	// ModuleE2E is the module of the UI tests
+	ModuleE2E = "e2e"
+)
+
+// Config configures the log levels per module and the log output format
+type Config struct {
+	// Level is the level of the entries not attributed to a configured module
+	Level logrus.Level
+	// Modules optionally overrides the level per module.
+	// Modules are hierarchical: the level of ssh applies to ssh.scp unless configured separately
+	Modules map[string]logrus.Level
+	// JSON requests the entries to be written as JSON objects
+	JSON bool
+}
+
+// Configure sets the log configuration for the loggers created with ConsoleLogger
+// and applies it to the standard logger
+func Configure(config Config) {
+	configMu.Lock()
+	current = config
+	configMu.Unlock()
+
+	logrus.SetLevel(config.maxLevel())
+	logrus.SetFormatter(&moduleFormatter{Formatter: newFormatter(config.JSON), level: config.Level})
+}
+
+// Module returns the logger that attributes its entries to module
+func Module(log logrus.FieldLogger, module string) logrus.FieldLogger {
+	return log.WithField(ModuleField, module)
+}
+
+// ParseModuleLevels parses the levels per module in the module=level,... format,
+// i.e. ssh=debug,gravity=info
+func ParseModuleLevels(spec string) (map[string]logrus.Level, error) {
+	levels := make(map[string]logrus.Level)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, trace.BadParameter("expected module=level, got %q", item)
+		}
+		level, err := logrus.ParseLevel(kv[1])
+		if err != nil {
+			return nil, trace.BadParameter("invalid level of module %v: %v", kv[0], err)
+		}
+		levels[kv[0]] = level
+	}
+	return levels, nil
+}
+
+// levelOf returns the level of the module of the entry,
+// or the fallback level if no level is configured for the module
+func (r Config) levelOf(e *logrus.Entry, fallback logrus.Level) logrus.Level {
+	module, _ := e.Data[ModuleField].(string)
+	for module != "" {
+		if level, ok := r.Modules[module]; ok {
+			return level
+		}
+		i := strings.LastIndex(module, ".")
+		if i < 0 {
+			break
+		}
+		module = module[:i]
+	}
+	return fallback
+}
+
+// maxLevel returns the most verbose of the configured levels
+func (r Config) maxLevel() logrus.Level {
+	level := r.Level
+	for _, moduleLevel := range r.Modules {
+		if moduleLevel > level {
+			level = moduleLevel
+		}
+	}
+	return level
+}
+
+// enabled returns true if the entry is to be logged given the fallback level
+func enabled(e *logrus.Entry, fallback logrus.Level) bool {
+	configMu.Lock()
+	config := current
+	configMu.Unlock()
+	return e.Level <= config.levelOf(e, fallback)
+}
+
+// jsonOutput returns true if JSON output has been configured
+func jsonOutput() bool {
+	configMu.Lock()
+	defer configMu.Unlock()
+	return current.JSON
+}
+
+func newFormatter(json bool) logrus.Formatter {
+	if json {
+		return &logrus.JSONFormatter{}
+	}
+	return &logrus.TextFormatter{}
+}
+
+// moduleFormatter drops the entries above the level of their module
+type moduleFormatter struct {
+	logrus.Formatter
+	level logrus.Level
+}
+
+// Format formats the entry if enabled for its module
+func (r *moduleFormatter) Format(e *logrus.Entry) ([]byte, error) {
+	if !enabled(e, r.level) {
+		return nil, nil
+	}
+	return r.Formatter.Format(e)
+}
+
+var (
+	configMu sync.Mutex
+	current  = Config{Level: logrus.InfoLevel}
+)
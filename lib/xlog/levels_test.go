@@ -0,0 +1,58 @@
+package xlog
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModuleLevels(t *testing.T) {
+	modules, err := ParseModuleLevels("ssh=debug, gravity=warn")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]logrus.Level{"ssh": logrus.DebugLevel, "gravity": logrus.WarnLevel}, modules)
+
+	config := Config{Level: logrus.InfoLevel, Modules: modules}
+	entry := func(module string) *logrus.Entry {
+		return logrus.WithField(ModuleField, module)
+	}
+	assert.Equal(t, logrus.DebugLevel, config.levelOf(entry("ssh.scp"), config.Level))
+	assert.Equal(t, logrus.WarnLevel, config.levelOf(entry("gravity"), config.Level))
+	assert.Equal(t, logrus.InfoLevel, config.levelOf(entry("infra"), config.Level))
+	assert.Equal(t, logrus.InfoLevel, config.levelOf(logrus.NewEntry(logrus.StandardLogger()), config.Level))
+	assert.Equal(t, logrus.DebugLevel, config.maxLevel())
+
+	_, err = ParseModuleLevels("ssh")
+	assert.Error(t, err)
+	_, err = ParseModuleLevels("ssh=loud")
+	assert.Error(t, err)
+}
+
+func TestFieldsHook(t *testing.T) {
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	var seen logrus.Fields
+	logger.Hooks.Add(recordHook(func(e *logrus.Entry) { seen = e.Data }))
+	phase := "install"
+	// registered last to fire first
+	PrependHook(logger, FieldsHook(func() logrus.Fields { return logrus.Fields{"phase": phase, "node": "default"} }))
+
+	log := logger.WithField("node", "10.0.0.1")
+	log.Info("Installing.")
+	assert.Equal(t, logrus.Fields{"phase": "install", "node": "10.0.0.1"}, seen)
+	phase = "upgrade"
+	log.Info("Upgrading.")
+	assert.Equal(t, logrus.Fields{"phase": "upgrade", "node": "10.0.0.1"}, seen)
+	assert.Equal(t, logrus.Fields{"node": "10.0.0.1"}, log.Data)
+}
+
+type recordHook func(e *logrus.Entry)
+
+func (r recordHook) Fire(e *logrus.Entry) error {
+	r(e)
+	return nil
+}
+
+func (r recordHook) Levels() []logrus.Level { return logrus.AllLevels }
@@ -14,10 +14,23 @@ type ProgressReporter struct {
 	uploader *bigquery.Uploader
 }
 
+// ProgressOptionSetter configures the uploader of the progress reporter
+type ProgressOptionSetter func(uploader *bigquery.Uploader)
+
+// IgnoreUnknownValues drops the values of the columns missing from the table schema
+// instead of failing the insert, i.e. for the columns (estimated_cost) added after
+// the table has been created
+func IgnoreUnknownValues() ProgressOptionSetter {
+	return func(uploader *bigquery.Uploader) {
+		uploader.IgnoreUnknownValues = true
+	}
+}
+
 var reporters sync.Map
 
-// NewProgressReporter initializes progress reporter
-func NewProgressReporter(ctx context.Context, projectID, datasetID, tableID string) (*ProgressReporter, error) {
+// NewProgressReporter initializes progress reporter.
+// The options only apply when the reporter for the table is first created
+func NewProgressReporter(ctx context.Context, projectID, datasetID, tableID string, opts ...ProgressOptionSetter) (*ProgressReporter, error) {
 	key := fmt.Sprintf("%s-%s-%s", projectID, datasetID, tableID)
 	stored, ok := reporters.Load(key)
 	if ok {
@@ -29,8 +42,12 @@ func NewProgressReporter(ctx context.Context, projectID, datasetID, tableID stri
 		return nil, trace.ConvertSystemError(err)
 	}
 
+	uploader := client.Dataset(datasetID).Table(tableID).Uploader()
+	for _, opt := range opts {
+		opt(uploader)
+	}
 	rep := ProgressReporter{
-		uploader: client.Dataset(datasetID).Table(tableID).Uploader(),
+		uploader: uploader,
 	}
 
 	reporters.Store(key, &rep)
@@ -30,7 +30,7 @@ func (hook *TestingHook) Levels() []logrus.Level {
 }
 
 // NewLogger returns logger which also prints everything to console
-func NewLogger(client *GCLClient, t *testing.T, commonFields logrus.Fields) logrus.FieldLogger {
+func NewLogger(client *GCLClient, t *testing.T, commonFields logrus.Fields) *logrus.Logger {
 	consoleLevel := logrus.InfoLevel
 	consoleStack := 1
 	if client == nil {
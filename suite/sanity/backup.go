@@ -0,0 +1,43 @@
+package sanity
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/gravitational/robotest/infra/gravity"
+	"github.com/gravitational/robotest/lib/defaults"
+)
+
+// backupRestore installs a cluster, backs up the application data, destroys the cluster
+// state by uninstalling the cluster from all nodes and verifies that the workload data
+// is brought back by restoring the backup into a newly installed cluster
+func backupRestore(p interface{}) (gravity.TestFunc, error) {
+	param := p.(installParam)
+
+	return func(g *gravity.TestContext, cfg gravity.ProvisionerConfig) {
+		cluster, err := provisionNodes(g, cfg, param)
+		g.OK("provision nodes", err)
+		defer func() {
+			g.Maybe("destroy", cluster.Destroy())
+		}()
+
+		g.OK("download installer", g.SetInstaller(cluster.Nodes, cfg.InstallerURL, "install"))
+		g.OK("install", g.OfflineInstall(cluster.Nodes, param.InstallParam))
+		g.OK("status", g.Status(cluster.Nodes))
+
+		master := cluster.Nodes[0]
+		backupPath := filepath.Join(defaults.TmpDir, "robotest-backup.tar.gz")
+		marker := fmt.Sprintf("%v", time.Now().Unix())
+		g.OK("write workload data", g.WriteWorkloadData(master, marker))
+		g.OK("backup", g.Backup(master, backupPath))
+		// the backup outside of the state directory survives the uninstall
+		g.OK("uninstall", g.Uninstall(cluster.Nodes))
+		g.OK("reinstall", g.OfflineInstall(cluster.Nodes, param.InstallParam))
+		g.OK("status", g.Status(cluster.Nodes))
+		g.Require("no workload data before restore", g.VerifyWorkloadData(master, marker) != nil)
+		g.OK("restore", g.Restore(master, backupPath))
+		g.OK("status", g.Status(cluster.Nodes))
+		g.OK("verify workload data", g.VerifyWorkloadData(master, marker))
+	}, nil
+}
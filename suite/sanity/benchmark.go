@@ -0,0 +1,94 @@
+package sanity
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gravitational/robotest/infra/gravity"
+
+	"github.com/gravitational/trace"
+)
+
+type benchmarkParam struct {
+	installParam
+	// Operation names the operation to benchmark: install or upgrade
+	Operation string `json:"operation" validate:"required,eq=install|eq=upgrade"`
+	// Runs is the number of times to run the operation, each on a new cluster
+	Runs int `json:"runs" validate:"required,gt=0"`
+	// BaseInstallerURL is the installer to install before the upgrade
+	BaseInstallerURL string `json:"from"`
+	// Baseline optionally specifies the path to the benchmark report to compare with
+	Baseline string `json:"baseline"`
+	// MaxRegressionPercent is the slowdown of the median duration compared to the baseline
+	// that fails the benchmark
+	MaxRegressionPercent int `json:"max_regression_percent" validate:"gte=0"`
+}
+
+// benchmark runs the operation on a number of identical clusters and summarizes
+// the durations of the operation and its plan phases.
+// The summary is compared with the baseline, if any, and the benchmark fails
+// on significant regressions
+func benchmark(p interface{}) (gravity.TestFunc, error) {
+	param := p.(benchmarkParam)
+	if param.Operation == "upgrade" && param.BaseInstallerURL == "" {
+		return nil, trace.BadParameter("upgrade benchmark requires the base installer")
+	}
+	var baseline *gravity.BenchmarkReport
+	if param.Baseline != "" {
+		var err error
+		baseline, err = gravity.LoadBenchmarkReport(param.Baseline)
+		if err != nil {
+			return nil, trace.Wrap(err, "failed to load baseline from %v", param.Baseline)
+		}
+	}
+
+	return func(g *gravity.TestContext, cfg gravity.ProvisionerConfig) {
+		var samples []gravity.BenchmarkSample
+		for i := 1; i <= param.Runs; i++ {
+			samples = append(samples, benchmarkRun(g, cfg.WithTag(fmt.Sprintf("run%v", i)), param))
+		}
+		report := gravity.NewBenchmarkReport(param.Operation, samples)
+		g.Logger().WithField("median", report.Total.Median).WithField("stddev", report.Total.StdDev).
+			Info("Benchmark complete.")
+		g.Maybe("save benchmark report", report.Save(filepath.Join(cfg.StateDir, "benchmark.json")))
+		if baseline == nil {
+			return
+		}
+		regressions := gravity.CompareBenchmark(*baseline, report, float64(param.MaxRegressionPercent)/100)
+		var descriptions []string
+		for _, regression := range regressions {
+			descriptions = append(descriptions, regression.String())
+		}
+		g.Require("no regressions compared to baseline", len(regressions) == 0, strings.Join(descriptions, ", "))
+	}, nil
+}
+
+// benchmarkRun runs the benchmarked operation once on a new cluster
+func benchmarkRun(g *gravity.TestContext, cfg gravity.ProvisionerConfig, param benchmarkParam) (sample gravity.BenchmarkSample) {
+	cluster, err := provisionNodes(g, cfg, param.installParam)
+	g.OK("provision nodes", err)
+	defer func() {
+		g.Maybe("destroy", cluster.Destroy())
+	}()
+
+	var start time.Time
+	switch param.Operation {
+	case "install":
+		g.OK("download installer", g.SetInstaller(cluster.Nodes, cfg.InstallerURL, "install"))
+		start = time.Now()
+		g.OK("install", g.OfflineInstall(cluster.Nodes, param.InstallParam))
+	case "upgrade":
+		g.OK("base installer", g.SetInstaller(cluster.Nodes, param.BaseInstallerURL, "base"))
+		g.OK("install", g.OfflineInstall(cluster.Nodes, param.InstallParam))
+		g.OK("install status", g.Status(cluster.Nodes))
+		start = time.Now()
+		g.OK("upgrade", g.Upgrade(cluster.Nodes, cfg.InstallerURL, cfg.GravityURL, "upgrade"))
+	}
+	sample.Total = time.Since(start)
+	g.OK("status", g.Status(cluster.Nodes))
+	sample.Phases, err = g.PhaseDurations(cluster.Nodes[0], start)
+	g.OK("phase durations", err)
+	return sample
+}
@@ -0,0 +1,62 @@
+package sanity
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/gravitational/robotest/infra/gravity"
+
+	"github.com/gravitational/trace"
+)
+
+type certRotateParam struct {
+	installParam
+	// ValidityHours is the validity of the shortened certificates in hours
+	ValidityHours int `json:"validity_hours" validate:"required,gt=0"`
+	// Probe is the regular expression the failed health probe reported
+	// for the expired certificates is expected to match
+	Probe string `json:"probe" validate:"required"`
+}
+
+// defaultCertExpiryProbe matches the x509 verification error the health probes
+// report for the expired certificates, i.e.
+//
+//	apiserver: Get https://leader.telekube.local:6443/healthz: x509: certificate has expired or is not yet valid
+//
+// Other failed probes (i.e. an apiserver that is still starting) are not matched
+const defaultCertExpiryProbe = `x509: certificate has expired`
+
+// certRotate installs a cluster, shortens the validity of the cluster certificates
+// and moves the clocks past it, then verifies that the expired certificates are
+// detected and the cluster recovers once they are rotated
+func certRotate(p interface{}) (gravity.TestFunc, error) {
+	param := p.(certRotateParam)
+	probe, err := regexp.Compile(param.Probe)
+	if err != nil {
+		return nil, trace.BadParameter("invalid probe pattern %q: %v", param.Probe, err)
+	}
+
+	return func(g *gravity.TestContext, cfg gravity.ProvisionerConfig) {
+		cluster, err := provisionNodes(g, cfg, param.installParam)
+		g.OK("provision nodes", err)
+		defer func() {
+			g.Maybe("destroy", cluster.Destroy())
+		}()
+
+		g.OK("download installer", g.SetInstaller(cluster.Nodes, cfg.InstallerURL, "install"))
+		g.OK("install", g.OfflineInstall(cluster.Nodes, param.InstallParam))
+		g.OK("install status", g.Status(cluster.Nodes))
+
+		validity := time.Duration(param.ValidityHours) * time.Hour
+		g.OK("shorten certificates", g.RotateCertificates(cluster.Nodes, validity))
+		g.OK("status with shortened certificates", g.Status(cluster.Nodes))
+		_, err = g.ExpireCertificates(cluster.Nodes)
+		g.OK("expire certificates", err)
+		g.OK("expired certificates detected", g.WaitForFailedProbe(cluster.Nodes, probe))
+
+		g.OK("rotate certificates", g.RotateCertificates(cluster.Nodes, 0))
+		g.OK("status after rotation", g.Status(cluster.Nodes))
+		g.OK("certificates valid", g.AssertCertificatesValid(cluster.Nodes))
+		g.OK("no failed probe", g.AssertNoFailedProbe(cluster.Nodes, probe))
+	}, nil
+}
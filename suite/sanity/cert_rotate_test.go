@@ -0,0 +1,24 @@
+package sanity
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCertExpiryProbe(t *testing.T) {
+	var testCases = []struct {
+		probe    string
+		expected bool
+	}{
+		{"10.0.0.1: apiserver: Get https://leader.telekube.local:6443/healthz: x509: certificate has expired or is not yet valid", true},
+		{"10.0.0.1: etcd-healthz: remote error: x509: certificate has expired or is not yet valid", true},
+		{"10.0.0.1: apiserver: Get https://leader.telekube.local:6443/healthz: dial tcp 10.0.0.1:6443: connect: connection refused", false},
+		{"10.0.0.1: cert-manager: deployment is not ready", false},
+	}
+	probe := regexp.MustCompile(defaultCertExpiryProbe)
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, probe.MatchString(tc.probe), tc.probe)
+	}
+}
@@ -0,0 +1,54 @@
+package sanity
+
+import (
+	"time"
+
+	"github.com/gravitational/robotest/infra/gravity"
+)
+
+type chaosParam struct {
+	installParam
+	// Seed seeds the fault schedule. Set to the seed from the report of a failed run to replay it
+	Seed int64 `json:"seed"`
+	// DurationMinutes is how long to inject faults for
+	DurationMinutes int `json:"duration_minutes" validate:"required,gt=0"`
+	// MinIntervalSeconds and MaxIntervalSeconds bound the time between faults
+	MinIntervalSeconds int `json:"min_interval_seconds" validate:"required,gt=0"`
+	MaxIntervalSeconds int `json:"max_interval_seconds" validate:"required,gtefield=MinIntervalSeconds"`
+	// Faults lists the faults to inject: reboot, partition, kill_service or slow_disk.
+	// Defaults to reboot, partition and kill_service
+	Faults []gravity.ChaosFault `json:"faults" validate:"dive,eq=reboot|eq=partition|eq=kill_service|eq=slow_disk"`
+}
+
+// chaos installs a cluster and injects random faults on a schedule derived
+// from the seed, then verifies the cluster recovers
+func chaos(p interface{}) (gravity.TestFunc, error) {
+	param := p.(chaosParam)
+
+	return func(g *gravity.TestContext, cfg gravity.ProvisionerConfig) {
+		cluster, err := provisionNodes(g, cfg, param.installParam)
+		g.OK("provision nodes", err)
+		defer func() {
+			g.Maybe("destroy", cluster.Destroy())
+		}()
+
+		g.OK("download installer", g.SetInstaller(cluster.Nodes, cfg.InstallerURL, "install"))
+		g.OK("install", g.OfflineInstall(cluster.Nodes, param.InstallParam))
+		g.OK("install status", g.Status(cluster.Nodes))
+
+		chaos, err := g.StartChaos(cluster.Nodes, gravity.ChaosConfig{
+			Seed:        param.Seed,
+			Duration:    time.Duration(param.DurationMinutes) * time.Minute,
+			MinInterval: time.Duration(param.MinIntervalSeconds) * time.Second,
+			MaxInterval: time.Duration(param.MaxIntervalSeconds) * time.Second,
+			Faults:      param.Faults,
+		})
+		g.OK("start chaos", err)
+		g.Sleep("chaos", time.Duration(param.DurationMinutes)*time.Minute)
+		report := chaos.Stop()
+		g.Logger().WithField("seed", report.Seed).WithField("faults", len(report.Events)).Info("Chaos stopped.")
+
+		g.OK("status after chaos", g.Status(cluster.Nodes))
+		g.OK("workload after chaos", g.CheckWorkloadHealth(cluster.Nodes))
+	}, nil
+}
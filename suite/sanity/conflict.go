@@ -0,0 +1,47 @@
+package sanity
+
+import (
+	"github.com/gravitational/robotest/infra/gravity"
+)
+
+type conflictParam struct {
+	installParam
+	// BaseInstallerURL is initial app installer URL
+	BaseInstallerURL string `json:"from" validate:"required"`
+}
+
+// conflictExtraNodes is the number of nodes provisioned in addition to the cluster nodes:
+// one to join during the upgrade and two to join concurrently
+const conflictExtraNodes = 3
+
+// conflict installs the base installer and requests overlapping operations:
+// a join while an upgrade is in progress and two simultaneous joins, verifying that
+// gravity rejects the conflicting operations and the cluster stays healthy
+func conflict(p interface{}) (gravity.TestFunc, error) {
+	param := p.(conflictParam)
+
+	return func(g *gravity.TestContext, cfg gravity.ProvisionerConfig) {
+		provisionParam := param.installParam
+		provisionParam.NodeCount += conflictExtraNodes
+		cluster, err := provisionNodes(g, cfg, provisionParam)
+		g.OK("provision nodes", err)
+		defer func() {
+			g.Maybe("destroy", cluster.Destroy())
+		}()
+
+		// copy the nodes so that appending the joined nodes does not overwrite cluster.Nodes
+		nodes := append([]gravity.Gravity(nil), cluster.Nodes[:param.NodeCount]...)
+		extra := cluster.Nodes[param.NodeCount:]
+		g.OK("base installer", g.SetInstaller(cluster.Nodes, param.BaseInstallerURL, "base"))
+		g.OK("install", g.OfflineInstall(nodes, param.InstallParam))
+		g.OK("status", g.Status(nodes))
+
+		g.OK("join during upgrade", g.AssertJoinDuringUpgradeRejected(nodes, extra[0],
+			cfg.InstallerURL, cfg.GravityURL, "upgrade", param.InstallParam))
+		g.OK("status", g.Status(nodes))
+
+		joined, err := g.ConcurrentJoin(nodes, extra[1:], param.InstallParam)
+		g.OK("concurrent join", err)
+		g.OK("status", g.Status(append(nodes, joined...)))
+	}, nil
+}
@@ -1,6 +1,9 @@
 package sanity
 
 import (
+	"time"
+
+	"github.com/gravitational/robotest/infra"
 	"github.com/gravitational/robotest/infra/gravity"
 
 	"cloud.google.com/go/bigquery"
@@ -12,6 +15,65 @@ type installParam struct {
 	NodeCount uint `json:"nodes" validate:"gte=1"`
 	// Script if not empty would be executed with args provided after installer has been transferred
 	Script *scriptParam `json:"script"`
+	// TerraformVars optionally overrides the variables of the terraform script,
+	// i.e. to provision larger instances or faster disks
+	TerraformVars map[string]interface{} `json:"terraform_vars,omitempty"`
+	// SELinux requests the nodes to run with SELinux in enforcing mode
+	SELinux bool `json:"selinux,omitempty"`
+	// Firewall optionally enables the distribution firewall on the nodes before install
+	Firewall *firewallParam `json:"firewall,omitempty"`
+	// Proxy requests the nodes to access the outside world via an HTTP(S) proxy
+	Proxy bool `json:"proxy,omitempty"`
+	// AirGapped requests outbound internet traffic to be blocked on the nodes
+	// after the installer has been transferred
+	AirGapped bool `json:"air_gapped,omitempty"`
+	// Arch optionally specifies the CPU architecture of the nodes
+	Arch string `json:"arch,omitempty"`
+	// DualStack requests the nodes to have IPv6 addresses in addition to IPv4.
+	// Use address_family to install the cluster on the IPv6 addresses
+	DualStack bool `json:"dual_stack,omitempty"`
+	// Subnets optionally specifies the number of subnets to spread the nodes over
+	Subnets int `json:"subnets,omitempty"`
+	// ExtraDisks optionally lists the additional block devices to attach to each node
+	ExtraDisks []infra.Disk `json:"extra_disks,omitempty"`
+	// SlowDisk optionally throttles the disk I/O on the nodes before install
+	SlowDisk *slowDiskParam `json:"slow_disk,omitempty"`
+	// CheckRegistry requests the cluster registry to be verified from all nodes
+	// after install and upgrade
+	CheckRegistry bool `json:"check_registry,omitempty"`
+	// Hooks optionally lists the application hooks expected to run during install, i.e. install.
+	// If specified, all hook jobs of the install are required to succeed
+	Hooks []string `json:"hooks,omitempty"`
+}
+
+type slowDiskParam struct {
+	// ReadBPS and WriteBPS limit the throughput in bytes per second
+	ReadBPS  uint64 `json:"read_bps"`
+	WriteBPS uint64 `json:"write_bps"`
+	// ReadIOPS and WriteIOPS limit the number of I/O operations per second
+	ReadIOPS  uint64 `json:"read_iops"`
+	WriteIOPS uint64 `json:"write_iops"`
+}
+
+// throttle returns the I/O throttle for the parameters, or the default throttle if no limits are given
+func (p slowDiskParam) throttle() gravity.IOThrottle {
+	throttle := gravity.IOThrottle{
+		ReadBPS:   p.ReadBPS,
+		WriteBPS:  p.WriteBPS,
+		ReadIOPS:  p.ReadIOPS,
+		WriteIOPS: p.WriteIOPS,
+	}
+	if throttle == (gravity.IOThrottle{}) {
+		return gravity.DefaultSlowDisk
+	}
+	return throttle
+}
+
+type firewallParam struct {
+	// ExpectError optionally specifies the documented error message the install
+	// is expected to fail with. If empty, the install is expected to succeed
+	// and open the required ports
+	ExpectError string `json:"expect_error,omitempty"`
 }
 
 type scriptParam struct {
@@ -30,15 +92,31 @@ func (p installParam) Save() (row map[string]bigquery.Value, insertID string, er
 }
 
 func provisionNodes(g *gravity.TestContext, cfg gravity.ProvisionerConfig, param installParam) (gravity.Cluster, error) {
-	return g.Provision(cfg.WithOS(param.OSFlavor).
+	return g.Provision(provisionConfig(cfg, param))
+}
+
+// provisionConfig returns the provisioner configuration for the install parameters
+func provisionConfig(cfg gravity.ProvisionerConfig, param installParam) gravity.ProvisionerConfig {
+	return cfg.WithOS(param.OSFlavor).
 		WithStorageDriver(param.DockerStorageDriver).
-		WithNodes(param.NodeCount))
+		WithTerraformVars(param.TerraformVars).
+		WithExtraDisks(param.ExtraDisks).
+		WithSubnets(param.Subnets).
+		WithIPv6(param.DualStack || param.AddressFamily == gravity.AddressFamilyIPv6).
+		WithArch(param.Arch).
+		WithSELinux(param.SELinux).
+		WithFIPS(param.FIPS).
+		WithFirewall(param.Firewall != nil).
+		WithProxy(param.Proxy).
+		WithAirGapped(param.AirGapped).
+		WithNodes(param.NodeCount)
 }
 
 func install(p interface{}) (gravity.TestFunc, error) {
 	param := p.(installParam)
 
 	return func(g *gravity.TestContext, cfg gravity.ProvisionerConfig) {
+		cfg = cfg.WithFIPS(param.FIPS)
 		cluster, err := provisionNodes(g, cfg, param)
 		g.OK("VMs ready", err)
 		defer func() {
@@ -51,12 +129,42 @@ func install(p interface{}) (gravity.TestFunc, error) {
 		}
 
 		g.OK("installer downloaded", g.SetInstaller(cluster.Nodes, installerURL, "install"))
+		if param.AirGapped {
+			g.OK("egress blocked", g.AssertEgressBlocked(cluster.Nodes))
+		}
 		if param.Script != nil {
 			g.OK("post bootstrap script",
 				g.ExecScript(cluster.Nodes, param.Script.Url, param.Script.Args))
 		}
+		if param.SlowDisk != nil {
+			g.OK("throttle disk I/O", g.ThrottleIO(cluster.Nodes, param.SlowDisk.throttle()))
+		}
+		if param.Firewall != nil && param.Firewall.ExpectError != "" {
+			err = g.OfflineInstall(cluster.Nodes, param.InstallParam)
+			g.OK("install blocked by firewall", g.AssertInstallFailedWith(cluster.Nodes, err, param.Firewall.ExpectError))
+			return
+		}
+		start := time.Now()
 		g.OK("application installed", g.OfflineInstall(cluster.Nodes, param.InstallParam))
 		g.OK("status", g.Status(cluster.Nodes))
+		if len(param.Hooks) != 0 {
+			g.OK("install hooks", g.AssertHooks(cluster.Nodes, "install", start, param.Hooks))
+		}
+		if param.Firewall != nil {
+			g.OK("firewall ports open", g.AssertPortsOpen(cluster.Nodes))
+		}
+		if param.SELinux {
+			g.OK("SELinux enforcing", g.AssertSELinuxEnforcing(cluster.Nodes))
+		}
+		if param.FIPS {
+			g.OK("FIPS mode", g.AssertFIPS(cluster.Nodes))
+		}
+		if len(param.NodeRoles) != 0 {
+			g.OK("node roles", g.AssertNodeRoles(cluster.Nodes, param.InstallParam))
+		}
+		if param.CheckRegistry {
+			g.OK("registry", g.CheckRegistry(cluster.Nodes))
+		}
 	}, nil
 }
 
@@ -0,0 +1,34 @@
+package sanity
+
+import (
+	"github.com/gravitational/robotest/infra/gravity"
+)
+
+type installImageParam struct {
+	installParam
+	// AppImage optionally specifies the reference of the application image
+	// to install into the cluster once it is up
+	AppImage string `json:"app_image,omitempty"`
+}
+
+// installImage installs the cluster from the cluster image in the registry
+// with the up-to-date gravity binary instead of the installer tarball
+func installImage(p interface{}) (gravity.TestFunc, error) {
+	param := p.(installImageParam)
+
+	return func(g *gravity.TestContext, cfg gravity.ProvisionerConfig) {
+		cluster, err := provisionNodes(g, cfg, param.installParam)
+		g.OK("VMs ready", err)
+		defer func() {
+			g.Maybe("destroy", cluster.Destroy())
+		}()
+
+		g.OK("gravity downloaded", g.SetGravityBinary(cluster.Nodes, cfg.GravityURL, "install"))
+		g.OK("cluster image installed", g.ImageInstall(cluster.Nodes, param.InstallParam))
+		g.OK("status", g.Status(cluster.Nodes))
+		if param.AppImage != "" {
+			g.OK("application image installed", g.InstallAppImage(cluster.Nodes[0], param.AppImage))
+			g.OK("status after app install", g.Status(cluster.Nodes))
+		}
+	}, nil
+}
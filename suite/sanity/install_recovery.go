@@ -0,0 +1,42 @@
+package sanity
+
+import (
+	"github.com/gravitational/robotest/infra/gravity"
+)
+
+type installRecoveryParam struct {
+	installParam
+	// Phase is the install plan phase after which the install is interrupted
+	Phase string `json:"phase" validate:"required"`
+	// Recovery specifies how to recover from the interrupted install:
+	// resume the install plan or wipe the nodes and reinstall
+	Recovery string `json:"recovery" validate:"required,eq=resume|eq=reinstall"`
+}
+
+const (
+	recoveryResume    = "resume"
+	recoveryReinstall = "reinstall"
+)
+
+// installRecovery interrupts the install after the configured phase and recovers
+// by either resuming the install or reinstalling on the same nodes
+func installRecovery(p interface{}) (gravity.TestFunc, error) {
+	param := p.(installRecoveryParam)
+
+	return func(g *gravity.TestContext, cfg gravity.ProvisionerConfig) {
+		cluster, err := provisionNodes(g, cfg, param.installParam)
+		g.OK("provision nodes", err)
+		defer func() {
+			g.Maybe("destroy", cluster.Destroy())
+		}()
+
+		g.OK("download installer", g.SetInstaller(cluster.Nodes, cfg.InstallerURL, "install"))
+		g.OK("interrupt install", g.InterruptInstall(cluster.Nodes, param.InstallParam, param.Phase))
+		switch param.Recovery {
+		case recoveryResume:
+			g.OK("resume install", g.ResumeInstall(cluster.Nodes))
+		case recoveryReinstall:
+			g.OK("reinstall", g.Reinstall(cluster.Nodes, param.InstallParam))
+		}
+	}, nil
+}
@@ -31,6 +31,8 @@ type lossAndRecoveryParam struct {
 	ExpandBeforeShrink bool `json:"expand_before_shrink" validate:"required"`
 	// PowerOff is whether to power off node before remove
 	PowerOff bool `json:"pwroff_before_remove" validate:"required"`
+	// Capture optionally requests packet captures on the cluster nodes for the duration of the test
+	Capture *gravity.CaptureFilter `json:"capture,omitempty"`
 }
 
 func lossAndRecoveryVariety(p interface{}) (gravity.TestFunc, error) {
@@ -86,6 +88,14 @@ func lossAndRecovery(p interface{}) (gravity.TestFunc, error) {
 		g.OK("install", g.OfflineInstall(nodes, param.InstallParam))
 		g.OK("install status", g.Status(nodes))
 
+		if param.Capture != nil {
+			capture, err := g.StartCapture(cluster.Nodes, "recover", *param.Capture)
+			g.OK("start packet capture", err)
+			defer func() {
+				g.Maybe("stop packet capture", g.StopCapture(capture))
+			}()
+		}
+
 		nodes, removed, err := removeNode(g, nodes, param.ReplaceNodeType, param.PowerOff)
 		g.OK(fmt.Sprintf("node for removal=%v, poweroff=%v", removed, param.PowerOff), err)
 
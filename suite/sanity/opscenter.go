@@ -0,0 +1,46 @@
+package sanity
+
+import (
+	"path/filepath"
+
+	"github.com/gravitational/robotest/infra/gravity"
+)
+
+type opsCenterParam struct {
+	installParam
+	// App is the application name:version the installer is pulled for
+	App string `json:"app" validate:"required"`
+	// InstallLink downloads the installer with a one-time installation link
+	// instead of pulling it with tele
+	InstallLink bool `json:"install_link"`
+}
+
+// opsCenter pushes the cluster image to the Ops Center, installs the cluster with
+// the installer downloaded from the Ops Center (pulled or with a one-time installation link) and connects it for remote support
+func opsCenter(p interface{}) (gravity.TestFunc, error) {
+	param := p.(opsCenterParam)
+
+	return func(g *gravity.TestContext, cfg gravity.ProvisionerConfig) {
+		g.OK("push", g.PushToOpsCenter(cfg.InstallerURL))
+		installerPath := filepath.Join(cfg.StateDir, "opscenter-installer.tar")
+		if param.InstallLink {
+			link, err := g.InstallLink(param.App)
+			g.OK("install link", err)
+			g.OK("download", g.DownloadInstaller(link, installerPath))
+		} else {
+			g.OK("pull", g.PullFromOpsCenter(param.App, installerPath))
+		}
+
+		cluster, err := provisionNodes(g, cfg, param.installParam)
+		g.OK("provision nodes", err)
+		defer func() {
+			g.Maybe("destroy", cluster.Destroy())
+		}()
+
+		g.OK("download installer", g.SetInstaller(cluster.Nodes, installerPath, "install"))
+		g.OK("install", g.OfflineInstall(cluster.Nodes, param.InstallParam))
+		g.OK("status", g.Status(cluster.Nodes))
+		g.OK("connect to Ops Center", g.ConnectToOpsCenter(cluster.Nodes[0]))
+		g.OK("remote support", g.VerifyRemoteSupport(cluster.Nodes[0]))
+	}, nil
+}
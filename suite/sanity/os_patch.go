@@ -0,0 +1,32 @@
+package sanity
+
+import (
+	"github.com/gravitational/robotest/infra/gravity"
+)
+
+type osPatchParam struct {
+	installParam
+	// Update selects the updates to apply: kernel or all
+	Update gravity.OSUpdate `json:"update" validate:"required,eq=kernel|eq=all"`
+}
+
+// osPatch installs a cluster and then applies operating system updates
+// node by node with a reboot, verifying the cluster after each node
+func osPatch(p interface{}) (gravity.TestFunc, error) {
+	param := p.(osPatchParam)
+
+	return func(g *gravity.TestContext, cfg gravity.ProvisionerConfig) {
+		cluster, err := provisionNodes(g, cfg, param.installParam)
+		g.OK("provision nodes", err)
+		defer func() {
+			g.Maybe("destroy", cluster.Destroy())
+		}()
+
+		g.OK("download installer", g.SetInstaller(cluster.Nodes, cfg.InstallerURL, "install"))
+		g.OK("install", g.OfflineInstall(cluster.Nodes, param.InstallParam))
+		g.OK("install status", g.Status(cluster.Nodes))
+
+		g.OK("patch operating system", g.PatchOS(cluster.Nodes, param.Update))
+		g.OK("status after patch", g.Status(cluster.Nodes))
+	}, nil
+}
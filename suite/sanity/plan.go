@@ -0,0 +1,268 @@
+package sanity
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gravitational/robotest/infra/gravity"
+	"github.com/gravitational/robotest/lib/config"
+
+	"github.com/gravitational/trace"
+	"gopkg.in/yaml.v2"
+)
+
+type planParam struct {
+	// File is the path to the test plan in YAML or JSON
+	File string `json:"file" validate:"required"`
+}
+
+// testPlan is a test scenario defined declaratively.
+// The nodes are provisioned with the install parameters before the steps run
+type testPlan struct {
+	installParam
+	// Name optionally names the plan
+	Name string `json:"name"`
+	// Steps lists the steps of the plan in order
+	Steps []planStep `json:"steps" validate:"required,min=1"`
+}
+
+// planStep is a single step of the test plan
+type planStep struct {
+	// Action names the action of the step, see planActions
+	Action string
+	// step is the step to run
+	step gravity.Step
+}
+
+// UnmarshalJSON decodes the step with the parameters of its action
+func (r *planStep) UnmarshalJSON(data []byte) error {
+	var step struct {
+		Action string `json:"action"`
+	}
+	if err := json.Unmarshal(data, &step); err != nil {
+		return trace.Wrap(err)
+	}
+	action, ok := planActions[step.Action]
+	if !ok {
+		return trace.BadParameter("unknown action %q, expected one of %v",
+			step.Action, strings.Join(planActionNames(), ", "))
+	}
+	param := action.param()
+	if err := json.Unmarshal(data, param); err != nil {
+		return trace.BadParameter("invalid %v step: %v", step.Action, err)
+	}
+	if err := config.Validate(param); err != nil {
+		return trace.BadParameter("invalid %v step: %v", step.Action, err)
+	}
+	r.Action, r.step = step.Action, action.step(param)
+	return nil
+}
+
+// runPlan runs the test plan from the file given with the parameters.
+// The plan is loaded and validated upfront so that errors are reported
+// before any resources have been provisioned
+func runPlan(p interface{}) (gravity.TestFunc, error) {
+	param := p.(planParam)
+	plan, err := loadPlan(param.File)
+	if err != nil {
+		return nil, trace.Wrap(err, "failed to load test plan from %v", param.File)
+	}
+	steps := []gravity.Step{gravity.ProvisionStep{}}
+	for _, step := range plan.Steps {
+		steps = append(steps, step.step)
+	}
+
+	return func(g *gravity.TestContext, cfg gravity.ProvisionerConfig) {
+		scenario := &gravity.Scenario{
+			Config: provisionConfig(cfg, plan.installParam),
+			Param:  plan.InstallParam,
+		}
+		defer func() {
+			if scenario.Cluster.Destroy != nil {
+				g.Maybe("destroy", scenario.Cluster.Destroy())
+			}
+		}()
+		g.RunSteps(scenario, steps...)
+	}, nil
+}
+
+// loadPlan reads the test plan from the YAML or JSON file at path
+func loadPlan(path string) (*testPlan, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, trace.ConvertSystemError(err)
+	}
+	var values interface{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, trace.BadParameter("invalid plan: %v", err)
+	}
+	// decode with the JSON tags shared with the test parameters
+	data, err = json.Marshal(jsonValue(values))
+	if err != nil {
+		return nil, trace.BadParameter("invalid plan: %v", err)
+	}
+	plan := testPlan{installParam: defaultInstallParam}
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if err := config.Validate(plan); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &plan, nil
+}
+
+// jsonValue converts the YAML value to one that can be encoded as JSON
+// by converting the maps to use string keys
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			out[fmt.Sprint(key)] = jsonValue(value)
+		}
+		return out
+	case []interface{}:
+		for i := range v {
+			v[i] = jsonValue(v[i])
+		}
+	}
+	return value
+}
+
+// planAction is an action a plan step can run
+type planAction struct {
+	// param returns a pointer to the new parameters of the action
+	param func() interface{}
+	// step returns the step to run for the parameters
+	step func(param interface{}) gravity.Step
+}
+
+var planActions = map[string]planAction{
+	"install": {
+		param: func() interface{} { return &planInstall{} },
+		step: func(p interface{}) gravity.Step {
+			param := p.(*planInstall)
+			return gravity.InstallStep{Nodes: param.Nodes, InstallerURL: param.InstallerURL}
+		},
+	},
+	"expand": {
+		param: func() interface{} { return &planResize{} },
+		step:  func(p interface{}) gravity.Step { return gravity.ExpandStep{To: p.(*planResize).To} },
+	},
+	"shrink": {
+		param: func() interface{} { return &planResize{} },
+		step:  func(p interface{}) gravity.Step { return gravity.ShrinkStep{To: p.(*planResize).To} },
+	},
+	"upgrade": {
+		param: func() interface{} { return &planUpgrade{} },
+		step: func(p interface{}) gravity.Step {
+			param := p.(*planUpgrade)
+			return gravity.UpgradeStep{InstallerURL: param.InstallerURL, GravityURL: param.GravityURL}
+		},
+	},
+	"failover": failoverAction,
+	// partition_leader is the former name of failover, kept for existing plans
+	"partition_leader": failoverAction,
+	"start_load": {
+		param: func() interface{} { return &loadParam{} },
+		step:  func(p interface{}) gravity.Step { return gravity.StartLoadStep{Config: p.(*loadParam).config()} },
+	},
+	"stop_load": {
+		param: func() interface{} { return &struct{}{} },
+		step:  func(interface{}) gravity.Step { return gravity.StopLoadStep{} },
+	},
+	"status": {
+		param: func() interface{} { return &struct{}{} },
+		step:  func(interface{}) gravity.Step { return gravity.StatusStep{} },
+	},
+	"check_registry": {
+		param: func() interface{} { return &struct{}{} },
+		step:  func(interface{}) gravity.Step { return gravity.CheckRegistryStep{} },
+	},
+	"sleep": {
+		param: func() interface{} { return &planSleep{} },
+		step: func(p interface{}) gravity.Step {
+			d := p.(*planSleep).Duration.Duration()
+			return gravity.NewStep("sleep", func(c *gravity.TestContext, s *gravity.Scenario) error {
+				c.Sleep("plan", d)
+				return nil
+			})
+		},
+	},
+	"collect_logs": {
+		param: func() interface{} { return &planCollectLogs{Prefix: "plan"} },
+		step:  func(p interface{}) gravity.Step { return gravity.CollectLogsStep{Prefix: p.(*planCollectLogs).Prefix} },
+	},
+}
+
+var failoverAction = planAction{
+	param: func() interface{} { return &planFailover{} },
+	step: func(p interface{}) gravity.Step {
+		return gravity.FailoverStep{Duration: p.(*planFailover).Duration.Duration()}
+	},
+}
+
+func planActionNames() (names []string) {
+	for name := range planActions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type planInstall struct {
+	// Nodes is the number of nodes to install on. Defaults to all provisioned nodes
+	Nodes int `json:"nodes" validate:"gte=0"`
+	// InstallerURL optionally overrides the installer of the provisioner configuration
+	InstallerURL string `json:"installer_url"`
+}
+
+type planResize struct {
+	// To is the number of cluster nodes after the resize
+	To int `json:"to" validate:"required,gt=0"`
+}
+
+type planUpgrade struct {
+	// InstallerURL is the installer to upgrade to
+	InstallerURL string `json:"installer_url" validate:"required"`
+	// GravityURL optionally specifies the gravity binary to upgrade with
+	GravityURL string `json:"gravity_url"`
+}
+
+type planFailover struct {
+	// Duration is how long the leader is kept partitioned from the other nodes
+	Duration duration `json:"duration"`
+}
+
+type planSleep struct {
+	// Duration is how long to sleep
+	Duration duration `json:"duration" validate:"required"`
+}
+
+type planCollectLogs struct {
+	// Prefix names the directory to collect the logs into
+	Prefix string `json:"prefix" validate:"required"`
+}
+
+// duration aliases time.Duration to decode it from strings, i.e. 5m
+type duration time.Duration
+
+// Duration returns this duration as time.Duration
+func (r duration) Duration() time.Duration {
+	return time.Duration(r)
+}
+
+// UnmarshalText interprets data as time.Duration.
+// UnmarshalText implements encoding.TextUnmarshaler
+func (r *duration) UnmarshalText(data []byte) error {
+	d, err := time.ParseDuration(string(data))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	*r = duration(d)
+	return nil
+}
@@ -0,0 +1,141 @@
+package sanity
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gravitational/robotest/infra/gravity"
+
+	"github.com/gravitational/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadPlan(t *testing.T) {
+	var testCases = []struct {
+		comment string
+		file    string
+		plan    string
+		steps   []gravity.Step
+	}{
+		{
+			comment: "yaml",
+			file:    "plan.yaml",
+			plan: `
+name: expand
+nodes: 3
+flavor: three
+role: node
+steps:
+  - action: install
+  - action: expand
+    to: 3
+  - action: upgrade
+    installer_url: /installer/next.tar
+  - action: status
+`,
+			steps: []gravity.Step{
+				gravity.InstallStep{},
+				gravity.ExpandStep{To: 3},
+				gravity.UpgradeStep{InstallerURL: "/installer/next.tar"},
+				gravity.StatusStep{},
+			},
+		},
+		{
+			comment: "json",
+			file:    "plan.json",
+			plan: `{"nodes": 2, "flavor": "two", "role": "node", "steps": [
+				{"action": "install", "nodes": 1},
+				{"action": "shrink", "to": 1},
+				{"action": "collect_logs"}
+			]}`,
+			steps: []gravity.Step{
+				gravity.InstallStep{Nodes: 1},
+				gravity.ShrinkStep{To: 1},
+				gravity.CollectLogsStep{Prefix: "plan"},
+			},
+		},
+	}
+	dir, err := ioutil.TempDir("", "robotest-plan")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, tc := range testCases {
+		plan, err := loadPlan(writePlan(t, dir, tc.file, tc.plan))
+		require.NoError(t, err, tc.comment)
+		var steps []gravity.Step
+		for _, step := range plan.Steps {
+			steps = append(steps, step.step)
+		}
+		assert.Equal(t, tc.steps, steps, tc.comment)
+	}
+}
+
+func TestLoadFailoverPlan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "robotest-plan")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	plan, err := loadPlan(writePlan(t, dir, "plan.yaml", `
+name: failover
+nodes: 3
+flavor: three
+role: node
+steps:
+  - action: install
+  - action: failover
+    duration: 1m
+  - action: partition_leader
+`))
+	require.NoError(t, err)
+	var steps []gravity.Step
+	for _, step := range plan.Steps {
+		steps = append(steps, step.step)
+	}
+	assert.Equal(t, []gravity.Step{
+		gravity.InstallStep{},
+		gravity.FailoverStep{Duration: time.Minute},
+		gravity.FailoverStep{},
+	}, steps, "partition_leader is an alias of failover")
+}
+
+func TestLoadInvalidPlan(t *testing.T) {
+	var testCases = []struct {
+		comment string
+		plan    string
+	}{
+		{"unknown action", "nodes: 1\nsteps:\n  - action: reboot\n"},
+		{"missing action", "nodes: 1\nsteps:\n  - to: 3\n"},
+		{"missing parameter", "nodes: 1\nsteps:\n  - action: expand\n"},
+		{"invalid parameter", "nodes: 1\nsteps:\n  - action: expand\n    to: -1\n"},
+		{"invalid duration", "nodes: 1\nsteps:\n  - action: sleep\n    duration: forever\n"},
+		{"no steps", "nodes: 1\nsteps: []\n"},
+		{"no nodes", "nodes: 0\nsteps:\n  - action: install\n"},
+		{"invalid yaml", "nodes: [1\n"},
+	}
+	dir, err := ioutil.TempDir("", "robotest-plan")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// the plans are invalid only for the reason given in the comment
+	_, err = loadPlan(writePlan(t, dir, "plan.yaml", "flavor: one\nrole: node\nnodes: 1\nsteps:\n  - action: install\n"))
+	require.NoError(t, err)
+	for _, tc := range testCases {
+		_, err := loadPlan(writePlan(t, dir, "plan.yaml", "flavor: one\nrole: node\n"+tc.plan))
+		assert.Error(t, err, tc.comment)
+	}
+
+	_, err = loadPlan(writePlan(t, dir, "plan.yaml", "flavor: one\nrole: node\nnodes: 1\nsteps:\n  - action: reboot\n"))
+	assert.True(t, trace.IsBadParameter(err), "unknown action: %v", err)
+}
+
+// writePlan writes the plan into the file with the given name in dir
+// and returns the path to the file
+func writePlan(t *testing.T, dir, name, plan string) (path string) {
+	path = filepath.Join(dir, name)
+	require.NoError(t, ioutil.WriteFile(path, []byte(plan), 0644))
+	return path
+}
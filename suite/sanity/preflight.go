@@ -0,0 +1,40 @@
+package sanity
+
+import (
+	"github.com/gravitational/robotest/infra/gravity"
+)
+
+type preflightParam struct {
+	installParam
+	// Misconfigure lists the misconfigurations to apply to the nodes
+	// before the checks: missing_module, low_disk or ip_forward
+	Misconfigure []gravity.Misconfiguration `json:"misconfigure,omitempty"`
+	// ExpectFailures lists the messages the failed checks are expected to report.
+	// If empty, the checks are expected to pass
+	ExpectFailures []string `json:"expect_failures,omitempty"`
+}
+
+// preflight runs the preflight checks on the prepared (and optionally misconfigured)
+// nodes and asserts the expected outcome
+func preflight(p interface{}) (gravity.TestFunc, error) {
+	param := p.(preflightParam)
+
+	return func(g *gravity.TestContext, cfg gravity.ProvisionerConfig) {
+		cluster, err := provisionNodes(g, cfg, param.installParam)
+		g.OK("VMs ready", err)
+		defer func() {
+			g.Maybe("destroy", cluster.Destroy())
+		}()
+
+		installerURL := cfg.InstallerURL
+		if param.InstallerURL != "" {
+			installerURL = param.InstallerURL
+		}
+
+		g.OK("installer downloaded", g.SetInstaller(cluster.Nodes, installerURL, "install"))
+		if len(param.Misconfigure) != 0 {
+			g.OK("nodes misconfigured", g.Misconfigure(cluster.Nodes, param.StateDir, param.Misconfigure))
+		}
+		g.OK("preflight checks", g.AssertPreflight(cluster.Nodes, param.InstallParam, param.ExpectFailures))
+	}, nil
+}
@@ -0,0 +1,37 @@
+package sanity
+
+import (
+	"fmt"
+
+	"github.com/gravitational/robotest/infra/gravity"
+)
+
+type replaceParam struct {
+	installParam
+	// ReplaceNodeType selects the node to replace: apimaster, clmaster, clbackup or worker
+	ReplaceNodeType string `json:"kill" validate:"required,eq=apimaster|eq=clmaster|eq=clbackup|eq=worker"`
+}
+
+// replaceNode installs a cluster, powers off one of the nodes and replaces it with a freshly provisioned node
+func replaceNode(p interface{}) (gravity.TestFunc, error) {
+	param := p.(replaceParam)
+
+	return func(g *gravity.TestContext, cfg gravity.ProvisionerConfig) {
+		cluster, err := provisionNodes(g, cfg, param.installParam)
+		g.OK("provision nodes", err)
+		defer func() {
+			g.Maybe("destroy", cluster.Destroy())
+		}()
+
+		g.OK("download installer", g.SetInstaller(cluster.Nodes, cfg.InstallerURL, "install"))
+		g.OK("install", g.OfflineInstall(cluster.Nodes, param.InstallParam))
+		g.OK("install status", g.Status(cluster.Nodes))
+
+		_, dead, err := removeNode(g, cluster.Nodes, param.ReplaceNodeType, true)
+		g.OK(fmt.Sprintf("power off %v", dead), err)
+
+		nodes, replacement, err := g.ReplaceNode(cluster.Nodes, dead, cfg.InstallerURL, param.InstallParam)
+		g.OK(fmt.Sprintf("replace %v", dead), err)
+		g.OK(fmt.Sprintf("status after replace with %v", replacement), g.Status(nodes))
+	}, nil
+}
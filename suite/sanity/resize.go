@@ -13,6 +13,12 @@ type resizeParam struct {
 	installParam
 	// TargetNodes is how many nodes cluster should have after expand
 	ToNodes uint `json:"to" validate:"required,gte=3"`
+	// AgentURL requests the extra nodes to join with the agent-token URL
+	// instead of gravity join
+	AgentURL bool `json:"agent_url,omitempty"`
+	// JoinAddr optionally specifies the address (host:port) the agent-token URL
+	// points to, i.e. a load balancer in front of the masters
+	JoinAddr string `json:"join_addr,omitempty"`
 }
 
 func (p resizeParam) Save() (row map[string]bigquery.Value, insertID string, err error) {
@@ -43,9 +49,15 @@ func resize(p interface{}) (gravity.TestFunc, error) {
 			g.OfflineInstall(cluster.Nodes[0:param.NodeCount], param.InstallParam))
 		g.OK("status", g.Status(cluster.Nodes[0:param.NodeCount]))
 		g.OK("time sync", g.CheckTimeSync(cluster.Nodes))
-		g.OK(fmt.Sprintf("expand to %d nodes", param.ToNodes),
-			g.Expand(cluster.Nodes[0:param.NodeCount], cluster.Nodes[param.NodeCount:param.ToNodes],
-				param.InstallParam))
+		if param.AgentURL {
+			g.OK(fmt.Sprintf("expand to %d nodes with agent URL", param.ToNodes),
+				g.ExpandWithAgentURL(cluster.Nodes[0:param.NodeCount], cluster.Nodes[param.NodeCount:param.ToNodes],
+					param.InstallParam, param.JoinAddr))
+		} else {
+			g.OK(fmt.Sprintf("expand to %d nodes", param.ToNodes),
+				g.Expand(cluster.Nodes[0:param.NodeCount], cluster.Nodes[param.NodeCount:param.ToNodes],
+					param.InstallParam))
+		}
 		g.OK("status", g.Status(cluster.Nodes[0:param.ToNodes]))
 	}, nil
 }
@@ -0,0 +1,51 @@
+package sanity
+
+import (
+	"fmt"
+
+	"github.com/gravitational/robotest/infra/gravity"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/gravitational/trace"
+)
+
+type resizeCycleParam struct {
+	installParam
+	// Cycles is how many times to expand and shrink the cluster by one node
+	Cycles int `json:"cycles" validate:"required,gte=1"`
+}
+
+func (p resizeCycleParam) Save() (row map[string]bigquery.Value, insertID string, err error) {
+	row, _, err = p.installParam.Save()
+	if err != nil {
+		return nil, "", trace.Wrap(err)
+	}
+
+	row["cycles"] = p.Cycles
+	return row, "", nil
+}
+
+// resizeCycle installs a cluster and then repeatedly expands it by one node and shrinks it back
+func resizeCycle(p interface{}) (gravity.TestFunc, error) {
+	param := p.(resizeCycleParam)
+
+	return func(g *gravity.TestContext, cfg gravity.ProvisionerConfig) {
+		cluster, err := g.Provision(cfg.WithOS(param.OSFlavor).
+			WithStorageDriver(param.DockerStorageDriver).
+			WithNodes(param.NodeCount + 1))
+		g.OK("provision nodes", err)
+		defer func() {
+			g.Maybe("destroy", cluster.Destroy())
+		}()
+
+		nodes, extra := cluster.Nodes[:param.NodeCount], cluster.Nodes[param.NodeCount]
+		g.OK("download installer", g.SetInstaller(cluster.Nodes, cfg.InstallerURL, "install"))
+		g.OK(fmt.Sprintf("install on %d nodes", param.NodeCount), g.OfflineInstall(nodes, param.InstallParam))
+		g.OK("status", g.Status(nodes))
+
+		stats, err := g.CycleResize(nodes, extra, param.InstallParam, param.Cycles)
+		g.Logger().WithFields(stats.Fields()).Info("Resize cycles.")
+		g.OK(fmt.Sprintf("%d resize cycles", param.Cycles), err)
+		g.OK("status", g.Status(nodes))
+	}, nil
+}
@@ -0,0 +1,37 @@
+package sanity
+
+import (
+	"github.com/gravitational/robotest/infra/gravity"
+)
+
+type rollbackParam struct {
+	installParam
+	// BaseInstallerURL is initial app installer URL
+	BaseInstallerURL string `json:"from" validate:"required"`
+	// Fault specifies where and how the upgrade fails
+	Fault gravity.UpgradeFault `json:"fault"`
+}
+
+// rollback installs the base installer, fails the upgrade at the configured phase,
+// rolls it back and verifies that the cluster is active with the original version
+func rollback(p interface{}) (gravity.TestFunc, error) {
+	param := p.(rollbackParam)
+
+	return func(g *gravity.TestContext, cfg gravity.ProvisionerConfig) {
+		cluster, err := provisionNodes(g, cfg, param.installParam)
+		g.OK("provision nodes", err)
+		defer func() {
+			g.Maybe("destroy", cluster.Destroy())
+		}()
+
+		g.OK("base installer", g.SetInstaller(cluster.Nodes, param.BaseInstallerURL, "base"))
+		g.OK("install", g.OfflineInstall(cluster.Nodes, param.InstallParam))
+		g.OK("status", g.Status(cluster.Nodes))
+		version, err := g.AppVersion(cluster.Nodes)
+		g.OK("version", err)
+
+		g.OK("fail upgrade", g.FailUpgrade(cluster.Nodes, cfg.InstallerURL, cfg.GravityURL, "upgrade", param.Fault))
+		g.OK("rollback", g.Rollback(cluster.Nodes))
+		g.OK("original version", g.AssertAppVersion(cluster.Nodes, version))
+	}, nil
+}
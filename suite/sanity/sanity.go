@@ -18,13 +18,31 @@ func Suite() *config.Config {
 
 	cfg.Add("noop", noop, noopParam{})
 	cfg.Add("noopV", noopVariety, noopParam{})
-	cfg.Add("provision", provision, defaultInstallParam)
-	cfg.Add("resize", resize, resizeParam{installParam: defaultInstallParam})
-	cfg.Add("install", install, defaultInstallParam)
-	cfg.Add("recover", lossAndRecovery, lossAndRecoveryParam{installParam: defaultInstallParam})
-	cfg.Add("recoverV", lossAndRecoveryVariety, defaultInstallParam)
-	cfg.Add("upgrade3lts", upgrade, upgradeParam{installParam: defaultInstallParam})
-	cfg.Add("autoscale", autoscale, defaultInstallParam)
+	cfg.Add("provision", provision, defaultInstallParam, "provision")
+	cfg.Add("resize", resize, resizeParam{installParam: defaultInstallParam}, "expand")
+	cfg.Add("resize_cycle", resizeCycle, resizeCycleParam{installParam: defaultInstallParam, Cycles: 10}, "expand", "resilience", "slow")
+	cfg.Add("install", install, defaultInstallParam, "install")
+	cfg.Add("install_image", installImage, installImageParam{installParam: defaultInstallParam}, "install")
+	cfg.Add("preflight", preflight, preflightParam{installParam: defaultInstallParam}, "install")
+	cfg.Add("install_recovery", installRecovery, installRecoveryParam{installParam: defaultInstallParam, Recovery: recoveryResume}, "install", "resilience")
+	cfg.Add("recover", lossAndRecovery, lossAndRecoveryParam{installParam: defaultInstallParam}, "resilience", "slow")
+	cfg.Add("recoverV", lossAndRecoveryVariety, defaultInstallParam, "resilience", "slow")
+	cfg.Add("replace_node", replaceNode, replaceParam{installParam: defaultInstallParam, ReplaceNodeType: nodeApiMaster}, "resilience")
+	cfg.Add("stop_start", stopStart, defaultInstallParam, "resilience")
+	cfg.Add("time_jump", timeJump, timeJumpParam{installParam: defaultInstallParam, Days: 30}, "resilience")
+	cfg.Add("cert_rotate", certRotate, certRotateParam{installParam: defaultInstallParam, ValidityHours: 24, Probe: defaultCertExpiryProbe}, "resilience")
+	cfg.Add("chaos", chaos, chaosParam{installParam: defaultInstallParam, DurationMinutes: 60, MinIntervalSeconds: 120, MaxIntervalSeconds: 600}, "resilience", "slow")
+	cfg.Add("soak", soak, soakParam{installParam: defaultInstallParam, DurationHours: 72, IntervalMinutes: 15, MinGrowthPercent: 20}, "resilience", "slow")
+	cfg.Add("os_patch", osPatch, osPatchParam{installParam: defaultInstallParam, Update: gravity.OSUpdateKernel}, "resilience", "slow")
+	cfg.Add("upgrade3lts", upgrade, upgradeParam{installParam: defaultInstallParam}, "upgrade")
+	cfg.Add("upgrade_path", upgradePath, upgradePathParam{installParam: defaultInstallParam}, "upgrade", "slow")
+	cfg.Add("rollback", rollback, rollbackParam{installParam: defaultInstallParam}, "upgrade", "resilience")
+	cfg.Add("conflict", conflict, conflictParam{installParam: defaultInstallParam}, "resilience")
+	cfg.Add("autoscale", autoscale, defaultInstallParam, "expand", "aws-only")
+	cfg.Add("backup", backupRestore, defaultInstallParam, "backup")
+	cfg.Add("benchmark", benchmark, benchmarkParam{installParam: defaultInstallParam, Operation: "install", Runs: 3, MaxRegressionPercent: 20}, "benchmark", "slow")
+	cfg.Add("plan", runPlan, planParam{}, "plan")
+	cfg.Add("opscenter", opsCenter, opsCenterParam{installParam: defaultInstallParam}, "opscenter")
 
 	return cfg
 }
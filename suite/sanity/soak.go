@@ -0,0 +1,43 @@
+package sanity
+
+import (
+	"time"
+
+	"github.com/gravitational/robotest/infra/gravity"
+)
+
+type soakParam struct {
+	installParam
+	// DurationHours is how long to keep the cluster running
+	DurationHours int `json:"duration_hours" validate:"required,gt=0"`
+	// IntervalMinutes is the time between the health samples
+	IntervalMinutes int `json:"interval_minutes" validate:"required,gt=0"`
+	// MinGrowthPercent is the growth of a sampled value over the run flagged as a trend.
+	// Zero disables the trend detection
+	MinGrowthPercent int `json:"min_growth_percent" validate:"gte=0"`
+}
+
+// soak installs a cluster and keeps it running for the configured duration,
+// sampling its health periodically and flagging values that grow monotonically
+func soak(p interface{}) (gravity.TestFunc, error) {
+	param := p.(soakParam)
+
+	return func(g *gravity.TestContext, cfg gravity.ProvisionerConfig) {
+		cluster, err := provisionNodes(g, cfg, param.installParam)
+		g.OK("provision nodes", err)
+		defer func() {
+			g.Maybe("destroy", cluster.Destroy())
+		}()
+
+		g.OK("download installer", g.SetInstaller(cluster.Nodes, cfg.InstallerURL, "install"))
+		g.OK("install", g.OfflineInstall(cluster.Nodes, param.InstallParam))
+		g.OK("install status", g.Status(cluster.Nodes))
+
+		_, err = g.Soak(cluster.Nodes, gravity.SoakConfig{
+			Duration:  time.Duration(param.DurationHours) * time.Hour,
+			Interval:  time.Duration(param.IntervalMinutes) * time.Minute,
+			MinGrowth: float64(param.MinGrowthPercent) / 100,
+		})
+		g.OK("soak", err)
+	}, nil
+}
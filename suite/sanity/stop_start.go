@@ -0,0 +1,27 @@
+package sanity
+
+import (
+	"github.com/gravitational/robotest/infra/gravity"
+)
+
+// stopStart installs a cluster, stops and starts all VMs with the cloud provider API
+// and verifies that the cluster and its workload come back
+func stopStart(p interface{}) (gravity.TestFunc, error) {
+	param := p.(installParam)
+
+	return func(g *gravity.TestContext, cfg gravity.ProvisionerConfig) {
+		cluster, err := provisionNodes(g, cfg, param)
+		g.OK("provision nodes", err)
+		defer func() {
+			g.Maybe("destroy", cluster.Destroy())
+		}()
+
+		g.OK("download installer", g.SetInstaller(cluster.Nodes, cfg.InstallerURL, "install"))
+		g.OK("install", g.OfflineInstall(cluster.Nodes, param.InstallParam))
+		g.OK("install status", g.Status(cluster.Nodes))
+
+		g.OK("stop and start all VMs", g.StopStartAll(cluster.Nodes))
+		g.OK("workload health", g.CheckWorkloadHealth(cluster.Nodes))
+		g.OK("time sync", g.CheckTimeSync(cluster.Nodes))
+	}, nil
+}
@@ -0,0 +1,35 @@
+package sanity
+
+import (
+	"time"
+
+	"github.com/gravitational/robotest/infra/gravity"
+)
+
+type timeJumpParam struct {
+	installParam
+	// Days is the number of days to move the clock forward by
+	Days int `json:"days" validate:"required,gt=0"`
+}
+
+// timeJump installs a cluster and then moves the clock on all nodes
+// forward across a reboot, verifying the cluster comes back
+func timeJump(p interface{}) (gravity.TestFunc, error) {
+	param := p.(timeJumpParam)
+
+	return func(g *gravity.TestContext, cfg gravity.ProvisionerConfig) {
+		cluster, err := provisionNodes(g, cfg, param.installParam)
+		g.OK("provision nodes", err)
+		defer func() {
+			g.Maybe("destroy", cluster.Destroy())
+		}()
+
+		g.OK("download installer", g.SetInstaller(cluster.Nodes, cfg.InstallerURL, "install"))
+		g.OK("install", g.OfflineInstall(cluster.Nodes, param.InstallParam))
+		g.OK("install status", g.Status(cluster.Nodes))
+
+		offset := time.Duration(param.Days) * 24 * time.Hour
+		g.OK("time jump", g.TimeJump(cluster.Nodes, offset))
+		g.OK("workload after time jump", g.CheckWorkloadHealth(cluster.Nodes))
+	}, nil
+}
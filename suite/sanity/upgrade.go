@@ -1,6 +1,8 @@
 package sanity
 
 import (
+	"time"
+
 	"github.com/gravitational/robotest/infra/gravity"
 
 	"cloud.google.com/go/bigquery"
@@ -11,6 +13,37 @@ type upgradeParam struct {
 	installParam
 	// BaseInstallerURL is initial app installer URL
 	BaseInstallerURL string `json:"from" validate:"required"`
+	// Load optionally runs the in-cluster load generator during the upgrade
+	Load *loadParam `json:"load,omitempty"`
+	// Helm requests a sample helm chart to be installed before the upgrade
+	// and its release to be verified after the upgrade
+	Helm bool `json:"helm,omitempty"`
+	// UpgradeHooks optionally lists the application hooks expected to run during the upgrade,
+	// i.e. update. If specified, all hook jobs of the upgrade are required to succeed
+	UpgradeHooks []string `json:"upgrade_hooks,omitempty"`
+}
+
+type loadParam struct {
+	// Replicas is the number of load generator pods
+	Replicas int `json:"replicas" validate:"gte=0"`
+	// Rate is the number of objects each pod creates and deletes per second
+	Rate int `json:"rate" validate:"gte=0"`
+	// Objects bounds the number of objects each pod keeps at a time
+	Objects int `json:"objects" validate:"gte=0"`
+	// ObjectSize is the size of each object in bytes
+	ObjectSize int `json:"object_size" validate:"gte=0"`
+	// Image optionally overrides the load generator image
+	Image string `json:"image"`
+}
+
+func (p loadParam) config() gravity.LoadGeneratorConfig {
+	return gravity.LoadGeneratorConfig{
+		Replicas:   p.Replicas,
+		Rate:       p.Rate,
+		Objects:    p.Objects,
+		ObjectSize: p.ObjectSize,
+		Image:      p.Image,
+	}
 }
 
 func (p upgradeParam) Save() (row map[string]bigquery.Value, insertID string, err error) {
@@ -27,6 +60,7 @@ func upgrade(p interface{}) (gravity.TestFunc, error) {
 	param := p.(upgradeParam)
 
 	return func(g *gravity.TestContext, cfg gravity.ProvisionerConfig) {
+		cfg = cfg.WithFIPS(param.FIPS)
 		cluster, err := provisionNodes(g, cfg, param.installParam)
 		g.OK("provision nodes", err)
 		defer func() {
@@ -34,9 +68,58 @@ func upgrade(p interface{}) (gravity.TestFunc, error) {
 		}()
 
 		g.OK("base installer", g.SetInstaller(cluster.Nodes, param.BaseInstallerURL, "base"))
+		if param.AirGapped {
+			g.OK("egress blocked", g.AssertEgressBlocked(cluster.Nodes))
+		}
+		start := time.Now()
 		g.OK("install", g.OfflineInstall(cluster.Nodes, param.InstallParam))
 		g.OK("status", g.Status(cluster.Nodes))
-		g.OK("upgrade", g.Upgrade(cluster.Nodes, cfg.InstallerURL, cfg.GravityURL, "upgrade"))
+		if len(param.Hooks) != 0 {
+			g.OK("install hooks", g.AssertHooks(cluster.Nodes, "install", start, param.Hooks))
+		}
+		if param.CheckRegistry {
+			g.OK("registry", g.CheckRegistry(cluster.Nodes))
+		}
+		var helmVersion int
+		var helmInstalled time.Time
+		if param.Helm {
+			g.OK("helm chart", g.InstallHelmChart(cluster.Nodes, helmRelease))
+			helmInstalled = time.Now()
+			helmVersion, err = g.HelmVersion(cluster.Nodes)
+			g.OK("helm version", err)
+		}
+		if param.Load != nil {
+			g.OK("start load", g.StartLoad(cluster.Nodes, param.Load.config()))
+		}
+		start = time.Now()
+		g.OK("upgrade", g.WithStateDiff("upgrade", cluster.Nodes, func() error {
+			return g.Upgrade(cluster.Nodes, cfg.InstallerURL, cfg.GravityURL, "upgrade")
+		}))
 		g.OK("status", g.Status(cluster.Nodes))
+		if len(param.UpgradeHooks) != 0 {
+			g.OK("upgrade hooks", g.AssertHooks(cluster.Nodes, "upgrade", start, param.UpgradeHooks))
+		}
+		if param.CheckRegistry {
+			g.OK("registry after upgrade", g.CheckRegistry(cluster.Nodes))
+		}
+		if param.Helm {
+			g.OK("helm release after upgrade", g.AssertHelmRelease(cluster.Nodes, helmRelease))
+			upgradedVersion, err := g.HelmVersion(cluster.Nodes)
+			g.OK("helm version after upgrade", err)
+			if helmVersion == 2 && upgradedVersion == 3 {
+				g.OK("helm 3 migration", g.AssertHelmMigrated(cluster.Nodes, helmRelease, helmInstalled))
+			}
+			g.OK("delete helm release", g.DeleteHelmRelease(cluster.Nodes, helmRelease))
+		}
+		if param.Load != nil {
+			g.OK("load after upgrade", g.AssertLoadRunning(cluster.Nodes))
+			g.OK("stop load", g.StopLoad(cluster.Nodes))
+		}
+		if param.FIPS {
+			g.OK("FIPS mode after upgrade", g.AssertFIPS(cluster.Nodes))
+		}
 	}, nil
 }
+
+// helmRelease names the release of the sample helm chart
+const helmRelease = "robotest"
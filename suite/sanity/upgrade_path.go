@@ -0,0 +1,56 @@
+package sanity
+
+import (
+	"fmt"
+
+	"github.com/gravitational/robotest/infra/gravity"
+
+	"github.com/sirupsen/logrus"
+)
+
+type upgradePathParam struct {
+	installParam
+	// BaseInstallerURL is initial app installer URL
+	BaseInstallerURL string `json:"from" validate:"required"`
+	// Hops lists the upgrades to perform in order
+	Hops []upgradeHop `json:"upgrade_path" validate:"required,min=1,dive"`
+}
+
+// upgradeHop describes a single upgrade on the upgrade path
+type upgradeHop struct {
+	// InstallerURL is the installer to upgrade to
+	InstallerURL string `json:"installer_url" validate:"required"`
+	// GravityURL is the gravity binary matching the installer
+	GravityURL string `json:"gravity_url" validate:"required"`
+	// EtcdVersion optionally specifies the etcd version expected after the upgrade
+	EtcdVersion string `json:"etcd_version,omitempty"`
+}
+
+// upgradePath installs the base installer and upgrades the same cluster
+// along the upgrade path, verifying the cluster after each upgrade
+func upgradePath(p interface{}) (gravity.TestFunc, error) {
+	param := p.(upgradePathParam)
+
+	return func(g *gravity.TestContext, cfg gravity.ProvisionerConfig) {
+		cluster, err := provisionNodes(g, cfg, param.installParam)
+		g.OK("provision nodes", err)
+		defer func() {
+			g.Maybe("destroy", cluster.Destroy())
+		}()
+
+		g.OK("base installer", g.SetInstaller(cluster.Nodes, param.BaseInstallerURL, "base"))
+		g.OK("install", g.OfflineInstall(cluster.Nodes, param.InstallParam))
+		g.OK("status", g.Status(cluster.Nodes))
+
+		for i, hop := range param.Hops {
+			g.WithFields(logrus.Fields{"hop": i + 1, "upgrade_to": hop.InstallerURL})
+			subdir := fmt.Sprintf("upgrade%v", i+1)
+			g.OK("upgrade", g.Upgrade(cluster.Nodes, hop.InstallerURL, hop.GravityURL, subdir))
+			g.OK("status", g.Status(cluster.Nodes))
+			g.OK("workload health", g.CheckWorkloadHealth(cluster.Nodes))
+			if hop.EtcdVersion != "" {
+				g.OK("etcd version", g.AssertEtcdVersion(cluster.Nodes, hop.EtcdVersion))
+			}
+		}
+	}, nil
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io/ioutil"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"testing"
 	"time"
@@ -13,6 +16,11 @@ import (
 	"github.com/gravitational/robotest/infra/gravity"
 	"github.com/gravitational/robotest/lib/config"
 	"github.com/gravitational/robotest/lib/debug"
+	"github.com/gravitational/robotest/lib/defaults"
+	"github.com/gravitational/robotest/lib/failure"
+	"github.com/gravitational/robotest/lib/grafana"
+	"github.com/gravitational/robotest/lib/issues"
+	"github.com/gravitational/robotest/lib/secret"
 	"github.com/gravitational/robotest/lib/xlog"
 	"github.com/gravitational/robotest/suite/sanity"
 
@@ -21,24 +29,60 @@ import (
 
 var testSuite = flag.String("suite", "sanity", "test suite to run")
 var provision = flag.String("provision", "", "cloud credentials in JSON string")
+var provisionFile = flag.String("provision-file", "", "file with the provisioner configuration in YAML, takes precedence over -provision")
 var tag = flag.String("tag", "", "tag to uniquely mark resources in cloud")
 
+var focusLabels = flag.String("focus-labels", "", "only run tests with labels matching the expression, i.e. 'upgrade && !slow'")
+var skipLabels = flag.String("skip-labels", "", "skip tests with labels matching the expression, i.e. 'aws-only || slow'")
+
 var repeat = flag.Int("repeat", 1, "how many times to repeat a test")
+var maxAttempts = flag.Int("max-attempts", defaults.MaxRetriesPerTest, "how many times to attempt a failing test, each on a fresh cluster")
 var failFast = flag.Bool("fail-fast", false, "will attemt to shut down all other tests on first failure")
 var destroyOnSuccess = flag.Bool("destroy-on-success", true, "remove resources after test success")
 var destroyOnFailure = flag.Bool("destroy-on-failure", false, "remove resources after test failure")
+var destroyOnInterrupt = flag.Bool("destroy-on-interrupt", false, "remove resources of tests interrupted with SIGINT/SIGTERM, otherwise record them in checkpoint.json in the test state directory")
+var suiteBudget = flag.Duration("suite-budget", 0, "wall-clock budget of the test suite: once exceeded, the tests do not start new phases, the running tests are interrupted, their logs collected and their resources removed")
+var collectLogsOnInterrupt = flag.Bool("collect-logs-on-interrupt", false, "collect logs from nodes of tests interrupted with SIGINT/SIGTERM")
 
 var resourceListFile = flag.String("resourcegroup-file", "", "file with list of resources created")
 var collectLogs = flag.Bool("always-collect-logs", true, "collect logs from nodes once tests are finished. otherwise they will only be pulled for failed tests")
 
 var cloudLogProjectID = flag.String("gcl-project-id", "", "enable logging to the cloud")
+var cloudLogOrchestration = flag.Bool("gcl-orchestration-logs", false, "also ship the logs outside of the tests to the cloud, requires -gcl-project-id")
+
+var elasticURL = flag.String("elastic-url", "", "index the test results and the warnings and errors logged into Elasticsearch at the URL")
+var elasticIndex = flag.String("elastic-index", "robotest", "prefix of the Elasticsearch indices, <prefix>-results and <prefix>-events")
+
+var reportFile = flag.String("report-file", "", "write the JSON report of the run into the file, see robotest compare")
+var htmlReportFile = flag.String("html-report-file", "", "write the standalone HTML report of the run into the file")
+var htmlReportUIArtifacts = flag.String("html-report-ui-artifacts", "", "report directory of the UI tests to embed the failure artifacts (screenshots, console logs) of into the HTML report")
+
+var issuesGitHubRepo = flag.String("issues-github-repo", "", "file the consistent product failures as issues in the GitHub repository (owner/name), authenticated with the GITHUB_TOKEN environment variable")
+var issuesJiraURL = flag.String("issues-jira-url", "", "file the consistent product failures as issues in Jira at the URL, authenticated with the JIRA_USER and JIRA_TOKEN environment variables")
+var issuesJiraProject = flag.String("issues-jira-project", "", "key of the Jira project to file the issues in")
+
+var signatureDB = flag.String("signature-db", "", "YAML file with the known failure signatures to match the failures against")
+
+var grafanaURL = flag.String("grafana-url", "", "annotate the suite start and end, test phase changes and injected faults in Grafana at the URL, authenticated with the GRAFANA_TOKEN environment variable")
+
+var stateDiffSteps = flag.Bool("state-diff-steps", true, "record the difference of the cluster state around the install, expand, shrink, failover and upgrade steps of tests composed of steps, i.e. plan")
+var statusAfterSteps = flag.Bool("status-after-steps", false, "log the cluster status on every node after every step of tests composed of steps, i.e. plan")
+
+var progress = flag.Bool("progress", false, "render a live table with the status of every test to stdout")
+var progressAddr = flag.String("progress-addr", "", "serve the status of every test as JSON on http://<addr>/progress")
 
 var debugFlag = flag.Bool("debug", false, "Verbose mode")
 var debugPort = flag.Int("debug-port", 6060, "Profiling port")
+var logModules = flag.String("log-modules", "", "override the log level per module (infra, ssh, ssh.scp, gravity), i.e. 'ssh=debug,gravity=info'")
+var logJSON = flag.Bool("log-json", false, "write the logs as JSON objects")
+var statusAddr = flag.String("status-addr", "", "serve the run state, active SSH sessions, recent node logs and profiling endpoints on http://<addr>")
 
 // max amount of time test will run
 var testMaxTime = time.Hour * 12
 
+// progressInterval defines how often the progress table is redrawn
+const progressInterval = 5 * time.Second
+
 var suites = map[string]*config.Config{
 	"sanity": sanity.Suite(),
 }
@@ -48,10 +92,12 @@ func setupSignals(suite gravity.TestSuite) {
 	signal.Notify(c, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGINT)
 
 	go func() {
-		for s := range c {
-			suite.Logger().WithField("signal", s).Warn(s.String())
-			suite.Cancel(s.String())
-		}
+		s := <-c
+		suite.Logger().WithField("signal", s).Warn("Interrupted, tearing down tests. Repeat to exit immediately.")
+		suite.Cancel(s.String())
+		s = <-c
+		suite.Logger().WithField("signal", s).Error("Interrupted again, exiting. Cloud resources might leak.")
+		os.Exit(1)
 	}()
 }
 
@@ -64,13 +110,25 @@ func TestMain(t *testing.T) {
 		t.Fatal("options required")
 	}
 
-	initLogger(*debugFlag)
+	modules, err := xlog.ParseModuleLevels(*logModules)
+	if err != nil {
+		t.Fatalf("invalid -log-modules: %v", err)
+	}
+	initLogger(*debugFlag, modules, *logJSON)
 	if *debugFlag {
 		debug.StartProfiling(fmt.Sprintf("localhost:%v", *debugPort))
 	}
 
-	config := gravity.LoadConfig(t, []byte(*provision))
-	config = config.WithTag(*tag)
+	configBytes := []byte(*provision)
+	if *provisionFile != "" {
+		var err error
+		configBytes, err = ioutil.ReadFile(*provisionFile)
+		if err != nil {
+			t.Fatalf("failed to read provisioner configuration: %v", err)
+		}
+	}
+	config := gravity.LoadConfig(t, configBytes)
+	config = config.WithTag(*tag).WithSuite(*testSuite)
 
 	suiteCfg, there := suites[*testSuite]
 	if !there {
@@ -81,19 +139,69 @@ func TestMain(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to parse args: %v", err)
 	}
+	focus, err := parseLabelExpr(*focusLabels)
+	if err != nil {
+		t.Fatalf("invalid -focus-labels: %v", err)
+	}
+	skip, err := parseLabelExpr(*skipLabels)
+	if err != nil {
+		t.Fatalf("invalid -skip-labels: %v", err)
+	}
+	testSet = testSet.Filter(focus, skip)
 
 	// testing package has internal 10 mins timeout, can be reset from command line only
 	// see docker/suite/entrypoint.sh
 	ctx, cancel := context.WithTimeout(context.Background(), testMaxTime)
 	defer cancel()
 
+	config, err = gravity.BuildInstaller(ctx, config, log.WithFields(log.Fields{"tag": *tag, "suite": *testSuite}))
+	if err != nil {
+		t.Fatalf("failed to build installer: %v", err)
+	}
+
+	credentials, err := gravity.NewCredentialsProvider(config, log.WithField("tag", *tag))
+	if err != nil {
+		t.Fatalf("failed to configure credentials: %v", err)
+	}
+	config, err = credentials.Issue(ctx, config)
+	if err != nil {
+		t.Fatalf("failed to issue credentials: %v", err)
+	}
+	defer func() {
+		// revoke even if the run has been interrupted
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err := credentials.Revoke(ctx); err != nil {
+			log.WithError(err).Warn("Failed to revoke credentials.")
+		}
+	}()
+
 	policy := gravity.ProvisionerPolicy{
 		DestroyOnSuccess:  *destroyOnSuccess,
 		DestroyOnFailure:  *destroyOnFailure,
 		AlwaysCollectLogs: *collectLogs,
 		ResourceListFile:  *resourceListFile,
+		MaxAttempts:       *maxAttempts,
+
+		DestroyOnInterrupt:     *destroyOnInterrupt,
+		CollectLogsOnInterrupt: *collectLogsOnInterrupt,
 	}
 	gravity.SetProvisionerPolicy(policy)
+	setKnownIssues(config.KnownIssues)
+	if *stateDiffSteps {
+		gravity.AddStateDiffHooks(gravity.StateDiffSteps...)
+	}
+	if *statusAfterSteps {
+		gravity.AddAfterHook("", gravity.StatusHook)
+	}
+	var annotations *grafana.Client
+	if *grafanaURL != "" {
+		annotations, err = grafana.New(*grafanaURL, os.Getenv("GRAFANA_TOKEN"))
+		if err != nil {
+			t.Fatalf("invalid -grafana-url: %v", err)
+		}
+		gravity.SetAnnotations(annotations)
+	}
 
 	suite := gravity.NewSuite(ctx, t, *cloudLogProjectID, log.Fields{
 		"test_suite":         *testSuite,
@@ -104,34 +212,286 @@ func TestMain(t *testing.T) {
 		"fail_fast":          *failFast,
 	}, *failFast)
 	defer suite.Close()
+	suite.SetBudget(*suiteBudget)
+	if *cloudLogOrchestration {
+		if err := suite.ShipLogs(log.StandardLogger(), *testSuite); err != nil {
+			log.WithError(err).Warn("Orchestration logs are not shipped to the cloud.")
+		}
+	}
+	var exporter *xlog.ElasticExporter
+	if *elasticURL != "" {
+		exporter, err = xlog.NewElasticExporter(ctx, *elasticURL, *elasticIndex)
+		if err != nil {
+			log.WithError(err).Warn("Results are not exported to Elasticsearch.")
+		} else {
+			defer exporter.Close()
+			log.AddHook(exporter.Hook())
+			suite.AddLogHook(exporter.Hook())
+		}
+	}
 	setupSignals(suite)
+	if *progressAddr != "" {
+		serveProgress(*progressAddr, suite.Progress())
+	}
+	if *statusAddr != "" {
+		debug.StartServer(*statusAddr, suite.DebugHandler())
+	}
+	stopProgress := func() {}
+	if *progress {
+		stopProgress = renderProgress(suite.Progress(), progressInterval)
+	}
 
 	for r := 1; r <= *repeat; r++ {
 		for ts, entry := range testSet {
 			suite.Schedule(entry.TestFunc,
-				config.WithTag(fmt.Sprintf("%s-%d", ts, r)),
+				config.WithQuarantine(ts).WithTag(fmt.Sprintf("%s-%d", ts, r)),
 				entry.Param)
 		}
 	}
 
+	annotateSuite(ctx, annotations, fmt.Sprintf("%v %v started", *testSuite, *tag))
+	started := time.Now()
 	result := suite.Run()
+	report := gravity.RunReport{
+		Suite:     *testSuite,
+		Tag:       *tag,
+		Started:   started,
+		Completed: time.Now(),
+		Results:   result,
+	}
+	if *reportFile != "" {
+		if err := gravity.WriteReport(*reportFile, report); err != nil {
+			log.WithError(err).Warn("Failed to write report.")
+		}
+	}
+	if *htmlReportFile != "" {
+		err := gravity.WriteHTMLReport(*htmlReportFile, gravity.HTMLReportConfig{
+			Report:      report,
+			UIArtifacts: *htmlReportUIArtifacts,
+			Log:         suite.Logger(),
+		})
+		if err != nil {
+			log.WithError(err).Warn("Failed to write HTML report.")
+		}
+	}
+	for _, tracker := range issueTrackers() {
+		if err := gravity.FileIssues(ctx, tracker, report, suite.Logger()); err != nil {
+			log.WithError(err).Warn("Failed to file issues.")
+		}
+	}
+	annotateSuite(ctx, annotations, fmt.Sprintf("%v %v completed: %v", *testSuite, *tag, summarize(result)))
+	stopProgress()
+	if *progress {
+		suite.Progress().Render(os.Stdout)
+	}
 	logger := suite.Logger()
 	for _, res := range result {
 		logger.Debugf("%s %s %q %s", res.Name, res.Status, res.LogUrl, xlog.ToJSON(res.Param))
 	}
 
 	fmt.Println("\n******** TEST SUITE COMPLETED **********")
+	var total float64
 	for _, res := range result {
-		fmt.Printf("%s %s %s %s\n", res.Status, res.Name, xlog.ToJSON(res.Param), res.LogUrl)
+		fmt.Printf("%s %s %s %s $%.2f\n", res.Status, res.Name, xlog.ToJSON(res.Param), res.LogUrl,
+			res.EstimatedCost.Total())
+		total += res.EstimatedCost.Total()
+		if res.Chaos != nil {
+			fmt.Printf("  chaos seed=%d %s\n", res.Chaos.Seed, xlog.ToJSON(res.Chaos.Events))
+		}
+		for _, diff := range res.StateDiffs {
+			fmt.Printf("  state diff %s %s\n", diff.Name, xlog.ToJSON(diff))
+		}
+	}
+	fmt.Printf("Estimated cloud cost: $%.2f\n", total)
+	if exporter != nil {
+		exportResults(ctx, exporter, result)
+	}
+
+	fmt.Println("\n******** TEST CLASSIFICATION **********")
+	for _, res := range gravity.Classify(result) {
+		fmt.Printf("%s %s attempts=%d\n", res.Class, res.Test, len(res.Attempts))
+		if res.Quarantine != nil {
+			fmt.Printf("  quarantined: %s %s\n", res.Quarantine.Ticket, res.Quarantine.Reason)
+		}
+		if res.Class == gravity.ClassTimedOut {
+			fmt.Printf("  timed out at phase %s\n", res.Attempts[len(res.Attempts)-1].TimedOutPhase)
+		}
+		for _, attempt := range res.Attempts {
+			if attempt.Error != "" {
+				fmt.Printf("  #%d %s [%s]: %s\n", attempt.Attempt, attempt.Status, attempt.Category, attempt.Error)
+			}
+		}
+	}
+
+	fmt.Println("\n******** FAILURE SIGNATURES **********")
+	for _, group := range gravity.GroupBySignature(result) {
+		fmt.Printf("%s [%s] x%d: %s\n", group.Signature, group.Category, len(group.Attempts), group.Error)
+		if group.KnownIssue != nil {
+			fmt.Printf("  known issue: %s %s %s\n", group.KnownIssue.Ticket, group.KnownIssue.Description, group.KnownIssue.Issue)
+		}
+		fmt.Printf("  %s\n", strings.Join(group.Attempts, " "))
+	}
+}
+
+// setKnownIssues sets the known failures from the configuration and the signature database.
+// Only the failures with a ticket suppress the failing tests, see failure.KnownSignature.Acknowledged
+func setKnownIssues(known []failure.KnownSignature) {
+	if *signatureDB != "" {
+		signatures, err := failure.ReadSignatures(*signatureDB)
+		if err != nil {
+			log.WithError(err).Warn("Failures are not matched against the signature database.")
+		}
+		known = append(known, signatures...)
+	}
+	now := time.Now()
+	for _, issue := range known {
+		if issue.Expired(now) {
+			log.WithField("ticket", issue.Ticket).Warnf("Known issue %q expired on %v.",
+				issue.Description, issue.Expires.Format("2006-01-02"))
+		}
+	}
+	db, err := failure.NewSignatureDB(known)
+	if err != nil {
+		log.WithError(err).Warn("Failures are not matched against known signatures.")
+		return
+	}
+	gravity.SetKnownIssues(db)
+}
+
+// issueTrackers returns the configured trackers to file the consistent failures in
+func issueTrackers() (trackers []issues.Tracker) {
+	if *issuesGitHubRepo != "" {
+		tracker, err := issues.NewGitHub(issues.GitHubConfig{
+			Repository: *issuesGitHubRepo,
+			Token:      os.Getenv("GITHUB_TOKEN"),
+			Labels:     []string{"robotest"},
+		})
+		if err != nil {
+			log.WithError(err).Warn("Issues are not filed in GitHub.")
+		} else {
+			trackers = append(trackers, tracker)
+		}
+	}
+	if *issuesJiraURL != "" {
+		tracker, err := issues.NewJira(issues.JiraConfig{
+			URL:     *issuesJiraURL,
+			Project: *issuesJiraProject,
+			User:    os.Getenv("JIRA_USER"),
+			Token:   os.Getenv("JIRA_TOKEN"),
+			Labels:  []string{"robotest"},
+		})
+		if err != nil {
+			log.WithError(err).Warn("Issues are not filed in Jira.")
+		} else {
+			trackers = append(trackers, tracker)
+		}
+	}
+	return trackers
+}
+
+// annotateSuite annotates the suite event in Grafana, if configured
+func annotateSuite(ctx context.Context, annotations *grafana.Client, text string) {
+	if annotations == nil {
+		return
+	}
+	err := annotations.Annotate(ctx, grafana.Annotation{
+		Time: time.Now(),
+		Tags: []string{"robotest", "suite", *testSuite, *tag},
+		Text: text,
+	})
+	if err != nil {
+		log.WithError(err).Warn("Failed to annotate in Grafana.")
+	}
+}
+
+// summarize returns the number of tests per status
+func summarize(results []gravity.TestStatus) string {
+	counts := map[string]int{}
+	var statuses []string
+	for _, res := range results {
+		if counts[res.Status] == 0 {
+			statuses = append(statuses, res.Status)
+		}
+		counts[res.Status]++
+	}
+	var summary []string
+	for _, status := range statuses {
+		summary = append(summary, fmt.Sprintf("%v %v", counts[status], status))
+	}
+	return strings.Join(summary, ", ")
+}
+
+// exportResults indexes the test results into Elasticsearch
+func exportResults(ctx context.Context, exporter *xlog.ElasticExporter, results []gravity.TestStatus) {
+	now := time.Now()
+	for _, res := range results {
+		err := exporter.IndexResult(ctx, xlog.ElasticResult{
+			Timestamp:     now,
+			RunID:         res.Name,
+			SuiteUID:      res.SuiteUID,
+			Suite:         *testSuite,
+			Test:          res.Test,
+			Attempt:       res.Attempt,
+			Status:        res.Status,
+			Category:      string(res.Category),
+			Error:         res.Error,
+			Preempted:     res.Preempted,
+			Quarantined:   res.Quarantine != nil,
+			LogURL:        res.LogUrl,
+			EstimatedCost: res.EstimatedCost.Total(),
+			Param:         xlog.ToJSON(res.Param),
+		})
+		if err != nil {
+			log.WithError(err).WithField("test", res.Name).Warn("Failed to export result to Elasticsearch.")
+		}
+	}
+}
+
+// serveProgress serves the test progress as JSON on addr
+func serveProgress(addr string, progress *gravity.Progress) {
+	mux := http.NewServeMux()
+	mux.Handle("/progress", progress)
+	go func() {
+		log.WithError(http.ListenAndServe(addr, mux)).Warn("Progress endpoint stopped.")
+	}()
+}
+
+// renderProgress redraws the test progress table in the terminal
+// every interval until the returned function is called
+func renderProgress(progress *gravity.Progress, interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				// clear the screen and move the cursor home
+				fmt.Print("\033[H\033[2J")
+				fmt.Printf("%v\n\n", time.Now().Format(time.RFC3339))
+				progress.Render(os.Stdout)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+func parseLabelExpr(expr string) (config.LabelExpr, error) {
+	if expr == "" {
+		return nil, nil
 	}
+	return config.ParseLabelExpr(expr)
 }
 
-func initLogger(debug bool) {
+func initLogger(debug bool, modules map[string]log.Level, json bool) {
 	level := log.InfoLevel
 	if debug {
 		level = log.DebugLevel
 	}
 	log.StandardLogger().Hooks = make(log.LevelHooks)
+	log.AddHook(secret.Hook{})
 	log.SetOutput(os.Stderr)
-	log.SetLevel(level)
+	xlog.Configure(xlog.Config{Level: level, Modules: modules, JSON: json})
 }